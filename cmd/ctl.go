@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/api"
+)
+
+const defaultCtlAddress = "http://127.0.0.1:9223"
+
+// runCtl implements "dns-ha ctl", which queries the status API of a running instance, and returns the exit code.
+func runCtl(args []string) int {
+	flags := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	address := flags.String("addr", defaultCtlAddress, "Address of the dns-ha metrics server, may contain basic auth credentials")
+	flags.Usage = func() {
+		//nolint forbidigo
+		fmt.Fprintf(flags.Output(), "Usage: dns-ha ctl [flags] history <hostname>\n")
+		//nolint forbidigo
+		fmt.Fprintf(flags.Output(), "       dns-ha ctl [flags] inject-failure <hostname> <ip> <duration>\n")
+		//nolint forbidigo
+		fmt.Fprintf(flags.Output(), "       dns-ha ctl [flags] clear-failure <hostname> <ip>\n")
+		//nolint forbidigo
+		fmt.Fprintf(flags.Output(), "       dns-ha ctl [flags] export-state\n")
+		//nolint forbidigo
+		fmt.Fprintf(flags.Output(), "       dns-ha ctl [flags] import-state <file|->\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	client, err := api.NewClient(*address)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	switch {
+	case flags.NArg() == 2 && flags.Arg(0) == "history":
+		history, err := client.History(ctx, flags.Arg(1))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not get history: %v\n", err)
+			return 1
+		}
+		printHistory(os.Stdout, history)
+		return 0
+	case flags.NArg() == 4 && flags.Arg(0) == "inject-failure":
+		duration, err := time.ParseDuration(flags.Arg(3))
+		if err != nil || duration <= 0 {
+			fmt.Fprintf(os.Stderr, "invalid duration %q\n", flags.Arg(3))
+			return 2
+		}
+		if err := client.InjectFailure(ctx, flags.Arg(1), flags.Arg(2), duration); err != nil {
+			fmt.Fprintf(os.Stderr, "could not inject failure: %v\n", err)
+			return 1
+		}
+		return 0
+	case flags.NArg() == 3 && flags.Arg(0) == "clear-failure":
+		if err := client.InjectFailure(ctx, flags.Arg(1), flags.Arg(2), 0); err != nil {
+			fmt.Fprintf(os.Stderr, "could not clear failure: %v\n", err)
+			return 1
+		}
+		return 0
+	case flags.NArg() == 1 && flags.Arg(0) == "export-state":
+		state, err := client.ExportState(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not export state: %v\n", err)
+			return 1
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(state); err != nil {
+			fmt.Fprintf(os.Stderr, "could not write state: %v\n", err)
+			return 1
+		}
+		return 0
+	case flags.NArg() == 2 && flags.Arg(0) == "import-state":
+		state, err := readState(flags.Arg(1))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not read state: %v\n", err)
+			return 1
+		}
+		if err := client.ImportState(ctx, *state); err != nil {
+			fmt.Fprintf(os.Stderr, "could not import state: %v\n", err)
+			return 1
+		}
+		return 0
+	default:
+		flags.Usage()
+		return 2
+	}
+}
+
+// readState reads an exported state from the file or from stdin if the path is "-".
+func readState(path string) (*internal.State, error) {
+	in := os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	var state internal.State
+	if err := json.NewDecoder(in).Decode(&state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func printHistory(out io.Writer, history *api.HistoryResponse) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "IP\tTIME\tRESULT\tLATENCY\tSTATUS\tERROR")
+	for _, record := range history.Records {
+		for _, result := range record.Results {
+			outcome := "healthy"
+			if !result.Healthy {
+				outcome = "unhealthy"
+			}
+			if result.Injected {
+				outcome = "injected"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", record.Ip, result.Timestamp.Format(time.RFC3339), outcome,
+				result.Latency.Round(time.Microsecond), result.Status, result.Error)
+		}
+	}
+}
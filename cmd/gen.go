@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/monitoring"
+)
+
+const (
+	dashboardFile  = "dns-ha-dashboard.json"
+	alertRulesFile = "dns-ha-alerts.yaml"
+)
+
+// runGenDashboards implements "dns-ha gen-dashboards", which writes a Grafana dashboard and Prometheus alerting rules
+// for the configured hostnames, and returns the exit code.
+func runGenDashboards(args []string) int {
+	flags := flag.NewFlagSet("gen-dashboards", flag.ContinueOnError)
+	configFile := flags.String("config", defaultConfigFile, "Config file, directory containing config fragments or remote location")
+	outputDir := flags.String("output", ".", "Directory the dashboard and alerting rules are written to")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	config, err := conf.Read(*configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not read config: %v\n", err)
+		return 1
+	}
+
+	hostnames := make([]monitoring.Hostname, 0, len(config.Records))
+	for hostname, records := range config.Records {
+		ips := make([]string, 0, len(records))
+		for _, record := range records {
+			ips = append(ips, record.Address())
+		}
+		hostnames = append(hostnames, monitoring.Hostname{Name: hostname, Ips: ips})
+	}
+
+	metrics := monitoring.Metrics{Namespace: config.MetricsNamespace, Labels: config.MetricsLabels}
+	dashboard, err := monitoring.Dashboard(hostnames, metrics)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not generate dashboard: %v\n", err)
+		return 1
+	}
+
+	rules, err := monitoring.AlertRules(hostnames, metrics)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not generate alerting rules: %v\n", err)
+		return 1
+	}
+
+	outputs := []struct {
+		file string
+		data []byte
+	}{
+		{file: dashboardFile, data: dashboard},
+		{file: alertRulesFile, data: rules},
+	}
+	for _, output := range outputs {
+		path := filepath.Join(*outputDir, output.file)
+		if err := os.WriteFile(path, output.data, 0644); err != nil { //nolint G306
+			fmt.Fprintf(os.Stderr, "could not write %s: %v\n", path, err)
+			return 1
+		}
+		//nolint forbidigo
+		fmt.Printf("Wrote %s\n", path)
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"go.uber.org/multierr"
+)
+
+type healthGroup struct {
+	conf  conf.HealthGroupConfig
+	check *internal.HealthGroup
+}
+
+// healthGroups are the health groups of the config by name. They are built once at startup, changes of the groups
+// require a restart.
+type healthGroups map[string]healthGroup
+
+func buildHealthGroups(c *conf.Config) (healthGroups, error) {
+	ret := make(healthGroups, len(c.HealthGroups))
+	var errs error
+	for name, groupConf := range c.HealthGroups {
+		group, err := buildHealthGroup(name, groupConf, c.CheckInterval)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("could not build health group %q: %w", name, err))
+			continue
+		}
+		ret[name] = healthGroup{conf: groupConf, check: group}
+	}
+	return ret, errs
+}
+
+func buildHealthGroup(name string, groupConf conf.HealthGroupConfig, checkInterval time.Duration) (*internal.HealthGroup, error) {
+	recordType := "AAAA"
+	if addr, err := netip.ParseAddr(groupConf.IP); err == nil && addr.Unmap().Is4() {
+		recordType = "A"
+	}
+	record, err := internal.NewDnsRecord(conf.RecordConfig{IP: groupConf.IP, RecordType: recordType})
+	if err != nil {
+		return nil, err
+	}
+
+	checker, err := buildHealthcheck(cmp.Or(groupConf.Host, record.Ip.String()), record, groupConf.HealthcheckConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return internal.NewHealthGroup(name, checker, cmp.Or(groupConf.Interval, checkInterval/2))
+}
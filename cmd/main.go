@@ -1,23 +1,39 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"log/slog"
+	"net"
 	"os"
 	"os/signal"
+	"os/user"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/api"
 	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/decision"
+	"github.com/soerenschneider/dns-ha/internal/dns"
+	"github.com/soerenschneider/dns-ha/internal/dns/bind"
+	"github.com/soerenschneider/dns-ha/internal/dns/files"
+	"github.com/soerenschneider/dns-ha/internal/dns/hosts"
+	"github.com/soerenschneider/dns-ha/internal/dns/provider"
 	"github.com/soerenschneider/dns-ha/internal/dns/unbound"
 	"github.com/soerenschneider/dns-ha/internal/healthcheck"
+	"github.com/soerenschneider/dns-ha/internal/hooks"
+	"github.com/soerenschneider/dns-ha/internal/kubernetes"
 	"github.com/soerenschneider/dns-ha/internal/metrics"
+	"github.com/soerenschneider/dns-ha/internal/notify"
+	"github.com/soerenschneider/dns-ha/internal/privileges"
+	"github.com/soerenschneider/dns-ha/internal/schedule"
 	"github.com/soerenschneider/dns-ha/internal/service"
 	"go.uber.org/multierr"
 )
@@ -26,6 +42,7 @@ const defaultConfigFile = "/etc/dns-ha.yaml"
 
 var (
 	flagConfigFile   string
+	flagConfigPoll   time.Duration
 	flagDebug        bool
 	flagPrintVersion bool
 
@@ -34,13 +51,25 @@ var (
 )
 
 func parseFlags() {
-	flag.StringVar(&flagConfigFile, "config", defaultConfigFile, "Config file")
+	flag.StringVar(&flagConfigFile, "config", defaultConfigFile, "Config file (yaml, json or toml), directory containing config fragments or remote location (http(s)://, s3://, etcd(s)://, consul(s)://)")
+	flag.DurationVar(&flagConfigPoll, "config-poll-interval", 0, "Interval to re-read the config and apply changed records, 0 disables polling")
 	flag.BoolVar(&flagDebug, "debug", false, "Print debug logs")
 	flag.BoolVar(&flagPrintVersion, "version", false, "Print version and exit")
 	flag.Parse()
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "ctl":
+			os.Exit(runCtl(os.Args[2:]))
+		case "gen-dashboards":
+			os.Exit(runGenDashboards(os.Args[2:]))
+		case "preflight":
+			os.Exit(runPreflight(os.Args[2:]))
+		}
+	}
+
 	parseFlags()
 
 	if flagPrintVersion {
@@ -52,7 +81,7 @@ func main() {
 	setupLogging()
 	slog.Info("Starting dns-ha", "version", BuildVersion)
 
-	conf, err := conf.ReadFromFile(flagConfigFile)
+	conf, err := conf.Read(flagConfigFile)
 	if err != nil {
 		log.Fatalf("could not read config: %v", err)
 	}
@@ -61,43 +90,490 @@ func main() {
 		log.Fatalf("validating config failed: %v", err)
 	}
 
-	dbConfWrapper, err := unbound.NewUnboundConfigWrapper(conf.Unbound.DbFile, conf.Unbound.CreateFile)
+	// the config and its secrets have been read at this point, everything else runs as the unprivileged user
+	if conf.Privileges != nil {
+		if err := privileges.Drop(conf.Privileges.User, conf.Privileges.Group); err != nil {
+			log.Fatalf("could not drop privileges: %v", err)
+		}
+		// ICMP sockets are opened for each check, fail now rather than with each check
+		if conf.UsesIcmp() {
+			if err := healthcheck.UnprivilegedPingPermitted(); err != nil {
+				log.Fatalf("icmp checks are not permitted after dropping privileges, the group needs to be within net.ipv4.ping_group_range: %v", err)
+			}
+		}
+	}
+
+	var db internal.DnsDb
+	var svc internal.Service
+	var watcher driftWatcher
+	if conf.Bind != nil {
+		db, svc = buildBind(conf.Bind, conf.Service)
+	} else if conf.MsDns != nil {
+		db, svc = buildMsDns(conf)
+	} else if conf.Resolved != nil {
+		db, svc = buildResolved(conf.Resolved, conf.Service)
+	} else if conf.CoreDns != nil {
+		db = buildCoreDns(conf.CoreDns, conf.Service)
+	} else if conf.Nsd != nil {
+		db, svc = buildNsd(conf.Nsd, conf.Service)
+	} else if conf.Knot != nil {
+		db, svc = buildKnot(conf.Knot, conf.Service)
+	} else {
+		db, svc, watcher = buildUnbound(conf.Unbound, conf.Service)
+		if len(conf.UnboundInstances) > 0 {
+			db, svc, watcher = buildUnboundInstances(conf, &dns.Instance{Db: db, Service: svc}, watcher)
+		}
+	}
+
+	routes, providers := buildProviderRoutes(conf)
+	if len(routes) > 0 {
+		router, err := dns.NewRouter(db, routes)
+		if err != nil {
+			log.Fatalf("could not create dns router: %v", err)
+		}
+		db = router
+	}
+
+	groups, err := buildHealthGroups(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	targets := newTargetResolver()
+	managedRecords, err := getManagedDnsRecords(targets.resolve(context.Background(), conf).Records, groups)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	run(db, svc, watcher, managedRecords, conf, targets, groups, providers)
+}
+
+func buildUnbound(unboundConf conf.UnboundConfig, serviceConf conf.ServiceConfig) (internal.DnsDb, internal.Service, driftWatcher) {
+	attrs, err := buildFileAttributes(unboundConf.File, unbound.DefaultFileMode)
+	if err != nil {
+		log.Fatalf("could not build file attributes: %v", err)
+	}
+
+	dbConfWrapper, err := unbound.NewUnboundConfigWrapper(unboundConf.DbFile, unboundConf.CreateFile,
+		unbound.WithBackups(unboundConf.Backups),
+		unbound.WithFileAttributes(attrs),
+		unbound.WithCheckconf(unboundConf.Checkconf.Binary, unboundConf.Checkconf.Args, unboundConf.Checkconf.Target),
+	)
 	if err != nil {
 		log.Fatalf("could not create unbound config wrapper: %v", err)
 	}
-	var db internal.DnsDb
-	db, err = unbound.NewUnbound(dbConfWrapper)
+	db, err := unbound.NewUnbound(dbConfWrapper, unbound.WithClientViews(unboundConf.Views))
 	if err != nil {
 		log.Fatalf("could not create unbound service: %v", err)
 	}
 
 	var svc internal.Service
-	svc, err = service.NewSystemdService("unbound")
+	svc, err = service.NewSystemdService(unboundConf.ServiceName, service.WithFlushCommand(serviceConf.FlushCacheCommand))
 	if err != nil {
 		log.Fatalf("could not create systemd service: %v", err)
 	}
+	if len(unboundConf.ReloadCommand) > 0 {
+		svc, err = service.NewUnboundControlService(svc, service.WithReloadCommand(unboundConf.ReloadCommand))
+		if err != nil {
+			log.Fatalf("could not create unbound-control service: %v", err)
+		}
+	}
+
+	var watcher driftWatcher
+	if unboundConf.Watch {
+		watcher = dbConfWrapper
+	}
+	return db, svc, watcher
+}
+
+// buildFileAttributes resolves the owner and group of the file config, which are either names or numeric ids.
+func buildFileAttributes(c conf.FileConfig, defaultMode os.FileMode) (files.Attributes, error) {
+	attrs := files.ModeOnly(defaultMode)
+	if c.Mode != "" {
+		mode, err := conf.ParseFileMode(c.Mode)
+		if err != nil {
+			return attrs, err
+		}
+		attrs.Mode = mode
+	}
+	if c.Restorecon {
+		attrs.Restorecon = files.DefaultRestoreconBinary
+	}
+
+	if c.Owner != "" {
+		uid, err := strconv.Atoi(c.Owner)
+		if err != nil {
+			owner, err := user.Lookup(c.Owner)
+			if err != nil {
+				return attrs, err
+			}
+			// the ids are numeric on all platforms that support changing the owner
+			if uid, err = strconv.Atoi(owner.Uid); err != nil {
+				return attrs, fmt.Errorf("unsupported uid %q of user %q", owner.Uid, c.Owner)
+			}
+		}
+		attrs.Uid = uid
+	}
+
+	if c.Group != "" {
+		gid, err := strconv.Atoi(c.Group)
+		if err != nil {
+			group, err := user.LookupGroup(c.Group)
+			if err != nil {
+				return attrs, err
+			}
+			if gid, err = strconv.Atoi(group.Gid); err != nil {
+				return attrs, fmt.Errorf("unsupported gid %q of group %q", group.Gid, c.Group)
+			}
+		}
+		attrs.Gid = gid
+	}
 
-	managedRecords, err := getManagedDnsRecords(conf.Records)
+	return attrs, nil
+}
+
+// buildUnboundInstances manages the hostnames that are assigned to additional unbound instances at their instance,
+// all other hostnames are managed at the default instance.
+func buildUnboundInstances(c *conf.Config, defaultInstance *dns.Instance, defaultWatcher driftWatcher) (internal.DnsDb, internal.Service, driftWatcher) {
+	var watchers multiWatcher
+	if defaultWatcher != nil {
+		watchers = append(watchers, defaultWatcher)
+	}
+
+	instances := make(map[string]*dns.Instance, len(c.UnboundInstances))
+	for name, instanceConf := range c.UnboundInstances {
+		db, svc, watcher := buildUnbound(instanceConf, c.Service)
+		instances[name] = &dns.Instance{Db: db, Service: svc}
+		if watcher != nil {
+			watchers = append(watchers, watcher)
+		}
+	}
+
+	routes := map[string]*dns.Instance{}
+	for hostname, hostnameConf := range c.Hostnames {
+		if hostnameConf.Unbound != "" {
+			routes[hostname] = instances[hostnameConf.Unbound]
+		}
+	}
+
+	multi, err := dns.NewInstances(defaultInstance, routes)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("could not create unbound instances: %v", err)
+	}
+
+	if len(watchers) == 0 {
+		return multi, multi, nil
+	}
+	return multi, multi, watchers
+}
+
+func buildBind(bindConf *conf.BindConfig, serviceConf conf.ServiceConfig) (internal.DnsDb, internal.Service) {
+	zoneFile, err := bind.NewZoneFile(bindConf.ZoneFile, bindConf.Zone,
+		bind.WithBackups(bindConf.Backups),
+		bind.WithCheckzone(bindConf.CheckzoneBinary, bindConf.CheckzoneArgs),
+	)
+	if err != nil {
+		log.Fatalf("could not create zone file wrapper: %v", err)
+	}
+
+	db, err := bind.NewBind(bindConf.Zone, zoneFile)
+	if err != nil {
+		log.Fatalf("could not create bind backend: %v", err)
+	}
+
+	svc, err := service.NewRndcService(bindConf.Zone, service.WithRndcCommand(bindConf.RndcBinary, bindConf.RndcArgs))
+	if err != nil {
+		log.Fatalf("could not create rndc service: %v", err)
+	}
+
+	if len(serviceConf.FlushCacheCommand) > 0 {
+		slog.Warn("flush_cache_command is ignored for bind")
+	}
+	return db, svc
+}
+
+func buildNsd(nsdConf *conf.ZoneServerConfig, serviceConf conf.ServiceConfig) (internal.DnsDb, internal.Service) {
+	db := buildZoneFile(nsdConf, cmp.Or(nsdConf.CheckzoneBinary, bind.DefaultNsdCheckzoneBinary), nsdConf.CheckzoneArgs)
+
+	svc, err := service.NewNsdControlService(nsdConf.Zone, service.WithControlCommand(nsdConf.ControlBinary, nsdConf.ControlArgs))
+	if err != nil {
+		log.Fatalf("could not create nsd-control service: %v", err)
+	}
+
+	if len(serviceConf.FlushCacheCommand) > 0 {
+		slog.Warn("flush_cache_command is ignored for nsd")
+	}
+	return db, svc
+}
+
+func buildKnot(knotConf *conf.ZoneServerConfig, serviceConf conf.ServiceConfig) (internal.DnsDb, internal.Service) {
+	checkzoneArgs := knotConf.CheckzoneArgs
+	if knotConf.CheckzoneBinary == "" && len(checkzoneArgs) == 0 {
+		// kzonecheck expects the origin as option value, the zone file is its only argument
+		checkzoneArgs = []string{"-o"}
+	}
+	db := buildZoneFile(knotConf, cmp.Or(knotConf.CheckzoneBinary, bind.DefaultKzonecheckBinary), checkzoneArgs)
+
+	svc, err := service.NewKnotcService(knotConf.Zone, service.WithControlCommand(knotConf.ControlBinary, knotConf.ControlArgs))
+	if err != nil {
+		log.Fatalf("could not create knotc service: %v", err)
+	}
+
+	if len(serviceConf.FlushCacheCommand) > 0 {
+		slog.Warn("flush_cache_command is ignored for knot")
+	}
+	return db, svc
+}
+
+func buildZoneFile(zoneConf *conf.ZoneServerConfig, checkzoneBinary string, checkzoneArgs []string) internal.DnsDb {
+	zoneFile, err := bind.NewZoneFile(zoneConf.ZoneFile, zoneConf.Zone,
+		bind.WithBackups(zoneConf.Backups),
+		bind.WithCheckzone(checkzoneBinary, checkzoneArgs),
+	)
+	if err != nil {
+		log.Fatalf("could not create zone file wrapper: %v", err)
+	}
+
+	db, err := bind.NewBind(zoneConf.Zone, zoneFile)
+	if err != nil {
+		log.Fatalf("could not create zone file backend: %v", err)
+	}
+	return db
+}
+
+func buildResolved(resolvedConf *conf.ResolvedConfig, serviceConf conf.ServiceConfig) (internal.DnsDb, internal.Service) {
+	db := buildHosts(resolvedConf.HostsFile, resolvedConf.Backups)
+
+	svc, err := service.NewResolvedService(service.WithResolvectlBinary(resolvedConf.ResolvectlBinary))
+	if err != nil {
+		log.Fatalf("could not create resolved service: %v", err)
+	}
+
+	if len(serviceConf.FlushCacheCommand) > 0 {
+		slog.Warn("flush_cache_command is ignored for resolved")
+	}
+	return db, svc
+}
+
+// buildCoreDns returns no service, CoreDNS reloads the hosts file on its own.
+func buildCoreDns(coreDnsConf *conf.CoreDnsConfig, serviceConf conf.ServiceConfig) internal.DnsDb {
+	if len(serviceConf.FlushCacheCommand) > 0 {
+		slog.Warn("flush_cache_command is ignored for coredns")
+	}
+	return buildHosts(coreDnsConf.HostsFile, coreDnsConf.Backups)
+}
+
+func buildHosts(path string, backups int) internal.DnsDb {
+	hostsFile, err := hosts.NewHostsFile(path, hosts.WithBackups(backups))
+	if err != nil {
+		log.Fatalf("could not create hosts file wrapper: %v", err)
+	}
+
+	db, err := hosts.NewHosts(hostsFile)
+	if err != nil {
+		log.Fatalf("could not create hosts backend: %v", err)
+	}
+	return db
+}
+
+// buildMsDns manages all hostnames that are not assigned to a dns provider in the zone of the Microsoft DNS Server.
+func buildMsDns(c *conf.Config) (internal.DnsDb, internal.Service) {
+	msDns, err := provider.NewMsDns(
+		provider.WithPowershell(c.MsDns.PowershellBinary),
+		provider.WithComputerName(c.MsDns.ComputerName),
+	)
+	if err != nil {
+		log.Fatalf("could not create microsoft dns server backend: %v", err)
+	}
+
+	zones := map[string]provider.Zone{}
+	for hostname := range c.Records {
+		if c.ProviderOf(hostname) == "" {
+			zones[hostname] = provider.Zone{Name: c.MsDns.Zone, Provider: msDns}
+		}
+	}
+	db, err := provider.NewDb(zones)
+	if err != nil {
+		log.Fatalf("could not create microsoft dns server backend: %v", err)
+	}
+
+	svc, err := service.NewWindowsService(c.MsDns.ServiceName, service.WithWindowsFlushCommand(c.Service.FlushCacheCommand))
+	if err != nil {
+		log.Fatalf("could not create windows service: %v", err)
+	}
+	return db, svc
+}
+
+// buildProviderRoutes returns the dns provider backend for each hostname that is managed at a provider along with the
+// providers, whose configs can be refreshed.
+func buildProviderRoutes(c *conf.Config) (map[string]internal.DnsDb, providerSet) {
+	providers := providerSet{}
+	zones := map[string]provider.Zone{}
+	var opts []provider.DbOpts
+	for hostname, hostnameConf := range c.Hostnames {
+		if hostnameConf.Provider == "" {
+			continue
+		}
+
+		p, found := providers[hostnameConf.Provider]
+		if !found {
+			var err error
+			p, err = newRefreshableProvider(c.DnsProviders[hostnameConf.Provider])
+			if err != nil {
+				log.Fatalf("could not build dns provider %q: %v", hostnameConf.Provider, err)
+			}
+			providers[hostnameConf.Provider] = p
+		}
+		zones[hostname] = provider.Zone{Name: hostnameConf.Zone, Provider: p}
+		if len(hostnameConf.ClientSubnets) > 0 {
+			opts = append(opts, provider.WithClientSubnets(hostname, hostnameConf.ClientSubnets))
+		}
 	}
-	run(db, svc, managedRecords, conf)
+
+	if len(zones) == 0 {
+		return nil, nil
+	}
+
+	db, err := provider.NewDb(zones, opts...)
+	if err != nil {
+		log.Fatalf("could not build dns provider backend: %v", err)
+	}
+
+	routes := make(map[string]internal.DnsDb, len(zones))
+	for hostname := range zones {
+		routes[hostname] = db
+		for view := range c.Hostnames[hostname].ClientSubnets {
+			routes[hostname+internal.ViewSeparator+view] = db
+		}
+	}
+	return routes, providers
+}
+
+func buildProvider(providerConf conf.DnsProviderConfig) (provider.Provider, error) {
+	var opts []provider.ProviderOpts
+	if providerConf.BaseUrl != "" {
+		opts = append(opts, provider.WithBaseUrl(providerConf.BaseUrl))
+	}
+
+	switch providerConf.Type {
+	case provider.HetznerProviderName:
+		return provider.NewHetzner(providerConf.Token, opts...)
+	case provider.DigitalOceanProviderName:
+		return provider.NewDigitalOcean(providerConf.Token, opts...)
+	case provider.GandiProviderName:
+		return provider.NewGandi(providerConf.Token, opts...)
+	case provider.OvhProviderName:
+		return provider.NewOvh(providerConf.ApplicationKey, providerConf.ApplicationSecret, providerConf.ConsumerKey, opts...)
+	case provider.DesecProviderName:
+		return provider.NewDesec(providerConf.Token, opts...)
+	case provider.DynDns2ProviderName:
+		// the base url is the update url, it's not passed as option
+		return provider.NewDynDns2(providerConf.BaseUrl, providerConf.Username, providerConf.Token)
+	case provider.DuckDnsProviderName:
+		return provider.NewDuckDns(providerConf.Token, opts...)
+	case provider.PowerDnsProviderName:
+		// the base url is the url of the server, it's not passed as option
+		return provider.NewPowerDns(providerConf.BaseUrl, providerConf.Token)
+	default:
+		return nil, fmt.Errorf("no dns provider %q available", providerConf.Type)
+	}
+}
+
+// driftWatcher notifies about external modifications of the DNS backend.
+type driftWatcher interface {
+	Watch(ctx context.Context, onChange func()) error
+}
+
+// multiWatcher watches several DNS backends at once.
+type multiWatcher []driftWatcher
+
+func (m multiWatcher) Watch(ctx context.Context, onChange func()) error {
+	errs := make(chan error, len(m))
+	for _, watcher := range m {
+		go func() {
+			errs <- watcher.Watch(ctx, onChange)
+		}()
+	}
+
+	var ret error
+	for range m {
+		ret = multierr.Append(ret, <-errs)
+	}
+	return ret
 }
 
-func run(db internal.DnsDb, svc internal.Service, managedRecords map[string][]*internal.ManagedDnsRecord, conf *conf.Config) {
-	recordManager, err := internal.NewRecordManager(db, svc, managedRecords)
+func run(db internal.DnsDb, svc internal.Service, watcher driftWatcher, managedRecords map[string][]*internal.ManagedDnsRecord, conf *conf.Config, targets *targetResolver, groups healthGroups, providers providerSet) {
+	opts := []internal.RecordManagerOpts{
+		internal.WithCheckInterval(conf.CheckInterval),
+		internal.WithCheckJitter(conf.CheckJitter),
+		internal.WithCheckStagger(conf.CheckStagger),
+		internal.WithMaxConcurrentChecks(conf.MaxConcurrentChecks),
+		internal.WithRestartCoalescing(conf.Service.RestartCoalesce),
+		internal.WithRestartMinInterval(conf.Service.RestartMinInterval),
+		internal.WithRestartPolicy(conf.Service.RestartPolicy, conf.Service.ReloadFailuresBeforeRestart),
+		internal.WithHostnamePolicies(getHostnamePolicies(conf.Hostnames)),
+	}
+	if conf.Service.Timeout > 0 {
+		opts = append(opts, internal.WithBackendTimeout(conf.Service.Timeout))
+	}
+	if conf.OnShutdown != "" {
+		opts = append(opts, internal.WithShutdownPolicy(conf.OnShutdown))
+	}
+	if conf.FailureInjection {
+		opts = append(opts, internal.WithFailureInjection())
+	}
+	if conf.StateTxt {
+		opts = append(opts, internal.WithStateTxt())
+	}
+	if conf.Service.Retry != nil {
+		opts = append(opts, internal.WithRetries(*conf.Service.Retry))
+	}
+
+	execHooks, err := hooks.NewExec(conf.Hooks)
+	if err != nil {
+		log.Fatalf("could not build hooks: %v", err)
+	}
+	chain := hooks.Chain{execHooks}
+	for _, vipConf := range conf.Hooks.Vip {
+		vip, err := hooks.NewVip(vipConf)
+		if err != nil {
+			log.Fatalf("could not build vip hook for %q: %v", vipConf.Hostname, err)
+		}
+		chain = append(chain, vip)
+	}
+	opts = append(opts, internal.WithHooks(chain))
+
+	if conf.Guard != nil {
+		guard, err := healthcheck.NewGatewayChecker(*conf.Guard)
+		if err != nil {
+			log.Fatalf("could not build guard: %v", err)
+		}
+		opts = append(opts, internal.WithGuard(guard, conf.Guard.Timeout))
+	}
+
+	recordManager, err := internal.NewRecordManager(db, svc, managedRecords, opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if err := metrics.SetExposition(conf.MetricsNamespace, conf.MetricsLabels); err != nil {
+		log.Fatalf("could not configure metrics: %v", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
+	// exporters that outlive the process stop after the record manager, so their last export omits the series it
+	// removed during shutdown
+	exportCtx, stopExport := context.WithCancel(context.Background())
 
 	wg := &sync.WaitGroup{}
 	metricsErrChan := make(chan error, 1)
 	go func() {
 		if conf.MetricsAddr != "" {
 			wg.Add(1)
-			metricsServer, err := metrics.New(conf.MetricsAddr)
+			apiHandler := api.NewHandler(recordManager)
+			opts := append(getMetricsServerOpts(conf), metrics.WithHandler(api.Prefix, apiHandler), metrics.WithHandler(api.UiPrefix, apiHandler),
+				metrics.WithHandler(api.CheckPrefix, apiHandler))
+			metricsServer, err := metrics.New(conf.MetricsAddr, opts...)
 			if err != nil {
 				metricsErrChan <- err
 			} else {
@@ -107,24 +583,87 @@ func run(db internal.DnsDb, svc internal.Service, managedRecords map[string][]*i
 			}
 		} else if conf.MetricsFile != "" {
 			wg.Add(1)
-			metrics.StartMetricsWriter(ctx, wg, conf.MetricsFile)
+			metrics.StartMetricsWriter(exportCtx, wg, conf.MetricsFile, conf.MetricsFileInterval)
 		}
 	}()
 
+	if conf.MetricsPush != nil {
+		pusher, err := metrics.NewPusher(conf.MetricsPush.Job, conf.MetricsPush.Interval, getMetricsPusherOpts(conf.MetricsPush)...)
+		if err != nil {
+			log.Fatalf("could not build metrics pusher: %v", err)
+		}
+		wg.Add(1)
+		go pusher.Start(exportCtx, wg)
+	}
+
+	for _, sinkConf := range conf.MetricsSinks {
+		sink, err := buildMetricsSink(sinkConf)
+		if err != nil {
+			log.Fatalf("could not build %s metrics sink: %v", sinkConf.Type, err)
+		}
+		wg.Add(1)
+		go metrics.StartSink(exportCtx, wg, sinkConf.Type, sink, sinkConf.Interval)
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		ticker := time.NewTicker(30 * time.Second)
-		recordManager.CheckRecords(ctx)
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				recordManager.CheckRecords(ctx)
-			}
-		}
+		defer stopExport()
+		recordManager.Run(ctx)
+	}()
+
+	reloader := newConfigReloader(flagConfigFile, conf, managedRecords, recordManager, targets, groups, providers)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reloader.RunVaultKeepAlive(ctx)
 	}()
+	if flagConfigPoll > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reloader.Run(ctx, flagConfigPoll)
+		}()
+	}
+	if conf.ResolveInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reloader.RunResolve(ctx, conf.ResolveInterval)
+		}()
+	}
+
+	if conf.Kubernetes != nil {
+		k8sWatcher, err := kubernetes.NewWatcher(*conf.Kubernetes)
+		if err != nil {
+			log.Fatalf("could not build kubernetes watcher: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			k8sWatcher.Watch(ctx, reloader.OnResourcesChange)
+		}()
+	}
+
+	if watcher != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := watcher.Watch(ctx, recordManager.RequestReconcile); err != nil {
+				slog.Error("could not watch DNS backend for external changes", "err", err)
+			}
+		}()
+	}
+
+	sdNotify(notify.Ready)
+	if interval, enabled := notify.WatchdogInterval(); enabled {
+		slog.Info("Enabling systemd watchdog", "interval", interval)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			notify.RunWatchdog(ctx, interval, recordManager.IsAlive)
+		}()
+	}
 
 	sigc := make(chan os.Signal, 1)
 	signal.Notify(sigc,
@@ -143,6 +682,7 @@ func run(db internal.DnsDb, svc internal.Service, managedRecords map[string][]*i
 		exitCode = 1
 	}
 
+	sdNotify(notify.Stopping)
 	cancel()
 	gracefulExitDone := make(chan struct{})
 
@@ -161,28 +701,24 @@ func run(db internal.DnsDb, svc internal.Service, managedRecords map[string][]*i
 	os.Exit(exitCode)
 }
 
-func getManagedDnsRecords(c map[string][]conf.RecordConfig) (map[string][]*internal.ManagedDnsRecord, error) {
+// sdNotify notifies systemd about the state if dns-ha is running as a Type=notify unit.
+func sdNotify(state string) {
+	if err := notify.Send(state); err != nil && !errors.Is(err, notify.ErrNotSupported) {
+		slog.Warn("Could not notify systemd", "state", state, "err", err)
+	}
+}
+
+func getManagedDnsRecords(c map[string][]conf.RecordConfig, groups healthGroups) (map[string][]*internal.ManagedDnsRecord, error) {
 	ret := make(map[string][]*internal.ManagedDnsRecord)
 	var errs error
 
 	for hostname, records := range c {
 		var add []*internal.ManagedDnsRecord
 		for _, recordConf := range records {
-			record, err := internal.NewDnsRecord(recordConf)
-			if err != nil {
-				errs = multierr.Append(errs, fmt.Errorf("could not build record from config: %w", err))
-			}
-
-			healthchecker, err := buildHealthcheck(hostname, record, recordConf.HealthcheckConfig)
+			r, err := buildManagedDnsRecord(hostname, recordConf, groups)
 			if err != nil {
-				errs = multierr.Append(errs, fmt.Errorf("could not build healthcheck: %w", err))
+				errs = multierr.Append(errs, err)
 			}
-
-			r, err := internal.NewManagedDnsRecord(hostname, record, recordConf.StatusConfig, healthchecker)
-			if err != nil {
-				errs = multierr.Append(errs, fmt.Errorf("could not build managed record: %w", err))
-			}
-
 			add = append(add, r)
 		}
 		ret[hostname] = add
@@ -191,6 +727,175 @@ func getManagedDnsRecords(c map[string][]conf.RecordConfig) (map[string][]*inter
 	return ret, errs
 }
 
+func buildManagedDnsRecord(hostname string, recordConf conf.RecordConfig, groups healthGroups) (*internal.ManagedDnsRecord, error) {
+	var errs error
+	record, err := internal.NewDnsRecord(recordConf)
+	if err != nil {
+		errs = multierr.Append(errs, fmt.Errorf("could not build record from config: %w", err))
+	}
+
+	opts := []internal.ManagedDnsRecordOpts{
+		internal.WithHistorySize(recordConf.HistorySize),
+	}
+
+	host, _ := internal.SplitView(hostname)
+	checkerConf := recordConf.HealthcheckConfig
+	var healthchecker internal.Healthcheck
+	if recordConf.HealthGroup != "" {
+		group, found := groups[recordConf.HealthGroup]
+		if !found {
+			errs = multierr.Append(errs, fmt.Errorf("unknown health group %q", recordConf.HealthGroup))
+		} else {
+			healthchecker = group.check
+			checkerConf = group.conf.HealthcheckConfig
+		}
+	} else {
+		healthchecker, err = buildHealthcheck(host, record, checkerConf)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("could not build healthcheck: %w", err))
+		}
+		if key, err := internal.CheckKey(host, record, checkerConf); err == nil {
+			opts = append(opts, internal.WithCheckKey(key))
+		}
+	}
+
+	if checkerConf.Timeout > 0 {
+		opts = append(opts, internal.WithCheckTimeout(checkerConf.Timeout))
+	}
+	if recordConf.Backoff != nil {
+		opts = append(opts, internal.WithBackoff(*recordConf.Backoff))
+	}
+	if recordConf.ValidUntil != nil {
+		opts = append(opts, internal.WithExpiry(*recordConf.ValidUntil))
+	} else if recordConf.ValidFor > 0 {
+		opts = append(opts, internal.WithExpiry(time.Now().Add(recordConf.ValidFor)))
+	}
+
+	r, err := internal.NewManagedDnsRecord(hostname, record, recordConf.StatusConfig, healthchecker, opts...)
+	if err != nil {
+		errs = multierr.Append(errs, fmt.Errorf("could not build managed record: %w", err))
+	}
+
+	return r, errs
+}
+
+func getMetricsServerOpts(conf *conf.Config) []metrics.MetricsServerOpts {
+	var opts []metrics.MetricsServerOpts
+	if conf.MetricsTls != nil {
+		opts = append(opts, metrics.WithTls(conf.MetricsTls.CertFile, conf.MetricsTls.KeyFile))
+		if conf.MetricsTls.ClientCaFile != "" {
+			opts = append(opts, metrics.WithClientCa(conf.MetricsTls.ClientCaFile))
+		}
+		if len(conf.MetricsTls.AllowedClientCns) > 0 {
+			opts = append(opts, metrics.WithAllowedClientCns(conf.MetricsTls.AllowedClientCns))
+		}
+	}
+	if conf.MetricsBasicAuth != nil {
+		opts = append(opts, metrics.WithBasicAuth(conf.MetricsBasicAuth.Username, conf.MetricsBasicAuth.Password))
+	}
+	if len(conf.MetricsBearerTokens) > 0 {
+		opts = append(opts, metrics.WithBearerTokens(conf.MetricsBearerTokens))
+	}
+	if conf.MetricsRateLimit > 0 {
+		opts = append(opts, metrics.WithRateLimit(conf.MetricsRateLimit))
+	}
+	return opts
+}
+
+func getMetricsPusherOpts(conf *conf.MetricsPushConfig) []metrics.MetricsPusherOpts {
+	var opts []metrics.MetricsPusherOpts
+	if conf.PushgatewayUrl != "" {
+		opts = append(opts, metrics.WithPushgateway(conf.PushgatewayUrl))
+	}
+	if conf.RemoteWriteUrl != "" {
+		opts = append(opts, metrics.WithRemoteWrite(conf.RemoteWriteUrl))
+	}
+	if conf.BasicAuth != nil {
+		opts = append(opts, metrics.WithPushBasicAuth(conf.BasicAuth.Username, conf.BasicAuth.Password))
+	}
+	return opts
+}
+
+func buildMetricsSink(conf conf.MetricsSinkConfig) (metrics.Sink, error) {
+	switch conf.Type {
+	case metrics.GraphiteSinkName:
+		return metrics.NewGraphiteSink(conf.Address, conf.Prefix)
+	case metrics.InfluxDbSinkName:
+		return metrics.NewInfluxDbSink(conf.Url, conf.Token)
+	case metrics.StatsdSinkName:
+		return metrics.NewStatsdSink(conf.Address, conf.Prefix)
+	default:
+		return nil, fmt.Errorf("no metrics sink %q available", conf.Type)
+	}
+}
+
+func getHostnamePolicies(c map[string]conf.HostnameConfig) map[string]internal.HostnamePolicy {
+	ret := make(map[string]internal.HostnamePolicy, len(c))
+	for hostname, hostnameConf := range c {
+		ret[hostname] = internal.HostnamePolicy{
+			OnAllUnhealthy:      hostnameConf.OnAllUnhealthy,
+			FallbackIp:          net.ParseIP(hostnameConf.FallbackIp),
+			KeepAddressFamilies: hostnameConf.KeepAddressFamilies,
+			DependsOn:           hostnameConf.DependsOn,
+			CheckInterval:       hostnameConf.CheckInterval,
+			Strategy:            internal.BuiltinStrategy(hostnameConf.Strategy),
+			MinRecords:          hostnameConf.MinRecords,
+			MaxRecords:          hostnameConf.MaxRecords,
+			GracePeriod:         hostnameConf.GracePeriod,
+			EscalateAfter:       hostnameConf.EscalateAfter,
+			Blackouts:           buildBlackouts(hostnameConf.Blackouts),
+			DecisionEngine:      buildDecisionEngine(hostnameConf.Decision),
+			DecisionFallback:    hostnameConf.Decision != nil && hostnameConf.Decision.OnError == "strategy",
+			ShutdownIps:         parseIps(hostnameConf.ShutdownIps),
+		}
+	}
+	return ret
+}
+
+// parseIps parses the already validated ips.
+func parseIps(ips []string) []net.IP {
+	var ret []net.IP
+	for _, ip := range ips {
+		ret = append(ret, net.ParseIP(ip))
+	}
+	return ret
+}
+
+// buildDecisionEngine builds the decision engine of a hostname, the config has already been validated.
+func buildDecisionEngine(c *conf.DecisionConfig) internal.DecisionEngine {
+	if c == nil {
+		return nil
+	}
+
+	opts := []decision.WebhookOpts{decision.WithHeaders(c.Headers)}
+	if c.Timeout > 0 {
+		opts = append(opts, decision.WithTimeout(c.Timeout))
+	}
+	webhook, err := decision.NewWebhook(c.Url, opts...)
+	if err != nil {
+		log.Fatalf("could not build decision engine for %q: %v", c.Url, err)
+	}
+	return webhook
+}
+
+// buildBlackouts builds the blackout windows of a hostname, the config has already been validated.
+func buildBlackouts(c []conf.BlackoutConfig) []*schedule.Window {
+	var ret []*schedule.Window
+	for _, blackout := range c {
+		location := time.Local
+		if blackout.Timezone != "" {
+			location, _ = time.LoadLocation(blackout.Timezone)
+		}
+		window, err := schedule.NewWindow(blackout.Schedule, blackout.Duration, location)
+		if err != nil {
+			slog.Error("Ignoring invalid blackout window", "schedule", blackout.Schedule, "err", err)
+			continue
+		}
+		ret = append(ret, window)
+	}
+	return ret
+}
+
 func setupLogging() {
 	var level slog.Leveler = slog.LevelInfo
 	if flagDebug {
@@ -205,20 +910,57 @@ func setupLogging() {
 	slog.SetDefault(logger)
 }
 
-func buildHealthcheck(host string, record internal.DnsRecord, args map[string]any) (internal.Healthcheck, error) {
-	healthchecker, found := args["type"]
-	if !found {
-		return nil, errors.New("no type specified")
+func buildHealthcheck(host string, record internal.DnsRecord, args conf.HealthcheckConfig) (internal.Healthcheck, error) {
+	var opts []healthcheck.CheckerOpts
+	if args.SourceIp != "" {
+		opts = append(opts, healthcheck.WithSourceIp(net.ParseIP(args.SourceIp)))
+	}
+	if args.SourceInterface != "" {
+		opts = append(opts, healthcheck.WithSourceInterface(args.SourceInterface))
 	}
 
-	switch healthchecker {
-	case healthcheck.HttpCheckerName:
-		return healthcheck.NewHttp(host, record, args)
-	case healthcheck.IcmpCheckerName:
-		return healthcheck.NewIcmpChecker(record, args)
-	case healthcheck.TcpCheckerName:
-		return healthcheck.NewTcpChecker(record, args)
+	var checker internal.Healthcheck
+	var err error
+	switch {
+	case args.Type == healthcheck.HttpCheckerName && args.Http != nil:
+		checker, err = healthcheck.NewHttp(host, record, *args.Http, opts...)
+	case args.Type == healthcheck.IcmpCheckerName && args.Icmp != nil:
+		checker, err = healthcheck.NewIcmpChecker(record, *args.Icmp, opts...)
+	case args.Type == healthcheck.TcpCheckerName && args.Tcp != nil:
+		checker, err = healthcheck.NewTcpChecker(record, *args.Tcp, opts...)
+	case args.Type == healthcheck.FileCheckerName && args.File != nil:
+		checker, err = healthcheck.NewFileChecker(*args.File)
+	case args.Type == healthcheck.SnmpCheckerName && args.Snmp != nil:
+		checker, err = healthcheck.NewSnmpChecker(record, *args.Snmp, opts...)
+	case args.Type == healthcheck.MqttCheckerName && args.Mqtt != nil:
+		checker, err = healthcheck.NewMqttChecker(host, record, *args.Mqtt, opts...)
+	case args.Type == healthcheck.ModbusCheckerName && args.Modbus != nil:
+		checker, err = healthcheck.NewModbusChecker(record, *args.Modbus, opts...)
+	case args.Type == healthcheck.LdapCheckerName && args.Ldap != nil:
+		checker, err = healthcheck.NewLdapChecker(host, record, *args.Ldap, opts...)
+	case args.Type == healthcheck.KerberosCheckerName && args.Kerberos != nil:
+		checker, err = healthcheck.NewKerberosChecker(record, *args.Kerberos, opts...)
+	case args.Type == healthcheck.SipCheckerName && args.Sip != nil:
+		checker, err = healthcheck.NewSipChecker(host, record, *args.Sip, opts...)
+	case args.Type == healthcheck.RtspCheckerName && args.Rtsp != nil:
+		checker, err = healthcheck.NewRtspChecker(record, *args.Rtsp, opts...)
+	case args.Type == healthcheck.MonitoringCheckerName && args.Monitoring != nil:
+		checker, err = healthcheck.NewMonitoringChecker(*args.Monitoring)
+	case args.Type == "":
+		return nil, errors.New("no type specified")
 	default:
-		return nil, fmt.Errorf("no checker %q available", healthchecker)
+		return nil, fmt.Errorf("no checker %q available", args.Type)
+	}
+	if err == nil && args.Samples != nil {
+		checker, err = healthcheck.NewSampledChecker(checker, *args.Samples, args.Timeout)
+	}
+	if err != nil || args.Passive == nil {
+		return checker, err
+	}
+
+	passive, err := buildHealthcheck(host, record, *args.Passive)
+	if err != nil {
+		return nil, fmt.Errorf("could not build passive healthcheck: %w", err)
 	}
+	return healthcheck.NewMixedChecker(checker, passive, args.Precedence, args.Passive.Timeout)
 }
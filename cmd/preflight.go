@@ -0,0 +1,215 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/dns/bind"
+	"github.com/soerenschneider/dns-ha/internal/dns/files"
+	"github.com/soerenschneider/dns-ha/internal/dns/provider"
+	"github.com/soerenschneider/dns-ha/internal/dns/unbound"
+	"github.com/soerenschneider/dns-ha/internal/healthcheck"
+	"github.com/soerenschneider/dns-ha/internal/privileges"
+	"github.com/soerenschneider/dns-ha/internal/service"
+)
+
+// preflightResult is the outcome of a single preflight check.
+type preflightResult struct {
+	name   string
+	target string
+	err    error
+}
+
+// runPreflight implements "dns-ha preflight", which verifies that dns-ha can start with the given config and prints
+// the results of all checks, and returns the exit code.
+func runPreflight(args []string) int {
+	flags := flag.NewFlagSet("preflight", flag.ContinueOnError)
+	configFile := flags.String("config", defaultConfigFile, "Config file, directory containing config fragments or remote location")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	config, err := conf.Read(*configFile)
+	if err == nil {
+		err = config.Validate()
+	}
+	results := []preflightResult{{name: "config", target: *configFile, err: err}}
+	if err == nil {
+		results = append(results, preflight(config)...)
+	}
+
+	printPreflight(os.Stdout, results)
+	if slices.ContainsFunc(results, func(r preflightResult) bool { return r.err != nil }) {
+		return 1
+	}
+	return 0
+}
+
+// preflight runs all checks in the order dns-ha starts up, privileges are dropped first so the checks reflect the
+// permissions dns-ha runs with.
+func preflight(c *conf.Config) []preflightResult {
+	var results []preflightResult
+	if c.Privileges != nil {
+		err := privileges.Drop(c.Privileges.User, c.Privileges.Group)
+		results = append(results, preflightResult{name: "drop privileges", target: c.Privileges.User, err: err})
+	}
+
+	switch {
+	case c.Bind != nil:
+		results = append(results,
+			preflightResult{name: "zone file writable", target: c.Bind.ZoneFile, err: files.CheckWritable(c.Bind.ZoneFile, false)},
+			lookPath("checkzone binary", cmp.Or(c.Bind.CheckzoneBinary, bind.DefaultCheckzoneBinary)),
+			lookPath("rndc binary", cmp.Or(c.Bind.RndcBinary, service.DefaultRndcBinary)),
+		)
+	case c.MsDns != nil:
+		_, err := service.NewWindowsService(c.MsDns.ServiceName)
+		results = append(results,
+			lookPath("powershell binary", cmp.Or(c.MsDns.PowershellBinary, provider.DefaultPowershellBinary)),
+			preflightResult{name: "windows service", target: c.MsDns.ServiceName, err: err},
+		)
+	case c.Resolved != nil:
+		results = append(results,
+			preflightResult{name: "hosts file writable", target: c.Resolved.HostsFile, err: files.CheckWritable(c.Resolved.HostsFile, false)},
+			lookPath("resolvectl binary", cmp.Or(c.Resolved.ResolvectlBinary, service.DefaultResolvectlBinary)),
+		)
+	case c.Nsd != nil:
+		results = append(results,
+			preflightResult{name: "zone file writable", target: c.Nsd.ZoneFile, err: files.CheckWritable(c.Nsd.ZoneFile, false)},
+			lookPath("checkzone binary", cmp.Or(c.Nsd.CheckzoneBinary, bind.DefaultNsdCheckzoneBinary)),
+			lookPath("nsd-control binary", cmp.Or(c.Nsd.ControlBinary, service.DefaultNsdControlBinary)),
+		)
+	case c.Knot != nil:
+		results = append(results,
+			preflightResult{name: "zone file writable", target: c.Knot.ZoneFile, err: files.CheckWritable(c.Knot.ZoneFile, false)},
+			lookPath("checkzone binary", cmp.Or(c.Knot.CheckzoneBinary, bind.DefaultKzonecheckBinary)),
+			lookPath("knotc binary", cmp.Or(c.Knot.ControlBinary, service.DefaultKnotcBinary)),
+		)
+	case c.CoreDns != nil:
+		results = append(results, preflightResult{name: "hosts file writable", target: c.CoreDns.HostsFile, err: files.CheckWritable(c.CoreDns.HostsFile, false)})
+	default:
+		results = append(results, preflightUnbound("unbound", c.Unbound)...)
+		for _, name := range slices.Sorted(maps.Keys(c.UnboundInstances)) {
+			results = append(results, preflightUnbound("unbound/"+name, c.UnboundInstances[name])...)
+		}
+	}
+
+	var targetResults []preflightResult
+	targetResults, c = preflightTargets(c)
+	results = append(results, targetResults...)
+
+	groups, err := buildHealthGroups(c)
+	if len(c.HealthGroups) > 0 {
+		results = append(results, preflightResult{name: "health groups", target: fmt.Sprintf("%d groups", len(c.HealthGroups)), err: err})
+	}
+
+	_, err = getManagedDnsRecords(c.Records, groups)
+	results = append(results, preflightResult{name: "healthchecks", target: fmt.Sprintf("%d hostnames", len(c.Records)), err: err})
+
+	// all icmp checkers of the same address family share the same socket type, so failures are reported once
+	var icmpErrs []string
+	icmpChecks := 0
+	for hostname, records := range c.Records {
+		host, _ := internal.SplitView(hostname)
+		for _, recordConf := range records {
+			record, err := internal.NewDnsRecord(recordConf)
+			if err != nil {
+				continue
+			}
+			checker, err := buildHealthcheck(host, record, recordConf.HealthcheckConfig)
+			if err != nil {
+				continue
+			}
+			icmpChecker, ok := checker.(*healthcheck.IcmpChecker)
+			if !ok {
+				continue
+			}
+			icmpChecks++
+			if err := icmpChecker.Preflight(); err != nil && !slices.Contains(icmpErrs, err.Error()) {
+				icmpErrs = append(icmpErrs, err.Error())
+			}
+		}
+	}
+	if icmpChecks > 0 {
+		slices.Sort(icmpErrs)
+		var err error
+		if len(icmpErrs) > 0 {
+			err = errors.New(strings.Join(icmpErrs, "; "))
+		}
+		results = append(results, preflightResult{name: "icmp sockets", target: fmt.Sprintf("%d checks", icmpChecks), err: err})
+	}
+
+	if c.MetricsAddr != "" {
+		listener, err := net.Listen("tcp", c.MetricsAddr)
+		if err == nil {
+			_ = listener.Close()
+		}
+		results = append(results, preflightResult{name: "metrics address", target: c.MetricsAddr, err: err})
+	}
+
+	return results
+}
+
+func preflightUnbound(name string, c conf.UnboundConfig) []preflightResult {
+	_, err := service.NewSystemdService(c.ServiceName)
+	results := []preflightResult{
+		{name: name + " db file writable", target: c.DbFile, err: files.CheckWritable(c.DbFile, c.CreateFile)},
+		lookPath(name+" checkconf binary", cmp.Or(c.Checkconf.Binary, unbound.DefaultCheckconfBinary)),
+		{name: name + " systemd unit", target: c.ServiceName, err: err},
+	}
+	if c.File.Restorecon {
+		results = append(results, lookPath(name+" restorecon binary", files.DefaultRestoreconBinary))
+	}
+	return results
+}
+
+func lookPath(name, binary string) preflightResult {
+	_, err := exec.LookPath(binary)
+	return preflightResult{name: name, target: binary, err: err}
+}
+
+func printPreflight(out io.Writer, results []preflightResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	for _, result := range results {
+		if result.err != nil {
+			fmt.Fprintf(w, "FAIL\t%s\t%s\t%v\n", result.name, result.target, result.err)
+		} else {
+			fmt.Fprintf(w, "OK\t%s\t%s\t\n", result.name, result.target)
+		}
+	}
+}
+
+// preflightTargets resolves the targets of all records and returns the config carrying the resolved ips.
+func preflightTargets(c *conf.Config) ([]preflightResult, *conf.Config) {
+	targets := newTargetResolver()
+	resolved := targets.resolve(context.Background(), c)
+
+	var results []preflightResult
+	for _, hostname := range slices.Sorted(maps.Keys(c.Records)) {
+		for _, record := range c.Records[hostname] {
+			if record.Target == "" {
+				continue
+			}
+			var err error
+			if !targets.isResolved(record) {
+				err = fmt.Errorf("could not resolve via %s", record.Resolver)
+			}
+			results = append(results, preflightResult{name: "target resolvable", target: record.Target, err: err})
+		}
+	}
+	return results, resolved
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"sync"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/dns/provider"
+)
+
+// refreshableProvider forwards to the provider built from the latest config of the provider, so credentials can be
+// replaced without a restart, e.g. short-lived credentials read from Vault.
+type refreshableProvider struct {
+	mutex    sync.RWMutex
+	conf     conf.DnsProviderConfig
+	provider provider.Provider
+}
+
+func newRefreshableProvider(providerConf conf.DnsProviderConfig) (*refreshableProvider, error) {
+	p, err := buildProvider(providerConf)
+	if err != nil {
+		return nil, err
+	}
+	return &refreshableProvider{conf: providerConf, provider: p}, nil
+}
+
+func (p *refreshableProvider) GetRecords(ctx context.Context, zone, name string) ([]provider.Record, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.provider.GetRecords(ctx, zone, name)
+}
+
+func (p *refreshableProvider) SetRecords(ctx context.Context, zone, name, rtype string, records []provider.Record) error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.provider.SetRecords(ctx, zone, name, rtype, records)
+}
+
+// refresh replaces the provider if its config changed and returns whether it has been replaced.
+func (p *refreshableProvider) refresh(providerConf conf.DnsProviderConfig) (bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if reflect.DeepEqual(p.conf, providerConf) {
+		return false, nil
+	}
+
+	updated, err := buildProvider(providerConf)
+	if err != nil {
+		return false, err
+	}
+	p.conf = providerConf
+	p.provider = updated
+	return true, nil
+}
+
+// providerSet holds the providers hostnames are managed at, by name.
+type providerSet map[string]*refreshableProvider
+
+// refresh applies the changed configs of the providers, providers that are not configured anymore are kept until the
+// hostnames managed at them are removed by a restart.
+func (s providerSet) refresh(c *conf.Config) {
+	for name, p := range s {
+		providerConf, found := c.DnsProviders[name]
+		if !found {
+			continue
+		}
+		replaced, err := p.refresh(providerConf)
+		if err != nil {
+			slog.Error("could not apply changed config of dns provider, keeping current provider", "provider", name, "err", err)
+		} else if replaced {
+			slog.Info("Applied changed config of dns provider", "provider", name)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/kubernetes"
+	"github.com/soerenschneider/dns-ha/internal/vault"
+)
+
+// configReloader hot-applies changes of the records and hostname settings, either read periodically from the config
+// location or from Kubernetes resources.
+type configReloader struct {
+	mutex    sync.Mutex
+	location string
+	// base is the config as read from the location
+	base      *conf.Config
+	resources []kubernetes.ManagedDnsRecord
+	// current is the effective config including the records of all resources
+	current *conf.Config
+	records map[string][]*internal.ManagedDnsRecord
+	manager *internal.RecordManager
+	targets *targetResolver
+	groups  healthGroups
+	// providers are refreshed with each read of the config, e.g. to apply renewed credentials
+	providers providerSet
+	// vaultClient is the client of the latest applied config, replacements are sent to the keep alive loop
+	vaultClient  *vault.Client
+	vaultClients chan *vault.Client
+}
+
+func newConfigReloader(location string, config *conf.Config, records map[string][]*internal.ManagedDnsRecord, manager *internal.RecordManager, targets *targetResolver, groups healthGroups, providers providerSet) *configReloader {
+	return &configReloader{
+		location:  location,
+		base:      config,
+		current:   targets.apply(config),
+		records:   records,
+		manager:   manager,
+		targets:   targets,
+		groups:    groups,
+		providers: providers,
+
+		vaultClient:  config.VaultClient(),
+		vaultClients: make(chan *vault.Client, 1),
+	}
+}
+
+func (r *configReloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reload()
+		}
+	}
+}
+
+// RunVaultKeepAlive keeps the vault client of the config alive, the loop is restarted on the client of a reloaded
+// config if reading it built a new client, e.g. because the vault section changed.
+func (r *configReloader) RunVaultKeepAlive(ctx context.Context) {
+	r.mutex.Lock()
+	client := r.vaultClient
+	r.mutex.Unlock()
+
+	for {
+		keepAliveCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if client != nil {
+				// reading the config again resolves the secrets using the same client
+				client.KeepAlive(keepAliveCtx, r.reload)
+			}
+		}()
+
+		select {
+		case <-ctx.Done():
+			cancel()
+			<-done
+			return
+		case client = <-r.vaultClients:
+			slog.Info("Vault client has been replaced, keeping the new client alive")
+			cancel()
+			<-done
+		}
+	}
+}
+
+// RunResolve periodically re-resolves the targets of the records and applies changed ips.
+func (r *configReloader) RunResolve(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.mutex.Lock()
+			r.apply()
+			r.mutex.Unlock()
+		}
+	}
+}
+
+func (r *configReloader) reload() {
+	updated, err := conf.Read(r.location)
+	if err != nil {
+		slog.Error("could not read config, keeping current config", "err", err)
+		return
+	}
+
+	if err := updated.Validate(); err != nil {
+		slog.Error("invalid config, keeping current config", "err", err)
+		return
+	}
+
+	r.providers.refresh(updated)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.base = updated
+	r.apply()
+
+	if client := updated.VaultClient(); client != r.vaultClient {
+		r.vaultClient = client
+		// only the latest client is of interest if the previous replacement has not been picked up yet
+		select {
+		case <-r.vaultClients:
+		default:
+		}
+		r.vaultClients <- client
+	}
+}
+
+// OnResourcesChange applies the records of the given Kubernetes resources in addition to the records of the config.
+func (r *configReloader) OnResourcesChange(resources []kubernetes.ManagedDnsRecord) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.resources = resources
+	r.apply()
+}
+
+func (r *configReloader) apply() {
+	// lookups are bounded by resolveTimeout, so they do not need to be canceled on shutdown
+	updated := r.targets.resolve(context.Background(), r.withResources(r.base))
+	if reflect.DeepEqual(updated.Records, r.current.Records) && reflect.DeepEqual(updated.Hostnames, r.current.Hostnames) {
+		return
+	}
+
+	if changed := restartRequiredChanges(r.current, updated); len(changed) > 0 {
+		slog.Warn("Config contains changes that are only applied after a restart", "settings", changed)
+	}
+
+	records, err := r.buildRecords(updated.Records)
+	if err != nil {
+		slog.Error("could not build records, keeping current config", "err", err)
+		return
+	}
+
+	slog.Info("Applying changed config", "hostnames", len(records))
+	r.manager.ReplaceRecords(records, getHostnamePolicies(updated.Hostnames))
+	r.current = updated
+	r.records = records
+}
+
+// withResources returns a copy of the config that additionally contains the records of all valid resources.
+// Resources must not redefine hostnames that are already defined.
+func (r *configReloader) withResources(base *conf.Config) *conf.Config {
+	if len(r.resources) == 0 {
+		return base
+	}
+
+	ret := *base
+	ret.Records = maps.Clone(base.Records)
+	ret.Hostnames = maps.Clone(base.Hostnames)
+	if ret.Records == nil {
+		ret.Records = map[string][]conf.RecordConfig{}
+	}
+	if ret.Hostnames == nil {
+		ret.Hostnames = map[string]conf.HostnameConfig{}
+	}
+
+	for _, resource := range r.resources {
+		name := fmt.Sprintf("%s/%s", resource.Namespace, resource.Name)
+		hostname := resource.Spec.Hostname
+		if _, found := ret.Records[hostname]; found {
+			slog.Warn("Skipping resource for hostname that is already defined", "resource", name, "hostname", hostname)
+			continue
+		}
+
+		// validate each resource on its own, so a single invalid resource does not block all others
+		candidate := *base
+		candidate.Records = map[string][]conf.RecordConfig{hostname: resource.Spec.Records}
+		candidate.Hostnames = map[string]conf.HostnameConfig{hostname: resource.Spec.HostnameConfig}
+		if err := candidate.Validate(); err != nil {
+			slog.Error("Skipping invalid resource", "resource", name, "err", err)
+			continue
+		}
+
+		ret.Records[hostname] = resource.Spec.Records
+		ret.Hostnames[hostname] = resource.Spec.HostnameConfig
+	}
+
+	return &ret
+}
+
+// buildRecords builds the managed records of the updated config. Records whose config did not change are reused, so
+// they keep their health state.
+func (r *configReloader) buildRecords(c map[string][]conf.RecordConfig) (map[string][]*internal.ManagedDnsRecord, error) {
+	ret := make(map[string][]*internal.ManagedDnsRecord, len(c))
+	for hostname, recordConfs := range c {
+		for _, recordConf := range recordConfs {
+			if existing := r.findUnchanged(hostname, recordConf); existing != nil {
+				ret[hostname] = append(ret[hostname], existing)
+				continue
+			}
+
+			record, err := buildManagedDnsRecord(hostname, recordConf, r.groups)
+			if err != nil {
+				return nil, err
+			}
+			ret[hostname] = append(ret[hostname], record)
+		}
+	}
+	return ret, nil
+}
+
+func (r *configReloader) findUnchanged(hostname string, recordConf conf.RecordConfig) *internal.ManagedDnsRecord {
+	for index, current := range r.current.Records[hostname] {
+		if reflect.DeepEqual(current, recordConf) && index < len(r.records[hostname]) {
+			return r.records[hostname][index]
+		}
+	}
+	return nil
+}
+
+// hostnameProviders returns the backend of all hostnames that are not managed at the default backend.
+func hostnameProviders(c *conf.Config) map[string]string {
+	ret := map[string]string{}
+	for hostname, hostnameConf := range c.Hostnames {
+		if hostnameConf.Provider != "" {
+			ret[hostname] = hostnameConf.Provider + "/" + hostnameConf.Zone
+		}
+		for view, subnets := range hostnameConf.ClientSubnets {
+			ret[hostname+internal.ViewSeparator+view] = fmt.Sprintf("%s/%s %v", hostnameConf.Provider, hostnameConf.Zone, subnets)
+		}
+		if hostnameConf.Unbound != "" {
+			ret[hostname] = "unbound/" + hostnameConf.Unbound
+		}
+	}
+	return ret
+}
+
+func restartRequiredChanges(current, updated *conf.Config) []string {
+	fields := map[string][2]any{
+		"unbound":               {current.Unbound, updated.Unbound},
+		"bind":                  {current.Bind, updated.Bind},
+		"msdns":                 {current.MsDns, updated.MsDns},
+		"resolved":              {current.Resolved, updated.Resolved},
+		"coredns":               {current.CoreDns, updated.CoreDns},
+		"nsd":                   {current.Nsd, updated.Nsd},
+		"knot":                  {current.Knot, updated.Knot},
+		"service":               {current.Service, updated.Service},
+		"hooks":                 {current.Hooks, updated.Hooks},
+		"kubernetes":            {current.Kubernetes, updated.Kubernetes},
+		"metrics_addr":          {current.MetricsAddr, updated.MetricsAddr},
+		"metrics_file":          {current.MetricsFile, updated.MetricsFile},
+		"metrics_file_interval": {current.MetricsFileInterval, updated.MetricsFileInterval},
+		"metrics_tls":           {current.MetricsTls, updated.MetricsTls},
+		"metrics_basic_auth":    {current.MetricsBasicAuth, updated.MetricsBasicAuth},
+		"metrics_bearer_tokens": {current.MetricsBearerTokens, updated.MetricsBearerTokens},
+		"metrics_rate_limit":    {current.MetricsRateLimit, updated.MetricsRateLimit},
+		"metrics_push":          {current.MetricsPush, updated.MetricsPush},
+		"metrics_sinks":         {current.MetricsSinks, updated.MetricsSinks},
+		"metrics_namespace":     {current.MetricsNamespace, updated.MetricsNamespace},
+		"metrics_labels":        {current.MetricsLabels, updated.MetricsLabels},
+		"guard":                 {current.Guard, updated.Guard},
+		"hostnames.provider":    {hostnameProviders(current), hostnameProviders(updated)},
+		"unbound_instances":     {current.UnboundInstances, updated.UnboundInstances},
+		"check_interval":        {current.CheckInterval, updated.CheckInterval},
+		"check_jitter":          {current.CheckJitter, updated.CheckJitter},
+		"check_stagger":         {current.CheckStagger, updated.CheckStagger},
+		"max_concurrent_checks": {current.MaxConcurrentChecks, updated.MaxConcurrentChecks},
+		"on_shutdown":           {current.OnShutdown, updated.OnShutdown},
+		"failure_injection":     {current.FailureInjection, updated.FailureInjection},
+		"state_txt":             {current.StateTxt, updated.StateTxt},
+		"resolve_interval":      {current.ResolveInterval, updated.ResolveInterval},
+		"health_groups":         {current.HealthGroups, updated.HealthGroups},
+	}
+
+	var changed []string
+	for name, values := range fields {
+		if !reflect.DeepEqual(values[0], values[1]) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/resolve"
+)
+
+const resolveTimeout = 5 * time.Second
+
+type targetKey struct {
+	target     string
+	recordType string
+	resolver   string
+}
+
+func newTargetKey(record conf.RecordConfig) targetKey {
+	return targetKey{target: record.Target, recordType: record.RecordType, resolver: record.Resolver}
+}
+
+// targetResolver resolves the ips of records that are configured by target hostname. The last resolved ip of a
+// target is kept if a lookup fails.
+type targetResolver struct {
+	mutex    sync.Mutex
+	resolved map[targetKey]string
+}
+
+func newTargetResolver() *targetResolver {
+	return &targetResolver{
+		resolved: map[targetKey]string{},
+	}
+}
+
+// resolve looks up all targets of the config and returns a copy of it with the resolved ips, see apply.
+func (t *targetResolver) resolve(ctx context.Context, c *conf.Config) *conf.Config {
+	for _, records := range c.Records {
+		for _, record := range records {
+			if record.Target == "" {
+				continue
+			}
+
+			key := newTargetKey(record)
+			lookupCtx, cancel := context.WithTimeout(ctx, resolveTimeout)
+			ip, err := resolve.New(record.Resolver).Lookup(lookupCtx, record.Target, record.RecordType)
+			cancel()
+			if err != nil {
+				slog.Error("Could not resolve target, keeping last ip", "target", record.Target, "resolver", record.Resolver, "err", err)
+				continue
+			}
+
+			t.mutex.Lock()
+			if previous, found := t.resolved[key]; found && previous != ip.String() {
+				slog.Info("Target resolves to a new ip", "target", record.Target, "ip", ip.String(), "previous", previous)
+			}
+			t.resolved[key] = ip.String()
+			t.mutex.Unlock()
+		}
+	}
+
+	return t.apply(c)
+}
+
+// isResolved returns whether the target of the record has been resolved at least once.
+func (t *targetResolver) isResolved(record conf.RecordConfig) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	_, found := t.resolved[newTargetKey(record)]
+	return found
+}
+
+// apply returns a copy of the config whose target records carry the last resolved ip. Target records that have never
+// been resolved are omitted.
+func (t *targetResolver) apply(c *conf.Config) *conf.Config {
+	if !hasTargets(c) {
+		return c
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	ret := *c
+	ret.Records = make(map[string][]conf.RecordConfig, len(c.Records))
+	for hostname, records := range c.Records {
+		resolved := make([]conf.RecordConfig, 0, len(records))
+		for _, record := range records {
+			if record.Target != "" {
+				ip, found := t.resolved[newTargetKey(record)]
+				if !found {
+					slog.Warn("Omitting record of unresolved target", "hostname", hostname, "target", record.Target)
+					continue
+				}
+				record.IP = ip
+			}
+			resolved = append(resolved, record)
+		}
+		ret.Records[hostname] = resolved
+	}
+	return &ret
+}
+
+func hasTargets(c *conf.Config) bool {
+	for records := range maps.Values(c.Records) {
+		for _, record := range records {
+			if record.Target != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+// Package api serves dns-ha's status API and web UI next to the metrics and contains the client used by "dns-ha ctl".
+package api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+const (
+	// Prefix is the path all API endpoints are served below.
+	Prefix = "/api/"
+	// UiPrefix is the path the web UI is served at.
+	UiPrefix = "/ui/"
+	// CheckPrefix is the path the health of hostnames is served below for load balancers, e.g. HAProxy's httpchk.
+	CheckPrefix = "/check/"
+
+	// RequestedByHeader needs to be set for all requests that change state. Browsers do not allow other sites to set
+	// custom headers, which protects the endpoints against cross-site requests.
+	RequestedByHeader = "X-Requested-By"
+
+	historyPath     = "/api/v1/history/"
+	statusPath      = "/api/v1/status"
+	maintenancePath = "/api/v1/maintenance/"
+	failoverPath    = "/api/v1/failover/"
+	injectPath      = "/api/v1/inject-failure/"
+	statePath       = "/api/v1/state"
+
+	// maxStateSize limits the size of imported states.
+	maxStateSize = 16 << 20
+)
+
+//go:embed ui/index.html
+var indexHtml []byte
+
+// RecordManager provides the state of the managed records and allows operators to intervene.
+type RecordManager interface {
+	History(hostname string) ([]internal.RecordHistory, bool)
+	Status() []internal.HostnameStatus
+	SetMaintenance(hostname, ip string, enabled bool) error
+	Failover(hostname string) ([]string, error)
+	InjectFailure(hostname, ip string, duration time.Duration) error
+	ExportState(ctx context.Context) (internal.State, error)
+	ImportState(ctx context.Context, state internal.State) error
+}
+
+// HistoryResponse is returned by the history endpoint.
+type HistoryResponse struct {
+	Hostname string                   `json:"hostname"`
+	Records  []internal.RecordHistory `json:"records"`
+}
+
+// FailoverResponse is returned by the failover endpoint.
+type FailoverResponse struct {
+	Hostname    string   `json:"hostname"`
+	Maintenance []string `json:"maintenance"`
+}
+
+// NewHandler returns the handler for all endpoints below Prefix and UiPrefix.
+func NewHandler(manager RecordManager) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+historyPath+"{hostname}", func(w http.ResponseWriter, r *http.Request) {
+		hostname := r.PathValue("hostname")
+		records, found := manager.History(hostname)
+		if !found {
+			http.Error(w, internal.ErrUnknownHostname.Error(), http.StatusNotFound)
+			return
+		}
+		writeJson(w, HistoryResponse{Hostname: hostname, Records: records})
+	})
+
+	mux.HandleFunc("GET "+statusPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, manager.Status())
+	})
+
+	setMaintenance := func(enabled bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if err := manager.SetMaintenance(r.PathValue("hostname"), r.PathValue("ip"), enabled); err != nil {
+				writeError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+	mux.Handle("PUT "+maintenancePath+"{hostname}/{ip}", requireHeader(setMaintenance(true)))
+	mux.Handle("DELETE "+maintenancePath+"{hostname}/{ip}", requireHeader(setMaintenance(false)))
+
+	mux.Handle("POST "+failoverPath+"{hostname}", requireHeader(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hostname := r.PathValue("hostname")
+		ips, err := manager.Failover(hostname)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJson(w, FailoverResponse{Hostname: hostname, Maintenance: ips})
+	})))
+
+	mux.Handle("PUT "+injectPath+"{hostname}/{ip}", requireHeader(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+		if err != nil || duration <= 0 {
+			http.Error(w, "positive duration required", http.StatusBadRequest)
+			return
+		}
+		if err := manager.InjectFailure(r.PathValue("hostname"), r.PathValue("ip"), duration); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	mux.Handle("DELETE "+injectPath+"{hostname}/{ip}", requireHeader(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := manager.InjectFailure(r.PathValue("hostname"), r.PathValue("ip"), 0); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	mux.HandleFunc("GET "+statePath, func(w http.ResponseWriter, r *http.Request) {
+		state, err := manager.ExportState(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		writeJson(w, state)
+	})
+	mux.Handle("PUT "+statePath, requireHeader(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var state internal.State
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxStateSize)).Decode(&state); err != nil {
+			http.Error(w, "invalid state: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := manager.ImportState(r.Context(), state); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})))
+
+	mux.HandleFunc("GET "+CheckPrefix+"{hostname}", func(w http.ResponseWriter, r *http.Request) {
+		hostname := r.PathValue("hostname")
+		hostnames := manager.Status()
+		index := slices.IndexFunc(hostnames, func(s internal.HostnameStatus) bool { return s.Hostname == hostname })
+		if index < 0 {
+			http.Error(w, internal.ErrUnknownHostname.Error(), http.StatusNotFound)
+			return
+		}
+		writeCheck(w, hostnames[index])
+	})
+
+	mux.HandleFunc("GET "+UiPrefix, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(indexHtml)
+	})
+
+	return mux
+}
+
+// writeCheck responds with 200 and the healthy addresses of the hostname, one per line, or 503 if none of its
+// records is healthy. Records in maintenance are not healthy, records in the error state keep their previous status.
+func writeCheck(w http.ResponseWriter, hostname internal.HostnameStatus) {
+	var healthy []string
+	for _, record := range hostname.Records {
+		if record.EffectiveStatus == status.HealthyStateName && !record.Maintenance {
+			healthy = append(healthy, record.Ip)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if len(healthy) == 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, "no healthy records\n")
+		return
+	}
+	_, _ = io.WriteString(w, strings.Join(healthy, "\n")+"\n")
+}
+
+func requireHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(RequestedByHeader) == "" {
+			http.Error(w, "missing "+RequestedByHeader+" header", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, internal.ErrUnknownHostname) || errors.Is(err, internal.ErrUnknownRecord) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, internal.ErrFailureInjectionDisabled) {
+		status = http.StatusForbidden
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func writeJson(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Warn("could not encode api response", "err", err)
+	}
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+)
+
+type dummyRecordManager struct {
+	history     map[string][]internal.RecordHistory
+	maintenance map[string]bool
+	injected    map[string]time.Duration
+	state       *internal.State
+}
+
+func (d *dummyRecordManager) History(hostname string) ([]internal.RecordHistory, bool) {
+	records, found := d.history[hostname]
+	return records, found
+}
+
+func (d *dummyRecordManager) Status() []internal.HostnameStatus {
+	return []internal.HostnameStatus{
+		{Hostname: "my.tld", Published: []string{"10.0.0.1"}, Records: []internal.RecordStatus{
+			{Ip: "10.0.0.1", Status: "healthy", EffectiveStatus: "healthy"},
+			{Ip: "10.0.0.2", Status: "unhealthy", EffectiveStatus: "unhealthy"},
+			{Ip: "10.0.0.3", Status: "healthy", EffectiveStatus: "healthy", Maintenance: true},
+			{Ip: "10.0.0.4", Status: "error", EffectiveStatus: "healthy"},
+		}},
+		{Hostname: "down.tld", Records: []internal.RecordStatus{{Ip: "10.0.1.1", Status: "error", EffectiveStatus: "unhealthy"}}},
+	}
+}
+
+func (d *dummyRecordManager) SetMaintenance(hostname, ip string, enabled bool) error {
+	if hostname != "my.tld" {
+		return internal.ErrUnknownHostname
+	}
+	d.maintenance[ip] = enabled
+	return nil
+}
+
+func (d *dummyRecordManager) InjectFailure(hostname, ip string, duration time.Duration) error {
+	if d.injected == nil {
+		return internal.ErrFailureInjectionDisabled
+	}
+	if hostname != "my.tld" {
+		return internal.ErrUnknownHostname
+	}
+	d.injected[ip] = duration
+	return nil
+}
+
+func (d *dummyRecordManager) Failover(hostname string) ([]string, error) {
+	return []string{"10.0.0.1"}, d.SetMaintenance(hostname, "10.0.0.1", true)
+}
+
+func (d *dummyRecordManager) ExportState(_ context.Context) (internal.State, error) {
+	return internal.State{Version: internal.StateVersion, Hostnames: []internal.HostnameState{{Hostname: "my.tld", Published: []string{"10.0.0.1"}}}}, nil
+}
+
+func (d *dummyRecordManager) ImportState(_ context.Context, state internal.State) error {
+	if state.Version != internal.StateVersion {
+		return errors.New("unsupported state version")
+	}
+	d.state = &state
+	return nil
+}
+
+func TestClient_History(t *testing.T) {
+	records := []internal.RecordHistory{{
+		Ip: "10.0.0.1",
+		Results: []internal.CheckResult{{
+			Timestamp: time.Date(2024, 1, 1, 3, 12, 0, 0, time.UTC),
+			Latency:   20 * time.Millisecond,
+			Error:     "connection refused",
+			Status:    "unhealthy",
+		}},
+	}}
+
+	server := httptest.NewServer(NewHandler(&dummyRecordManager{history: map[string][]internal.RecordHistory{"my.tld": records}}))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.History(t.Context(), "my.tld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Hostname != "my.tld" || !reflect.DeepEqual(got.Records, records) {
+		t.Errorf("got %+v, want %+v", got.Records, records)
+	}
+
+	if _, err := client.History(t.Context(), "unknown.tld"); err == nil {
+		t.Error("expected error for unknown hostname")
+	}
+}
+
+func TestClient_InjectFailure(t *testing.T) {
+	manager := &dummyRecordManager{injected: map[string]time.Duration{}}
+	server := httptest.NewServer(NewHandler(manager))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.InjectFailure(t.Context(), "my.tld", "10.0.0.1", 5*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.InjectFailure(t.Context(), "my.tld", "10.0.0.2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]time.Duration{"10.0.0.1": 5 * time.Minute, "10.0.0.2": 0}; !reflect.DeepEqual(manager.injected, want) {
+		t.Errorf("got %v, want %v", manager.injected, want)
+	}
+
+	if err := client.InjectFailure(t.Context(), "unknown.tld", "10.0.0.1", time.Minute); err == nil {
+		t.Error("expected error for unknown hostname")
+	}
+}
+
+func TestClient_State(t *testing.T) {
+	manager := &dummyRecordManager{}
+	server := httptest.NewServer(NewHandler(manager))
+	defer server.Close()
+
+	client, err := NewClient(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := client.ExportState(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ImportState(t.Context(), *state); err != nil {
+		t.Fatal(err)
+	}
+	if manager.state == nil || !reflect.DeepEqual(manager.state.Hostnames, state.Hostnames) {
+		t.Errorf("got imported state %+v, want %+v", manager.state, state)
+	}
+
+	if err := client.ImportState(t.Context(), internal.State{Version: 0}); err == nil {
+		t.Error("expected error for unsupported version")
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	manager := &dummyRecordManager{maintenance: map[string]bool{}}
+	handler := NewHandler(manager)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		header     bool
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "status", method: http.MethodGet, path: "/api/v1/status", wantStatus: http.StatusOK, wantBody: `"published":["10.0.0.1"]`},
+		{name: "check", method: http.MethodGet, path: "/check/my.tld", wantStatus: http.StatusOK, wantBody: "10.0.0.1\n10.0.0.4\n"},
+		{name: "check without healthy records", method: http.MethodGet, path: "/check/down.tld", wantStatus: http.StatusServiceUnavailable},
+		{name: "check unknown hostname", method: http.MethodGet, path: "/check/other.tld", wantStatus: http.StatusNotFound},
+		{name: "ui", method: http.MethodGet, path: "/ui/", wantStatus: http.StatusOK, wantBody: "<title>dns-ha</title>"},
+		{name: "maintenance without header", method: http.MethodPut, path: "/api/v1/maintenance/my.tld/10.0.0.2", wantStatus: http.StatusForbidden},
+		{name: "maintenance", method: http.MethodPut, path: "/api/v1/maintenance/my.tld/10.0.0.2", header: true, wantStatus: http.StatusNoContent},
+		{name: "maintenance unknown hostname", method: http.MethodPut, path: "/api/v1/maintenance/other.tld/10.0.0.2", header: true, wantStatus: http.StatusNotFound},
+		{name: "inject failure disabled", method: http.MethodPut, path: "/api/v1/inject-failure/my.tld/10.0.0.1?duration=5m", header: true, wantStatus: http.StatusForbidden},
+		{name: "inject failure without duration", method: http.MethodPut, path: "/api/v1/inject-failure/my.tld/10.0.0.1", header: true, wantStatus: http.StatusBadRequest},
+		{name: "import state without header", method: http.MethodPut, path: "/api/v1/state", wantStatus: http.StatusForbidden},
+		{name: "failover", method: http.MethodPost, path: "/api/v1/failover/my.tld", header: true, wantStatus: http.StatusOK, wantBody: `"maintenance":["10.0.0.1"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.header {
+				req.Header.Set(RequestedByHeader, "test")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("expected body to contain %q, got %q", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+
+	if !manager.maintenance["10.0.0.2"] || !manager.maintenance["10.0.0.1"] {
+		t.Errorf("expected records to be in maintenance, got %v", manager.maintenance)
+	}
+}
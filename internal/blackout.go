@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+	"github.com/soerenschneider/dns-ha/internal/schedule"
+)
+
+// inBlackout returns true while a blackout window of the hostname is active. The records keep being checked and
+// their state changes, but the published records are kept until the window ends.
+func (h *RecordManager) inBlackout(hostname string) bool {
+	now := h.clock.Now()
+	active := slices.ContainsFunc(h.hostnamePolicies[hostname].Blackouts, func(window *schedule.Window) bool {
+		return window.Active(now)
+	})
+	if active == h.blackouts[hostname] {
+		return active
+	}
+
+	if active {
+		slog.Info("Blackout window started, keeping published records", "hostname", hostname)
+		h.blackouts[hostname] = true
+		metrics.Blackout.WithLabelValues(hostname).Set(1)
+	} else {
+		slog.Info("Blackout window ended", "hostname", hostname)
+		delete(h.blackouts, hostname)
+		metrics.Blackout.WithLabelValues(hostname).Set(0)
+	}
+	return active
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/schedule"
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+func TestRecordManager_inBlackout(t *testing.T) {
+	primary := &ManagedDnsRecord{DnsRecord: DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, Hostname: "my.tld", status: &status.Healthy{}}
+	secondary := &ManagedDnsRecord{DnsRecord: DnsRecord{Priority: 10, DnsType: "A", Ip: net.ParseIP("10.0.0.2"), Ttl: 60}, Hostname: "my.tld", status: &status.Healthy{}}
+
+	// a window starting every minute is always active
+	always, err := schedule.NewWindow("* * * * *", time.Minute, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &dummyDnsDb{}
+	m, err := NewRecordManager(db, &dummyService{}, map[string][]*ManagedDnsRecord{"my.tld": {primary, secondary}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.applyRecords(context.Background())
+	if want := []string{"A 10.0.0.1"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Fatalf("published %v, want %v", db.updates["my.tld"], want)
+	}
+
+	m.hostnamePolicies = map[string]HostnamePolicy{"my.tld": {Blackouts: []*schedule.Window{always}}}
+	primary.status = &status.Unhealthy{}
+	m.applyRecords(context.Background())
+	if want := []string{"A 10.0.0.1"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Errorf("published %v during blackout, want %v", db.updates["my.tld"], want)
+	}
+	if !m.blackouts["my.tld"] {
+		t.Error("expected blackout to be tracked")
+	}
+
+	m.hostnamePolicies = nil
+	m.applyRecords(context.Background())
+	if want := []string{"A 10.0.0.2"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Errorf("published %v after blackout, want %v", db.updates["my.tld"], want)
+	}
+	if m.blackouts["my.tld"] {
+		t.Error("expected blackout to have ended")
+	}
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+)
+
+// ErrFailureInjectionDisabled is returned if failures are injected without enabling failure injection.
+var ErrFailureInjectionDisabled = errors.New("failure injection is disabled")
+
+// WithFailureInjection allows operators to inject simulated check failures to rehearse failovers. It should only be
+// enabled in staging environments.
+func WithFailureInjection() RecordManagerOpts {
+	return func(m *RecordManager) error {
+		m.failureInjection = true
+		return nil
+	}
+}
+
+// InjectFailure makes all checks of the record fail for the given duration without running them, a duration of zero
+// stops a running injection. The record's state changes as if the service had actually failed.
+func (h *RecordManager) InjectFailure(hostname, ip string, duration time.Duration) error {
+	if !h.failureInjection {
+		return ErrFailureInjectionDisabled
+	}
+	if duration < 0 {
+		return errors.New("duration must not be negative")
+	}
+
+	record, err := h.findRecord(hostname, ip)
+	if err != nil {
+		return err
+	}
+
+	var until time.Time
+	if duration > 0 {
+		until = h.clock.Now().Add(duration)
+	}
+	if err := record.injectFailure(until); err != nil {
+		return err
+	}
+
+	if until.IsZero() {
+		slog.Warn("Stopped injecting simulated check failures", "hostname", hostname, "ip", ip)
+	} else {
+		slog.Warn("Injecting simulated check failures", "hostname", hostname, "ip", ip, "until", until)
+	}
+	return nil
+}
+
+func (r *ManagedDnsRecord) injectFailure(until time.Time) error {
+	if r.shared == nil {
+		return errors.New("record does not support failure injection")
+	}
+
+	r.shared.mutex.Lock()
+	defer r.shared.mutex.Unlock()
+	r.shared.injectedUntil = until
+	return nil
+}
+
+// failureInjected returns true while simulated failures are injected into the record's checks.
+func (r *ManagedDnsRecord) failureInjected(now time.Time) bool {
+	if r.shared == nil {
+		return false
+	}
+
+	r.shared.mutex.Lock()
+	defer r.shared.mutex.Unlock()
+	return now.Before(r.shared.injectedUntil)
+}
+
+func (r *ManagedDnsRecord) recordInjectedFailure() {
+	metrics.InjectedFailures.WithLabelValues(r.Hostname, r.Ip.String()).Inc()
+	slog.Info("Simulated check failure", "hostname", r.Hostname, "ip", r.Ip)
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestRecordManager_InjectFailure(t *testing.T) {
+	newRecord := func(ip string, prio uint8) *ManagedDnsRecord {
+		record, err := NewManagedDnsRecord("my.tld", DnsRecord{Priority: prio, DnsType: "A", Ip: net.ParseIP(ip), Ttl: 60}, conf.StatusConfig{
+			HealthyStreak:          1,
+			UnhealthyStreak:        1,
+			InitialHealthyStreak:   1,
+			InitialUnhealthyStreak: 1,
+		}, &dummyHealthcheck{ret: true}, WithHistorySize(10))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record
+	}
+
+	records := map[string][]*ManagedDnsRecord{"my.tld": {newRecord("10.0.0.1", 20), newRecord("10.0.0.2", 10)}}
+	disabled, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := disabled.InjectFailure("my.tld", "10.0.0.1", time.Minute); !errors.Is(err, ErrFailureInjectionDisabled) {
+		t.Fatalf("expected injection to be disabled, got %v", err)
+	}
+
+	db := &dummyDnsDb{}
+	m, err := NewRecordManager(db, &dummyService{}, records, WithFailureInjection())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.InjectFailure("my.tld", "10.0.0.1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	m.CheckRecords(context.Background())
+	if want := []string{"A 10.0.0.2"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Fatalf("expected failover to the other record, got %v", db.updates["my.tld"])
+	}
+	history := records["my.tld"][0].History()
+	if len(history) != 1 || !history[0].Injected || history[0].Healthy {
+		t.Errorf("expected injected failure in history, got %+v", history)
+	}
+	if status := records["my.tld"][0].Status(); status.InjectedUntil == nil {
+		t.Error("expected status to report the injection")
+	}
+
+	if err := m.InjectFailure("my.tld", "10.0.0.1", 0); err != nil {
+		t.Fatal(err)
+	}
+	m.CheckRecords(context.Background())
+	if want := []string{"A 10.0.0.1"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Errorf("expected record to recover after the injection stopped, got %v", db.updates["my.tld"])
+	}
+}
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+)
+
+// hostnameResults are the results of a single pass of the check loop of a hostname. Run closes done once the results
+// have been applied, the loop does not touch the records before.
+type hostnameResults struct {
+	generation uint64
+	hostname   string
+	records    []*ManagedDnsRecord
+	probes     []*probeResult
+	done       chan struct{}
+}
+
+// startCheckLoops starts a check loop for each hostname, the loops are staggered across the check stagger window.
+func (h *RecordManager) startCheckLoops(ctx context.Context) {
+	ctx, h.stopLoops = context.WithCancel(ctx)
+	h.loopGeneration++
+	// the keys of replaced records are not checked anymore
+	h.checks = newCheckCache()
+
+	hostnames := slices.Sorted(maps.Keys(h.managedRecords))
+	for index, hostname := range hostnames {
+		var offset time.Duration
+		if h.checkStagger > 0 {
+			offset = h.checkStagger * time.Duration(index) / time.Duration(len(hostnames))
+		}
+		interval := cmp.Or(h.hostnamePolicies[hostname].CheckInterval, h.checkInterval)
+		records := slices.Clone(h.managedRecords[hostname])
+		generation := h.loopGeneration
+
+		h.loops.Add(1)
+		go func() {
+			defer h.loops.Done()
+			h.runCheckLoop(ctx, generation, hostname, records, interval, offset)
+		}()
+	}
+}
+
+// stopCheckLoops stops all check loops and waits for them to return.
+func (h *RecordManager) stopCheckLoops() {
+	if h.stopLoops == nil {
+		return
+	}
+	h.stopLoops()
+	h.loops.Wait()
+	h.stopLoops = nil
+}
+
+// runCheckLoop checks the records of the hostname once every interval until the context is canceled. Each hostname
+// has its own loop, so slow checks of a hostname neither delay the checks nor the failover of other hostnames.
+func (h *RecordManager) runCheckLoop(ctx context.Context, generation uint64, hostname string, records []*ManagedDnsRecord, interval, offset time.Duration) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-h.clock.After(offset):
+	}
+
+	changes := watchChanges(ctx, records)
+	ticker := h.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// results of other hostnames are reused within the interval, unless the healthchecks signaled a change
+		maxAge := interval
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		case <-changes:
+			slog.Debug("Healthcheck signaled a change, checking hostname", "hostname", hostname)
+			maxAge = 0
+		}
+
+		if h.guardActive.Load() {
+			continue
+		}
+
+		start := h.clock.Now()
+		probes := h.probeRecords(withCheckCache(ctx, h.checks, maxAge), records, interval)
+		if elapsed := h.clock.Since(start); elapsed >= interval {
+			slog.Warn("Checks of hostname took longer than its check interval", "hostname", hostname, "duration", elapsed, "interval", interval)
+		}
+
+		results := hostnameResults{generation: generation, hostname: hostname, records: records, probes: probes, done: make(chan struct{})}
+		select {
+		case <-ctx.Done():
+			return
+		case h.checkResults <- results:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-results.done:
+		}
+	}
+}
+
+// watchChanges merges the changes signaled by the healthchecks of the records, it returns nil if none of them signal
+// changes.
+func watchChanges(ctx context.Context, records []*ManagedDnsRecord) <-chan struct{} {
+	var merged chan struct{}
+	for _, record := range records {
+		notifier, ok := record.healthCheck.(ChangeNotifier)
+		if !ok {
+			continue
+		}
+		if merged == nil {
+			merged = make(chan struct{}, 1)
+		}
+
+		changes := notifier.Changes(ctx)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-changes:
+				}
+				select {
+				case merged <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+	return merged
+}
+
+// probeRecords runs the healthchecks of the records that are not backed off, a pass never takes longer than the
+// interval. Records that have not been checked have no result. Identical checks share their results via the cache of
+// the context, the records are staggered across the check stagger window.
+func (h *RecordManager) probeRecords(ctx context.Context, records []*ManagedDnsRecord, interval time.Duration) []*probeResult {
+	ctx, cancel := context.WithTimeout(ctx, interval)
+	defer cancel()
+
+	now := h.clock.Now()
+	probes := make([]*probeResult, len(records))
+	var wg sync.WaitGroup
+	for index, record := range records {
+		if !record.ShouldCheck(now) {
+			metrics.ChecksSkipped.WithLabelValues(record.Hostname, record.Ip.String()).Inc()
+			continue
+		}
+
+		wg.Add(1)
+		go func(delay time.Duration) {
+			defer wg.Done()
+			select {
+			case <-ctx.Done():
+				return
+			case <-h.clock.After(delay):
+			}
+
+			select {
+			case <-ctx.Done():
+			case h.checkSlots <- struct{}{}:
+				defer func() { <-h.checkSlots }()
+				probe := record.probe(ctx)
+				probes[index] = &probe
+			}
+		}(h.probeDelay(index, len(records)))
+	}
+
+	wg.Wait()
+	return probes
+}
+
+// applyCheckResults applies the results of a check loop and of all other loops that are waiting and publishes the
+// records of the affected hostnames in a single update.
+func (h *RecordManager) applyCheckResults(ctx context.Context, results hostnameResults) {
+	var hostnames []string
+	for {
+		// results of stopped loops belong to records that have been replaced in the meantime
+		if results.generation == h.loopGeneration && !h.guardActive.Load() {
+			for index, probe := range results.probes {
+				if probe != nil {
+					results.records[index].applyProbe(*probe)
+				}
+			}
+			hostnames = append(hostnames, results.hostname)
+		}
+		close(results.done)
+
+		select {
+		case results = <-h.checkResults:
+			continue
+		default:
+		}
+		break
+	}
+
+	if len(hostnames) > 0 {
+		h.applyRecords(ctx, hostnames...)
+	}
+}
@@ -0,0 +1,215 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/clock"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+// switchableHealthcheck returns the configured health and hangs until the context is canceled while blocking.
+type switchableHealthcheck struct {
+	unhealthy atomic.Bool
+	blocking  atomic.Bool
+}
+
+func (s *switchableHealthcheck) IsHealthy(ctx context.Context) (bool, error) {
+	if s.blocking.Load() {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	return !s.unhealthy.Load(), nil
+}
+
+type lockedDnsDb struct {
+	mutex sync.Mutex
+	dummyDnsDb
+}
+
+func (d *lockedDnsDb) Apply(ctx context.Context, desired map[string][]ManagedDnsRecord) (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.dummyDnsDb.Apply(ctx, desired)
+}
+
+func (d *lockedDnsDb) published(hostname string) []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.updates[hostname]
+}
+
+func TestRecordManager_checkLoopsAreIndependent(t *testing.T) {
+	slowCheck, fastCheck := &switchableHealthcheck{}, &switchableHealthcheck{}
+	newRecord := func(hostname, ip string, prio uint8, check Healthcheck) *ManagedDnsRecord {
+		return &ManagedDnsRecord{
+			DnsRecord:    DnsRecord{Priority: prio, DnsType: "A", Ip: net.ParseIP(ip), Ttl: 60},
+			Hostname:     hostname,
+			status:       &status.Healthy{},
+			healthCheck:  check,
+			checkTimeout: time.Hour,
+			history:      newCheckHistory(defaultHistorySize),
+			shared:       &sharedState{},
+		}
+	}
+	records := map[string][]*ManagedDnsRecord{
+		"slow.tld": {newRecord("slow.tld", "10.0.1.1", 10, slowCheck)},
+		"fast.tld": {
+			newRecord("fast.tld", "10.0.0.1", 20, fastCheck),
+			newRecord("fast.tld", "10.0.0.2", 10, &dummyHealthcheck{ret: true}),
+		},
+	}
+
+	db := &lockedDnsDb{}
+	m, err := NewRecordManager(db, &dummyService{}, records, WithCheckInterval(time.Hour), WithHostnamePolicies(map[string]HostnamePolicy{
+		"slow.tld": {CheckInterval: 200 * time.Millisecond},
+		"fast.tld": {CheckInterval: 10 * time.Millisecond},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitFor := func(want []string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if got := db.published("fast.tld"); reflect.DeepEqual(got, want) {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("published %v, want %v", db.published("fast.tld"), want)
+	}
+	waitFor([]string{"A 10.0.0.1"})
+
+	// the checks of slow.tld hang for their whole interval, the failover of fast.tld must not wait for them
+	slowCheck.blocking.Store(true)
+	time.Sleep(250 * time.Millisecond)
+	fastCheck.unhealthy.Store(true)
+	waitFor([]string{"A 10.0.0.2"})
+}
+
+type notifyingHealthcheck struct {
+	switchableHealthcheck
+	changes chan struct{}
+}
+
+func (n *notifyingHealthcheck) Changes(_ context.Context) <-chan struct{} {
+	return n.changes
+}
+
+func TestRecordManager_checkLoopReactsToChanges(t *testing.T) {
+	check := &notifyingHealthcheck{changes: make(chan struct{})}
+	newRecord := func(ip string, prio uint8, check Healthcheck) *ManagedDnsRecord {
+		return &ManagedDnsRecord{
+			DnsRecord:    DnsRecord{Priority: prio, DnsType: "A", Ip: net.ParseIP(ip), Ttl: 60},
+			Hostname:     "test.tld",
+			status:       &status.Healthy{},
+			healthCheck:  check,
+			checkTimeout: time.Hour,
+			history:      newCheckHistory(defaultHistorySize),
+			shared:       &sharedState{},
+		}
+	}
+	records := map[string][]*ManagedDnsRecord{
+		"test.tld": {
+			newRecord("10.0.0.1", 20, check),
+			newRecord("10.0.0.2", 10, &dummyHealthcheck{ret: true}),
+		},
+	}
+
+	db := &lockedDnsDb{}
+	m, err := NewRecordManager(db, &dummyService{}, records, WithCheckInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitFor := func(want []string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if got := db.published("test.tld"); reflect.DeepEqual(got, want) {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("published %v, want %v", db.published("test.tld"), want)
+	}
+
+	// the check interval never elapses, the records are only checked due to the signaled changes
+	check.changes <- struct{}{}
+	waitFor([]string{"A 10.0.0.1"})
+
+	check.unhealthy.Store(true)
+	check.changes <- struct{}{}
+	waitFor([]string{"A 10.0.0.2"})
+}
+
+func TestRecordManager_probeRecordsStaggered(t *testing.T) {
+	statusConf := conf.StatusConfig{HealthyStreak: 1, UnhealthyStreak: 1, InitialHealthyStreak: 1, InitialUnhealthyStreak: 1}
+	fake := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	checks := []*countingHealthcheck{{}, {}}
+	var records []*ManagedDnsRecord
+	for index, check := range checks {
+		record, err := NewManagedDnsRecord("my.tld", DnsRecord{DnsType: "A", Ip: net.IPv4(10, 0, 0, byte(index+1)), Ttl: 60}, statusConf, check)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	m, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, map[string][]*ManagedDnsRecord{"my.tld": records}, WithClock(fake), WithCheckStagger(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan []*probeResult)
+	go func() {
+		done <- m.probeRecords(context.Background(), records, time.Hour)
+	}()
+
+	// the first record is probed right away, the second one waits for half of the stagger window
+	deadline := time.Now().Add(5 * time.Second)
+	for checks[0].probes.Load() == 0 || fake.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the first record to be probed while the second one waits")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if probes := checks[1].probes.Load(); probes != 0 {
+		t.Fatalf("expected the second record to wait for its delay, got %d probes", probes)
+	}
+
+	fake.Advance(30 * time.Second)
+	if probes := <-done; probes[0] == nil || probes[1] == nil {
+		t.Errorf("expected both records to be probed, got %v", probes)
+	}
+}
@@ -0,0 +1,130 @@
+package clock
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Fake is a clock whose time only moves when it's advanced. Timers and tickers fire while the time is advanced past
+// their deadline, channels never block and drop ticks like tickers of the time package.
+type Fake struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []*waiter
+}
+
+type waiter struct {
+	clock    *Fake
+	deadline time.Time
+	// period is zero for timers
+	period time.Duration
+	c      chan time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.add(d, 0)
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return fakeTicker{f.add(d, d)}
+}
+
+func (f *Fake) add(d, period time.Duration) *waiter {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	w := &waiter{clock: f, deadline: f.now.Add(d), period: period, c: make(chan time.Time, 1)}
+	if d <= 0 && period == 0 {
+		w.c <- f.now
+		return w
+	}
+	f.waiters = append(f.waiters, w)
+	return w
+}
+
+// Advance moves the time forward, firing all timers and tickers whose deadline passes in the order of their
+// deadlines.
+func (f *Fake) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	end := f.now.Add(d)
+	for {
+		index := -1
+		for i, w := range f.waiters {
+			if !w.deadline.After(end) && (index < 0 || w.deadline.Before(f.waiters[index].deadline)) {
+				index = i
+			}
+		}
+		if index < 0 {
+			break
+		}
+
+		w := f.waiters[index]
+		f.now = w.deadline
+		select {
+		case w.c <- f.now:
+		default:
+		}
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+		} else {
+			f.waiters = slices.Delete(f.waiters, index, index+1)
+		}
+	}
+	f.now = end
+}
+
+// Waiters returns the amount of pending timers and tickers, e.g. to wait for a goroutine to start waiting.
+func (f *Fake) Waiters() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.waiters)
+}
+
+func (w *waiter) C() <-chan time.Time {
+	return w.c
+}
+
+func (w *waiter) Stop() bool {
+	f := w.clock
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	index := slices.Index(f.waiters, w)
+	if index < 0 {
+		return false
+	}
+	f.waiters = slices.Delete(f.waiters, index, index+1)
+	return true
+}
+
+type fakeTicker struct {
+	*waiter
+}
+
+func (t fakeTicker) Stop() {
+	t.waiter.Stop()
+}
@@ -0,0 +1,51 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake_Advance(t *testing.T) {
+	start := time.Unix(0, 0)
+	fake := NewFake(start)
+
+	timer := fake.NewTimer(90 * time.Second)
+	ticker := fake.NewTicker(time.Minute)
+	select {
+	case <-fake.After(0):
+	default:
+		t.Fatal("expected After(0) to fire immediately")
+	}
+
+	fake.Advance(time.Minute)
+	select {
+	case got := <-ticker.C():
+		if want := start.Add(time.Minute); !got.Equal(want) {
+			t.Errorf("ticker fired at %v, want %v", got, want)
+		}
+	default:
+		t.Fatal("expected ticker to fire")
+	}
+	select {
+	case <-timer.C():
+		t.Fatal("expected timer not to fire yet")
+	default:
+	}
+
+	// ticks that are not received are dropped
+	fake.Advance(5 * time.Minute)
+	if got := len(ticker.C()); got != 1 {
+		t.Errorf("expected a single pending tick, got %d", got)
+	}
+	if got := <-timer.C(); !got.Equal(start.Add(90 * time.Second)) {
+		t.Errorf("timer fired at %v", got)
+	}
+	if got := fake.Now(); !got.Equal(start.Add(6 * time.Minute)) {
+		t.Errorf("Now() = %v", got)
+	}
+
+	ticker.Stop()
+	if fake.Waiters() != 0 {
+		t.Errorf("expected no waiters after stopping the ticker, got %d", fake.Waiters())
+	}
+}
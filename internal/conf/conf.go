@@ -1,41 +1,372 @@
 package conf
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"net/netip"
 	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/soerenschneider/dns-ha/internal/remote"
+	"github.com/soerenschneider/dns-ha/internal/schedule"
+	"github.com/soerenschneider/dns-ha/internal/vault"
 	"go.uber.org/multierr"
 	"gopkg.in/yaml.v3"
 )
 
 const (
 	defaultUnboundServiceName = "unbound"
+	defaultUnboundBackups     = 3
+	defaultBindBackups        = 3
+	defaultMsDnsServiceName   = "DNS"
+	defaultHostsFile          = "/etc/hosts"
+	defaultResolvedBackups    = 3
+	defaultCoreDnsBackups     = 3
+	defaultZoneServerBackups  = 3
 	defaultMetricsAddr        = "127.0.0.1:9223"
+	defaultCheckInterval      = 30 * time.Second
+	defaultMaxConcurrency     = 32
+	defaultHistorySize        = 100
+	defaultResolveInterval    = 5 * time.Minute
+	remoteTimeout             = 30 * time.Second
 )
 
 var (
-	validate *validator.Validate = validator.New()
+	validate *validator.Validate = newValidator()
 )
 
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// report fields by their yaml name, so errors can be mapped to the config file
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	// the baked-in port validation only works for unsigned integers
+	_ = v.RegisterValidation("port", func(fl validator.FieldLevel) bool {
+		switch fl.Field().Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			val := fl.Field().Int()
+			return val >= 1 && val <= 65535
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			val := fl.Field().Uint()
+			return val >= 1 && val <= 65535
+		}
+		return false
+	})
+
+	// hostname that may start with a wildcard label, e.g. "*.apps.example.com"
+	_ = v.RegisterValidation("wildcard_hostname", func(fl validator.FieldLevel) bool {
+		return v.Var(strings.TrimPrefix(fl.Field().String(), "*."), "hostname") == nil
+	})
+
+	// ip that may carry the zone of an IPv6 link-local address, e.g. "fe80::1%eth0"
+	_ = v.RegisterValidation("zoned_ip", func(fl validator.FieldLevel) bool {
+		addr, err := netip.ParseAddr(fl.Field().String())
+		return err == nil && (addr.Zone() == "" || addr.Is6() && addr.IsLinkLocalUnicast())
+	})
+
+	// name of a Prometheus metric or label, names starting with "__" are reserved
+	metricName := regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	_ = v.RegisterValidation("metric_name", func(fl validator.FieldLevel) bool {
+		name := fl.Field().String()
+		return metricName.MatchString(name) && !strings.HasPrefix(name, "__")
+	})
+
+	_ = v.RegisterValidation("file_mode", func(fl validator.FieldLevel) bool {
+		_, err := ParseFileMode(fl.Field().String())
+		return err == nil
+	})
+
+	_ = v.RegisterValidation("cron", func(fl validator.FieldLevel) bool {
+		_, err := schedule.ParseCron(fl.Field().String())
+		return err == nil
+	})
+
+	return v
+}
+
+// viewSeparator separates the hostname from the name of the unbound view in the keys of the records, e.g.
+// "ha.example.com@internal".
+const viewSeparator = "@"
+
 type Config struct {
-	Records map[string][]RecordConfig `json:"records" yaml:"records" validate:"dive,dive"`
-	Unbound UnboundConfig             `json:"unbound" yaml:"unbound"`
+	Records   map[string][]RecordConfig `json:"records" yaml:"records" validate:"dive,dive"`
+	Hostnames map[string]HostnameConfig `json:"hostnames" yaml:"hostnames" validate:"dive"`
+	Unbound   UnboundConfig             `json:"unbound" yaml:"unbound"`
+	Hooks     HooksConfig               `json:"hooks" yaml:"hooks"`
+	Service   ServiceConfig             `json:"service" yaml:"service"`
+	Vault     *vault.Config             `json:"vault" yaml:"vault"`
+	// Privileges drops the privileges of the process after the config has been read.
+	Privileges *PrivilegesConfig `json:"privileges" yaml:"privileges"`
+	// Kubernetes additionally reads records from ManagedDnsRecord custom resources.
+	Kubernetes *KubernetesConfig `json:"kubernetes" yaml:"kubernetes"`
+	// Bind manages the records in the zone file of a BIND server instead of unbound.
+	Bind *BindConfig `json:"bind" yaml:"bind"`
+	// MsDns manages the records of a zone hosted by Microsoft DNS Server instead of unbound.
+	MsDns *MsDnsConfig `json:"msdns" yaml:"msdns" validate:"excluded_with=Bind"`
+	// Resolved manages the records in the hosts file served by systemd-resolved instead of unbound.
+	Resolved *ResolvedConfig `json:"resolved" yaml:"resolved" validate:"excluded_with=Bind MsDns"`
+	// CoreDns manages the records in the hosts file served by the hosts plugin of CoreDNS instead of unbound.
+	CoreDns *CoreDnsConfig `json:"coredns" yaml:"coredns" validate:"excluded_with=Bind MsDns Resolved"`
+	// Nsd manages the records in the zone file of an NSD server instead of unbound.
+	Nsd *ZoneServerConfig `json:"nsd" yaml:"nsd" validate:"excluded_with=Bind MsDns Resolved CoreDns"`
+	// Knot manages the records in the zone file of a Knot DNS server instead of unbound.
+	Knot *ZoneServerConfig `json:"knot" yaml:"knot" validate:"excluded_with=Bind MsDns Resolved CoreDns Nsd"`
+	// UnboundInstances are additional, independent unbound instances that hostnames can be assigned to.
+	UnboundInstances map[string]UnboundConfig `json:"unbound_instances" yaml:"unbound_instances" validate:"dive"`
+	// DnsProviders are named hosted DNS providers that hostnames are managed at instead of the local DNS server.
+	DnsProviders map[string]DnsProviderConfig `json:"dns_providers" yaml:"dns_providers" validate:"dive"`
+
+	// HealthcheckTemplates are named healthcheckers that are referenced by records using the template key.
+	HealthcheckTemplates map[string]HealthcheckConfig `json:"healthcheck_templates" yaml:"healthcheck_templates" validate:"-"`
+	// HealthGroups are named healthchecks whose result is shared by all records that reference the group using
+	// health_group, instead of checking each record on its own.
+	HealthGroups map[string]HealthGroupConfig `json:"health_groups" yaml:"health_groups" validate:"dive"`
 
 	MetricsFile string `json:"metrics_file" yaml:"metrics_file" validate:"excluded_with=MetricsAddr,omitempty,filepath"`
 	MetricsAddr string `json:"metrics_addr" yaml:"metrics_addr" validate:"excluded_with=MetricsFile,omitempty,hostname_port"`
+	// MetricsFileInterval is the interval the metrics file is written in, it's written right away on changes as well.
+	MetricsFileInterval time.Duration `json:"metrics_file_interval" yaml:"metrics_file_interval" validate:"omitempty,gte=1s"`
+	// MetricsTls serves the metrics via TLS, optionally requiring client certificates.
+	MetricsTls *MetricsTlsConfig `json:"metrics_tls" yaml:"metrics_tls"`
+	// MetricsBasicAuth protects the metrics endpoint using basic auth.
+	MetricsBasicAuth *BasicAuthConfig `json:"metrics_basic_auth" yaml:"metrics_basic_auth"`
+	// MetricsBearerTokens protects the metrics endpoint using bearer tokens, alternatively to basic auth.
+	MetricsBearerTokens []string `json:"metrics_bearer_tokens" yaml:"metrics_bearer_tokens" validate:"dive,required"`
+	// MetricsRateLimit is the amount of requests each client may send per minute to the metrics endpoint and the API.
+	MetricsRateLimit int `json:"metrics_rate_limit" yaml:"metrics_rate_limit" validate:"gte=0"`
+	// MetricsPush periodically pushes the metrics for hosts that can not be scraped.
+	MetricsPush *MetricsPushConfig `json:"metrics_push" yaml:"metrics_push"`
+	// MetricsSinks emit the metrics to monitoring systems other than Prometheus.
+	MetricsSinks []MetricsSinkConfig `json:"metrics_sinks" yaml:"metrics_sinks" validate:"dive"`
+	// MetricsNamespace replaces the "dns_ha" prefix of all metric names.
+	MetricsNamespace string `json:"metrics_namespace" yaml:"metrics_namespace" validate:"omitempty,metric_name"`
+	// MetricsLabels are added to all metrics, e.g. the site or the role of the instance.
+	MetricsLabels map[string]string `json:"metrics_labels" yaml:"metrics_labels" validate:"dive,keys,metric_name,endkeys"`
+
+	// OnShutdown defines what happens to the published records when dns-ha stops: "keep" leaves them in place,
+	// "publish_all" publishes the shutdown_ips of each hostname or all of its records that are not unhealthy and
+	// "remove" removes all managed records.
+	OnShutdown string `json:"on_shutdown" yaml:"on_shutdown" validate:"omitempty,oneof=keep publish_all remove"`
+
+	// FailureInjection allows injecting simulated check failures via the API to rehearse failovers.
+	FailureInjection bool `json:"failure_injection" yaml:"failure_injection"`
+	// StateTxt publishes a TXT record alongside each hostname containing the published addresses and the time they
+	// last changed.
+	StateTxt bool `json:"state_txt" yaml:"state_txt"`
+
+	// Guard suspends all decisions while the local connectivity check fails.
+	Guard *GuardConfig `json:"guard" yaml:"guard"`
+
+	// CheckInterval is the time between two check cycles, it also caps the duration of a single cycle.
+	CheckInterval time.Duration `json:"check_interval" yaml:"check_interval" validate:"gte=1s"`
+	// CheckJitter adds a random delay in [0, CheckJitter) to each healthcheck.
+	CheckJitter time.Duration `json:"check_jitter" yaml:"check_jitter" validate:"gte=0"`
+	// CheckStagger is the window across which the healthchecks of all records are evenly spread.
+	CheckStagger time.Duration `json:"check_stagger" yaml:"check_stagger" validate:"gte=0"`
+	// MaxConcurrentChecks limits the amount of healthchecks running in parallel.
+	MaxConcurrentChecks int `json:"max_concurrent_checks" yaml:"max_concurrent_checks" validate:"gte=1"`
+	// ResolveInterval is the time between two lookups of the records that are configured by target hostname.
+	ResolveInterval time.Duration `json:"resolve_interval" yaml:"resolve_interval" validate:"omitempty,gte=1s"`
+
+	vaultClient *vault.Client
+}
+
+// VaultClient returns the client that has been used to resolve vault references or nil if the config did not
+// contain any.
+func (c *Config) VaultClient() *vault.Client {
+	return c.vaultClient
+}
+
+// UsesIcmp returns true if any record, health group or the guard is checked using ICMP.
+func (c *Config) UsesIcmp() bool {
+	return c.Guard != nil || len(c.icmpChecks()) > 0
+}
+
+// icmpChecks returns the ICMP healthcheckers of the records and the health groups along with their names.
+func (c *Config) icmpChecks() map[string]*IcmpHealthcheckConfig {
+	ret := map[string]*IcmpHealthcheckConfig{}
+	add := func(name string, check *HealthcheckConfig) {
+		for ; check != nil; check = check.Passive {
+			if check.Icmp != nil {
+				ret[name] = check.Icmp
+			}
+		}
+	}
+	for hostname, records := range c.Records {
+		for _, record := range records {
+			add(fmt.Sprintf("record %q of hostname %q", record.IP, hostname), &record.HealthcheckConfig)
+		}
+	}
+	for name, group := range c.HealthGroups {
+		add(fmt.Sprintf("health group %q", name), &group.HealthcheckConfig)
+	}
+	return ret
+}
+
+// validatePrivilegedPings rejects privileged pings, as they need CAP_NET_RAW, which is dropped along with the
+// privileges of the process.
+func (c *Config) validatePrivilegedPings() error {
+	var errs error
+	for name, check := range c.icmpChecks() {
+		if check.Privileged != nil && *check.Privileged {
+			errs = multierr.Append(errs, fmt.Errorf("privileged icmp healthchecker of %s can not be used after dropping privileges", name))
+		}
+	}
+	if c.Guard != nil && c.Guard.Privileged != nil && *c.Guard.Privileged {
+		errs = multierr.Append(errs, errors.New("privileged guard can not be used after dropping privileges"))
+	}
+	return errs
+}
+
+// unboundBackend returns whether unbound manages the records, which is the case unless another backend is configured.
+func (c *Config) unboundBackend() bool {
+	return c.Bind == nil && c.MsDns == nil && c.Resolved == nil && c.CoreDns == nil && c.Nsd == nil && c.Knot == nil
 }
 
 func (c *Config) Validate() error {
 	var errs error
-	if err := validate.Struct(c); err != nil {
+	if !c.unboundBackend() {
+		// the unbound settings are not used if another backend is configured
+		if err := validate.StructExcept(c, "Unbound"); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	if c.Bind != nil {
+		errs = multierr.Append(errs, c.validateZoneRecords("bind", c.Bind.Zone))
+	} else if c.MsDns != nil {
+		errs = multierr.Append(errs, c.validateZoneRecords("msdns", c.MsDns.Zone))
+	} else if c.Nsd != nil {
+		errs = multierr.Append(errs, c.validateZoneRecords("nsd", c.Nsd.Zone))
+	} else if c.Knot != nil {
+		errs = multierr.Append(errs, c.validateZoneRecords("knot", c.Knot.Zone))
+	} else if c.Resolved != nil {
+		errs = multierr.Append(errs, c.validateHostsRecords("resolved"))
+		if c.Resolved.HostsFile != defaultHostsFile {
+			errs = multierr.Append(errs, fmt.Errorf("systemd-resolved only reads %s, hosts_file %q is not supported", defaultHostsFile, c.Resolved.HostsFile))
+		}
+	} else if c.CoreDns != nil {
+		errs = multierr.Append(errs, c.validateHostsRecords("coredns"))
+	} else if err := validate.Struct(c); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+
+	if c.CheckJitter+c.CheckStagger >= c.CheckInterval {
+		errs = multierr.Append(errs, fmt.Errorf("check_jitter and check_stagger combined must be lower than check_interval %v", c.CheckInterval))
+	}
+
+	if c.Service.Timeout >= c.CheckInterval {
+		errs = multierr.Append(errs, fmt.Errorf("service timeout %v must be lower than check_interval %v", c.Service.Timeout, c.CheckInterval))
+	}
+	// retries block the processing of check results, they must be done before the next cycle
+	if retry := c.Service.Retry; retry != nil {
+		if budget := retry.Budget(cmp.Or(c.Service.Timeout, c.CheckInterval/2)); budget >= c.CheckInterval {
+			errs = multierr.Append(errs, fmt.Errorf("retries may take up to %v including the service timeouts, which must be lower than check_interval %v", budget, c.CheckInterval))
+		}
+	}
+
+	if c.Guard != nil && c.Guard.Timeout >= c.CheckInterval {
+		errs = multierr.Append(errs, fmt.Errorf("guard timeout %v must be lower than check_interval %v", c.Guard.Timeout, c.CheckInterval))
+	}
+
+	invalidTemplates := map[string]struct{}{}
+	for name, template := range c.HealthcheckTemplates {
+		if err := template.Validate(); err != nil {
+			invalidTemplates[name] = struct{}{}
+			errs = multierr.Append(errs, fmt.Errorf("invalid healthcheck template %q: %w", name, err))
+		}
+	}
+
+	for name, group := range c.HealthGroups {
+		if err := group.HealthcheckConfig.Validate(); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("invalid healthchecker for health group %q: %w", name, err))
+		}
+		if timeout := group.HealthcheckConfig.EffectiveTimeout(); timeout >= c.CheckInterval {
+			errs = multierr.Append(errs, fmt.Errorf("healthchecker timeout %v for health group %q must be lower than check_interval %v", timeout, name, c.CheckInterval))
+		}
+	}
+
+	dbFiles := map[string]string{c.Unbound.DbFile: "unbound"}
+	errs = multierr.Append(errs, validateViewNames("unbound", c.Unbound.Views))
+	for name, instance := range c.UnboundInstances {
+		if other, found := dbFiles[instance.DbFile]; found {
+			errs = multierr.Append(errs, fmt.Errorf("unbound instance %q uses the same db_file as %q", name, other))
+		}
+		dbFiles[instance.DbFile] = name
+		errs = multierr.Append(errs, validateViewNames(fmt.Sprintf("unbound instance %q", name), instance.Views))
+	}
+
+	for hostname, hostnameConf := range c.Hostnames {
+		if _, found := c.Records[hostname]; !found {
+			errs = multierr.Append(errs, fmt.Errorf("settings for hostname %q defined but no records configured", hostname))
+		}
+		if hostnameConf.Provider != "" {
+			errs = multierr.Append(errs, c.validateProvider(hostname, hostnameConf))
+		}
+		if hostnameConf.Unbound != "" {
+			if _, found := c.UnboundInstances[hostnameConf.Unbound]; !found {
+				errs = multierr.Append(errs, fmt.Errorf("hostname %q uses unknown unbound instance %q", hostname, hostnameConf.Unbound))
+			}
+			if !c.unboundBackend() {
+				errs = multierr.Append(errs, fmt.Errorf("hostname %q can not use an unbound instance if unbound is not the backend", hostname))
+			}
+		}
+		if hostnameConf.Decision != nil && hostnameConf.Decision.Timeout >= c.CheckInterval {
+			errs = multierr.Append(errs, fmt.Errorf("decision timeout %v of hostname %q must be lower than check_interval %v", hostnameConf.Decision.Timeout, hostname, c.CheckInterval))
+		}
+		for _, dependency := range hostnameConf.DependsOn {
+			if _, found := c.Records[dependency]; !found {
+				errs = multierr.Append(errs, fmt.Errorf("hostname %q depends on %q which has no records configured", hostname, dependency))
+			}
+		}
+		for _, ip := range hostnameConf.ShutdownIps {
+			if !c.hasRecordIp(hostname, ip) {
+				errs = multierr.Append(errs, fmt.Errorf("shutdown ip %s of hostname %q is not one of its records", ip, hostname))
+			}
+		}
+	}
+	for _, vip := range c.Hooks.Vip {
+		if _, found := c.Records[vip.Hostname]; !found {
+			errs = multierr.Append(errs, fmt.Errorf("vip hook for hostname %q defined but no records configured", vip.Hostname))
+		}
+		if vip.KeepalivedTrackFile == "" && vip.ExabgpPipe == "" && vip.BirdProtocol == "" {
+			errs = multierr.Append(errs, fmt.Errorf("vip hook for hostname %q needs keepalived_track_file, exabgp_pipe or bird_protocol", vip.Hostname))
+		}
+	}
+	if err := c.dependencyCycle(); err != nil {
 		errs = multierr.Append(errs, err)
 	}
+	if c.Privileges != nil {
+		errs = multierr.Append(errs, c.validatePrivilegedPings())
+	}
 
 	for record, ips := range c.Records {
-		if err := validate.Var(record, "required,hostname"); err != nil {
+		hostname, view, hasView := strings.Cut(record, viewSeparator)
+		if err := validate.Var(hostname, "required,wildcard_hostname"); err != nil {
 			errs = multierr.Append(errs, fmt.Errorf("%q is not a valid hostname", record))
 		}
+		if base, isWildcard := strings.CutPrefix(hostname, "*."); isWildcard {
+			errs = multierr.Append(errs, c.validateWildcard(record, base+record[len(hostname):], ips))
+		}
+		if hasView && (view == "" || strings.ContainsAny(view, " \t\"#"+viewSeparator)) {
+			errs = multierr.Append(errs, fmt.Errorf("%q contains an invalid view name", record))
+		}
 
 		if len(ips) < 2 {
 			errs = multierr.Append(errs, fmt.Errorf("less than two records defined for %q", record))
@@ -50,25 +381,182 @@ func (c *Config) Validate() error {
 				errs = multierr.Append(errs, fmt.Errorf("duplicated prio %d for record %s", ip.Prio, record))
 			}
 
-			_, found = seenIps[ip.IP]
-			seenIps[ip.IP] = struct{}{}
+			if parsed, err := netip.ParseAddr(ip.IP); err == nil && parsed.Unmap().Is4() != (ip.RecordType == "A") {
+				errs = multierr.Append(errs, fmt.Errorf("ip %s does not match record type %s for record %s", ip.IP, ip.RecordType, record))
+			}
+
+			_, found = seenIps[ip.Address()]
+			seenIps[ip.Address()] = struct{}{}
 			if found {
-				errs = multierr.Append(errs, fmt.Errorf("duplicated ip %s for record %s", ip.IP, record))
+				errs = multierr.Append(errs, fmt.Errorf("duplicated ip %s for record %s", ip.Address(), record))
+			}
+
+			if ip.HealthGroup != "" {
+				if _, found := c.HealthGroups[ip.HealthGroup]; !found {
+					errs = multierr.Append(errs, fmt.Errorf("unknown health group %q for %s (%s)", ip.HealthGroup, record, ip.Address()))
+				}
+				continue
+			}
+
+			// errors of invalid templates are only reported once for the template
+			if _, invalid := invalidTemplates[ip.HealthcheckConfig.Template]; !invalid {
+				if err := ip.HealthcheckConfig.Validate(); err != nil {
+					errs = multierr.Append(errs, fmt.Errorf("invalid healthchecker for %s (%s): %w", record, ip.Address(), err))
+				}
+			}
+
+			if timeout := ip.HealthcheckConfig.EffectiveTimeout(); timeout >= c.CheckInterval {
+				errs = multierr.Append(errs, fmt.Errorf("healthchecker timeout %v for %s (%s) must be lower than check_interval %v", timeout, record, ip.Address(), c.CheckInterval))
+			}
+		}
+	}
+
+	return errs
+}
+
+// ProviderOf returns the name of the dns provider the records are managed at. Views are managed at the provider of
+// their hostname if it assigns client subnets to the view.
+func (c *Config) ProviderOf(record string) string {
+	hostname, view, hasView := strings.Cut(record, viewSeparator)
+	if _, found := c.Hostnames[hostname].ClientSubnets[view]; hasView && found {
+		return c.Hostnames[hostname].Provider
+	}
+	return c.Hostnames[record].Provider
+}
+
+// validateWildcard validates the wildcard hostname record, base is the hostname without the wildcard label.
+func (c *Config) validateWildcard(record, base string, records []RecordConfig) error {
+	var errs error
+	// unbound answers the names below the base using the records of the base
+	if _, found := c.Records[base]; found && c.unboundBackend() && c.ProviderOf(record) == "" {
+		errs = multierr.Append(errs, fmt.Errorf("%q can not be managed along with %q by unbound", record, base))
+	}
+	if slices.ContainsFunc(records, func(r RecordConfig) bool { return r.Ptr }) {
+		errs = multierr.Append(errs, fmt.Errorf("ptr records are not supported for wildcard hostname %q", record))
+	}
+	return errs
+}
+
+func (c *Config) validateProvider(hostname string, hostnameConf HostnameConfig) error {
+	var errs error
+	if strings.Contains(hostname, viewSeparator) {
+		errs = multierr.Append(errs, fmt.Errorf("views are not supported by dns providers for %s", hostname))
+	}
+	if _, found := c.DnsProviders[hostnameConf.Provider]; !found {
+		errs = multierr.Append(errs, fmt.Errorf("hostname %q uses unknown dns provider %q", hostname, hostnameConf.Provider))
+	}
+
+	zone := strings.ToLower(strings.TrimSuffix(hostnameConf.Zone, "."))
+	name := strings.ToLower(strings.TrimSuffix(hostname, "."))
+	if name != zone && !strings.HasSuffix(name, "."+zone) {
+		errs = multierr.Append(errs, fmt.Errorf("hostname %q is not part of zone %q", hostname, hostnameConf.Zone))
+	}
+
+	for _, record := range c.Records[hostname] {
+		if record.Ptr {
+			errs = multierr.Append(errs, fmt.Errorf("ptr records are not supported by dns providers for %s (%s)", hostname, record.Address()))
+		}
+	}
+
+	if len(hostnameConf.ClientSubnets) > 0 {
+		if providerType := c.DnsProviders[hostnameConf.Provider].Type; providerType != "powerdns" {
+			errs = multierr.Append(errs, fmt.Errorf("client_subnets are not supported by %s for %s", providerType, hostname))
+		}
+		errs = multierr.Append(errs, validateViewNames(fmt.Sprintf("client_subnets of %s", hostname), hostnameConf.ClientSubnets))
+		for view := range hostnameConf.ClientSubnets {
+			if _, found := c.Records[hostname+viewSeparator+view]; !found {
+				errs = multierr.Append(errs, fmt.Errorf("client subnets for view %q of %s defined but no records configured", view, hostname))
 			}
 		}
 	}
 
+	// dynamic dns services publish a single address per address family, they can neither remove records nor publish
+	// TXT records
+	if providerType := c.DnsProviders[hostnameConf.Provider].Type; providerType == "dyndns2" || providerType == "duckdns" {
+		if c.StateTxt {
+			errs = multierr.Append(errs, fmt.Errorf("state_txt is not supported by %s for %s", providerType, hostname))
+		}
+		if hostnameConf.Strategy == "all_healthy" || hostnameConf.OnAllUnhealthy == "publish_all" || hostnameConf.MinRecords > 1 {
+			errs = multierr.Append(errs, fmt.Errorf("%s publishes a single address per address family for %s", providerType, hostname))
+		}
+		if hostnameConf.OnAllUnhealthy == "remove" {
+			errs = multierr.Append(errs, fmt.Errorf("%s can not remove the records of %s", providerType, hostname))
+		}
+	}
 	return errs
 }
 
+// dependencyCycle returns an error if the hostnames depend on each other in a cycle.
+func (c *Config) dependencyCycle() error {
+	dependsOn := make(map[string][]string, len(c.Hostnames))
+	for hostname, hostnameConf := range c.Hostnames {
+		dependsOn[hostname] = hostnameConf.DependsOn
+	}
+	return DependencyCycle(dependsOn)
+}
+
+// DependencyCycle returns an error naming the first cycle found in the dependencies of the hostnames.
+func DependencyCycle(dependsOn map[string][]string) error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	states := make(map[string]int, len(dependsOn))
+
+	var visit func(hostname string, path []string) error
+	visit = func(hostname string, path []string) error {
+		switch states[hostname] {
+		case visiting:
+			return fmt.Errorf("dependency cycle detected: %s", strings.Join(append(path, hostname), " -> "))
+		case done:
+			return nil
+		}
+
+		states[hostname] = visiting
+		for _, dependency := range dependsOn[hostname] {
+			if err := visit(dependency, append(path, hostname)); err != nil {
+				return err
+			}
+		}
+		states[hostname] = done
+		return nil
+	}
+
+	for _, hostname := range slices.Sorted(maps.Keys(dependsOn)) {
+		if err := visit(hostname, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type RecordConfig struct {
-	IP         string `json:"ip" yaml:"ip" validate:"required,ip"`
+	IP string `json:"ip" yaml:"ip" validate:"required_without=Target,excluded_with=Target,omitempty,zoned_ip"`
+	// Target is a hostname the ip is resolved from using Resolver instead of a static ip, e.g. for backends with
+	// dynamic addresses. It is periodically re-resolved, see Config.ResolveInterval.
+	Target string `json:"target" yaml:"target" validate:"omitempty,hostname_rfc1123"`
+	// Resolver is the DNS server used to resolve Target, e.g. "9.9.9.9:53".
+	Resolver   string `json:"resolver" yaml:"resolver" validate:"required_with=Target,omitempty,hostname_port"`
 	RecordType string `json:"type" yaml:"type" validate:"required,oneof=A AAAA"`
 	Prio       int    `json:"prio" yaml:"prio" validate:"required,gte=0,lt=255"`
 	Ttl        int    `json:"ttl" yaml:"ttl" validate:"gte=1,lte=3600"`
+	// Ptr also maintains the reverse record pointing to the hostname while this record is published.
+	Ptr bool `json:"ptr" yaml:"ptr"`
 
-	HealthcheckConfig map[string]any `json:"healthchecker" yaml:"healthchecker" validate:"required"`
-	StatusConfig      StatusConfig   `json:"status" yaml:"status"`
+	HealthcheckConfig HealthcheckConfig `json:"healthchecker" yaml:"healthchecker" validate:"-"`
+	StatusConfig      StatusConfig      `json:"status" yaml:"status"`
+	Backoff           *BackoffConfig    `json:"backoff" yaml:"backoff"`
+	// HistorySize is the amount of check results kept in memory for the history API, zero disables the history.
+	HistorySize int `json:"history_size" yaml:"history_size" validate:"gte=0"`
+	// HealthGroup is the name of the health group whose check decides about the health of the record, the
+	// healthchecker of the record is not used.
+	HealthGroup string `json:"health_group" yaml:"health_group"`
+	// ValidUntil is the time the record is removed and not checked anymore, e.g. "2025-06-01T18:00:00Z" for a
+	// temporary failover target spun up during an incident.
+	ValidUntil *time.Time `json:"valid_until" yaml:"valid_until"`
+	// ValidFor removes the record once it has been managed for the given duration. The duration starts over when
+	// dns-ha restarts or the config of the record changes, use ValidUntil to remove the record at a fixed time.
+	ValidFor time.Duration `json:"valid_for" yaml:"valid_for" validate:"excluded_with=ValidUntil,gte=0"`
 }
 
 func (conf *RecordConfig) UnmarshalYAML(node *yaml.Node) error {
@@ -81,7 +569,10 @@ func (conf *RecordConfig) UnmarshalYAML(node *yaml.Node) error {
 			UnhealthyStreak:        5,
 			InitialHealthyStreak:   2,
 			InitialUnhealthyStreak: 1,
+			OnError:                "unhealthy",
+			ErrorStreak:            3,
 		},
+		HistorySize: defaultHistorySize,
 	}
 
 	// Unmarshal the yaml data into the temporary struct
@@ -94,34 +585,663 @@ func (conf *RecordConfig) UnmarshalYAML(node *yaml.Node) error {
 	return nil
 }
 
+// HealthGroupConfig configures a healthcheck that is shared by the records of several hostnames, e.g. a check of a
+// site that decides about the health of all records pointing to the site.
+type HealthGroupConfig struct {
+	// IP is the address that is checked.
+	IP string `json:"ip" yaml:"ip" validate:"required,zoned_ip"`
+	// Host is the hostname http checks send to the ip, defaults to the ip.
+	Host string `json:"host" yaml:"host" validate:"omitempty,hostname_rfc1123"`
+	// Interval is the maximum age of a check result that is shared, defaults to half of the check_interval.
+	Interval          time.Duration     `json:"interval" yaml:"interval" validate:"omitempty,gte=1s"`
+	HealthcheckConfig HealthcheckConfig `json:"healthchecker" yaml:"healthchecker" validate:"-"`
+}
+
+// Address returns the ip of the record or, if the ip has not been resolved yet, its target hostname.
+func (conf RecordConfig) Address() string {
+	return cmp.Or(conf.IP, conf.Target)
+}
+
 type StatusConfig struct {
 	HealthyStreak          int `yaml:"healthy" validate:"gte=1"`
 	UnhealthyStreak        int `yaml:"unhealthy" validate:"gte=1"`
 	InitialHealthyStreak   int `yaml:"initial_healthy" validate:"gte=1"`
 	InitialUnhealthyStreak int `yaml:"initial_unhealthy" validate:"gte=1"`
+	// HealthyAfter additionally requires a record to succeed for the given duration before it's considered healthy.
+	HealthyAfter time.Duration `yaml:"healthy_after" validate:"gte=0"`
+	// UnhealthyAfter additionally requires a record to fail for the given duration before it's considered unhealthy.
+	UnhealthyAfter time.Duration `yaml:"unhealthy_after" validate:"gte=0"`
+	// OnError defines how check errors are treated: "unhealthy" counts them as unhealthy results, "freeze" keeps the
+	// current state and moves the record into the error state after ErrorStreak consecutive errors.
+	OnError     string `yaml:"on_error" validate:"omitempty,oneof=unhealthy freeze"`
+	ErrorStreak int    `yaml:"error_streak" validate:"gte=0"`
+}
+
+// GuardConfig defines the local connectivity check that freezes all records while it fails, because every record
+// looks down from a disconnected host.
+type GuardConfig struct {
+	// Ip is the reference address that is pinged, the IPv4 default gateway is used if it's empty. It's required if the
+	// default gateway can not be determined at startup, e.g. on IPv6-only hosts or on other platforms than Linux.
+	Ip         string        `json:"ip" yaml:"ip" validate:"omitempty,zoned_ip"`
+	Timeout    time.Duration `json:"timeout" yaml:"timeout" validate:"gte=0"`
+	Privileged *bool         `json:"privileged" yaml:"privileged"`
+}
+
+// BackoffConfig defines how the probe frequency of a record is reduced after it has been unhealthy for a long time.
+type BackoffConfig struct {
+	// After is the time a record needs to be unhealthy before its checks are backed off.
+	After time.Duration `json:"after" yaml:"after" validate:"gte=0"`
+	// Initial is the first delay between two checks once backoff kicks in.
+	Initial time.Duration `json:"initial" yaml:"initial" validate:"required,gt=0"`
+	// Max caps the delay between two checks.
+	Max time.Duration `json:"max" yaml:"max" validate:"required,gtefield=Initial"`
+	// Multiplier is applied to the delay after each unsuccessful check, defaults to 2.
+	Multiplier float64 `json:"multiplier" yaml:"multiplier" validate:"omitempty,gte=1"`
+}
+
+// HostnameConfig holds settings that apply to all records of a hostname.
+type HostnameConfig struct {
+	// OnAllUnhealthy defines what is published while no record is healthy: "keep_last" (default), "publish_all",
+	// "fallback" to publish FallbackIp or "remove" to remove all records of the hostname.
+	OnAllUnhealthy string `json:"on_all_unhealthy" yaml:"on_all_unhealthy" validate:"omitempty,oneof=keep_last publish_all fallback remove"`
+	FallbackIp     string `json:"fallback_ip" yaml:"fallback_ip" validate:"required_if=OnAllUnhealthy fallback,omitempty,ip"`
+	// KeepAddressFamilies keeps the published records of an address family that are not selected anymore until the
+	// other family has a healthy record, e.g. A records are only removed once an AAAA record is healthy. This way
+	// dual-stack hostnames never lose their last reachable family, even if on_all_unhealthy removes the records.
+	KeepAddressFamilies bool `json:"keep_address_families" yaml:"keep_address_families"`
+	// DependsOn lists hostnames whose records are updated first. Records of this hostname are only changed after the
+	// changes of all of its dependencies could be applied.
+	DependsOn []string `json:"depends_on" yaml:"depends_on" validate:"dive,wildcard_hostname"`
+	// CheckInterval overrides the interval between the checks of the hostname's records, each hostname is checked
+	// independently of the others.
+	CheckInterval time.Duration `json:"check_interval" yaml:"check_interval" validate:"omitempty,gte=1s"`
+	// Strategy selects the published records among the healthy records: "priority" (default) publishes the record
+	// with the highest priority, "weighted" spreads hostnames across the records using the priority as weight,
+	// "latency" publishes the record with the lowest check latency, each per address family, and "all_healthy"
+	// publishes all healthy records.
+	Strategy string `json:"strategy" yaml:"strategy" validate:"omitempty,oneof=priority weighted latency all_healthy"`
+	// MinRecords is the minimum amount of published records while at least one record is healthy, e.g. with the
+	// "all_healthy" strategy. Missing records are filled up with the best remaining records, even if they are unhealthy.
+	MinRecords int `json:"min_records" yaml:"min_records" validate:"gte=0"`
+	// MaxRecords caps the amount of published records to keep responses small, the records with the highest priority
+	// are published.
+	MaxRecords int `json:"max_records" yaml:"max_records" validate:"omitempty,gtefield=MinRecords"`
+	// GracePeriod is the duration a record that took over after a failover stays published even if its checks fail,
+	// to give the service time to warm caches or catch up on replication after taking traffic.
+	GracePeriod time.Duration `json:"grace_period" yaml:"grace_period" validate:"omitempty,gte=1s"`
+	// EscalateAfter is the duration the hostname may have no healthy records before the outage hooks are run and
+	// an error is logged. Outages are not escalated if it's not set.
+	EscalateAfter time.Duration `json:"escalate_after" yaml:"escalate_after" validate:"omitempty,gte=1s"`
+	// Blackouts are recurring windows during which the records keep being checked, but the published records are not
+	// changed, e.g. nightly backups that always trip the checks.
+	Blackouts []BlackoutConfig `json:"blackouts" yaml:"blackouts" validate:"dive"`
+	// ShutdownIps are the records that are safe to publish when dns-ha stops with on_shutdown "publish_all". If it's
+	// not set, the records that are not unhealthy at the time are published.
+	ShutdownIps []string `json:"shutdown_ips" yaml:"shutdown_ips" validate:"dive,ip"`
+	// Unbound is the name of the unbound instance the records are managed at instead of the default instance.
+	Unbound string `json:"unbound" yaml:"unbound" validate:"excluded_with=Provider"`
+	// Provider is the name of the DNS provider the records are managed at, the records are part of the given zone.
+	Provider string `json:"provider" yaml:"provider"`
+	Zone     string `json:"zone" yaml:"zone" validate:"required_with=Provider,omitempty,hostname_rfc1123"`
+	// ClientSubnets assigns client subnets to the views of the hostname at providers that support LUA records, e.g.
+	// PowerDNS. Clients within the subnets of a view receive the records of "hostname@view", all other clients the
+	// records of the hostname. PowerDNS matches the EDNS Client Subnet of queries if edns-subnet-processing is enabled.
+	ClientSubnets map[string][]string `json:"client_subnets" yaml:"client_subnets" validate:"excluded_without=Provider,dive,dive,cidr"`
+	// Decision delegates the selection of the published records to an external decision engine, so the failover
+	// policy can be managed centrally.
+	Decision *DecisionConfig `json:"decision" yaml:"decision" validate:"excluded_with=Strategy MinRecords MaxRecords KeepAddressFamilies"`
+}
+
+// hasRecordIp returns true if the hostname has a record with the given ip, the zones of the records are ignored.
+func (c *Config) hasRecordIp(hostname, ip string) bool {
+	want, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(c.Records[hostname], func(record RecordConfig) bool {
+		addr, err := netip.ParseAddr(record.IP)
+		return err == nil && addr.WithZone("").Unmap() == want.Unmap()
+	})
+}
+
+// DecisionConfig defines the endpoint that selects the published records of a hostname.
+type DecisionConfig struct {
+	// Url receives the state and the latest check result of each record of the hostname as POST request and responds
+	// with the addresses to publish, e.g. {"publish": ["192.0.2.1"]}. An empty list is treated like a hostname without
+	// healthy records.
+	Url string `json:"url" yaml:"url" validate:"required,http_url"`
+	// Headers are sent along with each request, e.g. to authenticate.
+	Headers map[string]string `json:"headers" yaml:"headers" validate:"dive,keys,required,endkeys"`
+	// Timeout bounds each request, it defaults to 5s and must be lower than check_interval. Records of other
+	// hostnames are published after the request completed.
+	Timeout time.Duration `json:"timeout" yaml:"timeout" validate:"gte=0"`
+	// OnError defines the selection while the endpoint fails: "keep_last" (default) keeps the published records,
+	// "strategy" selects the records by priority.
+	OnError string `json:"on_error" yaml:"on_error" validate:"omitempty,oneof=keep_last strategy"`
+}
+
+// BlackoutConfig defines a recurring blackout window of a hostname.
+type BlackoutConfig struct {
+	// Schedule is a cron expression "minute hour day-of-month month day-of-week" that defines when the window starts,
+	// e.g. "30 1 * * *" for 01:30 every night.
+	Schedule string `json:"schedule" yaml:"schedule" validate:"required,cron"`
+	// Duration is the length of the window.
+	Duration time.Duration `json:"duration" yaml:"duration" validate:"required,gte=1m,lte=168h"`
+	// Timezone the schedule is evaluated in, e.g. "Europe/Berlin", defaults to the local timezone.
+	Timezone string `json:"timezone" yaml:"timezone" validate:"omitempty,timezone"`
+}
+
+// DnsProviderConfig configures the credentials of a hosted DNS provider.
+type DnsProviderConfig struct {
+	Type string `json:"type" yaml:"type" validate:"required,oneof=hetzner digitalocean gandi ovh desec dyndns2 duckdns powerdns"`
+	// Token authenticates at Hetzner, DigitalOcean, Gandi, deSEC and DuckDNS, it is the password for dyndns2 and the
+	// API key for PowerDNS.
+	Token string `json:"token" yaml:"token" validate:"required_unless=Type ovh"`
+	// BaseUrl overrides the url of the provider's API, e.g. the OVHcloud region. It is the update url for dyndns2,
+	// e.g. "https://update.dedyn.io/nic/update", and the server url for PowerDNS, e.g.
+	// "http://127.0.0.1:8081/api/v1/servers/localhost".
+	BaseUrl string `json:"base_url" yaml:"base_url" validate:"required_if=Type dyndns2,required_if=Type powerdns,omitempty,http_url"`
+	// Username authenticates at dyndns2 services.
+	Username          string `json:"username" yaml:"username" validate:"required_if=Type dyndns2"`
+	ApplicationKey    string `json:"application_key" yaml:"application_key" validate:"required_if=Type ovh"`
+	ApplicationSecret string `json:"application_secret" yaml:"application_secret" validate:"required_if=Type ovh"`
+	ConsumerKey       string `json:"consumer_key" yaml:"consumer_key" validate:"required_if=Type ovh"`
+}
+
+// ServiceConfig controls how the DNS service is restarted after records have been changed.
+type ServiceConfig struct {
+	// RestartCoalesce delays restarts, so changes across several cycles only lead to a single restart.
+	RestartCoalesce time.Duration `json:"restart_coalesce" yaml:"restart_coalesce" validate:"gte=0"`
+	// RestartMinInterval allows at most one restart per interval.
+	RestartMinInterval time.Duration `json:"restart_min_interval" yaml:"restart_min_interval" validate:"gte=0"`
+	// RestartPolicy is either "escalate" to restart the service if reloading fails or "never" to only ever reload it.
+	RestartPolicy string `json:"restart_policy" yaml:"restart_policy" validate:"omitempty,oneof=escalate never"`
+	// ReloadFailuresBeforeRestart is the amount of consecutive reload failures before escalating to a restart.
+	ReloadFailuresBeforeRestart int `json:"reload_failures_before_restart" yaml:"reload_failures_before_restart" validate:"gte=0"`
+	// FlushCacheCommand purges changed names from the resolver cache after the service picked up the changes, e.g.
+	// ["unbound-control", "flush"]. The name is appended as last argument.
+	FlushCacheCommand []string `json:"flush_cache_command" yaml:"flush_cache_command"`
+	// Timeout bounds each update of the DNS backend and each reload, restart or cache flush of the service. It
+	// defaults to half of check_interval and must be lower than check_interval.
+	Timeout time.Duration `json:"timeout" yaml:"timeout" validate:"gte=0"`
+	// Retry retries failed updates of the DNS backend and reloads or restarts of the service. All attempts along with
+	// their delays must fit into check_interval, as the retries hold up the processing of check results.
+	Retry *RetryConfig `json:"retry" yaml:"retry"`
+}
+
+// RetryConfig defines how often and how fast failed operations are retried.
+type RetryConfig struct {
+	// Attempts is the total amount of attempts, including the first one.
+	Attempts int `json:"attempts" yaml:"attempts" validate:"required,gte=1"`
+	// Backoff is the delay before the first retry, it doubles with every further retry.
+	Backoff time.Duration `json:"backoff" yaml:"backoff" validate:"gte=0"`
+	// MaxBackoff caps the delay between two attempts, the delay keeps doubling if it's not set.
+	MaxBackoff time.Duration `json:"max_backoff" yaml:"max_backoff" validate:"omitempty,gtefield=Backoff"`
+	// Jitter adds a random delay in [0, jitter) to each retry.
+	Jitter time.Duration `json:"jitter" yaml:"jitter" validate:"gte=0"`
+}
+
+// maxRetryDelay stops the delay from doubling before it overflows.
+const maxRetryDelay = time.Duration(math.MaxInt64 / 4)
+
+// Delay returns the delay before the given retry without the jitter, retries are counted from 1.
+func (c RetryConfig) Delay(retry int) time.Duration {
+	delay := c.Backoff
+	for range retry - 1 {
+		if delay >= maxRetryDelay/2 || (c.MaxBackoff > 0 && delay >= c.MaxBackoff) {
+			break
+		}
+		delay *= 2
+	}
+	if c.MaxBackoff > 0 {
+		delay = min(delay, c.MaxBackoff)
+	}
+	return delay
+}
+
+// Budget returns the worst-case duration of an operation that is retried using the policy, if each attempt takes up
+// to the given timeout.
+func (c RetryConfig) Budget(timeout time.Duration) time.Duration {
+	budget := time.Duration(max(c.Attempts, 1)) * timeout
+	for retry := 1; retry < c.Attempts && budget < maxRetryDelay; retry++ {
+		budget += c.Delay(retry) + c.Jitter
+	}
+	return budget
+}
+
+// HooksConfig holds shell commands that are executed when records are changed or the service is restarted.
+type HooksConfig struct {
+	PreUpdate []string `json:"pre_update" yaml:"pre_update" validate:"dive,required"`
+	// PostUpdate is run once the update announced by PreUpdate has been applied, and if it failed, with DNS_HA_EVENT
+	// "update_failed" and the error in DNS_HA_ERROR.
+	PostUpdate  []string `json:"post_update" yaml:"post_update" validate:"dive,required"`
+	PostRestart []string `json:"post_restart" yaml:"post_restart" validate:"dive,required"`
+	// Outage is run once a hostname has had no healthy records for longer than its escalate_after duration and again
+	// once it recovers, DNS_HA_EVENT is "outage" or "outage_resolved".
+	Outage  []string      `json:"outage" yaml:"outage" validate:"dive,required"`
+	Timeout time.Duration `json:"timeout" yaml:"timeout" validate:"gte=0"`
+	// Vip keeps VIP failovers and anycast announcements of this node consistent with the DNS failover.
+	Vip []VipConfig `json:"vip" yaml:"vip" validate:"dive"`
+}
+
+// VipConfig ties the VIP or route announcement of this node to the DNS failover of a hostname. The node is active for
+// the hostname while its address is published.
+type VipConfig struct {
+	Hostname string `json:"hostname" yaml:"hostname" validate:"required"`
+	// Address is the address of this node in the records of the hostname.
+	Address string `json:"address" yaml:"address" validate:"required,ip"`
+	// KeepalivedTrackFile is written with 0 while the node is active and 1 otherwise, to be used as track_file of a
+	// keepalived vrrp_instance.
+	KeepalivedTrackFile string `json:"keepalived_track_file" yaml:"keepalived_track_file" validate:"omitempty,filepath"`
+	// ExabgpPipe is the named pipe of the ExaBGP API, Route is announced while the node is active.
+	ExabgpPipe string `json:"exabgp_pipe" yaml:"exabgp_pipe" validate:"omitempty,filepath"`
+	// Route is the prefix announced via ExaBGP, defaults to the host route of Address.
+	Route string `json:"route" yaml:"route" validate:"omitempty,cidr"`
+	// BirdProtocol is the BIRD protocol that is enabled while the node is active.
+	BirdProtocol string `json:"bird_protocol" yaml:"bird_protocol"`
+	BirdcBinary  string `json:"birdc_binary" yaml:"birdc_binary"`
 }
 
 type UnboundConfig struct {
-	DbFile      string `json:"db_file" yaml:"db_file" validate:"filepath"`
+	DbFile string `json:"db_file" yaml:"db_file" validate:"filepath"`
+	// ServiceName is the systemd unit that is reloaded or restarted after the records changed, defaults to "unbound".
 	ServiceName string `json:"service_name" yaml:"service_name"`
 	CreateFile  bool   `json:"create_file" yaml:"create_file"`
+	// Watch re-applies the managed records immediately when the db file is modified externally.
+	Watch bool `json:"watch" yaml:"watch"`
+	// Backups is the amount of timestamped backups of the db file to keep, zero disables backups.
+	Backups   int             `json:"backups" yaml:"backups" validate:"gte=0"`
+	Checkconf CheckconfConfig `json:"checkconf" yaml:"checkconf"`
+	File      FileConfig      `json:"file" yaml:"file"`
+	// ReloadCommand optionally reloads unbound while keeping its resolver cache, e.g. ["unbound-control", "-c",
+	// "/etc/unbound/unbound.conf", "reload_keep_cache"], which requires unbound 1.17 or later. Unbound instances need to
+	// address their own config via -c. The systemd service is reloaded instead if the command fails.
+	ReloadCommand []string `json:"reload_command" yaml:"reload_command"`
+	// Views assigns client netblocks to views, e.g. {"vpn": ["10.8.0.0/24"]}, so clients of a netblock are answered
+	// with the records published for "hostname@view". Clients still need to be allowed using access-control.
+	Views map[string][]string `json:"views" yaml:"views" validate:"dive,dive,cidr"`
+}
+
+func defaultUnboundConfig() UnboundConfig {
+	return UnboundConfig{
+		ServiceName: defaultUnboundServiceName,
+		CreateFile:  true,
+		Watch:       true,
+		Backups:     defaultUnboundBackups,
+	}
+}
+
+// validateViewNames returns an error for each view name that can not be used in the unbound config.
+func validateViewNames(unbound string, views map[string][]string) error {
+	var errs error
+	for view := range views {
+		if view == "" || strings.ContainsAny(view, " \t\"#"+viewSeparator) {
+			errs = multierr.Append(errs, fmt.Errorf("%s contains an invalid view name %q", unbound, view))
+		}
+	}
+	return errs
+}
+
+func (conf *UnboundConfig) UnmarshalYAML(node *yaml.Node) error {
+	type Alias UnboundConfig
+
+	tmp := Alias(defaultUnboundConfig())
+	if err := node.Decode(&tmp); err != nil {
+		return err
+	}
+
+	*conf = UnboundConfig(tmp)
+	return nil
+}
+
+// MetricsTlsConfig configures TLS for the metrics server. Rotated certificates are picked up automatically.
+type MetricsTlsConfig struct {
+	CertFile string `json:"cert_file" yaml:"cert_file" validate:"required,filepath"`
+	KeyFile  string `json:"key_file" yaml:"key_file" validate:"required,filepath"`
+	// ClientCaFile enables mTLS, clients need to present a certificate signed by one of the CAs.
+	ClientCaFile string `json:"client_ca_file" yaml:"client_ca_file" validate:"omitempty,filepath"`
+	// AllowedClientCns restricts mTLS to client certificates carrying one of the common names.
+	AllowedClientCns []string `json:"allowed_client_cns" yaml:"allowed_client_cns" validate:"excluded_without=ClientCaFile,dive,required"`
+}
+
+// MetricsPushConfig configures pushing the metrics to a Pushgateway and/or a Prometheus remote-write endpoint. The
+// metrics are pushed a last time during shutdown.
+type MetricsPushConfig struct {
+	PushgatewayUrl string `json:"pushgateway_url" yaml:"pushgateway_url" validate:"required_without=RemoteWriteUrl,omitempty,http_url"`
+	RemoteWriteUrl string `json:"remote_write_url" yaml:"remote_write_url" validate:"required_without=PushgatewayUrl,omitempty,http_url"`
+	// Job is used as job label, it defaults to dns_ha.
+	Job       string           `json:"job" yaml:"job"`
+	Interval  time.Duration    `json:"interval" yaml:"interval" validate:"omitempty,gte=1s"`
+	BasicAuth *BasicAuthConfig `json:"basic_auth" yaml:"basic_auth"`
+}
+
+type MetricsSinkConfig struct {
+	Type string `json:"type" yaml:"type" validate:"required,oneof=graphite influxdb statsd"`
+	// Address is the host and port of the graphite or statsd server.
+	Address string `json:"address" yaml:"address" validate:"required_unless=Type influxdb,omitempty,hostname_port"`
+	// Url is the write endpoint of influxdb including the org and bucket parameters.
+	Url   string `json:"url" yaml:"url" validate:"required_if=Type influxdb,omitempty,http_url"`
+	Token string `json:"token" yaml:"token"`
+	// Prefix is prepended to the metric names sent to graphite and statsd.
+	Prefix   string        `json:"prefix" yaml:"prefix"`
+	Interval time.Duration `json:"interval" yaml:"interval" validate:"omitempty,gte=1s"`
+}
+
+type BasicAuthConfig struct {
+	Username string `json:"username" yaml:"username" validate:"required"`
+	Password string `json:"password" yaml:"password" validate:"required"`
+}
+
+// PrivilegesConfig defines the user and group the process switches to. The user needs write access to the db file
+// and must be allowed to reload or restart the DNS service, e.g. via polkit or sudo. ICMP checks need the group to be
+// within net.ipv4.ping_group_range, privileged ICMP checks are not supported as CAP_NET_RAW is dropped as well. The
+// metrics server is started afterwards, so it can not listen on privileged ports.
+type PrivilegesConfig struct {
+	User string `json:"user" yaml:"user" validate:"required"`
+	// Group defaults to the primary group of the user.
+	Group string `json:"group" yaml:"group"`
+}
+
+// KubernetesConfig configures how ManagedDnsRecord resources are watched. Empty values default to the in-cluster
+// configuration.
+type KubernetesConfig struct {
+	ApiServer string `json:"api_server" yaml:"api_server" validate:"omitempty,url"`
+	TokenFile string `json:"token_file" yaml:"token_file" validate:"omitempty,filepath"`
+	CaFile    string `json:"ca_file" yaml:"ca_file" validate:"omitempty,filepath"`
+	// Namespace restricts the watched resources to a single namespace, all namespaces are watched if empty.
+	Namespace     string `json:"namespace" yaml:"namespace"`
+	LabelSelector string `json:"label_selector" yaml:"label_selector"`
+}
+
+// BindConfig configures managing the records in a zone file of an authoritative BIND server. The zone file is
+// validated using named-checkzone and the zone is reloaded using rndc.
+type BindConfig struct {
+	ZoneFile string `json:"zone_file" yaml:"zone_file" validate:"required,filepath"`
+	Zone     string `json:"zone" yaml:"zone" validate:"required,hostname_rfc1123"`
+	// Backups is the amount of timestamped backups of the zone file to keep, zero disables backups.
+	Backups         int      `json:"backups" yaml:"backups" validate:"gte=0"`
+	CheckzoneBinary string   `json:"checkzone_binary" yaml:"checkzone_binary"`
+	CheckzoneArgs   []string `json:"checkzone_args" yaml:"checkzone_args"`
+	RndcBinary      string   `json:"rndc_binary" yaml:"rndc_binary"`
+	// RndcArgs are passed to rndc before the command, e.g. to select the server or key file.
+	RndcArgs []string `json:"rndc_args" yaml:"rndc_args"`
+}
+
+func (conf *BindConfig) UnmarshalYAML(node *yaml.Node) error {
+	type Alias BindConfig
+
+	tmp := &Alias{
+		Backups: defaultBindBackups,
+	}
+	if err := node.Decode(tmp); err != nil {
+		return err
+	}
+
+	*conf = BindConfig(*tmp)
+	return nil
+}
+
+// ZoneServerConfig configures managing the records in the zone file of an authoritative NSD or Knot DNS server. Knot
+// must be configured to load changes of the zone file, e.g. using "zonefile-load: difference".
+type ZoneServerConfig struct {
+	ZoneFile string `json:"zone_file" yaml:"zone_file" validate:"required,filepath"`
+	Zone     string `json:"zone" yaml:"zone" validate:"required,hostname_rfc1123"`
+	// Backups is the amount of timestamped backups of the zone file to keep, zero disables backups.
+	Backups int `json:"backups" yaml:"backups" validate:"gte=0"`
+	// CheckzoneBinary defaults to nsd-checkzone for NSD and kzonecheck for Knot.
+	CheckzoneBinary string   `json:"checkzone_binary" yaml:"checkzone_binary"`
+	CheckzoneArgs   []string `json:"checkzone_args" yaml:"checkzone_args"`
+	// ControlBinary defaults to nsd-control for NSD and knotc for Knot.
+	ControlBinary string `json:"control_binary" yaml:"control_binary"`
+	// ControlArgs are passed to the control binary before the command, e.g. to select the config file.
+	ControlArgs []string `json:"control_args" yaml:"control_args"`
+}
+
+func (conf *ZoneServerConfig) UnmarshalYAML(node *yaml.Node) error {
+	type Alias ZoneServerConfig
+
+	tmp := &Alias{
+		Backups: defaultZoneServerBackups,
+	}
+	if err := node.Decode(tmp); err != nil {
+		return err
+	}
+
+	*conf = ZoneServerConfig(*tmp)
+	return nil
+}
+
+// ResolvedConfig configures managing the records in the hosts file, which systemd-resolved answers from before
+// querying its upstream servers. This avoids installing a local DNS server on hosts that only run systemd-resolved.
+// The records are written to the hosts file only, resolvectl is used to flush the cache.
+type ResolvedConfig struct {
+	// HostsFile must be /etc/hosts, the only hosts file systemd-resolved reads. It's configurable for symmetry with
+	// the other hosts file backends.
+	HostsFile string `json:"hosts_file" yaml:"hosts_file" validate:"required,filepath"`
+	// Backups is the amount of timestamped backups of the hosts file to keep, zero disables backups.
+	Backups          int    `json:"backups" yaml:"backups" validate:"gte=0"`
+	ResolvectlBinary string `json:"resolvectl_binary" yaml:"resolvectl_binary"`
+}
+
+func (conf *ResolvedConfig) UnmarshalYAML(node *yaml.Node) error {
+	type Alias ResolvedConfig
+
+	tmp := &Alias{
+		HostsFile: defaultHostsFile,
+		Backups:   defaultResolvedBackups,
+	}
+	if err := node.Decode(tmp); err != nil {
+		return err
+	}
+
+	*conf = ResolvedConfig(*tmp)
+	return nil
+}
+
+// CoreDnsConfig configures managing the records in the hosts file served by the hosts plugin of CoreDNS. CoreDNS
+// reloads the file on its own, so there is no service to control.
+type CoreDnsConfig struct {
+	HostsFile string `json:"hosts_file" yaml:"hosts_file" validate:"required,filepath"`
+	// Backups is the amount of timestamped backups of the hosts file to keep, zero disables backups.
+	Backups int `json:"backups" yaml:"backups" validate:"gte=0"`
+}
+
+func (conf *CoreDnsConfig) UnmarshalYAML(node *yaml.Node) error {
+	type Alias CoreDnsConfig
+
+	tmp := &Alias{
+		Backups: defaultCoreDnsBackups,
+	}
+	if err := node.Decode(tmp); err != nil {
+		return err
+	}
+
+	*conf = CoreDnsConfig(*tmp)
+	return nil
+}
+
+// MsDnsConfig configures managing the records of a zone hosted by Microsoft DNS Server using the DnsServer PowerShell
+// module. The service is controlled using the Windows service control manager.
+type MsDnsConfig struct {
+	Zone string `json:"zone" yaml:"zone" validate:"required,hostname_rfc1123"`
+	// ComputerName is the DNS server to manage, defaults to the local server.
+	ComputerName     string `json:"computer_name" yaml:"computer_name" validate:"omitempty,hostname_rfc1123"`
+	PowershellBinary string `json:"powershell_binary" yaml:"powershell_binary"`
+	ServiceName      string `json:"service_name" yaml:"service_name" validate:"required"`
+}
+
+func (conf *MsDnsConfig) UnmarshalYAML(node *yaml.Node) error {
+	type Alias MsDnsConfig
+
+	tmp := &Alias{
+		ServiceName: defaultMsDnsServiceName,
+	}
+	if err := node.Decode(tmp); err != nil {
+		return err
+	}
+
+	*conf = MsDnsConfig(*tmp)
+	return nil
+}
+
+// validateZoneRecords ensures all hostnames that are not managed at a dns provider are part of the zone managed by the
+// backend. PTR records would require a reverse zone.
+func (c *Config) validateZoneRecords(backend, managedZone string) error {
+	zone := strings.ToLower(strings.TrimSuffix(managedZone, "."))
+
+	var errs error
+	for hostname, records := range c.Records {
+		if c.ProviderOf(hostname) != "" {
+			continue
+		}
+		if strings.Contains(hostname, viewSeparator) {
+			errs = multierr.Append(errs, fmt.Errorf("views are not supported by %s for %s", backend, hostname))
+			continue
+		}
+
+		name := strings.ToLower(strings.TrimSuffix(hostname, "."))
+		if name != zone && !strings.HasSuffix(name, "."+zone) {
+			errs = multierr.Append(errs, fmt.Errorf("hostname %q is not part of zone %q", hostname, managedZone))
+		}
+		for _, record := range records {
+			if record.Ptr {
+				errs = multierr.Append(errs, fmt.Errorf("ptr records are not supported by %s for %s (%s)", backend, hostname, record.Address()))
+			}
+		}
+	}
+	return errs
+}
+
+// validateHostsRecords validates that the records of all hostnames that are not assigned to a dns provider can be
+// expressed in the hosts file.
+func (c *Config) validateHostsRecords(backend string) error {
+	var errs error
+	if c.StateTxt {
+		errs = multierr.Append(errs, fmt.Errorf("state_txt is not supported by %s", backend))
+	}
+	for hostname, records := range c.Records {
+		if c.ProviderOf(hostname) != "" {
+			continue
+		}
+		if strings.Contains(hostname, viewSeparator) {
+			errs = multierr.Append(errs, fmt.Errorf("views are not supported by %s for %s", backend, hostname))
+			continue
+		}
+		if strings.HasPrefix(hostname, "*.") {
+			errs = multierr.Append(errs, fmt.Errorf("wildcard hostnames are not supported by %s for %s", backend, hostname))
+		}
+		for _, record := range records {
+			if record.Ptr {
+				errs = multierr.Append(errs, fmt.Errorf("ptr records are not supported by %s for %s (%s), they are derived from the hosts file", backend, hostname, record.Address()))
+			}
+		}
+	}
+	return errs
 }
 
+// CheckconfConfig configures how the written unbound config is validated.
+type CheckconfConfig struct {
+	Binary string   `json:"binary" yaml:"binary"`
+	Args   []string `json:"args" yaml:"args"`
+	// Target is either "db_file" to validate the written db file or "system" to validate unbound's default config.
+	Target string `json:"target" yaml:"target" validate:"omitempty,oneof=db_file system"`
+}
+
+// FileConfig configures the attributes of a written file.
+type FileConfig struct {
+	// Mode is the octal file mode, e.g. "0640".
+	Mode string `json:"mode" yaml:"mode" validate:"omitempty,file_mode"`
+	// Owner and Group are names or numeric ids, changing them usually requires running as root.
+	Owner string `json:"owner" yaml:"owner"`
+	Group string `json:"group" yaml:"group"`
+	// Restorecon restores the SELinux context of the file after writing it, e.g. for unbound refusing files with the
+	// wrong context.
+	Restorecon bool `json:"restorecon" yaml:"restorecon"`
+}
+
+// ParseFileMode parses an octal file mode containing permission bits only.
+func ParseFileMode(mode string) (os.FileMode, error) {
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file mode %q: %w", mode, err)
+	}
+	if parsed > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("invalid file mode %q: only permission bits are supported", mode)
+	}
+	return os.FileMode(parsed), nil
+}
+
+// Read reads the config from the location, which is either a local path, see ReadFromFile, or a remote location
+// supported by remote.Fetch.
+func Read(location string) (*Config, error) {
+	if !remote.IsRemote(location) {
+		return ReadFromFile(location)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+	defer cancel()
+
+	data, err := remote.Fetch(ctx, location)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch config: %w", err)
+	}
+
+	// the format is detected by the extension of the location, ignoring any query parameters
+	name, _, _ := strings.Cut(location, "?")
+	node, err := parseConfig(name, data, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if mappingValue(node, includeKey) != nil {
+		return nil, errors.New("include is not supported for remote configs")
+	}
+
+	return decode(node)
+}
+
+// ReadFromFile reads the config from a yaml, json or toml file or from all fragments of a directory, see loadNode.
 func ReadFromFile(filePath string) (*Config, error) {
+	node, err := loadNode(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode(node)
+}
+
+func decode(node *yaml.Node) (*Config, error) {
 	conf := Config{
-		MetricsAddr: defaultMetricsAddr,
-		Unbound: UnboundConfig{
-			ServiceName: defaultUnboundServiceName,
-			CreateFile:  true,
-		},
+		MetricsAddr:         defaultMetricsAddr,
+		CheckInterval:       defaultCheckInterval,
+		MaxConcurrentChecks: defaultMaxConcurrency,
+		ResolveInterval:     defaultResolveInterval,
+		Unbound:             defaultUnboundConfig(),
 	}
 
-	data, err := os.ReadFile(filePath)
+	if err := expandHostnameTemplates(node); err != nil {
+		return nil, fmt.Errorf("could not expand hostname templates: %w", err)
+	}
+
+	if err := resolveReferences(node); err != nil {
+		return nil, fmt.Errorf("could not resolve references: %w", err)
+	}
+
+	var err error
+	conf.vaultClient, err = resolveVaultReferences(node)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not resolve vault references: %w", err)
+	}
+
+	if err := resolveHealthcheckTemplates(node); err != nil {
+		return nil, fmt.Errorf("could not resolve healthcheck templates: %w", err)
+	}
+
+	if err := applyDefaults(node); err != nil {
+		return nil, fmt.Errorf("could not apply defaults: %w", err)
 	}
 
-	if err := yaml.Unmarshal(data, &conf); err != nil {
+	if err := node.Decode(&conf); err != nil {
 		return nil, err
 	}
 	return &conf, nil
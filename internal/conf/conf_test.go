@@ -1,16 +1,21 @@
 package conf
 
 import (
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestConf_Validate(t *testing.T) {
 	type fields struct {
-		Records map[string][]RecordConfig
-		Unbound UnboundConfig
+		Records      map[string][]RecordConfig
+		Unbound      UnboundConfig
+		HealthGroups map[string]HealthGroupConfig
 
-		MetricsFile string
-		MetricsAddr string
+		MetricsFile         string
+		MetricsAddr         string
+		CheckInterval       time.Duration
+		MaxConcurrentChecks int
 	}
 	tests := []struct {
 		name    string
@@ -20,6 +25,8 @@ func TestConf_Validate(t *testing.T) {
 		{
 			name: "invalid hostname",
 			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
 				Unbound: UnboundConfig{
 					DbFile:      "/path/to/file",
 					ServiceName: "unbound",
@@ -31,7 +38,7 @@ func TestConf_Validate(t *testing.T) {
 							RecordType:        "A",
 							Prio:              10,
 							Ttl:               60,
-							HealthcheckConfig: map[string]any{},
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
 							StatusConfig: StatusConfig{
 								HealthyStreak:          1,
 								UnhealthyStreak:        1,
@@ -44,7 +51,7 @@ func TestConf_Validate(t *testing.T) {
 							RecordType:        "A",
 							Prio:              10,
 							Ttl:               60,
-							HealthcheckConfig: map[string]any{},
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
 							StatusConfig: StatusConfig{
 								HealthyStreak:          1,
 								UnhealthyStreak:        1,
@@ -60,7 +67,9 @@ func TestConf_Validate(t *testing.T) {
 		{
 			name: "valid config",
 			fields: fields{
-				MetricsFile: "127.0.0.1:666",
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
+				MetricsFile:         "127.0.0.1:666",
 				Unbound: UnboundConfig{
 					DbFile:      "path/to/file",
 					ServiceName: "unbound",
@@ -72,7 +81,7 @@ func TestConf_Validate(t *testing.T) {
 							RecordType:        "A",
 							Prio:              20,
 							Ttl:               60,
-							HealthcheckConfig: map[string]any{},
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
 							StatusConfig: StatusConfig{
 								HealthyStreak:          1,
 								UnhealthyStreak:        1,
@@ -85,7 +94,7 @@ func TestConf_Validate(t *testing.T) {
 							RecordType:        "A",
 							Prio:              10,
 							Ttl:               60,
-							HealthcheckConfig: map[string]any{},
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
 							StatusConfig: StatusConfig{
 								HealthyStreak:          1,
 								UnhealthyStreak:        1,
@@ -98,9 +107,420 @@ func TestConf_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "target instead of ip",
+			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
+				Unbound: UnboundConfig{
+					DbFile:      "path/to/file",
+					ServiceName: "unbound",
+				},
+				Records: map[string][]RecordConfig{
+					"my.tld": []RecordConfig{
+						{
+							IP:                "10.0.0.1",
+							RecordType:        "A",
+							Prio:              20,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+						{
+							Target:            "backup.example.com",
+							Resolver:          "9.9.9.9:53",
+							RecordType:        "A",
+							Prio:              10,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "target without resolver",
+			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
+				Unbound: UnboundConfig{
+					DbFile:      "path/to/file",
+					ServiceName: "unbound",
+				},
+				Records: map[string][]RecordConfig{
+					"my.tld": []RecordConfig{
+						{
+							IP:                "10.0.0.1",
+							RecordType:        "A",
+							Prio:              20,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+						{
+							Target:            "backup.example.com",
+							RecordType:        "A",
+							Prio:              10,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "target and ip",
+			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
+				Unbound: UnboundConfig{
+					DbFile:      "path/to/file",
+					ServiceName: "unbound",
+				},
+				Records: map[string][]RecordConfig{
+					"my.tld": []RecordConfig{
+						{
+							IP:                "10.0.0.1",
+							RecordType:        "A",
+							Prio:              20,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+						{
+							IP:                "10.0.0.2",
+							Target:            "backup.example.com",
+							Resolver:          "9.9.9.9:53",
+							RecordType:        "A",
+							Prio:              10,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "link-local ips with zone",
+			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
+				Unbound: UnboundConfig{
+					DbFile:      "path/to/file",
+					ServiceName: "unbound",
+				},
+				Records: map[string][]RecordConfig{
+					"my.tld": []RecordConfig{
+						{
+							IP:                "fe80::1%eth0",
+							RecordType:        "AAAA",
+							Prio:              20,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+						{
+							IP:                "fe80::1%eth1",
+							RecordType:        "AAAA",
+							Prio:              10,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "zone of global ip",
+			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
+				Unbound: UnboundConfig{
+					DbFile:      "path/to/file",
+					ServiceName: "unbound",
+				},
+				Records: map[string][]RecordConfig{
+					"my.tld": []RecordConfig{
+						{
+							IP:                "2001:db8::1%eth0",
+							RecordType:        "AAAA",
+							Prio:              20,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+						{
+							IP:                "2001:db8::2",
+							RecordType:        "AAAA",
+							Prio:              10,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "wildcard hostname",
+			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
+				Unbound: UnboundConfig{
+					DbFile:      "path/to/file",
+					ServiceName: "unbound",
+				},
+				Records: map[string][]RecordConfig{
+					"*.apps.my.tld": []RecordConfig{
+						{
+							IP:                "10.0.0.1",
+							RecordType:        "A",
+							Prio:              20,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+						{
+							IP:                "10.0.0.2",
+							RecordType:        "A",
+							Prio:              10,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "wildcard along with its base",
+			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
+				Unbound: UnboundConfig{
+					DbFile:      "path/to/file",
+					ServiceName: "unbound",
+				},
+				Records: map[string][]RecordConfig{
+					"*.apps.my.tld": []RecordConfig{
+						{
+							IP:                "10.0.0.1",
+							RecordType:        "A",
+							Prio:              20,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+						{
+							IP:                "10.0.0.2",
+							RecordType:        "A",
+							Prio:              10,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+					},
+					"apps.my.tld": []RecordConfig{
+						{
+							IP:                "10.0.0.1",
+							RecordType:        "A",
+							Prio:              20,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+						{
+							IP:                "10.0.0.2",
+							RecordType:        "A",
+							Prio:              10,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "wildcard label within hostname",
+			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
+				Unbound: UnboundConfig{
+					DbFile:      "path/to/file",
+					ServiceName: "unbound",
+				},
+				Records: map[string][]RecordConfig{
+					"apps.*.my.tld": []RecordConfig{
+						{
+							IP:                "10.0.0.1",
+							RecordType:        "A",
+							Prio:              20,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+						{
+							IP:                "10.0.0.2",
+							RecordType:        "A",
+							Prio:              10,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "duplicated ip",
 			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
+				Unbound: UnboundConfig{
+					DbFile:      "path/to/file",
+					ServiceName: "unbound",
+				},
+				Records: map[string][]RecordConfig{
+					"my.tld": []RecordConfig{
+						{
+							IP:                "10.0.0.1",
+							RecordType:        "A",
+							Prio:              20,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+						{
+							IP:                "10.0.0.1",
+							RecordType:        "A",
+							Prio:              10,
+							Ttl:               60,
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicated prio",
+			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
 				Unbound: UnboundConfig{
 					DbFile:      "path/to/file",
 					ServiceName: "unbound",
@@ -112,7 +532,7 @@ func TestConf_Validate(t *testing.T) {
 							RecordType:        "A",
 							Prio:              20,
 							Ttl:               60,
-							HealthcheckConfig: map[string]any{},
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
 							StatusConfig: StatusConfig{
 								HealthyStreak:          1,
 								UnhealthyStreak:        1,
@@ -121,11 +541,11 @@ func TestConf_Validate(t *testing.T) {
 							},
 						},
 						{
-							IP:                "10.0.0.1",
+							IP:                "10.0.0.2",
 							RecordType:        "A",
-							Prio:              10,
+							Prio:              20,
 							Ttl:               60,
-							HealthcheckConfig: map[string]any{},
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
 							StatusConfig: StatusConfig{
 								HealthyStreak:          1,
 								UnhealthyStreak:        1,
@@ -139,8 +559,10 @@ func TestConf_Validate(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "duplicated prio",
+			name: "healthchecker timeout exceeds check interval",
 			fields: fields{
+				CheckInterval:       10 * time.Second,
+				MaxConcurrentChecks: 32,
 				Unbound: UnboundConfig{
 					DbFile:      "path/to/file",
 					ServiceName: "unbound",
@@ -152,7 +574,7 @@ func TestConf_Validate(t *testing.T) {
 							RecordType:        "A",
 							Prio:              20,
 							Ttl:               60,
-							HealthcheckConfig: map[string]any{},
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Timeout: 10 * time.Second, Icmp: &IcmpHealthcheckConfig{}},
 							StatusConfig: StatusConfig{
 								HealthyStreak:          1,
 								UnhealthyStreak:        1,
@@ -163,9 +585,105 @@ func TestConf_Validate(t *testing.T) {
 						{
 							IP:                "10.0.0.2",
 							RecordType:        "A",
-							Prio:              20,
+							Prio:              10,
 							Ttl:               60,
-							HealthcheckConfig: map[string]any{},
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "records of health group",
+			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
+				Unbound: UnboundConfig{
+					DbFile:      "path/to/file",
+					ServiceName: "unbound",
+				},
+				HealthGroups: map[string]HealthGroupConfig{
+					"site-a-up": {
+						IP:                "10.0.0.254",
+						HealthcheckConfig: HealthcheckConfig{Type: TcpCheckerName, Tcp: &TcpHealthcheckConfig{Port: 443}},
+					},
+				},
+				Records: map[string][]RecordConfig{
+					"my.tld": []RecordConfig{
+						{
+							IP:          "10.0.0.1",
+							RecordType:  "A",
+							Prio:        20,
+							Ttl:         60,
+							HealthGroup: "site-a-up",
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+						{
+							IP:          "10.0.0.2",
+							RecordType:  "A",
+							Prio:        10,
+							Ttl:         60,
+							HealthGroup: "site-a-up",
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown health group",
+			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
+				Unbound: UnboundConfig{
+					DbFile:      "path/to/file",
+					ServiceName: "unbound",
+				},
+				HealthGroups: map[string]HealthGroupConfig{
+					"site-a-up": {
+						IP:                "10.0.0.254",
+						HealthcheckConfig: HealthcheckConfig{Type: TcpCheckerName, Tcp: &TcpHealthcheckConfig{Port: 443}},
+					},
+				},
+				Records: map[string][]RecordConfig{
+					"my.tld": []RecordConfig{
+						{
+							IP:          "10.0.0.1",
+							RecordType:  "A",
+							Prio:        20,
+							Ttl:         60,
+							HealthGroup: "site-b-up",
+							StatusConfig: StatusConfig{
+								HealthyStreak:          1,
+								UnhealthyStreak:        1,
+								InitialHealthyStreak:   1,
+								InitialUnhealthyStreak: 1,
+							},
+						},
+						{
+							IP:          "10.0.0.2",
+							RecordType:  "A",
+							Prio:        10,
+							Ttl:         60,
+							HealthGroup: "site-b-up",
 							StatusConfig: StatusConfig{
 								HealthyStreak:          1,
 								UnhealthyStreak:        1,
@@ -181,6 +699,8 @@ func TestConf_Validate(t *testing.T) {
 		{
 			name: "only one record",
 			fields: fields{
+				CheckInterval:       30 * time.Second,
+				MaxConcurrentChecks: 32,
 				Unbound: UnboundConfig{
 					DbFile:      "path/to/file",
 					ServiceName: "unbound",
@@ -192,7 +712,7 @@ func TestConf_Validate(t *testing.T) {
 							RecordType:        "A",
 							Prio:              20,
 							Ttl:               60,
-							HealthcheckConfig: map[string]any{},
+							HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}},
 							StatusConfig: StatusConfig{
 								HealthyStreak:          1,
 								UnhealthyStreak:        1,
@@ -209,10 +729,13 @@ func TestConf_Validate(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := &Config{
-				Records:     tt.fields.Records,
-				Unbound:     tt.fields.Unbound,
-				MetricsAddr: tt.fields.MetricsAddr,
-				MetricsFile: tt.fields.MetricsFile,
+				Records:             tt.fields.Records,
+				Unbound:             tt.fields.Unbound,
+				HealthGroups:        tt.fields.HealthGroups,
+				MetricsAddr:         tt.fields.MetricsAddr,
+				MetricsFile:         tt.fields.MetricsFile,
+				CheckInterval:       tt.fields.CheckInterval,
+				MaxConcurrentChecks: tt.fields.MaxConcurrentChecks,
 			}
 			if err := c.Validate(); (err != nil) != tt.wantErr {
 				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
@@ -220,3 +743,325 @@ func TestConf_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_dependencyCycle(t *testing.T) {
+	tests := []struct {
+		name      string
+		hostnames map[string]HostnameConfig
+		wantErr   bool
+	}{
+		{
+			name: "no cycle",
+			hostnames: map[string]HostnameConfig{
+				"db.tld":  {DependsOn: []string{"app.tld"}},
+				"app.tld": {DependsOn: []string{"lb.tld"}},
+			},
+		},
+		{
+			name: "cycle",
+			hostnames: map[string]HostnameConfig{
+				"db.tld":  {DependsOn: []string{"app.tld"}},
+				"app.tld": {DependsOn: []string{"db.tld"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Hostnames: tt.hostnames}
+			if err := c.dependencyCycle(); (err != nil) != tt.wantErr {
+				t.Errorf("dependencyCycle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_validateZoneRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		records map[string][]RecordConfig
+		wantErr bool
+	}{
+		{
+			name:    "hostname in zone",
+			records: map[string][]RecordConfig{"www.example.com": {{IP: "10.0.0.1"}}},
+		},
+		{
+			name:    "zone apex",
+			records: map[string][]RecordConfig{"example.com": {{IP: "10.0.0.1"}}},
+		},
+		{
+			name:    "hostname outside of zone",
+			records: map[string][]RecordConfig{"www.notexample.com": {{IP: "10.0.0.1"}}},
+			wantErr: true,
+		},
+		{
+			name:    "ptr",
+			records: map[string][]RecordConfig{"www.example.com": {{IP: "10.0.0.1", Ptr: true}}},
+			wantErr: true,
+		},
+		{
+			name:    "view",
+			records: map[string][]RecordConfig{"www.example.com@internal": {{IP: "10.0.0.1"}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Records: tt.records}
+			if err := c.validateZoneRecords("bind", "example.com."); (err != nil) != tt.wantErr {
+				t.Errorf("validateZoneRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_validateHostsRecords(t *testing.T) {
+	tests := []struct {
+		name     string
+		records  map[string][]RecordConfig
+		stateTxt bool
+		wantErr  bool
+	}{
+		{
+			name:    "hostname",
+			records: map[string][]RecordConfig{"www.example.com": {{IP: "10.0.0.1"}}},
+		},
+		{
+			name:    "wildcard",
+			records: map[string][]RecordConfig{"*.example.com": {{IP: "10.0.0.1"}}},
+			wantErr: true,
+		},
+		{
+			name:    "ptr",
+			records: map[string][]RecordConfig{"www.example.com": {{IP: "10.0.0.1", Ptr: true}}},
+			wantErr: true,
+		},
+		{
+			name:    "view",
+			records: map[string][]RecordConfig{"www.example.com@internal": {{IP: "10.0.0.1"}}},
+			wantErr: true,
+		},
+		{
+			name:     "state txt",
+			records:  map[string][]RecordConfig{"www.example.com": {{IP: "10.0.0.1"}}},
+			stateTxt: true,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Records: tt.records, StateTxt: tt.stateTxt}
+			if err := c.validateHostsRecords("resolved"); (err != nil) != tt.wantErr {
+				t.Errorf("validateHostsRecords() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_validatePrivilegedPings(t *testing.T) {
+	privileged, unprivileged := true, false
+	withCheck := func(check HealthcheckConfig) map[string][]RecordConfig {
+		return map[string][]RecordConfig{"my.tld": {{IP: "10.0.0.1", HealthcheckConfig: check}}}
+	}
+	tests := []struct {
+		name    string
+		conf    Config
+		wantErr bool
+	}{
+		{
+			name: "default ping",
+			conf: Config{Records: withCheck(HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}})},
+		},
+		{
+			name: "unprivileged ping",
+			conf: Config{Records: withCheck(HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{Privileged: &unprivileged}})},
+		},
+		{
+			name:    "privileged ping",
+			conf:    Config{Records: withCheck(HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{Privileged: &privileged}})},
+			wantErr: true,
+		},
+		{
+			name: "privileged passive ping",
+			conf: Config{Records: withCheck(HealthcheckConfig{
+				Type:    TcpCheckerName,
+				Tcp:     &TcpHealthcheckConfig{Port: 80},
+				Passive: &HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{Privileged: &privileged}},
+			})},
+			wantErr: true,
+		},
+		{
+			name: "privileged ping of health group",
+			conf: Config{HealthGroups: map[string]HealthGroupConfig{
+				"group": {IP: "10.0.0.1", HealthcheckConfig: HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{Privileged: &privileged}}},
+			}},
+			wantErr: true,
+		},
+		{
+			name:    "privileged guard",
+			conf:    Config{Guard: &GuardConfig{Privileged: &privileged}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.conf.validatePrivilegedPings(); (err != nil) != tt.wantErr {
+				t.Errorf("validatePrivilegedPings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_hasRecordIp(t *testing.T) {
+	conf := Config{Records: map[string][]RecordConfig{"my.tld": {{IP: "10.0.0.1"}, {IP: "fe80::1%eth0"}}}}
+	tests := []struct {
+		name     string
+		hostname string
+		ip       string
+		want     bool
+	}{
+		{name: "record ip", hostname: "my.tld", ip: "10.0.0.1", want: true},
+		{name: "zone is ignored", hostname: "my.tld", ip: "fe80::1", want: true},
+		{name: "unknown ip", hostname: "my.tld", ip: "10.0.0.2"},
+		{name: "other hostname", hostname: "other.tld", ip: "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conf.hasRecordIp(tt.hostname, tt.ip); got != tt.want {
+				t.Errorf("hasRecordIp() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate_resolvedHostsFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		hostsFile string
+		wantErr   bool
+	}{
+		{name: "default hosts file", hostsFile: "/etc/hosts"},
+		{name: "other hosts file", hostsFile: "/etc/dns-ha/hosts", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Resolved: &ResolvedConfig{HostsFile: tt.hostsFile}}
+			err := c.Validate()
+			if got := err != nil && strings.Contains(err.Error(), "only reads"); got != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate_serviceTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		wantErr bool
+	}{
+		{name: "default timeout"},
+		{name: "lower than check interval", timeout: 10 * time.Second},
+		{name: "check interval", timeout: 30 * time.Second, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{CheckInterval: 30 * time.Second, Service: ServiceConfig{Timeout: tt.timeout}}
+			err := c.Validate()
+			if got := err != nil && strings.Contains(err.Error(), "service timeout"); got != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate_defaultCheckTimeout(t *testing.T) {
+	tests := []struct {
+		name          string
+		checkInterval time.Duration
+		timeout       time.Duration
+		wantErr       bool
+	}{
+		{name: "default timeout", checkInterval: 10 * time.Second},
+		{name: "default timeout reaches check interval", checkInterval: DefaultCheckTimeout, wantErr: true},
+		{name: "lower explicit timeout", checkInterval: DefaultCheckTimeout, timeout: time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := HealthcheckConfig{Type: IcmpCheckerName, Timeout: tt.timeout, Icmp: &IcmpHealthcheckConfig{}}
+			c := &Config{
+				CheckInterval: tt.checkInterval,
+				Records:       map[string][]RecordConfig{"my.tld": {{IP: "10.0.0.1", HealthcheckConfig: check}}},
+				HealthGroups:  map[string]HealthGroupConfig{"site-a-up": {IP: "10.0.0.254", HealthcheckConfig: check}},
+			}
+			err := c.Validate()
+			for _, subject := range []string{"for my.tld (10.0.0.1)", `for health group "site-a-up"`} {
+				if got := err != nil && strings.Contains(err.Error(), "healthchecker timeout 5s "+subject); got != tt.wantErr {
+					t.Errorf("Validate() error = %v, wantErr %v for %s", err, tt.wantErr, subject)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryConfig_Delay(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryConfig
+		retry  int
+		want   time.Duration
+	}{
+		{name: "first retry", policy: RetryConfig{Backoff: time.Second}, retry: 1, want: time.Second},
+		{name: "doubles", policy: RetryConfig{Backoff: time.Second}, retry: 3, want: 4 * time.Second},
+		{name: "capped", policy: RetryConfig{Backoff: time.Second, MaxBackoff: 3 * time.Second}, retry: 3, want: 3 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Delay(tt.retry); got != tt.want {
+				t.Errorf("Delay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := (RetryConfig{Backoff: time.Second}).Delay(100); got <= 0 || got > maxRetryDelay {
+		t.Errorf("Delay() = %v, want a positive delay of at most %v", got, maxRetryDelay)
+	}
+}
+
+func TestConfig_Validate_retryBudget(t *testing.T) {
+	tests := []struct {
+		name    string
+		service ServiceConfig
+		wantErr bool
+	}{
+		{name: "no retries", service: ServiceConfig{}},
+		{
+			name:    "fits into the check interval",
+			service: ServiceConfig{Timeout: 5 * time.Second, Retry: &RetryConfig{Attempts: 3, Backoff: time.Second, Jitter: time.Second}},
+		},
+		{
+			name:    "default timeout",
+			service: ServiceConfig{Retry: &RetryConfig{Attempts: 2}},
+			wantErr: true,
+		},
+		{
+			name:    "backoff exceeds the check interval",
+			service: ServiceConfig{Timeout: time.Second, Retry: &RetryConfig{Attempts: 10, Backoff: time.Second}},
+			wantErr: true,
+		},
+		{
+			name:    "capped backoff",
+			service: ServiceConfig{Timeout: time.Second, Retry: &RetryConfig{Attempts: 10, Backoff: time.Second, MaxBackoff: 2 * time.Second}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{CheckInterval: 30 * time.Second, Service: tt.service}
+			err := c.Validate()
+			if got := err != nil && strings.Contains(err.Error(), "retries may take"); got != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
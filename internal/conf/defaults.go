@@ -0,0 +1,70 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultsKey = "defaults"
+
+// applyDefaults merges the top-level defaults section into every record, so settings such as the ttl, status streaks
+// or the healthchecker only need to be defined once. Values defined by a record take precedence, nested mappings are
+// merged key-wise. A record's healthchecker of a different type replaces the default healthchecker entirely.
+func applyDefaults(node *yaml.Node) error {
+	defaults := topLevelValue(node, defaultsKey)
+	if defaults == nil {
+		return nil
+	}
+
+	if defaults.Kind != yaml.MappingNode {
+		return errors.New("defaults must be a mapping")
+	}
+
+	for _, key := range []string{"ip", "prio"} {
+		if mappingValue(defaults, key) != nil {
+			return fmt.Errorf("%q can not be set in defaults", key)
+		}
+	}
+
+	records := topLevelValue(node, "records")
+	if records == nil || records.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 1; i < len(records.Content); i += 2 {
+		if records.Content[i].Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, record := range records.Content[i].Content {
+			if record.Kind == yaml.MappingNode {
+				mergeDefaults(record, defaults)
+			}
+		}
+	}
+
+	return nil
+}
+
+func mergeDefaults(dst, defaults *yaml.Node) {
+	for i := 0; i+1 < len(defaults.Content); i += 2 {
+		key, value := defaults.Content[i], defaults.Content[i+1]
+
+		existing := mappingValue(dst, key.Value)
+		switch {
+		case existing == nil:
+			dst.Content = append(dst.Content, key, value)
+		case existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			if (key.Value == "healthchecker" || key.Value == passiveKey) && !sameHealthcheckType(existing, value) {
+				continue
+			}
+			mergeDefaults(existing, value)
+		}
+	}
+}
+
+func sameHealthcheckType(a, b *yaml.Node) bool {
+	typeA, typeB := mappingValue(a, "type"), mappingValue(b, "type")
+	return typeA == nil || typeB == nil || typeA.Value == typeB.Value
+}
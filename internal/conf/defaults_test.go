@@ -0,0 +1,115 @@
+package conf
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestApplyDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "record inherits and overrides",
+			data: `
+defaults:
+  ttl: 60
+  status:
+    healthy: 3
+    unhealthy: 2
+  healthchecker:
+    type: tcp
+    port: 22
+records:
+  a.tld:
+    - ip: 10.0.0.1
+      status:
+        healthy: 1
+    - ip: 10.0.0.2
+      ttl: 30
+      healthchecker:
+        port: 443
+`,
+			want: `
+a.tld:
+  - ip: 10.0.0.1
+    status:
+      healthy: 1
+      unhealthy: 2
+    ttl: 60
+    healthchecker:
+      type: tcp
+      port: 22
+  - ip: 10.0.0.2
+    ttl: 30
+    healthchecker:
+      port: 443
+      type: tcp
+    status:
+      healthy: 3
+      unhealthy: 2
+`,
+		},
+		{
+			name: "healthchecker of different type replaces default",
+			data: `
+defaults:
+  healthchecker:
+    type: tcp
+    port: 22
+records:
+  a.tld:
+    - ip: 10.0.0.1
+      healthchecker:
+        type: icmp
+`,
+			want: `
+a.tld:
+  - ip: 10.0.0.1
+    healthchecker:
+      type: icmp
+`,
+		},
+		{
+			name:    "ip in defaults",
+			data:    "defaults:\n  ip: 10.0.0.1\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var node yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.data), &node); err != nil {
+				t.Fatal(err)
+			}
+
+			err := applyDefaults(&node)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyDefaults() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var got struct {
+				Records map[string]any `yaml:"records"`
+			}
+			if err := node.Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+
+			var want map[string]any
+			if err := yaml.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+
+			if !equalYaml(got.Records, want) {
+				t.Errorf("applyDefaults() got = %v, want %v", got.Records, want)
+			}
+		})
+	}
+}
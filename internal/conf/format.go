@@ -0,0 +1,60 @@
+package conf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	formatYaml = "yaml"
+	formatJson = "json"
+	formatToml = "toml"
+)
+
+// formatFromPath detects the config format by the file extension, unknown extensions are treated as yaml.
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return formatJson
+	case ".toml":
+		return formatToml
+	default:
+		return formatYaml
+	}
+}
+
+func isConfigFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json", ".toml":
+		return true
+	}
+	return false
+}
+
+// parseDocument parses the data into a yaml node, so all formats share the same processing and decoding logic. JSON
+// is a subset of yaml and is parsed as is, TOML is converted.
+func parseDocument(data []byte, format string) (*yaml.Node, error) {
+	var node yaml.Node
+	switch format {
+	case formatYaml, formatJson:
+		if err := yaml.Unmarshal(data, &node); err != nil {
+			return nil, err
+		}
+	case formatToml:
+		var content map[string]any
+		if err := toml.Unmarshal(data, &content); err != nil {
+			return nil, err
+		}
+		if err := node.Encode(content); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown config format %q", format)
+	}
+
+	return &node, nil
+}
@@ -0,0 +1,128 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadFromFile_Formats(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+check_interval: 10s
+records:
+  host.my.tld:
+    - ip: 10.0.0.1
+      type: A
+      prio: 250
+      ttl: 60
+      healthchecker:
+        type: tcp
+        port: 22
+        timeout: 2s
+`,
+		"config.json": `{
+  "check_interval": "10s",
+  "records": {
+    "host.my.tld": [
+      {"ip": "10.0.0.1", "type": "A", "prio": 250, "ttl": 60, "healthchecker": {"type": "tcp", "port": 22, "timeout": "2s"}}
+    ]
+  }
+}`,
+		"config.toml": `
+check_interval = "10s"
+
+[[records."host.my.tld"]]
+ip = "10.0.0.1"
+type = "A"
+prio = 250
+ttl = 60
+
+[records."host.my.tld".healthchecker]
+type = "tcp"
+port = 22
+timeout = "2s"
+`,
+	}
+
+	dir := t.TempDir()
+	var want *Config
+	for _, name := range []string{"config.yaml", "config.json", "config.toml"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(files[name]), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ReadFromFile(path)
+			if err != nil {
+				t.Fatalf("ReadFromFile() unexpected error = %v", err)
+			}
+
+			if got.CheckInterval != 10*time.Second {
+				t.Errorf("expected check_interval 10s, got %v", got.CheckInterval)
+			}
+
+			records := got.Records["host.my.tld"]
+			if len(records) != 1 || records[0].HealthcheckConfig.Tcp == nil || records[0].HealthcheckConfig.Tcp.Port != 22 {
+				t.Fatalf("unexpected records %+v", records)
+			}
+
+			if want == nil {
+				want = got
+			} else if !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadFromFile() got = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadFromFile_BaselineArgs(t *testing.T) {
+	// earlier versions read the healthchecker args as strings
+	data := `
+records:
+  host.my.tld:
+    - ip: 10.0.0.1
+      type: A
+      prio: 250
+      ttl: 60
+      healthchecker:
+        type: http
+        port: "8443"
+        use_tls: "true"
+    - ip: 10.0.1.1
+      type: A
+      prio: 200
+      ttl: 60
+      healthchecker:
+        type: tcp
+        port: "443"
+        timeout: "2s"
+
+unbound:
+  db_file: /etc/unbound/dns-ha.conf
+  service_name: unbound
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadFromFile(path)
+	if err != nil {
+		t.Fatalf("ReadFromFile() unexpected error = %v", err)
+	}
+	if err := got.Validate(); err != nil {
+		t.Fatalf("Validate() unexpected error = %v", err)
+	}
+
+	records := got.Records["host.my.tld"]
+	if http := records[0].HealthcheckConfig.Http; http == nil || http.Port != 8443 || !http.UseTls {
+		t.Errorf("unexpected http healthcheck %+v", http)
+	}
+	if tcp := records[1].HealthcheckConfig.Tcp; tcp == nil || tcp.Port != 443 {
+		t.Errorf("unexpected tcp healthcheck %+v", tcp)
+	}
+}
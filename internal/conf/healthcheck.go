@@ -0,0 +1,437 @@
+package conf
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	HttpCheckerName       = "http"
+	IcmpCheckerName       = "icmp"
+	TcpCheckerName        = "tcp"
+	FileCheckerName       = "file"
+	SnmpCheckerName       = "snmp"
+	MqttCheckerName       = "mqtt"
+	ModbusCheckerName     = "modbus"
+	LdapCheckerName       = "ldap"
+	KerberosCheckerName   = "kerberos"
+	SipCheckerName        = "sip"
+	RtspCheckerName       = "rtsp"
+	MonitoringCheckerName = "monitoring"
+)
+
+// DefaultCheckTimeout bounds a single check if the healthchecker has no timeout configured.
+const DefaultCheckTimeout = 5 * time.Second
+
+// HealthcheckConfig holds the typed configuration of exactly one healthchecker, selected by Type.
+type HealthcheckConfig struct {
+	Type    string        `json:"type" yaml:"type" validate:"required,oneof=http icmp tcp file snmp mqtt modbus ldap kerberos sip rtsp monitoring"`
+	Timeout time.Duration `json:"timeout" yaml:"timeout" validate:"gte=0"`
+	// Template is the name of the healthcheck template the config is based on.
+	Template string `json:"template" yaml:"template"`
+	// SourceIp and SourceInterface pin the probes to a local address or interface on multi-homed hosts.
+	SourceIp        string `json:"source_ip" yaml:"source_ip" validate:"omitempty,ip"`
+	SourceInterface string `json:"source_interface" yaml:"source_interface"`
+	// Samples probes the healthchecker more often than the records are evaluated, each evaluation uses the verdict of
+	// the latest samples.
+	Samples *SamplesConfig `json:"samples" yaml:"samples"`
+	// Passive is a healthchecker that reports failures observed elsewhere, e.g. by a monitoring system, in addition
+	// to the probes of this healthchecker.
+	Passive *HealthcheckConfig `json:"passive" yaml:"passive" validate:"-"`
+	// Precedence is either "passive", a failure reported by the passive healthchecker renders the record unhealthy
+	// even if the probe succeeds, or "active", the passive healthchecker only decides if the probe fails with an
+	// error. Defaults to "passive".
+	Precedence string `json:"precedence" yaml:"precedence" validate:"excluded_without=Passive,omitempty,oneof=passive active"`
+
+	Http       *HttpHealthcheckConfig       `json:"-" yaml:"-" validate:"-"`
+	Icmp       *IcmpHealthcheckConfig       `json:"-" yaml:"-" validate:"-"`
+	Tcp        *TcpHealthcheckConfig        `json:"-" yaml:"-" validate:"-"`
+	File       *FileHealthcheckConfig       `json:"-" yaml:"-" validate:"-"`
+	Snmp       *SnmpHealthcheckConfig       `json:"-" yaml:"-" validate:"-"`
+	Mqtt       *MqttHealthcheckConfig       `json:"-" yaml:"-" validate:"-"`
+	Modbus     *ModbusHealthcheckConfig     `json:"-" yaml:"-" validate:"-"`
+	Ldap       *LdapHealthcheckConfig       `json:"-" yaml:"-" validate:"-"`
+	Kerberos   *KerberosHealthcheckConfig   `json:"-" yaml:"-" validate:"-"`
+	Sip        *SipHealthcheckConfig        `json:"-" yaml:"-" validate:"-"`
+	Rtsp       *RtspHealthcheckConfig       `json:"-" yaml:"-" validate:"-"`
+	Monitoring *MonitoringHealthcheckConfig `json:"-" yaml:"-" validate:"-"`
+}
+
+// SamplesConfig defines how often a healthchecker is sampled and how many samples make up its verdict.
+type SamplesConfig struct {
+	Interval time.Duration `json:"interval" yaml:"interval" validate:"required,gte=1s"`
+	// Window is the amount of latest samples the verdict is based on.
+	Window int `json:"window" yaml:"window" validate:"required,gte=1,lte=100"`
+	// MinHealthy is the amount of healthy samples within the window for a healthy verdict, defaults to the majority.
+	MinHealthy int `json:"min_healthy" yaml:"min_healthy" validate:"omitempty,ltefield=Window"`
+}
+
+type HttpHealthcheckConfig struct {
+	Port   int  `json:"port" yaml:"port" validate:"omitempty,port"`
+	UseTls bool `json:"use_tls" yaml:"use_tls"`
+	// FollowRedirects follows redirects and validates the status of the final response instead of the redirect.
+	FollowRedirects bool `json:"follow_redirects" yaml:"follow_redirects"`
+	// MaxRedirects is the maximum amount of redirects that are followed, defaults to 10.
+	MaxRedirects int `json:"max_redirects" yaml:"max_redirects" validate:"gte=0"`
+	// ExpectedHost is the host the final response needs to be served by, e.g. to detect redirects to an error page
+	// on another host.
+	ExpectedHost string `json:"expected_host" yaml:"expected_host" validate:"omitempty,hostname_rfc1123|ip"`
+	// HostHeader overrides the Host header of the request, the TLS server name is still derived from the hostname.
+	HostHeader string `json:"host_header" yaml:"host_header" validate:"omitempty,hostname_rfc1123"`
+	// VirtualHosts are probed on the same address, each using its name as Host header and TLS server name.
+	VirtualHosts []string `json:"virtual_hosts" yaml:"virtual_hosts" validate:"excluded_with=HostHeader,omitempty,dive,hostname_rfc1123"`
+	// VirtualHostsPolicy defines whether all or any of the virtual hosts need to pass, defaults to all.
+	VirtualHostsPolicy string `json:"virtual_hosts_policy" yaml:"virtual_hosts_policy" validate:"omitempty,oneof=all any"`
+	// BodySha256 is the hex encoded SHA-256 hash the response body needs to match.
+	BodySha256 string `json:"body_sha256" yaml:"body_sha256" validate:"omitempty,hexadecimal,len=64"`
+	// MinContentLength is the minimum size of the response body in bytes.
+	MinContentLength int64 `json:"min_content_length" yaml:"min_content_length" validate:"gte=0"`
+	// MaxContentLength is the maximum size of the response body in bytes, unlimited if 0.
+	MaxContentLength int64 `json:"max_content_length" yaml:"max_content_length" validate:"omitempty,gtefield=MinContentLength"`
+}
+
+type IcmpHealthcheckConfig struct {
+	Privileged *bool `json:"privileged" yaml:"privileged"`
+}
+
+type TcpHealthcheckConfig struct {
+	Port int `json:"port" yaml:"port" validate:"required,port"`
+}
+
+// FileHealthcheckConfig checks a file that is maintained by an external agent, e.g. a promotion script touching
+// /run/primary. By default, the record is healthy while the file exists.
+type FileHealthcheckConfig struct {
+	Path string `json:"path" yaml:"path" validate:"required,filepath"`
+	// Absent inverts the check, the record is healthy while the file does not exist.
+	Absent bool `json:"absent" yaml:"absent" validate:"excluded_with=Content MaxAge"`
+	// Content is compared to the content of the file, leading and trailing whitespace is ignored.
+	Content string `json:"content" yaml:"content"`
+	// MaxAge is the maximum age of the file's modification time, e.g. for files touched by a heartbeat.
+	MaxAge time.Duration `json:"max_age" yaml:"max_age" validate:"gte=0"`
+}
+
+// SnmpHealthcheckConfig gets a single OID of network gear or appliances whose health is not visible via HTTP or TCP.
+// The record is healthy if the value matches Expected and lies within Min and Max, numeric values are compared
+// numerically.
+type SnmpHealthcheckConfig struct {
+	Port int `json:"port" yaml:"port" validate:"omitempty,port"`
+	// Version is either "2c" or "3", defaults to "2c".
+	Version   string `json:"version" yaml:"version" validate:"omitempty,oneof=2c 3"`
+	Community string `json:"community" yaml:"community" validate:"required_unless=Version 3"`
+	Oid       string `json:"oid" yaml:"oid" validate:"required"`
+
+	Expected string   `json:"expected" yaml:"expected"`
+	Min      *float64 `json:"min" yaml:"min"`
+	Max      *float64 `json:"max" yaml:"max"`
+
+	// Username, the auth and the privacy settings authenticate and encrypt SNMPv3 requests, privacy requires auth.
+	Username     string `json:"username" yaml:"username" validate:"required_if=Version 3"`
+	AuthProtocol string `json:"auth_protocol" yaml:"auth_protocol" validate:"required_with=PrivProtocol,omitempty,oneof=md5 sha sha224 sha256 sha384 sha512"`
+	AuthPassword string `json:"auth_password" yaml:"auth_password" validate:"required_with=AuthProtocol"`
+	PrivProtocol string `json:"priv_protocol" yaml:"priv_protocol" validate:"required_with=PrivPassword,omitempty,oneof=des aes aes192 aes256"`
+	PrivPassword string `json:"priv_password" yaml:"priv_password" validate:"required_with=PrivProtocol"`
+}
+
+// MqttHealthcheckConfig connects to an MQTT broker. If a topic is configured, a message needs to be published to the
+// topic within the timeout of the check.
+type MqttHealthcheckConfig struct {
+	Port     int    `json:"port" yaml:"port" validate:"omitempty,port"`
+	UseTls   bool   `json:"use_tls" yaml:"use_tls"`
+	Username string `json:"username" yaml:"username" validate:"required_with=Password"`
+	Password string `json:"password" yaml:"password"`
+	Topic    string `json:"topic" yaml:"topic"`
+	// AcceptRetained accepts the retained message of the topic, which may be stale if its publisher is gone.
+	AcceptRetained bool `json:"accept_retained" yaml:"accept_retained"`
+}
+
+// ModbusHealthcheckConfig reads a single register of a Modbus TCP device. The record is healthy if the value matches
+// Expected and lies within Min and Max.
+type ModbusHealthcheckConfig struct {
+	Port   int `json:"port" yaml:"port" validate:"omitempty,port"`
+	UnitId int `json:"unit_id" yaml:"unit_id" validate:"gte=0,lte=255"`
+	// Register is the zero-based address of the register.
+	Register int `json:"register" yaml:"register" validate:"gte=0,lte=65535"`
+	// RegisterType is either "holding" or "input", defaults to "holding".
+	RegisterType string `json:"register_type" yaml:"register_type" validate:"omitempty,oneof=holding input"`
+	Expected     *int   `json:"expected" yaml:"expected" validate:"omitempty,gte=0,lte=65535"`
+	Min          *int   `json:"min" yaml:"min" validate:"omitempty,gte=0,lte=65535"`
+	Max          *int   `json:"max" yaml:"max" validate:"omitempty,gte=0,lte=65535"`
+}
+
+// LdapHealthcheckConfig binds to a directory server, anonymously unless BindDn is set, and searches the BaseDn or the
+// root DSE if BaseDn is empty.
+type LdapHealthcheckConfig struct {
+	Port int `json:"port" yaml:"port" validate:"omitempty,port"`
+	// UseTls connects using LDAPS, the port defaults to 636.
+	UseTls       bool   `json:"use_tls" yaml:"use_tls"`
+	BindDn       string `json:"bind_dn" yaml:"bind_dn"`
+	BindPassword string `json:"bind_password" yaml:"bind_password" validate:"required_with=BindDn"`
+	BaseDn       string `json:"base_dn" yaml:"base_dn"`
+}
+
+// KerberosHealthcheckConfig sends an AS-REQ to a KDC, any answer of the KDC for the realm counts as healthy.
+type KerberosHealthcheckConfig struct {
+	Port  int    `json:"port" yaml:"port" validate:"omitempty,port"`
+	Realm string `json:"realm" yaml:"realm" validate:"required"`
+	// Principal is the client principal of the request, it does not need to exist. Defaults to "dns-ha".
+	Principal string `json:"principal" yaml:"principal"`
+	// Transport is either "tcp" or "udp", defaults to "tcp".
+	Transport string `json:"transport" yaml:"transport" validate:"omitempty,oneof=tcp udp"`
+}
+
+// SipHealthcheckConfig sends a SIP OPTIONS request, e.g. to a PBX or SIP proxy.
+type SipHealthcheckConfig struct {
+	Port int `json:"port" yaml:"port" validate:"omitempty,port"`
+	// Transport is either "udp", "tcp" or "tls", defaults to "udp".
+	Transport string `json:"transport" yaml:"transport" validate:"omitempty,oneof=udp tcp tls"`
+	// StatusCodes are the final responses that are considered healthy, defaults to 200.
+	StatusCodes []int `json:"status_codes" yaml:"status_codes" validate:"omitempty,dive,gte=200,lte=699"`
+}
+
+// RtspHealthcheckConfig sends an RTSP OPTIONS request, e.g. to a camera or streaming server.
+type RtspHealthcheckConfig struct {
+	Port int `json:"port" yaml:"port" validate:"omitempty,port"`
+	// Path is the path of the stream, the request applies to the server itself if empty.
+	Path string `json:"path" yaml:"path" validate:"omitempty,startswith=/"`
+	// StatusCodes are the responses that are considered healthy, defaults to 200.
+	StatusCodes []int `json:"status_codes" yaml:"status_codes" validate:"omitempty,dive,gte=100,lte=599"`
+}
+
+// MonitoringHealthcheckConfig queries the state of a host or service from an existing monitoring system instead of
+// probing the record itself.
+type MonitoringHealthcheckConfig struct {
+	// System is either "icinga", "zabbix" or "uptime_kuma".
+	System string `json:"system" yaml:"system" validate:"required,oneof=icinga zabbix uptime_kuma"`
+	// Url is the base url of the Icinga 2 API, e.g. "https://icinga.example.com:5665", the url of the Zabbix API,
+	// e.g. "https://zabbix.example.com/api_jsonrpc.php", or the base url of Uptime Kuma.
+	Url string `json:"url" yaml:"url" validate:"required,http_url"`
+	// CaFile verifies the certificate of the monitoring system, e.g. the CA of the Icinga 2 cluster.
+	CaFile string `json:"ca_file" yaml:"ca_file" validate:"omitempty,filepath"`
+	// Username and Password authenticate at the Icinga 2 API.
+	Username string `json:"username" yaml:"username" validate:"required_with=Password"`
+	Password string `json:"password" yaml:"password"`
+	// Token is the API token of Zabbix.
+	Token string `json:"token" yaml:"token" validate:"required_if=System zabbix"`
+	// Host is the name of the host in Icinga 2 and Zabbix or the name of the monitor in Uptime Kuma.
+	Host string `json:"host" yaml:"host" validate:"required"`
+	// Service is the name of a service of the host in Icinga 2, in Zabbix it restricts the triggers of the host to
+	// those whose name contains it.
+	Service string `json:"service" yaml:"service" validate:"excluded_if=System uptime_kuma"`
+	// StatusPage is the slug of the Uptime Kuma status page that lists the monitor.
+	StatusPage string `json:"status_page" yaml:"status_page" validate:"required_if=System uptime_kuma"`
+	// AcceptWarning considers Icinga 2 services in warning state healthy.
+	AcceptWarning bool `json:"accept_warning" yaml:"accept_warning"`
+	// MinSeverity is the lowest severity of Zabbix problems that render the host unhealthy, from 0 (not classified)
+	// to 5 (disaster).
+	MinSeverity int `json:"min_severity" yaml:"min_severity" validate:"gte=0,lte=5"`
+}
+
+func (c *HealthcheckConfig) UnmarshalYAML(node *yaml.Node) error {
+	var meta struct {
+		Type            string             `yaml:"type"`
+		Timeout         time.Duration      `yaml:"timeout"`
+		Template        string             `yaml:"template"`
+		SourceIp        string             `yaml:"source_ip"`
+		SourceInterface string             `yaml:"source_interface"`
+		Samples         *SamplesConfig     `yaml:"samples"`
+		Passive         *HealthcheckConfig `yaml:"passive"`
+		Precedence      string             `yaml:"precedence"`
+	}
+	if err := node.Decode(&meta); err != nil {
+		return err
+	}
+
+	*c = HealthcheckConfig{
+		Type:            meta.Type,
+		Timeout:         meta.Timeout,
+		Template:        meta.Template,
+		SourceIp:        meta.SourceIp,
+		SourceInterface: meta.SourceInterface,
+		Samples:         meta.Samples,
+		Passive:         meta.Passive,
+		Precedence:      meta.Precedence,
+	}
+	switch meta.Type {
+	case HttpCheckerName:
+		c.Http = &HttpHealthcheckConfig{}
+		return quotedScalars(node, map[string]string{"port": intTag, "use_tls": boolTag}).Decode(c.Http)
+	case IcmpCheckerName:
+		c.Icmp = &IcmpHealthcheckConfig{}
+		return node.Decode(c.Icmp)
+	case TcpCheckerName:
+		c.Tcp = &TcpHealthcheckConfig{}
+		return quotedScalars(node, map[string]string{"port": intTag}).Decode(c.Tcp)
+	case FileCheckerName:
+		c.File = &FileHealthcheckConfig{}
+		return node.Decode(c.File)
+	case SnmpCheckerName:
+		c.Snmp = &SnmpHealthcheckConfig{}
+		return node.Decode(c.Snmp)
+	case MqttCheckerName:
+		c.Mqtt = &MqttHealthcheckConfig{}
+		return node.Decode(c.Mqtt)
+	case ModbusCheckerName:
+		c.Modbus = &ModbusHealthcheckConfig{}
+		return node.Decode(c.Modbus)
+	case LdapCheckerName:
+		c.Ldap = &LdapHealthcheckConfig{}
+		return node.Decode(c.Ldap)
+	case KerberosCheckerName:
+		c.Kerberos = &KerberosHealthcheckConfig{}
+		return node.Decode(c.Kerberos)
+	case SipCheckerName:
+		c.Sip = &SipHealthcheckConfig{}
+		return node.Decode(c.Sip)
+	case RtspCheckerName:
+		c.Rtsp = &RtspHealthcheckConfig{}
+		return node.Decode(c.Rtsp)
+	case MonitoringCheckerName:
+		c.Monitoring = &MonitoringHealthcheckConfig{}
+		return node.Decode(c.Monitoring)
+	case "":
+		return errors.New("no healthchecker type specified")
+	default:
+		return fmt.Errorf("unknown healthchecker type %q", meta.Type)
+	}
+}
+
+const (
+	intTag  = "!!int"
+	boolTag = "!!bool"
+)
+
+// quotedScalars returns a copy of the mapping node whose string values of the given keys are retagged, so configs
+// written for the untyped args of earlier versions, e.g. port: "443" or use_tls: "true", keep loading.
+func quotedScalars(node *yaml.Node, tags map[string]string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return node
+	}
+
+	ret := *node
+	ret.Content = slices.Clone(node.Content)
+	for i := 0; i+1 < len(ret.Content); i += 2 {
+		tag, found := tags[ret.Content[i].Value]
+		value := ret.Content[i+1]
+		if !found || value.Kind != yaml.ScalarNode || value.ShortTag() != "!!str" || !parsesAs(value.Value, tag) {
+			continue
+		}
+		retagged := *value
+		retagged.Tag, retagged.Style = tag, 0
+		ret.Content[i+1] = &retagged
+	}
+	return &ret
+}
+
+func parsesAs(value, tag string) bool {
+	var err error
+	switch tag {
+	case intTag:
+		_, err = strconv.Atoi(value)
+	case boolTag:
+		_, err = strconv.ParseBool(value)
+	}
+	return err == nil
+}
+
+// EffectiveTimeout returns the timeout of a single check, DefaultCheckTimeout if no timeout is configured.
+func (c *HealthcheckConfig) EffectiveTimeout() time.Duration {
+	return cmp.Or(c.Timeout, DefaultCheckTimeout)
+}
+
+// Validate validates the config of the selected healthchecker and returns human-readable errors such as
+// "http.port must be 1-65535".
+func (c *HealthcheckConfig) Validate() error {
+	if err := validate.Struct(c); err != nil {
+		return formatValidationErrors("healthchecker", err)
+	}
+
+	var checkerConf any
+	switch {
+	case c.Type == HttpCheckerName && c.Http != nil:
+		checkerConf = c.Http
+	case c.Type == IcmpCheckerName && c.Icmp != nil:
+		checkerConf = c.Icmp
+	case c.Type == TcpCheckerName && c.Tcp != nil:
+		checkerConf = c.Tcp
+	case c.Type == FileCheckerName && c.File != nil:
+		checkerConf = c.File
+	case c.Type == SnmpCheckerName && c.Snmp != nil:
+		checkerConf = c.Snmp
+	case c.Type == MqttCheckerName && c.Mqtt != nil:
+		checkerConf = c.Mqtt
+	case c.Type == ModbusCheckerName && c.Modbus != nil:
+		checkerConf = c.Modbus
+	case c.Type == LdapCheckerName && c.Ldap != nil:
+		checkerConf = c.Ldap
+	case c.Type == KerberosCheckerName && c.Kerberos != nil:
+		checkerConf = c.Kerberos
+	case c.Type == SipCheckerName && c.Sip != nil:
+		checkerConf = c.Sip
+	case c.Type == RtspCheckerName && c.Rtsp != nil:
+		checkerConf = c.Rtsp
+	case c.Type == MonitoringCheckerName && c.Monitoring != nil:
+		checkerConf = c.Monitoring
+	}
+
+	if checkerConf == nil {
+		return fmt.Errorf("missing %s healthchecker config", c.Type)
+	}
+
+	if err := validate.Struct(checkerConf); err != nil {
+		return formatValidationErrors(c.Type, err)
+	}
+
+	if c.Passive != nil {
+		if c.Passive.Passive != nil {
+			return errors.New("passive healthchecker must not have a passive healthchecker")
+		}
+		if err := c.Passive.Validate(); err != nil {
+			return fmt.Errorf("invalid passive healthchecker: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func formatValidationErrors(prefix string, err error) error {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err
+	}
+
+	errs := make([]error, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		errs = append(errs, fmt.Errorf("%s.%s %s", prefix, fieldErr.Field(), describeFieldError(fieldErr)))
+	}
+
+	return errors.Join(errs...)
+}
+
+func describeFieldError(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "port":
+		return "must be 1-65535"
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s]", strings.ReplaceAll(fieldErr.Param(), " ", ", "))
+	case "gte", "min":
+		return fmt.Sprintf("must be >= %s", fieldErr.Param())
+	case "lte", "max":
+		return fmt.Sprintf("must be <= %s", fieldErr.Param())
+	case "gt":
+		return fmt.Sprintf("must be > %s", fieldErr.Param())
+	case "lt":
+		return fmt.Sprintf("must be < %s", fieldErr.Param())
+	default:
+		return fmt.Sprintf("failed %q validation", fieldErr.Tag())
+	}
+}
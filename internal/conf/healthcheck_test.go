@@ -0,0 +1,163 @@
+package conf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestHealthcheckConfig_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    HealthcheckConfig
+		wantErr bool
+	}{
+		{
+			name: "http with integer port",
+			data: "type: http\nport: 8443\nuse_tls: true",
+			want: HealthcheckConfig{
+				Type: HttpCheckerName,
+				Http: &HttpHealthcheckConfig{Port: 8443, UseTls: true},
+			},
+		},
+		{
+			name: "http with quoted port and use_tls",
+			data: "type: http\nport: \"443\"\nuse_tls: \"true\"",
+			want: HealthcheckConfig{
+				Type: HttpCheckerName,
+				Http: &HttpHealthcheckConfig{Port: 443, UseTls: true},
+			},
+		},
+		{
+			name: "tcp with quoted port",
+			data: "type: tcp\nport: '22'",
+			want: HealthcheckConfig{
+				Type: TcpCheckerName,
+				Tcp:  &TcpHealthcheckConfig{Port: 22},
+			},
+		},
+		{
+			name: "tcp with timeout",
+			data: "type: tcp\nport: 22\ntimeout: 2s",
+			want: HealthcheckConfig{
+				Type:    TcpCheckerName,
+				Timeout: 2 * time.Second,
+				Tcp:     &TcpHealthcheckConfig{Port: 22},
+			},
+		},
+		{
+			name: "icmp",
+			data: "type: icmp",
+			want: HealthcheckConfig{
+				Type: IcmpCheckerName,
+				Icmp: &IcmpHealthcheckConfig{},
+			},
+		},
+		{
+			name: "tcp with passive file",
+			data: "type: tcp\nport: 22\nprecedence: active\npassive:\n  type: file\n  path: /run/maintenance\n  absent: true",
+			want: HealthcheckConfig{
+				Type:       TcpCheckerName,
+				Tcp:        &TcpHealthcheckConfig{Port: 22},
+				Precedence: "active",
+				Passive: &HealthcheckConfig{
+					Type: FileCheckerName,
+					File: &FileHealthcheckConfig{Path: "/run/maintenance", Absent: true},
+				},
+			},
+		},
+		{
+			name:    "port is not a number",
+			data:    "type: tcp\nport: ssh",
+			wantErr: true,
+		},
+		{
+			name:    "quoted port is not a number",
+			data:    "type: tcp\nport: \"ssh\"",
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			data:    "type: gopher",
+			wantErr: true,
+		},
+		{
+			name:    "missing type",
+			data:    "port: 22",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got HealthcheckConfig
+			err := yaml.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalYAML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnmarshalYAML() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthcheckConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    HealthcheckConfig
+		wantErr string
+	}{
+		{
+			name: "valid http",
+			conf: HealthcheckConfig{Type: HttpCheckerName, Http: &HttpHealthcheckConfig{Port: 443}},
+		},
+		{
+			name:    "http port out of range",
+			conf:    HealthcheckConfig{Type: HttpCheckerName, Http: &HttpHealthcheckConfig{Port: 70000}},
+			wantErr: "http.port must be 1-65535",
+		},
+		{
+			name:    "tcp port missing",
+			conf:    HealthcheckConfig{Type: TcpCheckerName, Tcp: &TcpHealthcheckConfig{}},
+			wantErr: "tcp.port is required",
+		},
+		{
+			name:    "invalid passive checker",
+			conf:    HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}, Passive: &HealthcheckConfig{Type: TcpCheckerName, Tcp: &TcpHealthcheckConfig{}}},
+			wantErr: "invalid passive healthchecker: tcp.port is required",
+		},
+		{
+			name:    "precedence without passive checker",
+			conf:    HealthcheckConfig{Type: IcmpCheckerName, Icmp: &IcmpHealthcheckConfig{}, Precedence: "active"},
+			wantErr: "healthchecker.precedence",
+		},
+		{
+			name:    "missing checker config",
+			conf:    HealthcheckConfig{Type: TcpCheckerName},
+			wantErr: "missing tcp healthchecker config",
+		},
+		{
+			name:    "unknown type",
+			conf:    HealthcheckConfig{Type: "gopher"},
+			wantErr: "healthchecker.type must be one of [http, icmp, tcp, file, snmp, mqtt, modbus, ldap, kerberos, sip, rtsp, monitoring]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.conf.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
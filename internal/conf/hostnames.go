@@ -0,0 +1,146 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const hostnameTemplatesKey = "hostname_templates"
+
+var placeholderPattern = regexp.MustCompile(`\{[a-zA-Z0-9_]+\}`)
+
+// hostnameTemplate defines a set of hostnames that share the same records, e.g. "{svc}.ha.example.com" for a list of
+// services. The placeholder is also replaced in all values of the records and settings.
+type hostnameTemplate struct {
+	Hostname string   `yaml:"hostname"`
+	Values   []string `yaml:"values"`
+}
+
+// expandHostnameTemplates removes the hostname_templates section and adds the records and hostname settings of each
+// template once for every value. Expanded hostnames must not be defined elsewhere.
+func expandHostnameTemplates(node *yaml.Node) error {
+	root := node
+	if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	templates := popMappingValue(root, hostnameTemplatesKey)
+	if templates == nil {
+		return nil
+	}
+	if templates.Kind != yaml.SequenceNode {
+		return errors.New("hostname_templates must be a list")
+	}
+
+	var errs []error
+	for _, template := range templates.Content {
+		if err := expandHostnameTemplate(root, template); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", template.Line, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func expandHostnameTemplate(root, template *yaml.Node) error {
+	var meta hostnameTemplate
+	if err := template.Decode(&meta); err != nil {
+		return err
+	}
+
+	placeholders := placeholderPattern.FindAllString(meta.Hostname, -1)
+	if len(slices.Compact(placeholders)) != 1 {
+		return fmt.Errorf("hostname template %q must contain exactly one placeholder such as {svc}", meta.Hostname)
+	}
+	placeholder := placeholders[0]
+
+	if len(meta.Values) == 0 {
+		return fmt.Errorf("hostname template %q has no values", meta.Hostname)
+	}
+
+	records := mappingValue(template, "records")
+	if records == nil || records.Kind != yaml.SequenceNode {
+		return fmt.Errorf("hostname template %q requires a list of records", meta.Hostname)
+	}
+	settings := mappingValue(template, "settings")
+	if settings != nil && settings.Kind != yaml.MappingNode {
+		return fmt.Errorf("settings of hostname template %q must be a mapping", meta.Hostname)
+	}
+
+	for _, value := range meta.Values {
+		hostname := strings.ReplaceAll(meta.Hostname, placeholder, value)
+
+		recordsSection := ensureMapping(root, "records")
+		if mappingValue(recordsSection, hostname) != nil {
+			return fmt.Errorf("hostname %q of template %q is already defined", hostname, meta.Hostname)
+		}
+		recordsSection.Content = append(recordsSection.Content, scalarNode(hostname), substitute(copyNode(records), placeholder, value))
+
+		if settings != nil {
+			hostnamesSection := ensureMapping(root, "hostnames")
+			if mappingValue(hostnamesSection, hostname) != nil {
+				return fmt.Errorf("settings for hostname %q of template %q are already defined", hostname, meta.Hostname)
+			}
+			hostnamesSection.Content = append(hostnamesSection.Content, scalarNode(hostname), substitute(copyNode(settings), placeholder, value))
+		}
+	}
+
+	return nil
+}
+
+// popMappingValue removes the key from the mapping and returns its value or nil if it does not exist.
+func popMappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			value := node.Content[i+1]
+			node.Content = slices.Delete(node.Content, i, i+2)
+			return value
+		}
+	}
+	return nil
+}
+
+// ensureMapping returns the mapping of the key, it's created if it does not exist yet.
+func ensureMapping(node *yaml.Node, key string) *yaml.Node {
+	value := mappingValue(node, key)
+	if value == nil || value.Tag == "!!null" {
+		mapping := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if value != nil {
+			*value = *mapping
+			return value
+		}
+		node.Content = append(node.Content, scalarNode(key), mapping)
+		return mapping
+	}
+	return value
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func copyNode(node *yaml.Node) *yaml.Node {
+	ret := *node
+	ret.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		ret.Content[i] = copyNode(child)
+	}
+	return &ret
+}
+
+func substitute(node *yaml.Node, placeholder, value string) *yaml.Node {
+	if node.Kind == yaml.ScalarNode {
+		node.Value = strings.ReplaceAll(node.Value, placeholder, value)
+	}
+	for _, child := range node.Content {
+		substitute(child, placeholder, value)
+	}
+	return node
+}
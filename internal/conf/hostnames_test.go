@@ -0,0 +1,124 @@
+package conf
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExpandHostnameTemplates(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		wantRecords   string
+		wantHostnames string
+		wantErr       bool
+	}{
+		{
+			name: "expand template",
+			data: `
+hostname_templates:
+  - hostname: "{svc}.ha.tld"
+    values: [api, web]
+    records:
+      - ip: 10.0.0.1
+        healthchecker:
+          type: http
+          path: /{svc}/health
+    settings:
+      on_all_unhealthy: publish_all
+records:
+  other.tld:
+    - ip: 10.0.0.2
+`,
+			wantRecords: `
+other.tld:
+  - ip: 10.0.0.2
+api.ha.tld:
+  - ip: 10.0.0.1
+    healthchecker:
+      type: http
+      path: /api/health
+web.ha.tld:
+  - ip: 10.0.0.1
+    healthchecker:
+      type: http
+      path: /web/health
+`,
+			wantHostnames: `
+api.ha.tld:
+  on_all_unhealthy: publish_all
+web.ha.tld:
+  on_all_unhealthy: publish_all
+`,
+		},
+		{
+			name: "hostname already defined",
+			data: `
+hostname_templates:
+  - hostname: "{svc}.ha.tld"
+    values: [api]
+    records:
+      - ip: 10.0.0.1
+records:
+  api.ha.tld:
+    - ip: 10.0.0.2
+`,
+			wantErr: true,
+		},
+		{
+			name: "no placeholder",
+			data: `
+hostname_templates:
+  - hostname: api.ha.tld
+    values: [api]
+    records:
+      - ip: 10.0.0.1
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var node yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.data), &node); err != nil {
+				t.Fatal(err)
+			}
+
+			err := expandHostnameTemplates(&node)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandHostnameTemplates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var got struct {
+				Records   map[string]any `yaml:"records"`
+				Hostnames map[string]any `yaml:"hostnames"`
+				Templates any            `yaml:"hostname_templates"`
+			}
+			if err := node.Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Templates != nil {
+				t.Errorf("expected hostname_templates to be removed")
+			}
+
+			var wantRecords, wantHostnames map[string]any
+			if err := yaml.Unmarshal([]byte(tt.wantRecords), &wantRecords); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal([]byte(tt.wantHostnames), &wantHostnames); err != nil {
+				t.Fatal(err)
+			}
+
+			if !equalYaml(got.Records, wantRecords) {
+				t.Errorf("records = %v, want %v", got.Records, wantRecords)
+			}
+			if !equalYaml(got.Hostnames, wantHostnames) {
+				t.Errorf("hostnames = %v, want %v", got.Hostnames, wantHostnames)
+			}
+		})
+	}
+}
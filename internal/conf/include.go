@@ -0,0 +1,229 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+const includeKey = "include"
+
+// loadNode reads the config at path and returns its top-level mapping. Directories are read as the merge of all
+// yaml, json and toml files they contain in lexical order. Files may reference further fragments using the top-level
+// include key, which accepts a single path or a list of paths and globs relative to the including file.
+func loadNode(path string) (*yaml.Node, error) {
+	return loadPath(path, map[string]bool{})
+}
+
+func loadPath(path string, visiting map[string]bool) (*yaml.Node, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return loadFile(path, visiting)
+	}
+
+	files, err := configFragments(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadFiles(files, visiting)
+}
+
+// configFragments returns the config files in the directory sorted lexically.
+func configFragments(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !isConfigFile(entry.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no config files found in directory %q", dir)
+	}
+
+	slices.Sort(files)
+	return files, nil
+}
+
+func loadFiles(files []string, visiting map[string]bool) (*yaml.Node, error) {
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, file := range files {
+		node, err := loadPath(file, visiting)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := mergeNodes(merged, node, ""); err != nil {
+			return nil, fmt.Errorf("could not merge %q: %w", file, err)
+		}
+	}
+	return merged, nil
+}
+
+func loadFile(filePath string, visiting map[string]bool) (*yaml.Node, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if visiting[absPath] {
+		return nil, fmt.Errorf("include cycle detected at %q", filePath)
+	}
+	visiting[absPath] = true
+	defer delete(visiting, absPath)
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := parseConfig(filePath, data, true)
+	if err != nil {
+		return nil, err
+	}
+
+	includes, err := popIncludes(node)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", filePath, err)
+	}
+
+	for _, pattern := range includes {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(filePath), pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%q: invalid include pattern %q: %w", filePath, pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%q: include %q does not match any file", filePath, pattern)
+		}
+
+		included, err := loadFiles(matches, visiting)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := mergeNodes(node, included, ""); err != nil {
+			return nil, fmt.Errorf("could not merge %q into %q: %w", pattern, filePath, err)
+		}
+	}
+
+	return node, nil
+}
+
+// parseConfig parses the data in the format detected by the location and decrypts it if it is SOPS-encrypted. It
+// returns the top-level mapping of the document.
+func parseConfig(location string, data []byte, isLocalFile bool) (*yaml.Node, error) {
+	format := formatFromPath(location)
+	doc, err := parseDocument(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %q: %w", location, err)
+	}
+
+	if isSopsEncrypted(doc) {
+		if isLocalFile {
+			data, err = decryptSops(location, format)
+		} else {
+			data, err = decryptSopsData(data, format)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		doc, err = parseDocument(data, format)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse decrypted %q: %w", location, err)
+		}
+	}
+
+	node := doc
+	if doc.Kind == yaml.DocumentNode || doc.Kind == 0 {
+		node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if len(doc.Content) > 0 {
+			node = doc.Content[0]
+		}
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%q does not contain a mapping", location)
+	}
+
+	return node, nil
+}
+
+// popIncludes removes the include key from the mapping and returns its paths.
+func popIncludes(node *yaml.Node) ([]string, error) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != includeKey {
+			continue
+		}
+
+		value := node.Content[i+1]
+		node.Content = slices.Delete(node.Content, i, i+2)
+
+		var includes []string
+		switch value.Kind {
+		case yaml.ScalarNode:
+			includes = []string{value.Value}
+		case yaml.SequenceNode:
+			if err := value.Decode(&includes); err != nil {
+				return nil, fmt.Errorf("invalid include: %w", err)
+			}
+		default:
+			return nil, errors.New("include must be a path or a list of paths")
+		}
+		return includes, nil
+	}
+	return nil, nil
+}
+
+// mergeNodes merges the src mapping into dst. Nested mappings are merged recursively, all other values may only be
+// defined once across all fragments.
+func mergeNodes(dst, src *yaml.Node, path string) error {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		keyPath := key.Value
+		if path != "" {
+			keyPath = path + "." + key.Value
+		}
+
+		existing := mappingValue(dst, key.Value)
+		switch {
+		case existing == nil:
+			dst.Content = append(dst.Content, key, value)
+		case existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			if err := mergeNodes(existing, value, keyPath); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%q is defined more than once (line %d)", keyPath, key.Line)
+		}
+	}
+	return nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
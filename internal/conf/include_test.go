@@ -0,0 +1,98 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadNode(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		path    string
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name: "directory",
+			files: map[string]string{
+				"00-base.yaml": "check_interval: 10s\nrecords:\n  a.tld: [1]\n",
+				"10-web.yml":   "records:\n  b.tld: [2]\n",
+				"README.md":    "ignored",
+			},
+			path: ".",
+			want: map[string]any{"check_interval": "10s", "records": map[string]any{"a.tld": []any{1}, "b.tld": []any{2}}},
+		},
+		{
+			name: "include key with glob",
+			files: map[string]string{
+				"config.yaml":     "include:\n  - conf.d/*.yaml\nrecords:\n  a.tld: [1]\n",
+				"conf.d/web.yaml": "records:\n  b.tld: [2]\n",
+			},
+			path: "config.yaml",
+			want: map[string]any{"records": map[string]any{"a.tld": []any{1}, "b.tld": []any{2}}},
+		},
+		{
+			name: "hostname defined twice",
+			files: map[string]string{
+				"a.yaml": "records:\n  a.tld: [1]\n",
+				"b.yaml": "records:\n  a.tld: [2]\n",
+			},
+			path:    ".",
+			wantErr: true,
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"a.yaml": "include: b.yaml\n",
+				"b.yaml": "include: a.yaml\n",
+			},
+			path:    "a.yaml",
+			wantErr: true,
+		},
+		{
+			name: "include without match",
+			files: map[string]string{
+				"config.yaml": "include: conf.d/*.yaml\n",
+			},
+			path:    "config.yaml",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			node, err := loadNode(filepath.Join(dir, tt.path))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadNode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var got map[string]any
+			if err := node.Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if !equalYaml(got, tt.want) {
+				t.Errorf("loadNode() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
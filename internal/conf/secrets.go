@@ -0,0 +1,208 @@
+package conf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/vault"
+	"go.uber.org/multierr"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	fileReferencePrefix = "file://"
+	envReferencePrefix  = "env://"
+
+	vaultTimeout = 30 * time.Second
+)
+
+// envVarPattern matches "${VAR}" and its escaped form "$${VAR}".
+var envVarPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// resolveReferences walks all scalar values of the yaml document and replaces references to secrets, so secrets
+// don't need to be stored in the config file:
+//   - "${VAR}" is replaced by the value of the environment variable VAR, "$${VAR}" is replaced by a literal "${VAR}"
+//   - "env://VAR" is replaced by the value of the environment variable VAR
+//   - "file:///path/to/secret" is replaced by the content of the file, without trailing newlines
+//
+// Variables in hook commands are left to the shell that runs them, which inherits the environment and additionally
+// receives the variables describing the event.
+//
+// References to secrets stored in Vault are resolved separately by resolveVaultReferences.
+func resolveReferences(node *yaml.Node) error {
+	hooks := topLevelValue(node, "hooks")
+	return multierr.Combine(
+		walkValuesSkipping(node, hooks, func(value string) (string, error) {
+			return resolveReference(value, true)
+		}),
+		walkValues(hooks, func(value string) (string, error) {
+			return resolveReference(value, false)
+		}),
+	)
+}
+
+// walkValues replaces all string values of the yaml document by the result of the resolve function.
+func walkValues(node *yaml.Node, resolve func(string) (string, error)) error {
+	return walkValuesSkipping(node, nil, resolve)
+}
+
+// walkValuesSkipping replaces all string values of the yaml document except the ones below skip by the result of the
+// resolve function.
+func walkValuesSkipping(node, skip *yaml.Node, resolve func(string) (string, error)) error {
+	if node == nil || node == skip {
+		return nil
+	}
+
+	var errs error
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			errs = multierr.Append(errs, walkValuesSkipping(child, skip, resolve))
+		}
+	case yaml.MappingNode:
+		// only resolve values, keys are never secrets
+		for i := 1; i < len(node.Content); i += 2 {
+			errs = multierr.Append(errs, walkValuesSkipping(node.Content[i], skip, resolve))
+		}
+	case yaml.ScalarNode:
+		if node.Tag != "!!str" {
+			return nil
+		}
+		resolved, err := resolve(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		node.Value = resolved
+	}
+
+	return errs
+}
+
+// hasValueWithPrefix returns true if any string value of the yaml document starts with the prefix.
+func hasValueWithPrefix(node *yaml.Node, prefix string) bool {
+	found := false
+	_ = walkValues(node, func(value string) (string, error) {
+		found = found || strings.HasPrefix(value, prefix)
+		return value, nil
+	})
+	return found
+}
+
+// topLevelValue returns the value node of the given top-level key of the document or nil if it does not exist.
+func topLevelValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	return mappingValue(node, key)
+}
+
+// resolveVaultReferences replaces all "vault:<mount>/<path>#<key>" values by the secrets read from Vault. The
+// Vault client is configured by the top-level vault section of the document.
+func resolveVaultReferences(node *yaml.Node) (*vault.Client, error) {
+	if !hasValueWithPrefix(node, vault.ReferencePrefix) {
+		return nil, nil
+	}
+
+	vaultNode := topLevelValue(node, "vault")
+	if vaultNode == nil {
+		return nil, errors.New("config contains vault references but no vault section")
+	}
+
+	var vaultConf vault.Config
+	if err := vaultNode.Decode(&vaultConf); err != nil {
+		return nil, fmt.Errorf("could not decode vault config: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), vaultTimeout)
+	defer cancel()
+
+	client, err := vaultClient(ctx, vaultConf)
+	if err != nil {
+		return nil, err
+	}
+
+	err = walkValues(node, func(value string) (string, error) {
+		if !strings.HasPrefix(value, vault.ReferencePrefix) {
+			return value, nil
+		}
+		return client.Resolve(ctx, value)
+	})
+	return client, err
+}
+
+// vaultClients holds the client built by the latest read of the config, so reading the config again, e.g. when
+// polling it or refreshing secrets, does not log in again.
+var vaultClients struct {
+	mutex  sync.Mutex
+	conf   vault.Config
+	client *vault.Client
+}
+
+// vaultClient returns the client of the latest read of the config if its vault config is unchanged or a new client.
+func vaultClient(ctx context.Context, vaultConf vault.Config) (*vault.Client, error) {
+	vaultClients.mutex.Lock()
+	defer vaultClients.mutex.Unlock()
+
+	if vaultClients.client != nil && reflect.DeepEqual(vaultClients.conf, vaultConf) {
+		return vaultClients.client, nil
+	}
+
+	client, err := vault.NewClient(ctx, vaultConf)
+	if err != nil {
+		return nil, err
+	}
+	vaultClients.conf = vaultConf
+	vaultClients.client = client
+	return client, nil
+}
+
+// resolveReference resolves a reference to a secret, "${VAR}" within the value is only expanded if interpolate is set.
+func resolveReference(value string, interpolate bool) (string, error) {
+	switch {
+	case strings.HasPrefix(value, fileReferencePrefix):
+		path := strings.TrimPrefix(value, fileReferencePrefix)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("could not read secret from file %q: %w", path, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	case strings.HasPrefix(value, envReferencePrefix):
+		name := strings.TrimPrefix(value, envReferencePrefix)
+		val, found := os.LookupEnv(name)
+		if !found {
+			return "", fmt.Errorf("environment variable %q is not set", name)
+		}
+		return val, nil
+	}
+
+	if !interpolate {
+		return value, nil
+	}
+
+	var errs error
+	expanded := envVarPattern.ReplaceAllStringFunc(value, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		name := envVarPattern.FindStringSubmatch(match)[1]
+		val, found := os.LookupEnv(name)
+		if !found {
+			errs = multierr.Append(errs, fmt.Errorf("environment variable %q is not set", name))
+		}
+		return val
+	})
+
+	return expanded, errs
+}
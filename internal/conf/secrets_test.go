@@ -0,0 +1,130 @@
+package conf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/vault"
+	"gopkg.in/yaml.v3"
+)
+
+func TestResolveReferences(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DNS_HA_TEST_TOKEN", "token")
+
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name: "all kinds of references",
+			data: "a: ${DNS_HA_TEST_TOKEN}\nb: env://DNS_HA_TEST_TOKEN\nc: file://" + secretFile + "\nd: prefix-${DNS_HA_TEST_TOKEN}\ne: 5\n",
+			want: map[string]any{"a": "token", "b": "token", "c": "s3cr3t", "d": "prefix-token", "e": 5},
+		},
+		{
+			name: "values in sequences",
+			data: "a:\n  - ${DNS_HA_TEST_TOKEN}\n",
+			want: map[string]any{"a": []any{"token"}},
+		},
+		{
+			name: "escaped env var",
+			data: "a: $${DNS_HA_TEST_MISSING}\nb: $${DNS_HA_TEST_TOKEN}-${DNS_HA_TEST_TOKEN}\n",
+			want: map[string]any{"a": "${DNS_HA_TEST_MISSING}", "b": "${DNS_HA_TEST_TOKEN}-token"},
+		},
+		{
+			name: "hook commands are left to the shell",
+			data: "a: ${DNS_HA_TEST_TOKEN}\nhooks:\n  post_update:\n    - echo ${DNS_HA_EVENT} ${DNS_HA_TEST_MISSING}\n  outage:\n    - env://DNS_HA_TEST_TOKEN\n",
+			want: map[string]any{
+				"a": "token",
+				"hooks": map[string]any{
+					"post_update": []any{"echo ${DNS_HA_EVENT} ${DNS_HA_TEST_MISSING}"},
+					"outage":      []any{"token"},
+				},
+			},
+		},
+		{
+			name:    "missing env var",
+			data:    "a: ${DNS_HA_TEST_MISSING}\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			data:    "a: file:///does/not/exist\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var node yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.data), &node); err != nil {
+				t.Fatal(err)
+			}
+
+			err := resolveReferences(&node)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveReferences() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var got map[string]any
+			if err := node.Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if !equalYaml(got, tt.want) {
+				t.Errorf("resolveReferences() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func equalYaml(a, b any) bool {
+	x, _ := yaml.Marshal(a)
+	y, _ := yaml.Marshal(b)
+	return string(x) == string(y)
+}
+
+func TestResolveVaultReferences_reusesClient(t *testing.T) {
+	var logins atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/auth/approle/login", func(w http.ResponseWriter, r *http.Request) {
+		logins.Add(1)
+		_, _ = w.Write([]byte(`{"auth": {"client_token": "token", "lease_duration": 3600, "renewable": true}}`))
+	})
+	mux.HandleFunc("GET /v1/secret/data/dns-ha", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": {"data": {"token": "s3cr3t"}}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	data := "token: vault:secret/dns-ha#token\nvault:\n  address: " + server.URL + "\n  auth:\n    method: approle\n    role_id: role\n    secret_id: secret\n"
+	var clients []*vault.Client
+	for range 2 {
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(data), &node); err != nil {
+			t.Fatal(err)
+		}
+		client, err := resolveVaultReferences(&node)
+		if err != nil {
+			t.Fatalf("resolveVaultReferences() error = %v", err)
+		}
+		if got := topLevelValue(&node, "token").Value; got != "s3cr3t" {
+			t.Errorf("resolveVaultReferences() got = %q, want %q", got, "s3cr3t")
+		}
+		clients = append(clients, client)
+	}
+
+	if clients[0] != clients[1] || logins.Load() != 1 {
+		t.Errorf("expected reading the config again to reuse the client, got %d logins", logins.Load())
+	}
+}
@@ -0,0 +1,73 @@
+package conf
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const sopsMetadataKey = "sops"
+
+// SopsBinary is the binary used to decrypt SOPS-encrypted config files. Decryption keys (age, GPG, KMS, ...) are
+// picked up by sops itself, e.g. via SOPS_AGE_KEY_FILE.
+var SopsBinary = "sops"
+
+// isSopsEncrypted detects SOPS-encrypted documents by the presence of the top-level sops metadata key.
+func isSopsEncrypted(node *yaml.Node) bool {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return false
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == sopsMetadataKey {
+			return true
+		}
+	}
+	return false
+}
+
+func decryptSops(filePath, format string) ([]byte, error) {
+	if format == formatToml {
+		return nil, fmt.Errorf("could not decrypt %q: sops does not support toml", filePath)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(SopsBinary, "--decrypt", "--input-type", format, "--output-type", format, filePath) //nolint G204
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("could not decrypt sops file %q: %w: %s", filePath, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// decryptSopsData decrypts content that has not been read from a local file, e.g. a remote config.
+func decryptSopsData(data []byte, format string) ([]byte, error) {
+	tmp, err := os.CreateTemp("", "dns-ha-sops-*."+format)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not write temporary file: %w", err)
+	}
+
+	return decryptSops(tmp.Name(), format)
+}
@@ -0,0 +1,50 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadFromFile_Sops(t *testing.T) {
+	dir := t.TempDir()
+	encrypted := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(encrypted, []byte("metrics_addr: ENC[AES256_GCM,data:abc]\nsops:\n  version: 3.9.0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fakeSops := filepath.Join(dir, "sops")
+	if err := os.WriteFile(fakeSops, []byte("#!/bin/sh\necho 'metrics_addr: 127.0.0.1:1234'\n"), 0700); err != nil { //nolint G306
+		t.Fatal(err)
+	}
+
+	defer func(binary string) {
+		SopsBinary = binary
+	}(SopsBinary)
+	SopsBinary = fakeSops
+
+	conf, err := ReadFromFile(encrypted)
+	if err != nil {
+		t.Fatalf("ReadFromFile() unexpected error = %v", err)
+	}
+
+	if conf.MetricsAddr != "127.0.0.1:1234" {
+		t.Errorf("expected decrypted metrics_addr, got %q", conf.MetricsAddr)
+	}
+}
+
+func TestReadFromFile_Plain(t *testing.T) {
+	defer func(binary string) {
+		SopsBinary = binary
+	}(SopsBinary)
+	SopsBinary = "/does/not/exist"
+
+	conf, err := ReadFromFile("../../contrib/config.yaml")
+	if err != nil {
+		t.Fatalf("ReadFromFile() unexpected error = %v", err)
+	}
+
+	if len(conf.Records) != 1 {
+		t.Errorf("expected 1 hostname, got %d", len(conf.Records))
+	}
+}
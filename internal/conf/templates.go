@@ -0,0 +1,92 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	healthcheckTemplatesKey = "healthcheck_templates"
+	templateKey             = "template"
+	passiveKey              = "passive"
+)
+
+// resolveHealthcheckTemplates merges the named templates of the healthcheck_templates section into all healthcheckers
+// referencing them by their template key. Values defined by the healthchecker override the template's values.
+func resolveHealthcheckTemplates(node *yaml.Node) error {
+	templates := topLevelValue(node, healthcheckTemplatesKey)
+	if templates != nil && templates.Kind != yaml.MappingNode {
+		return errors.New("healthcheck_templates must be a mapping")
+	}
+
+	var checkers []*yaml.Node
+	if defaults := topLevelValue(node, defaultsKey); defaults != nil && defaults.Kind == yaml.MappingNode {
+		if checker := mappingValue(defaults, "healthchecker"); checker != nil {
+			checkers = append(checkers, checker)
+		}
+	}
+
+	if records := topLevelValue(node, "records"); records != nil && records.Kind == yaml.MappingNode {
+		for i := 1; i < len(records.Content); i += 2 {
+			for _, record := range records.Content[i].Content {
+				if record.Kind != yaml.MappingNode {
+					continue
+				}
+				if checker := mappingValue(record, "healthchecker"); checker != nil {
+					checkers = append(checkers, checker)
+				}
+			}
+		}
+	}
+
+	if groups := topLevelValue(node, "health_groups"); groups != nil && groups.Kind == yaml.MappingNode {
+		for i := 1; i < len(groups.Content); i += 2 {
+			if checker := mappingValue(groups.Content[i], "healthchecker"); checker != nil {
+				checkers = append(checkers, checker)
+			}
+		}
+	}
+
+	var errs []error
+	// the list grows while iterating, passive healthcheckers may reference templates as well
+	for i := 0; i < len(checkers); i++ {
+		checker := checkers[i]
+		if checker.Kind != yaml.MappingNode {
+			continue
+		}
+
+		if err := mergeHealthcheckTemplate(checker, templates); err != nil {
+			errs = append(errs, err)
+		}
+		if passive := mappingValue(checker, passiveKey); passive != nil {
+			checkers = append(checkers, passive)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// mergeHealthcheckTemplate merges the template referenced by the healthchecker into it, if any.
+func mergeHealthcheckTemplate(checker, templates *yaml.Node) error {
+	name := mappingValue(checker, templateKey)
+	if name == nil {
+		return nil
+	}
+
+	var template *yaml.Node
+	if templates != nil {
+		template = mappingValue(templates, name.Value)
+	}
+	if template == nil || template.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: unknown healthcheck template %q", name.Line, name.Value)
+	}
+
+	if !sameHealthcheckType(checker, template) {
+		return fmt.Errorf("line %d: healthchecker type does not match type of template %q", name.Line, name.Value)
+	}
+
+	mergeDefaults(checker, template)
+	return nil
+}
@@ -0,0 +1,121 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadFromFile_HealthcheckTemplates(t *testing.T) {
+	const records = `
+records:
+  host.my.tld:
+    - ip: 10.0.0.1
+      type: A
+      prio: 250
+      ttl: 60
+      healthchecker:
+        template: web
+    - ip: 10.0.0.2
+      type: A
+      prio: 200
+      ttl: 60
+      healthchecker:
+        template: web
+        port: 8443
+`
+	tests := []struct {
+		name          string
+		templates     string
+		wantPorts     []int
+		wantErr       bool
+		wantValidErrs int
+	}{
+		{
+			name:      "template with override",
+			templates: "healthcheck_templates:\n  web:\n    type: http\n    port: 443\n    use_tls: true\n",
+			wantPorts: []int{443, 8443},
+		},
+		{
+			name:          "invalid template is reported once",
+			templates:     "healthcheck_templates:\n  web:\n    type: http\n    port: 70000\n",
+			wantPorts:     []int{70000, 8443},
+			wantValidErrs: 1,
+		},
+		{
+			name:      "unknown template",
+			templates: "healthcheck_templates:\n  ssh:\n    type: tcp\n    port: 22\n",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.templates+records), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			conf, err := ReadFromFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadFromFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			for i, record := range conf.Records["host.my.tld"] {
+				checker := record.HealthcheckConfig
+				if checker.Http == nil || checker.Http.Port != tt.wantPorts[i] || checker.Template != "web" {
+					t.Errorf("unexpected healthchecker %+v", checker)
+				}
+			}
+
+			err = conf.Validate()
+			gotValidErrs := 0
+			if err != nil {
+				gotValidErrs = strings.Count(err.Error(), "http.port must be 1-65535")
+			}
+			if gotValidErrs != tt.wantValidErrs {
+				t.Errorf("Validate() error = %v, want %d port errors", err, tt.wantValidErrs)
+			}
+		})
+	}
+}
+
+func TestReadFromFile_PassiveHealthcheckTemplates(t *testing.T) {
+	const config = `
+healthcheck_templates:
+  kuma:
+    type: monitoring
+    system: uptime_kuma
+    url: https://kuma.my.tld
+    status_page: infra
+records:
+  host.my.tld:
+    - ip: 10.0.0.1
+      type: A
+      prio: 250
+      ttl: 60
+      healthchecker:
+        type: tcp
+        port: 443
+        passive:
+          template: kuma
+          host: web1
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := ReadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passive := conf.Records["host.my.tld"][0].HealthcheckConfig.Passive
+	if passive == nil || passive.Monitoring == nil || passive.Monitoring.StatusPage != "infra" || passive.Monitoring.Host != "web1" {
+		t.Errorf("unexpected passive healthchecker %+v", passive)
+	}
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+)
+
+// DecisionEngine selects the published records of a hostname outside of dns-ha, e.g. a service that centralizes the
+// failover policy of an organization. dns-ha keeps checking the records and enforces the selection.
+type DecisionEngine interface {
+	// Decide returns the addresses of the records to publish, no addresses mean none of the records is fit to be
+	// published and the hostname is treated like a hostname without healthy records. The records of all hostnames are
+	// published one after another, so Decide needs to bound its calls, e.g. by a timeout.
+	Decide(ctx context.Context, request DecisionRequest) ([]string, error)
+}
+
+// DecisionRequest holds the state of all records of a hostname.
+type DecisionRequest struct {
+	Hostname  string           `json:"hostname"`
+	Published []string         `json:"published"`
+	Records   []DecisionRecord `json:"records"`
+}
+
+// DecisionRecord is the state of a single record along with the result of its latest check.
+type DecisionRecord struct {
+	RecordStatus
+	LastResult *CheckResult `json:"last_result,omitempty"`
+}
+
+// selectRecords returns the records selected by the decision engine of the hostname, or by its strategy if it has no
+// engine. It returns false if the engine failed and the published records are to be kept.
+func (h *RecordManager) selectRecords(ctx context.Context, hostname string, ips []*ManagedDnsRecord) ([]ManagedDnsRecord, bool) {
+	policy := h.hostnamePolicies[hostname]
+	if policy.DecisionEngine == nil {
+		return filterHealthyIps(hostname, ips, h.strategy(hostname)), true
+	}
+
+	selected, err := h.decide(ctx, hostname, policy.DecisionEngine, ips)
+	if err == nil {
+		return selected, true
+	}
+
+	metrics.Errors.WithLabelValues(hostname, "decision", ErrorKind(err)).Inc()
+	if policy.DecisionFallback {
+		slog.Warn("Decision engine failed, selecting records using the strategy", "hostname", hostname, "err", err)
+		return filterHealthyIps(hostname, ips, h.strategy(hostname)), true
+	}
+	slog.Warn("Decision engine failed, keeping the published records", "hostname", hostname, "err", err)
+	return nil, false
+}
+
+// decide asks the engine for the records to publish. Addresses that are not configured for the hostname invalidate the
+// whole decision.
+func (h *RecordManager) decide(ctx context.Context, hostname string, engine DecisionEngine, ips []*ManagedDnsRecord) ([]ManagedDnsRecord, error) {
+	request := DecisionRequest{Hostname: hostname, Published: h.publishedIps[hostname]}
+	for _, ip := range ips {
+		record := DecisionRecord{RecordStatus: ip.Status()}
+		if history := ip.History(); len(history) > 0 {
+			record.LastResult = &history[len(history)-1]
+		}
+		request.Records = append(request.Records, record)
+	}
+
+	addresses, err := engine.Decide(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]ManagedDnsRecord, 0, len(addresses))
+	activeIps := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		index := slices.IndexFunc(ips, func(record *ManagedDnsRecord) bool {
+			return record.Ip.Equal(net.ParseIP(address))
+		})
+		if index < 0 {
+			return nil, fmt.Errorf("decision contains unknown address %q", address)
+		}
+		if !activeIps[ips[index].Ip.String()] {
+			activeIps[ips[index].Ip.String()] = true
+			selected = append(selected, *ips[index])
+		}
+	}
+
+	updateMetrics(hostname, ips, activeIps)
+	slices.SortStableFunc(selected, PriorityComparator)
+	return selected, nil
+}
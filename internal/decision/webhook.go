@@ -0,0 +1,117 @@
+// Package decision implements decision engines that select the published records of hostnames outside of dns-ha.
+package decision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+)
+
+const (
+	defaultTimeout  = 5 * time.Second
+	maxResponseSize = 1024 * 1024
+	maxErrorSize    = 1024
+)
+
+// Webhook posts the state of the records of a hostname to a policy endpoint and publishes the addresses it responds
+// with, e.g. {"publish": ["192.0.2.1"]}.
+type Webhook struct {
+	url     string
+	client  *http.Client
+	headers map[string]string
+}
+
+type WebhookOpts func(*Webhook) error
+
+// WithHeaders sets headers that are sent along with each request, e.g. to authenticate.
+func WithHeaders(headers map[string]string) WebhookOpts {
+	return func(w *Webhook) error {
+		for name := range headers {
+			if name == "" {
+				return errors.New("empty header name supplied")
+			}
+		}
+		w.headers = headers
+		return nil
+	}
+}
+
+// WithTimeout bounds each request.
+func WithTimeout(timeout time.Duration) WebhookOpts {
+	return func(w *Webhook) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		w.client.Timeout = timeout
+		return nil
+	}
+}
+
+func NewWebhook(url string, opts ...WebhookOpts) (*Webhook, error) {
+	if url == "" {
+		return nil, errors.New("empty url supplied")
+	}
+
+	ret := &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: defaultTimeout},
+	}
+
+	var errs []error
+	for _, opt := range opts {
+		if err := opt(ret); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return ret, errors.Join(errs...)
+}
+
+func (w *Webhook) Decide(ctx context.Context, request internal.DecisionRequest) ([]string, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorSize))
+		return nil, fmt.Errorf("decision endpoint returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	// an empty list is a valid decision, a missing list is not
+	var decision struct {
+		Publish *[]string `json:"publish"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&decision); err != nil {
+		return nil, fmt.Errorf("malformed decision: %w", err)
+	}
+	if decision.Publish == nil {
+		return nil, errors.New("decision lacks the addresses to publish")
+	}
+	return *decision.Publish, nil
+}
@@ -0,0 +1,89 @@
+package decision
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal"
+)
+
+func TestWebhook_Decide(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "addresses",
+			status:   http.StatusOK,
+			response: `{"publish": ["10.0.0.2"]}`,
+			want:     []string{"10.0.0.2"},
+		},
+		{
+			name:     "no addresses",
+			status:   http.StatusOK,
+			response: `{"publish": []}`,
+			want:     []string{},
+		},
+		{
+			name:     "missing addresses",
+			status:   http.StatusOK,
+			response: `{}`,
+			wantErr:  true,
+		},
+		{
+			name:     "malformed",
+			status:   http.StatusOK,
+			response: `publish`,
+			wantErr:  true,
+		},
+		{
+			name:     "error status",
+			status:   http.StatusInternalServerError,
+			response: `{"publish": ["10.0.0.2"]}`,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received internal.DecisionRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_ = json.NewDecoder(r.Body).Decode(&received)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			webhook, err := NewWebhook(server.URL, WithHeaders(map[string]string{"Authorization": "Bearer secret"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request := internal.DecisionRequest{
+				Hostname:  "my.tld",
+				Published: []string{"10.0.0.1"},
+				Records:   []internal.DecisionRecord{{RecordStatus: internal.RecordStatus{Ip: "10.0.0.1", Status: "unhealthy"}}},
+			}
+			got, err := webhook.Decide(context.Background(), request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decide() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decide() = %v, want %v", got, tt.want)
+			}
+			if received.Hostname != "my.tld" || len(received.Records) != 1 || received.Records[0].Status != "unhealthy" {
+				t.Errorf("received %v", received)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/schedule"
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+type dummyDecisionEngine struct {
+	publish  []string
+	err      error
+	requests []DecisionRequest
+}
+
+func (d *dummyDecisionEngine) Decide(_ context.Context, request DecisionRequest) ([]string, error) {
+	d.requests = append(d.requests, request)
+	return d.publish, d.err
+}
+
+func TestRecordManager_decisionEngine(t *testing.T) {
+	tests := []struct {
+		name      string
+		publish   []string
+		err       error
+		fallback  bool
+		published string
+		want      []string
+	}{
+		{
+			name:    "engine overrides priority",
+			publish: []string{"10.0.0.2"},
+			want:    []string{"A 10.0.0.2"},
+		},
+		{
+			name:    "unhealthy record selected",
+			publish: []string{"10.0.0.3", "10.0.0.2"},
+			want:    []string{"A 10.0.0.2", "A 10.0.0.3"},
+		},
+		{
+			name:    "unknown address keeps published records",
+			publish: []string{"10.0.0.9"},
+			want:    []string{"A 10.0.0.1"},
+		},
+		{
+			name:      "error keeps published records",
+			err:       errors.New("unavailable"),
+			published: "10.0.0.2",
+			want:      []string{"A 10.0.0.2"},
+		},
+		{
+			name:      "error falls back to strategy",
+			err:       errors.New("unavailable"),
+			fallback:  true,
+			published: "10.0.0.2",
+			want:      []string{"A 10.0.0.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := []*ManagedDnsRecord{
+				{DnsRecord: DnsRecord{Priority: 30, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, Hostname: "my.tld", status: &status.Healthy{}},
+				{DnsRecord: DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.2"), Ttl: 60}, Hostname: "my.tld", status: &status.Healthy{}},
+				{DnsRecord: DnsRecord{Priority: 10, DnsType: "A", Ip: net.ParseIP("10.0.0.3"), Ttl: 60}, Hostname: "my.tld", status: &status.Unhealthy{}},
+			}
+			engine := &dummyDecisionEngine{publish: tt.publish, err: tt.err}
+			db := &dummyDnsDb{}
+			m, err := NewRecordManager(db, &dummyService{}, map[string][]*ManagedDnsRecord{"my.tld": records},
+				WithHostnamePolicies(map[string]HostnamePolicy{"my.tld": {DecisionEngine: engine, DecisionFallback: tt.fallback}}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			published := cmp.Or(tt.published, "10.0.0.1")
+			m.publishedIps["my.tld"] = []string{published}
+
+			m.applyRecords(context.Background())
+			if !reflect.DeepEqual(db.updates["my.tld"], tt.want) {
+				t.Errorf("published %v, want %v", db.updates["my.tld"], tt.want)
+			}
+
+			if len(engine.requests) != 1 || !reflect.DeepEqual(engine.requests[0].Published, []string{published}) || len(engine.requests[0].Records) != 3 {
+				t.Errorf("unexpected requests %v", engine.requests)
+			}
+		})
+	}
+}
+
+func TestRecordManager_decisionEngine_frozen(t *testing.T) {
+	always, err := schedule.NewWindow("* * * * *", time.Minute, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []*ManagedDnsRecord{
+		{DnsRecord: DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, Hostname: "my.tld", status: &status.Unhealthy{}},
+		{DnsRecord: DnsRecord{Priority: 10, DnsType: "A", Ip: net.ParseIP("10.0.0.2"), Ttl: 60}, Hostname: "my.tld", status: &status.Healthy{}},
+	}
+	engine := &dummyDecisionEngine{publish: []string{"10.0.0.2"}}
+	db := &dummyDnsDb{}
+	m, err := NewRecordManager(db, &dummyService{}, map[string][]*ManagedDnsRecord{"my.tld": records},
+		WithHostnamePolicies(map[string]HostnamePolicy{"my.tld": {DecisionEngine: engine, Blackouts: []*schedule.Window{always}}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.publishedIps["my.tld"] = []string{"10.0.0.1"}
+
+	m.applyRecords(context.Background())
+	if len(engine.requests) != 0 {
+		t.Errorf("expected the engine not to be asked during the blackout, got %v", engine.requests)
+	}
+	if got := db.updates["my.tld"]; len(got) != 0 && !reflect.DeepEqual(got, []string{"A 10.0.0.1"}) {
+		t.Errorf("expected the published records to be kept, got %v", got)
+	}
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+)
+
+// sniCheckers send the hostname along with their probes, e.g. via SNI, so their results depend on the hostname.
+var sniCheckers = []string{conf.HttpCheckerName, conf.MqttCheckerName, conf.LdapCheckerName, conf.SipCheckerName}
+
+type checkCacheKey struct{}
+
+// checkCache shares the results of identical healthchecks, so an address that is configured for several hostnames
+// with the same checker is only probed once per interval. The check loops of the hostnames share a single cache.
+type checkCache struct {
+	mutex   sync.Mutex
+	results map[string]*checkCall
+}
+
+type checkCall struct {
+	started time.Time
+	done    chan struct{}
+	healthy bool
+	err     error
+}
+
+// reusable returns true if the call is still running or if it started less than maxAge ago.
+func (c *checkCall) reusable(now time.Time, maxAge time.Duration) bool {
+	select {
+	case <-c.done:
+		return now.Sub(c.started) < maxAge
+	default:
+		return true
+	}
+}
+
+// checkScope is the cache along with the maximum age of the results a check may reuse.
+type checkScope struct {
+	cache  *checkCache
+	maxAge time.Duration
+}
+
+func newCheckCache() *checkCache {
+	return &checkCache{results: map[string]*checkCall{}}
+}
+
+// withCheckCache makes the checks share their results via the cache, results older than maxAge are not reused.
+func withCheckCache(ctx context.Context, cache *checkCache, maxAge time.Duration) context.Context {
+	return context.WithValue(ctx, checkCacheKey{}, checkScope{cache: cache, maxAge: maxAge})
+}
+
+// WithCheckKey identifies the record's healthcheck, records with the same key share a single check per cycle. The
+// key must cover the target as well as the complete checker config.
+func WithCheckKey(key string) ManagedDnsRecordOpts {
+	return func(r *ManagedDnsRecord) error {
+		r.checkKey = key
+		return nil
+	}
+}
+
+// CheckKey fingerprints the healthcheck of a record, records with the same key share their check results. The key is
+// built from every field of the healthcheck config, so it also covers the configs of the checkers that are hidden from
+// the json representation of the config.
+func CheckKey(host string, record DnsRecord, args conf.HealthcheckConfig) (string, error) {
+	fingerprint, err := json.Marshal(structuralValue(reflect.ValueOf(args)))
+	if err != nil {
+		return "", err
+	}
+
+	if !slices.Contains(sniCheckers, args.Type) && (args.Passive == nil || !slices.Contains(sniCheckers, args.Passive.Type)) {
+		host = ""
+	}
+	return fmt.Sprintf("%s|%s|%s", record.Ip, host, fingerprint), nil
+}
+
+// structuralValue returns the value with the exported fields of structs keyed by their names, regardless of their
+// json tags. Structs without exported fields, e.g. time.Time, are kept as they are.
+func structuralValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return structuralValue(v.Elem())
+	case reflect.Struct:
+		fields := map[string]any{}
+		for i := range v.NumField() {
+			if field := v.Type().Field(i); field.IsExported() {
+				fields[field.Name] = structuralValue(v.Field(i))
+			}
+		}
+		if len(fields) == 0 {
+			return v.Interface()
+		}
+		return fields
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		ret := make([]any, v.Len())
+		for i := range v.Len() {
+			ret[i] = structuralValue(v.Index(i))
+		}
+		return ret
+	case reflect.Map:
+		ret := make(map[string]any, v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			ret[fmt.Sprint(iter.Key().Interface())] = structuralValue(iter.Value())
+		}
+		return ret
+	default:
+		return v.Interface()
+	}
+}
+
+// check runs the record's healthcheck, waits for the result of an identical check that is already running or reuses
+// the result of an identical check that started less than the maximum age of the scope ago.
+func (r *ManagedDnsRecord) check(ctx context.Context) (bool, error) {
+	scope, ok := ctx.Value(checkCacheKey{}).(checkScope)
+	if !ok || r.checkKey == "" {
+		return r.healthCheck.IsHealthy(ctx)
+	}
+
+	now := r.Now()
+	scope.cache.mutex.Lock()
+	call, found := scope.cache.results[r.checkKey]
+	if found && !call.reusable(now, scope.maxAge) {
+		found = false
+	}
+	if !found {
+		call = &checkCall{started: now, done: make(chan struct{})}
+		scope.cache.results[r.checkKey] = call
+	}
+	scope.cache.mutex.Unlock()
+
+	if found {
+		select {
+		case <-call.done:
+			metrics.ChecksDeduplicated.WithLabelValues(r.Hostname, r.Ip.String()).Inc()
+			return call.healthy, call.err
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+
+	call.healthy, call.err = r.healthCheck.IsHealthy(ctx)
+	close(call.done)
+	return call.healthy, call.err
+}
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+type countingHealthcheck struct {
+	probes atomic.Int32
+}
+
+func (c *countingHealthcheck) IsHealthy(_ context.Context) (bool, error) {
+	c.probes.Add(1)
+	return true, nil
+}
+
+func TestRecordManager_runHealthchecksDedupe(t *testing.T) {
+	statusConf := conf.StatusConfig{HealthyStreak: 1, UnhealthyStreak: 1, InitialHealthyStreak: 1, InitialUnhealthyStreak: 1}
+	check := &countingHealthcheck{}
+	other := &countingHealthcheck{}
+
+	build := func(hostname, ip, key string, healthcheck Healthcheck) *ManagedDnsRecord {
+		record, err := NewManagedDnsRecord(hostname, DnsRecord{DnsType: "A", Ip: net.ParseIP(ip), Ttl: 60}, statusConf, healthcheck, WithCheckKey(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record
+	}
+
+	records := map[string][]*ManagedDnsRecord{
+		"a.tld": {build("a.tld", "10.0.0.1", "10.0.0.1|icmp", check)},
+		"b.tld": {build("b.tld", "10.0.0.1", "10.0.0.1|icmp", check)},
+		"c.tld": {build("c.tld", "10.0.0.1", "10.0.0.1|tcp", other)},
+	}
+	m, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.runHealthchecks(context.Background())
+	if probes := check.probes.Load(); probes != 1 {
+		t.Errorf("expected identical checks to be probed once, got %d", probes)
+	}
+	if probes := other.probes.Load(); probes != 1 {
+		t.Errorf("expected different checker to be probed, got %d", probes)
+	}
+
+	m.runHealthchecks(context.Background())
+	if probes := check.probes.Load(); probes != 2 {
+		t.Errorf("expected results not to be shared across cycles, got %d probes", probes)
+	}
+	for _, hostname := range []string{"a.tld", "b.tld"} {
+		if state := records[hostname][0].GetState().Name(); state != "healthy" {
+			t.Errorf("expected %s to be healthy, got %s", hostname, state)
+		}
+	}
+}
+
+func TestCheckKey_checkerConfig(t *testing.T) {
+	statusConf := conf.StatusConfig{HealthyStreak: 1, UnhealthyStreak: 1, InitialHealthyStreak: 1, InitialUnhealthyStreak: 1}
+	record := DnsRecord{DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}
+
+	checks := map[string]*countingHealthcheck{}
+	records := map[string][]*ManagedDnsRecord{}
+	for hostname, port := range map[string]int{"a.tld": 80, "b.tld": 443} {
+		key, err := CheckKey(hostname, record, conf.HealthcheckConfig{Type: conf.TcpCheckerName, Tcp: &conf.TcpHealthcheckConfig{Port: port}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checks[hostname] = &countingHealthcheck{}
+		managed, err := NewManagedDnsRecord(hostname, record, statusConf, checks[hostname], WithCheckKey(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records[hostname] = []*ManagedDnsRecord{managed}
+	}
+	if records["a.tld"][0].checkKey == records["b.tld"][0].checkKey {
+		t.Fatalf("expected tcp checks on different ports to have different keys, got %q", records["a.tld"][0].checkKey)
+	}
+
+	m, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.runHealthchecks(context.Background())
+	for hostname, check := range checks {
+		if probes := check.probes.Load(); probes != 1 {
+			t.Errorf("expected the check of %s to be probed once, got %d", hostname, probes)
+		}
+	}
+
+	// the hostname is only part of the key for checkers that send it
+	tcpA, _ := CheckKey("a.tld", record, conf.HealthcheckConfig{Type: conf.TcpCheckerName, Tcp: &conf.TcpHealthcheckConfig{Port: 80}})
+	tcpB, _ := CheckKey("b.tld", record, conf.HealthcheckConfig{Type: conf.TcpCheckerName, Tcp: &conf.TcpHealthcheckConfig{Port: 80}})
+	if tcpA != tcpB {
+		t.Errorf("expected identical tcp checks of different hostnames to share a key, got %q and %q", tcpA, tcpB)
+	}
+}
+
+func TestRecordManager_checkLoopsShareChecks(t *testing.T) {
+	statusConf := conf.StatusConfig{HealthyStreak: 1, UnhealthyStreak: 1, InitialHealthyStreak: 1, InitialUnhealthyStreak: 1}
+	check := &countingHealthcheck{}
+	records := map[string][]*ManagedDnsRecord{}
+	for _, hostname := range []string{"a.tld", "b.tld"} {
+		record, err := NewManagedDnsRecord(hostname, DnsRecord{DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, statusConf, check, WithCheckKey("10.0.0.1|icmp"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records[hostname] = []*ManagedDnsRecord{record}
+	}
+
+	const interval = 100 * time.Millisecond
+	m, err := NewRecordManager(&lockedDnsDb{}, &dummyService{}, records, WithCheckInterval(interval))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+	time.Sleep(5*interval + interval/2)
+	cancel()
+	<-done
+
+	// one probe at the start and one per interval, the loops of both hostnames share them
+	if probes, limit := check.probes.Load(), int32(time.Since(start)/interval)+2; probes > limit {
+		t.Errorf("expected at most %d probes, got %d", limit, probes)
+	}
+}
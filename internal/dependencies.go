@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+// hostnameOrder returns the managed hostnames sorted by name, hostnames are preceded by the hostnames they depend on.
+func (h *RecordManager) hostnameOrder() []string {
+	hostnames := slices.Sorted(maps.Keys(h.managedRecords))
+	ordered := make([]string, 0, len(hostnames))
+	visited := make(map[string]bool, len(hostnames))
+
+	var visit func(hostname string)
+	visit = func(hostname string) {
+		if visited[hostname] {
+			return
+		}
+		// cycles are rejected when the policies are set, marking the hostname first merely guards against recursion
+		visited[hostname] = true
+		for _, dependency := range h.hostnamePolicies[hostname].DependsOn {
+			if _, found := h.managedRecords[dependency]; found {
+				visit(dependency)
+			}
+		}
+		ordered = append(ordered, hostname)
+	}
+
+	for _, hostname := range hostnames {
+		visit(hostname)
+	}
+	return ordered
+}
+
+// hostnameWaves groups the hostnames by their depth in the dependency graph. Hostnames without dependencies are part of
+// the first wave, every other hostname is part of the wave after the last wave of its dependencies.
+func (h *RecordManager) hostnameWaves() [][]string {
+	depths := make(map[string]int, len(h.managedRecords))
+	var waves [][]string
+	for _, hostname := range h.hostnameOrder() {
+		depth := 0
+		for _, dependency := range h.hostnamePolicies[hostname].DependsOn {
+			if dependencyDepth, found := depths[dependency]; found {
+				depth = max(depth, dependencyDepth+1)
+			}
+		}
+		depths[hostname] = depth
+
+		for len(waves) <= depth {
+			waves = append(waves, nil)
+		}
+		waves[depth] = append(waves[depth], hostname)
+	}
+	return waves
+}
+
+// blockingDependency returns the first dependency of the hostname that could not apply its own changes in the
+// current cycle, or an empty string if the hostname is free to change its records.
+func (h *RecordManager) blockingDependency(hostname string) string {
+	for _, dependency := range h.hostnamePolicies[hostname].DependsOn {
+		if h.pendingHostnames[dependency] {
+			return dependency
+		}
+	}
+	return ""
+}
+
+// deferChange returns true if the change of the records of the hostname has to wait for one of its dependencies.
+func (h *RecordManager) deferChange(hostname string) bool {
+	dependency := h.blockingDependency(hostname)
+	if dependency == "" {
+		return false
+	}
+
+	slog.Info("Deferring change of records until dependency has been updated", "hostname", hostname, "dependency", dependency)
+	h.pendingHostnames[hostname] = true
+	return true
+}
+
+// dependencyCycle returns an error if the hostnames depend on each other in a cycle.
+func dependencyCycle(policies map[string]HostnamePolicy) error {
+	dependsOn := make(map[string][]string, len(policies))
+	for hostname, policy := range policies {
+		dependsOn[hostname] = policy.DependsOn
+	}
+	return conf.DependencyCycle(dependsOn)
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+type failingDnsDb struct {
+	dummyDnsDb
+	fail map[string]bool
+}
+
+func (d *failingDnsDb) Apply(ctx context.Context, desired map[string][]ManagedDnsRecord) (bool, error) {
+	for hostname := range desired {
+		if d.fail[hostname] {
+			return false, errors.New("failed")
+		}
+	}
+	return d.dummyDnsDb.Apply(ctx, desired)
+}
+
+func TestRecordManager_hostnameOrder(t *testing.T) {
+	records := map[string][]*ManagedDnsRecord{"a.tld": nil, "b.tld": nil, "c.tld": nil, "d.tld": nil}
+	policies := map[string]HostnamePolicy{
+		"a.tld": {DependsOn: []string{"c.tld"}},
+		"c.tld": {DependsOn: []string{"d.tld"}},
+	}
+	m, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, records, WithHostnamePolicies(policies))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"d.tld", "c.tld", "a.tld", "b.tld"}
+	if got := m.hostnameOrder(); !reflect.DeepEqual(got, want) {
+		t.Errorf("hostnameOrder() = %v, want %v", got, want)
+	}
+}
+
+func TestWithHostnamePolicies_cycle(t *testing.T) {
+	policies := map[string]HostnamePolicy{
+		"a.tld": {DependsOn: []string{"b.tld"}},
+		"b.tld": {DependsOn: []string{"a.tld"}},
+	}
+	if _, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, nil, WithHostnamePolicies(policies)); err == nil {
+		t.Fatal("expected error for dependency cycle")
+	}
+}
+
+func TestRecordManager_dependencies(t *testing.T) {
+	newRecords := func(hostname string) []*ManagedDnsRecord {
+		return []*ManagedDnsRecord{
+			{DnsRecord: DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, Hostname: hostname, status: &status.Unhealthy{}},
+			{DnsRecord: DnsRecord{Priority: 10, DnsType: "A", Ip: net.ParseIP("10.0.0.2"), Ttl: 60}, Hostname: hostname, status: &status.Healthy{}},
+		}
+	}
+
+	db := &failingDnsDb{fail: map[string]bool{"app.tld": true}}
+	records := map[string][]*ManagedDnsRecord{"app.tld": newRecords("app.tld"), "db.tld": newRecords("db.tld")}
+	policies := map[string]HostnamePolicy{"db.tld": {DependsOn: []string{"app.tld"}}}
+	m, err := NewRecordManager(db, &dummyService{}, records, WithHostnamePolicies(policies))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.applyRecords(context.Background())
+	if _, updated := db.updates["db.tld"]; updated {
+		t.Fatalf("expected change of db.tld to be deferred, got %v", db.updates)
+	}
+
+	db.fail = nil
+	m.applyRecords(context.Background())
+	want := map[string][]string{"app.tld": {"A 10.0.0.2"}, "db.tld": {"A 10.0.0.2"}}
+	if !reflect.DeepEqual(db.updates, want) {
+		t.Errorf("expected both hostnames to be updated in a single cycle, got %v", db.updates)
+	}
+}
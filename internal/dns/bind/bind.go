@@ -0,0 +1,259 @@
+package bind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/dns/files"
+	"go.uber.org/multierr"
+)
+
+const (
+	DefaultCheckzoneBinary    = "named-checkzone"
+	DefaultNsdCheckzoneBinary = "nsd-checkzone"
+	DefaultKzonecheckBinary   = "kzonecheck"
+	defaultBackups            = 3
+	defaultFileMode           = 0640
+)
+
+// Bind manages records in the zone file of an authoritative BIND server. Every change bumps the serial of the zone.
+// NSD and Knot DNS read zone files of the same format, so Bind manages their zones as well.
+type Bind struct {
+	zone string
+	fs   ZoneFileWrapper
+	now  func() time.Time
+}
+
+// ZoneFileWrapper is just a simple wrapper to increase testability for Bind.
+type ZoneFileWrapper interface {
+	ReadZone() ([]string, error)
+	WriteZone(lines []string) error
+	ValidateZone(ctx context.Context) error
+	// Rollback restores the content that was replaced by the last call to WriteZone.
+	Rollback() error
+}
+
+func NewBind(zone string, fs ZoneFileWrapper) (*Bind, error) {
+	if zone == "" {
+		return nil, errors.New("empty zone supplied")
+	}
+	if fs == nil {
+		return nil, errors.New("nil fs supplied")
+	}
+
+	return &Bind{zone: normalizeName(zone), fs: fs, now: time.Now}, nil
+}
+
+// ValidateConfig validates the written zone file and rolls back to the previous version if it is invalid.
+func (b *Bind) ValidateConfig(ctx context.Context) error {
+	err := b.fs.ValidateZone(ctx)
+	if err == nil {
+		return nil
+	}
+
+	slog.Warn("Rolling back invalid zone file", "zone", b.zone, "err", err)
+	if rollbackErr := b.fs.Rollback(); rollbackErr != nil {
+		return errors.Join(err, fmt.Errorf("rollback failed: %w", rollbackErr))
+	}
+	// validating again would only validate the previous version
+	return fmt.Errorf("%w: %w", err, internal.ErrNotRetryable)
+}
+
+// Apply writes the records of all hostnames with a single write of the zone file, which bumps the serial once.
+func (b *Bind) Apply(ctx context.Context, desired map[string][]internal.ManagedDnsRecord) (bool, error) {
+	for hostname := range desired {
+		if !b.inZone(hostname) {
+			return false, fmt.Errorf("hostname %q is not part of zone %q", hostname, b.zone)
+		}
+	}
+
+	lines, err := b.fs.ReadZone()
+	if err != nil {
+		return false, err
+	}
+
+	zone, err := parseZoneFile(lines)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for _, hostname := range slices.Sorted(maps.Keys(desired)) {
+		wanted := make([]record, 0, len(desired[hostname]))
+		for _, r := range desired[hostname] {
+			wanted = append(wanted, record{
+				name:  fqdn(hostname),
+				ttl:   int(r.Ttl),
+				rtype: r.DnsType,
+				data:  r.Data(),
+			})
+		}
+		if zone.replace(hostname, wanted) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return false, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	if err := zone.bumpSerial(b.now()); err != nil {
+		return false, err
+	}
+
+	return true, b.fs.WriteZone(zone.lines())
+}
+
+// PublishedIps returns the addresses of the A and AAAA records of the hostname in the managed block.
+func (b *Bind) PublishedIps(hostname string) ([]string, error) {
+	lines, err := b.fs.ReadZone()
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := parseZoneFile(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for _, r := range zone.managed {
+		if r.managedBy == normalizeName(hostname) && (r.rtype == "A" || r.rtype == "AAAA") {
+			ips = append(ips, r.data)
+		}
+	}
+	return ips, nil
+}
+
+func (b *Bind) inZone(hostname string) bool {
+	hostname = normalizeName(hostname)
+	return hostname == b.zone || strings.HasSuffix(hostname, "."+b.zone)
+}
+
+type ZoneFile struct {
+	filePath string
+	zone     string
+	backups  int
+
+	checkzoneBinary string
+	checkzoneArgs   []string
+
+	// previous holds the content replaced by the last write, it's used to roll back invalid zones
+	previous []byte
+}
+
+type ZoneFileOpts func(*ZoneFile) error
+
+// WithBackups keeps the given amount of timestamped backups of the previous versions of the zone file. Zero disables
+// backups.
+func WithBackups(backups int) ZoneFileOpts {
+	return func(z *ZoneFile) error {
+		if backups < 0 {
+			return errors.New("amount of backups must not be negative")
+		}
+		z.backups = backups
+		return nil
+	}
+}
+
+// WithCheckzone configures the binary and additional arguments used to validate the zone file. The zone name and the
+// path of the zone file are appended as last arguments.
+func WithCheckzone(binary string, args []string) ZoneFileOpts {
+	return func(z *ZoneFile) error {
+		if binary != "" {
+			z.checkzoneBinary = binary
+		}
+		z.checkzoneArgs = args
+		return nil
+	}
+}
+
+// NewZoneFile manages the zone file at filePath. The file must exist, as dns-ha does not create the SOA record.
+func NewZoneFile(filePath, zone string, opts ...ZoneFileOpts) (*ZoneFile, error) {
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, fmt.Errorf("zone file %q is not accessible: %w", filePath, err)
+	}
+	if !files.IsWritable(filePath) {
+		return nil, fmt.Errorf("zone file %q is not writable", filePath)
+	}
+
+	ret := &ZoneFile{
+		filePath:        filePath,
+		zone:            zone,
+		backups:         defaultBackups,
+		checkzoneBinary: DefaultCheckzoneBinary,
+	}
+
+	var errs error
+	for _, opt := range opts {
+		if err := opt(ret); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	return ret, errs
+}
+
+func (z *ZoneFile) ReadZone() ([]string, error) {
+	content, err := os.ReadFile(z.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zone file: %w", err)
+	}
+
+	return strings.Split(string(content), "\n"), nil
+}
+
+// WriteZone atomically replaces the zone file after keeping a backup of its current content.
+func (z *ZoneFile) WriteZone(lines []string) error {
+	previous, err := os.ReadFile(z.filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not read current zone file: %w", err)
+	}
+
+	if z.backups > 0 && previous != nil {
+		if err := files.Backup(z.filePath, previous, z.backups, defaultFileMode); err != nil {
+			return err
+		}
+	}
+
+	if err := files.WriteAtomic(z.filePath, []byte(strings.Join(lines, "\n")), defaultFileMode); err != nil {
+		return err
+	}
+
+	z.previous = previous
+	return nil
+}
+
+func (z *ZoneFile) Rollback() error {
+	if z.previous == nil {
+		return errors.New("no previous version available")
+	}
+
+	if err := files.WriteAtomic(z.filePath, z.previous, defaultFileMode); err != nil {
+		return err
+	}
+
+	z.previous = nil
+	return nil
+}
+
+func (z *ZoneFile) ValidateZone(ctx context.Context) error {
+	args := append(slices.Clone(z.checkzoneArgs), z.zone, z.filePath)
+	cmd := exec.CommandContext(ctx, z.checkzoneBinary, args...) //nolint G204
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", z.checkzoneBinary, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
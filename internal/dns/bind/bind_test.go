@@ -0,0 +1,86 @@
+package bind
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+)
+
+type dummyZoneFile struct {
+	read    []string
+	written []string
+}
+
+func (d *dummyZoneFile) ReadZone() ([]string, error) {
+	return d.read, nil
+}
+
+func (d *dummyZoneFile) WriteZone(lines []string) error {
+	d.written = lines
+	return nil
+}
+
+func (d *dummyZoneFile) ValidateZone(_ context.Context) error {
+	return nil
+}
+
+func (d *dummyZoneFile) Rollback() error {
+	return nil
+}
+
+func TestBind_Apply(t *testing.T) {
+	fs := &dummyZoneFile{read: []string{
+		"$ORIGIN example.com.",
+		"@ 3600 IN SOA ns1 admin 7 7200 3600 1209600 3600",
+		"ns1 IN A 10.0.0.53",
+		"",
+	}}
+	b, err := NewBind("example.com", fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.now = func() time.Time { return time.Unix(0, 0) }
+
+	records := []internal.ManagedDnsRecord{
+		{DnsRecord: internal.DnsRecord{DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}},
+	}
+	// all hostnames are written at once and bump the serial only once
+	updated, err := b.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"www.example.com": records, "mail.example.com": records})
+	if err != nil || !updated {
+		t.Fatalf("Apply() = %v, %v", updated, err)
+	}
+
+	want := []string{
+		"$ORIGIN example.com.",
+		"@ 3600 IN SOA ns1 admin 8 7200 3600 1209600 3600",
+		"ns1 IN A 10.0.0.53",
+		managedBlockStart,
+		"mail.example.com. 60 IN A 10.0.0.1 ; managed-by: dns-ha mail.example.com",
+		"www.example.com. 60 IN A 10.0.0.1 ; managed-by: dns-ha www.example.com",
+		managedBlockEnd,
+		"",
+	}
+	if !reflect.DeepEqual(fs.written, want) {
+		t.Errorf("written = %q, want %q", fs.written, want)
+	}
+
+	// unchanged records neither bump the serial nor write the file
+	fs.read, fs.written = fs.written, nil
+	updated, err = b.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"www.example.com": records})
+	if err != nil || updated || fs.written != nil {
+		t.Errorf("expected no update, got %v, %v", updated, err)
+	}
+
+	ips, err := b.PublishedIps("www.example.com")
+	if err != nil || !reflect.DeepEqual(ips, []string{"10.0.0.1"}) {
+		t.Errorf("PublishedIps() = %v, %v", ips, err)
+	}
+
+	if _, err := b.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"www.other.com": records}); err == nil {
+		t.Error("expected error for hostname outside of zone")
+	}
+}
@@ -0,0 +1,246 @@
+package bind
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	managedBlockStart = "; BEGIN managed by dns-ha, do not edit"
+	managedBlockEnd   = "; END managed by dns-ha"
+	// ownerMarker is appended to each managed record, followed by the hostname owning it
+	ownerMarker = "managed-by: dns-ha "
+)
+
+var tokenPattern = regexp.MustCompile(`[^\s()]+`)
+
+// record is a single line of the managed block. Lines that can not be parsed are kept verbatim in raw.
+type record struct {
+	name  string
+	ttl   int
+	rtype string
+	data  string
+	// managedBy is the hostname of the ownership marker, empty for records without marker
+	managedBy string
+
+	raw string
+}
+
+func parseRecord(line string) record {
+	content, comment, _ := strings.Cut(line, ";")
+	fields := strings.Fields(content)
+	if len(fields) < 3 {
+		return record{raw: line}
+	}
+
+	ret := record{name: fields[0], ttl: -1}
+	if managedBy, found := strings.CutPrefix(strings.TrimSpace(comment), ownerMarker); found {
+		ret.managedBy = normalizeName(strings.TrimSpace(managedBy))
+	}
+	fields = fields[1:]
+	if ttl, err := strconv.Atoi(fields[0]); err == nil {
+		ret.ttl = ttl
+		fields = fields[1:]
+	}
+
+	if len(fields) > 1 && slices.Contains([]string{"IN", "CH", "HS"}, strings.ToUpper(fields[0])) {
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return record{raw: line}
+	}
+
+	ret.rtype = strings.ToUpper(fields[0])
+	ret.data = strings.Join(fields[1:], " ")
+	return ret
+}
+
+func (r record) owner() string {
+	if r.rtype == "" {
+		return ""
+	}
+	return normalizeName(r.name)
+}
+
+func (r record) String() string {
+	if r.rtype == "" {
+		return r.raw
+	}
+
+	var ttl string
+	if r.ttl >= 0 {
+		ttl = fmt.Sprintf("%d ", r.ttl)
+	}
+	var marker string
+	if r.managedBy != "" {
+		marker = " ; " + ownerMarker + r.managedBy
+	}
+	return fmt.Sprintf("%s %sIN %s %s%s", r.name, ttl, r.rtype, r.data, marker)
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// fqdn returns the absolute form of the name, so records do not depend on the $ORIGIN of the zone file.
+func fqdn(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
+
+// zoneFile is the structured representation of a zone file. Only the records in the block marked as managed by dns-ha
+// and the serial of the SOA record are ever modified, all other lines are written back byte-identical.
+type zoneFile struct {
+	head     []string
+	managed  []record
+	tail     []string
+	hasBlock bool
+}
+
+func parseZoneFile(lines []string) (*zoneFile, error) {
+	start := slices.Index(lines, managedBlockStart)
+	end := slices.Index(lines, managedBlockEnd)
+
+	if start < 0 && end < 0 {
+		return &zoneFile{head: lines}, nil
+	}
+
+	if start < 0 || end < start {
+		return nil, errors.New("malformed dns-ha managed block")
+	}
+
+	zone := &zoneFile{
+		head:     lines[:start],
+		tail:     lines[end+1:],
+		hasBlock: true,
+	}
+	for _, line := range lines[start+1 : end] {
+		zone.managed = append(zone.managed, parseRecord(line))
+	}
+	// blocks written before ownership markers were introduced only contain records of dns-ha, they are marked with
+	// their owner and migrated with the next write
+	if !slices.ContainsFunc(zone.managed, func(r record) bool { return r.managedBy != "" }) {
+		for i := range zone.managed {
+			zone.managed[i].managedBy = zone.managed[i].owner()
+		}
+	}
+
+	return zone, nil
+}
+
+// replace replaces all managed records owned by the hostname with the wanted records. Records are owned by the
+// hostname of their ownership marker, records without marker are never touched. It returns false if the managed
+// records already match the wanted records.
+func (z *zoneFile) replace(hostname string, wanted []record) bool {
+	hostname = normalizeName(hostname)
+	isOwned := func(r record) bool {
+		return r.managedBy == hostname
+	}
+	for i := range wanted {
+		wanted[i].managedBy = hostname
+	}
+
+	var current []string
+	for _, r := range z.managed {
+		if isOwned(r) {
+			current = append(current, r.String())
+		}
+	}
+
+	wantedLines := make([]string, 0, len(wanted))
+	for _, r := range wanted {
+		wantedLines = append(wantedLines, r.String())
+	}
+
+	slices.Sort(current)
+	slices.Sort(wantedLines)
+	if slices.Equal(current, wantedLines) {
+		return false
+	}
+
+	pos := slices.IndexFunc(z.managed, isOwned)
+	if pos < 0 {
+		pos = len(z.managed)
+	}
+
+	z.managed = slices.DeleteFunc(z.managed, isOwned)
+	z.managed = slices.Insert(z.managed, pos, wanted...)
+	return true
+}
+
+// bumpSerial increments the serial of the SOA record, which has to be defined outside the managed block.
+func (z *zoneFile) bumpSerial(now time.Time) error {
+	if bumpSerial(z.head, now) || bumpSerial(z.tail, now) {
+		return nil
+	}
+	return errors.New("no SOA record found in zone file")
+}
+
+func (z *zoneFile) lines() []string {
+	block := make([]string, 0, len(z.managed)+2)
+	block = append(block, managedBlockStart)
+	for _, r := range z.managed {
+		block = append(block, r.String())
+	}
+	block = append(block, managedBlockEnd)
+
+	if z.hasBlock {
+		return slices.Concat(z.head, block, z.tail)
+	}
+
+	// append a new block to the end of the file and keep the trailing newline
+	head := z.head
+	if len(head) > 0 && head[len(head)-1] == "" {
+		head = head[:len(head)-1]
+	}
+	return slices.Concat(head, block, []string{""})
+}
+
+// bumpSerial replaces the serial of the first SOA record in place. The SOA record may span multiple lines using
+// parentheses. It returns false if no SOA record has been found.
+func bumpSerial(lines []string, now time.Time) bool {
+	// amount of tokens after the SOA type: the primary name server, the mailbox and the serial
+	tokensAfterSoa := -1
+	for index, line := range lines {
+		content, _, _ := strings.Cut(line, ";")
+		for _, loc := range tokenPattern.FindAllStringIndex(content, -1) {
+			token := content[loc[0]:loc[1]]
+			if tokensAfterSoa < 0 {
+				if strings.EqualFold(token, "SOA") {
+					tokensAfterSoa = 0
+				}
+				continue
+			}
+
+			tokensAfterSoa++
+			if tokensAfterSoa < 3 {
+				continue
+			}
+
+			serial, err := strconv.ParseUint(token, 10, 32)
+			if err != nil {
+				return false
+			}
+			lines[index] = line[:loc[0]] + strconv.FormatUint(uint64(nextSerial(uint32(serial), now)), 10) + line[loc[1]:]
+			return true
+		}
+	}
+	return false
+}
+
+// nextSerial keeps date based serials (YYYYMMDDnn) in their format and increments all other serials.
+func nextSerial(current uint32, now time.Time) uint32 {
+	date := now.UTC()
+	dateSerial := uint32(date.Year()*1000000 + int(date.Month())*10000 + date.Day()*100)
+
+	looksLikeDate := current >= 1970010100 && current <= dateSerial+99
+	if looksLikeDate && current < dateSerial {
+		return dateSerial
+	}
+	// serial arithmetic wraps around, see RFC 1982
+	return current + 1
+}
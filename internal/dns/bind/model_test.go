@@ -0,0 +1,78 @@
+package bind
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBumpSerial(t *testing.T) {
+	now := time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+		found bool
+	}{
+		{
+			name:  "single line",
+			lines: []string{"@ 3600 IN SOA ns1.example.com. admin.example.com. 41 7200 3600 1209600 3600"},
+			want:  []string{"@ 3600 IN SOA ns1.example.com. admin.example.com. 42 7200 3600 1209600 3600"},
+			found: true,
+		},
+		{
+			name: "multi line date serial",
+			lines: []string{
+				"@ IN SOA ns1.example.com. admin.example.com. (",
+				"        2024010203 ; serial",
+				"        7200 ; refresh",
+				")",
+			},
+			want: []string{
+				"@ IN SOA ns1.example.com. admin.example.com. (",
+				"        2024051700 ; serial",
+				"        7200 ; refresh",
+				")",
+			},
+			found: true,
+		},
+		{
+			name:  "date serial of today",
+			lines: []string{"@ IN SOA ns1 admin (2024051705 7200 3600 1209600 3600)"},
+			want:  []string{"@ IN SOA ns1 admin (2024051706 7200 3600 1209600 3600)"},
+			found: true,
+		},
+		{
+			name:  "soa in comment",
+			lines: []string{"; SOA ns1 admin 1"},
+			want:  []string{"; SOA ns1 admin 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bumpSerial(tt.lines, now); got != tt.found {
+				t.Fatalf("bumpSerial() = %v, want %v", got, tt.found)
+			}
+			if !reflect.DeepEqual(tt.lines, tt.want) {
+				t.Errorf("bumpSerial() lines = %q, want %q", tt.lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	tests := []struct {
+		line string
+		want record
+	}{
+		{line: "www.example.com. 60 IN A 10.0.0.1", want: record{name: "www.example.com.", ttl: 60, rtype: "A", data: "10.0.0.1"}},
+		{line: "www.example.com. AAAA 2001:db8::1 ; comment", want: record{name: "www.example.com.", ttl: -1, rtype: "AAAA", data: "2001:db8::1"}},
+		{line: "www.example.com. 60 IN A 10.0.0.1 ; managed-by: dns-ha www.example.com", want: record{name: "www.example.com.", ttl: 60, rtype: "A", data: "10.0.0.1", managedBy: "www.example.com"}},
+		{line: "; comment", want: record{raw: "; comment"}},
+	}
+	for _, tt := range tests {
+		if got := parseRecord(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseRecord(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Package files contains the file handling shared by the file based DNS backends.
+package files
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	BackupSuffix            = ".bak"
+	DefaultRestoreconBinary = "restorecon"
+	backupTimeFormat        = "20060102T150405.000000000"
+)
+
+// Attributes are applied to written files.
+type Attributes struct {
+	Mode os.FileMode
+	// Uid and Gid change the owner of the file unless they are negative, which usually requires root privileges.
+	Uid int
+	Gid int
+	// Restorecon restores the SELinux context of the file using the given binary unless it's empty, e.g. "restorecon".
+	Restorecon string
+}
+
+// ModeOnly returns the attributes that only set the mode, the file is owned by the writing user.
+func ModeOnly(mode os.FileMode) Attributes {
+	return Attributes{Mode: mode, Uid: -1, Gid: -1}
+}
+
+// Backup writes the content to a timestamped backup next to path and removes the oldest backups exceeding keep.
+func Backup(path string, content []byte, keep int, mode os.FileMode) error {
+	backupFile := fmt.Sprintf("%s.%s%s", path, time.Now().UTC().Format(backupTimeFormat), BackupSuffix)
+	if err := WriteAtomic(backupFile, content, mode); err != nil {
+		return fmt.Errorf("could not write backup: %w", err)
+	}
+
+	backups, err := filepath.Glob(path + ".*" + BackupSuffix)
+	if err != nil {
+		return err
+	}
+
+	// the timestamp format sorts lexicographically, the oldest backups come first
+	slices.Sort(backups)
+	for len(backups) > keep {
+		if err := os.Remove(backups[0]); err != nil {
+			slog.Warn("could not remove old backup", "file", backups[0], "err", err)
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// WriteAtomic writes the data to a temporary file in the same directory, syncs it and renames it to the target
+// so readers never observe a partially written file.
+func WriteAtomic(path string, data []byte, mode os.FileMode) error {
+	return WriteAtomicWith(path, data, ModeOnly(mode))
+}
+
+// WriteAtomicWith is WriteAtomic applying all of the attributes. The owner is set before the rename, the SELinux
+// context depends on the path and is restored after the rename.
+func WriteAtomicWith(path string, data []byte, attrs Attributes) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("could not write temporary file: %w", err)
+	}
+
+	if err := tmp.Chmod(attrs.Mode); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("could not set file mode: %w", err)
+	}
+
+	if attrs.Uid >= 0 || attrs.Gid >= 0 {
+		if err := tmp.Chown(attrs.Uid, attrs.Gid); err != nil {
+			_ = tmp.Close()
+			return fmt.Errorf("could not set file owner: %w", err)
+		}
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("could not sync temporary file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not close temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not rename temporary file: %w", err)
+	}
+
+	// sync the directory to persist the rename
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+
+	if attrs.Restorecon != "" {
+		cmd := exec.Command(attrs.Restorecon, path) //nolint G204
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("could not restore selinux context: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	return nil
+}
+
+// IsWritable returns true if the existing file at path can be opened for writing.
+func IsWritable(path string) bool {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return false
+	}
+	_ = file.Close()
+	return true
+}
+
+// CheckWritable verifies that the file at path can be replaced by WriteAtomic without writing to it. If create is
+// true, the file does not need to exist yet.
+func CheckWritable(path string, create bool) error {
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return fmt.Errorf("%s is a directory", path)
+	case err == nil:
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		_ = f.Close()
+	case !os.IsNotExist(err) || !create:
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("directory is not writable: %w", err)
+	}
+	_ = tmp.Close()
+	return os.Remove(tmp.Name())
+}
@@ -0,0 +1,276 @@
+// Package hosts manages records in a managed block of a hosts file. It serves DNS servers that answer from a hosts
+// file and pick up its changes on their own, such as systemd-resolved and the hosts plugin of CoreDNS.
+package hosts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/dns/files"
+	"go.uber.org/multierr"
+)
+
+const (
+	defaultBackups  = 3
+	defaultFileMode = 0644
+
+	managedBlockStart = "# BEGIN managed by dns-ha, do not edit"
+	managedBlockEnd   = "# END managed by dns-ha"
+)
+
+// Hosts manages records in a hosts file. Only A and AAAA records can be expressed in
+// the hosts file, other records are skipped.
+type Hosts struct {
+	fs HostsFileWrapper
+}
+
+// HostsFileWrapper is just a simple wrapper to increase testability for Hosts.
+type HostsFileWrapper interface {
+	ReadHosts() ([]string, error)
+	WriteHosts(lines []string) error
+}
+
+func NewHosts(fs HostsFileWrapper) (*Hosts, error) {
+	if fs == nil {
+		return nil, errors.New("nil fs supplied")
+	}
+
+	return &Hosts{fs: fs}, nil
+}
+
+// ValidateConfig does nothing, the lines of the managed block are valid by construction.
+func (h *Hosts) ValidateConfig(_ context.Context) error {
+	return nil
+}
+
+// Apply writes the records of all hostnames with a single write of the hosts file.
+func (h *Hosts) Apply(ctx context.Context, desired map[string][]internal.ManagedDnsRecord) (bool, error) {
+	lines, err := h.fs.ReadHosts()
+	if err != nil {
+		return false, err
+	}
+
+	hosts, err := parseHostsFile(lines)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for _, hostname := range slices.Sorted(maps.Keys(desired)) {
+		var ips []string
+		for _, record := range desired[hostname] {
+			if record.DnsType == "A" || record.DnsType == "AAAA" {
+				ips = append(ips, record.Ip.String())
+			}
+		}
+		if hosts.replace(hostname, ips) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return false, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	return true, h.fs.WriteHosts(hosts.lines())
+}
+
+// PublishedIps returns the addresses of the hostname in the managed block.
+func (h *Hosts) PublishedIps(hostname string) ([]string, error) {
+	lines, err := h.fs.ReadHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	hosts, err := parseHostsFile(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []string
+	for _, e := range hosts.managed {
+		if e.hostname == normalizeName(hostname) {
+			ips = append(ips, e.ip)
+		}
+	}
+	return ips, nil
+}
+
+// entry is a single line of the managed block, each line maps one address to one hostname.
+type entry struct {
+	ip       string
+	hostname string
+}
+
+func (e entry) String() string {
+	return e.ip + " " + e.hostname
+}
+
+type hostsFile struct {
+	before  []string
+	managed []entry
+	after   []string
+}
+
+func parseHostsFile(lines []string) (*hostsFile, error) {
+	ret := &hostsFile{}
+
+	start := slices.Index(lines, managedBlockStart)
+	if start < 0 {
+		if slices.Contains(lines, managedBlockEnd) {
+			return nil, errors.New("end of managed block without start")
+		}
+		// drop the trailing empty line of the final newline, it's added again after the managed block
+		ret.before = slices.Clone(lines)
+		if len(ret.before) > 0 && ret.before[len(ret.before)-1] == "" {
+			ret.before = ret.before[:len(ret.before)-1]
+		}
+		return ret, nil
+	}
+
+	end := slices.Index(lines[start:], managedBlockEnd)
+	if end < 0 {
+		return nil, errors.New("start of managed block without end")
+	}
+	end += start
+
+	ret.before = slices.Clone(lines[:start])
+	ret.after = slices.Clone(lines[end+1:])
+	for _, line := range lines[start+1 : end] {
+		content, _, _ := strings.Cut(line, "#")
+		fields := strings.Fields(content)
+		if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+			return nil, fmt.Errorf("invalid line in managed block: %q", line)
+		}
+		for _, hostname := range fields[1:] {
+			ret.managed = append(ret.managed, entry{ip: fields[0], hostname: normalizeName(hostname)})
+		}
+	}
+
+	return ret, nil
+}
+
+// replace replaces the entries of the hostname with the given addresses and returns whether the entries changed.
+func (h *hostsFile) replace(hostname string, ips []string) bool {
+	hostname = normalizeName(hostname)
+
+	var current []string
+	for _, e := range h.managed {
+		if e.hostname == hostname {
+			current = append(current, e.ip)
+		}
+	}
+	if slices.Equal(current, ips) {
+		return false
+	}
+
+	h.managed = slices.DeleteFunc(h.managed, func(e entry) bool {
+		return e.hostname == hostname
+	})
+	for _, ip := range ips {
+		h.managed = append(h.managed, entry{ip: ip, hostname: hostname})
+	}
+	return true
+}
+
+func (h *hostsFile) lines() []string {
+	// keep the block stable regardless of the order hostnames have been updated in
+	managed := slices.Clone(h.managed)
+	slices.SortStableFunc(managed, func(a, b entry) int {
+		return strings.Compare(a.hostname, b.hostname)
+	})
+
+	ret := slices.Clone(h.before)
+	ret = append(ret, managedBlockStart)
+	for _, e := range managed {
+		ret = append(ret, e.String())
+	}
+	ret = append(ret, managedBlockEnd)
+	if len(h.after) == 0 {
+		return append(ret, "")
+	}
+	return append(ret, h.after...)
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+type HostsFile struct {
+	filePath string
+	backups  int
+}
+
+type HostsFileOpts func(*HostsFile) error
+
+// WithBackups keeps the given amount of timestamped backups of the previous versions of the hosts file. Zero disables
+// backups.
+func WithBackups(backups int) HostsFileOpts {
+	return func(h *HostsFile) error {
+		if backups < 0 {
+			return errors.New("amount of backups must not be negative")
+		}
+		h.backups = backups
+		return nil
+	}
+}
+
+// NewHostsFile manages the hosts file at filePath, the lines outside the managed block are left untouched.
+func NewHostsFile(filePath string, opts ...HostsFileOpts) (*HostsFile, error) {
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, fmt.Errorf("hosts file %q is not accessible: %w", filePath, err)
+	}
+	if !files.IsWritable(filePath) {
+		return nil, fmt.Errorf("hosts file %q is not writable", filePath)
+	}
+
+	ret := &HostsFile{
+		filePath: filePath,
+		backups:  defaultBackups,
+	}
+
+	var errs error
+	for _, opt := range opts {
+		if err := opt(ret); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	return ret, errs
+}
+
+func (h *HostsFile) ReadHosts() ([]string, error) {
+	content, err := os.ReadFile(h.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hosts file: %w", err)
+	}
+
+	return strings.Split(string(content), "\n"), nil
+}
+
+// WriteHosts atomically replaces the hosts file after keeping a backup of its current content.
+func (h *HostsFile) WriteHosts(lines []string) error {
+	if h.backups > 0 {
+		previous, err := os.ReadFile(h.filePath)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not read current hosts file: %w", err)
+		}
+		if previous != nil {
+			if err := files.Backup(h.filePath, previous, h.backups, defaultFileMode); err != nil {
+				return err
+			}
+		}
+	}
+
+	return files.WriteAtomic(h.filePath, []byte(strings.Join(lines, "\n")), defaultFileMode)
+}
@@ -0,0 +1,134 @@
+package hosts
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal"
+)
+
+type dummyHostsFile struct {
+	read    []string
+	written []string
+}
+
+func (d *dummyHostsFile) ReadHosts() ([]string, error) {
+	return d.read, nil
+}
+
+func (d *dummyHostsFile) WriteHosts(lines []string) error {
+	d.written = lines
+	return nil
+}
+
+func TestHosts_Apply(t *testing.T) {
+	fs := &dummyHostsFile{read: []string{
+		"127.0.0.1 localhost",
+		"::1 localhost",
+		"",
+	}}
+	h, err := NewHosts(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []internal.ManagedDnsRecord{
+		{DnsRecord: internal.DnsRecord{DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}},
+		{DnsRecord: internal.DnsRecord{DnsType: "AAAA", Ip: net.ParseIP("2001:db8::1"), Ttl: 60}},
+		{DnsRecord: internal.DnsRecord{DnsType: "TXT", Txt: "not expressible", Ttl: 60}},
+	}
+	updated, err := h.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"www.example.com": records, "mail.example.com.": records[:1]})
+	if err != nil || !updated {
+		t.Fatalf("Apply() = %v, %v", updated, err)
+	}
+
+	want := []string{
+		"127.0.0.1 localhost",
+		"::1 localhost",
+		managedBlockStart,
+		"10.0.0.1 mail.example.com",
+		"10.0.0.1 www.example.com",
+		"2001:db8::1 www.example.com",
+		managedBlockEnd,
+		"",
+	}
+	if !reflect.DeepEqual(fs.written, want) {
+		t.Errorf("written = %q, want %q", fs.written, want)
+	}
+
+	// unchanged records do not write the file
+	fs.read, fs.written = fs.written, nil
+	updated, err = h.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"www.example.com": records})
+	if err != nil || updated || fs.written != nil {
+		t.Errorf("expected no update, got %v, %v", updated, err)
+	}
+
+	ips, err := h.PublishedIps("www.example.com")
+	if err != nil || !reflect.DeepEqual(ips, []string{"10.0.0.1", "2001:db8::1"}) {
+		t.Errorf("PublishedIps() = %v, %v", ips, err)
+	}
+
+	// removing the records of a hostname keeps the other hostnames and the lines outside the block
+	updated, err = h.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"www.example.com": nil})
+	if err != nil || !updated {
+		t.Fatalf("Apply() = %v, %v", updated, err)
+	}
+	want = []string{
+		"127.0.0.1 localhost",
+		"::1 localhost",
+		managedBlockStart,
+		"10.0.0.1 mail.example.com",
+		managedBlockEnd,
+		"",
+	}
+	if !reflect.DeepEqual(fs.written, want) {
+		t.Errorf("written = %q, want %q", fs.written, want)
+	}
+}
+
+func TestParseHostsFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    []entry
+		wantErr bool
+	}{
+		{
+			name:  "no managed block",
+			lines: []string{"127.0.0.1 localhost", ""},
+		},
+		{
+			name:  "multiple hostnames per line",
+			lines: []string{managedBlockStart, "10.0.0.1 a.example.com B.example.com. # comment", managedBlockEnd},
+			want:  []entry{{ip: "10.0.0.1", hostname: "a.example.com"}, {ip: "10.0.0.1", hostname: "b.example.com"}},
+		},
+		{
+			name:    "invalid address",
+			lines:   []string{managedBlockStart, "10.0.0 a.example.com", managedBlockEnd},
+			wantErr: true,
+		},
+		{
+			name:    "missing end",
+			lines:   []string{managedBlockStart, "10.0.0.1 a.example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "missing start",
+			lines:   []string{"10.0.0.1 a.example.com", managedBlockEnd},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHostsFile(tt.lines)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHostsFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got.managed, tt.want) {
+				t.Errorf("parseHostsFile() = %v, want %v", got.managed, tt.want)
+			}
+		})
+	}
+}
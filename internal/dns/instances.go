@@ -0,0 +1,160 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"go.uber.org/multierr"
+)
+
+// Instance is a DNS server together with the service that serves its records.
+type Instance struct {
+	Db      internal.DnsDb
+	Service internal.Service
+}
+
+// Instances manages hostnames across several independent DNS servers, e.g. two unbound instances of a split-horizon
+// setup. It's both the DnsDb and the Service, only instances whose records have changed are reloaded.
+type Instances struct {
+	fallback *Instance
+	routes   map[string]*Instance
+
+	mutex sync.Mutex
+	dirty map[*Instance]bool
+}
+
+func NewInstances(fallback *Instance, routes map[string]*Instance) (*Instances, error) {
+	if fallback == nil || fallback.Db == nil || fallback.Service == nil {
+		return nil, errors.New("incomplete fallback instance supplied")
+	}
+	for _, instance := range routes {
+		if instance == nil || instance.Db == nil || instance.Service == nil {
+			return nil, errors.New("incomplete instance supplied")
+		}
+	}
+
+	return &Instances{fallback: fallback, routes: routes, dirty: map[*Instance]bool{}}, nil
+}
+
+// Apply applies the records of the hostnames of each instance in a single update of that instance.
+func (i *Instances) Apply(ctx context.Context, desired map[string][]internal.ManagedDnsRecord) (bool, error) {
+	grouped := map[*Instance]map[string][]internal.ManagedDnsRecord{}
+	for hostname, records := range desired {
+		instance := i.instance(hostname)
+		if grouped[instance] == nil {
+			grouped[instance] = map[string][]internal.ManagedDnsRecord{}
+		}
+		grouped[instance][hostname] = records
+	}
+
+	var changed bool
+	var errs error
+	for _, instance := range i.instances() {
+		if len(grouped[instance]) == 0 {
+			continue
+		}
+		updated, err := instance.Db.Apply(ctx, grouped[instance])
+		if updated {
+			changed = true
+			i.mutex.Lock()
+			i.dirty[instance] = true
+			i.mutex.Unlock()
+		}
+		errs = multierr.Append(errs, err)
+	}
+	return changed, errs
+}
+
+// ValidateConfig validates the config of all instances.
+func (i *Instances) ValidateConfig(ctx context.Context) error {
+	var errs error
+	for _, instance := range i.instances() {
+		errs = multierr.Append(errs, instance.Db.ValidateConfig(ctx))
+	}
+	return errs
+}
+
+// PublishedIps returns the published addresses of the hostname if its instance is able to read them.
+func (i *Instances) PublishedIps(hostname string) ([]string, error) {
+	reader, ok := i.instance(hostname).Db.(internal.DnsDbReader)
+	if !ok {
+		return nil, nil
+	}
+	return reader.PublishedIps(hostname)
+}
+
+// Reload reloads all instances whose records have changed since they have been reloaded the last time.
+func (i *Instances) Reload(ctx context.Context) error {
+	return i.forDirty(ctx, internal.Service.Reload)
+}
+
+// Restart restarts all instances whose records have changed since they have been reloaded the last time.
+func (i *Instances) Restart(ctx context.Context) error {
+	return i.forDirty(ctx, internal.Service.Restart)
+}
+
+// FlushCache flushes the names from the caches of the instances serving them.
+func (i *Instances) FlushCache(ctx context.Context, names []string) error {
+	grouped := map[*Instance][]string{}
+	for _, name := range names {
+		instance := i.instance(name)
+		grouped[instance] = append(grouped[instance], name)
+	}
+
+	var errs error
+	unsupported := 0
+	for instance, instanceNames := range grouped {
+		err := instance.Service.FlushCache(ctx, instanceNames)
+		if errors.Is(err, internal.ErrFlushNotSupported) {
+			unsupported++
+			continue
+		}
+		errs = multierr.Append(errs, err)
+	}
+
+	if errs == nil && unsupported > 0 && unsupported == len(grouped) {
+		return internal.ErrFlushNotSupported
+	}
+	return errs
+}
+
+// forDirty runs the operation for all changed instances, instances are only marked clean if it succeeded.
+func (i *Instances) forDirty(ctx context.Context, operation func(internal.Service, context.Context) error) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	var errs error
+	for _, instance := range i.instances() {
+		if !i.dirty[instance] {
+			continue
+		}
+		if err := operation(instance.Service, ctx); err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		delete(i.dirty, instance)
+	}
+	return errs
+}
+
+func (i *Instances) instance(hostname string) *Instance {
+	if instance, found := i.routes[hostname]; found {
+		return instance
+	}
+	return i.fallback
+}
+
+// instances returns every distinct instance once, the fallback comes first.
+func (i *Instances) instances() []*Instance {
+	ret := []*Instance{i.fallback}
+	seen := map[*Instance]bool{i.fallback: true}
+	for _, instance := range i.routes {
+		if !seen[instance] {
+			seen[instance] = true
+			ret = append(ret, instance)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,66 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal"
+)
+
+type dummyService struct {
+	reloads   int
+	reloadErr error
+}
+
+func (d *dummyService) Reload(_ context.Context) error {
+	d.reloads++
+	return d.reloadErr
+}
+
+func (d *dummyService) Restart(ctx context.Context) error {
+	return d.Reload(ctx)
+}
+
+func (d *dummyService) FlushCache(_ context.Context, _ []string) error {
+	return nil
+}
+
+func TestInstances_Reload(t *testing.T) {
+	internalSvc, externalSvc := &dummyService{}, &dummyService{}
+	internalDb, externalDb := &dummyDnsDb{}, &dummyDnsDb{}
+	external := &Instance{Db: externalDb, Service: externalSvc}
+	instances, err := NewInstances(&Instance{Db: internalDb, Service: internalSvc}, map[string]*Instance{"ha.example.com": external})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = instances.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"ha.example.com": nil})
+	if len(externalDb.updated) != 1 || len(internalDb.updated) != 0 {
+		t.Fatalf("expected only the external instance to be updated, got %v and %v", externalDb.updated, internalDb.updated)
+	}
+
+	externalSvc.reloadErr = errors.New("reload failed")
+	if err := instances.Reload(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if internalSvc.reloads != 0 || externalSvc.reloads != 1 {
+		t.Fatalf("expected only the changed instance to be reloaded, got %d and %d", internalSvc.reloads, externalSvc.reloads)
+	}
+
+	// the failed instance stays dirty and is reloaded again
+	externalSvc.reloadErr = nil
+	if err := instances.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if externalSvc.reloads != 2 {
+		t.Fatalf("expected failed instance to be reloaded again, got %d reloads", externalSvc.reloads)
+	}
+
+	if err := instances.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if internalSvc.reloads != 0 || externalSvc.reloads != 2 {
+		t.Errorf("expected no reloads without changes, got %d and %d", internalSvc.reloads, externalSvc.reloads)
+	}
+}
@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const maxErrorBodySize = 1024
+
+type ProviderOpts func(*apiClient) error
+
+// WithBaseUrl overrides the url of the provider's API, e.g. for another region or a proxy.
+func WithBaseUrl(url string) ProviderOpts {
+	return func(c *apiClient) error {
+		if url == "" {
+			return errors.New("empty base url supplied")
+		}
+		c.baseUrl = strings.TrimSuffix(url, "/")
+		return nil
+	}
+}
+
+// WithHttpClient sets the client used to talk to the provider's API.
+func WithHttpClient(client *http.Client) ProviderOpts {
+	return func(c *apiClient) error {
+		if client == nil {
+			return errors.New("nil http client supplied")
+		}
+		c.client = client
+		return nil
+	}
+}
+
+// apiClient sends JSON requests to the API of a provider.
+type apiClient struct {
+	baseUrl string
+	client  *http.Client
+	// authenticate adds the credentials to the request, the body is passed for providers that sign requests.
+	authenticate func(req *http.Request, body []byte)
+}
+
+func newApiClient(baseUrl string, authenticate func(req *http.Request, body []byte), opts []ProviderOpts) (apiClient, error) {
+	ret := apiClient{
+		baseUrl:      baseUrl,
+		client:       &http.Client{Timeout: providerTimeout},
+		authenticate: authenticate,
+	}
+
+	var errs []error
+	for _, opt := range opts {
+		if err := opt(&ret); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return ret, errors.Join(errs...)
+}
+
+func (c *apiClient) do(ctx context.Context, method, path string, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseUrl+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.authenticate(req, body)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return fmt.Errorf("%s %s returned %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// getText sends a GET request and returns the body as text, it's used for update protocols that do not speak JSON.
+// The query is left out of errors, as some protocols pass credentials as query parameters.
+func (c *apiClient) getText(ctx context.Context, path string, query url.Values) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseUrl+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	c.authenticate(req, nil)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// the error of the client contains the url
+		return "", fmt.Errorf("GET %s failed: %w", path, errors.Unwrap(err))
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("GET %s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return strings.TrimSpace(string(body)), nil
+}
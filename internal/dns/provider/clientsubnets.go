@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/soerenschneider/dns-ha/internal"
+)
+
+// luaType is the type of PowerDNS records whose answers are computed by a LUA snippet, the value is the type of the
+// answers followed by the quoted snippet.
+const luaType = "LUA"
+
+var (
+	luaViewBranch    = regexp.MustCompile(`if netmask\(\{([^}]*)\}\) then return \{([^}]*)\} end `)
+	luaDefaultBranch = regexp.MustCompile(`return \{([^}]*)\}$`)
+)
+
+type DbOpts func(*Db) error
+
+// WithClientSubnets answers the records of the views of the hostname, e.g. "ha.example.com@office", to clients of the
+// subnets of the view, all other clients receive the records of the hostname. The records are published as LUA
+// records that match the EDNS Client Subnet of the query, or the address of the resolver if the query carries none,
+// so the provider needs to support LUA records.
+func WithClientSubnets(hostname string, views map[string][]string) DbOpts {
+	return func(d *Db) error {
+		if len(views) == 0 {
+			return fmt.Errorf("no views supplied for hostname %q", hostname)
+		}
+
+		policy := clientSubnets{views: slices.Sorted(maps.Keys(views)), subnets: map[string][]string{}}
+		var errs []error
+		for view, subnets := range views {
+			if len(subnets) == 0 {
+				errs = append(errs, fmt.Errorf("no subnets supplied for view %q of hostname %q", view, hostname))
+			}
+			for _, subnet := range subnets {
+				prefix, err := netip.ParsePrefix(subnet)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("invalid subnet for view %q of hostname %q: %w", view, hostname, err))
+					continue
+				}
+				policy.subnets[view] = append(policy.subnets[view], prefix.Masked().String())
+			}
+		}
+		d.clientSubnets[hostname] = policy
+		return errors.Join(errs...)
+	}
+}
+
+// clientSubnets assigns the subnets of clients to the views of a hostname. Views are matched in alphabetical order,
+// the first view that contains the client wins.
+type clientSubnets struct {
+	views   []string
+	subnets map[string][]string
+}
+
+// luaRecords returns a LUA record per address family that answers the records of the matching view. Clients of views
+// without records of the address family receive the records of the hostname. All records share the lowest ttl, as
+// PowerDNS manages a single ttl for all LUA records of a name.
+func (c clientSubnets) luaRecords(hostname, name string, desired map[string][]internal.ManagedDnsRecord) []Record {
+	var ret []Record
+	ttl := 0
+	addresses := func(records []internal.ManagedDnsRecord, rtype string) []string {
+		var values []string
+		for _, record := range records {
+			if record.DnsType == rtype {
+				values = append(values, record.Data())
+				if ttl == 0 || int(record.Ttl) < ttl {
+					ttl = int(record.Ttl)
+				}
+			}
+		}
+		slices.Sort(values)
+		return values
+	}
+
+	for _, rtype := range []string{"A", "AAAA"} {
+		var code strings.Builder
+		code.WriteString(";")
+		for _, view := range c.views {
+			values := addresses(desired[hostname+internal.ViewSeparator+view], rtype)
+			if len(values) > 0 {
+				fmt.Fprintf(&code, "if netmask({%s}) then return {%s} end ", luaList(c.subnets[view]), luaList(values))
+			}
+		}
+		values := addresses(desired[hostname], rtype)
+		if len(values) == 0 && code.Len() == 1 {
+			continue
+		}
+		fmt.Fprintf(&code, "return {%s}", luaList(values))
+		ret = append(ret, Record{Name: name, Type: luaType, Value: rtype + " " + strconv.Quote(code.String())})
+	}
+
+	for i := range ret {
+		ret[i].Ttl = ttl
+	}
+	return ret
+}
+
+// publishedIps returns the addresses the LUA records answer to clients of the view, the hostname's records if the
+// view is empty.
+func (c clientSubnets) publishedIps(view string, records []Record) []string {
+	var ips []string
+	for _, record := range records {
+		if record.Type != luaType {
+			continue
+		}
+		_, quoted, _ := strings.Cut(record.Value, " ")
+		code, err := strconv.Unquote(quoted)
+		if err != nil {
+			continue
+		}
+
+		answer := luaDefaultBranch.FindStringSubmatch(code)
+		if view != "" {
+			subnets := luaList(c.subnets[view])
+			for _, branch := range luaViewBranch.FindAllStringSubmatch(code, -1) {
+				if branch[1] == subnets {
+					answer = branch
+					break
+				}
+			}
+		}
+		if answer != nil {
+			ips = append(ips, parseLuaList(answer[len(answer)-1])...)
+		}
+	}
+	return ips
+}
+
+func luaList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = "'" + value + "'"
+	}
+	return strings.Join(quoted, ",")
+}
+
+func parseLuaList(list string) []string {
+	var ret []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.Trim(strings.TrimSpace(value), "'"); value != "" {
+			ret = append(ret, value)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const desecBaseUrl = "https://desec.io/api/v1"
+
+// Desec manages records using the deSEC API, which manages all records of a name and type as a single set.
+type Desec struct {
+	api apiClient
+}
+
+type desecRrset struct {
+	Subname string   `json:"subname"`
+	Type    string   `json:"type"`
+	Ttl     int      `json:"ttl"`
+	Records []string `json:"records"`
+}
+
+// NewDesec authenticates using an API token.
+func NewDesec(token string, opts ...ProviderOpts) (*Desec, error) {
+	if token == "" {
+		return nil, errors.New("empty token supplied")
+	}
+
+	api, err := newApiClient(desecBaseUrl, func(req *http.Request, _ []byte) {
+		req.Header.Set("Authorization", "Token "+token)
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Desec{api: api}, nil
+}
+
+func (d *Desec) GetRecords(ctx context.Context, zone, name string) ([]Record, error) {
+	var rrsets []desecRrset
+	path := fmt.Sprintf("/domains/%s/rrsets/?subname=%s", url.PathEscape(normalizeName(zone)), url.QueryEscape(name))
+	if err := d.api.do(ctx, http.MethodGet, path, nil, &rrsets); err != nil {
+		return nil, err
+	}
+
+	var ret []Record
+	for _, rrset := range rrsets {
+		for _, value := range rrset.Records {
+			ret = append(ret, Record{Name: name, Type: rrset.Type, Value: value, Ttl: rrset.Ttl})
+		}
+	}
+	return ret, nil
+}
+
+func (d *Desec) SetRecords(ctx context.Context, zone, name, rtype string, records []Record) error {
+	path := fmt.Sprintf("/domains/%s/rrsets/%s/%s/", url.PathEscape(normalizeName(zone)), url.PathEscape(apexName(name)), url.PathEscape(rtype))
+	if len(records) == 0 {
+		return d.api.do(ctx, http.MethodDelete, path, nil, nil)
+	}
+
+	// all records of a set share the ttl
+	rrset := desecRrset{Subname: name, Type: rtype, Ttl: records[0].Ttl}
+	for _, record := range records {
+		rrset.Records = append(rrset.Records, record.Value)
+		rrset.Ttl = min(rrset.Ttl, record.Ttl)
+	}
+	return d.api.do(ctx, http.MethodPut, path, rrset, nil)
+}
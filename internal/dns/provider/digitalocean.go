@@ -0,0 +1,95 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	digitalOceanBaseUrl = "https://api.digitalocean.com/v2"
+	digitalOceanPerPage = 200
+)
+
+// DigitalOcean manages records using the DigitalOcean domains API.
+type DigitalOcean struct {
+	api apiClient
+}
+
+type digitalOceanRecord struct {
+	ID   int    `json:"id,omitempty"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Data string `json:"data"`
+	Ttl  int    `json:"ttl"`
+}
+
+func NewDigitalOcean(token string, opts ...ProviderOpts) (*DigitalOcean, error) {
+	if token == "" {
+		return nil, errors.New("empty token supplied")
+	}
+
+	api, err := newApiClient(digitalOceanBaseUrl, func(req *http.Request, _ []byte) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DigitalOcean{api: api}, nil
+}
+
+func (d *DigitalOcean) GetRecords(ctx context.Context, zone, name string) ([]Record, error) {
+	fqdn := normalizeName(zone)
+	if name != "" {
+		fqdn = name + "." + fqdn
+	}
+
+	var ret []Record
+	for page := 1; ; page++ {
+		var resp struct {
+			Records []digitalOceanRecord `json:"domain_records"`
+		}
+		query := url.Values{
+			"name":     []string{fqdn},
+			"per_page": []string{strconv.Itoa(digitalOceanPerPage)},
+			"page":     []string{strconv.Itoa(page)},
+		}
+		if err := d.api.do(ctx, http.MethodGet, d.recordsPath(zone)+"?"+query.Encode(), nil, &resp); err != nil {
+			return nil, err
+		}
+
+		for _, record := range resp.Records {
+			if record.Name == apexName(name) {
+				ret = append(ret, Record{ID: strconv.Itoa(record.ID), Name: name, Type: record.Type, Value: record.Data, Ttl: record.Ttl})
+			}
+		}
+		if len(resp.Records) < digitalOceanPerPage {
+			return ret, nil
+		}
+	}
+}
+
+func (d *DigitalOcean) SetRecords(ctx context.Context, zone, name, rtype string, records []Record) error {
+	existing, err := d.GetRecords(ctx, zone, name)
+	if err != nil {
+		return err
+	}
+	existing = filterType(existing, rtype)
+
+	create := func(ctx context.Context, record Record) error {
+		body := digitalOceanRecord{Type: rtype, Name: apexName(name), Data: record.Value, Ttl: record.Ttl}
+		return d.api.do(ctx, http.MethodPost, d.recordsPath(zone), body, nil)
+	}
+	remove := func(ctx context.Context, record Record) error {
+		return d.api.do(ctx, http.MethodDelete, fmt.Sprintf("%s/%s", d.recordsPath(zone), url.PathEscape(record.ID)), nil, nil)
+	}
+	return setIndividually(ctx, existing, records, create, remove)
+}
+
+func (d *DigitalOcean) recordsPath(zone string) string {
+	return fmt.Sprintf("/domains/%s/records", url.PathEscape(normalizeName(zone)))
+}
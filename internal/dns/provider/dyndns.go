@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const duckDnsBaseUrl = "https://www.duckdns.org"
+
+// dynDnsRecords remembers the records set last at dynamic DNS services, whose update protocols can not read records.
+// The records are unknown after a start, so the first update of each hostname is always sent.
+type dynDnsRecords struct {
+	mutex   sync.Mutex
+	records map[string][]Record
+}
+
+func (d *dynDnsRecords) get(hostname string) []Record {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return slices.Clone(d.records[hostname])
+}
+
+// merge returns the records of the hostname with the records of the type replaced.
+func (d *dynDnsRecords) merge(hostname, rtype string, records []Record) []Record {
+	merged := slices.DeleteFunc(d.get(hostname), func(r Record) bool {
+		return r.Type == rtype
+	})
+	for _, record := range records {
+		record.Type = rtype
+		merged = append(merged, record)
+	}
+	return merged
+}
+
+func (d *dynDnsRecords) set(hostname string, records []Record) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.records[hostname] = records
+}
+
+// addressOf returns the address of the single record of the type or the empty string if there is none.
+func addressOf(records []Record, rtype string) string {
+	for _, record := range records {
+		if record.Type == rtype {
+			return record.Value
+		}
+	}
+	return ""
+}
+
+// checkDynDnsRecords returns an error for records that dynamic DNS services can not publish.
+func checkDynDnsRecords(rtype string, records []Record) error {
+	if rtype != "A" && rtype != "AAAA" {
+		return fmt.Errorf("%s records are not supported by dynamic dns services", rtype)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("%s records can not be removed at dynamic dns services", rtype)
+	}
+	if len(records) > 1 {
+		return fmt.Errorf("dynamic dns services publish a single %s record only, got %d", rtype, len(records))
+	}
+	return nil
+}
+
+func fqdnOf(zone, name string) string {
+	if name == "" {
+		return normalizeName(zone)
+	}
+	return name + "." + normalizeName(zone)
+}
+
+// DynDns2 updates records using the dyndns2 protocol spoken by many dynamic DNS services. The protocol can not read
+// records and publishes a single address per address family.
+type DynDns2 struct {
+	api     apiClient
+	updated dynDnsRecords
+}
+
+// NewDynDns2 sends updates to the given url, e.g. "https://update.dedyn.io/nic/update", and authenticates using basic
+// authentication.
+func NewDynDns2(updateUrl, username, password string, opts ...ProviderOpts) (*DynDns2, error) {
+	if updateUrl == "" {
+		return nil, errors.New("empty update url supplied")
+	}
+	if username == "" || password == "" {
+		return nil, errors.New("empty credentials supplied")
+	}
+
+	api, err := newApiClient(strings.TrimSuffix(updateUrl, "/"), func(req *http.Request, _ []byte) {
+		req.SetBasicAuth(username, password)
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DynDns2{api: api, updated: dynDnsRecords{records: map[string][]Record{}}}, nil
+}
+
+func (d *DynDns2) GetRecords(_ context.Context, zone, name string) ([]Record, error) {
+	return d.updated.get(fqdnOf(zone, name)), nil
+}
+
+// SetRecords sends the addresses of both address families, so the update does not drop the other family.
+func (d *DynDns2) SetRecords(ctx context.Context, zone, name, rtype string, records []Record) error {
+	if err := checkDynDnsRecords(rtype, records); err != nil {
+		return err
+	}
+
+	hostname := fqdnOf(zone, name)
+	merged := d.updated.merge(hostname, rtype, records)
+	var ips []string
+	for _, record := range merged {
+		ips = append(ips, record.Value)
+	}
+
+	query := url.Values{"hostname": {hostname}, "myip": {strings.Join(ips, ",")}}
+	resp, err := d.api.getText(ctx, "", query)
+	if err != nil {
+		return err
+	}
+	// the response starts with a status code, followed by the published addresses
+	if code, _, _ := strings.Cut(resp, " "); code != "good" && code != "nochg" {
+		return fmt.Errorf("update of %q failed: %s", hostname, resp)
+	}
+
+	d.updated.set(hostname, merged)
+	return nil
+}
+
+// DuckDns updates subdomains of duckdns.org. Like dyndns2, the API can not read records and publishes a single address
+// per address family. DuckDNS detects the IPv4 address of the sender if a hostname has no A record.
+type DuckDns struct {
+	api     apiClient
+	token   string
+	updated dynDnsRecords
+}
+
+func NewDuckDns(token string, opts ...ProviderOpts) (*DuckDns, error) {
+	if token == "" {
+		return nil, errors.New("empty token supplied")
+	}
+
+	// the token is passed as query parameter
+	api, err := newApiClient(duckDnsBaseUrl, func(_ *http.Request, _ []byte) {}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DuckDns{api: api, token: token, updated: dynDnsRecords{records: map[string][]Record{}}}, nil
+}
+
+func (d *DuckDns) GetRecords(_ context.Context, zone, name string) ([]Record, error) {
+	return d.updated.get(fqdnOf(zone, name)), nil
+}
+
+func (d *DuckDns) SetRecords(ctx context.Context, zone, name, rtype string, records []Record) error {
+	if err := checkDynDnsRecords(rtype, records); err != nil {
+		return err
+	}
+	if name == "" {
+		return fmt.Errorf("only subdomains of %q can be updated", zone)
+	}
+
+	hostname := fqdnOf(zone, name)
+	merged := d.updated.merge(hostname, rtype, records)
+	query := url.Values{
+		"domains": {name},
+		"token":   {d.token},
+		"ip":      {addressOf(merged, "A")},
+		"ipv6":    {addressOf(merged, "AAAA")},
+	}
+	resp, err := d.api.getText(ctx, "/update", query)
+	if err != nil {
+		return err
+	}
+	if resp != "OK" {
+		return fmt.Errorf("update of %q failed: %s", hostname, resp)
+	}
+
+	d.updated.set(hostname, merged)
+	return nil
+}
@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const gandiBaseUrl = "https://api.gandi.net/v5/livedns"
+
+// Gandi manages records using the Gandi LiveDNS API, which manages all records of a name and type as a single set.
+type Gandi struct {
+	api apiClient
+}
+
+type gandiRrset struct {
+	Name   string   `json:"rrset_name,omitempty"`
+	Type   string   `json:"rrset_type,omitempty"`
+	Ttl    int      `json:"rrset_ttl"`
+	Values []string `json:"rrset_values"`
+}
+
+// NewGandi authenticates using a personal access token.
+func NewGandi(token string, opts ...ProviderOpts) (*Gandi, error) {
+	if token == "" {
+		return nil, errors.New("empty token supplied")
+	}
+
+	api, err := newApiClient(gandiBaseUrl, func(req *http.Request, _ []byte) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Gandi{api: api}, nil
+}
+
+func (g *Gandi) GetRecords(ctx context.Context, zone, name string) ([]Record, error) {
+	var rrsets []gandiRrset
+	path := fmt.Sprintf("/domains/%s/records/%s", url.PathEscape(normalizeName(zone)), url.PathEscape(apexName(name)))
+	if err := g.api.do(ctx, http.MethodGet, path, nil, &rrsets); err != nil {
+		return nil, err
+	}
+
+	var ret []Record
+	for _, rrset := range rrsets {
+		for _, value := range rrset.Values {
+			ret = append(ret, Record{Name: name, Type: rrset.Type, Value: value, Ttl: rrset.Ttl})
+		}
+	}
+	return ret, nil
+}
+
+func (g *Gandi) SetRecords(ctx context.Context, zone, name, rtype string, records []Record) error {
+	path := fmt.Sprintf("/domains/%s/records/%s/%s", url.PathEscape(normalizeName(zone)), url.PathEscape(apexName(name)), url.PathEscape(rtype))
+	if len(records) == 0 {
+		return g.api.do(ctx, http.MethodDelete, path, nil, nil)
+	}
+
+	// all records of a set share the ttl
+	rrset := gandiRrset{Ttl: records[0].Ttl}
+	for _, record := range records {
+		rrset.Values = append(rrset.Values, record.Value)
+		rrset.Ttl = min(rrset.Ttl, record.Ttl)
+	}
+	return g.api.do(ctx, http.MethodPut, path, rrset, nil)
+}
@@ -0,0 +1,133 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+const hetznerBaseUrl = "https://dns.hetzner.com/api/v1"
+
+// Hetzner manages records using the Hetzner DNS API.
+type Hetzner struct {
+	api apiClient
+
+	mutex   sync.Mutex
+	zoneIds map[string]string
+}
+
+type hetznerRecord struct {
+	ID     string `json:"id,omitempty"`
+	ZoneID string `json:"zone_id"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Ttl    int    `json:"ttl"`
+}
+
+func NewHetzner(token string, opts ...ProviderOpts) (*Hetzner, error) {
+	if token == "" {
+		return nil, errors.New("empty token supplied")
+	}
+
+	api, err := newApiClient(hetznerBaseUrl, func(req *http.Request, _ []byte) {
+		req.Header.Set("Auth-API-Token", token)
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Hetzner{api: api, zoneIds: map[string]string{}}, nil
+}
+
+func (h *Hetzner) GetRecords(ctx context.Context, zone, name string) ([]Record, error) {
+	_, records, err := h.records(ctx, zone, name)
+	return records, err
+}
+
+func (h *Hetzner) SetRecords(ctx context.Context, zone, name, rtype string, records []Record) error {
+	zoneId, existing, err := h.records(ctx, zone, name)
+	if err != nil {
+		return err
+	}
+	existing = filterType(existing, rtype)
+
+	create := func(ctx context.Context, record Record) error {
+		body := hetznerRecord{ZoneID: zoneId, Type: rtype, Name: apexName(name), Value: record.Value, Ttl: record.Ttl}
+		return h.api.do(ctx, http.MethodPost, "/records", body, nil)
+	}
+	remove := func(ctx context.Context, record Record) error {
+		return h.api.do(ctx, http.MethodDelete, "/records/"+url.PathEscape(record.ID), nil, nil)
+	}
+	return setIndividually(ctx, existing, records, create, remove)
+}
+
+func (h *Hetzner) records(ctx context.Context, zone, name string) (string, []Record, error) {
+	zoneId, err := h.zoneId(ctx, zone)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var resp struct {
+		Records []hetznerRecord `json:"records"`
+	}
+	if err := h.api.do(ctx, http.MethodGet, "/records?zone_id="+url.QueryEscape(zoneId), nil, &resp); err != nil {
+		return "", nil, err
+	}
+
+	var ret []Record
+	for _, record := range resp.Records {
+		if record.Name == apexName(name) {
+			ret = append(ret, Record{ID: record.ID, Name: name, Type: record.Type, Value: record.Value, Ttl: record.Ttl})
+		}
+	}
+	return zoneId, ret, nil
+}
+
+func (h *Hetzner) zoneId(ctx context.Context, zone string) (string, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if id, found := h.zoneIds[zone]; found {
+		return id, nil
+	}
+
+	var resp struct {
+		Zones []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"zones"`
+	}
+	if err := h.api.do(ctx, http.MethodGet, "/zones?name="+url.QueryEscape(normalizeName(zone)), nil, &resp); err != nil {
+		return "", err
+	}
+
+	for _, z := range resp.Zones {
+		if normalizeName(z.Name) == normalizeName(zone) {
+			h.zoneIds[zone] = z.ID
+			return z.ID, nil
+		}
+	}
+	return "", fmt.Errorf("zone %q not found", zone)
+}
+
+// apexName returns the name used by most providers for the apex of the zone.
+func apexName(name string) string {
+	if name == "" {
+		return "@"
+	}
+	return name
+}
+
+func filterType(records []Record, rtype string) []Record {
+	var ret []Record
+	for _, record := range records {
+		if record.Type == rtype {
+			ret = append(ret, record)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,157 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+)
+
+const DefaultPowershellBinary = "powershell.exe"
+
+// MsDns manages records of a Microsoft DNS Server using the DnsServer PowerShell module. Changes are applied through
+// the management API of the server and are served immediately.
+type MsDns struct {
+	binary string
+	// computerName is the DNS server to manage, the local server if empty
+	computerName string
+
+	run func(ctx context.Context, binary, script string) ([]byte, error)
+}
+
+type MsDnsOpts func(*MsDns) error
+
+// WithPowershell configures the PowerShell binary, e.g. "pwsh.exe".
+func WithPowershell(binary string) MsDnsOpts {
+	return func(m *MsDns) error {
+		if binary != "" {
+			m.binary = binary
+		}
+		return nil
+	}
+}
+
+// WithComputerName manages the records of a remote DNS server.
+func WithComputerName(computerName string) MsDnsOpts {
+	return func(m *MsDns) error {
+		if strings.ContainsAny(computerName, "'\"`$;") {
+			return fmt.Errorf("invalid computer name %q", computerName)
+		}
+		m.computerName = computerName
+		return nil
+	}
+}
+
+func NewMsDns(opts ...MsDnsOpts) (*MsDns, error) {
+	ret := &MsDns{binary: DefaultPowershellBinary, run: runPowershell}
+
+	var errs []error
+	for _, opt := range opts {
+		if err := opt(ret); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return ret, errors.Join(errs...)
+}
+
+type msDnsRecord struct {
+	Type  string `json:"Type"`
+	Ttl   int    `json:"Ttl"`
+	Value string `json:"Value"`
+}
+
+func (m *MsDns) GetRecords(ctx context.Context, zone, name string) ([]Record, error) {
+	// a missing name is reported as ObjectNotFound, all other errors are fatal
+	script := fmt.Sprintf(`$records = @(Get-DnsServerResourceRecord -ZoneName %s -Name %s%s -ErrorAction SilentlyContinue -ErrorVariable lookupErr |
+	Where-Object { $_.RecordType -eq 'A' -or $_.RecordType -eq 'AAAA' -or $_.RecordType -eq 'TXT' } |
+	ForEach-Object { [pscustomobject]@{ Type = $_.RecordType; Ttl = [int]$_.TimeToLive.TotalSeconds; Value = $(switch ($_.RecordType) { 'A' { $_.RecordData.IPv4Address.IPAddressToString } 'AAAA' { $_.RecordData.IPv6Address.IPAddressToString } 'TXT' { $_.RecordData.DescriptiveText } }) } })
+if ($lookupErr -and $lookupErr[0].CategoryInfo.Category -ne 'ObjectNotFound') { throw $lookupErr[0] }
+ConvertTo-Json -Compress -InputObject $records`, psQuote(normalizeName(zone)), psQuote(apexName(name)), m.computerNameArg())
+
+	output, err := m.run(ctx, m.binary, script)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []msDnsRecord
+	if err := json.Unmarshal(output, &records); err != nil {
+		return nil, fmt.Errorf("could not parse records: %w", err)
+	}
+
+	ret := make([]Record, 0, len(records))
+	for _, record := range records {
+		ret = append(ret, Record{Name: name, Type: record.Type, Value: record.Value, Ttl: record.Ttl})
+	}
+	return ret, nil
+}
+
+func (m *MsDns) SetRecords(ctx context.Context, zone, name, rtype string, records []Record) error {
+	existing, err := m.GetRecords(ctx, zone, name)
+	if err != nil {
+		return err
+	}
+	existing = filterType(existing, rtype)
+
+	create := func(ctx context.Context, record Record) error {
+		dataArg := "-A -IPv4Address"
+		switch rtype {
+		case "AAAA":
+			dataArg = "-AAAA -IPv6Address"
+		case "TXT":
+			dataArg = "-Txt -DescriptiveText"
+		}
+		script := fmt.Sprintf("Add-DnsServerResourceRecord -ZoneName %s -Name %s %s %s -TimeToLive ([TimeSpan]::FromSeconds(%d))%s",
+			psQuote(normalizeName(zone)), psQuote(apexName(name)), dataArg, psQuote(unquoteTxt(record.Value)), record.Ttl, m.computerNameArg())
+		_, err := m.run(ctx, m.binary, script)
+		return err
+	}
+	remove := func(ctx context.Context, record Record) error {
+		script := fmt.Sprintf("Remove-DnsServerResourceRecord -ZoneName %s -Name %s -RRType %s -RecordData %s -Force%s",
+			psQuote(normalizeName(zone)), psQuote(apexName(name)), rtype, psQuote(unquoteTxt(record.Value)), m.computerNameArg())
+		_, err := m.run(ctx, m.binary, script)
+		return err
+	}
+
+	// the server refuses to add an address that already exists, so addresses with a changed ttl are removed first
+	var unchanged []Record
+	for _, record := range existing {
+		ttlChanged := slices.ContainsFunc(records, func(r Record) bool {
+			return unquoteTxt(r.Value) == unquoteTxt(record.Value) && r.Ttl != record.Ttl
+		})
+		if !ttlChanged {
+			unchanged = append(unchanged, record)
+			continue
+		}
+		if err := remove(ctx, record); err != nil {
+			return err
+		}
+	}
+	return setIndividually(ctx, unchanged, records, create, remove)
+}
+
+func (m *MsDns) computerNameArg() string {
+	if m.computerName == "" {
+		return ""
+	}
+	return " -ComputerName " + psQuote(m.computerName)
+}
+
+// psQuote quotes the value as a literal PowerShell string.
+func psQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func runPowershell(ctx context.Context, binary, script string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, binary, "-NoProfile", "-NonInteractive", "-Command", "$ErrorActionPreference = 'Stop'\n"+script) //nolint G204
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w: %s", binary, err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha1" //nolint G505
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ovhBaseUrl = "https://eu.api.ovh.com/1.0"
+
+// Ovh manages records using the OVHcloud API. Changes are only served after the zone has been refreshed.
+type Ovh struct {
+	api apiClient
+	now func() time.Time
+}
+
+type ovhRecord struct {
+	ID        int    `json:"id,omitempty"`
+	FieldType string `json:"fieldType"`
+	SubDomain string `json:"subDomain"`
+	Target    string `json:"target"`
+	Ttl       int    `json:"ttl"`
+}
+
+// NewOvh authenticates using an application key and secret and a consumer key that has been granted access to the
+// /domain/zone API.
+func NewOvh(applicationKey, applicationSecret, consumerKey string, opts ...ProviderOpts) (*Ovh, error) {
+	if applicationKey == "" || applicationSecret == "" || consumerKey == "" {
+		return nil, errors.New("application key, application secret and consumer key are required")
+	}
+
+	ret := &Ovh{now: time.Now}
+	api, err := newApiClient(ovhBaseUrl, func(req *http.Request, body []byte) {
+		timestamp := strconv.FormatInt(ret.now().Unix(), 10)
+		req.Header.Set("X-Ovh-Application", applicationKey)
+		req.Header.Set("X-Ovh-Consumer", consumerKey)
+		req.Header.Set("X-Ovh-Timestamp", timestamp)
+		req.Header.Set("X-Ovh-Signature", ovhSignature(applicationSecret, consumerKey, req.Method, req.URL.String(), body, timestamp))
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	ret.api = api
+
+	return ret, nil
+}
+
+// ovhSignature signs the request as required by the OVHcloud API.
+func ovhSignature(applicationSecret, consumerKey, method, url string, body []byte, timestamp string) string {
+	hash := sha1.Sum([]byte(strings.Join([]string{applicationSecret, consumerKey, method, url, string(body), timestamp}, "+"))) //nolint G401
+	return "$1$" + hex.EncodeToString(hash[:])
+}
+
+func (o *Ovh) GetRecords(ctx context.Context, zone, name string) ([]Record, error) {
+	var ids []int
+	path := fmt.Sprintf("%s?subDomain=%s", o.recordsPath(zone), url.QueryEscape(name))
+	if err := o.api.do(ctx, http.MethodGet, path, nil, &ids); err != nil {
+		return nil, err
+	}
+
+	ret := make([]Record, 0, len(ids))
+	for _, id := range ids {
+		var record ovhRecord
+		if err := o.api.do(ctx, http.MethodGet, fmt.Sprintf("%s/%d", o.recordsPath(zone), id), nil, &record); err != nil {
+			return nil, err
+		}
+		ret = append(ret, Record{ID: strconv.Itoa(record.ID), Name: name, Type: record.FieldType, Value: record.Target, Ttl: record.Ttl})
+	}
+	return ret, nil
+}
+
+func (o *Ovh) SetRecords(ctx context.Context, zone, name, rtype string, records []Record) error {
+	existing, err := o.GetRecords(ctx, zone, name)
+	if err != nil {
+		return err
+	}
+	existing = filterType(existing, rtype)
+
+	create := func(ctx context.Context, record Record) error {
+		body := ovhRecord{FieldType: rtype, SubDomain: name, Target: record.Value, Ttl: record.Ttl}
+		return o.api.do(ctx, http.MethodPost, o.recordsPath(zone), body, nil)
+	}
+	remove := func(ctx context.Context, record Record) error {
+		return o.api.do(ctx, http.MethodDelete, o.recordsPath(zone)+"/"+url.PathEscape(record.ID), nil, nil)
+	}
+	if err := setIndividually(ctx, existing, records, create, remove); err != nil {
+		return err
+	}
+
+	return o.api.do(ctx, http.MethodPost, fmt.Sprintf("/domain/zone/%s/refresh", url.PathEscape(normalizeName(zone))), nil, nil)
+}
+
+func (o *Ovh) recordsPath(zone string) string {
+	return fmt.Sprintf("/domain/zone/%s/record", url.PathEscape(normalizeName(zone)))
+}
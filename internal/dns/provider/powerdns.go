@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// PowerDns manages records using the API of a PowerDNS authoritative server, which manages all records of a name and
+// type as a single set.
+type PowerDns struct {
+	api apiClient
+}
+
+type powerDnsZone struct {
+	Rrsets []powerDnsRrset `json:"rrsets"`
+}
+
+type powerDnsRrset struct {
+	Name       string           `json:"name"`
+	Type       string           `json:"type"`
+	Ttl        int              `json:"ttl,omitempty"`
+	ChangeType string           `json:"changetype,omitempty"`
+	Records    []powerDnsRecord `json:"records"`
+}
+
+type powerDnsRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+// NewPowerDns authenticates using an API key, the url points to the server, e.g.
+// "http://127.0.0.1:8081/api/v1/servers/localhost".
+func NewPowerDns(serverUrl, apiKey string, opts ...ProviderOpts) (*PowerDns, error) {
+	if serverUrl == "" {
+		return nil, errors.New("empty server url supplied")
+	}
+	if apiKey == "" {
+		return nil, errors.New("empty api key supplied")
+	}
+
+	opts = append([]ProviderOpts{WithBaseUrl(serverUrl)}, opts...)
+	api, err := newApiClient("", func(req *http.Request, _ []byte) {
+		req.Header.Set("X-API-Key", apiKey)
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PowerDns{api: api}, nil
+}
+
+func (p *PowerDns) GetRecords(ctx context.Context, zone, name string) ([]Record, error) {
+	var response powerDnsZone
+	if err := p.api.do(ctx, http.MethodGet, powerDnsZonePath(zone), nil, &response); err != nil {
+		return nil, err
+	}
+
+	fqdn := absoluteName(zone, name)
+	var ret []Record
+	for _, rrset := range response.Rrsets {
+		if normalizeName(rrset.Name) != normalizeName(fqdn) {
+			continue
+		}
+		for _, record := range rrset.Records {
+			if !record.Disabled {
+				ret = append(ret, Record{Name: name, Type: rrset.Type, Value: record.Content, Ttl: rrset.Ttl})
+			}
+		}
+	}
+	return ret, nil
+}
+
+func (p *PowerDns) SetRecords(ctx context.Context, zone, name, rtype string, records []Record) error {
+	rrset := powerDnsRrset{Name: absoluteName(zone, name), Type: rtype, ChangeType: "DELETE", Records: []powerDnsRecord{}}
+	if len(records) > 0 {
+		// all records of a set share the ttl
+		rrset.ChangeType = "REPLACE"
+		rrset.Ttl = records[0].Ttl
+		for _, record := range records {
+			rrset.Records = append(rrset.Records, powerDnsRecord{Content: record.Value})
+			rrset.Ttl = min(rrset.Ttl, record.Ttl)
+		}
+	}
+	return p.api.do(ctx, http.MethodPatch, powerDnsZonePath(zone), powerDnsZone{Rrsets: []powerDnsRrset{rrset}}, nil)
+}
+
+// powerDnsZonePath returns the path of the zone, PowerDNS identifies zones by their absolute name.
+func powerDnsZonePath(zone string) string {
+	return fmt.Sprintf("/zones/%s", url.PathEscape(normalizeName(zone)+"."))
+}
+
+// absoluteName returns the fully qualified name of the name relative to the zone.
+func absoluteName(zone, name string) string {
+	if name == "" {
+		return normalizeName(zone) + "."
+	}
+	return name + "." + normalizeName(zone) + "."
+}
@@ -0,0 +1,304 @@
+// Package provider manages records at hosted DNS providers through a small, libdns-style abstraction.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+)
+
+const (
+	HetznerProviderName      = "hetzner"
+	DigitalOceanProviderName = "digitalocean"
+	GandiProviderName        = "gandi"
+	OvhProviderName          = "ovh"
+	DesecProviderName        = "desec"
+	DynDns2ProviderName      = "dyndns2"
+	DuckDnsProviderName      = "duckdns"
+	PowerDnsProviderName     = "powerdns"
+
+	providerTimeout = 30 * time.Second
+)
+
+// Record is a single record of a zone. Name is relative to the zone, the apex of the zone is the empty string.
+type Record struct {
+	// ID identifies the record at providers that manage records individually.
+	ID    string
+	Name  string
+	Type  string
+	Value string
+	Ttl   int
+}
+
+// Provider reads and writes records of a zone hosted by a DNS provider.
+type Provider interface {
+	// GetRecords returns all records of the name in the zone.
+	GetRecords(ctx context.Context, zone, name string) ([]Record, error)
+	// SetRecords replaces all records of the name and type in the zone, no records removes them.
+	SetRecords(ctx context.Context, zone, name, rtype string, records []Record) error
+}
+
+// Zone assigns a hostname to a zone hosted by the given provider.
+type Zone struct {
+	Name     string
+	Provider Provider
+}
+
+// Db manages the records of the configured hostnames at their providers.
+type Db struct {
+	zones         map[string]Zone
+	clientSubnets map[string]clientSubnets
+	// desired remembers the records of the hostnames with client subnets and their views, as the records of all views
+	// are published together
+	desired map[string][]internal.ManagedDnsRecord
+}
+
+func NewDb(zones map[string]Zone, opts ...DbOpts) (*Db, error) {
+	for hostname, zone := range zones {
+		if zone.Provider == nil {
+			return nil, fmt.Errorf("nil provider supplied for hostname %q", hostname)
+		}
+		if _, err := relativeName(hostname, zone.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	ret := &Db{
+		zones:         zones,
+		clientSubnets: map[string]clientSubnets{},
+		desired:       map[string][]internal.ManagedDnsRecord{},
+	}
+
+	var errs []error
+	for _, opt := range opts {
+		if err := opt(ret); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for hostname := range ret.clientSubnets {
+		if _, found := zones[hostname]; !found {
+			errs = append(errs, fmt.Errorf("client subnets supplied for unknown hostname %q", hostname))
+		}
+	}
+	return ret, errors.Join(errs...)
+}
+
+// Apply sets the records of all hostnames at their providers. Providers manage records individually, so a failing
+// hostname does not prevent the others from being updated.
+func (d *Db) Apply(ctx context.Context, desired map[string][]internal.ManagedDnsRecord) (bool, error) {
+	// the views of hostnames with client subnets are published along with their hostname
+	hostnames := map[string]bool{}
+	for name, records := range desired {
+		hostname, _ := internal.SplitView(name)
+		if _, found := d.clientSubnets[hostname]; found {
+			d.desired[name] = records
+			name = hostname
+		}
+		hostnames[name] = true
+	}
+
+	var changed bool
+	var errs []error
+	for _, hostname := range slices.Sorted(maps.Keys(hostnames)) {
+		updated, err := d.apply(ctx, hostname, desired[hostname])
+		changed = changed || updated
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return changed, errors.Join(errs...)
+}
+
+func (d *Db) apply(ctx context.Context, hostname string, records []internal.ManagedDnsRecord) (bool, error) {
+	zone, name, err := d.lookup(hostname)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, providerTimeout)
+	defer cancel()
+
+	current, err := zone.Provider.GetRecords(ctx, zone.Name, name)
+	if err != nil {
+		return false, fmt.Errorf("could not get records of %q: %w", hostname, err)
+	}
+
+	wanted := map[string][]Record{}
+	rtypes := []string{"A", "AAAA"}
+	policy, hasClientSubnets := d.clientSubnets[hostname]
+	if hasClientSubnets {
+		records = d.desired[hostname]
+		// the LUA records are set before the plain records are removed, so the name never resolves to nothing
+		rtypes = append([]string{luaType}, rtypes...)
+		wanted[luaType] = policy.luaRecords(hostname, name, d.desired)
+	}
+
+	// TXT records are only managed if they are part of the desired records, so foreign TXT records are kept
+	if slices.ContainsFunc(records, func(r internal.ManagedDnsRecord) bool { return r.DnsType == "TXT" }) {
+		rtypes = append(rtypes, "TXT")
+	}
+	for _, record := range records {
+		if record.DnsType == "TXT" || !hasClientSubnets {
+			wanted[record.DnsType] = append(wanted[record.DnsType], Record{Name: name, Type: record.DnsType, Value: record.Data(), Ttl: int(record.Ttl)})
+		}
+	}
+
+	var updated bool
+	for _, rtype := range rtypes {
+		existing := slices.DeleteFunc(slices.Clone(current), func(r Record) bool {
+			return r.Type != rtype
+		})
+		if equalRecords(existing, wanted[rtype]) {
+			continue
+		}
+
+		slog.Debug("Setting records at provider", "hostname", hostname, "type", rtype, "records", wanted[rtype])
+		if err := zone.Provider.SetRecords(ctx, zone.Name, name, rtype, wanted[rtype]); err != nil {
+			return updated, fmt.Errorf("could not set %s records of %q: %w", rtype, hostname, err)
+		}
+		updated = true
+	}
+
+	return updated, nil
+}
+
+// ValidateConfig is a no-op, the providers validate records when they are set.
+func (d *Db) ValidateConfig(_ context.Context) error {
+	return nil
+}
+
+// PublishedIps returns the addresses of the A and AAAA records of the hostname at its provider. The addresses of
+// hostnames with client subnets are the addresses the LUA records answer to clients of the view.
+func (d *Db) PublishedIps(record string) ([]string, error) {
+	hostname, view := internal.SplitView(record)
+	zone, name, err := d.lookup(hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerTimeout)
+	defer cancel()
+
+	records, err := zone.Provider.GetRecords(ctx, zone.Name, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if policy, found := d.clientSubnets[hostname]; found {
+		return policy.publishedIps(view, records), nil
+	}
+
+	var ips []string
+	for _, record := range records {
+		if record.Type == "A" || record.Type == "AAAA" {
+			ips = append(ips, record.Value)
+		}
+	}
+	return ips, nil
+}
+
+func (d *Db) lookup(hostname string) (Zone, string, error) {
+	zone, found := d.zones[hostname]
+	if !found {
+		return Zone{}, "", fmt.Errorf("no provider configured for hostname %q", hostname)
+	}
+
+	name, err := relativeName(hostname, zone.Name)
+	return zone, name, err
+}
+
+// relativeName returns the name of the hostname relative to the zone.
+func relativeName(hostname, zone string) (string, error) {
+	hostname = normalizeName(hostname)
+	zone = normalizeName(zone)
+	if hostname == zone {
+		return "", nil
+	}
+
+	name, found := strings.CutSuffix(hostname, "."+zone)
+	if !found || zone == "" {
+		return "", fmt.Errorf("hostname %q is not part of zone %q", hostname, zone)
+	}
+	return name, nil
+}
+
+// unquoteTxt returns the text of a quoted TXT record value, some providers return the text without quotes.
+func unquoteTxt(value string) string {
+	if unquoted, err := strconv.Unquote(value); err == nil && strings.HasPrefix(value, `"`) {
+		return unquoted
+	}
+	return value
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// equalRecords compares the values and ttls of the records regardless of their order.
+func equalRecords(a, b []Record) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	key := func(r Record) string {
+		return fmt.Sprintf("%s %d", unquoteTxt(r.Value), r.Ttl)
+	}
+	keysA, keysB := make([]string, len(a)), make([]string, len(b))
+	for i := range a {
+		keysA[i], keysB[i] = key(a[i]), key(b[i])
+	}
+	slices.Sort(keysA)
+	slices.Sort(keysB)
+	return slices.Equal(keysA, keysB)
+}
+
+// diffRecords returns the existing records that need to be removed and the wanted records that need to be added for
+// providers that manage records individually. Records that already exist with the same ttl are kept.
+func diffRecords(existing, wanted []Record) (remove, add []Record) {
+	matches := func(a, b Record) bool {
+		return a.Value == b.Value && a.Ttl == b.Ttl
+	}
+
+	for _, record := range existing {
+		if !slices.ContainsFunc(wanted, func(w Record) bool { return matches(record, w) }) {
+			remove = append(remove, record)
+		}
+	}
+	for _, record := range wanted {
+		if !slices.ContainsFunc(existing, func(e Record) bool { return matches(record, e) }) {
+			add = append(add, record)
+		}
+	}
+	return remove, add
+}
+
+// setIndividually applies the records for providers that create and delete single records. New records are created
+// before obsolete records are removed, so the name never resolves to nothing during the change.
+func setIndividually(ctx context.Context, existing, wanted []Record, create, remove func(context.Context, Record) error) error {
+	toRemove, toAdd := diffRecords(existing, wanted)
+
+	var errs []error
+	for _, record := range toAdd {
+		if err := create(ctx, record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	for _, record := range toRemove {
+		if err := remove(ctx, record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
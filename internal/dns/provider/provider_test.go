@@ -0,0 +1,295 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal"
+)
+
+type dummyProvider struct {
+	records map[string][]Record
+	sets    int
+}
+
+func (d *dummyProvider) GetRecords(_ context.Context, _, name string) ([]Record, error) {
+	return d.records[name], nil
+}
+
+func (d *dummyProvider) SetRecords(_ context.Context, _, name, rtype string, records []Record) error {
+	d.sets++
+	d.records[name] = slices.DeleteFunc(d.records[name], func(r Record) bool { return r.Type == rtype })
+	d.records[name] = append(d.records[name], records...)
+	return nil
+}
+
+func TestDb_Apply(t *testing.T) {
+	p := &dummyProvider{records: map[string][]Record{
+		"www": {{Name: "www", Type: "A", Value: "10.0.0.1", Ttl: 60}},
+	}}
+	db, err := NewDb(map[string]Zone{"www.example.com": {Name: "example.com", Provider: p}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []internal.ManagedDnsRecord{
+		{DnsRecord: internal.DnsRecord{DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}},
+	}
+	updated, err := db.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"www.example.com": records})
+	if err != nil || updated || p.sets != 0 {
+		t.Fatalf("expected unchanged records to be skipped, got %v, %v", updated, err)
+	}
+
+	records = append(records, internal.ManagedDnsRecord{DnsRecord: internal.DnsRecord{DnsType: "AAAA", Ip: net.ParseIP("2001:db8::1"), Ttl: 60}})
+	updated, err = db.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"www.example.com": records})
+	if err != nil || !updated || p.sets != 1 {
+		t.Fatalf("expected only AAAA records to be set, got %v, %v, %d sets", updated, err, p.sets)
+	}
+
+	ips, err := db.PublishedIps("www.example.com")
+	if err != nil || !reflect.DeepEqual(ips, []string{"10.0.0.1", "2001:db8::1"}) {
+		t.Errorf("PublishedIps() = %v, %v", ips, err)
+	}
+}
+
+func TestDb_ApplyClientSubnets(t *testing.T) {
+	p := &dummyProvider{records: map[string][]Record{
+		"www": {{Name: "www", Type: "A", Value: "10.0.0.1", Ttl: 60}},
+	}}
+	db, err := NewDb(map[string]Zone{"www.example.com": {Name: "example.com", Provider: p}},
+		WithClientSubnets("www.example.com", map[string][]string{"office": {"192.168.1.7/24"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := func(ip string, ttl uint16) internal.ManagedDnsRecord {
+		return internal.ManagedDnsRecord{DnsRecord: internal.DnsRecord{DnsType: "A", Ip: net.ParseIP(ip), Ttl: ttl}}
+	}
+	desired := map[string][]internal.ManagedDnsRecord{
+		"www.example.com":        {record("10.0.0.1", 60)},
+		"www.example.com@office": {record("192.168.1.2", 30), record("192.168.1.1", 30)},
+	}
+	updated, err := db.Apply(context.Background(), desired)
+	if err != nil || !updated {
+		t.Fatalf("expected records to be updated, got %v, %v", updated, err)
+	}
+
+	want := []Record{{Name: "www", Type: "LUA", Ttl: 30, Value: `A ";if netmask({'192.168.1.0/24'}) then return {'192.168.1.1','192.168.1.2'} end return {'10.0.0.1'}"`}}
+	if !reflect.DeepEqual(p.records["www"], want) {
+		t.Errorf("records = %v, want %v", p.records["www"], want)
+	}
+
+	// the views are published along with the hostname, even if only the view changed
+	updated, err = db.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"www.example.com@office": {record("192.168.1.1", 30)}})
+	if err != nil || !updated || !strings.Contains(p.records["www"][0].Value, "return {'192.168.1.1'} end return {'10.0.0.1'}") {
+		t.Fatalf("expected view to be updated, got %v, %v, %v", updated, err, p.records["www"])
+	}
+
+	for name, want := range map[string][]string{"www.example.com": {"10.0.0.1"}, "www.example.com@office": {"192.168.1.1"}} {
+		ips, err := db.PublishedIps(name)
+		if err != nil || !reflect.DeepEqual(ips, want) {
+			t.Errorf("PublishedIps(%q) = %v, %v, want %v", name, ips, err, want)
+		}
+	}
+}
+
+func TestNewDb_zone(t *testing.T) {
+	if _, err := NewDb(map[string]Zone{"www.other.com": {Name: "example.com", Provider: &dummyProvider{}}}); err == nil {
+		t.Fatal("expected error for hostname outside of zone")
+	}
+}
+
+func TestDiffRecords(t *testing.T) {
+	existing := []Record{{ID: "1", Value: "10.0.0.1", Ttl: 60}, {ID: "2", Value: "10.0.0.2", Ttl: 60}}
+	wanted := []Record{{Value: "10.0.0.2", Ttl: 60}, {Value: "10.0.0.3", Ttl: 60}}
+
+	remove, add := diffRecords(existing, wanted)
+	if !reflect.DeepEqual(remove, []Record{{ID: "1", Value: "10.0.0.1", Ttl: 60}}) {
+		t.Errorf("remove = %v", remove)
+	}
+	if !reflect.DeepEqual(add, []Record{{Value: "10.0.0.3", Ttl: 60}}) {
+		t.Errorf("add = %v", add)
+	}
+}
+
+func TestHetzner_SetRecords(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Auth-API-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/zones":
+			_ = json.NewEncoder(w).Encode(map[string]any{"zones": []map[string]string{{"id": "z1", "name": "example.com"}}})
+		case r.Method == http.MethodGet && r.URL.Path == "/records":
+			_ = json.NewEncoder(w).Encode(map[string]any{"records": []hetznerRecord{
+				{ID: "r1", ZoneID: "z1", Type: "A", Name: "www", Value: "10.0.0.1", Ttl: 60},
+				{ID: "r2", ZoneID: "z1", Type: "A", Name: "mail", Value: "10.0.0.9", Ttl: 60},
+			}})
+		}
+	}))
+	defer server.Close()
+
+	h, err := NewHetzner("secret", WithBaseUrl(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.SetRecords(context.Background(), "example.com", "www", "A", []Record{{Value: "10.0.0.2", Ttl: 60}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"GET /zones?name=example.com",
+		"GET /records?zone_id=z1",
+		"POST /records",
+		"DELETE /records/r1",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+}
+
+func TestPowerDns(t *testing.T) {
+	var patches []powerDnsZone
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" || r.URL.Path != "/api/v1/servers/localhost/zones/example.com." {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			_ = json.NewEncoder(w).Encode(powerDnsZone{Rrsets: []powerDnsRrset{
+				{Name: "www.example.com.", Type: "A", Ttl: 60, Records: []powerDnsRecord{{Content: "10.0.0.1"}, {Content: "10.0.0.2", Disabled: true}}},
+				{Name: "mail.example.com.", Type: "A", Ttl: 60, Records: []powerDnsRecord{{Content: "10.0.0.9"}}},
+			}})
+		case http.MethodPatch:
+			var zone powerDnsZone
+			_ = json.NewDecoder(r.Body).Decode(&zone)
+			patches = append(patches, zone)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	p, err := NewPowerDns(server.URL+"/api/v1/servers/localhost", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	records, err := p.GetRecords(ctx, "example.com", "www")
+	if err != nil || !reflect.DeepEqual(records, []Record{{Name: "www", Type: "A", Value: "10.0.0.1", Ttl: 60}}) {
+		t.Errorf("GetRecords() = %v, %v", records, err)
+	}
+
+	if err := p.SetRecords(ctx, "example.com", "www", "A", []Record{{Value: "10.0.0.3", Ttl: 60}, {Value: "10.0.0.4", Ttl: 30}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetRecords(ctx, "example.com.", "", "AAAA", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []powerDnsZone{
+		{Rrsets: []powerDnsRrset{{Name: "www.example.com.", Type: "A", Ttl: 30, ChangeType: "REPLACE", Records: []powerDnsRecord{{Content: "10.0.0.3"}, {Content: "10.0.0.4"}}}}},
+		{Rrsets: []powerDnsRrset{{Name: "example.com.", Type: "AAAA", ChangeType: "DELETE", Records: []powerDnsRecord{}}}},
+	}
+	if !reflect.DeepEqual(patches, want) {
+		t.Errorf("patches = %v, want %v", patches, want)
+	}
+}
+
+func TestDynDns2_SetRecords(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			_, _ = w.Write([]byte("badauth"))
+			return
+		}
+		queries = append(queries, r.URL.Query().Get("hostname")+" "+r.URL.Query().Get("myip"))
+		_, _ = w.Write([]byte("good " + r.URL.Query().Get("myip")))
+	}))
+	defer server.Close()
+
+	d, err := NewDynDns2(server.URL+"/nic/update", "user", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := d.SetRecords(ctx, "example.com", "www", "A", []Record{{Value: "10.0.0.1", Ttl: 60}}); err != nil {
+		t.Fatal(err)
+	}
+	// the address of the other family is sent along
+	if err := d.SetRecords(ctx, "example.com", "www", "AAAA", []Record{{Value: "2001:db8::1", Ttl: 60}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetRecords(ctx, "example.com", "www", "A", []Record{{Value: "10.0.0.1"}, {Value: "10.0.0.2"}}); err == nil {
+		t.Error("expected error for more than one address per family")
+	}
+
+	want := []string{"www.example.com 10.0.0.1", "www.example.com 10.0.0.1,2001:db8::1"}
+	if !reflect.DeepEqual(queries, want) {
+		t.Errorf("queries = %v, want %v", queries, want)
+	}
+
+	records, err := d.GetRecords(ctx, "example.com", "www")
+	if err != nil || len(records) != 2 {
+		t.Errorf("GetRecords() = %v, %v", records, err)
+	}
+
+	wrongAuth, _ := NewDynDns2(server.URL+"/nic/update", "user", "wrong")
+	if err := wrongAuth.SetRecords(ctx, "example.com", "www", "A", []Record{{Value: "10.0.0.1"}}); err == nil {
+		t.Error("expected error for bad credentials")
+	}
+}
+
+func TestOvhSignature(t *testing.T) {
+	got := ovhSignature("secret", "consumer", http.MethodGet, "https://eu.api.ovh.com/1.0/domain/zone/example.com/record", nil, "1700000000")
+	// sha1 of "secret+consumer+GET+https://eu.api.ovh.com/1.0/domain/zone/example.com/record++1700000000"
+	want := "$1$6f0acd66c702f090c1ec7a014e1201de9168c5db"
+	if got != want {
+		t.Errorf("ovhSignature() = %q, want %q", got, want)
+	}
+}
+
+func TestMsDns_SetRecords(t *testing.T) {
+	var scripts []string
+	m, err := NewMsDns(WithComputerName("dc01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.run = func(_ context.Context, _, script string) ([]byte, error) {
+		scripts = append(scripts, script)
+		if strings.HasPrefix(script, "$records") {
+			return []byte(`[{"Type":"A","Ttl":60,"Value":"10.0.0.1"},{"Type":"A","Ttl":60,"Value":"10.0.0.3"},{"Type":"AAAA","Ttl":60,"Value":"::1"}]`), nil
+		}
+		return nil, nil
+	}
+
+	records := []Record{{Value: "10.0.0.2", Ttl: 60}, {Value: "10.0.0.3", Ttl: 30}}
+	if err := m.SetRecords(context.Background(), "example.com.", "www", "A", records); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"Remove-DnsServerResourceRecord -ZoneName 'example.com' -Name 'www' -RRType A -RecordData '10.0.0.3' -Force -ComputerName 'dc01'",
+		"Add-DnsServerResourceRecord -ZoneName 'example.com' -Name 'www' -A -IPv4Address '10.0.0.2' -TimeToLive ([TimeSpan]::FromSeconds(60)) -ComputerName 'dc01'",
+		"Add-DnsServerResourceRecord -ZoneName 'example.com' -Name 'www' -A -IPv4Address '10.0.0.3' -TimeToLive ([TimeSpan]::FromSeconds(30)) -ComputerName 'dc01'",
+		"Remove-DnsServerResourceRecord -ZoneName 'example.com' -Name 'www' -RRType A -RecordData '10.0.0.1' -Force -ComputerName 'dc01'",
+	}
+	if len(scripts) < 1 || !reflect.DeepEqual(scripts[1:], want) {
+		t.Errorf("scripts = %q, want %q", scripts[1:], want)
+	}
+}
@@ -0,0 +1,85 @@
+// Package dns routes the records of hostnames to the DNS backend they are managed by.
+package dns
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"go.uber.org/multierr"
+)
+
+// Router manages each hostname using the backend configured for it, all other hostnames use the default backend.
+type Router struct {
+	fallback internal.DnsDb
+	routes   map[string]internal.DnsDb
+}
+
+func NewRouter(fallback internal.DnsDb, routes map[string]internal.DnsDb) (*Router, error) {
+	if fallback == nil {
+		return nil, errors.New("nil fallback supplied")
+	}
+
+	return &Router{fallback: fallback, routes: routes}, nil
+}
+
+// Apply applies the records of the hostnames of each backend in a single update of that backend.
+func (r *Router) Apply(ctx context.Context, desired map[string][]internal.ManagedDnsRecord) (bool, error) {
+	grouped := map[internal.DnsDb]map[string][]internal.ManagedDnsRecord{}
+	for hostname, records := range desired {
+		backend := r.backend(hostname)
+		if grouped[backend] == nil {
+			grouped[backend] = map[string][]internal.ManagedDnsRecord{}
+		}
+		grouped[backend][hostname] = records
+	}
+
+	var changed bool
+	var errs error
+	for _, backend := range r.backends() {
+		if len(grouped[backend]) == 0 {
+			continue
+		}
+		updated, err := backend.Apply(ctx, grouped[backend])
+		changed = changed || updated
+		errs = multierr.Append(errs, err)
+	}
+	return changed, errs
+}
+
+// ValidateConfig validates the config of all backends.
+func (r *Router) ValidateConfig(ctx context.Context) error {
+	var errs error
+	for _, backend := range r.backends() {
+		errs = multierr.Append(errs, backend.ValidateConfig(ctx))
+	}
+	return errs
+}
+
+// PublishedIps returns the published addresses of the hostname if its backend is able to read them.
+func (r *Router) PublishedIps(hostname string) ([]string, error) {
+	reader, ok := r.backend(hostname).(internal.DnsDbReader)
+	if !ok {
+		return nil, nil
+	}
+	return reader.PublishedIps(hostname)
+}
+
+func (r *Router) backend(hostname string) internal.DnsDb {
+	if backend, found := r.routes[hostname]; found {
+		return backend
+	}
+	return r.fallback
+}
+
+// backends returns every distinct backend once.
+func (r *Router) backends() []internal.DnsDb {
+	ret := []internal.DnsDb{r.fallback}
+	for _, backend := range r.routes {
+		if !slices.Contains(ret, backend) {
+			ret = append(ret, backend)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,43 @@
+package dns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal"
+)
+
+type dummyDnsDb struct {
+	updated []string
+}
+
+func (d *dummyDnsDb) Apply(_ context.Context, desired map[string][]internal.ManagedDnsRecord) (bool, error) {
+	for hostname := range desired {
+		d.updated = append(d.updated, hostname)
+	}
+	return true, nil
+}
+
+func (d *dummyDnsDb) ValidateConfig(_ context.Context) error {
+	return nil
+}
+
+func TestRouter_Apply(t *testing.T) {
+	fallback, routed := &dummyDnsDb{}, &dummyDnsDb{}
+	router, err := NewRouter(fallback, map[string]internal.DnsDb{"cloud.example.com": routed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = router.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"local.example.com": nil, "cloud.example.com": nil})
+
+	if len(fallback.updated) != 1 || fallback.updated[0] != "local.example.com" {
+		t.Errorf("fallback updated %v", fallback.updated)
+	}
+	if len(routed.updated) != 1 || routed.updated[0] != "cloud.example.com" {
+		t.Errorf("routed backend updated %v", routed.updated)
+	}
+	if backends := router.backends(); len(backends) != 2 {
+		t.Errorf("expected 2 distinct backends, got %d", len(backends))
+	}
+}
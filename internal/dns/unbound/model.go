@@ -0,0 +1,462 @@
+package unbound
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	managedBlockStart = "# BEGIN managed by dns-ha, do not edit"
+	managedBlockEnd   = "# END managed by dns-ha"
+	// ownerMarker is appended to each managed entry, followed by the hostname owning it
+	ownerMarker = "# managed-by: dns-ha "
+
+	localData    = "local-data"
+	localDataPtr = "local-data-ptr"
+	localZone    = "local-zone"
+	// redirectZone answers all names below the zone with the local-data of the zone itself
+	redirectZone = "redirect"
+
+	viewClause = "view:"
+	// accessControlView answers the queries of clients of a netblock from a view
+	accessControlView = "access-control-view"
+)
+
+// entry is a single line of the db file. Lines that are neither local-data, local-data-ptr nor local-zone statements
+// are kept verbatim in raw.
+type entry struct {
+	kind string
+	// name is the owner name for local-data, the address for local-data-ptr and the zone for local-zone entries
+	name string
+	ttl  int
+	// rtype is only set for local-data entries
+	rtype string
+	// data is the record data for local-data, the target name for local-data-ptr and the type for local-zone entries
+	data string
+	// view is the name of the view clause the entry is part of, empty for the server clause
+	view string
+	// managedBy is the hostname of the ownership marker, empty for entries without marker
+	managedBy string
+
+	raw string
+}
+
+func parseEntry(line string) entry {
+	content, managedBy, _ := strings.Cut(line, ownerMarker)
+	key, value, found := strings.Cut(strings.TrimSpace(content), ":")
+	if !found || (key != localData && key != localDataPtr && key != localZone) {
+		return entry{raw: line}
+	}
+
+	value = strings.TrimSpace(value)
+	if key == localZone {
+		fields := strings.Fields(value)
+		if len(fields) != 2 || len(fields[0]) < 2 || !strings.HasPrefix(fields[0], `"`) || !strings.HasSuffix(fields[0], `"`) {
+			return entry{raw: line}
+		}
+		return entry{kind: key, name: strings.Trim(fields[0], `"`), ttl: -1, data: fields[1], managedBy: normalizeName(strings.TrimSpace(managedBy))}
+	}
+
+	// statements containing double quotes, e.g. TXT records, are enclosed in single quotes
+	quote := `"`
+	if strings.HasPrefix(value, "'") {
+		quote = "'"
+	}
+	start, end := strings.Index(value, quote), strings.LastIndex(value, quote)
+	if start != 0 || end <= start {
+		return entry{raw: line}
+	}
+
+	fields := strings.Fields(value[start+1 : end])
+	if len(fields) < 2 {
+		return entry{raw: line}
+	}
+
+	ret := entry{kind: key, name: fields[0], ttl: -1, managedBy: normalizeName(strings.TrimSpace(managedBy))}
+	fields = fields[1:]
+	if ttl, err := strconv.Atoi(fields[0]); err == nil && len(fields) > 1 {
+		ret.ttl = ttl
+		fields = fields[1:]
+	}
+
+	if key == localDataPtr {
+		ret.data = strings.Join(fields, " ")
+		return ret
+	}
+
+	if len(fields) > 1 && slices.Contains([]string{"IN", "CH", "HS"}, strings.ToUpper(fields[0])) {
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return entry{raw: line}
+	}
+
+	ret.rtype = strings.ToUpper(fields[0])
+	ret.data = strings.Join(fields[1:], " ")
+	return ret
+}
+
+// owner returns the hostname an entry belongs to, PTR entries belong to the name they point to. Zones are only owned
+// via their ownership marker.
+func (e entry) owner() string {
+	switch e.kind {
+	case localData:
+		return normalizeName(e.name)
+	case localDataPtr:
+		return normalizeName(e.data)
+	}
+	return ""
+}
+
+func (e entry) String() string {
+	var ttl string
+	if e.ttl >= 0 {
+		ttl = fmt.Sprintf("%d ", e.ttl)
+	}
+
+	var marker string
+	if e.managedBy != "" {
+		marker = " " + ownerMarker + e.managedBy
+	}
+
+	switch e.kind {
+	case localData:
+		if strings.Contains(e.data, `"`) {
+			return fmt.Sprintf(`%s: '%s %s%s %s'%s`, localData, e.name, ttl, e.rtype, e.data, marker)
+		}
+		return fmt.Sprintf(`%s: "%s %s%s %s"%s`, localData, e.name, ttl, e.rtype, e.data, marker)
+	case localDataPtr:
+		return fmt.Sprintf(`%s: "%s %s%s"%s`, localDataPtr, e.name, ttl, e.data, marker)
+	case localZone:
+		return fmt.Sprintf(`%s: "%s" %s%s`, localZone, e.name, e.data, marker)
+	}
+	return e.raw
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// dbFile is the structured representation of the unbound db file. Only the entries in the block marked as managed by
+// dns-ha are ever modified, all other lines are written back byte-identical. Entries of views are written to view
+// clauses at the end of the managed block.
+type dbFile struct {
+	head []string
+	// runs are the entries of the managed block in file order
+	runs []*run
+	// index holds the runs of each owner, so the entries of a hostname are found without scanning the whole block
+	index map[ownerKey][]*run
+	tail  []string
+	// outside indexes the lines of head and tail by their owner, it's built on first use
+	outside  map[string][]string
+	hasBlock bool
+	// clientViews are written to the server clause of the managed block, a view clause is written for each of their
+	// views even if the view has no entries
+	clientViews []clientView
+}
+
+// clientView assigns the clients of a netblock to a view.
+type clientView struct {
+	netblock string
+	view     string
+}
+
+func (c clientView) String() string {
+	return fmt.Sprintf("%s: %s %s", accessControlView, c.netblock, c.view)
+}
+
+// ownerKey identifies the managed entries of a hostname in a view.
+type ownerKey struct {
+	hostname string
+	view     string
+}
+
+// run is a sequence of consecutive entries with the same owner, entries without ownership marker are never indexed.
+type run struct {
+	key     ownerKey
+	entries []entry
+}
+
+// lineDiff holds the lines that are removed and added by a replacement.
+type lineDiff struct {
+	removed []string
+	added   []string
+}
+
+func (d lineDiff) empty() bool {
+	return len(d.removed) == 0 && len(d.added) == 0
+}
+
+func parseDbFile(lines []string) (*dbFile, error) {
+	start := slices.Index(lines, managedBlockStart)
+	end := slices.Index(lines, managedBlockEnd)
+
+	if start < 0 && end < 0 {
+		return &dbFile{head: lines, index: map[ownerKey][]*run{}}, nil
+	}
+
+	if start < 0 || end < start {
+		return nil, errors.New("malformed dns-ha managed block")
+	}
+
+	db := &dbFile{
+		head:     lines[:start],
+		tail:     lines[end+1:],
+		index:    map[ownerKey][]*run{},
+		hasBlock: true,
+	}
+	managed, clientViews, err := parseManaged(lines[start+1 : end])
+	if err != nil {
+		return nil, err
+	}
+	db.clientViews = clientViews
+	// blocks written before ownership markers were introduced only contain entries of dns-ha, they are marked with
+	// their owner and migrated with the next write
+	if !slices.ContainsFunc(managed, func(e entry) bool { return e.managedBy != "" }) {
+		for i := range managed {
+			managed[i].managedBy = managed[i].owner()
+		}
+	}
+
+	for _, e := range managed {
+		key := ownerKey{hostname: e.managedBy, view: e.view}
+		if len(db.runs) > 0 && db.runs[len(db.runs)-1].key == key {
+			last := db.runs[len(db.runs)-1]
+			last.entries = append(last.entries, e)
+			continue
+		}
+		r := &run{key: key, entries: []entry{e}}
+		db.runs = append(db.runs, r)
+		if key.hostname != "" {
+			db.index[key] = append(db.index[key], r)
+		}
+	}
+
+	return db, nil
+}
+
+// entries returns all entries of the managed block in file order.
+func (d *dbFile) entries() []entry {
+	var ret []entry
+	for _, r := range d.runs {
+		ret = append(ret, r.entries...)
+	}
+	return ret
+}
+
+// owned returns the managed entries of the hostname in the view.
+func (d *dbFile) owned(hostname, view string) []entry {
+	var ret []entry
+	for _, r := range d.index[ownerKey{hostname: normalizeName(hostname), view: view}] {
+		ret = append(ret, r.entries...)
+	}
+	return ret
+}
+
+// parseManaged parses the lines of the managed block, the view clauses are not kept but recorded in the entries. The
+// assignments of clients to views are returned separately.
+func parseManaged(lines []string) ([]entry, []clientView, error) {
+	var ret []entry
+	var clientViews []clientView
+	var view string
+	inView := false
+	for _, line := range lines {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), ":")
+		switch {
+		case key+":" == viewClause:
+			inView, view = true, ""
+			continue
+		case inView && key == "name":
+			view = strings.Trim(strings.TrimSpace(value), `"`)
+			continue
+		case inView && key == "view-first":
+			continue
+		case !inView && key == accessControlView:
+			if fields := strings.Fields(value); len(fields) == 2 {
+				clientViews = append(clientViews, clientView{netblock: fields[0], view: fields[1]})
+				continue
+			}
+		}
+
+		e := parseEntry(line)
+		if inView && view == "" && e.kind != "" {
+			return nil, nil, errors.New("view clause without name in dns-ha managed block")
+		}
+		e.view = view
+		ret = append(ret, e)
+	}
+	return ret, clientViews, nil
+}
+
+// hasServerOptionsAfterBlock returns true if the file contains statements after the managed block, they'd become part
+// of the last view clause.
+func (d *dbFile) hasServerOptionsAfterBlock() bool {
+	for _, line := range d.tail {
+		if trimmed := strings.TrimSpace(line); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// unmanagedEntries returns all entries outside the managed block that belong to the given hostname.
+// Entries outside the managed block are never part of a view.
+func (d *dbFile) unmanagedEntries(hostname string) []string {
+	if d.outside == nil {
+		d.outside = map[string][]string{}
+		for _, line := range slices.Concat(d.head, d.tail) {
+			if owner := parseEntry(line).owner(); owner != "" {
+				d.outside[owner] = append(d.outside[owner], line)
+			}
+		}
+	}
+	return d.outside[normalizeName(hostname)]
+}
+
+// adopt moves the entries of the hostname that are not part of a managed block into the managed block and returns the
+// moved lines. Files written before the managed block was introduced only contain entries of dns-ha, they are taken
+// over on the first write of the hostname, so stale addresses are not served along with the managed entries.
+// Hostnames are written one after another, e.g. once their dependencies have been updated, so the entries are adopted
+// as long as the hostname owns no entries in the managed block, regardless of whether the block exists.
+func (d *dbFile) adopt(hostname string) []string {
+	hostname = normalizeName(hostname)
+	if len(d.owned(hostname, "")) > 0 {
+		return nil
+	}
+
+	var adopted []entry
+	var moved []string
+	// the lines share their memory with the cached content of the file, so head is not modified in place
+	head := make([]string, 0, len(d.head))
+	for _, line := range d.head {
+		e := parseEntry(line)
+		if e.owner() != hostname {
+			head = append(head, line)
+			continue
+		}
+		e.managedBy = hostname
+		adopted = append(adopted, e)
+		moved = append(moved, line)
+	}
+	if len(adopted) == 0 {
+		return nil
+	}
+
+	d.head, d.outside = head, nil
+	key := ownerKey{hostname: hostname}
+	r := &run{key: key, entries: adopted}
+	d.runs = append(d.runs, r)
+	d.index[key] = append(d.index[key], r)
+	return moved
+}
+
+// replace replaces all managed entries owned by the hostname in the view with the wanted entries and returns the
+// changed lines. Entries are owned by the hostname of their ownership marker, entries without marker are never
+// touched. The wanted entries are inserted at the position of the first existing entry of the hostname, so the order
+// of the file stays stable. Only the entries of the hostname are compared, so the cost does not depend on the size of
+// the file.
+func (d *dbFile) replace(hostname, view string, wanted []entry) lineDiff {
+	key := ownerKey{hostname: normalizeName(hostname), view: view}
+	for i := range wanted {
+		wanted[i].view = view
+		wanted[i].managedBy = key.hostname
+	}
+
+	var current []string
+	for _, e := range d.owned(hostname, view) {
+		current = append(current, e.String())
+	}
+
+	wantedLines := make([]string, 0, len(wanted))
+	for _, e := range wanted {
+		wantedLines = append(wantedLines, e.String())
+	}
+
+	diff := diffLines(current, wantedLines)
+	if diff.empty() {
+		return diff
+	}
+
+	runs := d.index[key]
+	if len(runs) == 0 {
+		r := &run{key: key}
+		d.runs = append(d.runs, r)
+		runs = []*run{r}
+	}
+	runs[0].entries = wanted
+	for _, r := range runs[1:] {
+		r.entries = nil
+	}
+	d.index[key] = runs[:1]
+	return diff
+}
+
+// diffLines returns the lines only contained in current as removed and the lines only contained in wanted as added.
+func diffLines(current, wanted []string) lineDiff {
+	current, wanted = slices.Sorted(slices.Values(current)), slices.Sorted(slices.Values(wanted))
+	var diff lineDiff
+	i, j := 0, 0
+	for i < len(current) || j < len(wanted) {
+		switch {
+		case j == len(wanted) || (i < len(current) && current[i] < wanted[j]):
+			diff.removed = append(diff.removed, current[i])
+			i++
+		case i == len(current) || wanted[j] < current[i]:
+			diff.added = append(diff.added, wanted[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return diff
+}
+
+func (d *dbFile) lines() []string {
+	managed := d.entries()
+	block := make([]string, 0, len(managed)+2)
+	block = append(block, managedBlockStart)
+	var views []string
+	for _, e := range managed {
+		if e.view == "" {
+			block = append(block, e.String())
+		} else if !slices.Contains(views, e.view) {
+			views = append(views, e.view)
+		}
+	}
+	for _, clientView := range d.clientViews {
+		block = append(block, clientView.String())
+		if !slices.Contains(views, clientView.view) {
+			views = append(views, clientView.view)
+		}
+	}
+	// unmatched queries fall through to the records of the server clause
+	for _, view := range views {
+		block = append(block, viewClause, fmt.Sprintf("\tname: %q", view), "\tview-first: yes")
+		for _, e := range managed {
+			if e.view != view {
+				continue
+			}
+			if e.kind == "" {
+				block = append(block, e.raw)
+			} else {
+				block = append(block, "\t"+e.String())
+			}
+		}
+	}
+	block = append(block, managedBlockEnd)
+
+	if d.hasBlock {
+		return slices.Concat(d.head, block, d.tail)
+	}
+
+	// append a new block to the end of the file and keep the trailing newline
+	head := d.head
+	if len(head) > 0 && head[len(head)-1] == "" {
+		head = head[:len(head)-1]
+	}
+	return slices.Concat(head, block, []string{""})
+}
@@ -0,0 +1,111 @@
+package unbound
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantOwner string
+		want      string
+	}{
+		{
+			line:      `local-data: "host.my.tld 60 A 10.0.0.1"`,
+			wantOwner: "host.my.tld",
+			want:      `local-data: "host.my.tld 60 A 10.0.0.1"`,
+		},
+		{
+			line:      `  local-data: "Host.My.Tld. IN aaaa ::1"`,
+			wantOwner: "host.my.tld",
+			want:      `local-data: "Host.My.Tld. AAAA ::1"`,
+		},
+		{
+			line:      `local-data-ptr: "10.0.0.1 60 host.my.tld"`,
+			wantOwner: "host.my.tld",
+			want:      `local-data-ptr: "10.0.0.1 60 host.my.tld"`,
+		},
+		{
+			line:      `local-data: "host.my.tld 60 A 10.0.0.1" # managed-by: dns-ha Host.My.Tld`,
+			wantOwner: "host.my.tld",
+			want:      `local-data: "host.my.tld 60 A 10.0.0.1" # managed-by: dns-ha host.my.tld`,
+		},
+		{
+			line:      `local-data: 'host.my.tld 60 TXT "active=10.0.0.1 ts=2024-05-17T12:00:00Z"'`,
+			wantOwner: "host.my.tld",
+			want:      `local-data: 'host.my.tld 60 TXT "active=10.0.0.1 ts=2024-05-17T12:00:00Z"'`,
+		},
+		{
+			line:      `local-zone: "my.tld." static`,
+			wantOwner: "",
+			want:      `local-zone: "my.tld." static`,
+		},
+		{
+			line:      `local-zone: "apps.my.tld" redirect # managed-by: dns-ha *.apps.my.tld`,
+			wantOwner: "",
+			want:      `local-zone: "apps.my.tld" redirect # managed-by: dns-ha *.apps.my.tld`,
+		},
+		{
+			line:      "# a comment",
+			wantOwner: "",
+			want:      "# a comment",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got := parseEntry(tt.line)
+			if got.owner() != tt.wantOwner {
+				t.Errorf("owner() = %q, want %q", got.owner(), tt.wantOwner)
+			}
+			if got.String() != tt.want {
+				t.Errorf("String() = %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDbFile_replaceOwnership(t *testing.T) {
+	db, err := parseDbFile([]string{
+		managedBlockStart,
+		`local-data: "host.my.tld 60 A 10.0.0.1" # managed-by: dns-ha host.my.tld`,
+		`local-data: "host.my.tld 60 A 10.0.0.9"`,
+		managedBlockEnd,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db.replace("host.my.tld", "", []entry{{kind: localData, name: "host.my.tld", ttl: 60, rtype: "A", data: "10.0.0.2"}}).empty() {
+		t.Fatal("expected replace() to report a change")
+	}
+
+	// the entry without ownership marker is not owned by dns-ha and kept
+	want := []string{
+		managedBlockStart,
+		`local-data: "host.my.tld 60 A 10.0.0.2" # managed-by: dns-ha host.my.tld`,
+		`local-data: "host.my.tld 60 A 10.0.0.9"`,
+		managedBlockEnd,
+	}
+	if got := db.lines(); !reflect.DeepEqual(got, want) {
+		t.Errorf("lines() = %q, want %q", got, want)
+	}
+}
+
+func TestParseDbFile_migratesLegacyBlock(t *testing.T) {
+	db, err := parseDbFile([]string{
+		managedBlockStart,
+		`local-data: "host.my.tld 60 A 10.0.0.1"`,
+		`local-data-ptr: "10.0.0.1 60 host.my.tld"`,
+		managedBlockEnd,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range db.entries() {
+		if e.managedBy != "host.my.tld" {
+			t.Errorf("expected %q to be owned by host.my.tld, got %q", e.String(), e.managedBy)
+		}
+	}
+}
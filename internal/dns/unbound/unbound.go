@@ -5,134 +5,305 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"net/netip"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"slices"
 	"strings"
 
 	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/dns/files"
+	"go.uber.org/multierr"
+)
+
+const (
+	CheckconfTargetDbFile = "db_file"
+	CheckconfTargetSystem = "system"
+
+	DefaultCheckconfBinary = "unbound-checkconf"
+	defaultBackups         = 3
+	DefaultFileMode        = 0640
 )
 
 type Unbound struct {
 	fs UnboundConfWrapper
+
+	// db is the parsed content of lines, it's reused as long as the content of the file does not change
+	db    *dbFile
+	lines []string
+	// clientViews are the assignments of client netblocks to views that are written to the managed block
+	clientViews []clientView
 }
 
+type UnboundOpts func(*Unbound) error
+
 // UnboundConfWrapper is just a simple wrapper to increase testability for Unbound.
 type UnboundConfWrapper interface {
 	ReadConf() ([]string, error)
 	WriteConf(conf []string) error
 	ValidateConfig(ctx context.Context) error
+	// Rollback restores the content that was replaced by the last call to WriteConf.
+	Rollback() error
 }
 
-func NewUnbound(fs UnboundConfWrapper) (*Unbound, error) {
+func NewUnbound(fs UnboundConfWrapper, opts ...UnboundOpts) (*Unbound, error) {
 	if fs == nil {
 		return nil, errors.New("nil fs supplied")
 	}
 
-	return &Unbound{fs: fs}, nil
+	ret := &Unbound{fs: fs}
+	var errs error
+	for _, opt := range opts {
+		if err := opt(ret); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	return ret, errs
+}
+
+// WithClientViews answers the queries of clients from the view their netblock is assigned to, e.g. to answer VPN
+// clients with the VPN-side addresses of the records of a hostname in that view.
+func WithClientViews(views map[string][]string) UnboundOpts {
+	return func(u *Unbound) error {
+		var errs error
+		u.clientViews = nil
+		for _, view := range slices.Sorted(maps.Keys(views)) {
+			if view == "" || strings.ContainsAny(view, " \t\"#"+internal.ViewSeparator) {
+				errs = multierr.Append(errs, fmt.Errorf("invalid view name %q", view))
+				continue
+			}
+			for _, netblock := range views[view] {
+				prefix, err := netip.ParsePrefix(netblock)
+				if err != nil {
+					errs = multierr.Append(errs, fmt.Errorf("invalid netblock %q of view %q: %w", netblock, view, err))
+					continue
+				}
+				u.clientViews = append(u.clientViews, clientView{netblock: prefix.Masked().String(), view: view})
+			}
+		}
+		return errs
+	}
 }
 
+// ValidateConfig validates the written config and rolls back to the previous version if it is invalid.
 func (u *Unbound) ValidateConfig(ctx context.Context) error {
-	return u.fs.ValidateConfig(ctx)
+	err := u.fs.ValidateConfig(ctx)
+	if err == nil {
+		return nil
+	}
+
+	slog.Warn("Rolling back invalid unbound config", "err", err)
+	if rollbackErr := u.fs.Rollback(); rollbackErr != nil {
+		return errors.Join(err, fmt.Errorf("rollback failed: %w", rollbackErr))
+	}
+	// validating again would only validate the previous version
+	return fmt.Errorf("%w: %w", err, internal.ErrNotRetryable)
 }
 
-func (u *Unbound) UpdateIps(dnsRecord string, records []internal.ManagedDnsRecord) (bool, error) {
-	lines, err := u.fs.ReadConf()
+// Apply writes the records of all hostnames with a single write of the db file. Hostnames carrying a view suffix are
+// written to the view clause of that name.
+func (u *Unbound) Apply(ctx context.Context, desired map[string][]internal.ManagedDnsRecord) (bool, error) {
+	db, err := u.read()
 	if err != nil {
 		return false, err
 	}
 
-	// wantedRecords holds the unbound configuration for the record as key and the line where it is found in the list
-	wantedRecords := make(map[string]*int)
-	for _, record := range records {
-		wantedRecords[recordToUnbound(dnsRecord, record)] = nil
-	}
-
-	recordsToRemove := make([]int, 0, len(records))
-	for index, line := range lines {
-		if strings.HasPrefix(line, fmt.Sprintf(`local-data: "%s `, dnsRecord)) {
-			lineContainsWantedRecord := false
-			for wantedRecord := range wantedRecords {
-				if line == wantedRecord {
-					wantedRecords[line] = &index
-					lineContainsWantedRecord = true
-				}
-			}
-			// as this line passed the check for the domain but is not a configuration we want, we need to remove it
-			if !lineContainsWantedRecord {
-				recordsToRemove = append(recordsToRemove, index)
+	changed := false
+	for _, name := range slices.Sorted(maps.Keys(desired)) {
+		if hostname, view := internal.SplitView(name); view == "" {
+			if adopted := db.adopt(strings.TrimPrefix(hostname, "*.")); len(adopted) > 0 {
+				slog.Info("Migrating records written before the managed block was introduced", "hostname", hostname, "lines", adopted)
+				changed = true
 			}
 		}
+		diff, err := u.replace(db, name, desired[name])
+		if err != nil {
+			// the parsed db has been modified already
+			u.db = nil
+			return false, err
+		}
+		if !diff.empty() {
+			slog.Debug("Changing unbound records", "hostname", name, "removed", diff.removed, "added", diff.added)
+			changed = true
+		}
 	}
 
-	// iterate over wanted records and collect missing ones
-	missingRecords := make([]string, 0, len(wantedRecords))
-	for record, line := range wantedRecords {
-		if line == nil {
-			missingRecords = append(missingRecords, record)
-		}
+	if !slices.Equal(db.clientViews, u.clientViews) {
+		slog.Debug("Changing unbound client views", "removed", db.clientViews, "added", u.clientViews)
+		db.clientViews = u.clientViews
+		changed = true
 	}
 
-	if len(missingRecords) == 0 && len(recordsToRemove) == 0 {
+	if !changed {
 		return false, nil
 	}
+	// the parsed db does not match the file anymore until it has been written
+	u.db = nil
+	// don't start writing once the deadline has passed, the write itself is not interruptible
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 
-	// remove old records
-	if len(recordsToRemove) > 0 {
-		slog.Warn("Need to remove stale records", "n", len(recordsToRemove), "lines", recordsToRemove)
-		lines = removeIndices(lines, recordsToRemove)
+	lines := db.lines()
+	if err := u.fs.WriteConf(lines); err != nil {
+		return true, err
 	}
+	u.db, u.lines = db, lines
+	return true, nil
+}
 
-	// if we are missing the equal amount of records as the amount of records we want to insert
-	if len(missingRecords) == len(records) {
-		lines = append(lines, missingRecords...)
-	} else {
-		idx := 0
-		for _, line := range wantedRecords {
-			if line != nil {
-				idx = *line
-			}
-		}
-		lines = slices.Insert(lines, idx, missingRecords...)
+// read returns the parsed db file. It's only parsed again if the content of the file changed, comparing the lines is
+// cheap as long as the file is not modified, as unchanged lines share their memory.
+func (u *Unbound) read() (*dbFile, error) {
+	lines, err := u.fs.ReadConf()
+	if err != nil {
+		return nil, err
+	}
+	if u.db != nil && slices.Equal(lines, u.lines) {
+		return u.db, nil
 	}
 
-	return true, u.fs.WriteConf(lines)
+	db, err := parseDbFile(lines)
+	if err != nil {
+		return nil, err
+	}
+	u.db, u.lines = db, lines
+	return db, nil
 }
 
-func removeIndices(slice []string, indicesToRemove []int) []string {
-	indexMap := make(map[int]struct{}, len(indicesToRemove))
-	for _, index := range indicesToRemove {
-		indexMap[index] = struct{}{}
+// replace replaces the records of the hostname in the managed block of the db. Records of views are refused if the
+// file contains statements after the managed block, as they'd become part of the last view clause.
+func (u *Unbound) replace(db *dbFile, name string, records []internal.ManagedDnsRecord) (lineDiff, error) {
+	dnsRecord, view := internal.SplitView(name)
+	// unbound has no wildcard records, a redirect zone answers all names below the base with the records of the base
+	recordName, isWildcard := strings.CutPrefix(dnsRecord, "*.")
+	if view == "" {
+		if conflicting := db.unmanagedEntries(recordName); len(conflicting) > 0 {
+			slog.Warn("Found records outside of the managed block, not touching them", "hostname", dnsRecord, "lines", conflicting)
+		}
+	} else if db.hasServerOptionsAfterBlock() {
+		return lineDiff{}, fmt.Errorf("can not write view %q of %q, the statements after the managed block would become part of the view clause: %w", view, dnsRecord, internal.ErrNotRetryable)
 	}
 
-	var result []string
-	for i, value := range slice {
-		if _, exists := indexMap[i]; !exists {
-			result = append(result, value)
+	wanted := make([]entry, 0, len(records)+1)
+	if isWildcard && len(records) > 0 {
+		wanted = append(wanted, entry{kind: localZone, name: recordName, ttl: -1, data: redirectZone})
+	}
+	for _, record := range records {
+		wanted = append(wanted, recordToEntry(recordName, record))
+		if record.Ptr {
+			wanted = append(wanted, recordToPtrEntry(dnsRecord, record))
 		}
 	}
 
-	return result
+	return db.replace(dnsRecord, view, wanted), nil
 }
 
-func recordToUnbound(hostname string, record internal.ManagedDnsRecord) string {
-	return fmt.Sprintf(`local-data: "%s %d %s %s"`, hostname, record.Ttl, record.DnsType, record.Ip)
+// PublishedIps returns the addresses of the A and AAAA records of the hostname in the managed block.
+func (u *Unbound) PublishedIps(name string) ([]string, error) {
+	db, err := u.read()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, view := internal.SplitView(name)
+	var ips []string
+	for _, e := range db.owned(hostname, view) {
+		if e.kind == localData && (e.rtype == "A" || e.rtype == "AAAA") {
+			ips = append(ips, e.data)
+		}
+	}
+	return ips, nil
 }
 
-func isFileWritable(path string) bool {
-	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		return false
+func recordToEntry(hostname string, record internal.ManagedDnsRecord) entry {
+	return entry{
+		kind:  localData,
+		name:  hostname,
+		ttl:   int(record.Ttl),
+		rtype: record.DnsType,
+		data:  record.Data(),
+	}
+}
+
+func recordToPtrEntry(hostname string, record internal.ManagedDnsRecord) entry {
+	return entry{
+		kind: localDataPtr,
+		name: record.Ip.String(),
+		ttl:  int(record.Ttl),
+		data: hostname,
 	}
-	_ = file.Close()
-	return true
 }
 
 type FsImpl struct {
 	filePath string
+	backups  int
+	attrs    files.Attributes
+
+	checkconfBinary string
+	checkconfArgs   []string
+	checkconfTarget string
+
+	// previous holds the content replaced by the last write, it's used to roll back invalid configs
+	previous []byte
+
+	// cached holds the content of the file as of cachedInfo, the file is only read again after it has been modified
+	cached     []string
+	cachedInfo os.FileInfo
+}
+
+type FsImplOpts func(*FsImpl) error
+
+// WithBackups keeps the given amount of timestamped backups of the previous versions of the file. Zero disables
+// backups.
+func WithBackups(backups int) FsImplOpts {
+	return func(f *FsImpl) error {
+		if backups < 0 {
+			return errors.New("amount of backups must not be negative")
+		}
+		f.backups = backups
+		return nil
+	}
+}
+
+// WithFileAttributes configures the mode, owner and SELinux context of the written db file.
+func WithFileAttributes(attrs files.Attributes) FsImplOpts {
+	return func(f *FsImpl) error {
+		if attrs.Mode&^os.ModePerm != 0 {
+			return fmt.Errorf("invalid file mode %v", attrs.Mode)
+		}
+		f.attrs = attrs
+		return nil
+	}
 }
 
-func NewUnboundConfigWrapper(filePath string, createFile bool) (*FsImpl, error) {
+// WithCheckconf configures the binary and additional arguments used to validate the config. If target is
+// CheckconfTargetDbFile, the db file is validated by including it into a minimal server config, otherwise unbound's
+// default config is validated.
+func WithCheckconf(binary string, args []string, target string) FsImplOpts {
+	return func(f *FsImpl) error {
+		if binary != "" {
+			f.checkconfBinary = binary
+		}
+		f.checkconfArgs = args
+
+		switch target {
+		case "":
+		case CheckconfTargetDbFile, CheckconfTargetSystem:
+			f.checkconfTarget = target
+		default:
+			return fmt.Errorf("unknown checkconf target %q", target)
+		}
+		return nil
+	}
+}
+
+func NewUnboundConfigWrapper(filePath string, createFile bool, opts ...FsImplOpts) (*FsImpl, error) {
 	_, err := os.Stat(filePath)
 	if err != nil && os.IsNotExist(err) {
 		if !createFile {
@@ -147,32 +318,136 @@ func NewUnboundConfigWrapper(filePath string, createFile bool) (*FsImpl, error)
 			}()
 		}
 	} else {
-		if !isFileWritable(filePath) {
+		if !files.IsWritable(filePath) {
 			return nil, fmt.Errorf("unbound config file %q is not writable", filePath)
 		}
 	}
 
-	return &FsImpl{filePath: filePath}, nil
+	ret := &FsImpl{
+		filePath:        filePath,
+		backups:         defaultBackups,
+		attrs:           files.ModeOnly(DefaultFileMode),
+		checkconfBinary: DefaultCheckconfBinary,
+		checkconfTarget: CheckconfTargetDbFile,
+	}
+
+	var errs error
+	for _, opt := range opts {
+		if err := opt(ret); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	return ret, errs
 }
 
+// ReadConf returns the lines of the file, it's only read again if its modification time or size changed.
 func (u *FsImpl) ReadConf() ([]string, error) {
+	info, err := os.Stat(u.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zone file: %w", err)
+	}
+	if u.isCached(info) {
+		return slices.Clone(u.cached), nil
+	}
+
 	oldContent, err := os.ReadFile(u.filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read zone file: %v", err)
+		return nil, fmt.Errorf("failed to read zone file: %w", err)
 	}
 
-	return strings.Split(strings.TrimSpace(string(oldContent)), "\n"), nil
+	u.cached = strings.Split(string(oldContent), "\n")
+	u.cachedInfo = info
+	return slices.Clone(u.cached), nil
+}
+
+func (u *FsImpl) isCached(info os.FileInfo) bool {
+	return u.cachedInfo != nil && info.ModTime().Equal(u.cachedInfo.ModTime()) && info.Size() == u.cachedInfo.Size()
 }
 
+// WriteConf atomically replaces the file after keeping a backup of its current content. The written content is
+// cached, so the file is not read again until it's modified by someone else.
 func (u *FsImpl) WriteConf(conf []string) error {
-	//nolint G306
-	return os.WriteFile(u.filePath, []byte(strings.Join(conf, "\n")), 0640)
+	var previous []byte
+	if info, err := os.Stat(u.filePath); err == nil && u.isCached(info) {
+		previous = []byte(strings.Join(u.cached, "\n"))
+	} else if previous, err = os.ReadFile(u.filePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not read current zone file: %w", err)
+	}
+
+	if u.backups > 0 && previous != nil {
+		if err := files.Backup(u.filePath, previous, u.backups, u.attrs.Mode); err != nil {
+			return err
+		}
+	}
+
+	u.cachedInfo = nil
+	if err := files.WriteAtomicWith(u.filePath, []byte(strings.Join(conf, "\n")), u.attrs); err != nil {
+		return err
+	}
+
+	u.previous = previous
+	if info, err := os.Stat(u.filePath); err == nil {
+		u.cached, u.cachedInfo = conf, info
+	}
+	return nil
+}
+
+func (u *FsImpl) Rollback() error {
+	if u.previous == nil {
+		return errors.New("no previous version available")
+	}
+
+	u.cachedInfo = nil
+	if err := files.WriteAtomicWith(u.filePath, u.previous, u.attrs); err != nil {
+		return err
+	}
+
+	u.previous = nil
+	return nil
 }
 
 func (u *FsImpl) ValidateConfig(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, "unbound-checkconf")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("unbound-checkconf failed: %w", err)
+	args := slices.Clone(u.checkconfArgs)
+	if u.checkconfTarget == CheckconfTargetDbFile {
+		wrapper, err := u.writeCheckconfWrapper()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = os.Remove(wrapper)
+		}()
+		args = append(args, wrapper)
+	}
+
+	cmd := exec.CommandContext(ctx, u.checkconfBinary, args...) //nolint G204
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", u.checkconfBinary, err, strings.TrimSpace(string(output)))
 	}
 	return nil
 }
+
+// writeCheckconfWrapper writes a minimal unbound config that only includes the db file, so the checkconf binary
+// validates exactly the file that has been written.
+func (u *FsImpl) writeCheckconfWrapper() (string, error) {
+	dbFile, err := filepath.Abs(u.filePath)
+	if err != nil {
+		return "", err
+	}
+
+	wrapper, err := os.CreateTemp("", "dns-ha-checkconf-*.conf")
+	if err != nil {
+		return "", fmt.Errorf("could not create checkconf wrapper: %w", err)
+	}
+
+	_, err = fmt.Fprintf(wrapper, "server:\n\tinclude: %q\n", dbFile)
+	if closeErr := wrapper.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(wrapper.Name())
+		return "", fmt.Errorf("could not write checkconf wrapper: %w", err)
+	}
+
+	return wrapper.Name(), nil
+}
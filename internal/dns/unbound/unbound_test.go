@@ -2,11 +2,18 @@ package unbound
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/soerenschneider/dns-ha/internal"
 	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/dns/files"
 
 	"log"
 )
@@ -26,6 +33,10 @@ func (d *dummyUnboundFs) ValidateConfig(_ context.Context) error {
 	return nil
 }
 
+func (d *dummyUnboundFs) Rollback() error {
+	return nil
+}
+
 func (d *dummyUnboundFs) WriteConf(conf []string) error {
 	d.written = conf
 	return d.writeErr
@@ -50,7 +61,7 @@ func mustNewDnsRecord(conf conf.RecordConfig, healthCheck internal.Healthcheck)
 	return *managed
 }
 
-func TestUnbound_UpdateIps(t *testing.T) {
+func TestUnbound_Apply(t *testing.T) {
 	type fields struct {
 		fs UnboundConfWrapper
 	}
@@ -71,12 +82,11 @@ func TestUnbound_UpdateIps(t *testing.T) {
 			fields: fields{
 				fs: &dummyUnboundFs{
 					read: []string{
+						managedBlockStart,
 						`local-data: "test-01.my.tld 30 A 192.168.1.5"`,
 						`local-data: "test-01.other.tld 30 A 192.168.1.5"`,
+						managedBlockEnd,
 					},
-					readErr:  nil,
-					written:  nil,
-					writeErr: nil,
 				},
 			},
 			args: args{
@@ -99,12 +109,11 @@ func TestUnbound_UpdateIps(t *testing.T) {
 			fields: fields{
 				fs: &dummyUnboundFs{
 					read: []string{
+						managedBlockStart,
 						`local-data: "test-01.my.tld 30 A 192.168.1.1"`,
 						`local-data: "test-01.my.tld 30 AAAA ::1"`,
+						managedBlockEnd,
 					},
-					readErr:  nil,
-					written:  nil,
-					writeErr: nil,
 				},
 			},
 			args: args{
@@ -133,12 +142,11 @@ func TestUnbound_UpdateIps(t *testing.T) {
 			fields: fields{
 				fs: &dummyUnboundFs{
 					read: []string{
+						managedBlockStart,
 						`local-data: "test-01.my.tld 60 A 192.168.1.5"`,
 						`local-data: "test-01.other.tld 30 A 192.168.1.5"`,
+						managedBlockEnd,
 					},
-					readErr:  nil,
-					written:  nil,
-					writeErr: nil,
 				},
 			},
 			args: args{
@@ -154,8 +162,10 @@ func TestUnbound_UpdateIps(t *testing.T) {
 			},
 			want: true,
 			wantWritten: []string{
-				`local-data: "test-01.other.tld 30 A 192.168.1.5"`,
-				`local-data: "test-01.my.tld 30 A 192.168.1.5"`,
+				managedBlockStart,
+				`local-data: "test-01.my.tld 30 A 192.168.1.5" # managed-by: dns-ha test-01.my.tld`,
+				`local-data: "test-01.other.tld 30 A 192.168.1.5" # managed-by: dns-ha test-01.other.tld`,
+				managedBlockEnd,
 			},
 			wantErr: false,
 		},
@@ -164,12 +174,110 @@ func TestUnbound_UpdateIps(t *testing.T) {
 			fields: fields{
 				fs: &dummyUnboundFs{
 					read: []string{
-						`local-data: "test-01.my.tld 60 A 192.168.1.25"`,
+						managedBlockStart,
 						`local-data: "test-01.other.tld 30 A 192.168.1.5"`,
+						`local-data: "test-01.my.tld 60 A 192.168.1.25"`,
+						managedBlockEnd,
+					},
+				},
+			},
+			args: args{
+				dnsRecord: "test-01.my.tld",
+				records: []internal.ManagedDnsRecord{
+					mustNewDnsRecord(conf.RecordConfig{
+						IP:         "192.168.1.5",
+						RecordType: "A",
+						Prio:       200,
+						Ttl:        30,
+					}, &dummyHealthCheck{}),
+				},
+			},
+			want: true,
+			wantWritten: []string{
+				managedBlockStart,
+				`local-data: "test-01.other.tld 30 A 192.168.1.5" # managed-by: dns-ha test-01.other.tld`,
+				`local-data: "test-01.my.tld 30 A 192.168.1.5" # managed-by: dns-ha test-01.my.tld`,
+				managedBlockEnd,
+			},
+			wantErr: false,
+		},
+		{
+			name: "no managed block yet, legacy records of managed hostnames are migrated",
+			fields: fields{
+				fs: &dummyUnboundFs{
+					read: []string{
+						"# hand written records",
+						"",
+						`local-data: "test-01.my.tld 60 A 192.168.1.25"`,
+						`local-data-ptr: "192.168.1.25 60 test-01.my.tld"`,
+						`  local-data: "test-01.other.tld 30 A 192.168.1.5"  `,
+						"",
+					},
+				},
+			},
+			args: args{
+				dnsRecord: "test-01.my.tld",
+				records: []internal.ManagedDnsRecord{
+					mustNewDnsRecord(conf.RecordConfig{
+						IP:         "192.168.1.5",
+						RecordType: "A",
+						Prio:       200,
+						Ttl:        30,
+					}, &dummyHealthCheck{}),
+				},
+			},
+			want: true,
+			wantWritten: []string{
+				"# hand written records",
+				"",
+				`  local-data: "test-01.other.tld 30 A 192.168.1.5"  `,
+				managedBlockStart,
+				`local-data: "test-01.my.tld 30 A 192.168.1.5" # managed-by: dns-ha test-01.my.tld`,
+				managedBlockEnd,
+				"",
+			},
+			wantErr: false,
+		},
+		{
+			name: "legacy records matching the selection are migrated",
+			fields: fields{
+				fs: &dummyUnboundFs{
+					read: []string{
+						`local-data: "test-01.my.tld 30 A 192.168.1.5"`,
+						"",
+					},
+				},
+			},
+			args: args{
+				dnsRecord: "test-01.my.tld",
+				records: []internal.ManagedDnsRecord{
+					mustNewDnsRecord(conf.RecordConfig{
+						IP:         "192.168.1.5",
+						RecordType: "A",
+						Prio:       200,
+						Ttl:        30,
+					}, &dummyHealthCheck{}),
+				},
+			},
+			want: true,
+			wantWritten: []string{
+				managedBlockStart,
+				`local-data: "test-01.my.tld 30 A 192.168.1.5" # managed-by: dns-ha test-01.my.tld`,
+				managedBlockEnd,
+				"",
+			},
+			wantErr: false,
+		},
+		{
+			name: "ptr record follows failover",
+			fields: fields{
+				fs: &dummyUnboundFs{
+					read: []string{
+						managedBlockStart,
+						`local-data: "test-01.my.tld 30 A 192.168.1.25"`,
+						`local-data-ptr: "192.168.1.25 30 test-01.my.tld"`,
+						managedBlockEnd,
 					},
-					readErr:  nil,
-					written:  nil,
-					writeErr: nil,
 				},
 			},
 			args: args{
@@ -180,33 +288,611 @@ func TestUnbound_UpdateIps(t *testing.T) {
 						RecordType: "A",
 						Prio:       200,
 						Ttl:        30,
+						Ptr:        true,
 					}, &dummyHealthCheck{}),
 				},
 			},
 			want: true,
 			wantWritten: []string{
-				`local-data: "test-01.other.tld 30 A 192.168.1.5"`,
-				`local-data: "test-01.my.tld 30 A 192.168.1.5"`,
+				managedBlockStart,
+				`local-data: "test-01.my.tld 30 A 192.168.1.5" # managed-by: dns-ha test-01.my.tld`,
+				`local-data-ptr: "192.168.1.5 30 test-01.my.tld" # managed-by: dns-ha test-01.my.tld`,
+				managedBlockEnd,
 			},
 			wantErr: false,
 		},
+		{
+			name: "malformed managed block",
+			fields: fields{
+				fs: &dummyUnboundFs{
+					read: []string{
+						managedBlockStart,
+						`local-data: "test-01.my.tld 60 A 192.168.1.25"`,
+					},
+				},
+			},
+			args: args{
+				dnsRecord: "test-01.my.tld",
+				records: []internal.ManagedDnsRecord{
+					mustNewDnsRecord(conf.RecordConfig{
+						IP:         "192.168.1.5",
+						RecordType: "A",
+						Prio:       200,
+						Ttl:        30,
+					}, &dummyHealthCheck{}),
+				},
+			},
+			want:        false,
+			wantWritten: nil,
+			wantErr:     true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			u := &Unbound{
 				fs: tt.fields.fs,
 			}
-			got, err := u.UpdateIps(tt.args.dnsRecord, tt.args.records)
+			got, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{tt.args.dnsRecord: tt.args.records})
 			if (err != nil) != tt.wantErr {
-				t.Errorf("UpdateIps() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("Apply() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if got != tt.want {
-				t.Errorf("UpdateIps() got = %v, want %v", got, tt.want)
+				t.Errorf("Apply() got = %v, want %v", got, tt.want)
 			}
 
 			if !reflect.DeepEqual(tt.fields.fs.(*dummyUnboundFs).written, tt.wantWritten) {
-				t.Errorf("UpdateIps() got written = %v, want %v", tt.fields.fs.(*dummyUnboundFs).written, tt.wantWritten)
+				t.Errorf("Apply() got written = %v, want %v", tt.fields.fs.(*dummyUnboundFs).written, tt.wantWritten)
+			}
+		})
+	}
+}
+
+func TestFsImpl_WriteConfAndRollback(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "unbound.conf")
+	if err := os.WriteFile(file, []byte("v0"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := NewUnboundConfigWrapper(file, false, WithBackups(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []string{"v1", "v2", "v3"} {
+		if err := fs.WriteConf([]string{version}); err != nil {
+			t.Fatalf("WriteConf() unexpected error = %v", err)
+		}
+	}
+
+	backups, _ := filepath.Glob(file + ".*" + files.BackupSuffix)
+	if len(backups) != 2 {
+		t.Errorf("expected 2 backups, got %v", backups)
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("expected no temporary files, got %v", leftovers)
+	}
+
+	if err := fs.Rollback(); err != nil {
+		t.Fatalf("Rollback() unexpected error = %v", err)
+	}
+
+	content, _ := os.ReadFile(file)
+	if string(content) != "v2" {
+		t.Errorf("expected rolled back content %q, got %q", "v2", content)
+	}
+
+	if err := fs.Rollback(); err == nil {
+		t.Errorf("expected error when rolling back twice")
+	}
+}
+
+func TestFsImpl_WriteConfAttributes(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "unbound.conf")
+	attrs := files.ModeOnly(0600)
+	// changing the group to the current group is allowed without privileges
+	attrs.Gid = os.Getgid()
+
+	fs, err := NewUnboundConfigWrapper(file, true, WithFileAttributes(attrs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.WriteConf([]string{"v1"}); err != nil {
+		t.Fatalf("WriteConf() unexpected error = %v", err)
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	if _, err := NewUnboundConfigWrapper(file, true, WithFileAttributes(files.ModeOnly(os.ModeDir|0600))); err == nil {
+		t.Error("expected error for mode with non-permission bits")
+	}
+}
+
+func TestFsImpl_ValidateConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "unbound.conf")
+	// the wrapper config is passed as last argument, which becomes $0 of the script
+	fs, err := NewUnboundConfigWrapper(file, true, WithCheckconf("/bin/sh", []string{"-c", `cat "$0"; exit 1`}, CheckconfTargetDbFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.ValidateConfig(context.Background())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if !strings.Contains(err.Error(), fmt.Sprintf("include: %q", file)) {
+		t.Errorf("expected error to contain the checkconf output including the db file, got %v", err)
+	}
+}
+
+func TestUnbound_PublishedIps(t *testing.T) {
+	fs := &dummyUnboundFs{read: []string{
+		`local-data: "my.tld 60 IN A 10.0.0.9"`,
+		managedBlockStart,
+		`local-data: "my.tld 60 IN A 10.0.0.1"`,
+		`local-data: "My.tld. 60 IN AAAA 2001:db8::1"`,
+		`local-data-ptr: "10.0.0.1 60 my.tld"`,
+		`local-data: "other.tld 60 IN A 10.0.0.2"`,
+		managedBlockEnd,
+	}}
+	u, err := NewUnbound(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := u.PublishedIps("my.tld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.1", "2001:db8::1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	fs.readErr = fmt.Errorf("failed to read zone file: %w", os.ErrNotExist)
+	if got, err := u.PublishedIps("my.tld"); err != nil || got != nil {
+		t.Errorf("expected missing file to have no records, got %v, %v", got, err)
+	}
+}
+
+func TestUnbound_ApplyView(t *testing.T) {
+	fs := &dummyUnboundFs{read: []string{
+		managedBlockStart,
+		`local-data: "my.tld 60 A 10.0.0.1"`,
+		managedBlockEnd,
+		"",
+	}}
+	u, err := NewUnbound(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	internalRecord := mustNewDnsRecord(conf.RecordConfig{IP: "192.168.0.1", RecordType: "A", Ttl: 60}, &dummyHealthCheck{})
+	updated, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"my.tld@internal": {internalRecord}})
+	if err != nil || !updated {
+		t.Fatalf("expected update, got %v, %v", updated, err)
+	}
+
+	want := []string{
+		managedBlockStart,
+		`local-data: "my.tld 60 A 10.0.0.1" # managed-by: dns-ha my.tld`,
+		"view:",
+		"\tname: \"internal\"",
+		"\tview-first: yes",
+		"\tlocal-data: \"my.tld 60 A 192.168.0.1\" # managed-by: dns-ha my.tld",
+		managedBlockEnd,
+		"",
+	}
+	if !reflect.DeepEqual(fs.written, want) {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(fs.written, "\n"), strings.Join(want, "\n"))
+	}
+
+	fs.read = fs.written
+	for name, want := range map[string][]string{"my.tld": {"10.0.0.1"}, "my.tld@internal": {"192.168.0.1"}} {
+		got, err := u.PublishedIps(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("PublishedIps(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	if updated, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"my.tld@internal": {internalRecord}}); err != nil || updated {
+		t.Errorf("expected parsed view to be unchanged, got %v, %v", updated, err)
+	}
+}
+
+func TestUnbound_ApplyMigratesHostnamesSeparately(t *testing.T) {
+	fs := &dummyUnboundFs{read: []string{
+		`local-data: "app.my.tld 60 A 192.168.1.25"`,
+		`local-data: "db.my.tld 60 A 192.168.2.25"`,
+		"",
+	}}
+	u, err := NewUnbound(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := mustNewDnsRecord(conf.RecordConfig{IP: "192.168.1.5", RecordType: "A", Ttl: 30}, &dummyHealthCheck{})
+	if _, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"app.my.tld": {app}}); err != nil {
+		t.Fatal(err)
+	}
+	// the second hostname is written after a restart, once the file has been parsed with the managed block
+	fs.read = fs.written
+	if u, err = NewUnbound(fs); err != nil {
+		t.Fatal(err)
+	}
+
+	db := mustNewDnsRecord(conf.RecordConfig{IP: "192.168.2.5", RecordType: "A", Ttl: 30}, &dummyHealthCheck{})
+	if _, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"db.my.tld": {db}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		managedBlockStart,
+		`local-data: "app.my.tld 30 A 192.168.1.5" # managed-by: dns-ha app.my.tld`,
+		`local-data: "db.my.tld 30 A 192.168.2.5" # managed-by: dns-ha db.my.tld`,
+		managedBlockEnd,
+		"",
+	}
+	if !reflect.DeepEqual(fs.written, want) {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(fs.written, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestUnbound_ApplyViewRefusesStatementsAfterBlock(t *testing.T) {
+	fs := &dummyUnboundFs{read: []string{
+		managedBlockStart,
+		`local-data: "my.tld 60 A 10.0.0.1"`,
+		managedBlockEnd,
+		`local-zone: "example.org." static`,
+	}}
+	u, err := NewUnbound(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	internalRecord := mustNewDnsRecord(conf.RecordConfig{IP: "192.168.0.1", RecordType: "A", Ttl: 60}, &dummyHealthCheck{})
+	updated, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"my.tld@internal": {internalRecord}})
+	if err == nil || !errors.Is(err, internal.ErrNotRetryable) || updated {
+		t.Fatalf("expected non-retryable error, got %v, %v", updated, err)
+	}
+	if fs.written != nil {
+		t.Errorf("expected nothing to be written, got\n%s", strings.Join(fs.written, "\n"))
+	}
+
+	// records outside of views are still written
+	record := mustNewDnsRecord(conf.RecordConfig{IP: "10.0.0.2", RecordType: "A", Ttl: 60}, &dummyHealthCheck{})
+	if updated, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"my.tld": {record}}); err != nil || !updated {
+		t.Errorf("expected update, got %v, %v", updated, err)
+	}
+}
+
+func TestUnbound_ApplyClientViews(t *testing.T) {
+	fs := &dummyUnboundFs{read: []string{
+		managedBlockStart,
+		`local-data: "my.tld 60 A 10.0.0.1" # managed-by: dns-ha my.tld`,
+		managedBlockEnd,
+		"",
+	}}
+	u, err := NewUnbound(fs, WithClientViews(map[string][]string{"vpn": {"10.8.0.0/24", "fd00:8::1/64"}, "lan": {"192.168.0.0/16"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vpnRecord := mustNewDnsRecord(conf.RecordConfig{IP: "10.8.0.1", RecordType: "A", Ttl: 60}, &dummyHealthCheck{})
+	updated, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"my.tld@vpn": {vpnRecord}})
+	if err != nil || !updated {
+		t.Fatalf("expected update, got %v, %v", updated, err)
+	}
+
+	want := []string{
+		managedBlockStart,
+		`local-data: "my.tld 60 A 10.0.0.1" # managed-by: dns-ha my.tld`,
+		"access-control-view: 192.168.0.0/16 lan",
+		"access-control-view: 10.8.0.0/24 vpn",
+		"access-control-view: fd00:8::/64 vpn",
+		"view:",
+		"\tname: \"vpn\"",
+		"\tview-first: yes",
+		"\tlocal-data: \"my.tld 60 A 10.8.0.1\" # managed-by: dns-ha my.tld",
+		"view:",
+		"\tname: \"lan\"",
+		"\tview-first: yes",
+		managedBlockEnd,
+		"",
+	}
+	if !reflect.DeepEqual(fs.written, want) {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(fs.written, "\n"), strings.Join(want, "\n"))
+	}
+
+	fs.read = fs.written
+	if updated, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"my.tld@vpn": {vpnRecord}}); err != nil || updated {
+		t.Errorf("expected parsed client views to be unchanged, got %v, %v", updated, err)
+	}
+
+	// removing the client views removes the empty view clauses as well
+	u.clientViews = nil
+	if updated, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"my.tld@vpn": {vpnRecord}}); err != nil || !updated {
+		t.Fatalf("expected update, got %v, %v", updated, err)
+	}
+	if slices.ContainsFunc(fs.written, func(line string) bool {
+		return strings.Contains(line, "lan") || strings.HasPrefix(line, accessControlView)
+	}) {
+		t.Errorf("expected client views to be removed, got\n%s", strings.Join(fs.written, "\n"))
+	}
+}
+
+func TestWithClientViews(t *testing.T) {
+	if _, err := NewUnbound(&dummyUnboundFs{}, WithClientViews(map[string][]string{"vpn": {"10.8.0.0"}})); err == nil {
+		t.Error("expected error for netblock without prefix length")
+	}
+	if _, err := NewUnbound(&dummyUnboundFs{}, WithClientViews(map[string][]string{"my vpn": {"10.8.0.0/24"}})); err == nil {
+		t.Error("expected error for invalid view name")
+	}
+}
+
+type countingUnboundFs struct {
+	dummyUnboundFs
+	reads  int
+	writes int
+}
+
+func (c *countingUnboundFs) ReadConf() ([]string, error) {
+	c.reads++
+	return c.dummyUnboundFs.ReadConf()
+}
+
+func (c *countingUnboundFs) WriteConf(conf []string) error {
+	c.writes++
+	return c.dummyUnboundFs.WriteConf(conf)
+}
+
+func TestUnbound_ApplyBatch(t *testing.T) {
+	fs := &countingUnboundFs{dummyUnboundFs: dummyUnboundFs{read: []string{""}}}
+	u, err := NewUnbound(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := mustNewDnsRecord(conf.RecordConfig{IP: "10.0.0.1", RecordType: "A", Ttl: 60}, &dummyHealthCheck{})
+	desired := map[string][]internal.ManagedDnsRecord{"a.tld": {record}, "b.tld": {record}, "c.tld": {record}}
+	if updated, err := u.Apply(context.Background(), desired); err != nil || !updated {
+		t.Fatalf("expected update, got %v, %v", updated, err)
+	}
+	if fs.reads != 1 || fs.writes != 1 {
+		t.Errorf("expected a single read and write, got %d reads and %d writes", fs.reads, fs.writes)
+	}
+
+	want := []string{
+		managedBlockStart,
+		`local-data: "a.tld 60 A 10.0.0.1" # managed-by: dns-ha a.tld`,
+		`local-data: "b.tld 60 A 10.0.0.1" # managed-by: dns-ha b.tld`,
+		`local-data: "c.tld 60 A 10.0.0.1" # managed-by: dns-ha c.tld`,
+		managedBlockEnd,
+		"",
+	}
+	if !reflect.DeepEqual(fs.written, want) {
+		t.Errorf("got %q, want %q", fs.written, want)
+	}
+}
+
+func TestFsImpl_ReadConfCached(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "unbound.conf")
+	if err := os.WriteFile(file, []byte("v0"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := NewUnboundConfigWrapper(file, false, WithBackups(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() string {
+		lines, err := fs.ReadConf()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	if got := read(); got != "v0" {
+		t.Fatalf("got %q", got)
+	}
+
+	// modified by someone else
+	if err := os.WriteFile(file, []byte("version 1"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != "version 1" {
+		t.Errorf("expected modified file to be read again, got %q", got)
+	}
+
+	if err := fs.WriteConf([]string{"version 2"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(); got != "version 2" {
+		t.Errorf("expected written file to be read again, got %q", got)
+	}
+}
+
+func TestUnbound_ApplyWildcard(t *testing.T) {
+	fs := &dummyUnboundFs{read: []string{""}}
+	u, err := NewUnbound(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := mustNewDnsRecord(conf.RecordConfig{IP: "10.0.0.1", RecordType: "A", Ttl: 60}, &dummyHealthCheck{})
+	if _, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"*.apps.tld": {record}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		managedBlockStart,
+		`local-zone: "apps.tld" redirect # managed-by: dns-ha *.apps.tld`,
+		`local-data: "apps.tld 60 A 10.0.0.1" # managed-by: dns-ha *.apps.tld`,
+		managedBlockEnd,
+		"",
+	}
+	if !reflect.DeepEqual(fs.written, want) {
+		t.Fatalf("got %q, want %q", fs.written, want)
+	}
+
+	fs.read = fs.written
+	ips, err := u.PublishedIps("*.apps.tld")
+	if err != nil || !reflect.DeepEqual(ips, []string{"10.0.0.1"}) {
+		t.Errorf("PublishedIps() = %v, %v", ips, err)
+	}
+
+	// the redirect zone is removed along with the last record
+	if _, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"*.apps.tld": nil}); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{managedBlockStart, managedBlockEnd, ""}
+	if !reflect.DeepEqual(fs.written, want) {
+		t.Errorf("got %q, want %q", fs.written, want)
+	}
+}
+
+func TestUnbound_readReusesParsedDb(t *testing.T) {
+	fs := &dummyUnboundFs{read: []string{managedBlockStart, `local-data: "a.tld 60 A 10.0.0.1" # managed-by: dns-ha a.tld`, managedBlockEnd, ""}}
+	u, err := NewUnbound(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := u.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second, _ := u.read(); second != first {
+		t.Error("expected the parsed db to be reused while the file is unchanged")
+	}
+
+	fs.read = []string{managedBlockStart, `local-data: "a.tld 60 A 10.0.0.2" # managed-by: dns-ha a.tld`, managedBlockEnd, ""}
+	if third, _ := u.read(); third == first {
+		t.Error("expected the db to be parsed again after the file changed")
+	}
+
+	// failed writes must not leave a modified db behind
+	fs.writeErr = errors.New("disk full")
+	record := mustNewDnsRecord(conf.RecordConfig{IP: "10.0.0.3", RecordType: "A", Ttl: 60}, &dummyHealthCheck{})
+	if _, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"a.tld": {record}}); err == nil {
+		t.Fatal("expected error")
+	}
+	ips, err := u.PublishedIps("a.tld")
+	if err != nil || !reflect.DeepEqual(ips, []string{"10.0.0.2"}) {
+		t.Errorf("PublishedIps() = %v, %v", ips, err)
+	}
+}
+
+// memUnboundFs keeps the written content in memory, so it's read back by the next cycle.
+type memUnboundFs struct {
+	dummyUnboundFs
+}
+
+func (m *memUnboundFs) WriteConf(conf []string) error {
+	m.read = conf
+	return nil
+}
+
+// benchmarkRecords returns the desired records of the given amount of hostnames, the hostname with the given index
+// points to an alternative ip.
+func benchmarkRecords(hostnames, changed int) map[string][]internal.ManagedDnsRecord {
+	records := []internal.ManagedDnsRecord{
+		mustNewDnsRecord(conf.RecordConfig{IP: "10.0.0.1", RecordType: "A", Ttl: 60}, &dummyHealthCheck{}),
+		mustNewDnsRecord(conf.RecordConfig{IP: "10.0.0.2", RecordType: "A", Ttl: 60}, &dummyHealthCheck{}),
+	}
+	alternative := []internal.ManagedDnsRecord{
+		mustNewDnsRecord(conf.RecordConfig{IP: "10.0.0.3", RecordType: "A", Ttl: 60}, &dummyHealthCheck{}),
+	}
+
+	ret := make(map[string][]internal.ManagedDnsRecord, hostnames)
+	for i := range hostnames {
+		ret[fmt.Sprintf("host-%d.my.tld", i)] = records
+	}
+	ret[fmt.Sprintf("host-%d.my.tld", changed)] = alternative
+	return ret
+}
+
+// BenchmarkUnbound_Apply measures a cycle that changes a single hostname while all hostnames are desired. The cost
+// grows linearly with the size of the file, as it's written as a whole, but not with hostnames times the file size.
+func BenchmarkUnbound_Apply(b *testing.B) {
+	for _, hostnames := range []int{100, 1000, 3000} {
+		b.Run(fmt.Sprintf("hostnames=%d", hostnames), func(b *testing.B) {
+			fs := &memUnboundFs{dummyUnboundFs{read: []string{""}}}
+			u, _ := NewUnbound(fs)
+			cycles := []map[string][]internal.ManagedDnsRecord{benchmarkRecords(hostnames, 0), benchmarkRecords(hostnames, 1)}
+			if _, err := u.Apply(context.Background(), cycles[0]); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := u.Apply(context.Background(), cycles[(i+1)%2]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkUnbound_ApplyUnchanged measures a cycle without changes, which neither parses nor writes the file.
+func BenchmarkUnbound_ApplyUnchanged(b *testing.B) {
+	for _, hostnames := range []int{100, 1000, 3000} {
+		b.Run(fmt.Sprintf("hostnames=%d", hostnames), func(b *testing.B) {
+			fs := &memUnboundFs{dummyUnboundFs{read: []string{""}}}
+			u, _ := NewUnbound(fs)
+			desired := benchmarkRecords(hostnames, 0)
+			if _, err := u.Apply(context.Background(), desired); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := u.Apply(context.Background(), map[string][]internal.ManagedDnsRecord{"host-0.my.tld": desired["host-0.my.tld"]}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDbFile_replace measures replacing the records of a single hostname, its cost does not depend on the size
+// of the file.
+func BenchmarkDbFile_replace(b *testing.B) {
+	for _, hostnames := range []int{100, 1000, 3000} {
+		b.Run(fmt.Sprintf("hostnames=%d", hostnames), func(b *testing.B) {
+			fs := &memUnboundFs{dummyUnboundFs{read: []string{""}}}
+			u, _ := NewUnbound(fs)
+			if _, err := u.Apply(context.Background(), benchmarkRecords(hostnames, 0)); err != nil {
+				b.Fatal(err)
+			}
+			db, err := parseDbFile(fs.read)
+			if err != nil {
+				b.Fatal(err)
+			}
+			wanted := [][]entry{
+				{{kind: localData, name: "host-1.my.tld", ttl: 60, rtype: "A", data: "10.0.0.3"}},
+				{{kind: localData, name: "host-1.my.tld", ttl: 60, rtype: "A", data: "10.0.0.4"}},
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if db.replace("host-1.my.tld", "", wanted[i%2]).empty() {
+					b.Fatal("expected a change")
+				}
 			}
 		})
 	}
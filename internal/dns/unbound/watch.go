@@ -0,0 +1,58 @@
+package unbound
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const watchDebounce = time.Second
+
+// Watch notifies onChange whenever the db file is modified, replaced or removed by anyone. Events are debounced, so
+// bursts of writes only result in a single notification. Changes made by dns-ha itself also trigger a notification,
+// so onChange must be idempotent.
+func (u *FsImpl) Watch(ctx context.Context, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("could not create watcher: %w", err)
+	}
+	defer func() {
+		_ = watcher.Close()
+	}()
+
+	// watch the directory instead of the file, as the file is replaced on every write
+	if err := watcher.Add(filepath.Dir(u.filePath)); err != nil {
+		return fmt.Errorf("could not watch %q: %w", u.filePath, err)
+	}
+
+	fileName := filepath.Clean(u.filePath)
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) == fileName {
+				debounce.Reset(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("Error while watching unbound db file", "err", err)
+		case <-debounce.C:
+			slog.Debug("Detected change of unbound db file", "file", u.filePath)
+			onChange()
+		}
+	}
+}
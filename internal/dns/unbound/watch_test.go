@@ -0,0 +1,47 @@
+package unbound
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFsImpl_Watch(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "unbound.conf")
+	fs, err := NewUnboundConfigWrapper(file, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 10)
+	go func() {
+		_ = fs.Watch(ctx, func() {
+			changes <- struct{}{}
+		})
+	}()
+
+	// give the watcher time to start
+	time.Sleep(100 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(file, []byte("tampered"), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected change notification")
+	}
+
+	select {
+	case <-changes:
+		t.Error("expected burst of writes to be debounced into a single notification")
+	case <-time.After(2 * watchDebounce):
+	}
+}
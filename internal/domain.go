@@ -7,16 +7,25 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/soerenschneider/dns-ha/internal/clock"
 	"github.com/soerenschneider/dns-ha/internal/conf"
 	"github.com/soerenschneider/dns-ha/internal/metrics"
 	"github.com/soerenschneider/dns-ha/internal/status"
+	"go.uber.org/multierr"
+)
+
+const (
+	defaultCheckTimeout      = conf.DefaultCheckTimeout
+	defaultBackoffMultiplier = 2
 )
 
 var (
 	ErrReloadNotSupported error = errors.New("reload not supported")
+	ErrFlushNotSupported  error = errors.New("cache flush not supported")
 	PriorityComparator          = func(a, b ManagedDnsRecord) int {
 		return cmp.Compare(b.Priority, a.Priority)
 	}
@@ -26,48 +35,162 @@ type Healthcheck interface {
 	IsHealthy(ctx context.Context) (bool, error)
 }
 
+// ChangeNotifier is optionally implemented by Healthchecks that notice changes of the health on their own, e.g. by
+// watching a file. The records of the hostname are checked right away once a change is signaled.
+type ChangeNotifier interface {
+	Changes(ctx context.Context) <-chan struct{}
+}
+
 type DnsRecord struct {
 	Priority uint8
 	DnsType  string
 	Ip       net.IP
-	Ttl      uint16
+	// Zone is the zone of IPv6 link-local addresses, it is only used to reach the address and never published.
+	Zone string
+	Ttl  uint16
+	// Ptr signals that a reverse record should be published alongside the record.
+	Ptr bool
+	// Txt is the text of TXT records, which carry no address.
+	Txt string
+}
+
+// Data returns the data of the record in presentation format, the text of TXT records is quoted.
+func (r DnsRecord) Data() string {
+	if r.DnsType == "TXT" {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(r.Txt) + `"`
+	}
+	return r.Ip.String()
+}
+
+// Address returns the ip of the record including its zone, e.g. "fe80::1%eth0", to reach scoped addresses.
+func (r DnsRecord) Address() string {
+	if r.Zone == "" {
+		return r.Ip.String()
+	}
+	return r.Ip.String() + "%" + r.Zone
 }
 
 func NewDnsRecord(conf conf.RecordConfig) (DnsRecord, error) {
-	parsed := net.ParseIP(conf.IP)
+	address, zone, _ := strings.Cut(conf.IP, "%")
+	parsed := net.ParseIP(address)
 	if parsed == nil {
 		return DnsRecord{}, fmt.Errorf("could not parse %s as ip address", conf.IP)
 	}
 
-	if parsed.To4() == nil && conf.RecordType != "AAAA" {
-		return DnsRecord{}, fmt.Errorf("refusing to use IPv4 address with AAAA record")
+	if err := checkAddressFamily(parsed, conf.RecordType); err != nil {
+		return DnsRecord{}, err
+	}
+
+	if zone != "" && (parsed.To4() != nil || !parsed.IsLinkLocalUnicast()) {
+		return DnsRecord{}, fmt.Errorf("zone of %s is only supported for IPv6 link-local addresses", conf.IP)
 	}
 
 	return DnsRecord{
 		Priority: uint8(conf.Prio), //nolint G115
 		DnsType:  conf.RecordType,
 		Ip:       parsed,
+		Zone:     zone,
 		Ttl:      uint16(conf.Ttl), //nolint G115
+		Ptr:      conf.Ptr,
 	}, nil
 
 }
 
+// checkAddressFamily makes sure A records only use IPv4 and AAAA records only use IPv6 addresses.
+func checkAddressFamily(ip net.IP, dnsType string) error {
+	isIpv4 := ip.To4() != nil
+	switch {
+	case dnsType == "A" && !isIpv4:
+		return fmt.Errorf("refusing to use IPv6 address %s with A record", ip)
+	case dnsType == "AAAA" && isIpv4:
+		return fmt.Errorf("refusing to use IPv4 address %s with AAAA record", ip)
+	case dnsType != "A" && dnsType != "AAAA":
+		return fmt.Errorf("unsupported record type %q", dnsType)
+	}
+	return nil
+}
+
 type ManagedDnsRecord struct {
 	DnsRecord
 	Hostname         string
 	status           status.State
 	healthCheck      Healthcheck
+	checkKey         string
+	checkTimeout     time.Duration
 	lastStatusChange time.Time
+
+	backoff      *conf.BackoffConfig
+	backoffDelay time.Duration
+	nextCheck    time.Time
+	// latency is the duration of the last successful check
+	latency time.Duration
+
+	history *checkHistory
+	shared  *sharedState
+	// expiresAt is the time the record is removed at, the record never expires if it's zero.
+	expiresAt time.Time
+	// clock is set by the RecordManager, the real clock is used if it's nil.
+	clock clock.Clock
 }
 
-func NewManagedDnsRecord(hostname string, record DnsRecord, statusOpts conf.StatusConfig, healthCheck Healthcheck) (*ManagedDnsRecord, error) {
-	return &ManagedDnsRecord{
+type ManagedDnsRecordOpts func(*ManagedDnsRecord) error
+
+func NewManagedDnsRecord(hostname string, record DnsRecord, statusOpts conf.StatusConfig, healthCheck Healthcheck, opts ...ManagedDnsRecordOpts) (*ManagedDnsRecord, error) {
+	r := &ManagedDnsRecord{
 		Hostname:         hostname,
 		DnsRecord:        record,
 		status:           status.NewUnknownState(statusOpts),
 		healthCheck:      healthCheck,
+		checkTimeout:     defaultCheckTimeout,
 		lastStatusChange: time.Time{},
-	}, nil
+		history:          newCheckHistory(defaultHistorySize),
+		shared:           &sharedState{},
+	}
+	r.shared.update(r.status)
+
+	var errs error
+	for _, opt := range opts {
+		if err := opt(r); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	return r, errs
+}
+
+// WithCheckTimeout sets the maximum duration a single healthcheck of the record may take.
+func WithCheckTimeout(timeout time.Duration) ManagedDnsRecordOpts {
+	return func(r *ManagedDnsRecord) error {
+		if timeout <= 0 {
+			return errors.New("check timeout must be positive")
+		}
+		r.checkTimeout = timeout
+		return nil
+	}
+}
+
+// WithBackoff reduces the probe frequency of the record after it has been unhealthy for longer than the policy allows.
+func WithBackoff(policy conf.BackoffConfig) ManagedDnsRecordOpts {
+	return func(r *ManagedDnsRecord) error {
+		if policy.Initial <= 0 || policy.Max < policy.Initial {
+			return errors.New("invalid backoff policy")
+		}
+		r.backoff = &policy
+		return nil
+	}
+}
+
+// ShouldCheck returns false while the record's checks are backed off.
+func (r *ManagedDnsRecord) ShouldCheck(now time.Time) bool {
+	return !now.Before(r.nextCheck)
+}
+
+// Now returns the current time of the record's clock.
+func (r *ManagedDnsRecord) Now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+	return r.clock.Now()
 }
 
 func (r *ManagedDnsRecord) GetState() status.State {
@@ -76,34 +199,123 @@ func (r *ManagedDnsRecord) GetState() status.State {
 
 func (r *ManagedDnsRecord) Eval(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
+	r.applyProbe(r.probe(ctx))
+}
 
-	isHealthy, err := r.healthCheck.IsHealthy(ctx)
-	if err != nil {
-		slog.Error("healthcheck produced error", "err", err)
+// probeResult is the outcome of a healthcheck that has not yet been applied to the state of the record.
+type probeResult struct {
+	result  CheckResult
+	healthy bool
+	err     error
+}
+
+// probe runs the healthcheck without touching the state of the record, so it can run concurrently to decisions based
+// on the state.
+func (r *ManagedDnsRecord) probe(ctx context.Context) probeResult {
+	ctx, cancel := context.WithTimeout(ctx, r.checkTimeout)
+	defer cancel()
+
+	start := r.Now()
+	injected := r.failureInjected(start)
+	var isHealthy bool
+	var err error
+	if injected {
+		r.recordInjectedFailure()
+	} else {
+		isHealthy, err = r.check(ctx)
+		if err != nil {
+			err = classifyCheckError(ctx, err)
+		}
+	}
+	return probeResult{
+		result: CheckResult{
+			Timestamp: start,
+			Healthy:   isHealthy && err == nil,
+			Latency:   r.Now().Sub(start),
+			Injected:  injected,
+		},
+		healthy: isHealthy,
+		err:     err,
+	}
+}
+
+// Latency returns the duration of the last check that completed without error.
+func (r *ManagedDnsRecord) Latency() time.Duration {
+	return r.latency
+}
+
+// applyProbe transitions the state of the record according to the outcome of a healthcheck.
+func (r *ManagedDnsRecord) applyProbe(probe probeResult) {
+	result := probe.result
+	defer func() {
+		result.Status = r.status.Name()
+		r.history.add(result)
+		r.shared.update(r.status)
+	}()
+	defer r.updateBackoff(probe.healthy && probe.err == nil)
+	if probe.err != nil {
+		slog.Error("healthcheck produced error", "hostname", r.Hostname, "ip", r.Ip, "err", probe.err)
+		result.Error = probe.err.Error()
+		result.ErrorKind = ErrorKind(probe.err)
+		r.shared.setLastError(result.Error, result.ErrorKind, result.Timestamp)
+		metrics.CheckErrors.WithLabelValues(r.Hostname, r.Ip.String(), result.ErrorKind).Inc()
+		metrics.SetLastCheckError(r.Hostname, r.Ip.String(), result.Error)
 		r.status.Error(r)
 		return
 	}
 
-	slog.Debug("healthcheck", "healthy", isHealthy, "ip", r.Ip)
-	if isHealthy {
+	slog.Debug("healthcheck", "healthy", probe.healthy, "ip", r.Ip)
+	r.latency = result.Latency
+	if probe.healthy {
 		r.status.Healthy(r)
 	} else {
 		r.status.Unhealthy(r)
 	}
 }
 
+func (r *ManagedDnsRecord) updateBackoff(success bool) {
+	if r.backoff == nil {
+		return
+	}
+
+	// resume regular checks as soon as a single check succeeds
+	if success || r.status.Name() != status.UnhealthyStateName || r.Now().Sub(r.lastStatusChange) < r.backoff.After {
+		if r.backoffDelay > 0 {
+			slog.Info("Resuming regular checks", "hostname", r.Hostname, "ip", r.Ip)
+		}
+		r.backoffDelay = 0
+		r.nextCheck = time.Time{}
+		return
+	}
+
+	if r.backoffDelay == 0 {
+		slog.Info("Backing off checks for persistently unhealthy record", "hostname", r.Hostname, "ip", r.Ip)
+		r.backoffDelay = r.backoff.Initial
+	} else {
+		multiplier := cmp.Or(r.backoff.Multiplier, defaultBackoffMultiplier)
+		r.backoffDelay = min(time.Duration(float64(r.backoffDelay)*multiplier), r.backoff.Max)
+	}
+	r.nextCheck = r.Now().Add(r.backoffDelay)
+}
+
 func (r *ManagedDnsRecord) SetState(newStatus status.State) {
 	// update metrics
 	metrics.StatusChangeTimestamp.WithLabelValues(r.Hostname, r.Ip.String()).SetToCurrentTime()
-	for _, state := range []string{status.HealthyStateName, status.UnhealthyStateName} {
+	updateStatusMetrics(r.Hostname, r.Ip.String(), newStatus.Name())
+
+	slog.Info("Status change", "record", r.Ip, "old", r.status.Name(), "new", newStatus.Name())
+	r.lastStatusChange = r.Now()
+	r.shared.addTransition(Transition{Timestamp: r.lastStatusChange, From: r.status.Name(), To: newStatus.Name()})
+	r.status = newStatus
+}
+
+func updateStatusMetrics(hostname, ip, name string) {
+	for _, state := range []string{status.HealthyStateName, status.UnhealthyStateName, status.ErrorStateName} {
 		var val float64 = 0
-		if newStatus.Name() == state {
+		if name == state {
 			val = 1
 		}
-		metrics.Status.WithLabelValues(r.Hostname, r.Ip.String(), state).Set(val)
+		metrics.Status.WithLabelValues(hostname, ip, state).Set(val)
 	}
-
-	slog.Info("Status change", "record", r.Ip, "old", r.status.Name(), "new", newStatus.Name())
-	r.status = newStatus
-	r.lastStatusChange = time.Now()
+	metrics.NotifyChange()
 }
@@ -1,10 +1,17 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"net"
 	"reflect"
 	"slices"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/status"
 )
 
 func TestComparator(t *testing.T) {
@@ -36,3 +43,117 @@ func TestComparator(t *testing.T) {
 		t.Errorf("expected %v, got %v", record1, records[0])
 	}
 }
+
+func TestManagedDnsRecord_Backoff(t *testing.T) {
+	record, err := NewManagedDnsRecord("my.tld", DnsRecord{Ip: net.ParseIP("10.0.0.1")}, conf.StatusConfig{}, &dummyHealthcheck{ret: false},
+		WithBackoff(conf.BackoffConfig{
+			After:   time.Minute,
+			Initial: 10 * time.Second,
+			Max:     30 * time.Second,
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.status = &status.Unhealthy{}
+	record.lastStatusChange = time.Now().Add(-time.Hour)
+
+	wg := &sync.WaitGroup{}
+	for _, want := range []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second} {
+		wg.Add(1)
+		record.Eval(context.Background(), wg)
+		if record.backoffDelay != want {
+			t.Errorf("expected backoff delay %v, got %v", want, record.backoffDelay)
+		}
+		if record.ShouldCheck(time.Now()) {
+			t.Errorf("expected check to be backed off")
+		}
+	}
+
+	record.healthCheck = &dummyHealthcheck{ret: true}
+	wg.Add(1)
+	record.Eval(context.Background(), wg)
+	if record.backoffDelay != 0 || !record.ShouldCheck(time.Now()) {
+		t.Errorf("expected backoff to be reset after successful check, got delay %v", record.backoffDelay)
+	}
+}
+
+func TestManagedDnsRecord_LastError(t *testing.T) {
+	healthcheck := &dummyHealthcheck{retErr: errors.New("connection refused")}
+	record, err := NewManagedDnsRecord("my.tld", DnsRecord{Ip: net.ParseIP("10.0.0.1")}, conf.StatusConfig{}, healthcheck)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := record.Status(); got.LastError != "" || got.LastErrorTime != nil {
+		t.Errorf("expected no error before the first check, got %q", got.LastError)
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	record.Eval(context.Background(), wg)
+	got := record.Status()
+	if got.LastError != "connection refused" || got.LastErrorTime == nil {
+		t.Errorf("expected last error %q, got %q", "connection refused", got.LastError)
+	}
+
+	// the error is kept after the record recovers, so it can still be diagnosed
+	healthcheck.retErr = nil
+	healthcheck.ret = true
+	wg.Add(1)
+	record.Eval(context.Background(), wg)
+	if got := record.Status(); got.LastError != "connection refused" {
+		t.Errorf("expected last error to be kept, got %q", got.LastError)
+	}
+}
+
+func TestNewDnsRecord_AddressFamily(t *testing.T) {
+	tests := []struct {
+		ip         string
+		recordType string
+		wantErr    bool
+	}{
+		{ip: "10.0.0.1", recordType: "A"},
+		{ip: "2001:db8::1", recordType: "AAAA"},
+		{ip: "10.0.0.1", recordType: "AAAA", wantErr: true},
+		{ip: "2001:db8::1", recordType: "A", wantErr: true},
+		{ip: "10.0.0.1", recordType: "CNAME", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.recordType+" "+tt.ip, func(t *testing.T) {
+			_, err := NewDnsRecord(conf.RecordConfig{IP: tt.ip, RecordType: tt.recordType, Prio: 10, Ttl: 60})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewDnsRecord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewDnsRecord_Zone(t *testing.T) {
+	tests := []struct {
+		ip          string
+		wantData    string
+		wantAddress string
+		wantErr     bool
+	}{
+		{ip: "fe80::1%eth0", wantData: "fe80::1", wantAddress: "fe80::1%eth0"},
+		{ip: "fe80::1", wantData: "fe80::1", wantAddress: "fe80::1"},
+		{ip: "2001:db8::1%eth0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			record, err := NewDnsRecord(conf.RecordConfig{IP: tt.ip, RecordType: "AAAA", Prio: 10, Ttl: 60})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewDnsRecord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := record.Data(); got != tt.wantData {
+				t.Errorf("Data() = %v, want %v", got, tt.wantData)
+			}
+			if got := record.Address(); got != tt.wantAddress {
+				t.Errorf("Address() = %v, want %v", got, tt.wantAddress)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Typed errors classify failures, so automation can tell errors that need a change of the config apart from transient
+// ones. Errors are wrapped, the classification is available using errors.Is and ErrorKind.
+var (
+	// ErrBackendUnavailable is returned if the DNS backend or the service could not be updated, reloaded or restarted.
+	ErrBackendUnavailable = errors.New("backend unavailable")
+	// ErrValidationFailed is returned if the DNS backend rejected the written records.
+	ErrValidationFailed = errors.New("validation failed")
+	// ErrCheckTimeout is returned if a healthcheck did not produce a result within its timeout.
+	ErrCheckTimeout = errors.New("check timed out")
+	// ErrCheckMisconfigured is returned if a healthcheck can not run due to its config or missing privileges.
+	ErrCheckMisconfigured = errors.New("check misconfigured")
+)
+
+// Error kinds are the label values of the typed errors in metrics and the API.
+const (
+	ErrorKindBackendUnavailable = "backend_unavailable"
+	ErrorKindValidationFailed   = "validation_failed"
+	ErrorKindCheckTimeout       = "check_timeout"
+	ErrorKindCheckMisconfigured = "check_misconfigured"
+	ErrorKindOther              = "other"
+)
+
+// ErrorKind returns the kind of the typed error wrapped by err, ErrorKindOther if it does not wrap a typed error and the
+// empty string for nil errors.
+func ErrorKind(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrValidationFailed):
+		return ErrorKindValidationFailed
+	case errors.Is(err, ErrCheckMisconfigured):
+		return ErrorKindCheckMisconfigured
+	case errors.Is(err, ErrCheckTimeout):
+		return ErrorKindCheckTimeout
+	case errors.Is(err, ErrBackendUnavailable):
+		return ErrorKindBackendUnavailable
+	default:
+		return ErrorKindOther
+	}
+}
+
+// classify wraps errors that do not wrap a typed error yet with the given typed error.
+func classify(err, typed error) error {
+	if err == nil || ErrorKind(err) != ErrorKindOther {
+		return err
+	}
+	return fmt.Errorf("%w: %w", typed, err)
+}
+
+// classifyCheckError marks errors of healthchecks that ran into their timeout.
+func classifyCheckError(ctx context.Context, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return classify(err, ErrCheckTimeout)
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestErrorKind(t *testing.T) {
+	expired, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "nil", err: nil, want: ""},
+		{name: "untyped", err: errors.New("connection reset"), want: ErrorKindOther},
+		{name: "wrapped", err: fmt.Errorf("named-checkconf: %w", ErrValidationFailed), want: ErrorKindValidationFailed},
+		{name: "classified", err: classify(errors.New("dbus hiccup"), ErrBackendUnavailable), want: ErrorKindBackendUnavailable},
+		{name: "classified keeps kind", err: classify(fmt.Errorf("%w: bad url", ErrCheckMisconfigured), ErrBackendUnavailable), want: ErrorKindCheckMisconfigured},
+		{name: "check deadline", err: classifyCheckError(context.Background(), fmt.Errorf("dial: %w", context.DeadlineExceeded)), want: ErrorKindCheckTimeout},
+		{name: "check context expired", err: classifyCheckError(expired, errors.New("i/o timeout")), want: ErrorKindCheckTimeout},
+		{name: "check error", err: classifyCheckError(context.Background(), errors.New("no route to host")), want: ErrorKindOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorKind(tt.err); got != tt.want {
+				t.Errorf("ErrorKind() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// WithExpiry removes the record at the given time, e.g. for temporary failover targets that must not linger.
+func WithExpiry(expiresAt time.Time) ManagedDnsRecordOpts {
+	return func(r *ManagedDnsRecord) error {
+		if expiresAt.IsZero() {
+			return errors.New("empty expiry supplied")
+		}
+		r.expiresAt = expiresAt
+		return nil
+	}
+}
+
+// Expired returns true if the record has an expiry that has been reached.
+func (r *ManagedDnsRecord) Expired(now time.Time) bool {
+	return !r.expiresAt.IsZero() && !now.Before(r.expiresAt)
+}
+
+// removeExpiredRecords stops managing the records that expired, hostnames whose records all expired are removed from
+// the DNS backend.
+func (h *RecordManager) removeExpiredRecords(ctx context.Context) {
+	now := h.clock.Now()
+	for _, records := range h.managedRecords {
+		if slices.ContainsFunc(records, func(r *ManagedDnsRecord) bool { return r.Expired(now) }) {
+			// replacing the records drops the expired ones
+			h.stopCheckLoops()
+			h.replaceRecords(ctx, recordsUpdate{records: h.managedRecords, policies: h.hostnamePolicies})
+			h.startCheckLoops(ctx)
+			return
+		}
+	}
+}
+
+// dropExpiredRecords stops managing the records that expired before the start, hostnames whose records all expired
+// are removed from the DNS backend, as they may have been published before the restart.
+func (h *RecordManager) dropExpiredRecords(ctx context.Context) {
+	records, expired := h.withoutExpired(h.managedRecords)
+	if !expired {
+		return
+	}
+
+	var removedHostnames []string
+	for hostname := range h.managedRecords {
+		if _, found := records[hostname]; !found {
+			removedHostnames = append(removedHostnames, hostname)
+		}
+	}
+	slices.Sort(removedHostnames)
+
+	h.recordsMutex.Lock()
+	h.managedRecords = records
+	h.recordsMutex.Unlock()
+	h.removeHostnames(ctx, removedHostnames)
+}
+
+// withoutExpired returns the records without the expired ones and whether any record expired. The given map is not
+// modified.
+func (h *RecordManager) withoutExpired(records map[string][]*ManagedDnsRecord) (map[string][]*ManagedDnsRecord, bool) {
+	now := h.clock.Now()
+	var expired bool
+	ret := make(map[string][]*ManagedDnsRecord, len(records))
+	for hostname, ips := range records {
+		var kept []*ManagedDnsRecord
+		for _, record := range ips {
+			if record.Expired(now) {
+				slog.Info("Removing expired record", "hostname", hostname, "ip", record.Ip, "expired_at", record.expiresAt)
+				expired = true
+				continue
+			}
+			kept = append(kept, record)
+		}
+		// hostnames without records are removed, unless they did not have any records to begin with
+		if len(kept) > 0 || len(ips) == 0 {
+			ret[hostname] = kept
+		}
+	}
+	return ret, expired
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/clock"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestRecordManager_removeExpiredRecords(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	newRecord := func(hostname, ip string, prio uint8, opts ...ManagedDnsRecordOpts) *ManagedDnsRecord {
+		record, err := NewManagedDnsRecord(hostname, DnsRecord{Priority: prio, DnsType: "A", Ip: net.ParseIP(ip), Ttl: 60}, conf.StatusConfig{
+			HealthyStreak:          1,
+			UnhealthyStreak:        1,
+			InitialHealthyStreak:   1,
+			InitialUnhealthyStreak: 1,
+		}, &dummyHealthcheck{ret: true}, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record
+	}
+
+	expiry := WithExpiry(fake.Now().Add(time.Hour))
+	db := &dummyDnsDb{}
+	m, err := NewRecordManager(db, &dummyService{}, map[string][]*ManagedDnsRecord{
+		"a.tld": {newRecord("a.tld", "10.0.0.1", 10), newRecord("a.tld", "10.0.0.2", 20, expiry)},
+		"b.tld": {newRecord("b.tld", "10.0.0.3", 10, expiry)},
+	}, WithClock(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.CheckRecords(ctx)
+	m.removeExpiredRecords(ctx)
+	if got := db.updates["a.tld"]; len(got) != 1 || got[0] != "A 10.0.0.2" {
+		t.Fatalf("expected the preferred record of a.tld to be published before its expiry, got %v", got)
+	}
+
+	fake.Advance(time.Hour)
+	m.removeExpiredRecords(ctx)
+	m.stopCheckLoops()
+
+	if got := db.updates["a.tld"]; len(got) != 1 || got[0] != "A 10.0.0.1" {
+		t.Errorf("expected the remaining record of a.tld to be published, got %v", got)
+	}
+	if got := db.updates["b.tld"]; len(got) != 0 {
+		t.Errorf("expected records of b.tld to be removed, got %v", got)
+	}
+	if _, found := m.managedRecords["b.tld"]; found {
+		t.Error("expected b.tld not to be managed anymore")
+	}
+
+	// records that are still part of the config are dropped when the config is applied again
+	m.replaceRecords(ctx, recordsUpdate{records: map[string][]*ManagedDnsRecord{
+		"b.tld": {newRecord("b.tld", "10.0.0.3", 10, expiry)},
+	}})
+	if _, found := m.managedRecords["b.tld"]; found {
+		t.Error("expected expired record of b.tld not to be managed again")
+	}
+}
+
+func TestRecordManager_Run_removesRecordsExpiredBeforeStart(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	newRecord := func(hostname, ip string, opts ...ManagedDnsRecordOpts) *ManagedDnsRecord {
+		record, err := NewManagedDnsRecord(hostname, DnsRecord{Priority: 10, DnsType: "A", Ip: net.ParseIP(ip), Ttl: 60}, conf.StatusConfig{
+			HealthyStreak:          1,
+			UnhealthyStreak:        1,
+			InitialHealthyStreak:   1,
+			InitialUnhealthyStreak: 1,
+		}, &dummyHealthcheck{ret: true}, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record
+	}
+
+	// b.tld has been published before the restart, its only record expired while dns-ha was down
+	db := &lockedDnsDb{dummyDnsDb: dummyDnsDb{published: map[string][]string{"b.tld": {"10.0.0.3"}}}}
+	m, err := NewRecordManager(db, &dummyService{}, map[string][]*ManagedDnsRecord{
+		"a.tld": {newRecord("a.tld", "10.0.0.1")},
+		"b.tld": {newRecord("b.tld", "10.0.0.3", WithExpiry(fake.Now().Add(-time.Minute)))},
+	}, WithClock(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if got := db.published("b.tld"); got != nil && len(got) == 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected records of b.tld to be removed, got %v", db.published("b.tld"))
+}
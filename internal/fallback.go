@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+	"github.com/soerenschneider/dns-ha/internal/schedule"
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+const (
+	// AllUnhealthyKeepLast leaves the last published records in place.
+	AllUnhealthyKeepLast = "keep_last"
+	// AllUnhealthyPublishAll publishes all configured records of the hostname.
+	AllUnhealthyPublishAll = "publish_all"
+	// AllUnhealthyFallback publishes a designated fallback IP.
+	AllUnhealthyFallback = "fallback"
+	// AllUnhealthyRemove removes all records of the hostname.
+	AllUnhealthyRemove = "remove"
+)
+
+var allUnhealthyPolicies = []string{AllUnhealthyKeepLast, AllUnhealthyPublishAll, AllUnhealthyFallback, AllUnhealthyRemove}
+
+// HostnamePolicy holds settings that apply to all records of a single hostname.
+type HostnamePolicy struct {
+	// OnAllUnhealthy defines what is published while none of the records of the hostname is healthy.
+	OnAllUnhealthy string
+	// FallbackIp is published if OnAllUnhealthy is AllUnhealthyFallback.
+	FallbackIp net.IP
+	// KeepAddressFamilies keeps the published records of an address family until another family has a healthy record.
+	KeepAddressFamilies bool
+	// DependsOn lists hostnames that are updated before this hostname. The records of this hostname are only changed
+	// once the changes of all of its dependencies have been applied, all changes of a cycle lead to a single restart.
+	DependsOn []string
+	// CheckInterval overrides the interval between the checks of the hostname's records.
+	CheckInterval time.Duration
+	// Strategy selects the published records among the healthy records, defaults to StrategyPriority.
+	Strategy Strategy
+	// MinRecords is the minimum amount of published records while at least one record is healthy, missing records are
+	// filled up with the best remaining records even if they are unhealthy. Zero disables the minimum.
+	MinRecords int
+	// MaxRecords caps the amount of published records, the records with the highest priority are kept. Zero disables
+	// the maximum.
+	MaxRecords int
+	// GracePeriod is the duration a record that took over from another record is kept published while it fails its
+	// checks, so the service behind it has time to warm up.
+	GracePeriod time.Duration
+	// EscalateAfter is the duration the hostname may have no healthy records before the outage is escalated to the
+	// OutageHooks. Outages are not escalated if it's zero.
+	EscalateAfter time.Duration
+	// Blackouts are recurring windows during which the published records are not changed.
+	Blackouts []*schedule.Window
+	// DecisionEngine selects the published records instead of the Strategy.
+	DecisionEngine DecisionEngine
+	// DecisionFallback selects the records using the Strategy while the DecisionEngine fails, otherwise the published
+	// records are kept.
+	DecisionFallback bool
+	// ShutdownIps are published by ShutdownPublishAll instead of the records that are not unhealthy.
+	ShutdownIps []net.IP
+}
+
+// WithHostnamePolicies sets the policies for individual hostnames, hostnames without a policy keep their last
+// published records if all records are unhealthy.
+func WithHostnamePolicies(policies map[string]HostnamePolicy) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		for hostname, policy := range policies {
+			if policy.OnAllUnhealthy != "" && !slices.Contains(allUnhealthyPolicies, policy.OnAllUnhealthy) {
+				return fmt.Errorf("unknown policy %q for hostname %q", policy.OnAllUnhealthy, hostname)
+			}
+			if policy.OnAllUnhealthy == AllUnhealthyFallback && policy.FallbackIp == nil {
+				return errors.New("fallback policy requires a fallback IP")
+			}
+			if policy.MinRecords < 0 || policy.MaxRecords < 0 || (policy.MaxRecords > 0 && policy.MaxRecords < policy.MinRecords) {
+				return fmt.Errorf("invalid record limits for hostname %q", hostname)
+			}
+		}
+		if err := dependencyCycle(policies); err != nil {
+			return err
+		}
+		m.hostnamePolicies = policies
+		return nil
+	}
+}
+
+func (h *RecordManager) allUnhealthyPolicy(hostname string) string {
+	return cmp.Or(h.hostnamePolicies[hostname].OnAllUnhealthy, AllUnhealthyKeepLast)
+}
+
+// fallbackRecords returns the records to publish while all records of the hostname are unhealthy. The boolean is
+// false if the currently published records should be kept.
+func (h *RecordManager) fallbackRecords(hostname string, ips []*ManagedDnsRecord) ([]ManagedDnsRecord, bool) {
+	policy := h.allUnhealthyPolicy(hostname)
+	for _, p := range allUnhealthyPolicies {
+		val := 0.
+		if p == policy {
+			val = 1
+		}
+		metrics.FallbackActive.WithLabelValues(hostname, p).Set(val)
+	}
+
+	switch policy {
+	case AllUnhealthyPublishAll:
+		ret := make([]ManagedDnsRecord, 0, len(ips))
+		for _, ip := range ips {
+			ret = append(ret, *ip)
+		}
+		return h.limitRecords(hostname, ips, ret), true
+	case AllUnhealthyFallback:
+		fallback, _ := h.fallbackRecord(hostname, ips)
+		return []ManagedDnsRecord{fallback}, true
+	case AllUnhealthyRemove:
+		return []ManagedDnsRecord{}, true
+	default:
+		return nil, false
+	}
+}
+
+// fallbackRecord returns the record of the hostname's fallback IP and false if the hostname has no fallback IP.
+func (h *RecordManager) fallbackRecord(hostname string, ips []*ManagedDnsRecord) (ManagedDnsRecord, bool) {
+	fallbackIp := h.hostnamePolicies[hostname].FallbackIp
+	if fallbackIp == nil {
+		return ManagedDnsRecord{}, false
+	}
+
+	dnsType := "AAAA"
+	if fallbackIp.To4() != nil {
+		dnsType = "A"
+	}
+
+	var ttl uint16
+	if len(ips) > 0 {
+		ttl = ips[0].Ttl
+	}
+
+	return ManagedDnsRecord{
+		Hostname: hostname,
+		DnsRecord: DnsRecord{
+			DnsType: dnsType,
+			Ip:      fallbackIp,
+			Ttl:     ttl,
+		},
+	}, true
+}
+
+func resetFallbackMetrics(hostname string) {
+	for _, p := range allUnhealthyPolicies {
+		metrics.FallbackActive.WithLabelValues(hostname, p).Set(0)
+	}
+}
+
+// keepAddressFamilies adds the currently published records of address families that are not selected to the
+// selection, as long as no other family has a healthy record, if the hostname's policy asks for it. This way the
+// records of a family are only removed once clients can turn to another family.
+func (h *RecordManager) keepAddressFamilies(hostname string, ips []*ManagedDnsRecord, selected []ManagedDnsRecord) []ManagedDnsRecord {
+	if !h.hostnamePolicies[hostname].KeepAddressFamilies {
+		return selected
+	}
+
+	selectedTypes := map[string]bool{}
+	for _, record := range selected {
+		selectedTypes[record.DnsType] = true
+	}
+
+	healthyTypes := map[string]bool{}
+	for _, ip := range ips {
+		if status.Effective(ip.GetState()).Name() == status.HealthyStateName && !ip.InMaintenance() {
+			healthyTypes[ip.DnsType] = true
+		}
+	}
+	otherFamilyHealthy := func(dnsType string) bool {
+		for healthyType := range healthyTypes {
+			if healthyType != dnsType {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, published := range h.publishedIps[hostname] {
+		for _, ip := range ips {
+			if ip.Ip.String() == published && !selectedTypes[ip.DnsType] && !otherFamilyHealthy(ip.DnsType) && !ip.InMaintenance() {
+				slog.Debug("Keeping record of address family while no other family has healthy records", "hostname", hostname, "ip", published)
+				selected = append(selected, *ip)
+			}
+		}
+	}
+
+	return selected
+}
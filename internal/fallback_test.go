@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+type dummyDnsDb struct {
+	updates   map[string][]string
+	published map[string][]string
+}
+
+func (d *dummyDnsDb) PublishedIps(hostname string) ([]string, error) {
+	return d.published[hostname], nil
+}
+
+func (d *dummyDnsDb) Apply(_ context.Context, desired map[string][]ManagedDnsRecord) (bool, error) {
+	if d.updates == nil {
+		d.updates = map[string][]string{}
+	}
+	for hostname, addresses := range desired {
+		ips := make([]string, 0, len(addresses))
+		for _, address := range addresses {
+			ips = append(ips, address.DnsType+" "+address.Data())
+		}
+		d.updates[hostname] = ips
+	}
+	return true, nil
+}
+
+func (d *dummyDnsDb) ValidateConfig(_ context.Context) error {
+	return nil
+}
+
+func TestRecordManager_fallback(t *testing.T) {
+	newUnhealthyRecords := func() []*ManagedDnsRecord {
+		return []*ManagedDnsRecord{
+			{
+				DnsRecord: DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60},
+				Hostname:  "my.tld",
+				status:    &status.Unhealthy{},
+			},
+			{
+				DnsRecord: DnsRecord{Priority: 10, DnsType: "A", Ip: net.ParseIP("10.0.0.2"), Ttl: 60},
+				Hostname:  "my.tld",
+				status:    &status.Unhealthy{},
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		policy      HostnamePolicy
+		wantUpdated bool
+		want        []string
+	}{
+		{
+			name:        "keep last",
+			policy:      HostnamePolicy{},
+			wantUpdated: false,
+		},
+		{
+			name:        "publish all",
+			policy:      HostnamePolicy{OnAllUnhealthy: AllUnhealthyPublishAll},
+			wantUpdated: true,
+			want:        []string{"A 10.0.0.1", "A 10.0.0.2"},
+		},
+		{
+			name:        "fallback ip",
+			policy:      HostnamePolicy{OnAllUnhealthy: AllUnhealthyFallback, FallbackIp: net.ParseIP("2001:db8::1")},
+			wantUpdated: true,
+			want:        []string{"AAAA 2001:db8::1"},
+		},
+		{
+			name:        "remove",
+			policy:      HostnamePolicy{OnAllUnhealthy: AllUnhealthyRemove},
+			wantUpdated: true,
+			want:        []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &dummyDnsDb{}
+			records := map[string][]*ManagedDnsRecord{"my.tld": newUnhealthyRecords()}
+			m, err := NewRecordManager(db, &dummyService{}, records, WithHostnamePolicies(map[string]HostnamePolicy{"my.tld": tt.policy}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m.applyRecords(context.Background())
+			if _, got := db.updates["my.tld"]; got != tt.wantUpdated {
+				t.Errorf("updated = %v, want %v", got, tt.wantUpdated)
+			}
+			if got := db.updates["my.tld"]; tt.wantUpdated && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("published %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordManager_keepAddressFamilies(t *testing.T) {
+	newRecords := func(v4Healthy, v6Healthy bool) []*ManagedDnsRecord {
+		state := func(healthy bool) status.State {
+			if healthy {
+				return &status.Healthy{}
+			}
+			return &status.Unhealthy{}
+		}
+		return []*ManagedDnsRecord{
+			{DnsRecord: DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, Hostname: "my.tld", status: state(v4Healthy)},
+			{DnsRecord: DnsRecord{Priority: 20, DnsType: "AAAA", Ip: net.ParseIP("2001:db8::1"), Ttl: 60}, Hostname: "my.tld", status: state(v6Healthy)},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		keep      bool
+		v4Healthy bool
+		v6Healthy bool
+		want      []string
+	}{
+		{
+			name:      "unhealthy AAAA records are removed while A records are healthy",
+			keep:      true,
+			v4Healthy: true,
+			want:      []string{"A 10.0.0.1"},
+		},
+		{
+			name:      "unhealthy A records are removed while AAAA records are healthy",
+			keep:      true,
+			v6Healthy: true,
+			want:      []string{"AAAA 2001:db8::1"},
+		},
+		{
+			name: "records are kept while no family is healthy",
+			keep: true,
+			want: []string{"A 10.0.0.1", "AAAA 2001:db8::1"},
+		},
+		{
+			name: "records are removed without the policy",
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &dummyDnsDb{}
+			records := map[string][]*ManagedDnsRecord{"my.tld": newRecords(true, true)}
+			m, err := NewRecordManager(db, &dummyService{}, records, WithHostnamePolicies(map[string]HostnamePolicy{
+				"my.tld": {KeepAddressFamilies: tt.keep, OnAllUnhealthy: AllUnhealthyRemove},
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m.applyRecords(context.Background())
+			m.managedRecords["my.tld"] = newRecords(tt.v4Healthy, tt.v6Healthy)
+			m.applyRecords(context.Background())
+
+			if got := db.updates["my.tld"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("published %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+// startGracePeriods starts the grace period of the addresses that took over from other addresses of the hostname and
+// forgets about the addresses that are not published anymore.
+func (h *RecordManager) startGracePeriods(hostname string, previous, published []string) {
+	gracePeriod := h.hostnamePolicies[hostname].GracePeriod
+	if gracePeriod <= 0 {
+		delete(h.graceUntil, hostname)
+		return
+	}
+
+	graceUntil := h.graceUntil[hostname]
+	maps.DeleteFunc(graceUntil, func(ip string, _ time.Time) bool {
+		return !slices.Contains(published, ip)
+	})
+	// records published at startup or after an outage did not take over from another record
+	if len(previous) == 0 {
+		return
+	}
+
+	for _, ip := range published {
+		if slices.Contains(previous, ip) {
+			continue
+		}
+		if graceUntil == nil {
+			graceUntil = map[string]time.Time{}
+			h.graceUntil[hostname] = graceUntil
+		}
+		graceUntil[ip] = h.clock.Now().Add(gracePeriod)
+	}
+}
+
+// withinGracePeriod returns true while a record of the hostname that recently took over fails its checks during its
+// grace period, the current selection is kept until the grace period ends.
+func (h *RecordManager) withinGracePeriod(hostname string, ips []*ManagedDnsRecord) bool {
+	now := h.clock.Now()
+	for _, ip := range ips {
+		until, found := h.graceUntil[hostname][ip.Ip.String()]
+		if !found || !now.Before(until) || ip.InMaintenance() {
+			continue
+		}
+
+		if status.Effective(ip.GetState()).Name() == status.UnhealthyStateName {
+			slog.Debug("Keeping record that fails during its grace period", "hostname", hostname, "ip", ip.Ip, "until", until)
+			return true
+		}
+	}
+	return false
+}
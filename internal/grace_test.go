@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+func TestRecordManager_gracePeriod(t *testing.T) {
+	primary := &ManagedDnsRecord{DnsRecord: DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, Hostname: "my.tld", status: &status.Healthy{}}
+	secondary := &ManagedDnsRecord{DnsRecord: DnsRecord{Priority: 10, DnsType: "A", Ip: net.ParseIP("10.0.0.2"), Ttl: 60}, Hostname: "my.tld", status: &status.Healthy{}}
+
+	db := &dummyDnsDb{}
+	m, err := NewRecordManager(db, &dummyService{}, map[string][]*ManagedDnsRecord{"my.tld": {primary, secondary}},
+		WithHostnamePolicies(map[string]HostnamePolicy{"my.tld": {GracePeriod: time.Minute}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.applyRecords(context.Background())
+	if len(m.graceUntil["my.tld"]) != 0 {
+		t.Errorf("expected no grace period for the initial selection, got %v", m.graceUntil)
+	}
+
+	// failover to the secondary record starts its grace period
+	primary.status = &status.Unhealthy{}
+	m.applyRecords(context.Background())
+	if want := []string{"A 10.0.0.2"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Fatalf("published %v, want %v", db.updates["my.tld"], want)
+	}
+
+	// failures of the secondary record during its grace period don't flip the records back
+	primary.status = &status.Healthy{}
+	secondary.status = &status.Unhealthy{}
+	m.applyRecords(context.Background())
+	if want := []string{"A 10.0.0.2"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Errorf("published %v during grace period, want %v", db.updates["my.tld"], want)
+	}
+
+	m.graceUntil["my.tld"]["10.0.0.2"] = time.Now().Add(-time.Second)
+	m.applyRecords(context.Background())
+	if want := []string{"A 10.0.0.1"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Errorf("published %v after grace period, want %v", db.updates["my.tld"], want)
+	}
+}
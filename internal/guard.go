@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+)
+
+const defaultGuardTimeout = 5 * time.Second
+
+// WithGuard checks the local connectivity before each cycle of healthchecks. While the guard fails, all records would
+// look down from this host, so healthchecks are suspended and the published records are frozen.
+func WithGuard(guard Healthcheck, timeout time.Duration) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		if guard == nil {
+			return errors.New("nil guard supplied")
+		}
+		if timeout < 0 {
+			return errors.New("guard timeout must not be negative")
+		}
+		m.guard = guard
+		m.guardTimeout = timeout
+		return nil
+	}
+}
+
+// guardEngaged returns true if the guard is configured and fails, in which case no decisions must be made.
+func (h *RecordManager) guardEngaged(ctx context.Context) bool {
+	if h.guard == nil {
+		return false
+	}
+
+	timeout := min(cmp.Or(h.guardTimeout, defaultGuardTimeout), h.checkInterval)
+	guardCtx, cancel := context.WithTimeout(ctx, timeout)
+	healthy, err := h.guard.IsHealthy(guardCtx)
+	cancel()
+
+	engaged := !healthy || err != nil
+	if engaged && !h.guardActive.Load() {
+		slog.Warn("Local connectivity check failed, suspending healthchecks and freezing records", "err", err)
+	} else if !engaged && h.guardActive.Load() {
+		slog.Info("Local connectivity check recovered, resuming healthchecks")
+	}
+	if err != nil {
+		metrics.GuardErrors.Inc()
+	}
+
+	h.guardActive.Store(engaged)
+	if engaged {
+		metrics.GuardEngaged.Set(1)
+	} else {
+		metrics.GuardEngaged.Set(0)
+	}
+	return engaged
+}
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+func TestRecordManager_guard(t *testing.T) {
+	statusConf := conf.StatusConfig{
+		HealthyStreak:          1,
+		UnhealthyStreak:        1,
+		InitialHealthyStreak:   1,
+		InitialUnhealthyStreak: 1,
+	}
+
+	checker := &dummyHealthcheck{ret: true}
+	record, err := NewManagedDnsRecord("my.tld", DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, statusConf, checker)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	guard := &dummyHealthcheck{ret: true}
+	db := &dummyDnsDb{}
+	m, err := NewRecordManager(db, &dummyService{}, map[string][]*ManagedDnsRecord{"my.tld": {record}}, WithGuard(guard, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.CheckRecords(context.Background())
+	if want := []string{"A 10.0.0.1"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Fatalf("expected record to be published, got %v", db.updates["my.tld"])
+	}
+
+	// every record looks down while the local connectivity is lost
+	guard.ret = false
+	checker.ret = false
+	m.CheckRecords(context.Background())
+	if record.GetState().Name() != status.HealthyStateName {
+		t.Fatalf("expected record to stay healthy while guard is engaged, got %s", record.GetState().Name())
+	}
+
+	guard.ret = true
+	m.CheckRecords(context.Background())
+	if record.GetState().Name() != status.UnhealthyStateName {
+		t.Fatalf("expected record to be checked after guard recovered, got %s", record.GetState().Name())
+	}
+}
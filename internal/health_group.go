@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+)
+
+// HealthGroup shares a single healthcheck between the records of several hostnames, e.g. a check of a site that all
+// hostnames served by the site depend on. The check runs at most once per interval, all records that are checked
+// within an interval receive the same result, so they fail over together.
+type HealthGroup struct {
+	name     string
+	check    Healthcheck
+	interval time.Duration
+
+	mutex   sync.Mutex
+	call    *checkCall
+	checked time.Time
+}
+
+func NewHealthGroup(name string, check Healthcheck, interval time.Duration) (*HealthGroup, error) {
+	if check == nil {
+		return nil, errors.New("empty healthcheck provided")
+	}
+	if interval <= 0 {
+		return nil, errors.New("interval must be positive")
+	}
+
+	return &HealthGroup{
+		name:     name,
+		check:    check,
+		interval: interval,
+	}, nil
+}
+
+// IsHealthy returns the result of the group's check, it only runs the check if the last result is older than the
+// interval. Concurrent callers wait for the running check.
+func (g *HealthGroup) IsHealthy(ctx context.Context) (bool, error) {
+	g.mutex.Lock()
+	call := g.call
+	owner := call == nil || time.Since(g.checked) >= g.interval
+	if owner {
+		call = &checkCall{done: make(chan struct{})}
+		g.call = call
+		g.checked = time.Now()
+	}
+	g.mutex.Unlock()
+
+	if !owner {
+		select {
+		case <-call.done:
+			return call.healthy, call.err
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+
+	metrics.HealthGroupChecks.WithLabelValues(g.name).Inc()
+	call.healthy, call.err = g.check.IsHealthy(ctx)
+	close(call.done)
+	return call.healthy, call.err
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type slowHealthcheck struct {
+	calls   atomic.Int32
+	healthy atomic.Bool
+	delay   time.Duration
+}
+
+func (c *slowHealthcheck) IsHealthy(_ context.Context) (bool, error) {
+	c.calls.Add(1)
+	time.Sleep(c.delay)
+	return c.healthy.Load(), nil
+}
+
+func TestHealthGroup_IsHealthy(t *testing.T) {
+	check := &slowHealthcheck{delay: 10 * time.Millisecond}
+	check.healthy.Store(true)
+	group, err := NewHealthGroup("site-a-up", check, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// concurrent callers share a single check
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if healthy, err := group.IsHealthy(context.Background()); !healthy || err != nil {
+				t.Errorf("IsHealthy() = %v, %v, want true", healthy, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := check.calls.Load(); got != 1 {
+		t.Errorf("expected a single check, got %d", got)
+	}
+
+	// the result is reused within the interval
+	check.healthy.Store(false)
+	if healthy, _ := group.IsHealthy(context.Background()); !healthy {
+		t.Errorf("expected result to be reused within the interval")
+	}
+
+	group.checked = time.Now().Add(-time.Hour)
+	if healthy, _ := group.IsHealthy(context.Background()); healthy {
+		t.Errorf("expected the group to be checked again after the interval")
+	}
+	if got := check.calls.Load(); got != 2 {
+		t.Errorf("expected two checks, got %d", got)
+	}
+}
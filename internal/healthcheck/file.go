@@ -0,0 +1,153 @@
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+const (
+	FileCheckerName = conf.FileCheckerName
+	// fileWatchRetry is the delay before watching the directory of the file again after the watch failed.
+	fileWatchRetry = 30 * time.Second
+)
+
+// FileChecker checks a file maintained by an external agent, e.g. a database promotion script touching /run/primary.
+// The directory of the file is watched, so the records are checked right away once the file changes instead of
+// waiting for the next check interval.
+type FileChecker struct {
+	path    string
+	absent  bool
+	content []byte
+	maxAge  time.Duration
+
+	mutex    sync.Mutex
+	watching context.Context
+	changes  chan struct{}
+}
+
+func NewFileChecker(args conf.FileHealthcheckConfig) (*FileChecker, error) {
+	if args.Path == "" {
+		return nil, errors.New("missing path in args")
+	}
+	if args.Absent && (args.Content != "" || args.MaxAge > 0) {
+		return nil, errors.New("content and max age can not be checked for absent files")
+	}
+
+	ret := &FileChecker{
+		path:    filepath.Clean(args.Path),
+		absent:  args.Absent,
+		maxAge:  args.MaxAge,
+		changes: make(chan struct{}, 1),
+	}
+	if args.Content != "" {
+		ret.content = bytes.TrimSpace([]byte(args.Content))
+	}
+	return ret, nil
+}
+
+func (c *FileChecker) IsHealthy(_ context.Context) (bool, error) {
+	info, err := os.Stat(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c.absent, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if c.absent {
+		return false, nil
+	}
+
+	if c.maxAge > 0 && time.Since(info.ModTime()) > c.maxAge {
+		return false, nil
+	}
+
+	if c.content != nil {
+		content, err := os.ReadFile(c.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return bytes.Equal(bytes.TrimSpace(content), c.content), nil
+	}
+
+	return true, nil
+}
+
+// Changes returns a channel that receives a value whenever the file is created, modified or removed. The file is only
+// watched once Changes has been called, the watch ends with the context and is started again by the next call.
+func (c *FileChecker) Changes(ctx context.Context) <-chan struct{} {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.watching == nil || c.watching.Err() != nil {
+		c.watching = ctx
+		go c.watch(ctx)
+	}
+	return c.changes
+}
+
+func (c *FileChecker) watch(ctx context.Context) {
+	for {
+		err := c.watchDir(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Warn("Could not watch file of healthcheck, retrying", "file", c.path, "err", err, "retry_in", fileWatchRetry)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(fileWatchRetry):
+		}
+	}
+}
+
+// watchDir watches the directory instead of the file, so creating and replacing the file is noticed.
+func (c *FileChecker) watchDir(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("could not create watcher: %w", err)
+	}
+	defer func() {
+		_ = watcher.Close()
+	}()
+
+	if err := watcher.Add(filepath.Dir(c.path)); err != nil {
+		return fmt.Errorf("could not watch %q: %w", filepath.Dir(c.path), err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return errors.New("watcher closed")
+			}
+			if filepath.Clean(event.Name) != c.path {
+				continue
+			}
+			select {
+			case c.changes <- struct{}{}:
+			default:
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return errors.New("watcher closed")
+			}
+			return err
+		}
+	}
+}
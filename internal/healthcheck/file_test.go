@@ -0,0 +1,113 @@
+package healthcheck
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestFileChecker_IsHealthy(t *testing.T) {
+	dir := t.TempDir()
+	primary := filepath.Join(dir, "primary")
+	if err := os.WriteFile(primary, []byte("db1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(dir, "stale")
+	if err := os.WriteFile(stale, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(stale, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	tests := []struct {
+		name string
+		args conf.FileHealthcheckConfig
+		want bool
+	}{
+		{
+			name: "exists",
+			args: conf.FileHealthcheckConfig{Path: primary},
+			want: true,
+		},
+		{
+			name: "missing",
+			args: conf.FileHealthcheckConfig{Path: missing},
+			want: false,
+		},
+		{
+			name: "absent",
+			args: conf.FileHealthcheckConfig{Path: missing, Absent: true},
+			want: true,
+		},
+		{
+			name: "not absent",
+			args: conf.FileHealthcheckConfig{Path: primary, Absent: true},
+			want: false,
+		},
+		{
+			name: "matching content",
+			args: conf.FileHealthcheckConfig{Path: primary, Content: "db1"},
+			want: true,
+		},
+		{
+			name: "different content",
+			args: conf.FileHealthcheckConfig{Path: primary, Content: "db2"},
+			want: false,
+		},
+		{
+			name: "fresh",
+			args: conf.FileHealthcheckConfig{Path: primary, MaxAge: time.Minute},
+			want: true,
+		},
+		{
+			name: "too old",
+			args: conf.FileHealthcheckConfig{Path: stale, MaxAge: time.Minute},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker, err := NewFileChecker(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := checker.IsHealthy(t.Context())
+			if err != nil {
+				t.Fatalf("IsHealthy() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileChecker_Changes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "primary")
+	checker, err := NewFileChecker(conf.FileHealthcheckConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := checker.Changes(t.Context())
+	// the watch is started asynchronously, keep touching the file until the change is noticed
+	deadline := time.After(5 * time.Second)
+	for {
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-changes:
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("expected change of the file to be signaled")
+		}
+	}
+}
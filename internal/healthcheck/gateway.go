@@ -0,0 +1,108 @@
+package healthcheck
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+const routeFile = "/proc/net/route"
+
+// GatewayChecker pings a reference IP or the default gateway to detect whether this host lost its connectivity.
+type GatewayChecker struct {
+	// host is the reference address, it may carry the zone of an IPv6 link-local address
+	host       string
+	privileged bool
+	routeFile  string
+}
+
+// NewGatewayChecker builds the guard. Without a reference ip the default gateway must be known at startup, otherwise
+// the guard would fail on every check and freeze all records forever, e.g. on hosts without an IPv4 default route or
+// without /proc/net/route.
+func NewGatewayChecker(args conf.GuardConfig) (*GatewayChecker, error) {
+	return newGatewayChecker(args, routeFile)
+}
+
+func newGatewayChecker(args conf.GuardConfig, routeFile string) (*GatewayChecker, error) {
+	ret := &GatewayChecker{
+		privileged: getPrivilegedDefaultForPlatform(),
+		routeFile:  routeFile,
+	}
+
+	if args.Ip != "" {
+		addr, err := netip.ParseAddr(args.Ip)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip %q", args.Ip)
+		}
+		ret.host = addr.String()
+	} else if _, err := defaultGateway(routeFile); err != nil {
+		return nil, fmt.Errorf("%w, the guard requires an ip", err)
+	}
+
+	if args.Privileged != nil {
+		ret.privileged = *args.Privileged
+	}
+
+	return ret, nil
+}
+
+func (c *GatewayChecker) IsHealthy(ctx context.Context) (bool, error) {
+	target := c.host
+	if target == "" {
+		// the default gateway is looked up for every check, as it may change, e.g. after a dhcp lease renewal
+		gateway, err := defaultGateway(c.routeFile)
+		if err != nil {
+			return false, err
+		}
+		target = gateway.String()
+	}
+
+	pinger := &IcmpChecker{host: target, privileged: c.privileged}
+	return pinger.IsHealthy(ctx)
+}
+
+// defaultGateway returns the IPv4 gateway of the default route from the given file in the format of /proc/net/route.
+func defaultGateway(file string) (net.IP, error) {
+	data, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not determine default gateway: %w", err)
+	}
+	defer func() {
+		_ = data.Close()
+	}()
+
+	scanner := bufio.NewScanner(data)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Iface Destination Gateway Flags ...
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+
+		raw, err := hex.DecodeString(fields[2])
+		if err != nil || len(raw) != net.IPv4len {
+			continue
+		}
+
+		// the kernel prints the address in host byte order
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(raw))
+		if !ip.IsUnspecified() {
+			return ip, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not determine default gateway: %w", err)
+	}
+
+	return nil, errors.New("no default gateway found")
+}
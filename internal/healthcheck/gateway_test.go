@@ -0,0 +1,76 @@
+package healthcheck
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestDefaultGateway(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "default route",
+			content: "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+				"eth0\t0002A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n" +
+				"eth0\t00000000\t0102A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n",
+			want: "192.168.2.1",
+		},
+		{
+			name: "no default route",
+			content: "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+				"eth0\t0002A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\t0\t0\t0\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "route")
+			if err := os.WriteFile(file, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := defaultGateway(file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("defaultGateway() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("defaultGateway() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewGatewayChecker(t *testing.T) {
+	routes := filepath.Join(t.TempDir(), "route")
+	content := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT\n" +
+		"eth0\t00000000\t0102A8C0\t0003\t0\t0\t100\t00000000\t0\t0\t0\n"
+	if err := os.WriteFile(routes, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	tests := []struct {
+		name      string
+		args      conf.GuardConfig
+		routeFile string
+		wantErr   bool
+	}{
+		{name: "default gateway", routeFile: routes},
+		{name: "unknown default gateway", routeFile: missing, wantErr: true},
+		{name: "reference ip", args: conf.GuardConfig{Ip: "192.168.2.1"}, routeFile: missing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newGatewayChecker(tt.args, tt.routeFile); (err != nil) != tt.wantErr {
+				t.Errorf("newGatewayChecker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
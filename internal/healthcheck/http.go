@@ -1,21 +1,36 @@
 package healthcheck
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"slices"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
 )
 
 const (
-	HttpCheckerName = "http"
-	defaultMethod   = http.MethodGet
+	HttpCheckerName     = conf.HttpCheckerName
+	defaultMethod       = http.MethodGet
+	defaultMaxRedirects = 10
+	// maxBodySize limits how much of the body is read to verify its content
+	maxBodySize = 10 << 20
+
+	VirtualHostsAll = "all"
+	VirtualHostsAny = "any"
 )
 
 var defaultStatusCodes = []int{200, 201, 301}
@@ -24,58 +39,117 @@ type Http struct {
 	endpoint          string
 	method            string
 	wantedStatusCodes []int
-	httpClient        *http.Client
+	// expectedHost is the host that needs to serve the final response, any host if empty
+	expectedHost string
+	// probes are the requests sent for each check, one per virtual host
+	probes []httpProbe
+	// anyVirtualHost considers the record healthy if any instead of all probes pass
+	anyVirtualHost bool
+	// bodySha256 is the hash the body needs to match, the body is not verified if empty
+	bodySha256       []byte
+	minContentLength int64
+	maxContentLength int64
 }
 
-func NewHttp(host string, record internal.DnsRecord, args map[string]any) (*Http, error) {
+type httpProbe struct {
+	// host is the Host header of the request, derived from the endpoint if empty
+	host       string
+	httpClient *http.Client
+}
+
+func NewHttp(host string, record internal.DnsRecord, args conf.HttpHealthcheckConfig, opts ...CheckerOpts) (*Http, error) {
 	if host == "" {
 		return nil, errors.New("empty endpoint supplied")
 	}
 
+	source, err := buildSource(record.Ip, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	var method = defaultMethod
 	var statusCodes = defaultStatusCodes
 
-	var httpClient *http.Client
-	var endpoint string
+	endpoint := url.URL{Scheme: "http", Host: record.Address()}
+	if args.UseTls {
+		endpoint.Scheme = "https"
+	}
+	if args.Port > 0 {
+		endpoint.Host = net.JoinHostPort(record.Address(), strconv.Itoa(args.Port))
+	} else if record.Ip.To4() == nil {
+		endpoint.Host = "[" + record.Address() + "]"
+	}
 
-	usesTls := false
-	usesTlsRaw, found := args["use_tls"]
-	if found {
-		var err error
-		usesTls, err = strconv.ParseBool(usesTlsRaw.(string))
-		if err != nil {
-			return nil, errors.New("could not parse use_tls as bool from map")
+	newClient := func(serverName string) *http.Client {
+		var httpClient *http.Client
+		if args.UseTls {
+			httpClient = newHTTPClientWithHost(serverName, source)
+		} else {
+			httpClient = newHTTPClient(source)
+		}
+
+		// without following redirects, the status of the redirect itself is validated
+		httpClient.CheckRedirect = func(_ *http.Request, _ []*http.Request) error {
+			return http.ErrUseLastResponse
 		}
+		if args.FollowRedirects {
+			maxRedirects := cmp.Or(args.MaxRedirects, defaultMaxRedirects)
+			httpClient.CheckRedirect = func(_ *http.Request, via []*http.Request) error {
+				if len(via) > maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return nil
+			}
+		}
+		return httpClient
 	}
-	if usesTls {
-		httpClient = newHTTPClientWithHost(host)
-		endpoint = "https://" + record.Ip.String()
-	} else {
-		httpClient = &http.Client{
-			Timeout: 5 * time.Second,
+
+	var bodySha256 []byte
+	if args.BodySha256 != "" {
+		bodySha256, err = hex.DecodeString(args.BodySha256)
+		if err != nil || len(bodySha256) != sha256.Size {
+			return nil, fmt.Errorf("invalid body hash %q", args.BodySha256)
 		}
-		endpoint = "http://" + record.Ip.String()
 	}
 
-	portRaw, found := args["port"]
-	if found {
-		port, err := strconv.Atoi(portRaw.(string))
-		if err != nil {
-			return nil, errors.New("could not parse port as integer")
+	probes := []httpProbe{{host: args.HostHeader, httpClient: newClient(host)}}
+	if len(args.VirtualHosts) > 0 {
+		probes = make([]httpProbe, 0, len(args.VirtualHosts))
+		for _, virtualHost := range args.VirtualHosts {
+			probes = append(probes, httpProbe{host: virtualHost, httpClient: newClient(virtualHost)})
 		}
-		endpoint += fmt.Sprintf(":%d", port)
 	}
 
 	return &Http{
-		endpoint:          endpoint,
+		endpoint:          endpoint.String(),
 		method:            method,
 		wantedStatusCodes: statusCodes,
-		httpClient:        httpClient,
+		expectedHost:      args.ExpectedHost,
+		probes:            probes,
+		anyVirtualHost:    args.VirtualHostsPolicy == VirtualHostsAny,
+		bodySha256:        bodySha256,
+		minContentLength:  args.MinContentLength,
+		maxContentLength:  args.MaxContentLength,
 	}, nil
 }
 
-func newHTTPClientWithHost(host string) *http.Client {
+func newHTTPClient(source source) *http.Client {
+	if !source.hasSource {
+		return &http.Client{}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = source.dialer().DialContext
+	return &http.Client{
+		Transport: transport,
+	}
+}
+
+func newHTTPClientWithHost(host string, source source) *http.Client {
 	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if source.hasSource {
+		transport.DialContext = source.dialer().DialContext
+	}
 	transport.TLSClientConfig = &tls.Config{
 		MinVersion: tls.VersionTLS13,
 		ServerName: host,
@@ -83,21 +157,97 @@ func newHTTPClientWithHost(host string) *http.Client {
 
 	return &http.Client{
 		Transport: transport,
-		Timeout:   5 * time.Second,
 	}
 }
 
 func (h *Http) IsHealthy(ctx context.Context) (bool, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	var errs []error
+	for _, probe := range h.probes {
+		healthy, err := h.probe(ctx, probe)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", cmp.Or(probe.host, h.endpoint), err)
+		}
+		switch {
+		case h.anyVirtualHost && healthy:
+			return true, nil
+		case h.anyVirtualHost:
+			errs = append(errs, err)
+		case !healthy:
+			return false, err
+		}
+	}
+
+	if h.anyVirtualHost {
+		return false, errors.Join(errs...)
+	}
+	return true, nil
+}
+
+func (h *Http) probe(ctx context.Context, probe httpProbe) (bool, error) {
 	req, err := http.NewRequestWithContext(ctx, h.method, h.endpoint, nil)
 	if err != nil {
-		return false, err
+		return false, fmt.Errorf("%w: %w", internal.ErrCheckMisconfigured, err)
 	}
+	req.Host = probe.host
 
-	resp, err := h.httpClient.Do(req)
+	resp, err := probe.httpClient.Do(req)
 	if err != nil {
 		return false, err
 	}
 
 	defer resp.Body.Close()
-	return slices.Contains(h.wantedStatusCodes, resp.StatusCode), nil
+	if h.expectedHost != "" && !strings.EqualFold(requestHost(resp.Request), h.expectedHost) {
+		slog.Debug("Final response served by unexpected host", "endpoint", h.endpoint, "host", probe.host, "url", resp.Request.URL.String())
+		return false, nil
+	}
+	if !slices.Contains(h.wantedStatusCodes, resp.StatusCode) {
+		slog.Debug("Unexpected status code", "endpoint", h.endpoint, "host", probe.host, "status", resp.StatusCode)
+		return false, nil
+	}
+	return h.verifyBody(resp.Body)
+}
+
+// verifyBody checks the size and hash of the body, a body that deviates means the node serves stale or wrong content.
+func (h *Http) verifyBody(body io.Reader) (bool, error) {
+	if h.bodySha256 == nil && h.minContentLength == 0 && h.maxContentLength == 0 {
+		return true, nil
+	}
+
+	limit := int64(maxBodySize)
+	if h.maxContentLength > 0 {
+		limit = h.maxContentLength
+	}
+	hash := sha256.New()
+	// one byte more than the limit is read to detect bodies exceeding it
+	size, err := io.Copy(hash, io.LimitReader(body, limit+1))
+	if err != nil {
+		return false, fmt.Errorf("could not read body: %w", err)
+	}
+
+	if size < h.minContentLength || size > limit {
+		slog.Debug("Unexpected body size", "endpoint", h.endpoint, "size", size)
+		return false, nil
+	}
+	if h.bodySha256 != nil && !bytes.Equal(hash.Sum(nil), h.bodySha256) {
+		slog.Debug("Unexpected body hash", "endpoint", h.endpoint, "sha256", hex.EncodeToString(hash.Sum(nil)))
+		return false, nil
+	}
+	return true, nil
+}
+
+// requestHost returns the host the request was sent to, preferring an overridden Host header over the address.
+func requestHost(req *http.Request) string {
+	if req.Host == "" {
+		return req.URL.Hostname()
+	}
+	if host, _, err := net.SplitHostPort(req.Host); err == nil {
+		return host
+	}
+	return req.Host
 }
@@ -0,0 +1,263 @@
+package healthcheck
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestHttp_IsHealthyRedirects(t *testing.T) {
+	// the error page is served by another host, localhost instead of 127.0.0.1
+	errorPage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer errorPage.Close()
+	errorPageUrl, _ := url.Parse(errorPage.URL)
+	errorPageUrl.Host = net.JoinHostPort("localhost", errorPageUrl.Port())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			http.Redirect(w, r, "/hop", http.StatusMovedPermanently)
+		case "/hop":
+			if r.URL.Query().Get("broken") != "" {
+				http.Redirect(w, r, errorPageUrl.String(), http.StatusFound)
+				return
+			}
+			http.Redirect(w, r, "/final", http.StatusFound)
+		case "/final":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	serverUrl, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverUrl.Port())
+
+	tests := []struct {
+		name    string
+		args    conf.HttpHealthcheckConfig
+		path    string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "redirect is not followed",
+			args: conf.HttpHealthcheckConfig{},
+			want: true,
+		},
+		{
+			name: "final status is validated",
+			args: conf.HttpHealthcheckConfig{FollowRedirects: true},
+			want: false,
+		},
+		{
+			name:    "too many redirects",
+			args:    conf.HttpHealthcheckConfig{FollowRedirects: true, MaxRedirects: 1},
+			wantErr: true,
+		},
+		{
+			name: "redirect to other host",
+			args: conf.HttpHealthcheckConfig{FollowRedirects: true, ExpectedHost: "127.0.0.1"},
+			path: "/hop?broken=1",
+			want: false,
+		},
+		{
+			name: "redirect to expected host",
+			args: conf.HttpHealthcheckConfig{FollowRedirects: true, ExpectedHost: "localhost"},
+			path: "/hop?broken=1",
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args.Port = port
+			checker, err := NewHttp("example.com", internal.DnsRecord{Ip: net.ParseIP("127.0.0.1")}, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checker.endpoint += tt.path
+
+			got, err := checker.IsHealthy(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsHealthy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHttp_IsHealthyVirtualHosts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "www.example.com" && r.Host != "api.example.com" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	serverUrl, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverUrl.Port())
+
+	tests := []struct {
+		name string
+		args conf.HttpHealthcheckConfig
+		want bool
+	}{
+		{
+			name: "host derived from address",
+			want: false,
+		},
+		{
+			name: "host header",
+			args: conf.HttpHealthcheckConfig{HostHeader: "www.example.com"},
+			want: true,
+		},
+		{
+			name: "all virtual hosts pass",
+			args: conf.HttpHealthcheckConfig{VirtualHosts: []string{"www.example.com", "api.example.com"}},
+			want: true,
+		},
+		{
+			name: "not all virtual hosts pass",
+			args: conf.HttpHealthcheckConfig{VirtualHosts: []string{"www.example.com", "unknown.example.com"}},
+			want: false,
+		},
+		{
+			name: "any virtual host passes",
+			args: conf.HttpHealthcheckConfig{VirtualHosts: []string{"unknown.example.com", "api.example.com"}, VirtualHostsPolicy: VirtualHostsAny},
+			want: true,
+		},
+		{
+			name: "no virtual host passes",
+			args: conf.HttpHealthcheckConfig{VirtualHosts: []string{"unknown.example.com"}, VirtualHostsPolicy: VirtualHostsAny},
+			want: false,
+		},
+		{
+			name: "expected host matches host header",
+			args: conf.HttpHealthcheckConfig{HostHeader: "www.example.com", ExpectedHost: "www.example.com"},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args.Port = port
+			checker, err := NewHttp("example.com", internal.DnsRecord{Ip: net.ParseIP("127.0.0.1")}, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := checker.IsHealthy(context.Background())
+			if err != nil {
+				t.Fatalf("IsHealthy() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHttp_IsHealthyBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok\n"))
+	}))
+	defer server.Close()
+	serverUrl, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(serverUrl.Port())
+
+	tests := []struct {
+		name string
+		args conf.HttpHealthcheckConfig
+		want bool
+	}{
+		{
+			name: "matching hash",
+			// sha256 of "ok\n"
+			args: conf.HttpHealthcheckConfig{BodySha256: "dc51b8c96c2d745df3bd5590d990230a482fd247123599548e0632fdbf97fc22"},
+			want: true,
+		},
+		{
+			name: "deviating hash",
+			args: conf.HttpHealthcheckConfig{BodySha256: "0000000000000000000000000000000000000000000000000000000000000000"},
+			want: false,
+		},
+		{
+			name: "content length in bounds",
+			args: conf.HttpHealthcheckConfig{MinContentLength: 1, MaxContentLength: 3},
+			want: true,
+		},
+		{
+			name: "body too large",
+			args: conf.HttpHealthcheckConfig{MaxContentLength: 2},
+			want: false,
+		},
+		{
+			name: "body too small",
+			args: conf.HttpHealthcheckConfig{MinContentLength: 4},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args.Port = port
+			checker, err := NewHttp("example.com", internal.DnsRecord{Ip: net.ParseIP("127.0.0.1")}, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := checker.IsHealthy(context.Background())
+			if err != nil {
+				t.Fatalf("IsHealthy() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHttp_endpoint(t *testing.T) {
+	tests := []struct {
+		name   string
+		record internal.DnsRecord
+		args   conf.HttpHealthcheckConfig
+		want   string
+	}{
+		{
+			name:   "ipv4",
+			record: internal.DnsRecord{Ip: net.ParseIP("192.0.2.1")},
+			args:   conf.HttpHealthcheckConfig{Port: 8080},
+			want:   "http://192.0.2.1:8080",
+		},
+		{
+			name:   "ipv6",
+			record: internal.DnsRecord{Ip: net.ParseIP("2001:db8::1")},
+			args:   conf.HttpHealthcheckConfig{UseTls: true},
+			want:   "https://[2001:db8::1]",
+		},
+		{
+			name:   "ipv6 link-local with zone",
+			record: internal.DnsRecord{Ip: net.ParseIP("fe80::1"), Zone: "eth0"},
+			args:   conf.HttpHealthcheckConfig{Port: 8080},
+			want:   "http://[fe80::1%25eth0]:8080",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker, err := NewHttp("example.com", tt.record, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if checker.endpoint != tt.want {
+				t.Errorf("NewHttp() endpoint = %v, want %v", checker.endpoint, tt.want)
+			}
+		})
+	}
+}
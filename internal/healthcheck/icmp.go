@@ -1,47 +1,49 @@
 package healthcheck
 
 import (
-	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
 
 	probing "github.com/prometheus-community/pro-bing"
 	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"golang.org/x/net/icmp"
 
 	"runtime"
 	"time"
 )
 
 const (
-	IcmpCheckerName    = "icmp"
+	IcmpCheckerName    = conf.IcmpCheckerName
 	icmpDefaultTimeout = 3 * time.Second
+	pingGroupRangeFile = "/proc/sys/net/ipv4/ping_group_range"
 )
 
 type IcmpChecker struct {
 	host       string
-	timeout    time.Duration
 	privileged bool
+	source     source
 }
 
-func NewIcmpChecker(record internal.DnsRecord, args map[string]any) (*IcmpChecker, error) {
-	ret := &IcmpChecker{
-		host:       record.Ip.String(),
-		timeout:    icmpDefaultTimeout,
-		privileged: getPrivilegedDefaultForPlatform(),
+func NewIcmpChecker(record internal.DnsRecord, args conf.IcmpHealthcheckConfig, opts ...CheckerOpts) (*IcmpChecker, error) {
+	source, err := buildSource(record.Ip, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	timeoutHuman, ok := args["timeout"].(string)
-	if ok {
-		timeout, err := time.ParseDuration(timeoutHuman)
-		if err != nil {
-			return nil, fmt.Errorf("timeout duration could not be parsed: %w", err)
-		}
-		ret.timeout = timeout
+	ret := &IcmpChecker{
+		host:       record.Address(),
+		privileged: getPrivilegedDefaultForPlatform(),
+		source:     source,
 	}
 
-	privileged, ok := args["privileged"].(bool)
-	if ok {
-		ret.privileged = privileged
+	if args.Privileged != nil {
+		ret.privileged = *args.Privileged
 	}
 
 	return ret, nil
@@ -50,7 +52,9 @@ func NewIcmpChecker(record internal.DnsRecord, args map[string]any) (*IcmpChecke
 func getPrivilegedDefaultForPlatform() bool {
 	switch runtime.GOOS {
 	case "linux":
-		return true
+		// unprivileged ICMP sockets do not need CAP_NET_RAW, they are available if the process' group is allowed to
+		// use them
+		return !unprivilegedPingAllowed(pingGroupRangeFile)
 	case "windows":
 		return true
 	}
@@ -58,20 +62,88 @@ func getPrivilegedDefaultForPlatform() bool {
 	return false
 }
 
+// unprivilegedPingAllowed checks whether the group or any of the supplementary groups of the process are within
+// net.ipv4.ping_group_range.
+func unprivilegedPingAllowed(rangeFile string) bool {
+	data, err := os.ReadFile(rangeFile)
+	if err != nil {
+		return false
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return false
+	}
+
+	lower, errLower := strconv.Atoi(fields[0])
+	upper, errUpper := strconv.Atoi(fields[1])
+	if errLower != nil || errUpper != nil {
+		return false
+	}
+
+	groups, _ := os.Getgroups()
+	for _, gid := range append(groups, os.Getgid()) {
+		if gid >= lower && gid <= upper {
+			return true
+		}
+	}
+	return false
+}
+
+// Preflight verifies that the process is allowed to open the ICMP socket used by the checker.
+func (c *IcmpChecker) Preflight() error {
+	network, address := "udp4", "0.0.0.0"
+	if c.privileged {
+		network = "ip4:icmp"
+	}
+	if ip := net.ParseIP(c.host); ip != nil && ip.To4() == nil {
+		network, address = "udp6", "::"
+		if c.privileged {
+			network = "ip6:ipv6-icmp"
+		}
+	}
+	if c.source.ip != nil {
+		address = c.source.ip.String()
+	}
+
+	conn, err := icmp.ListenPacket(network, address)
+	if err != nil {
+		return fmt.Errorf("could not open %s socket: %w", network, err)
+	}
+	return conn.Close()
+}
+
 func (c *IcmpChecker) IsHealthy(ctx context.Context) (bool, error) {
 	pinger, err := probing.NewPinger(c.host)
 	if err != nil {
-		return false, fmt.Errorf("could not create pinger: %w", err)
+		return false, fmt.Errorf("%w: could not create pinger: %w", internal.ErrCheckMisconfigured, err)
 	}
 
 	count := 1
-	pinger.Timeout = cmp.Or(c.timeout, defaultTimeout)
+	pinger.Timeout = icmpDefaultTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		pinger.Timeout = time.Until(deadline)
+	}
 	pinger.Count = count
 	pinger.SetPrivileged(c.privileged)
+	if c.source.ip != nil {
+		pinger.Source = c.source.ip.String()
+	}
+	pinger.InterfaceName = c.source.iface
 	if err := pinger.RunWithContext(ctx); err != nil {
+		// unprivileged pings need to be allowed by the kernel, privileged pings need CAP_NET_RAW
+		if errors.Is(err, os.ErrPermission) {
+			return false, fmt.Errorf("%w: ping not permitted: %w", internal.ErrCheckMisconfigured, err)
+		}
 		return false, fmt.Errorf("ping unsuccessful: %w", err)
 	}
 
 	stats := pinger.Statistics()
 	return stats.PacketsRecv == count, nil
 }
+
+// UnprivilegedPingPermitted verifies that the process is allowed to open unprivileged ICMP sockets, e.g. after it
+// dropped its privileges.
+func UnprivilegedPingPermitted() error {
+	return (&IcmpChecker{}).Preflight()
+}
@@ -0,0 +1,36 @@
+package healthcheck
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnprivilegedPingAllowed(t *testing.T) {
+	gid := os.Getgid()
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{name: "gid in range", content: fmt.Sprintf("%d %d\n", gid, gid+1), want: true},
+		{name: "disabled", content: "1\t0\n", want: false},
+		{name: "malformed", content: "everyone", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "ping_group_range")
+			if err := os.WriteFile(file, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if got := unprivilegedPingAllowed(file); got != tt.want {
+				t.Errorf("unprivilegedPingAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if unprivilegedPingAllowed(filepath.Join(t.TempDir(), "missing")) {
+		t.Error("unprivilegedPingAllowed() = true for missing file")
+	}
+}
@@ -0,0 +1,231 @@
+package healthcheck
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+const (
+	KerberosCheckerName      = conf.KerberosCheckerName
+	defaultKerberosPort      = 88
+	defaultKerberosPrincipal = "dns-ha"
+	kerberosMaxResponseSize  = 64 * 1024
+
+	krbAsReq    = 10
+	krbAsRep    = 11
+	krbError    = 30
+	krbNtPrinc  = 1
+	krbNtSrvIns = 2
+
+	// the errors of a KDC that is up, but does not serve the realm or is not able to answer
+	krbErrWrongRealm     = 68
+	krbErrSvcUnavailable = 29
+)
+
+type krbPrincipalName struct {
+	NameType   int      `asn1:"explicit,tag:0"`
+	NameString []string `asn1:"generalstring,explicit,tag:1"`
+}
+
+type krbReqBody struct {
+	KdcOptions asn1.BitString   `asn1:"explicit,tag:0"`
+	Cname      krbPrincipalName `asn1:"explicit,tag:1"`
+	Realm      string           `asn1:"generalstring,explicit,tag:2"`
+	Sname      krbPrincipalName `asn1:"explicit,tag:3"`
+	Till       time.Time        `asn1:"generalized,explicit,tag:5"`
+	Nonce      int              `asn1:"explicit,tag:7"`
+	Etype      []int            `asn1:"explicit,tag:8"`
+}
+
+type krbKdcReq struct {
+	Pvno    int        `asn1:"explicit,tag:1"`
+	MsgType int        `asn1:"explicit,tag:2"`
+	ReqBody krbReqBody `asn1:"explicit,tag:4"`
+}
+
+// krbErrorMsg holds the fields of a KRB-ERROR up to the error code, the remaining fields are ignored.
+type krbErrorMsg struct {
+	Pvno      int       `asn1:"explicit,tag:0"`
+	MsgType   int       `asn1:"explicit,tag:1"`
+	Ctime     time.Time `asn1:"generalized,optional,explicit,tag:2"`
+	Cusec     int       `asn1:"optional,explicit,tag:3"`
+	Stime     time.Time `asn1:"generalized,explicit,tag:4"`
+	Susec     int       `asn1:"explicit,tag:5"`
+	ErrorCode int       `asn1:"explicit,tag:6"`
+}
+
+// KerberosChecker sends an AS-REQ without pre-authentication to a KDC, e.g. of a domain controller. Any answer for
+// the realm proves that the KDC is up, usually the KDC demands pre-authentication or does not know the principal.
+type KerberosChecker struct {
+	host      string
+	port      string
+	source    source
+	transport string
+	realm     string
+	principal string
+}
+
+func NewKerberosChecker(record internal.DnsRecord, args conf.KerberosHealthcheckConfig, opts ...CheckerOpts) (*KerberosChecker, error) {
+	if args.Realm == "" {
+		return nil, errors.New("missing realm in args")
+	}
+
+	source, err := buildSource(record.Ip, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &KerberosChecker{
+		host:      record.Address(),
+		port:      strconv.Itoa(defaultKerberosPort),
+		source:    source,
+		transport: "tcp",
+		realm:     args.Realm,
+		principal: defaultKerberosPrincipal,
+	}
+	if args.Port > 0 {
+		ret.port = strconv.Itoa(args.Port)
+	}
+	if args.Principal != "" {
+		ret.principal = args.Principal
+	}
+	switch args.Transport {
+	case "", "tcp":
+	case "udp":
+		ret.transport = "udp"
+	default:
+		return nil, fmt.Errorf("unknown transport %q", args.Transport)
+	}
+	return ret, nil
+}
+
+func (c *KerberosChecker) IsHealthy(ctx context.Context) (bool, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	request, err := c.asReq()
+	if err != nil {
+		return false, err
+	}
+
+	conn, err := c.source.dial(ctx, c.transport, net.JoinHostPort(c.host, c.port))
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	response, err := c.exchange(conn, request)
+	if err != nil {
+		return false, err
+	}
+	return parseKdcResponse(response)
+}
+
+// exchange sends the request and returns the response, messages sent via tcp are prefixed with their length.
+func (c *KerberosChecker) exchange(conn net.Conn, request []byte) ([]byte, error) {
+	if c.transport == "udp" {
+		if _, err := conn.Write(request); err != nil {
+			return nil, err
+		}
+		response := make([]byte, kerberosMaxResponseSize)
+		n, err := conn.Read(response)
+		if err != nil {
+			return nil, err
+		}
+		return response[:n], nil
+	}
+
+	if _, err := conn.Write(binary.BigEndian.AppendUint32(nil, uint32(len(request)))); err != nil { //nolint G115
+		return nil, err
+	}
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	var length uint32
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	if length > kerberosMaxResponseSize {
+		return nil, fmt.Errorf("response of %d bytes exceeds the maximum size", length)
+	}
+	response := make([]byte, length)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *KerberosChecker) asReq() ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, big.NewInt(1<<31-1))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := asn1.Marshal(krbKdcReq{
+		Pvno:    5,
+		MsgType: krbAsReq,
+		ReqBody: krbReqBody{
+			KdcOptions: asn1.BitString{Bytes: make([]byte, 4), BitLength: 32},
+			Cname:      krbPrincipalName{NameType: krbNtPrinc, NameString: []string{c.principal}},
+			Realm:      c.realm,
+			Sname:      krbPrincipalName{NameType: krbNtSrvIns, NameString: []string{"krbtgt", c.realm}},
+			Till:       time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+			Nonce:      int(nonce.Int64()),
+			// aes256-cts-hmac-sha1-96 and aes128-cts-hmac-sha1-96
+			Etype: []int{18, 17},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassApplication, Tag: krbAsReq, IsCompound: true, Bytes: body})
+}
+
+// parseKdcResponse evaluates the AS-REP or KRB-ERROR sent by the KDC.
+func parseKdcResponse(response []byte) (bool, error) {
+	var outer asn1.RawValue
+	if _, err := asn1.Unmarshal(response, &outer); err != nil {
+		return false, fmt.Errorf("malformed response: %w", err)
+	}
+	if outer.Class != asn1.ClassApplication {
+		return false, errors.New("malformed response")
+	}
+
+	switch outer.Tag {
+	case krbAsRep:
+		return true, nil
+	case krbError:
+		var msg krbErrorMsg
+		if _, err := asn1.Unmarshal(outer.Bytes, &msg); err != nil {
+			return false, fmt.Errorf("malformed error: %w", err)
+		}
+		switch msg.ErrorCode {
+		case krbErrWrongRealm:
+			return false, fmt.Errorf("%w: kdc does not serve the realm", internal.ErrCheckMisconfigured)
+		case krbErrSvcUnavailable:
+			return false, nil
+		}
+		return true, nil
+	default:
+		return false, fmt.Errorf("unexpected response with tag %d", outer.Tag)
+	}
+}
@@ -0,0 +1,115 @@
+package healthcheck
+
+import (
+	"encoding/asn1"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func krbErrorResponse(t *testing.T, code int) []byte {
+	t.Helper()
+	type krbErrorFull struct {
+		Pvno      int              `asn1:"explicit,tag:0"`
+		MsgType   int              `asn1:"explicit,tag:1"`
+		Stime     time.Time        `asn1:"generalized,explicit,tag:4"`
+		Susec     int              `asn1:"explicit,tag:5"`
+		ErrorCode int              `asn1:"explicit,tag:6"`
+		Realm     string           `asn1:"generalstring,explicit,tag:9"`
+		Sname     krbPrincipalName `asn1:"explicit,tag:10"`
+	}
+
+	body, err := asn1.Marshal(krbErrorFull{
+		Pvno:      5,
+		MsgType:   krbError,
+		Stime:     time.Now().UTC().Truncate(time.Second),
+		ErrorCode: code,
+		Realm:     "EXAMPLE.COM",
+		Sname:     krbPrincipalName{NameType: krbNtSrvIns, NameString: []string{"krbtgt", "EXAMPLE.COM"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassApplication, Tag: krbError, IsCompound: true, Bytes: body})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestKerberosChecker_asReq(t *testing.T) {
+	checker, err := NewKerberosChecker(internal.DnsRecord{Ip: net.ParseIP("10.0.0.1")}, conf.KerberosHealthcheckConfig{Realm: "EXAMPLE.COM"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, err := checker.asReq()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var outer asn1.RawValue
+	if _, err := asn1.Unmarshal(request, &outer); err != nil {
+		t.Fatal(err)
+	}
+	if outer.Class != asn1.ClassApplication || outer.Tag != krbAsReq {
+		t.Fatalf("expected AS-REQ, got class %d tag %d", outer.Class, outer.Tag)
+	}
+
+	var req krbKdcReq
+	if _, err := asn1.Unmarshal(outer.Bytes, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.ReqBody.Realm != "EXAMPLE.COM" || req.ReqBody.Cname.NameString[0] != defaultKerberosPrincipal {
+		t.Errorf("unexpected request body %+v", req.ReqBody)
+	}
+}
+
+func TestParseKdcResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response []byte
+		want     bool
+		wantErr  error
+	}{
+		{
+			name:     "pre-authentication required",
+			response: krbErrorResponse(t, 25),
+			want:     true,
+		},
+		{
+			name:     "unknown principal",
+			response: krbErrorResponse(t, 6),
+			want:     true,
+		},
+		{
+			name:     "service unavailable",
+			response: krbErrorResponse(t, krbErrSvcUnavailable),
+		},
+		{
+			name:     "wrong realm",
+			response: krbErrorResponse(t, krbErrWrongRealm),
+			wantErr:  internal.ErrCheckMisconfigured,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseKdcResponse(tt.response)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseKdcResponse() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseKdcResponse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseKdcResponse([]byte("garbage")); err == nil {
+		t.Error("expected error for malformed response")
+	}
+}
@@ -0,0 +1,294 @@
+package healthcheck
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+const (
+	LdapCheckerName   = conf.LdapCheckerName
+	defaultLdapPort   = 389
+	defaultLdapsPort  = 636
+	ldapMaxPacketSize = 1024 * 1024
+
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berEnumerated  = 0x0a
+	berSequence    = 0x30
+
+	ldapBindRequest       = 0x60
+	ldapBindResponse      = 0x61
+	ldapUnbindRequest     = 0x42
+	ldapSearchRequest     = 0x63
+	ldapSearchResultEntry = 0x64
+	ldapSearchResultDone  = 0x65
+	ldapSearchResultRef   = 0x73
+	ldapSimpleAuth        = 0x80
+	ldapPresentFilter     = 0x87
+)
+
+// ldapMisconfigured are the result codes that point to a misconfigured check instead of an unhealthy server.
+var ldapMisconfigured = map[int]string{
+	32: "no such object",
+	49: "invalid credentials",
+	50: "insufficient access rights",
+}
+
+// LdapChecker binds to a directory server and runs a base search, so the backend database of the server needs to
+// answer, e.g. of a domain controller.
+type LdapChecker struct {
+	host      string
+	port      string
+	source    source
+	tlsConfig *tls.Config
+
+	bindDn       string
+	bindPassword string
+	baseDn       string
+}
+
+func NewLdapChecker(host string, record internal.DnsRecord, args conf.LdapHealthcheckConfig, opts ...CheckerOpts) (*LdapChecker, error) {
+	if args.BindDn != "" && args.BindPassword == "" {
+		// an empty password results in an unauthenticated bind that servers accept without checking the dn
+		return nil, errors.New("bind dn requires a password")
+	}
+
+	source, err := buildSource(record.Ip, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &LdapChecker{
+		host:         record.Address(),
+		port:         strconv.Itoa(defaultLdapPort),
+		source:       source,
+		bindDn:       args.BindDn,
+		bindPassword: args.BindPassword,
+		baseDn:       args.BaseDn,
+	}
+	if args.UseTls {
+		ret.port = strconv.Itoa(defaultLdapsPort)
+		ret.tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+	if args.Port > 0 {
+		ret.port = strconv.Itoa(args.Port)
+	}
+	return ret, nil
+}
+
+func (c *LdapChecker) IsHealthy(ctx context.Context) (bool, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	dialer := c.source.dialer()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(c.host, c.port))
+	if err != nil {
+		return false, err
+	}
+	if c.tlsConfig != nil {
+		conn = tls.Client(conn, c.tlsConfig)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	reader := bufio.NewReader(conn)
+	defer func() {
+		_, _ = conn.Write(ldapMessage(3, berTlv(ldapUnbindRequest, nil)))
+	}()
+
+	// bind request: version 3, the dn and the password as simple authentication
+	bind := berTlv(ldapBindRequest, concat(
+		berInt(berInteger, 3),
+		berTlv(berOctetString, []byte(c.bindDn)),
+		berTlv(ldapSimpleAuth, []byte(c.bindPassword)),
+	))
+	if _, err := conn.Write(ldapMessage(1, bind)); err != nil {
+		return false, err
+	}
+	if healthy, err := c.awaitResult(reader, 1, ldapBindResponse); !healthy || err != nil {
+		return healthy, err
+	}
+
+	// base search for any object without returning attributes: base dn, scope base, never dereference aliases, size
+	// limit 1, no time limit, types only false, filter (objectClass=*) and the attribute list "1.1"
+	search := berTlv(ldapSearchRequest, concat(
+		berTlv(berOctetString, []byte(c.baseDn)),
+		berInt(berEnumerated, 0),
+		berInt(berEnumerated, 0),
+		berInt(berInteger, 1),
+		berInt(berInteger, 0),
+		[]byte{0x01, 0x01, 0x00},
+		berTlv(ldapPresentFilter, []byte("objectClass")),
+		berTlv(berSequence, berTlv(berOctetString, []byte("1.1"))),
+	))
+	if _, err := conn.Write(ldapMessage(2, search)); err != nil {
+		return false, err
+	}
+	return c.awaitResult(reader, 2, ldapSearchResultDone)
+}
+
+// awaitResult reads messages until the result of the given operation arrives and evaluates its result code.
+func (c *LdapChecker) awaitResult(reader *bufio.Reader, messageId int, operation byte) (bool, error) {
+	for {
+		tag, message, err := readBer(reader)
+		if err != nil {
+			return false, fmt.Errorf("could not read response: %w", err)
+		}
+		if tag != berSequence {
+			return false, errors.New("malformed response")
+		}
+
+		idTag, id, rest, err := parseBer(message)
+		if err != nil || idTag != berInteger {
+			return false, errors.New("malformed response")
+		}
+		opTag, op, _, err := parseBer(rest)
+		if err != nil {
+			return false, errors.New("malformed response")
+		}
+		if berValue(id) != messageId || opTag == ldapSearchResultEntry || opTag == ldapSearchResultRef {
+			continue
+		}
+		if opTag != operation {
+			return false, fmt.Errorf("unexpected response %#x", opTag)
+		}
+
+		codeTag, code, _, err := parseBer(op)
+		if err != nil || codeTag != berEnumerated {
+			return false, errors.New("malformed result")
+		}
+		return ldapResult(berValue(code))
+	}
+}
+
+func ldapResult(code int) (bool, error) {
+	switch code {
+	case 0:
+		return true, nil
+	case 51, 52, 53:
+		// busy, unavailable or unwilling to perform
+		return false, nil
+	}
+	if reason, ok := ldapMisconfigured[code]; ok {
+		return false, fmt.Errorf("%w: server responded with %s", internal.ErrCheckMisconfigured, reason)
+	}
+	return false, fmt.Errorf("server responded with result code %d", code)
+}
+
+func ldapMessage(messageId int, op []byte) []byte {
+	return berTlv(berSequence, concat(berInt(berInteger, messageId), op))
+}
+
+func berTlv(tag byte, content []byte) []byte {
+	ret := []byte{tag}
+	switch length := len(content); {
+	case length < 0x80:
+		ret = append(ret, byte(length))
+	case length <= 0xff:
+		ret = append(ret, 0x81, byte(length))
+	default:
+		ret = append(ret, 0x82, byte(length>>8), byte(length)) //nolint G115
+	}
+	return append(ret, content...)
+}
+
+func berInt(tag byte, value int) []byte {
+	// the values are small positive numbers, they fit into a single byte
+	return berTlv(tag, []byte{byte(value)}) //nolint G115
+}
+
+// berValue decodes a non-negative integer or enumerated value.
+func berValue(content []byte) int {
+	var ret int
+	for _, b := range content {
+		ret = ret<<8 | int(b)
+	}
+	return ret
+}
+
+func concat(parts ...[]byte) []byte {
+	var ret []byte
+	for _, part := range parts {
+		ret = append(ret, part...)
+	}
+	return ret
+}
+
+// readBer reads a single element from the reader, servers are free to use long form lengths of any size.
+func readBer(reader *bufio.Reader) (byte, []byte, error) {
+	tag, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, err := berLength(reader.ReadByte)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(reader, content); err != nil {
+		return 0, nil, err
+	}
+	return tag, content, nil
+}
+
+// parseBer splits the first element off the data.
+func parseBer(data []byte) (byte, []byte, []byte, error) {
+	reader := bytes.NewReader(data)
+	tag, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	length, err := berLength(reader.ReadByte)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	start := len(data) - reader.Len()
+	if length > reader.Len() {
+		return 0, nil, nil, io.ErrUnexpectedEOF
+	}
+	return tag, data[start : start+length], data[start+length:], nil
+}
+
+func berLength(next func() (byte, error)) (int, error) {
+	first, err := next()
+	if err != nil {
+		return 0, err
+	}
+	if first&0x80 == 0 {
+		return int(first), nil
+	}
+
+	octets := int(first & 0x7f)
+	if octets == 0 || octets > 4 {
+		return 0, errors.New("unsupported length")
+	}
+	var length int
+	for range octets {
+		b, err := next()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	if length > ldapMaxPacketSize {
+		return 0, fmt.Errorf("message of %d bytes exceeds the maximum size", length)
+	}
+	return length, nil
+}
@@ -0,0 +1,109 @@
+package healthcheck
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+// serveLdap answers binds with the bind result code and searches with an entry and the search result code. The
+// responses use long form lengths like Active Directory does.
+func serveLdap(t *testing.T, bindCode, searchCode int) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	longForm := func(tag byte, content []byte) []byte {
+		return append([]byte{tag, 0x84, 0, 0, byte(len(content) >> 8), byte(len(content))}, content...)
+	}
+	result := func(id int, op byte, code int) []byte {
+		return longForm(berSequence, concat(berInt(berInteger, id), longForm(op, concat(berInt(berEnumerated, code), berTlv(berOctetString, nil), berTlv(berOctetString, nil)))))
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					_, message, err := readBer(reader)
+					if err != nil {
+						return
+					}
+					_, id, rest, _ := parseBer(message)
+					opTag, _, _, _ := parseBer(rest)
+					switch opTag {
+					case ldapBindRequest:
+						_, _ = conn.Write(result(berValue(id), ldapBindResponse, bindCode))
+					case ldapSearchRequest:
+						entry := longForm(berSequence, concat(berInt(berInteger, berValue(id)), berTlv(ldapSearchResultEntry, concat(berTlv(berOctetString, nil), berTlv(berSequence, nil)))))
+						_, _ = conn.Write(append(entry, result(berValue(id), ldapSearchResultDone, searchCode)...))
+					default:
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestLdapChecker_IsHealthy(t *testing.T) {
+	record := internal.DnsRecord{Ip: net.ParseIP("127.0.0.1")}
+
+	tests := []struct {
+		name       string
+		bindCode   int
+		searchCode int
+		args       conf.LdapHealthcheckConfig
+		want       bool
+		wantErr    error
+	}{
+		{
+			name: "anonymous",
+			want: true,
+		},
+		{
+			name:     "invalid credentials",
+			bindCode: 49,
+			args:     conf.LdapHealthcheckConfig{BindDn: "cn=dns-ha,dc=example,dc=com", BindPassword: "wrong"},
+			wantErr:  internal.ErrCheckMisconfigured,
+		},
+		{
+			name:       "unavailable",
+			searchCode: 52,
+			args:       conf.LdapHealthcheckConfig{BaseDn: "dc=example,dc=com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			args.Port = serveLdap(t, tt.bindCode, tt.searchCode)
+			checker, err := NewLdapChecker("dc.example.com", record, args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := checker.IsHealthy(t.Context())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("IsHealthy() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
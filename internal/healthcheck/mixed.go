@@ -0,0 +1,135 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+)
+
+const (
+	PrecedencePassive = "passive"
+	PrecedenceActive  = "active"
+)
+
+// MixedChecker combines the probes of an active checker with the evidence of a passive checker, e.g. a monitoring
+// system that already observed the failure of a service. Both checkers run concurrently.
+type MixedChecker struct {
+	active         internal.Healthcheck
+	passive        internal.Healthcheck
+	passiveTimeout time.Duration
+	activeFirst    bool
+}
+
+// NewMixedChecker combines the checkers according to the precedence, the timeout bounds the passive checker.
+func NewMixedChecker(active, passive internal.Healthcheck, precedence string, passiveTimeout time.Duration) (*MixedChecker, error) {
+	if active == nil || passive == nil {
+		return nil, errors.New("empty healthcheck provided")
+	}
+
+	ret := &MixedChecker{
+		active:         active,
+		passive:        passive,
+		passiveTimeout: passiveTimeout,
+	}
+	switch precedence {
+	case "", PrecedencePassive:
+	case PrecedenceActive:
+		ret.activeFirst = true
+	default:
+		return nil, fmt.Errorf("unknown precedence %q", precedence)
+	}
+	return ret, nil
+}
+
+// IsHealthy returns unhealthy as soon as the passive checker reports a failure, unless the active checker takes
+// precedence. Then the passive checker only decides if the active checker could not produce a verdict.
+func (c *MixedChecker) IsHealthy(ctx context.Context) (bool, error) {
+	// stops the remaining checker once the verdict is known
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	passive := make(chan sample, 1)
+	go func() {
+		passiveCtx := ctx
+		if c.passiveTimeout > 0 {
+			var cancel context.CancelFunc
+			passiveCtx, cancel = context.WithTimeout(ctx, c.passiveTimeout)
+			defer cancel()
+		}
+		healthy, err := c.passive.IsHealthy(passiveCtx)
+		passive <- sample{healthy: healthy, err: err}
+	}()
+
+	if c.activeFirst {
+		healthy, err := c.active.IsHealthy(ctx)
+		if err == nil {
+			return healthy, nil
+		}
+		if evidence := <-passive; evidence.err == nil {
+			return evidence.healthy, nil
+		}
+		return false, err
+	}
+
+	active := make(chan sample, 1)
+	go func() {
+		healthy, err := c.active.IsHealthy(ctx)
+		active <- sample{healthy: healthy, err: err}
+	}()
+
+	evidence := <-passive
+	if evidence.err == nil && !evidence.healthy {
+		return false, nil
+	}
+	if evidence.err != nil {
+		slog.Warn("Passive healthcheck failed, relying on active healthcheck", "err", evidence.err)
+	}
+	probe := <-active
+	return probe.healthy, probe.err
+}
+
+// Close closes both checkers if they need to be closed.
+func (c *MixedChecker) Close() error {
+	var errs []error
+	for _, check := range []internal.Healthcheck{c.active, c.passive} {
+		if closer, ok := check.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Changes forwards the changes announced by either checker, the channel is nil if neither announces changes.
+func (c *MixedChecker) Changes(ctx context.Context) <-chan struct{} {
+	var merged chan struct{}
+	for _, check := range []internal.Healthcheck{c.active, c.passive} {
+		notifier, ok := check.(internal.ChangeNotifier)
+		if !ok {
+			continue
+		}
+		if merged == nil {
+			merged = make(chan struct{}, 1)
+		}
+
+		changes := notifier.Changes(ctx)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-changes:
+				}
+				select {
+				case merged <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+	return merged
+}
@@ -0,0 +1,108 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingHealthcheck blocks until its context is done, like a probe that runs into its timeout.
+type blockingHealthcheck struct{}
+
+func (c *blockingHealthcheck) IsHealthy(ctx context.Context) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func TestMixedChecker_IsHealthy(t *testing.T) {
+	errProbe := errors.New("probe failed")
+
+	tests := []struct {
+		name       string
+		precedence string
+		active     *constantHealthcheck
+		passive    *constantHealthcheck
+		want       bool
+		wantErr    bool
+	}{
+		{
+			name:    "both healthy",
+			active:  &constantHealthcheck{healthy: true},
+			passive: &constantHealthcheck{healthy: true},
+			want:    true,
+		},
+		{
+			name:    "passive failure overrides healthy probe",
+			active:  &constantHealthcheck{healthy: true},
+			passive: &constantHealthcheck{},
+		},
+		{
+			name:    "passive error is ignored",
+			active:  &constantHealthcheck{healthy: true},
+			passive: &constantHealthcheck{err: errProbe},
+			want:    true,
+		},
+		{
+			name:    "active failure",
+			active:  &constantHealthcheck{},
+			passive: &constantHealthcheck{healthy: true},
+		},
+		{
+			name:       "active precedence ignores passive failure",
+			precedence: PrecedenceActive,
+			active:     &constantHealthcheck{healthy: true},
+			passive:    &constantHealthcheck{},
+			want:       true,
+		},
+		{
+			name:       "active precedence falls back to passive",
+			precedence: PrecedenceActive,
+			active:     &constantHealthcheck{err: errProbe},
+			passive:    &constantHealthcheck{healthy: true},
+			want:       true,
+		},
+		{
+			name:       "active precedence with both failing",
+			precedence: PrecedenceActive,
+			active:     &constantHealthcheck{err: errProbe},
+			passive:    &constantHealthcheck{err: errProbe},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker, err := NewMixedChecker(tt.active, tt.passive, tt.precedence, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := checker.IsHealthy(t.Context())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsHealthy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMixedChecker_shortCircuits(t *testing.T) {
+	checker, err := NewMixedChecker(&blockingHealthcheck{}, &constantHealthcheck{}, PrecedencePassive, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Minute)
+	defer cancel()
+	start := time.Now()
+	healthy, err := checker.IsHealthy(ctx)
+	if healthy || err != nil {
+		t.Fatalf("IsHealthy() = %v, %v, want unhealthy without error", healthy, err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Error("expected the passive failure to short-circuit the active probe")
+	}
+}
@@ -0,0 +1,157 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+const (
+	ModbusCheckerName = conf.ModbusCheckerName
+	defaultModbusPort = 502
+
+	modbusReadHoldingRegisters = 0x03
+	modbusReadInputRegisters   = 0x04
+	modbusExceptionFlag        = 0x80
+	// the exceptions that are caused by requesting a function or register the device does not provide
+	modbusIllegalFunction    = 0x01
+	modbusIllegalDataAddress = 0x02
+)
+
+// ModbusChecker reads a single register of a Modbus TCP device, e.g. an industrial gateway.
+type ModbusChecker struct {
+	host     string
+	port     string
+	source   source
+	unitId   byte
+	function byte
+	register uint16
+
+	expected *int
+	min      *int
+	max      *int
+
+	transaction atomic.Uint32
+}
+
+func NewModbusChecker(record internal.DnsRecord, args conf.ModbusHealthcheckConfig, opts ...CheckerOpts) (*ModbusChecker, error) {
+	if args.UnitId < 0 || args.UnitId > 255 {
+		return nil, fmt.Errorf("invalid unit id %d", args.UnitId)
+	}
+	if args.Register < 0 || args.Register > 65535 {
+		return nil, fmt.Errorf("invalid register %d", args.Register)
+	}
+
+	function := byte(modbusReadHoldingRegisters)
+	switch args.RegisterType {
+	case "", "holding":
+	case "input":
+		function = modbusReadInputRegisters
+	default:
+		return nil, fmt.Errorf("unknown register type %q", args.RegisterType)
+	}
+
+	source, err := buildSource(record.Ip, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	port := defaultModbusPort
+	if args.Port > 0 {
+		port = args.Port
+	}
+
+	return &ModbusChecker{
+		host:     record.Address(),
+		port:     strconv.Itoa(port),
+		source:   source,
+		unitId:   byte(args.UnitId),
+		function: function,
+		register: uint16(args.Register), //nolint G115
+		expected: args.Expected,
+		min:      args.Min,
+		max:      args.Max,
+	}, nil
+}
+
+func (c *ModbusChecker) IsHealthy(ctx context.Context) (bool, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	dialer := c.source.dialer()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(c.host, c.port))
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	value, err := c.readRegister(conn)
+	if err != nil {
+		return false, err
+	}
+
+	healthy := (c.expected == nil || int(value) == *c.expected) &&
+		(c.min == nil || int(value) >= *c.min) &&
+		(c.max == nil || int(value) <= *c.max)
+	return healthy, nil
+}
+
+// readRegister sends a request for a single register and returns its value.
+func (c *ModbusChecker) readRegister(conn io.ReadWriter) (uint16, error) {
+	transaction := uint16(c.transaction.Add(1)) //nolint G115
+
+	// MBAP header: transaction id, protocol id 0, length of the remaining bytes and unit id, followed by the PDU
+	request := make([]byte, 12)
+	binary.BigEndian.PutUint16(request[0:], transaction)
+	binary.BigEndian.PutUint16(request[4:], 6)
+	request[6] = c.unitId
+	request[7] = c.function
+	binary.BigEndian.PutUint16(request[8:], c.register)
+	binary.BigEndian.PutUint16(request[10:], 1)
+	if _, err := conn.Write(request); err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return 0, fmt.Errorf("could not read response: %w", err)
+	}
+	if binary.BigEndian.Uint16(header[0:]) != transaction || binary.BigEndian.Uint16(header[2:]) != 0 {
+		return 0, errors.New("response does not match the request")
+	}
+	length := binary.BigEndian.Uint16(header[4:])
+	if length < 3 || length > 256 {
+		return 0, fmt.Errorf("invalid response length %d", length)
+	}
+
+	pdu := make([]byte, length-1)
+	if _, err := io.ReadFull(conn, pdu); err != nil {
+		return 0, fmt.Errorf("could not read response: %w", err)
+	}
+
+	if pdu[0] == c.function|modbusExceptionFlag {
+		err := fmt.Errorf("device responded with exception %d", pdu[1])
+		if pdu[1] == modbusIllegalFunction || pdu[1] == modbusIllegalDataAddress {
+			return 0, fmt.Errorf("%w: %w", internal.ErrCheckMisconfigured, err)
+		}
+		return 0, err
+	}
+	if pdu[0] != c.function || len(pdu) != 4 || pdu[1] != 2 {
+		return 0, errors.New("malformed response")
+	}
+	return binary.BigEndian.Uint16(pdu[2:]), nil
+}
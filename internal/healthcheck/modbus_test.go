@@ -0,0 +1,104 @@
+package healthcheck
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+// serveModbus answers read register requests with the value or, if it's negative, with the exception code.
+func serveModbus(t *testing.T, value int) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			request := make([]byte, 12)
+			if _, err := io.ReadFull(conn, request); err == nil {
+				response := append([]byte{}, request[:4]...)
+				if value < 0 {
+					response = append(response, 0, 3, request[6], request[7]|0x80, byte(-value))
+				} else {
+					response = append(response, 0, 5, request[6], request[7], 2)
+					response = binary.BigEndian.AppendUint16(response, uint16(value))
+				}
+				_, _ = conn.Write(response)
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestModbusChecker_IsHealthy(t *testing.T) {
+	record := internal.DnsRecord{Ip: net.ParseIP("127.0.0.1")}
+	expected, lower, upper := 42, 10, 40
+
+	tests := []struct {
+		name    string
+		value   int
+		args    conf.ModbusHealthcheckConfig
+		want    bool
+		wantErr error
+	}{
+		{
+			name:  "read succeeds",
+			value: 42,
+			want:  true,
+		},
+		{
+			name:  "expected value",
+			value: 42,
+			args:  conf.ModbusHealthcheckConfig{Expected: &expected},
+			want:  true,
+		},
+		{
+			name:  "unexpected value",
+			value: 41,
+			args:  conf.ModbusHealthcheckConfig{Expected: &expected},
+		},
+		{
+			name:  "above max",
+			value: 42,
+			args:  conf.ModbusHealthcheckConfig{Min: &lower, Max: &upper},
+		},
+		{
+			name:    "illegal data address",
+			value:   -2,
+			wantErr: internal.ErrCheckMisconfigured,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			args.Port = serveModbus(t, tt.value)
+			checker, err := NewModbusChecker(record, args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := checker.IsHealthy(t.Context())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("IsHealthy() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+const (
+	MonitoringCheckerName = conf.MonitoringCheckerName
+
+	MonitoringIcinga     = "icinga"
+	MonitoringZabbix     = "zabbix"
+	MonitoringUptimeKuma = "uptime_kuma"
+
+	icingaStateOk      = 0
+	icingaStateWarning = 1
+	uptimeKumaUp       = 1
+
+	// zabbixInvalidParams is the code of JSON-RPC errors that Zabbix also uses for failed authentication
+	zabbixInvalidParams = -32602
+)
+
+// MonitoringChecker reuses the verdict of an established monitoring system instead of probing the record itself.
+type MonitoringChecker struct {
+	system     string
+	url        string
+	httpClient *http.Client
+
+	username string
+	password string
+	token    string
+
+	host          string
+	service       string
+	statusPage    string
+	acceptWarning bool
+	minSeverity   int
+}
+
+func NewMonitoringChecker(args conf.MonitoringHealthcheckConfig) (*MonitoringChecker, error) {
+	if args.Host == "" {
+		return nil, errors.New("empty host supplied")
+	}
+
+	switch args.System {
+	case MonitoringIcinga, MonitoringZabbix:
+	case MonitoringUptimeKuma:
+		if args.StatusPage == "" {
+			return nil, errors.New("uptime kuma requires a status page")
+		}
+	default:
+		return nil, fmt.Errorf("unknown monitoring system %q", args.System)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if args.CaFile != "" {
+		ca, err := os.ReadFile(args.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %q", args.CaFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return &MonitoringChecker{
+		system:        args.System,
+		url:           strings.TrimSuffix(args.Url, "/"),
+		httpClient:    &http.Client{Transport: transport},
+		username:      args.Username,
+		password:      args.Password,
+		token:         args.Token,
+		host:          args.Host,
+		service:       args.Service,
+		statusPage:    args.StatusPage,
+		acceptWarning: args.AcceptWarning,
+		minSeverity:   args.MinSeverity,
+	}, nil
+}
+
+func (c *MonitoringChecker) IsHealthy(ctx context.Context) (bool, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	switch c.system {
+	case MonitoringIcinga:
+		return c.icinga(ctx)
+	case MonitoringZabbix:
+		return c.zabbix(ctx)
+	default:
+		return c.uptimeKuma(ctx)
+	}
+}
+
+// icinga gets the state of the host or service, hosts are up in state 0 and services are ok in state 0 or in warning
+// state 1.
+func (c *MonitoringChecker) icinga(ctx context.Context) (bool, error) {
+	endpoint := c.url + "/v1/objects/hosts/" + url.PathEscape(c.host)
+	if c.service != "" {
+		endpoint = c.url + "/v1/objects/services/" + url.PathEscape(c.host+"!"+c.service)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?attrs=state", nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	var response struct {
+		Results []struct {
+			Attrs struct {
+				State float64 `json:"state"`
+			} `json:"attrs"`
+		} `json:"results"`
+	}
+	if err := c.do(req, &response); err != nil {
+		return false, err
+	}
+	if len(response.Results) == 0 {
+		return false, fmt.Errorf("%w: icinga does not know the object", internal.ErrCheckMisconfigured)
+	}
+
+	state := int(response.Results[0].Attrs.State)
+	return state == icingaStateOk || (c.service != "" && c.acceptWarning && state == icingaStateWarning), nil
+}
+
+// zabbix looks up the host and counts its open problems of at least the configured severity.
+func (c *MonitoringChecker) zabbix(ctx context.Context) (bool, error) {
+	var hosts []struct {
+		HostId string `json:"hostid"`
+	}
+	err := c.zabbixCall(ctx, "host.get", map[string]any{
+		"filter": map[string]any{"host": []string{c.host}},
+		"output": []string{"hostid"},
+	}, &hosts)
+	if err != nil {
+		return false, err
+	}
+	if len(hosts) == 0 {
+		return false, fmt.Errorf("%w: zabbix does not know host %q", internal.ErrCheckMisconfigured, c.host)
+	}
+
+	severities := make([]int, 0, 6)
+	for severity := c.minSeverity; severity <= 5; severity++ {
+		severities = append(severities, severity)
+	}
+	params := map[string]any{
+		"hostids":     []string{hosts[0].HostId},
+		"severities":  severities,
+		"countOutput": true,
+	}
+	if c.service != "" {
+		params["search"] = map[string]any{"name": c.service}
+	}
+
+	// the count is returned as a string
+	var problems json.Number
+	if err := c.zabbixCall(ctx, "problem.get", params, &problems); err != nil {
+		return false, err
+	}
+	count, err := problems.Int64()
+	if err != nil {
+		return false, fmt.Errorf("malformed problem count %q", problems)
+	}
+	return count == 0, nil
+}
+
+func (c *MonitoringChecker) zabbixCall(ctx context.Context, method string, params any, result any) error {
+	body, err := json.Marshal(map[string]any{"jsonrpc": "2.0", "method": method, "params": params, "id": 1})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json-rpc")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			Data    string `json:"data"`
+		} `json:"error"`
+	}
+	if err := c.do(req, &response); err != nil {
+		return err
+	}
+	if response.Error != nil {
+		err := fmt.Errorf("zabbix responded with %s %s", response.Error.Message, response.Error.Data)
+		if response.Error.Code == zabbixInvalidParams {
+			return fmt.Errorf("%w: %w", internal.ErrCheckMisconfigured, err)
+		}
+		return err
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// uptimeKuma looks up the monitor on the public status page and evaluates its latest heartbeat.
+func (c *MonitoringChecker) uptimeKuma(ctx context.Context) (bool, error) {
+	slug := url.PathEscape(c.statusPage)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/api/status-page/"+slug, nil)
+	if err != nil {
+		return false, err
+	}
+
+	var page struct {
+		PublicGroupList []struct {
+			MonitorList []struct {
+				Id   int    `json:"id"`
+				Name string `json:"name"`
+			} `json:"monitorList"`
+		} `json:"publicGroupList"`
+	}
+	if err := c.do(req, &page); err != nil {
+		return false, err
+	}
+
+	id := -1
+	for _, group := range page.PublicGroupList {
+		for _, monitor := range group.MonitorList {
+			if monitor.Name == c.host {
+				id = monitor.Id
+			}
+		}
+	}
+	if id < 0 {
+		return false, fmt.Errorf("%w: status page does not list monitor %q", internal.ErrCheckMisconfigured, c.host)
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/api/status-page/heartbeat/"+slug, nil)
+	if err != nil {
+		return false, err
+	}
+
+	var heartbeats struct {
+		HeartbeatList map[string][]struct {
+			Status int `json:"status"`
+		} `json:"heartbeatList"`
+	}
+	if err := c.do(req, &heartbeats); err != nil {
+		return false, err
+	}
+
+	// the heartbeats are ordered from the oldest to the latest
+	list := heartbeats.HeartbeatList[fmt.Sprint(id)]
+	if len(list) == 0 {
+		return false, fmt.Errorf("no heartbeats of monitor %q", c.host)
+	}
+	return list[len(list)-1].Status == uptimeKumaUp, nil
+}
+
+// do sends the request and decodes the json response, unknown objects and rejected credentials are misconfigurations.
+func (c *MonitoringChecker) do(req *http.Request, result any) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %s responded with status %d", internal.ErrCheckMisconfigured, c.system, resp.StatusCode)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("%s responded with status %d", c.system, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBodySize)).Decode(result); err != nil {
+		return fmt.Errorf("malformed response: %w", err)
+	}
+	return nil
+}
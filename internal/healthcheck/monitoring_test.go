@@ -0,0 +1,219 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestMonitoringChecker_Icinga(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "dns-ha" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		states := map[string]int{
+			"/v1/objects/hosts/web1":         0,
+			"/v1/objects/hosts/web2":         1,
+			"/v1/objects/services/web1!http": 1,
+		}
+		state, ok := states[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"results":[{"attrs":{"state":%d.0},"name":"x","type":"Host"}]}`, state)
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name    string
+		args    conf.MonitoringHealthcheckConfig
+		want    bool
+		wantErr error
+	}{
+		{
+			name: "host up",
+			args: conf.MonitoringHealthcheckConfig{Host: "web1", Password: "secret"},
+			want: true,
+		},
+		{
+			name: "host down",
+			args: conf.MonitoringHealthcheckConfig{Host: "web2", Password: "secret"},
+		},
+		{
+			name: "service warning",
+			args: conf.MonitoringHealthcheckConfig{Host: "web1", Service: "http", Password: "secret"},
+		},
+		{
+			name: "service warning accepted",
+			args: conf.MonitoringHealthcheckConfig{Host: "web1", Service: "http", Password: "secret", AcceptWarning: true},
+			want: true,
+		},
+		{
+			name:    "unknown host",
+			args:    conf.MonitoringHealthcheckConfig{Host: "web3", Password: "secret"},
+			wantErr: internal.ErrCheckMisconfigured,
+		},
+		{
+			name:    "wrong password",
+			args:    conf.MonitoringHealthcheckConfig{Host: "web1", Password: "wrong"},
+			wantErr: internal.ErrCheckMisconfigured,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			args.System = MonitoringIcinga
+			args.Url = server.URL
+			args.Username = "dns-ha"
+			testMonitoringChecker(t, args, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestMonitoringChecker_Zabbix(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params.","data":"Not authorized."},"id":1}`))
+			return
+		}
+
+		var req struct {
+			Method string `json:"method"`
+			Params struct {
+				Filter struct {
+					Host []string `json:"host"`
+				} `json:"filter"`
+				Severities []int `json:"severities"`
+			} `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch req.Method {
+		case "host.get":
+			if req.Params.Filter.Host[0] != "web1" {
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","result":[],"id":1}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","result":[{"hostid":"10084"}],"id":1}`))
+		case "problem.get":
+			// a single open problem of severity average
+			count := 0
+			if req.Params.Severities[0] <= 3 {
+				count = 1
+			}
+			_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","result":"%d","id":1}`, count)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name    string
+		args    conf.MonitoringHealthcheckConfig
+		want    bool
+		wantErr error
+	}{
+		{
+			name: "open problem",
+			args: conf.MonitoringHealthcheckConfig{Host: "web1", Token: "token"},
+		},
+		{
+			name: "problem below min severity",
+			args: conf.MonitoringHealthcheckConfig{Host: "web1", Token: "token", MinSeverity: 4},
+			want: true,
+		},
+		{
+			name:    "unknown host",
+			args:    conf.MonitoringHealthcheckConfig{Host: "web2", Token: "token"},
+			wantErr: internal.ErrCheckMisconfigured,
+		},
+		{
+			name:    "wrong token",
+			args:    conf.MonitoringHealthcheckConfig{Host: "web1", Token: "wrong"},
+			wantErr: internal.ErrCheckMisconfigured,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			args.System = MonitoringZabbix
+			args.Url = server.URL + "/api_jsonrpc.php"
+			testMonitoringChecker(t, args, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func TestMonitoringChecker_UptimeKuma(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status-page/infra", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"config":{},"publicGroupList":[{"name":"Services","monitorList":[{"id":1,"name":"web1"},{"id":2,"name":"web2"}]}]}`))
+	})
+	mux.HandleFunc("/api/status-page/heartbeat/infra", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"heartbeatList":{"1":[{"status":0},{"status":1}],"2":[{"status":1},{"status":0}]},"uptimeList":{}}`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name    string
+		args    conf.MonitoringHealthcheckConfig
+		want    bool
+		wantErr error
+	}{
+		{
+			name: "up",
+			args: conf.MonitoringHealthcheckConfig{Host: "web1", StatusPage: "infra"},
+			want: true,
+		},
+		{
+			name: "down",
+			args: conf.MonitoringHealthcheckConfig{Host: "web2", StatusPage: "infra"},
+		},
+		{
+			name:    "unknown monitor",
+			args:    conf.MonitoringHealthcheckConfig{Host: "web3", StatusPage: "infra"},
+			wantErr: internal.ErrCheckMisconfigured,
+		},
+		{
+			name:    "unknown status page",
+			args:    conf.MonitoringHealthcheckConfig{Host: "web1", StatusPage: "other"},
+			wantErr: internal.ErrCheckMisconfigured,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			args.System = MonitoringUptimeKuma
+			args.Url = server.URL
+			testMonitoringChecker(t, args, tt.want, tt.wantErr)
+		})
+	}
+}
+
+func testMonitoringChecker(t *testing.T, args conf.MonitoringHealthcheckConfig, want bool, wantErr error) {
+	t.Helper()
+	checker, err := NewMonitoringChecker(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := checker.IsHealthy(t.Context())
+	if !errors.Is(err, wantErr) {
+		t.Fatalf("IsHealthy() error = %v, want %v", err, wantErr)
+	}
+	if got != want {
+		t.Errorf("IsHealthy() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,247 @@
+package healthcheck
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+const (
+	MqttCheckerName   = conf.MqttCheckerName
+	defaultMqttPort   = 1883
+	defaultMqttsPort  = 8883
+	mqttMaxPacketSize = 256 * 1024
+
+	mqttConnect     = 0x10
+	mqttConnack     = 0x20
+	mqttPublish     = 0x30
+	mqttSubscribe   = 0x82
+	mqttSuback      = 0x90
+	mqttDisconnect  = 0xe0
+	mqttRetainFlag  = 0x01
+	mqttSubscribeId = 1
+)
+
+// MqttChecker connects to an MQTT broker using MQTT 3.1.1, e.g. of a home automation system. If a topic is
+// configured, the broker needs to deliver a message of the topic within the timeout.
+type MqttChecker struct {
+	host      string
+	port      string
+	source    source
+	tlsConfig *tls.Config
+
+	username string
+	password string
+
+	topic          string
+	acceptRetained bool
+}
+
+func NewMqttChecker(host string, record internal.DnsRecord, args conf.MqttHealthcheckConfig, opts ...CheckerOpts) (*MqttChecker, error) {
+	if args.Password != "" && args.Username == "" {
+		return nil, errors.New("password requires a username")
+	}
+
+	source, err := buildSource(record.Ip, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &MqttChecker{
+		host:           record.Address(),
+		port:           strconv.Itoa(defaultMqttPort),
+		source:         source,
+		username:       args.Username,
+		password:       args.Password,
+		topic:          args.Topic,
+		acceptRetained: args.AcceptRetained,
+	}
+	if args.UseTls {
+		ret.port = strconv.Itoa(defaultMqttsPort)
+		ret.tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+	if args.Port > 0 {
+		ret.port = strconv.Itoa(args.Port)
+	}
+	return ret, nil
+}
+
+func (c *MqttChecker) IsHealthy(ctx context.Context) (bool, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	dialer := c.source.dialer()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(c.host, c.port))
+	if err != nil {
+		return false, err
+	}
+	if c.tlsConfig != nil {
+		conn = tls.Client(conn, c.tlsConfig)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	reader := bufio.NewReader(conn)
+	if err := c.connect(conn, reader); err != nil {
+		return false, err
+	}
+	defer func() {
+		_, _ = conn.Write([]byte{mqttDisconnect, 0})
+	}()
+
+	if c.topic == "" {
+		return true, nil
+	}
+	if err := c.awaitMessage(conn, reader); err != nil {
+		// the broker is reachable, but nothing has been published to the topic in time
+		if isTimeout(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *MqttChecker) connect(conn io.Writer, reader *bufio.Reader) error {
+	clientId := make([]byte, 8)
+	_, _ = rand.Read(clientId)
+
+	flags := byte(0x02) // clean session
+	payload := mqttString("dns-ha-" + hex.EncodeToString(clientId))
+	if c.username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(c.username)...)
+	}
+	if c.password != "" {
+		flags |= 0x40
+		payload = append(payload, mqttString(c.password)...)
+	}
+
+	// protocol name, protocol level 4 (MQTT 3.1.1), connect flags and keep alive in seconds
+	body := append(mqttString("MQTT"), 4, flags, 0, 30)
+	if _, err := conn.Write(mqttPacket(mqttConnect, append(body, payload...))); err != nil {
+		return err
+	}
+
+	packetType, body, err := readMqttPacket(reader)
+	if err != nil {
+		return fmt.Errorf("could not read connack: %w", err)
+	}
+	if packetType&0xf0 != mqttConnack || len(body) != 2 {
+		return errors.New("broker did not acknowledge the connection")
+	}
+	switch body[1] {
+	case 0:
+		return nil
+	case 4, 5:
+		// bad username or password, not authorized
+		return fmt.Errorf("%w: broker refused the connection with code %d", internal.ErrCheckMisconfigured, body[1])
+	default:
+		return fmt.Errorf("broker refused the connection with code %d", body[1])
+	}
+}
+
+// awaitMessage subscribes to the topic and waits for a message.
+func (c *MqttChecker) awaitMessage(conn io.Writer, reader *bufio.Reader) error {
+	body := binary.BigEndian.AppendUint16(nil, mqttSubscribeId)
+	body = append(append(body, mqttString(c.topic)...), 0)
+	if _, err := conn.Write(mqttPacket(mqttSubscribe, body)); err != nil {
+		return err
+	}
+
+	for {
+		packetType, body, err := readMqttPacket(reader)
+		if err != nil {
+			return err
+		}
+
+		switch packetType & 0xf0 {
+		case mqttSuback:
+			if len(body) != 3 || binary.BigEndian.Uint16(body) != mqttSubscribeId {
+				return errors.New("malformed suback")
+			}
+			if body[2] == 0x80 {
+				return fmt.Errorf("%w: broker refused the subscription of %q", internal.ErrCheckMisconfigured, c.topic)
+			}
+		case mqttPublish:
+			if packetType&mqttRetainFlag == 0 || c.acceptRetained {
+				return nil
+			}
+		}
+	}
+}
+
+func readMqttPacket(reader *bufio.Reader) (byte, []byte, error) {
+	packetType, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// the remaining length is encoded using up to 4 bytes, 7 bits each
+	var length, shift int
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
+		if shift > 21 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+	}
+	if length > mqttMaxPacketSize {
+		return 0, nil, fmt.Errorf("packet of %d bytes exceeds the maximum size", length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return 0, nil, err
+	}
+	return packetType, body, nil
+}
+
+func mqttPacket(packetType byte, body []byte) []byte {
+	packet := []byte{packetType}
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+func mqttString(s string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(s))), s...) //nolint G115
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,121 @@
+package healthcheck
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+// serveMqtt acknowledges connections with the return code and answers subscriptions with the given publish packets.
+func serveMqtt(t *testing.T, returnCode byte, publish ...[]byte) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				if _, _, err := readMqttPacket(reader); err != nil {
+					return
+				}
+				_, _ = conn.Write(mqttPacket(mqttConnack, []byte{0, returnCode}))
+
+				packetType, _, err := readMqttPacket(reader)
+				if err != nil || packetType != mqttSubscribe {
+					return
+				}
+				_, _ = conn.Write(mqttPacket(mqttSuback, []byte{0, mqttSubscribeId, 0}))
+				for _, packet := range publish {
+					_, _ = conn.Write(packet)
+				}
+				// keep the connection open until the client disconnects
+				_, _, _ = readMqttPacket(reader)
+			}()
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestMqttChecker_IsHealthy(t *testing.T) {
+	record := internal.DnsRecord{Ip: net.ParseIP("127.0.0.1")}
+	message := mqttPacket(mqttPublish, append(mqttString("sensors/up"), "1"...))
+	retained := mqttPacket(mqttPublish|mqttRetainFlag, append(mqttString("sensors/up"), "1"...))
+
+	tests := []struct {
+		name       string
+		returnCode byte
+		publish    [][]byte
+		args       conf.MqttHealthcheckConfig
+		want       bool
+		wantErr    error
+	}{
+		{
+			name: "connect",
+			want: true,
+		},
+		{
+			name:       "not authorized",
+			returnCode: 5,
+			args:       conf.MqttHealthcheckConfig{Username: "dns-ha", Password: "secret"},
+			wantErr:    internal.ErrCheckMisconfigured,
+		},
+		{
+			name:    "message published",
+			publish: [][]byte{message},
+			args:    conf.MqttHealthcheckConfig{Topic: "sensors/up"},
+			want:    true,
+		},
+		{
+			name: "no message",
+			args: conf.MqttHealthcheckConfig{Topic: "sensors/up"},
+		},
+		{
+			name:    "retained message",
+			publish: [][]byte{retained},
+			args:    conf.MqttHealthcheckConfig{Topic: "sensors/up"},
+		},
+		{
+			name:    "accepted retained message",
+			publish: [][]byte{retained},
+			args:    conf.MqttHealthcheckConfig{Topic: "sensors/up", AcceptRetained: true},
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			args.Port = serveMqtt(t, tt.returnCode, tt.publish...)
+			checker, err := NewMqttChecker("broker.example", record, args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+			defer cancel()
+			got, err := checker.IsHealthy(ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("IsHealthy() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
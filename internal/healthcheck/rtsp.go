@@ -0,0 +1,86 @@
+package healthcheck
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+const (
+	RtspCheckerName = conf.RtspCheckerName
+	defaultRtspPort = 554
+)
+
+// RtspChecker sends an RTSP OPTIONS request to a camera or streaming server.
+type RtspChecker struct {
+	host        string
+	port        string
+	source      source
+	path        string
+	statusCodes []int
+}
+
+func NewRtspChecker(record internal.DnsRecord, args conf.RtspHealthcheckConfig, opts ...CheckerOpts) (*RtspChecker, error) {
+	source, err := buildSource(record.Ip, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &RtspChecker{
+		host:        record.Address(),
+		port:        strconv.Itoa(defaultRtspPort),
+		source:      source,
+		path:        args.Path,
+		statusCodes: defaultOptionsStatusCodes,
+	}
+	if args.Port > 0 {
+		ret.port = strconv.Itoa(args.Port)
+	}
+	if len(args.StatusCodes) > 0 {
+		ret.statusCodes = args.StatusCodes
+	}
+	return ret, nil
+}
+
+func (c *RtspChecker) IsHealthy(ctx context.Context) (bool, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	address := net.JoinHostPort(c.host, c.port)
+	conn, err := c.source.dial(ctx, "tcp", address)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	// the request applies to the server itself unless a stream is given
+	target := "*"
+	if c.path != "" {
+		target = "rtsp://" + address + c.path
+	}
+	if _, err := fmt.Fprintf(conn, "OPTIONS %s RTSP/1.0\r\nCSeq: 1\r\nUser-Agent: dns-ha\r\n\r\n", target); err != nil {
+		return false, err
+	}
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("could not read response: %w", err)
+	}
+	code, err := parseStatusLine(line, "RTSP/1.0")
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(c.statusCodes, code), nil
+}
@@ -0,0 +1,109 @@
+package healthcheck
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+// serveRtsp answers requests for the given path with the status, other requests are answered with 404.
+func serveRtsp(t *testing.T, path, status string) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				line, err := reader.ReadString('\n')
+				if err != nil || skipHeaders(reader) != nil {
+					return
+				}
+				response := status
+				fields := strings.Fields(line)
+				if len(fields) != 3 || fields[0] != "OPTIONS" || !strings.HasSuffix(fields[1], path) {
+					response = "404 Not Found"
+				}
+				_, _ = conn.Write([]byte("RTSP/1.0 " + response + "\r\nCSeq: 1\r\nPublic: DESCRIBE, SETUP, PLAY, TEARDOWN\r\n\r\n"))
+			}()
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestRtspChecker_IsHealthy(t *testing.T) {
+	record := internal.DnsRecord{Ip: net.ParseIP("127.0.0.1")}
+
+	tests := []struct {
+		name       string
+		serverPath string
+		status     string
+		args       conf.RtspHealthcheckConfig
+		want       bool
+	}{
+		{
+			name:       "server",
+			serverPath: "*",
+			status:     "200 OK",
+			want:       true,
+		},
+		{
+			name:       "stream",
+			serverPath: "/stream1",
+			status:     "200 OK",
+			args:       conf.RtspHealthcheckConfig{Path: "/stream1"},
+			want:       true,
+		},
+		{
+			name:       "unknown stream",
+			serverPath: "/stream1",
+			status:     "200 OK",
+			args:       conf.RtspHealthcheckConfig{Path: "/stream2"},
+		},
+		{
+			name:       "unavailable",
+			serverPath: "*",
+			status:     "503 Service Unavailable",
+		},
+		{
+			name:       "custom status codes",
+			serverPath: "*",
+			status:     "401 Unauthorized",
+			args:       conf.RtspHealthcheckConfig{StatusCodes: []int{200, 401}},
+			want:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			args.Port = serveRtsp(t, tt.serverPath, tt.status)
+			checker, err := NewRtspChecker(record, args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := checker.IsHealthy(t.Context())
+			if err != nil {
+				t.Fatalf("IsHealthy() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+// sampledIdleTimeout stops the probes of a checker whose verdict has not been requested for a while, e.g. because
+// its record has been removed from the config. The probes are resumed on the next request.
+const sampledIdleTimeout = 10 * time.Minute
+
+// SampledChecker probes a checker more often than the records are evaluated. Each evaluation consumes the verdict of
+// the latest samples, so failures are detected faster without changing the published records more often.
+type SampledChecker struct {
+	check      internal.Healthcheck
+	interval   time.Duration
+	timeout    time.Duration
+	window     int
+	minHealthy int
+
+	mutex    sync.Mutex
+	samples  []sample
+	stop     context.CancelFunc
+	lastRead time.Time
+}
+
+type sample struct {
+	healthy bool
+	err     error
+}
+
+// NewSampledChecker samples the checker once every interval of the config. The timeout bounds each sample, it
+// defaults to the interval.
+func NewSampledChecker(check internal.Healthcheck, args conf.SamplesConfig, timeout time.Duration) (*SampledChecker, error) {
+	if check == nil {
+		return nil, errors.New("empty healthcheck provided")
+	}
+	if args.Interval <= 0 || args.Window < 1 || args.MinHealthy > args.Window {
+		return nil, errors.New("invalid samples config")
+	}
+
+	ret := &SampledChecker{
+		check:      check,
+		interval:   args.Interval,
+		timeout:    args.Interval,
+		window:     args.Window,
+		minHealthy: args.MinHealthy,
+	}
+	if timeout > 0 {
+		ret.timeout = min(timeout, args.Interval)
+	}
+	// a majority of the samples needs to be healthy by default
+	if ret.minHealthy == 0 {
+		ret.minHealthy = args.Window/2 + 1
+	}
+	return ret, nil
+}
+
+// IsHealthy returns the verdict of the latest samples. Until enough samples have been taken, e.g. after a start, the
+// checker is probed directly.
+func (c *SampledChecker) IsHealthy(ctx context.Context) (bool, error) {
+	c.mutex.Lock()
+	c.lastRead = time.Now()
+	if c.stop == nil {
+		var ctx context.Context
+		ctx, c.stop = context.WithCancel(context.Background())
+		go c.sample(ctx)
+	}
+	samples := c.samples
+	c.mutex.Unlock()
+
+	if len(samples) < c.window {
+		healthy, err := c.check.IsHealthy(ctx)
+		c.add(sample{healthy: healthy, err: err})
+		return healthy, err
+	}
+	return c.verdict(samples)
+}
+
+func (c *SampledChecker) verdict(samples []sample) (bool, error) {
+	var healthy int
+	var errs []error
+	for _, s := range samples {
+		switch {
+		case s.err != nil:
+			errs = append(errs, s.err)
+		case s.healthy:
+			healthy++
+		}
+	}
+
+	if healthy >= c.minHealthy {
+		return true, nil
+	}
+	// the checks keep failing with errors instead of producing a result
+	if len(errs) == len(samples) {
+		return false, errs[len(errs)-1]
+	}
+	return false, nil
+}
+
+func (c *SampledChecker) sample(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		c.mutex.Lock()
+		if time.Since(c.lastRead) >= sampledIdleTimeout {
+			c.reset()
+			c.mutex.Unlock()
+			return
+		}
+		c.mutex.Unlock()
+
+		sampleCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		healthy, err := c.check.IsHealthy(sampleCtx)
+		cancel()
+		// samples taken while closing are incomplete
+		if ctx.Err() != nil {
+			return
+		}
+		c.add(sample{healthy: healthy, err: err})
+	}
+}
+
+// Close stops the probes, e.g. once the record has been removed. The probes are resumed on the next request.
+func (c *SampledChecker) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reset()
+	return nil
+}
+
+// reset stops the probes and drops the samples, the caller needs to hold the mutex.
+func (c *SampledChecker) reset() {
+	if c.stop != nil {
+		c.stop()
+		c.stop = nil
+	}
+	c.samples = nil
+}
+
+func (c *SampledChecker) add(s sample) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// samples is replaced instead of modified, so readers can keep using the previous slice without holding the lock
+	samples := append(slices.Clone(c.samples), s)
+	c.samples = samples[max(0, len(samples)-c.window):]
+}
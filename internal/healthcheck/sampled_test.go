@@ -0,0 +1,151 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+type constantHealthcheck struct {
+	healthy bool
+	err     error
+}
+
+func (c *constantHealthcheck) IsHealthy(_ context.Context) (bool, error) {
+	return c.healthy, c.err
+}
+
+func TestSampledChecker_verdict(t *testing.T) {
+	errProbe := errors.New("probe failed")
+
+	tests := []struct {
+		name        string
+		minHealthy  int
+		samples     []sample
+		wantHealthy bool
+		wantErr     bool
+	}{
+		{
+			name:        "majority healthy",
+			samples:     []sample{{healthy: true}, {healthy: true}, {healthy: true}, {healthy: true}, {}, {}},
+			wantHealthy: true,
+		},
+		{
+			name:    "tie is unhealthy",
+			samples: []sample{{healthy: true}, {healthy: true}, {healthy: true}, {}, {}, {}},
+		},
+		{
+			name:        "errors count as unhealthy",
+			samples:     []sample{{healthy: true}, {healthy: true}, {healthy: true}, {healthy: true}, {err: errProbe}, {err: errProbe}},
+			wantHealthy: true,
+		},
+		{
+			name:    "only errors",
+			samples: []sample{{err: errProbe}, {err: errProbe}, {err: errProbe}, {err: errProbe}, {err: errProbe}, {err: errProbe}},
+			wantErr: true,
+		},
+		{
+			name:        "configured minimum",
+			minHealthy:  2,
+			samples:     []sample{{healthy: true}, {healthy: true}, {}, {}, {}, {}},
+			wantHealthy: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker, err := NewSampledChecker(&constantHealthcheck{}, conf.SamplesConfig{Interval: 5 * time.Second, Window: 6, MinHealthy: tt.minHealthy}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			healthy, err := checker.verdict(tt.samples)
+			if healthy != tt.wantHealthy {
+				t.Errorf("verdict() healthy = %v, want %v", healthy, tt.wantHealthy)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verdict() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSampledChecker_window(t *testing.T) {
+	check := &constantHealthcheck{healthy: true}
+	checker, err := NewSampledChecker(check, conf.SamplesConfig{Interval: time.Hour, Window: 3}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the checker is probed directly until the window is filled
+	for range 3 {
+		if healthy, err := checker.IsHealthy(t.Context()); !healthy || err != nil {
+			t.Fatalf("expected healthy, got %v, %v", healthy, err)
+		}
+	}
+
+	// a single failed sample is outvoted by the older ones
+	check.healthy = false
+	checker.add(sample{})
+	if healthy, _ := checker.IsHealthy(t.Context()); !healthy {
+		t.Error("expected healthy verdict with 2 of 3 healthy samples")
+	}
+
+	checker.add(sample{})
+	if healthy, _ := checker.IsHealthy(t.Context()); healthy {
+		t.Error("expected unhealthy verdict with 1 of 3 healthy samples")
+	}
+}
+
+type countingHealthcheck struct {
+	probes atomic.Int32
+}
+
+func (c *countingHealthcheck) IsHealthy(_ context.Context) (bool, error) {
+	c.probes.Add(1)
+	return true, nil
+}
+
+func TestSampledChecker_Close(t *testing.T) {
+	check := &countingHealthcheck{}
+	checker, err := NewSampledChecker(check, conf.SamplesConfig{Interval: time.Millisecond, Window: 3}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := checker.IsHealthy(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for check.probes.Load() < 5 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := checker.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// a probe may still be in flight while closing
+	time.Sleep(10 * time.Millisecond)
+	probes := check.probes.Load()
+	time.Sleep(20 * time.Millisecond)
+	if got := check.probes.Load(); got != probes {
+		t.Errorf("expected no probes after closing, got %d more", got-probes)
+	}
+
+	// the probes are resumed on the next request
+	if _, err := checker.IsHealthy(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for check.probes.Load() < probes+3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if check.probes.Load() < probes+3 {
+		t.Error("expected the probes to be resumed")
+	}
+	_ = checker.Close()
+}
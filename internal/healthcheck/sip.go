@@ -0,0 +1,183 @@
+package healthcheck
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+const (
+	SipCheckerName     = conf.SipCheckerName
+	defaultSipPort     = 5060
+	defaultSipsPort    = 5061
+	sipMaxResponseSize = 64 * 1024
+)
+
+var defaultOptionsStatusCodes = []int{200}
+
+// SipChecker sends a SIP OPTIONS request to a PBX or SIP proxy and waits for its final response.
+type SipChecker struct {
+	host        string
+	port        string
+	source      source
+	transport   string
+	tlsConfig   *tls.Config
+	statusCodes []int
+}
+
+func NewSipChecker(host string, record internal.DnsRecord, args conf.SipHealthcheckConfig, opts ...CheckerOpts) (*SipChecker, error) {
+	source, err := buildSource(record.Ip, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &SipChecker{
+		host:        record.Address(),
+		port:        strconv.Itoa(defaultSipPort),
+		source:      source,
+		transport:   "udp",
+		statusCodes: defaultOptionsStatusCodes,
+	}
+	switch args.Transport {
+	case "", "udp":
+	case "tcp":
+		ret.transport = "tcp"
+	case "tls":
+		ret.transport = "tcp"
+		ret.port = strconv.Itoa(defaultSipsPort)
+		ret.tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("unknown transport %q", args.Transport)
+	}
+	if args.Port > 0 {
+		ret.port = strconv.Itoa(args.Port)
+	}
+	if len(args.StatusCodes) > 0 {
+		ret.statusCodes = args.StatusCodes
+	}
+	return ret, nil
+}
+
+func (c *SipChecker) IsHealthy(ctx context.Context) (bool, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
+
+	conn, err := c.source.dial(ctx, c.transport, net.JoinHostPort(c.host, c.port))
+	if err != nil {
+		return false, err
+	}
+	if c.tlsConfig != nil {
+		conn = tls.Client(conn, c.tlsConfig)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(c.request(conn.LocalAddr())); err != nil {
+		return false, err
+	}
+
+	reader := bufio.NewReaderSize(conn, sipMaxResponseSize)
+	for {
+		code, err := c.readResponse(reader)
+		if err != nil {
+			return false, err
+		}
+		// provisional responses precede the final response
+		if code >= 200 {
+			return slices.Contains(c.statusCodes, code), nil
+		}
+	}
+}
+
+func (c *SipChecker) request(local net.Addr) []byte {
+	transport := strings.ToUpper(c.transport)
+	if c.tlsConfig != nil {
+		transport = "TLS"
+	}
+	target := "sip:" + net.JoinHostPort(c.host, c.port)
+	if strings.Contains(c.host, ":") {
+		target = "sip:[" + c.host + "]:" + c.port
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "OPTIONS %s SIP/2.0\r\n", target)
+	fmt.Fprintf(&buf, "Via: SIP/2.0/%s %s;branch=z9hG4bK%s;rport\r\n", transport, local, randomToken())
+	buf.WriteString("Max-Forwards: 70\r\n")
+	fmt.Fprintf(&buf, "From: <sip:dns-ha@%s>;tag=%s\r\n", local, randomToken())
+	fmt.Fprintf(&buf, "To: <%s>\r\n", target)
+	fmt.Fprintf(&buf, "Call-ID: %s@dns-ha\r\n", randomToken())
+	buf.WriteString("CSeq: 1 OPTIONS\r\n")
+	buf.WriteString("Accept: application/sdp\r\n")
+	buf.WriteString("Content-Length: 0\r\n\r\n")
+	return buf.Bytes()
+}
+
+// readResponse returns the status code of the next response and skips its headers. Responses via udp are read as
+// a whole, the headers are only skipped for stream transports.
+func (c *SipChecker) readResponse(reader *bufio.Reader) (int, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return 0, fmt.Errorf("could not read response: %w", err)
+	}
+	code, err := parseStatusLine(line, "SIP/2.0")
+	if err != nil {
+		return 0, err
+	}
+
+	if c.transport == "udp" {
+		_, _ = reader.Discard(reader.Buffered())
+		return code, nil
+	}
+	return code, skipHeaders(reader)
+}
+
+// parseStatusLine returns the status code of a response line such as "SIP/2.0 200 OK".
+func parseStatusLine(line, proto string) (int, error) {
+	version, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	if version != proto {
+		return 0, fmt.Errorf("unexpected response %q", strings.TrimSpace(line))
+	}
+	status, _, _ := strings.Cut(rest, " ")
+	code, err := strconv.Atoi(status)
+	if err != nil || code < 100 || code > 699 {
+		return 0, fmt.Errorf("invalid status %q", status)
+	}
+	return code, nil
+}
+
+// skipHeaders reads up to the empty line that terminates the headers, the body is not read.
+func skipHeaders(reader *bufio.Reader) error {
+	for read := 0; read < sipMaxResponseSize; {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("could not read headers: %w", err)
+		}
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		read += len(line)
+	}
+	return fmt.Errorf("headers exceed %d bytes", sipMaxResponseSize)
+}
+
+func randomToken() string {
+	token := make([]byte, 8)
+	_, _ = rand.Read(token)
+	return hex.EncodeToString(token)
+}
@@ -0,0 +1,173 @@
+package healthcheck
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func sipResponse(status string) string {
+	return "SIP/2.0 " + status + "\r\nVia: SIP/2.0/UDP 127.0.0.1\r\nCSeq: 1 OPTIONS\r\nContent-Length: 0\r\n\r\n"
+}
+
+// serveSipUdp answers each OPTIONS request with the given responses, one datagram per response.
+func serveSipUdp(t *testing.T, responses ...string) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if !strings.HasPrefix(string(buf[:n]), "OPTIONS sip:") {
+				continue
+			}
+			for _, response := range responses {
+				_, _ = conn.WriteTo([]byte(response), addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// serveSipTcp reads the headers of a single request and writes the responses back in one go.
+func serveSipTcp(t *testing.T, responses ...string) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if err := skipHeaders(bufio.NewReader(conn)); err != nil {
+					return
+				}
+				_, _ = conn.Write([]byte(strings.Join(responses, "")))
+			}()
+		}
+	}()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestSipChecker_IsHealthy(t *testing.T) {
+	record := internal.DnsRecord{Ip: net.ParseIP("127.0.0.1")}
+
+	tests := []struct {
+		name      string
+		transport string
+		responses []string
+		args      conf.SipHealthcheckConfig
+		want      bool
+		wantErr   bool
+	}{
+		{
+			name:      "udp ok",
+			transport: "udp",
+			responses: []string{sipResponse("200 OK")},
+			want:      true,
+		},
+		{
+			name:      "udp provisional response",
+			transport: "udp",
+			responses: []string{sipResponse("100 Trying"), sipResponse("200 OK")},
+			want:      true,
+		},
+		{
+			name:      "tcp provisional response",
+			transport: "tcp",
+			responses: []string{sipResponse("100 Trying"), sipResponse("200 OK")},
+			want:      true,
+		},
+		{
+			name:      "tcp unavailable",
+			transport: "tcp",
+			responses: []string{sipResponse("503 Service Unavailable")},
+		},
+		{
+			name:      "custom status codes",
+			transport: "udp",
+			responses: []string{sipResponse("405 Method Not Allowed")},
+			args:      conf.SipHealthcheckConfig{StatusCodes: []int{200, 405}},
+			want:      true,
+		},
+		{
+			name:      "not sip",
+			transport: "tcp",
+			responses: []string{"HTTP/1.1 400 Bad Request\r\n\r\n"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			args.Transport = tt.transport
+			if tt.transport == "udp" {
+				args.Port = serveSipUdp(t, tt.responses...)
+			} else {
+				args.Port = serveSipTcp(t, tt.responses...)
+			}
+			checker, err := NewSipChecker("pbx.example.com", record, args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := checker.IsHealthy(t.Context())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsHealthy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("IsHealthy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStatusLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		proto   string
+		want    int
+		wantErr bool
+	}{
+		{line: "SIP/2.0 200 OK\r\n", proto: "SIP/2.0", want: 200},
+		{line: "RTSP/1.0 454 Session Not Found\r\n", proto: "RTSP/1.0", want: 454},
+		{line: "RTSP/1.0 200\r\n", proto: "RTSP/1.0", want: 200},
+		{line: "HTTP/1.1 200 OK\r\n", proto: "RTSP/1.0", wantErr: true},
+		{line: "SIP/2.0 abc OK\r\n", proto: "SIP/2.0", wantErr: true},
+		{line: "SIP/2.0 99 Too Low\r\n", proto: "SIP/2.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := parseStatusLine(tt.line, tt.proto)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseStatusLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseStatusLine() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
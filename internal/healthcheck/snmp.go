@@ -0,0 +1,228 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+const (
+	SnmpCheckerName = conf.SnmpCheckerName
+	defaultSnmpPort = 161
+)
+
+var (
+	snmpAuthProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+		"md5":    gosnmp.MD5,
+		"sha":    gosnmp.SHA,
+		"sha224": gosnmp.SHA224,
+		"sha256": gosnmp.SHA256,
+		"sha384": gosnmp.SHA384,
+		"sha512": gosnmp.SHA512,
+	}
+	snmpPrivProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+		"des":    gosnmp.DES,
+		"aes":    gosnmp.AES,
+		"aes192": gosnmp.AES192,
+		"aes256": gosnmp.AES256,
+	}
+)
+
+// SnmpChecker gets a single OID using SNMP v2c or v3 and compares its value against the expected value and
+// thresholds.
+type SnmpChecker struct {
+	host   string
+	port   uint16
+	oid    string
+	source source
+
+	expected string
+	min      *float64
+	max      *float64
+
+	// configure sets the version and the credentials of a request
+	configure func(*gosnmp.GoSNMP)
+}
+
+func NewSnmpChecker(record internal.DnsRecord, args conf.SnmpHealthcheckConfig, opts ...CheckerOpts) (*SnmpChecker, error) {
+	if args.Oid == "" {
+		return nil, errors.New("missing oid in args")
+	}
+	if args.Min != nil && args.Max != nil && *args.Min > *args.Max {
+		return nil, errors.New("min must not be greater than max")
+	}
+
+	source, err := buildSource(record.Ip, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	configure, err := snmpCredentials(args)
+	if err != nil {
+		return nil, err
+	}
+
+	port := defaultSnmpPort
+	if args.Port > 0 {
+		port = args.Port
+	}
+
+	return &SnmpChecker{
+		host:      record.Ip.String(),
+		port:      uint16(port), //nolint G115
+		oid:       args.Oid,
+		source:    source,
+		expected:  args.Expected,
+		min:       args.Min,
+		max:       args.Max,
+		configure: configure,
+	}, nil
+}
+
+func snmpCredentials(args conf.SnmpHealthcheckConfig) (func(*gosnmp.GoSNMP), error) {
+	if args.Version != "3" {
+		if args.Community == "" {
+			return nil, errors.New("missing community in args")
+		}
+		return func(g *gosnmp.GoSNMP) {
+			g.Version = gosnmp.Version2c
+			g.Community = args.Community
+		}, nil
+	}
+
+	if args.Username == "" {
+		return nil, errors.New("missing username in args")
+	}
+	params := gosnmp.UsmSecurityParameters{UserName: args.Username, AuthenticationProtocol: gosnmp.NoAuth, PrivacyProtocol: gosnmp.NoPriv}
+	flags := gosnmp.NoAuthNoPriv
+	if args.AuthProtocol != "" {
+		protocol, ok := snmpAuthProtocols[args.AuthProtocol]
+		if !ok {
+			return nil, fmt.Errorf("unknown auth protocol %q", args.AuthProtocol)
+		}
+		params.AuthenticationProtocol, params.AuthenticationPassphrase = protocol, args.AuthPassword
+		flags = gosnmp.AuthNoPriv
+	}
+	if args.PrivProtocol != "" {
+		protocol, ok := snmpPrivProtocols[args.PrivProtocol]
+		if !ok {
+			return nil, fmt.Errorf("unknown priv protocol %q", args.PrivProtocol)
+		}
+		if flags != gosnmp.AuthNoPriv {
+			return nil, errors.New("privacy requires an auth protocol")
+		}
+		params.PrivacyProtocol, params.PrivacyPassphrase = protocol, args.PrivPassword
+		flags = gosnmp.AuthPriv
+	}
+
+	return func(g *gosnmp.GoSNMP) {
+		g.Version = gosnmp.Version3
+		g.SecurityModel = gosnmp.UserSecurityModel
+		g.MsgFlags = flags
+		// the parameters hold the state of the engine discovery, so each request needs its own copy
+		g.SecurityParameters = &gosnmp.UsmSecurityParameters{
+			UserName:                 params.UserName,
+			AuthenticationProtocol:   params.AuthenticationProtocol,
+			AuthenticationPassphrase: params.AuthenticationPassphrase,
+			PrivacyProtocol:          params.PrivacyProtocol,
+			PrivacyPassphrase:        params.PrivacyPassphrase,
+		}
+	}, nil
+}
+
+func (c *SnmpChecker) IsHealthy(ctx context.Context) (bool, error) {
+	timeout := defaultTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	client := &gosnmp.GoSNMP{
+		Target:  c.host,
+		Port:    c.port,
+		Context: ctx,
+		Timeout: timeout,
+		MaxOids: gosnmp.MaxOids,
+	}
+	c.configure(client)
+	if c.source.ip != nil {
+		client.LocalAddr = net.JoinHostPort(c.source.ip.String(), "0")
+	}
+	if c.source.iface != "" {
+		client.Control = bindToInterface(c.source.iface)
+	}
+
+	if err := client.Connect(); err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = client.Conn.Close()
+	}()
+
+	result, err := client.Get([]string{c.oid})
+	if err != nil {
+		return false, err
+	}
+	if result.Error != gosnmp.NoError {
+		return false, fmt.Errorf("snmp get of %s failed: %v", c.oid, result.Error)
+	}
+	if len(result.Variables) != 1 {
+		return false, fmt.Errorf("expected a single variable, got %d", len(result.Variables))
+	}
+
+	value, err := snmpValue(result.Variables[0])
+	if err != nil {
+		return false, err
+	}
+	return c.matches(value), nil
+}
+
+// snmpValue returns the string representation of the variable.
+func snmpValue(variable gosnmp.SnmpPDU) (string, error) {
+	switch variable.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView:
+		return "", fmt.Errorf("%w: oid %s: %v", internal.ErrCheckMisconfigured, variable.Name, variable.Type)
+	case gosnmp.OctetString:
+		value, _ := variable.Value.([]byte)
+		return strings.TrimSpace(string(value)), nil
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Counter64, gosnmp.Uinteger32:
+		return gosnmp.ToBigInt(variable.Value).String(), nil
+	case gosnmp.ObjectIdentifier, gosnmp.IPAddress:
+		value, _ := variable.Value.(string)
+		return value, nil
+	default:
+		return "", fmt.Errorf("unsupported type %v of oid %s", variable.Type, variable.Name)
+	}
+}
+
+// matches compares the value against the expectations, values are compared numerically if both are numbers.
+func (c *SnmpChecker) matches(value string) bool {
+	number, numeric := new(big.Float).SetString(value)
+	if c.expected != "" {
+		expected, ok := new(big.Float).SetString(c.expected)
+		if numeric && ok {
+			if number.Cmp(expected) != 0 {
+				return false
+			}
+		} else if value != c.expected {
+			return false
+		}
+	}
+
+	if c.min == nil && c.max == nil {
+		return true
+	}
+	if !numeric {
+		return false
+	}
+	parsed, _ := strconv.ParseFloat(value, 64)
+	return (c.min == nil || parsed >= *c.min) && (c.max == nil || parsed <= *c.max)
+}
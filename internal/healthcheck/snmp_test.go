@@ -0,0 +1,159 @@
+package healthcheck
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestNewSnmpChecker(t *testing.T) {
+	record := internal.DnsRecord{Ip: net.ParseIP("10.0.0.1")}
+	lower, upper := 10.0, 5.0
+
+	tests := []struct {
+		name    string
+		args    conf.SnmpHealthcheckConfig
+		wantErr bool
+	}{
+		{
+			name: "v2c",
+			args: conf.SnmpHealthcheckConfig{Community: "public", Oid: ".1.3.6.1.2.1.1.3.0"},
+		},
+		{
+			name:    "v2c without community",
+			args:    conf.SnmpHealthcheckConfig{Oid: ".1.3.6.1.2.1.1.3.0"},
+			wantErr: true,
+		},
+		{
+			name: "v3 auth priv",
+			args: conf.SnmpHealthcheckConfig{Version: "3", Username: "monitor", AuthProtocol: "sha256", AuthPassword: "secret1234", PrivProtocol: "aes", PrivPassword: "secret1234", Oid: ".1.3.6.1.2.1.1.3.0"},
+		},
+		{
+			name:    "v3 priv without auth",
+			args:    conf.SnmpHealthcheckConfig{Version: "3", Username: "monitor", PrivProtocol: "aes", PrivPassword: "secret1234", Oid: ".1.3.6.1.2.1.1.3.0"},
+			wantErr: true,
+		},
+		{
+			name:    "min greater than max",
+			args:    conf.SnmpHealthcheckConfig{Community: "public", Oid: ".1.3.6.1.2.1.1.3.0", Min: &lower, Max: &upper},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSnmpChecker(record, tt.args); (err != nil) != tt.wantErr {
+				t.Errorf("NewSnmpChecker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSnmpChecker_matches(t *testing.T) {
+	lower, upper := 10.0, 75.5
+
+	tests := []struct {
+		name    string
+		checker SnmpChecker
+		value   string
+		want    bool
+	}{
+		{
+			name:  "no expectations",
+			value: "anything",
+			want:  true,
+		},
+		{
+			name:    "expected string",
+			checker: SnmpChecker{expected: "up"},
+			value:   "up",
+			want:    true,
+		},
+		{
+			name:    "unexpected string",
+			checker: SnmpChecker{expected: "up"},
+			value:   "down",
+		},
+		{
+			name:    "expected number",
+			checker: SnmpChecker{expected: "1.0"},
+			value:   "1",
+			want:    true,
+		},
+		{
+			name:    "within thresholds",
+			checker: SnmpChecker{min: &lower, max: &upper},
+			value:   "42",
+			want:    true,
+		},
+		{
+			name:    "above max",
+			checker: SnmpChecker{min: &lower, max: &upper},
+			value:   "80",
+		},
+		{
+			name:    "below min",
+			checker: SnmpChecker{min: &lower},
+			value:   "9",
+		},
+		{
+			name:    "threshold of non-numeric value",
+			checker: SnmpChecker{max: &upper},
+			value:   "up",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.checker.matches(tt.value); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnmpValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		variable gosnmp.SnmpPDU
+		want     string
+		wantErr  error
+	}{
+		{
+			name:     "octet string",
+			variable: gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte("up\n")},
+			want:     "up",
+		},
+		{
+			name:     "gauge",
+			variable: gosnmp.SnmpPDU{Type: gosnmp.Gauge32, Value: uint(42)},
+			want:     "42",
+		},
+		{
+			name:     "integer",
+			variable: gosnmp.SnmpPDU{Type: gosnmp.Integer, Value: -3},
+			want:     "-3",
+		},
+		{
+			name:     "no such object",
+			variable: gosnmp.SnmpPDU{Name: ".1.3.6.1.4.1.1", Type: gosnmp.NoSuchObject},
+			wantErr:  internal.ErrCheckMisconfigured,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := snmpValue(tt.variable)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("snmpValue() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("snmpValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// source pins the probes of a healthcheck to a local address and/or interface.
+type source struct {
+	ip        net.IP
+	iface     string
+	hasSource bool
+}
+
+type CheckerOpts func(*source) error
+
+// WithSourceIp sends the probes from the given local address.
+func WithSourceIp(ip net.IP) CheckerOpts {
+	return func(s *source) error {
+		if ip == nil {
+			return errors.New("nil source ip supplied")
+		}
+		s.ip = ip
+		s.hasSource = true
+		return nil
+	}
+}
+
+// WithSourceInterface sends the probes via the given local interface.
+func WithSourceInterface(name string) CheckerOpts {
+	return func(s *source) error {
+		if name == "" {
+			return errors.New("empty source interface supplied")
+		}
+		if _, err := net.InterfaceByName(name); err != nil {
+			return fmt.Errorf("unknown source interface %q: %w", name, err)
+		}
+		s.iface = name
+		s.hasSource = true
+		return nil
+	}
+}
+
+func buildSource(target net.IP, opts []CheckerOpts) (source, error) {
+	var ret source
+	var errs []error
+	for _, opt := range opts {
+		if err := opt(&ret); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if ret.ip != nil && (ret.ip.To4() == nil) != (target.To4() == nil) {
+		errs = append(errs, fmt.Errorf("source ip %s and target %s are of different address families", ret.ip, target))
+	}
+
+	return ret, errors.Join(errs...)
+}
+
+func (s source) dialer() *net.Dialer {
+	dialer := &net.Dialer{}
+	if s.ip != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: s.ip}
+	}
+	if s.iface != "" {
+		dialer.Control = bindToInterface(s.iface)
+	}
+	return dialer
+}
+
+// dial connects to the address using the dialer, the source address is also applied to udp connections.
+func (s source) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := s.dialer()
+	if strings.HasPrefix(network, "udp") && s.ip != nil {
+		dialer.LocalAddr = &net.UDPAddr{IP: s.ip}
+	}
+	return dialer.DialContext(ctx, network, address)
+}
@@ -0,0 +1,18 @@
+package healthcheck
+
+import (
+	"syscall"
+)
+
+func bindToInterface(iface string) func(network, address string, conn syscall.RawConn) error {
+	return func(_, _ string, conn syscall.RawConn) error {
+		var bindErr error
+		err := conn.Control(func(fd uintptr) {
+			bindErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface)
+		})
+		if err != nil {
+			return err
+		}
+		return bindErr
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package healthcheck
+
+import (
+	"errors"
+	"syscall"
+)
+
+func bindToInterface(_ string) func(network, address string, conn syscall.RawConn) error {
+	return func(_, _ string, _ syscall.RawConn) error {
+		return errors.New("binding tcp and http healthchecks to an interface is only supported on linux")
+	}
+}
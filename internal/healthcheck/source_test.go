@@ -0,0 +1,48 @@
+package healthcheck
+
+import (
+	"net"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestTcpChecker_Source(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	sourceAddr := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		sourceAddr <- conn.RemoteAddr().(*net.TCPAddr).IP.String()
+		_ = conn.Close()
+	}()
+
+	record := internal.DnsRecord{Ip: net.ParseIP("127.0.0.1")}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	if _, err := NewTcpChecker(record, conf.TcpHealthcheckConfig{Port: port}, WithSourceIp(net.ParseIP("::1"))); err == nil {
+		t.Error("expected error for source ip of different address family")
+	}
+
+	checker, err := NewTcpChecker(record, conf.TcpHealthcheckConfig{Port: port}, WithSourceIp(net.ParseIP("127.0.0.2")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	healthy, err := checker.IsHealthy(t.Context())
+	if err != nil || !healthy {
+		t.Skipf("could not connect from 127.0.0.2 to port %d: %v", port, err)
+	}
+
+	if got := <-sourceAddr; got != "127.0.0.2" {
+		t.Errorf("expected connection from 127.0.0.2, got %s", got)
+	}
+}
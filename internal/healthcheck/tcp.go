@@ -1,7 +1,6 @@
 package healthcheck
 
 import (
-	"cmp"
 	"context"
 	"errors"
 	"fmt"
@@ -11,49 +10,46 @@ import (
 	"time"
 
 	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
 )
 
 const (
-	TcpCheckerName = "tcp"
+	TcpCheckerName = conf.TcpCheckerName
 	defaultTimeout = 5 * time.Second
 )
 
 type TcpChecker struct {
-	host    string
-	port    string
-	timeout time.Duration
+	host   string
+	port   string
+	source source
 }
 
-func NewTcpChecker(record internal.DnsRecord, args map[string]any) (*TcpChecker, error) {
-	portRaw, found := args["port"]
-	if !found {
+func NewTcpChecker(record internal.DnsRecord, args conf.TcpHealthcheckConfig, opts ...CheckerOpts) (*TcpChecker, error) {
+	if args.Port <= 0 {
 		return nil, errors.New("missing port in args")
 	}
 
-	_, err := strconv.Atoi(portRaw.(string))
+	source, err := buildSource(record.Ip, opts)
 	if err != nil {
-		return nil, errors.New("could not parse port as integer")
+		return nil, err
 	}
 
-	ret := &TcpChecker{
-		host: record.Ip.String(),
-		port: portRaw.(string),
-	}
+	return &TcpChecker{
+		host:   record.Address(),
+		port:   strconv.Itoa(args.Port),
+		source: source,
+	}, nil
+}
 
-	timeoutHuman, ok := args["timeout"].(string)
-	if ok {
-		timeout, err := time.ParseDuration(timeoutHuman)
-		if err != nil {
-			return nil, fmt.Errorf("timeout duration could not be parsed: %w", err)
-		}
-		ret.timeout = timeout
+func (c *TcpChecker) IsHealthy(ctx context.Context) (bool, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
 	}
 
-	return ret, nil
-}
-
-func (c *TcpChecker) IsHealthy(_ context.Context) (bool, error) {
-	conn, err := net.DialTimeout("tcp", net.JoinHostPort(c.host, c.port), cmp.Or(c.timeout, defaultTimeout))
+	dialer := c.source.dialer()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(c.host, c.port))
 	if err == nil && conn != nil {
 		defer conn.Close()
 		return true, nil
@@ -64,5 +60,9 @@ func (c *TcpChecker) IsHealthy(_ context.Context) (bool, error) {
 		return true, nil
 	}
 
+	// the source address is not assigned to this host
+	if errors.Is(err, syscall.EADDRNOTAVAIL) {
+		return false, fmt.Errorf("%w: %w", internal.ErrCheckMisconfigured, err)
+	}
 	return false, err
 }
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultHistorySize = 100
+
+// CheckResult is the outcome of a single healthcheck of a record.
+type CheckResult struct {
+	Timestamp time.Time     `json:"timestamp"`
+	Healthy   bool          `json:"healthy"`
+	Latency   time.Duration `json:"latency"`
+	Error     string        `json:"error,omitempty"`
+	// ErrorKind classifies the error, see ErrorKind.
+	ErrorKind string `json:"error_kind,omitempty"`
+	// Status is the state of the record after the result has been evaluated.
+	Status string `json:"status"`
+	// Injected is true if the failure has been simulated instead of running the check.
+	Injected bool `json:"injected,omitempty"`
+}
+
+// RecordHistory holds the latest check results of a single record, oldest first.
+type RecordHistory struct {
+	Ip      string        `json:"ip"`
+	Results []CheckResult `json:"results"`
+}
+
+// WithHistorySize sets the amount of check results that are kept for the record, zero disables the history.
+func WithHistorySize(size int) ManagedDnsRecordOpts {
+	return func(r *ManagedDnsRecord) error {
+		if size < 0 {
+			return errors.New("history size must not be negative")
+		}
+		r.history = newCheckHistory(size)
+		return nil
+	}
+}
+
+// History returns the latest check results of the record, oldest first.
+func (r *ManagedDnsRecord) History() []CheckResult {
+	return r.history.list()
+}
+
+// History returns the check results of all records of the hostname. The boolean is false if the hostname is not
+// managed. It's safe to call while Run is active.
+func (h *RecordManager) History(hostname string) ([]RecordHistory, bool) {
+	h.recordsMutex.RLock()
+	records, found := h.managedRecords[hostname]
+	h.recordsMutex.RUnlock()
+	if !found {
+		return nil, false
+	}
+
+	ret := make([]RecordHistory, 0, len(records))
+	for _, record := range records {
+		ret = append(ret, RecordHistory{
+			Ip:      record.Ip.String(),
+			Results: record.History(),
+		})
+	}
+	return ret, true
+}
+
+// checkHistory is a ring buffer of check results that is safe for concurrent use. A nil history discards all results.
+type checkHistory struct {
+	mutex   sync.Mutex
+	results []CheckResult
+	next    int
+	full    bool
+}
+
+func newCheckHistory(size int) *checkHistory {
+	return &checkHistory{results: make([]CheckResult, size)}
+}
+
+func (h *checkHistory) add(result CheckResult) {
+	if h == nil {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.results) == 0 {
+		return
+	}
+
+	h.results[h.next] = result
+	h.next = (h.next + 1) % len(h.results)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+func (h *checkHistory) list() []CheckResult {
+	if h == nil {
+		return nil
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if !h.full {
+		return append([]CheckResult{}, h.results[:h.next]...)
+	}
+	return append(append([]CheckResult{}, h.results[h.next:]...), h.results[:h.next]...)
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestCheckHistory(t *testing.T) {
+	result := func(i int) CheckResult {
+		return CheckResult{Timestamp: time.Unix(int64(i), 0)}
+	}
+
+	tests := []struct {
+		name  string
+		size  int
+		added int
+		want  []CheckResult
+	}{
+		{name: "empty", size: 3, added: 0, want: []CheckResult{}},
+		{name: "partially filled", size: 3, added: 2, want: []CheckResult{result(0), result(1)}},
+		{name: "wrapped", size: 3, added: 5, want: []CheckResult{result(2), result(3), result(4)}},
+		{name: "disabled", size: 0, added: 2, want: []CheckResult{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := newCheckHistory(tt.size)
+			for i := range tt.added {
+				history.add(result(i))
+			}
+			if got := history.list(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManagedDnsRecord_History(t *testing.T) {
+	healthcheck := &dummyHealthcheck{ret: true}
+	record, err := NewManagedDnsRecord("my.tld", DnsRecord{Ip: net.ParseIP("10.0.0.1")}, conf.StatusConfig{
+		HealthyStreak:          1,
+		UnhealthyStreak:        1,
+		InitialHealthyStreak:   1,
+		InitialUnhealthyStreak: 1,
+	}, healthcheck, WithHistorySize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, err := range []error{nil, errors.New("connection refused")} {
+		healthcheck.retErr = err
+		wg.Add(1)
+		record.Eval(t.Context(), wg)
+	}
+
+	history := record.History()
+	if len(history) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(history))
+	}
+	if !history[0].Healthy || history[0].Status != "healthy" {
+		t.Errorf("expected first result to be healthy, got %+v", history[0])
+	}
+	if history[1].Healthy || history[1].Error != "connection refused" || history[1].Status != "unhealthy" {
+		t.Errorf("expected second result to be unhealthy with error, got %+v", history[1])
+	}
+
+	m, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, map[string][]*ManagedDnsRecord{"my.tld": {record}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found := m.History("unknown.tld"); found {
+		t.Error("expected unknown hostname not to be found")
+	}
+	if records, found := m.History("my.tld"); !found || len(records) != 1 || records[0].Ip != "10.0.0.1" {
+		t.Errorf("unexpected history %v", records)
+	}
+}
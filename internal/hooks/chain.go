@@ -0,0 +1,84 @@
+package hooks
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// Hook is notified about changes of the published records and restarts of the service.
+type Hook interface {
+	PreUpdate(ctx context.Context, hostname string, oldIps, newIps []string) error
+	PostUpdate(ctx context.Context, hostname string, oldIps, newIps []string) error
+	PostRestart(ctx context.Context, hostnames []string) error
+}
+
+// OutageHook is optionally implemented by hooks that are notified about prolonged outages of a hostname.
+type OutageHook interface {
+	Outage(ctx context.Context, hostname string, since time.Time) error
+	OutageResolved(ctx context.Context, hostname string, since time.Time) error
+}
+
+// UpdateFailedHook is optionally implemented by hooks that are notified if an update announced by PreUpdate could not
+// be applied.
+type UpdateFailedHook interface {
+	UpdateFailed(ctx context.Context, hostname string, oldIps, newIps []string, err error) error
+}
+
+// Chain runs all hooks in order, a failing hook does not prevent the remaining hooks from running.
+type Chain []Hook
+
+func (c Chain) PreUpdate(ctx context.Context, hostname string, oldIps, newIps []string) error {
+	var errs error
+	for _, hook := range c {
+		errs = multierr.Append(errs, hook.PreUpdate(ctx, hostname, oldIps, newIps))
+	}
+	return errs
+}
+
+func (c Chain) PostUpdate(ctx context.Context, hostname string, oldIps, newIps []string) error {
+	var errs error
+	for _, hook := range c {
+		errs = multierr.Append(errs, hook.PostUpdate(ctx, hostname, oldIps, newIps))
+	}
+	return errs
+}
+
+func (c Chain) UpdateFailed(ctx context.Context, hostname string, oldIps, newIps []string, err error) error {
+	var errs error
+	for _, hook := range c {
+		if updateFailedHook, ok := hook.(UpdateFailedHook); ok {
+			errs = multierr.Append(errs, updateFailedHook.UpdateFailed(ctx, hostname, oldIps, newIps, err))
+		}
+	}
+	return errs
+}
+
+func (c Chain) PostRestart(ctx context.Context, hostnames []string) error {
+	var errs error
+	for _, hook := range c {
+		errs = multierr.Append(errs, hook.PostRestart(ctx, hostnames))
+	}
+	return errs
+}
+
+func (c Chain) Outage(ctx context.Context, hostname string, since time.Time) error {
+	var errs error
+	for _, hook := range c {
+		if outageHook, ok := hook.(OutageHook); ok {
+			errs = multierr.Append(errs, outageHook.Outage(ctx, hostname, since))
+		}
+	}
+	return errs
+}
+
+func (c Chain) OutageResolved(ctx context.Context, hostname string, since time.Time) error {
+	var errs error
+	for _, hook := range c {
+		if outageHook, ok := hook.(OutageHook); ok {
+			errs = multierr.Append(errs, outageHook.OutageResolved(ctx, hostname, since))
+		}
+	}
+	return errs
+}
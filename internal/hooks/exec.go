@@ -0,0 +1,128 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"go.uber.org/multierr"
+)
+
+const (
+	PreUpdateEvent      = "pre_update"
+	PostUpdateEvent     = "post_update"
+	UpdateFailedEvent   = "update_failed"
+	PostRestartEvent    = "post_restart"
+	OutageEvent         = "outage"
+	OutageResolvedEvent = "outage_resolved"
+
+	defaultTimeout = 30 * time.Second
+)
+
+// Exec runs the configured hook commands using "/bin/sh -c". Details about the change are passed to the commands
+// using DNS_HA_* environment variables.
+type Exec struct {
+	preUpdate   []string
+	postUpdate  []string
+	postRestart []string
+	outage      []string
+	timeout     time.Duration
+}
+
+func NewExec(conf conf.HooksConfig) (*Exec, error) {
+	timeout := defaultTimeout
+	if conf.Timeout > 0 {
+		timeout = conf.Timeout
+	}
+
+	for _, cmd := range slices.Concat(conf.PreUpdate, conf.PostUpdate, conf.PostRestart, conf.Outage) {
+		if strings.TrimSpace(cmd) == "" {
+			return nil, errors.New("empty hook command provided")
+		}
+	}
+
+	return &Exec{
+		preUpdate:   conf.PreUpdate,
+		postUpdate:  conf.PostUpdate,
+		postRestart: conf.PostRestart,
+		outage:      conf.Outage,
+		timeout:     timeout,
+	}, nil
+}
+
+func (e *Exec) PreUpdate(ctx context.Context, hostname string, oldIps, newIps []string) error {
+	return e.run(ctx, PreUpdateEvent, e.preUpdate, updateEnv(hostname, oldIps, newIps))
+}
+
+func (e *Exec) PostUpdate(ctx context.Context, hostname string, oldIps, newIps []string) error {
+	return e.run(ctx, PostUpdateEvent, e.postUpdate, updateEnv(hostname, oldIps, newIps))
+}
+
+// UpdateFailed runs the post_update commands if the update announced by PreUpdate could not be applied, so they can
+// undo the preparations of the pre_update commands.
+func (e *Exec) UpdateFailed(ctx context.Context, hostname string, oldIps, newIps []string, err error) error {
+	return e.run(ctx, UpdateFailedEvent, e.postUpdate, append(updateEnv(hostname, oldIps, newIps), "DNS_HA_ERROR="+err.Error()))
+}
+
+func (e *Exec) PostRestart(ctx context.Context, hostnames []string) error {
+	return e.run(ctx, PostRestartEvent, e.postRestart, []string{
+		"DNS_HA_HOSTNAMES=" + strings.Join(hostnames, ","),
+	})
+}
+
+func (e *Exec) Outage(ctx context.Context, hostname string, since time.Time) error {
+	return e.run(ctx, OutageEvent, e.outage, outageEnv(hostname, since))
+}
+
+func (e *Exec) OutageResolved(ctx context.Context, hostname string, since time.Time) error {
+	return e.run(ctx, OutageResolvedEvent, e.outage, outageEnv(hostname, since))
+}
+
+func outageEnv(hostname string, since time.Time) []string {
+	return []string{
+		"DNS_HA_HOSTNAME=" + hostname,
+		"DNS_HA_OUTAGE_SINCE=" + since.Format(time.RFC3339),
+	}
+}
+
+func updateEnv(hostname string, oldIps, newIps []string) []string {
+	return []string{
+		"DNS_HA_HOSTNAME=" + hostname,
+		"DNS_HA_OLD_IPS=" + strings.Join(oldIps, ","),
+		"DNS_HA_NEW_IPS=" + strings.Join(newIps, ","),
+	}
+}
+
+func (e *Exec) run(ctx context.Context, event string, commands []string, env []string) error {
+	var errs error
+	for _, command := range commands {
+		if err := e.runCommand(ctx, event, command, env); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (e *Exec) runCommand(ctx context.Context, event string, command string, env []string) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	slog.Debug("Running hook", "event", event, "cmd", command)
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command) //nolint G204
+	cmd.Env = append(os.Environ(), "DNS_HA_EVENT="+event)
+	cmd.Env = append(cmd.Env, env...)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s hook %q failed: %w: %s", event, command, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestExec_PostUpdate(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "env")
+	hooks, err := NewExec(conf.HooksConfig{
+		PostUpdate: []string{`echo "$DNS_HA_EVENT $DNS_HA_HOSTNAME $DNS_HA_OLD_IPS $DNS_HA_NEW_IPS" > ` + out},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hooks.PostUpdate(context.Background(), "my.tld", []string{"10.0.0.1"}, []string{"10.0.0.2", "::1"}); err != nil {
+		t.Fatalf("PostUpdate() unexpected error = %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "post_update my.tld 10.0.0.1 10.0.0.2,::1"
+	if got := strings.TrimSpace(string(data)); got != want {
+		t.Errorf("PostUpdate() got env %q, want %q", got, want)
+	}
+}
+
+func TestExec_Outage(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "env")
+	hooks, err := NewExec(conf.HooksConfig{
+		Outage: []string{`echo "$DNS_HA_EVENT $DNS_HA_HOSTNAME $DNS_HA_OUTAGE_SINCE" >> ` + out},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := hooks.Outage(context.Background(), "my.tld", since); err != nil {
+		t.Fatalf("Outage() unexpected error = %v", err)
+	}
+	if err := hooks.OutageResolved(context.Background(), "my.tld", since); err != nil {
+		t.Fatalf("OutageResolved() unexpected error = %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "outage my.tld 2024-05-01T12:00:00Z\noutage_resolved my.tld 2024-05-01T12:00:00Z"
+	if got := strings.TrimSpace(string(data)); got != want {
+		t.Errorf("Outage() got env %q, want %q", got, want)
+	}
+}
+
+func TestExec_FailingHook(t *testing.T) {
+	hooks, err := NewExec(conf.HooksConfig{
+		PostRestart: []string{"echo broken >&2; exit 1", "true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = hooks.PostRestart(context.Background(), []string{"my.tld"})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("PostRestart() expected error containing output, got %v", err)
+	}
+}
+
+func TestExec_UpdateFailed(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "env")
+	hooks, err := NewExec(conf.HooksConfig{
+		PostUpdate: []string{`echo "$DNS_HA_EVENT $DNS_HA_HOSTNAME $DNS_HA_NEW_IPS $DNS_HA_ERROR" > ` + out},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hooks.UpdateFailed(context.Background(), "my.tld", []string{"10.0.0.1"}, []string{"10.0.0.2"}, errors.New("unavailable")); err != nil {
+		t.Fatalf("UpdateFailed() unexpected error = %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "update_failed my.tld 10.0.0.2 unavailable"
+	if got := strings.TrimSpace(string(data)); got != want {
+		t.Errorf("UpdateFailed() got env %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,143 @@
+package hooks
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/dns/files"
+	"go.uber.org/multierr"
+)
+
+const DefaultBirdcBinary = "birdc"
+
+// Vip keeps the VIP of keepalived and the route announcements of ExaBGP and BIRD consistent with the DNS failover of
+// a hostname, so both failover mechanisms follow the same healthchecks. The node is active while its address is
+// published for the hostname.
+type Vip struct {
+	hostname     string
+	address      net.IP
+	trackFile    string
+	exabgpPipe   string
+	route        string
+	birdProtocol string
+	birdcBinary  string
+}
+
+func NewVip(conf conf.VipConfig) (*Vip, error) {
+	address := net.ParseIP(conf.Address)
+	if address == nil {
+		return nil, fmt.Errorf("could not parse %q as ip address", conf.Address)
+	}
+	if conf.KeepalivedTrackFile == "" && conf.ExabgpPipe == "" && conf.BirdProtocol == "" {
+		return nil, errors.New("no keepalived track file, exabgp pipe or bird protocol supplied")
+	}
+
+	route := conf.Route
+	if route == "" {
+		bits := 128
+		if address.To4() != nil {
+			bits = 32
+		}
+		route = fmt.Sprintf("%s/%d", address, bits)
+	}
+
+	return &Vip{
+		hostname:     conf.Hostname,
+		address:      address,
+		trackFile:    conf.KeepalivedTrackFile,
+		exabgpPipe:   conf.ExabgpPipe,
+		route:        route,
+		birdProtocol: conf.BirdProtocol,
+		birdcBinary:  cmp.Or(conf.BirdcBinary, DefaultBirdcBinary),
+	}, nil
+}
+
+func (v *Vip) PreUpdate(_ context.Context, _ string, _, _ []string) error {
+	return nil
+}
+
+func (v *Vip) PostUpdate(ctx context.Context, hostname string, _, newIps []string) error {
+	if hostname != v.hostname {
+		return nil
+	}
+
+	active := slices.ContainsFunc(newIps, func(ip string) bool {
+		return v.address.Equal(net.ParseIP(ip))
+	})
+	slog.Info("Updating VIP state", "hostname", hostname, "address", v.address, "active", active)
+
+	var errs error
+	if v.trackFile != "" {
+		errs = multierr.Append(errs, v.writeTrackFile(active))
+	}
+	if v.exabgpPipe != "" {
+		errs = multierr.Append(errs, v.announceRoute(active))
+	}
+	if v.birdProtocol != "" {
+		errs = multierr.Append(errs, v.toggleBirdProtocol(ctx, active))
+	}
+	return errs
+}
+
+func (v *Vip) PostRestart(_ context.Context, _ []string) error {
+	return nil
+}
+
+// writeTrackFile writes the status for keepalived's track_file, a non-zero value lowers the priority of the node by
+// the configured weight or puts it into fault state if the weight is 0.
+func (v *Vip) writeTrackFile(active bool) error {
+	status := "1\n"
+	if active {
+		status = "0\n"
+	}
+	if err := files.WriteAtomic(v.trackFile, []byte(status), 0644); err != nil { //nolint G306
+		return fmt.Errorf("could not write keepalived track file: %w", err)
+	}
+	return nil
+}
+
+// announceRoute sends the command to the ExaBGP API. The pipe is opened non-blocking, so a stopped ExaBGP does not
+// block the update.
+func (v *Vip) announceRoute(active bool) error {
+	command := "withdraw"
+	if active {
+		command = "announce"
+	}
+
+	pipe, err := os.OpenFile(v.exabgpPipe, os.O_WRONLY|os.O_APPEND|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return fmt.Errorf("could not open exabgp pipe: %w", err)
+	}
+	defer pipe.Close()
+
+	if _, err := fmt.Fprintf(pipe, "%s route %s next-hop self\n", command, v.route); err != nil {
+		return fmt.Errorf("could not write to exabgp pipe: %w", err)
+	}
+	return nil
+}
+
+func (v *Vip) toggleBirdProtocol(ctx context.Context, active bool) error {
+	command := "disable"
+	if active {
+		command = "enable"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, v.birdcBinary, command, v.birdProtocol).CombinedOutput() //nolint G204
+	if err != nil {
+		return fmt.Errorf("could not %s bird protocol %q: %w: %s", command, v.birdProtocol, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package hooks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestVip_PostUpdate(t *testing.T) {
+	dir := t.TempDir()
+	trackFile := filepath.Join(dir, "track")
+	pipe := filepath.Join(dir, "exabgp.in")
+	birdLog := filepath.Join(dir, "birdc.log")
+	birdc := filepath.Join(dir, "birdc")
+	if err := os.WriteFile(pipe, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(birdc, []byte("#!/bin/sh\necho \"$@\" >> "+birdLog+"\n"), 0700); err != nil { //nolint G306
+		t.Fatal(err)
+	}
+
+	vip, err := NewVip(conf.VipConfig{
+		Hostname:            "my.tld",
+		Address:             "10.0.0.1",
+		KeepalivedTrackFile: trackFile,
+		ExabgpPipe:          pipe,
+		BirdProtocol:        "anycast",
+		BirdcBinary:         birdc,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		hostname  string
+		newIps    []string
+		wantTrack string
+		wantPipe  string
+		wantBird  string
+	}{
+		{
+			name:      "active",
+			hostname:  "my.tld",
+			newIps:    []string{"10.0.0.1", "10.0.0.2"},
+			wantTrack: "0",
+			wantPipe:  "announce route 10.0.0.1/32 next-hop self",
+			wantBird:  "enable anycast",
+		},
+		{
+			name:      "other hostname",
+			hostname:  "other.tld",
+			newIps:    []string{"10.0.0.2"},
+			wantTrack: "0",
+			wantPipe:  "announce route 10.0.0.1/32 next-hop self",
+			wantBird:  "enable anycast",
+		},
+		{
+			name:      "inactive",
+			hostname:  "my.tld",
+			newIps:    []string{"10.0.0.2"},
+			wantTrack: "1",
+			wantPipe:  "announce route 10.0.0.1/32 next-hop self\nwithdraw route 10.0.0.1/32 next-hop self",
+			wantBird:  "enable anycast\ndisable anycast",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := vip.PostUpdate(context.Background(), tt.hostname, nil, tt.newIps); err != nil {
+				t.Fatalf("PostUpdate() unexpected error = %v", err)
+			}
+
+			for path, want := range map[string]string{trackFile: tt.wantTrack, pipe: tt.wantPipe, birdLog: tt.wantBird} {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := strings.TrimSpace(string(data)); got != want {
+					t.Errorf("%s = %q, want %q", filepath.Base(path), got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewVip(t *testing.T) {
+	if _, err := NewVip(conf.VipConfig{Hostname: "my.tld", Address: "10.0.0.1"}); err == nil {
+		t.Error("expected error without any target")
+	}
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+// incumbentCycles caps the amount of check cycles the incumbent selection is kept while its records have not produced
+// any health evidence, e.g. because their checks keep failing with errors.
+const incumbentCycles = 5
+
+// DnsDbReader is implemented by DnsDbs that can report the records that are currently published.
+type DnsDbReader interface {
+	PublishedIps(hostname string) ([]string, error)
+}
+
+// loadIncumbents reads the records that are published at startup, so they are kept until there is evidence that a
+// different selection is needed. This prevents writes and restarts right after dns-ha has been restarted.
+func (h *RecordManager) loadIncumbents() {
+	reader, ok := h.dnsDb.(DnsDbReader)
+	if !ok {
+		return
+	}
+
+	h.incumbentsUntil = h.clock.Now().Add(incumbentCycles * h.checkInterval)
+	for hostname := range h.managedRecords {
+		ips, err := reader.PublishedIps(hostname)
+		if err != nil {
+			slog.Warn("Could not read published records", "hostname", hostname, "err", err)
+			continue
+		}
+		if len(ips) == 0 {
+			continue
+		}
+
+		slices.Sort(ips)
+		slog.Info("Found published records", "hostname", hostname, "ips", ips)
+		h.publishedMutex.Lock()
+		h.publishedIps[hostname] = ips
+		h.publishedMutex.Unlock()
+	}
+}
+
+// keepIncumbents returns true while a published record of the hostname has not been checked conclusively yet.
+func (h *RecordManager) keepIncumbents(hostname string, ips []*ManagedDnsRecord) bool {
+	if h.incumbentsUntil.IsZero() || h.clock.Now().After(h.incumbentsUntil) {
+		return false
+	}
+
+	published := h.publishedIps[hostname]
+	for _, ip := range ips {
+		if status.Effective(ip.GetState()).Name() == status.InitialStateName && slices.Contains(published, ip.Ip.String()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestRecordManager_incumbents(t *testing.T) {
+	statusConf := conf.StatusConfig{
+		HealthyStreak:          1,
+		UnhealthyStreak:        1,
+		InitialHealthyStreak:   1,
+		InitialUnhealthyStreak: 1,
+	}
+
+	// the incumbent's checks fail with errors, so it stays in its initial state
+	incumbent, err := NewManagedDnsRecord("my.tld", DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, statusConf, &dummyHealthcheck{retErr: errors.New("timeout")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewManagedDnsRecord("my.tld", DnsRecord{Priority: 10, DnsType: "A", Ip: net.ParseIP("10.0.0.2"), Ttl: 60}, statusConf, &dummyHealthcheck{ret: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := &dummyDnsDb{published: map[string][]string{"my.tld": {"10.0.0.1"}}}
+	m, err := NewRecordManager(db, &dummyService{}, map[string][]*ManagedDnsRecord{"my.tld": {incumbent, other}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.loadIncumbents()
+	if published := m.published("my.tld"); !reflect.DeepEqual(published, []string{"10.0.0.1"}) {
+		t.Errorf("expected incumbent to be published, got %v", published)
+	}
+
+	m.CheckRecords(context.Background())
+	if want := []string{"A 10.0.0.1"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Fatalf("expected incumbent to be kept without evidence, got %v", db.updates)
+	}
+
+	m.incumbentsUntil = time.Now().Add(-time.Second)
+	m.CheckRecords(context.Background())
+	if want := []string{"A 10.0.0.2"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Errorf("expected healthy record after incumbent protection expired, got %v", db.updates["my.tld"])
+	}
+}
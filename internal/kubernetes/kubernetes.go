@@ -0,0 +1,245 @@
+package kubernetes
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	Group    = "dns-ha.soerenschneider.github.io"
+	Version  = "v1alpha1"
+	Resource = "manageddnsrecords"
+
+	defaultTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	defaultCaFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	retryInterval    = 5 * time.Second
+)
+
+// ManagedDnsRecordSpec is the spec of a ManagedDnsRecord custom resource. It uses the same field names as the config
+// file.
+type ManagedDnsRecordSpec struct {
+	Hostname            string              `yaml:"hostname"`
+	Records             []conf.RecordConfig `yaml:"records"`
+	conf.HostnameConfig `yaml:",inline"`
+}
+
+// ManagedDnsRecord is a custom resource that defines the records of a single hostname.
+type ManagedDnsRecord struct {
+	Namespace string
+	Name      string
+	Spec      ManagedDnsRecordSpec
+}
+
+type object struct {
+	Metadata struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Spec json.RawMessage `json:"spec"`
+}
+
+func (o object) key() string {
+	return o.Metadata.Namespace + "/" + o.Metadata.Name
+}
+
+// Watcher lists and watches ManagedDnsRecord resources using the Kubernetes API.
+type Watcher struct {
+	apiServer     string
+	tokenFile     string
+	namespace     string
+	labelSelector string
+	httpClient    *http.Client
+}
+
+// NewWatcher builds a watcher from the config. Unset values default to the in-cluster configuration.
+func NewWatcher(c conf.KubernetesConfig) (*Watcher, error) {
+	apiServer := c.ApiServer
+	if apiServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, errors.New("no kubernetes api server configured and not running in a cluster")
+		}
+		apiServer = "https://" + net.JoinHostPort(host, port)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	caFile := cmp.Or(c.CaFile, defaultCaFile)
+	if ca, err := os.ReadFile(caFile); err == nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %q", caFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	} else if c.CaFile != "" {
+		return nil, fmt.Errorf("could not read ca file: %w", err)
+	}
+
+	return &Watcher{
+		apiServer:     strings.TrimSuffix(apiServer, "/"),
+		tokenFile:     cmp.Or(c.TokenFile, defaultTokenFile),
+		namespace:     c.Namespace,
+		labelSelector: c.LabelSelector,
+		httpClient:    &http.Client{Transport: transport},
+	}, nil
+}
+
+// Watch calls onChange with all resources whenever a resource is added, modified or deleted. It blocks until the
+// context is canceled.
+func (w *Watcher) Watch(ctx context.Context, onChange func([]ManagedDnsRecord)) {
+	for {
+		err := w.listAndWatch(ctx, onChange)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Error("Watching kubernetes resources failed", "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+func (w *Watcher) listAndWatch(ctx context.Context, onChange func([]ManagedDnsRecord)) error {
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []object `json:"items"`
+	}
+
+	resp, err := w.get(ctx, url.Values{})
+	if err != nil {
+		return err
+	}
+	err = json.NewDecoder(resp.Body).Decode(&list)
+	_ = resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("could not decode list: %w", err)
+	}
+
+	objects := make(map[string]object, len(list.Items))
+	for _, item := range list.Items {
+		objects[item.key()] = item
+	}
+	onChange(toRecords(objects))
+
+	resp, err = w.get(ctx, url.Values{
+		"watch":               {"1"},
+		"resourceVersion":     {list.Metadata.ResourceVersion},
+		"allowWatchBookmarks": {"true"},
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for scanner.Scan() {
+		var event struct {
+			Type   string `json:"type"`
+			Object object `json:"object"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("could not decode watch event: %w", err)
+		}
+
+		switch event.Type {
+		case "ADDED", "MODIFIED":
+			objects[event.Object.key()] = event.Object
+		case "DELETED":
+			delete(objects, event.Object.key())
+		case "BOOKMARK":
+			continue
+		case "ERROR":
+			// usually the resource version is too old, start over with a fresh list
+			return fmt.Errorf("watch returned error: %s", scanner.Text())
+		}
+		onChange(toRecords(objects))
+	}
+
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func (w *Watcher) get(ctx context.Context, query url.Values) (*http.Response, error) {
+	path := fmt.Sprintf("/apis/%s/%s/%s", Group, Version, Resource)
+	if w.namespace != "" {
+		path = fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", Group, Version, w.namespace, Resource)
+	}
+	if w.labelSelector != "" {
+		query.Set("labelSelector", w.labelSelector)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.apiServer+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// the token is read on every request as it's rotated by the kubelet
+	if token, err := os.ReadFile(w.tokenFile); err == nil {
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("kubernetes api returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return resp, nil
+}
+
+// toRecords decodes the specs of all objects, invalid objects are skipped.
+func toRecords(objects map[string]object) []ManagedDnsRecord {
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	ret := make([]ManagedDnsRecord, 0, len(objects))
+	for _, key := range keys {
+		obj := objects[key]
+		record := ManagedDnsRecord{Namespace: obj.Metadata.Namespace, Name: obj.Metadata.Name}
+		// JSON is valid yaml, this way the custom yaml unmarshalling of the config types is used
+		if err := yaml.Unmarshal(obj.Spec, &record.Spec); err != nil {
+			slog.Error("Skipping invalid resource", "resource", key, "err", err)
+			continue
+		}
+		ret = append(ret, record)
+	}
+	return ret
+}
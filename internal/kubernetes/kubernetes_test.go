@@ -0,0 +1,67 @@
+package kubernetes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func resource(name, hostname string) string {
+	return fmt.Sprintf(`{"metadata": {"name": %q, "namespace": "dns"}, "spec": {"hostname": %q, "on_all_unhealthy": "remove", "records": [{"ip": "10.0.0.1", "type": "A", "prio": 10, "healthchecker": {"type": "tcp", "port": 22}}]}}`, name, hostname)
+}
+
+func TestWatcher_listAndWatch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /apis/dns-ha.soerenschneider.github.io/v1alpha1/namespaces/dns/manageddnsrecords", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected authorization header")
+		}
+		if r.URL.Query().Get("watch") == "" {
+			_, _ = fmt.Fprintf(w, `{"metadata": {"resourceVersion": "1"}, "items": [%s]}`, resource("a", "a.tld"))
+			return
+		}
+		if r.URL.Query().Get("resourceVersion") != "1" {
+			t.Errorf("expected watch to start at resource version 1")
+		}
+		_, _ = fmt.Fprintf(w, "{\"type\": \"ADDED\", \"object\": %s}\n", resource("b", "b.tld"))
+		_, _ = fmt.Fprintf(w, "{\"type\": \"BOOKMARK\", \"object\": {\"metadata\": {\"resourceVersion\": \"3\"}}}\n")
+		_, _ = fmt.Fprintf(w, "{\"type\": \"DELETED\", \"object\": %s}\n", resource("a", "a.tld"))
+		_, _ = fmt.Fprintf(w, "{\"type\": \"ADDED\", \"object\": {\"metadata\": {\"name\": \"c\", \"namespace\": \"dns\"}, \"spec\": {\"records\": \"invalid\"}}}\n")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	watcher, err := NewWatcher(conf.KubernetesConfig{
+		ApiServer: server.URL,
+		Namespace: "dns",
+		TokenFile: filepath.Join(t.TempDir(), "missing"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]string
+	err = watcher.listAndWatch(t.Context(), func(records []ManagedDnsRecord) {
+		var hostnames []string
+		for _, record := range records {
+			hostnames = append(hostnames, record.Spec.Hostname)
+			if record.Spec.OnAllUnhealthy != "remove" || record.Spec.Records[0].HealthcheckConfig.Tcp == nil {
+				t.Errorf("spec not decoded correctly: %+v", record.Spec)
+			}
+		}
+		got = append(got, hostnames)
+	})
+	if err != nil {
+		t.Fatalf("listAndWatch() error = %v", err)
+	}
+
+	want := [][]string{{"a.tld"}, {"a.tld", "b.tld"}, {"b.tld"}, {"b.tld"}}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("listAndWatch() got = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+// limitRecords enforces the MinRecords and MaxRecords of the hostname's policy on the selected records. Missing records
+// are filled up with the remaining records, healthy ones first, ordered by priority. Records in maintenance are never
+// added.
+func (h *RecordManager) limitRecords(hostname string, ips []*ManagedDnsRecord, selected []ManagedDnsRecord) []ManagedDnsRecord {
+	policy := h.hostnamePolicies[hostname]
+	if policy.MinRecords > 0 && len(selected) < policy.MinRecords {
+		selected = fillRecords(ips, selected, policy.MinRecords)
+		slog.Debug("Added records to publish the minimum amount of records", "hostname", hostname, "ips", sortedIps(selected))
+	}
+
+	if policy.MaxRecords > 0 && len(selected) > policy.MaxRecords {
+		selected = slices.Clone(selected)
+		slices.SortStableFunc(selected, PriorityComparator)
+		selected = selected[:policy.MaxRecords]
+	}
+
+	return selected
+}
+
+func fillRecords(ips []*ManagedDnsRecord, selected []ManagedDnsRecord, amount int) []ManagedDnsRecord {
+	var candidates []ManagedDnsRecord
+	for _, ip := range ips {
+		isSelected := slices.ContainsFunc(selected, func(record ManagedDnsRecord) bool {
+			return record.Ip.Equal(ip.Ip)
+		})
+		if !isSelected && !ip.InMaintenance() {
+			candidates = append(candidates, *ip)
+		}
+	}
+
+	isHealthy := func(record ManagedDnsRecord) int {
+		if status.Effective(record.GetState()).Name() == status.HealthyStateName {
+			return 0
+		}
+		return 1
+	}
+	slices.SortStableFunc(candidates, func(a, b ManagedDnsRecord) int {
+		if byHealth := isHealthy(a) - isHealthy(b); byHealth != 0 {
+			return byHealth
+		}
+		return PriorityComparator(a, b)
+	})
+
+	missing := min(amount-len(selected), len(candidates))
+	return append(slices.Clone(selected), candidates[:missing]...)
+}
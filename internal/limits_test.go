@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+func TestRecordManager_limitRecords(t *testing.T) {
+	newRecord := func(ip string, priority uint8, state status.State) *ManagedDnsRecord {
+		return &ManagedDnsRecord{DnsRecord: DnsRecord{Priority: priority, DnsType: "A", Ip: net.ParseIP(ip), Ttl: 60}, Hostname: "my.tld", status: state}
+	}
+	ips := []*ManagedDnsRecord{
+		newRecord("10.0.0.1", 40, &status.Healthy{}),
+		newRecord("10.0.0.2", 30, &status.Unhealthy{}),
+		newRecord("10.0.0.3", 20, &status.Healthy{}),
+		newRecord("10.0.0.4", 10, &status.Healthy{}),
+	}
+
+	tests := []struct {
+		name     string
+		policy   HostnamePolicy
+		selected []string
+		want     []string
+	}{
+		{
+			name:     "no limits",
+			selected: []string{"10.0.0.1"},
+			want:     []string{"10.0.0.1"},
+		},
+		{
+			name:     "healthy records are added first",
+			policy:   HostnamePolicy{MinRecords: 2},
+			selected: []string{"10.0.0.1"},
+			want:     []string{"10.0.0.1", "10.0.0.3"},
+		},
+		{
+			name:     "unhealthy records are added as last resort",
+			policy:   HostnamePolicy{MinRecords: 4},
+			selected: []string{"10.0.0.1", "10.0.0.3", "10.0.0.4"},
+			want:     []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"},
+		},
+		{
+			name:     "less records than minimum",
+			policy:   HostnamePolicy{MinRecords: 10},
+			selected: []string{"10.0.0.1"},
+			want:     []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"},
+		},
+		{
+			name:     "records with the highest priority are kept",
+			policy:   HostnamePolicy{MaxRecords: 2},
+			selected: []string{"10.0.0.4", "10.0.0.3", "10.0.0.1"},
+			want:     []string{"10.0.0.1", "10.0.0.3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &RecordManager{hostnamePolicies: map[string]HostnamePolicy{"my.tld": tt.policy}}
+			var selected []ManagedDnsRecord
+			for _, ip := range ips {
+				if slices.Contains(tt.selected, ip.Ip.String()) {
+					selected = append(selected, *ip)
+				}
+			}
+
+			if got := sortedIps(m.limitRecords("my.tld", ips, selected)); !slices.Equal(got, tt.want) {
+				t.Errorf("limitRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+const maxTransitions = 10
+
+var (
+	ErrUnknownHostname = errors.New("hostname not managed")
+	ErrUnknownRecord   = errors.New("record not managed")
+)
+
+// Transition is a change of a record's state.
+type Transition struct {
+	Timestamp time.Time `json:"timestamp"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+}
+
+// RecordStatus is a snapshot of the state of a record.
+type RecordStatus struct {
+	Ip       string `json:"ip"`
+	DnsType  string `json:"type"`
+	Priority uint8  `json:"priority"`
+	Status   string `json:"status"`
+	// EffectiveStatus is the status the record is treated as, a record in the error state keeps its previous status.
+	EffectiveStatus  string       `json:"effective_status"`
+	Streak           int          `json:"streak"`
+	Maintenance      bool         `json:"maintenance"`
+	InjectedUntil    *time.Time   `json:"injected_until,omitempty"`
+	LastStatusChange time.Time    `json:"last_status_change"`
+	Transitions      []Transition `json:"transitions"`
+	LastError        string       `json:"last_error,omitempty"`
+	LastErrorKind    string       `json:"last_error_kind,omitempty"`
+	LastErrorTime    *time.Time   `json:"last_error_time,omitempty"`
+}
+
+// HostnameStatus is a snapshot of the state of all records of a hostname.
+type HostnameStatus struct {
+	Hostname  string         `json:"hostname"`
+	Published []string       `json:"published"`
+	Records   []RecordStatus `json:"records"`
+}
+
+// sharedState holds the parts of a record's state that are read and written outside of Run, e.g. by the API. A nil
+// sharedState is never in maintenance and discards all updates.
+type sharedState struct {
+	mutex            sync.Mutex
+	status           string
+	effectiveStatus  string
+	streak           int
+	lastStatusChange time.Time
+	transitions      []Transition
+	maintenance      bool
+	// injectedUntil is the point in time simulated check failures stop being injected.
+	injectedUntil time.Time
+	// lastError is the most recent healthcheck error, it is kept after the record recovers.
+	lastError     string
+	lastErrorKind string
+	lastErrorTime time.Time
+}
+
+func (s *sharedState) update(state status.State) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.status = state.Name()
+	s.effectiveStatus = status.Effective(state).Name()
+	s.streak = state.Streak()
+}
+
+func (s *sharedState) setLastError(msg, kind string, timestamp time.Time) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastError = msg
+	s.lastErrorKind = kind
+	s.lastErrorTime = timestamp
+}
+
+func (s *sharedState) addTransition(transition Transition) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastStatusChange = transition.Timestamp
+	s.transitions = append(s.transitions, transition)
+	if len(s.transitions) > maxTransitions {
+		s.transitions = slices.Delete(s.transitions, 0, len(s.transitions)-maxTransitions)
+	}
+}
+
+// InMaintenance returns true if the record has been taken out of the selection manually.
+func (r *ManagedDnsRecord) InMaintenance() bool {
+	if r.shared == nil {
+		return false
+	}
+
+	r.shared.mutex.Lock()
+	defer r.shared.mutex.Unlock()
+	return r.shared.maintenance
+}
+
+func (r *ManagedDnsRecord) setMaintenance(enabled bool) error {
+	if r.shared == nil {
+		return errors.New("record does not support maintenance")
+	}
+
+	r.shared.mutex.Lock()
+	r.shared.maintenance = enabled
+	r.shared.mutex.Unlock()
+
+	val := 0.
+	if enabled {
+		val = 1
+	}
+	metrics.Maintenance.WithLabelValues(r.Hostname, r.Ip.String()).Set(val)
+	return nil
+}
+
+// Status returns a snapshot of the record's state.
+func (r *ManagedDnsRecord) Status() RecordStatus {
+	ret := RecordStatus{
+		Ip:       r.Ip.String(),
+		DnsType:  r.DnsType,
+		Priority: r.Priority,
+	}
+	if r.shared == nil {
+		return ret
+	}
+
+	r.shared.mutex.Lock()
+	defer r.shared.mutex.Unlock()
+	ret.Status = r.shared.status
+	ret.EffectiveStatus = r.shared.effectiveStatus
+	ret.Streak = r.shared.streak
+	ret.Maintenance = r.shared.maintenance
+	if r.Now().Before(r.shared.injectedUntil) {
+		until := r.shared.injectedUntil
+		ret.InjectedUntil = &until
+	}
+	ret.LastStatusChange = r.shared.lastStatusChange
+	ret.Transitions = slices.Clone(r.shared.transitions)
+	if r.shared.lastError != "" {
+		ret.LastError = r.shared.lastError
+		ret.LastErrorKind = r.shared.lastErrorKind
+		lastErrorTime := r.shared.lastErrorTime
+		ret.LastErrorTime = &lastErrorTime
+	}
+	return ret
+}
+
+// Status returns a snapshot of all hostnames, sorted by name. It's safe to call while Run is active.
+func (h *RecordManager) Status() []HostnameStatus {
+	h.recordsMutex.RLock()
+	managedRecords := h.managedRecords
+	h.recordsMutex.RUnlock()
+
+	ret := make([]HostnameStatus, 0, len(managedRecords))
+	for hostname, records := range managedRecords {
+		hostnameStatus := HostnameStatus{
+			Hostname:  hostname,
+			Published: h.published(hostname),
+			Records:   make([]RecordStatus, 0, len(records)),
+		}
+		for _, record := range records {
+			hostnameStatus.Records = append(hostnameStatus.Records, record.Status())
+		}
+		ret = append(ret, hostnameStatus)
+	}
+
+	slices.SortFunc(ret, func(a, b HostnameStatus) int {
+		return strings.Compare(a.Hostname, b.Hostname)
+	})
+	return ret
+}
+
+// SetMaintenance takes a record out of the selection or puts it back and applies the change immediately. Records in
+// maintenance keep being checked, but are never published while another record of the hostname is healthy.
+func (h *RecordManager) SetMaintenance(hostname, ip string, enabled bool) error {
+	record, err := h.findRecord(hostname, ip)
+	if err != nil {
+		return err
+	}
+
+	if err := record.setMaintenance(enabled); err != nil {
+		return err
+	}
+	slog.Info("Changed maintenance mode", "hostname", hostname, "ip", ip, "enabled", enabled)
+	h.RequestReconcile()
+	return nil
+}
+
+// Failover puts all currently published records of the hostname into maintenance, so the next healthy records take
+// over. It returns the records that have been put into maintenance.
+func (h *RecordManager) Failover(hostname string) ([]string, error) {
+	published := h.published(hostname)
+	if len(published) == 0 {
+		return nil, fmt.Errorf("no records published for hostname %q", hostname)
+	}
+
+	for _, ip := range published {
+		if err := h.SetMaintenance(hostname, ip, true); err != nil {
+			return nil, err
+		}
+	}
+	return published, nil
+}
+
+func (h *RecordManager) findRecord(hostname, ip string) (*ManagedDnsRecord, error) {
+	h.recordsMutex.RLock()
+	defer h.recordsMutex.RUnlock()
+
+	records, found := h.managedRecords[hostname]
+	if !found {
+		return nil, ErrUnknownHostname
+	}
+
+	for _, record := range records {
+		if record.Ip.String() == ip {
+			return record, nil
+		}
+	}
+	return nil, ErrUnknownRecord
+}
+
+func (h *RecordManager) published(hostname string) []string {
+	h.publishedMutex.RLock()
+	defer h.publishedMutex.RUnlock()
+	return slices.Clone(h.publishedIps[hostname])
+}
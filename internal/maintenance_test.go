@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestRecordManager_Maintenance(t *testing.T) {
+	newRecord := func(ip string, prio uint8) *ManagedDnsRecord {
+		record, err := NewManagedDnsRecord("my.tld", DnsRecord{Priority: prio, DnsType: "A", Ip: net.ParseIP(ip), Ttl: 60}, conf.StatusConfig{
+			HealthyStreak:          1,
+			UnhealthyStreak:        1,
+			InitialHealthyStreak:   1,
+			InitialUnhealthyStreak: 1,
+		}, &dummyHealthcheck{ret: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record
+	}
+
+	db := &dummyDnsDb{}
+	m, err := NewRecordManager(db, &dummyService{}, map[string][]*ManagedDnsRecord{
+		"my.tld": {newRecord("10.0.0.1", 20), newRecord("10.0.0.2", 10)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.CheckRecords(context.Background())
+	if want := []string{"A 10.0.0.1"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Fatalf("got %v, want %v", db.updates["my.tld"], want)
+	}
+
+	failedOver, err := m.Failover("my.tld")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(failedOver, []string{"10.0.0.1"}) {
+		t.Errorf("unexpected records put into maintenance %v", failedOver)
+	}
+
+	m.applyRecords(context.Background())
+	if want := []string{"A 10.0.0.2"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Errorf("got %v, want %v", db.updates["my.tld"], want)
+	}
+
+	hostnames := m.Status()
+	if len(hostnames) != 1 || !hostnames[0].Records[0].Maintenance || hostnames[0].Records[0].Status != "healthy" {
+		t.Errorf("unexpected status %+v", hostnames)
+	}
+
+	if err := m.SetMaintenance("my.tld", "10.0.0.1", false); err != nil {
+		t.Fatal(err)
+	}
+	m.applyRecords(context.Background())
+	if want := []string{"A 10.0.0.1"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Errorf("got %v, want %v", db.updates["my.tld"], want)
+	}
+
+	if err := m.SetMaintenance("my.tld", "10.0.0.3", true); !errors.Is(err, ErrUnknownRecord) {
+		t.Errorf("expected ErrUnknownRecord, got %v", err)
+	}
+	if _, err := m.Failover("other.tld"); err == nil {
+		t.Error("expected error for unknown hostname")
+	}
+}
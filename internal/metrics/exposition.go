@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// exposition controls how the metrics of dns-ha are exposed. It's configured once before any metrics are exposed.
+var exposition = struct {
+	namespace string
+	labels    []*dto.LabelPair
+}{namespace: namespace}
+
+// SetExposition replaces the namespace of the dns-ha metrics and adds constant labels to them, so the samples of
+// several instances can be told apart, e.g. after federation. Labels of the metrics themselves take precedence. It
+// must be called before the metrics are exposed.
+func SetExposition(ns string, labels map[string]string) error {
+	var errs []error
+	if ns != "" && !namePattern.MatchString(ns) {
+		errs = append(errs, fmt.Errorf("invalid metrics namespace %q", ns))
+	}
+
+	var pairs []*dto.LabelPair
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		if !namePattern.MatchString(name) || strings.HasPrefix(name, "__") {
+			errs = append(errs, fmt.Errorf("invalid metrics label name %q", name))
+			continue
+		}
+		pairs = append(pairs, &dto.LabelPair{Name: proto.String(name), Value: proto.String(labels[name])})
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if ns != "" {
+		exposition.namespace = ns
+	}
+	exposition.labels = pairs
+	return nil
+}
+
+// gatherExposed returns all metrics, the metrics of dns-ha are renamed and labeled according to the exposition.
+func gatherExposed() ([]*dto.MetricFamily, error) {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+	return expose(families), nil
+}
+
+func expose(families []*dto.MetricFamily) []*dto.MetricFamily {
+	for _, family := range families {
+		name, own := strings.CutPrefix(family.GetName(), namespace+"_")
+		if !own {
+			continue
+		}
+
+		family.Name = proto.String(exposition.namespace + "_" + name)
+		for _, metric := range family.GetMetric() {
+			for _, pair := range exposition.labels {
+				if !slices.ContainsFunc(metric.GetLabel(), func(l *dto.LabelPair) bool { return l.GetName() == pair.GetName() }) {
+					metric.Label = append(metric.Label, pair)
+				}
+			}
+			slices.SortFunc(metric.Label, func(a, b *dto.LabelPair) int {
+				return strings.Compare(a.GetName(), b.GetName())
+			})
+		}
+	}
+	return families
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestExpose(t *testing.T) {
+	previous := exposition
+	t.Cleanup(func() {
+		exposition = previous
+	})
+
+	if err := SetExposition("site_a", map[string]string{"site": "ber", "hostname": "ignored"}); err != nil {
+		t.Fatal(err)
+	}
+
+	families := []*dto.MetricFamily{
+		{
+			Name: proto.String("dns_ha_active_record"),
+			Metric: []*dto.Metric{{
+				Label: []*dto.LabelPair{{Name: proto.String("hostname"), Value: proto.String("my.tld")}},
+			}},
+		},
+		{
+			Name:   proto.String("go_goroutines"),
+			Metric: []*dto.Metric{{}},
+		},
+	}
+	expose(families)
+
+	if got := families[0].GetName(); got != "site_a_active_record" {
+		t.Errorf("expected renamed metric, got %q", got)
+	}
+	got := map[string]string{}
+	for _, label := range families[0].GetMetric()[0].GetLabel() {
+		got[label.GetName()] = label.GetValue()
+	}
+	if len(got) != 2 || got["site"] != "ber" || got["hostname"] != "my.tld" {
+		t.Errorf("unexpected labels %v", got)
+	}
+
+	if families[1].GetName() != "go_goroutines" || len(families[1].GetMetric()[0].GetLabel()) != 0 {
+		t.Errorf("expected foreign metrics to be left untouched, got %v", families[1])
+	}
+}
+
+func TestSetExposition_invalid(t *testing.T) {
+	if err := SetExposition("site-a", nil); err == nil {
+		t.Error("expected error for invalid namespace")
+	}
+	if err := SetExposition("", map[string]string{"__name__": "x"}); err == nil {
+		t.Error("expected error for reserved label name")
+	}
+}
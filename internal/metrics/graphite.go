@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var graphiteReplacer = strings.NewReplacer(";", "_", "~", "_", " ", "_", "\n", "_")
+
+// GraphiteSink sends the metrics using Graphite's plaintext protocol, labels are sent as Graphite tags.
+type GraphiteSink struct {
+	address string
+	prefix  string
+	dialer  net.Dialer
+}
+
+func NewGraphiteSink(address, prefix string) (*GraphiteSink, error) {
+	if address == "" {
+		return nil, errors.New("empty graphite address supplied")
+	}
+
+	return &GraphiteSink{
+		address: address,
+		prefix:  prefix,
+		dialer:  net.Dialer{Timeout: pushTimeout},
+	}, nil
+}
+
+func (g *GraphiteSink) Emit(ctx context.Context, families []*dto.MetricFamily, now time.Time) error {
+	conn, err := g.dialer.DialContext(ctx, "tcp", g.address)
+	if err != nil {
+		return fmt.Errorf("could not connect to graphite: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetWriteDeadline(deadline)
+	}
+
+	var buf strings.Builder
+	for _, s := range flatten(families, now) {
+		buf.WriteString(g.formatLine(s))
+	}
+
+	_, err = conn.Write([]byte(buf.String()))
+	return err
+}
+
+// formatLine returns the series as "<path>;<tag>=<value> <value> <timestamp>".
+func (g *GraphiteSink) formatLine(s series) string {
+	path := s.name
+	if g.prefix != "" {
+		path = g.prefix + "." + path
+	}
+
+	var buf strings.Builder
+	buf.WriteString(graphiteReplacer.Replace(path))
+	for _, name := range slices.Sorted(maps.Keys(s.labels)) {
+		// graphite does not accept empty tag values
+		if s.labels[name] == "" {
+			continue
+		}
+		buf.WriteString(";" + graphiteReplacer.Replace(name) + "=" + graphiteReplacer.Replace(s.labels[name]))
+	}
+	buf.WriteString(" " + strconv.FormatFloat(s.value, 'f', -1, 64))
+	buf.WriteString(" " + strconv.FormatInt(s.timestamp.Unix(), 10) + "\n")
+	return buf.String()
+}
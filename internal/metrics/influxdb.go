@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	influxMeasurementReplacer = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", "")
+	influxTagReplacer         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`, "\n", "")
+)
+
+// InfluxDbSink writes the metrics using InfluxDB's line protocol. Each metric is written as a measurement with a
+// single field called value.
+type InfluxDbSink struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+// NewInfluxDbSink writes to the given url, e.g. "http://influxdb:8086/api/v2/write?org=example&bucket=dns-ha". The
+// token is sent as authorization header if it's not empty.
+func NewInfluxDbSink(url, token string) (*InfluxDbSink, error) {
+	if url == "" {
+		return nil, errors.New("empty influxdb url supplied")
+	}
+
+	return &InfluxDbSink{
+		url:   url,
+		token: token,
+		httpClient: &http.Client{
+			Timeout: pushTimeout,
+		},
+	}, nil
+}
+
+func (i *InfluxDbSink) Emit(ctx context.Context, families []*dto.MetricFamily, now time.Time) error {
+	var buf bytes.Buffer
+	for _, s := range flatten(families, now) {
+		buf.WriteString(formatInfluxLine(s))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.url, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if i.token != "" {
+		req.Header.Set("Authorization", "Token "+i.token)
+	}
+
+	resp, err := i.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("influxdb returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// formatInfluxLine returns the series as "<measurement>,<tag>=<value> value=<value> <timestamp>".
+func formatInfluxLine(s series) string {
+	var buf strings.Builder
+	buf.WriteString(influxMeasurementReplacer.Replace(s.name))
+	for _, name := range slices.Sorted(maps.Keys(s.labels)) {
+		// influxdb does not accept empty tag values
+		if s.labels[name] == "" {
+			continue
+		}
+		buf.WriteString("," + influxTagReplacer.Replace(name) + "=" + influxTagReplacer.Replace(s.labels[name]))
+	}
+	buf.WriteString(" value=" + strconv.FormatFloat(s.value, 'g', -1, 64))
+	buf.WriteString(" " + strconv.FormatInt(s.timestamp.UnixNano(), 10) + "\n")
+	return buf.String()
+}
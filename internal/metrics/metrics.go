@@ -3,6 +3,7 @@ package metrics
 import (
 	"bytes"
 	"context"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -22,6 +23,9 @@ import (
 const (
 	namespace                        = "dns_ha"
 	defaultMetricsHeartbeatFrequency = 1 * time.Minute
+
+	// maxErrorLabelLength limits the length of error messages used as label values
+	maxErrorLabelLength = 200
 )
 
 var (
@@ -41,7 +45,7 @@ var (
 		Namespace: namespace,
 		Name:      "errors_total",
 		Help:      "Total amount of errors",
-	}, []string{"hostname", "error"})
+	}, []string{"hostname", "error", "kind"})
 
 	Status = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace:   namespace,
@@ -50,6 +54,102 @@ var (
 		ConstLabels: nil,
 	}, []string{"hostname", "ip", "status"})
 
+	Restarts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "service_restarts_total",
+		Help:      "Total amount of service restarts",
+	})
+
+	BackendAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "backend_attempts_total",
+		Help:      "Total amount of attempts to update the DNS backend or to reload or restart the service",
+	}, []string{"operation"})
+
+	BackendFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "backend_failures_total",
+		Help:      "Total amount of operations on the DNS backend or the service that failed after all attempts",
+	}, []string{"operation"})
+
+	DriftRepairs = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "drift_repairs_total",
+		Help:      "Total amount of updates that restored records which deviated from the desired state",
+	})
+
+	RestartsSuppressed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "service_restarts_suppressed_total",
+		Help:      "Total amount of service restarts that have been coalesced or delayed due to rate limiting",
+	})
+
+	FallbackActive = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "fallback_active",
+		Help:      "Whether the given policy is applied because no record of the hostname is healthy",
+	}, []string{"hostname", "policy"})
+
+	Outage = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "outage",
+		Help:      "Whether the hostname has had no healthy records for longer than its escalation threshold",
+	}, []string{"hostname"})
+
+	Blackout = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "blackout",
+		Help:      "Whether a blackout window of the hostname is active, its published records are not changed",
+	}, []string{"hostname"})
+
+	ChecksSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "checks_skipped_total",
+		Help:      "Total amount of healthchecks skipped due to backoff",
+	}, []string{"hostname", "ip"})
+
+	ChecksDeduplicated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "checks_deduplicated_total",
+		Help:      "Total amount of healthchecks that reused the result of an identical check of the same cycle",
+	}, []string{"hostname", "ip"})
+
+	HealthGroupChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "health_group_checks_total",
+		Help:      "Total amount of healthchecks run on behalf of all records of a health group",
+	}, []string{"group"})
+
+	InjectedFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "injected_failures_total",
+		Help:      "Total amount of simulated check failures that have been injected instead of running the check",
+	}, []string{"hostname", "ip"})
+
+	GuardEngaged = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "guard_engaged",
+		Help:      "Whether the local connectivity check fails and all decisions are suspended",
+	})
+
+	GuardErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "guard_errors_total",
+		Help:      "Total amount of local connectivity checks that produced an error",
+	})
+
+	CheckErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "check_errors_total",
+		Help:      "Total amount of healthchecks that produced an error instead of a result",
+	}, []string{"hostname", "ip", "kind"})
+
+	LastCheckError = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_check_error_info",
+		Help:      "The most recent error a healthcheck of the record produced",
+	}, []string{"hostname", "ip", "error"})
+
 	StatusChangeTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace:   namespace,
 		Name:        "status_change_timestamp_seconds",
@@ -71,6 +171,18 @@ var (
 		ConstLabels: nil,
 	}, []string{"hostname"})
 
+	Maintenance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "maintenance",
+		Help:      "Whether the record has been taken out of the selection manually",
+	}, []string{"hostname", "ip"})
+
+	SelectionStrategy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "selection_strategy",
+		Help:      "The strategy that selects the published records among the healthy records of the hostname",
+	}, []string{"hostname", "strategy"})
+
 	ConfiguredRecords = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace:   namespace,
 		Name:        "configured_records_total",
@@ -86,6 +198,16 @@ func init() {
 
 type MetricsServer struct {
 	address string
+
+	certReloader      *certReloader
+	clientCas         *x509.CertPool
+	basicAuthUser     string
+	basicAuthPassword string
+	bearerTokens      []string
+	allowedClientCns  []string
+	limiter           *rateLimiter
+
+	handlers map[string]http.Handler
 }
 
 type MetricsServerOpts func(*MetricsServer) error
@@ -109,14 +231,38 @@ func New(address string, opts ...MetricsServerOpts) (*MetricsServer, error) {
 	return w, errs
 }
 
+// WithHandler serves an additional handler next to the metrics, protected by the same TLS, auth and rate limits.
+func WithHandler(pattern string, handler http.Handler) MetricsServerOpts {
+	return func(s *MetricsServer) error {
+		if pattern == "" || handler == nil {
+			return errors.New("handler requires pattern and handler")
+		}
+		if s.handlers == nil {
+			s.handlers = map[string]http.Handler{}
+		}
+		s.handlers[pattern] = handler
+		return nil
+	}
+}
+
 func (s *MetricsServer) StartServer(ctx context.Context, wg *sync.WaitGroup) error {
 	defer wg.Done()
 
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.Handler())
+	handler := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(prometheus.GathererFunc(gatherExposed), promhttp.HandlerOpts{}))
+	mux.Handle("/metrics", s.withRateLimit(s.withAuth(handler)))
+	for pattern, handler := range s.handlers {
+		mux.Handle(pattern, s.withRateLimit(s.withAuth(handler)))
+	}
 	server := http.Server{
 		Addr:              s.address,
 		Handler:           mux,
+		TLSConfig:         tlsConfig,
 		ReadTimeout:       1 * time.Second,
 		ReadHeaderTimeout: 1 * time.Second,
 		WriteTimeout:      1 * time.Second,
@@ -125,8 +271,14 @@ func (s *MetricsServer) StartServer(ctx context.Context, wg *sync.WaitGroup) err
 
 	errChan := make(chan error)
 	go func() {
-		slog.Info("Starting server", "address", s.address)
-		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Info("Starting server", "address", s.address, "tls", tlsConfig != nil)
+		var err error
+		if tlsConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			errChan <- fmt.Errorf("can not start metrics server: %w", err)
 		}
 	}()
@@ -147,9 +299,25 @@ func (s *MetricsServer) StartServer(ctx context.Context, wg *sync.WaitGroup) err
 	}
 }
 
-func StartMetricsWriter(ctx context.Context, wg *sync.WaitGroup, path string) {
+// changes signals the metrics writer that records changed their state or have been published.
+var changes = make(chan struct{}, 1)
+
+// NotifyChange makes the metrics writer write the metrics file right away instead of at the next interval, so
+// failovers become visible without delay. It never blocks, pending notifications are coalesced.
+func NotifyChange() {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
+
+// StartMetricsWriter writes the metrics file once every interval and whenever NotifyChange is called.
+func StartMetricsWriter(ctx context.Context, wg *sync.WaitGroup, path string, interval time.Duration) {
 	defer wg.Done()
-	ticker := time.NewTicker(defaultMetricsHeartbeatFrequency)
+	if interval <= 0 {
+		interval = defaultMetricsHeartbeatFrequency
+	}
+	ticker := time.NewTicker(interval)
 
 	for {
 		select {
@@ -158,8 +326,16 @@ func StartMetricsWriter(ctx context.Context, wg *sync.WaitGroup, path string) {
 			if err := WriteMetrics(path); err != nil {
 				slog.Error("Error dumping metrics", "err", err)
 			}
+		case <-changes:
+			if err := WriteMetrics(path); err != nil {
+				slog.Error("Error dumping metrics", "err", err)
+			}
 		case <-ctx.Done():
 			ticker.Stop()
+			// the file outlives the process, it must not keep the series that have been removed during shutdown
+			if err := WriteMetrics(path); err != nil {
+				slog.Error("Error dumping metrics", "err", err)
+			}
 			return
 		}
 	}
@@ -183,19 +359,67 @@ func dumpMetrics() (string, error) {
 	fmt := expfmt.NewFormat(expfmt.TypeTextPlain)
 	enc := expfmt.NewEncoder(buf, fmt)
 
-	families, err := prometheus.DefaultGatherer.Gather()
+	// Writing other metrics will cause a duplication error with other tools writing the same metrics
+	families, err := gatherOwn()
 	if err != nil {
 		return "", err
 	}
 
 	for _, f := range families {
-		// Writing these metrics will cause a duplication error with other tools writing the same metrics
-		if strings.HasPrefix(f.GetName(), namespace) {
-			if err := enc.Encode(f); err != nil {
-				slog.Warn("could not encode metric", "err", err.Error())
-			}
+		if err := enc.Encode(f); err != nil {
+			slog.Warn("could not encode metric", "err", err.Error())
 		}
 	}
 
 	return buf.String(), nil
 }
+
+// DeleteHostname removes all series of a hostname that is not managed anymore.
+func DeleteHostname(hostname string) {
+	labels := prometheus.Labels{"hostname": hostname}
+	Status.DeletePartialMatch(labels)
+	FallbackActive.DeletePartialMatch(labels)
+	Outage.DeletePartialMatch(labels)
+	Blackout.DeletePartialMatch(labels)
+	ChecksSkipped.DeletePartialMatch(labels)
+	InjectedFailures.DeletePartialMatch(labels)
+	ChecksDeduplicated.DeletePartialMatch(labels)
+	CheckErrors.DeletePartialMatch(labels)
+	LastCheckError.DeletePartialMatch(labels)
+	StatusChangeTimestamp.DeletePartialMatch(labels)
+	ActiveRecord.DeletePartialMatch(labels)
+	ActiveRecords.DeletePartialMatch(labels)
+	ConfiguredRecords.DeletePartialMatch(labels)
+	Maintenance.DeletePartialMatch(labels)
+	SelectionStrategy.DeletePartialMatch(labels)
+}
+
+// DeleteRecord removes the series of a record that is not managed anymore while its hostname still is.
+func DeleteRecord(hostname, ip string) {
+	labels := prometheus.Labels{"hostname": hostname, "ip": ip}
+	Status.DeletePartialMatch(labels)
+	ChecksSkipped.DeletePartialMatch(labels)
+	InjectedFailures.DeletePartialMatch(labels)
+	ChecksDeduplicated.DeletePartialMatch(labels)
+	CheckErrors.DeletePartialMatch(labels)
+	LastCheckError.DeletePartialMatch(labels)
+	StatusChangeTimestamp.DeletePartialMatch(labels)
+	ActiveRecord.DeletePartialMatch(labels)
+	Maintenance.DeletePartialMatch(labels)
+}
+
+// SetSelectionStrategy exposes the selection strategy of the hostname, replacing the series of its previous strategy.
+func SetSelectionStrategy(hostname, strategy string) {
+	SelectionStrategy.DeletePartialMatch(prometheus.Labels{"hostname": hostname})
+	SelectionStrategy.WithLabelValues(hostname, strategy).Set(1)
+}
+
+// SetLastCheckError exposes the most recent error of a record's healthcheck, replacing the series of its previous
+// error.
+func SetLastCheckError(hostname, ip, msg string) {
+	if len(msg) > maxErrorLabelLength {
+		msg = strings.ToValidUTF8(msg[:maxErrorLabelLength], "") + "…"
+	}
+	LastCheckError.DeletePartialMatch(prometheus.Labels{"hostname": hostname, "ip": ip})
+	LastCheckError.WithLabelValues(hostname, ip, msg).Set(1)
+}
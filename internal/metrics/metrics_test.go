@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStartMetricsWriter_notifyChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dns-ha.prom")
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go StartMetricsWriter(ctx, wg, path, time.Hour)
+
+	NotifyChange()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected metrics file to be written on change")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	wg.Wait()
+}
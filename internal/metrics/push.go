@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/multierr"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	defaultPushJob      = "dns_ha"
+	defaultPushInterval = 1 * time.Minute
+	pushTimeout         = 10 * time.Second
+)
+
+// MetricsPusher periodically pushes the metrics to a Pushgateway and/or a Prometheus remote-write endpoint.
+type MetricsPusher struct {
+	pushgatewayUrl string
+	remoteWriteUrl string
+	job            string
+	instance       string
+	interval       time.Duration
+	username       string
+	password       string
+	httpClient     *http.Client
+}
+
+type MetricsPusherOpts func(*MetricsPusher) error
+
+func NewPusher(job string, interval time.Duration, opts ...MetricsPusherOpts) (*MetricsPusher, error) {
+	instance, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine instance name: %w", err)
+	}
+
+	p := &MetricsPusher{
+		job:      cmp.Or(job, defaultPushJob),
+		instance: instance,
+		interval: cmp.Or(interval, defaultPushInterval),
+		httpClient: &http.Client{
+			Timeout: pushTimeout,
+		},
+	}
+
+	var errs error
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	if p.pushgatewayUrl == "" && p.remoteWriteUrl == "" {
+		errs = multierr.Append(errs, errors.New("neither pushgateway nor remote-write url supplied"))
+	}
+
+	return p, errs
+}
+
+// WithPushgateway pushes the metrics to the Pushgateway at the given url, grouped by job and instance.
+func WithPushgateway(url string) MetricsPusherOpts {
+	return func(p *MetricsPusher) error {
+		if url == "" {
+			return errors.New("empty pushgateway url supplied")
+		}
+		p.pushgatewayUrl = url
+		return nil
+	}
+}
+
+// WithRemoteWrite sends the metrics to the given Prometheus remote-write endpoint.
+func WithRemoteWrite(url string) MetricsPusherOpts {
+	return func(p *MetricsPusher) error {
+		if url == "" {
+			return errors.New("empty remote-write url supplied")
+		}
+		p.remoteWriteUrl = url
+		return nil
+	}
+}
+
+// WithPushBasicAuth authenticates against the Pushgateway and the remote-write endpoint using basic auth.
+func WithPushBasicAuth(username, password string) MetricsPusherOpts {
+	return func(p *MetricsPusher) error {
+		if username == "" || password == "" {
+			return errors.New("basic auth requires username and password")
+		}
+		p.username = username
+		p.password = password
+		return nil
+	}
+}
+
+// Start pushes the metrics until the context is canceled and pushes them a last time afterward, so the final state
+// of short-lived runs is not lost.
+func (p *MetricsPusher) Start(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			Heartbeat.SetToCurrentTime()
+			if err := p.Push(ctx); err != nil {
+				slog.Error("Error pushing metrics", "err", err)
+			}
+		case <-ctx.Done():
+			pushCtx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+			if err := p.Push(pushCtx); err != nil {
+				slog.Error("Error pushing metrics during shutdown", "err", err)
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+// Push sends the current metrics to all configured destinations.
+func (p *MetricsPusher) Push(ctx context.Context) error {
+	var errs error
+	if p.pushgatewayUrl != "" {
+		if err := p.pushToGateway(ctx); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("could not push to pushgateway: %w", err))
+		}
+	}
+
+	if p.remoteWriteUrl != "" {
+		if err := p.remoteWrite(ctx); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("could not send via remote-write: %w", err))
+		}
+	}
+
+	return errs
+}
+
+func (p *MetricsPusher) pushToGateway(ctx context.Context) error {
+	pusher := push.New(p.pushgatewayUrl, p.job).
+		Gatherer(prometheus.GathererFunc(gatherOwn)).
+		Grouping("instance", p.instance).
+		Client(p.httpClient)
+	if p.username != "" {
+		pusher = pusher.BasicAuth(p.username, p.password)
+	}
+	return pusher.PushContext(ctx)
+}
+
+func (p *MetricsPusher) remoteWrite(ctx context.Context) error {
+	families, err := gatherOwn()
+	if err != nil {
+		return err
+	}
+
+	body := snappy.Encode(nil, encodeWriteRequest(families, p.job, p.instance, time.Now()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.remoteWriteUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if p.username != "" {
+		req.SetBasicAuth(p.username, p.password)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("remote-write endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// gatherOwn returns the metrics of dns-ha only, metrics of the Go runtime and the process are left out to not clash
+// with other tools exporting them.
+func gatherOwn() ([]*dto.MetricFamily, error) {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+
+	return expose(slices.DeleteFunc(families, func(f *dto.MetricFamily) bool {
+		return !strings.HasPrefix(f.GetName(), namespace)
+	})), nil
+}
+
+// encodeWriteRequest encodes the metric families as a remote-write protobuf WriteRequest.
+func encodeWriteRequest(families []*dto.MetricFamily, job, instance string, now time.Time) []byte {
+	var req []byte
+	for _, s := range flatten(families, now) {
+		s.labels["__name__"] = s.name
+		s.labels["job"] = job
+		s.labels["instance"] = instance
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, encodeTimeSeries(s))
+	}
+	return req
+}
+
+func encodeTimeSeries(s series) []byte {
+	names := make([]string, 0, len(s.labels))
+	for name := range s.labels {
+		names = append(names, name)
+	}
+	// remote-write requires the labels to be sorted by name
+	slices.Sort(names)
+
+	var ts []byte
+	for _, name := range names {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, s.labels[name])
+
+		ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, label)
+	}
+
+	var encodedSample []byte
+	encodedSample = protowire.AppendTag(encodedSample, 1, protowire.Fixed64Type)
+	encodedSample = protowire.AppendFixed64(encodedSample, math.Float64bits(s.value))
+	encodedSample = protowire.AppendTag(encodedSample, 2, protowire.VarintType)
+	encodedSample = protowire.AppendVarint(encodedSample, uint64(s.timestamp.UnixMilli()))
+
+	ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+	return protowire.AppendBytes(ts, encodedSample)
+}
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMetricsPusher_Push(t *testing.T) {
+	var pushgatewayPath, remoteWriteBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/metrics/job/"):
+			pushgatewayPath = r.URL.Path
+		case r.URL.Path == "/api/v1/write":
+			compressed, _ := io.ReadAll(r.Body)
+			data, err := snappy.Decode(nil, compressed)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			remoteWriteBody = string(data)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	Restarts.Inc()
+	pusher, err := NewPusher("", 0,
+		WithPushgateway(server.URL),
+		WithRemoteWrite(server.URL+"/api/v1/write"),
+		WithPushBasicAuth("user", "pass"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pusher.Push(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "/metrics/job/dns_ha/instance/" + pusher.instance; pushgatewayPath != want {
+		t.Errorf("expected push to %q, got %q", want, pushgatewayPath)
+	}
+	if !strings.Contains(remoteWriteBody, "dns_ha_service_restarts_total") {
+		t.Error("expected remote-write request to contain restart counter")
+	}
+	if strings.Contains(remoteWriteBody, "go_goroutines") {
+		t.Error("expected remote-write request to contain dns-ha metrics only")
+	}
+}
+
+func TestNewPusher_NoDestination(t *testing.T) {
+	if _, err := NewPusher("job", time.Minute); err == nil {
+		t.Error("expected error without destination")
+	}
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	families := []*dto.MetricFamily{
+		{
+			Name: proto.String("dns_ha_status"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{{
+				Label: []*dto.LabelPair{{Name: proto.String("hostname"), Value: proto.String("example.com")}},
+				Gauge: &dto.Gauge{Value: proto.Float64(1)},
+			}},
+		},
+	}
+
+	data := encodeWriteRequest(families, "dns_ha", "host", time.UnixMilli(1000))
+
+	// WriteRequest -> TimeSeries
+	num, typ, n := protowire.ConsumeTag(data)
+	if num != 1 || typ != protowire.BytesType {
+		t.Fatalf("unexpected field %d of type %d", num, typ)
+	}
+	ts, m := protowire.ConsumeBytes(data[n:])
+	if n+m != len(data) {
+		t.Fatalf("expected a single time series")
+	}
+
+	var labels []string
+	var value float64
+	var timestamp int64
+	for len(ts) > 0 {
+		num, _, n := protowire.ConsumeTag(ts)
+		field, m := protowire.ConsumeBytes(ts[n:])
+		ts = ts[n+m:]
+
+		switch num {
+		case 1:
+			_, _, n := protowire.ConsumeTag(field)
+			name, m := protowire.ConsumeString(field[n:])
+			field = field[n+m:]
+			_, _, n = protowire.ConsumeTag(field)
+			val, _ := protowire.ConsumeString(field[n:])
+			labels = append(labels, name+"="+val)
+		case 2:
+			_, _, n := protowire.ConsumeTag(field)
+			bits, m := protowire.ConsumeFixed64(field[n:])
+			value = math.Float64frombits(bits)
+			field = field[n+m:]
+			_, _, n = protowire.ConsumeTag(field)
+			ms, _ := protowire.ConsumeVarint(field[n:])
+			timestamp = int64(ms)
+		}
+	}
+
+	want := "__name__=dns_ha_status,hostname=example.com,instance=host,job=dns_ha"
+	if got := strings.Join(labels, ","); got != want {
+		t.Errorf("got labels %q, want %q", got, want)
+	}
+	if value != 1 || timestamp != 1000 {
+		t.Errorf("got sample %v@%d, want 1@1000", value, timestamp)
+	}
+}
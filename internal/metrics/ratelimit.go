@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const rateLimitWindow = time.Minute
+
+// WithRateLimit limits the amount of requests each client may send per minute. Clients are told apart by the common
+// name of their certificate if mTLS is used and by their address otherwise.
+func WithRateLimit(requestsPerMinute int) MetricsServerOpts {
+	return func(s *MetricsServer) error {
+		if requestsPerMinute <= 0 {
+			return errors.New("rate limit must be positive")
+		}
+		s.limiter = &rateLimiter{limit: requestsPerMinute, counts: map[string]int{}}
+		return nil
+	}
+}
+
+// rateLimiter counts the requests of each client within fixed windows.
+type rateLimiter struct {
+	limit int
+
+	mutex       sync.Mutex
+	windowStart time.Time
+	counts      map[string]int
+}
+
+func (l *rateLimiter) allow(client string, now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if now.Sub(l.windowStart) >= rateLimitWindow {
+		l.windowStart = now
+		clear(l.counts)
+	}
+	l.counts[client]++
+	return l.counts[client] <= l.limit
+}
+
+func (s *MetricsServer) withRateLimit(next http.Handler) http.Handler {
+	if s.limiter == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.limiter.allow(clientOf(r), time.Now()) {
+			w.Header().Set("Retry-After", "60")
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func clientOf(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return "cn:" + r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
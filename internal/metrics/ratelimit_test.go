@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_allow(t *testing.T) {
+	limiter := &rateLimiter{limit: 2, counts: map[string]int{}}
+	now := time.Now()
+
+	got := []bool{
+		limiter.allow("10.0.0.1", now),
+		limiter.allow("10.0.0.1", now),
+		limiter.allow("10.0.0.1", now),
+		limiter.allow("10.0.0.2", now),
+		limiter.allow("10.0.0.1", now.Add(rateLimitWindow)),
+	}
+	want := []bool{true, true, false, true, true}
+	if !slices.Equal(got, want) {
+		t.Errorf("allow() = %v, want %v", got, want)
+	}
+}
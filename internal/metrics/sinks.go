@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	GraphiteSinkName = "graphite"
+	InfluxDbSinkName = "influxdb"
+	StatsdSinkName   = "statsd"
+
+	defaultSinkInterval = 1 * time.Minute
+)
+
+// Sink emits the metrics to a monitoring system other than Prometheus.
+type Sink interface {
+	Emit(ctx context.Context, families []*dto.MetricFamily, now time.Time) error
+}
+
+// StartSink emits the metrics to the sink in the given interval until the context is canceled and a last time
+// afterward.
+func StartSink(ctx context.Context, wg *sync.WaitGroup, name string, sink Sink, interval time.Duration) {
+	defer wg.Done()
+	if interval <= 0 {
+		interval = defaultSinkInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	emit := func(ctx context.Context) {
+		families, err := gatherOwn()
+		if err == nil {
+			err = sink.Emit(ctx, families, time.Now())
+		}
+		if err != nil {
+			slog.Error("Error emitting metrics", "sink", name, "err", err)
+		}
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			Heartbeat.SetToCurrentTime()
+			emit(ctx)
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+			emit(shutdownCtx)
+			cancel()
+			return
+		}
+	}
+}
+
+// series is a single sample of a metric family, histograms and summaries are split into multiple series.
+type series struct {
+	name      string
+	labels    map[string]string
+	value     float64
+	timestamp time.Time
+	counter   bool
+}
+
+// key identifies the series across multiple gatherings.
+func (s series) key() string {
+	return fmt.Sprint(s.name, s.labels)
+}
+
+func flatten(families []*dto.MetricFamily, now time.Time) []series {
+	var ret []series
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			timestamp := now
+			if metric.TimestampMs != nil {
+				timestamp = time.UnixMilli(metric.GetTimestampMs())
+			}
+
+			labels := make(map[string]string, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			for _, s := range expandMetric(family.GetName(), family.GetType(), metric) {
+				maps.Copy(s.labels, labels)
+				s.timestamp = timestamp
+				ret = append(ret, s)
+			}
+		}
+	}
+	return ret
+}
+
+// expandMetric returns the series of a single metric without the metric's labels.
+func expandMetric(name string, metricType dto.MetricType, metric *dto.Metric) []series {
+	single := func(name string, value float64, counter bool, extra ...string) series {
+		labels := map[string]string{}
+		for i := 0; i+1 < len(extra); i += 2 {
+			labels[extra[i]] = extra[i+1]
+		}
+		return series{name: name, labels: labels, value: value, counter: counter}
+	}
+
+	switch metricType {
+	case dto.MetricType_COUNTER:
+		return []series{single(name, metric.GetCounter().GetValue(), true)}
+	case dto.MetricType_GAUGE:
+		return []series{single(name, metric.GetGauge().GetValue(), false)}
+	case dto.MetricType_UNTYPED:
+		return []series{single(name, metric.GetUntyped().GetValue(), false)}
+	case dto.MetricType_HISTOGRAM:
+		h := metric.GetHistogram()
+		ret := []series{
+			single(name+"_sum", h.GetSampleSum(), true),
+			single(name+"_count", float64(h.GetSampleCount()), true),
+			single(name+"_bucket", float64(h.GetSampleCount()), true, "le", "+Inf"),
+		}
+		for _, bucket := range h.GetBucket() {
+			if math.IsInf(bucket.GetUpperBound(), 1) {
+				continue
+			}
+			ret = append(ret, single(name+"_bucket", float64(bucket.GetCumulativeCount()), true, "le", fmt.Sprint(bucket.GetUpperBound())))
+		}
+		return ret
+	case dto.MetricType_SUMMARY:
+		s := metric.GetSummary()
+		ret := []series{
+			single(name+"_sum", s.GetSampleSum(), true),
+			single(name+"_count", float64(s.GetSampleCount()), true),
+		}
+		for _, q := range s.GetQuantile() {
+			ret = append(ret, single(name, q.GetValue(), false, "quantile", fmt.Sprint(q.GetQuantile())))
+		}
+		return ret
+	default:
+		return nil
+	}
+}
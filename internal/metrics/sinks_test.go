@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+var testSeries = series{
+	name:      "dns_ha_status",
+	labels:    map[string]string{"hostname": "example.com", "status": "healthy here", "empty": ""},
+	value:     1,
+	timestamp: time.Unix(1700000000, 0),
+}
+
+func TestGraphiteSink_formatLine(t *testing.T) {
+	sink, err := NewGraphiteSink("localhost:2003", "prefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "prefix.dns_ha_status;hostname=example.com;status=healthy_here 1 1700000000\n"
+	if got := sink.formatLine(testSeries); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatInfluxLine(t *testing.T) {
+	want := `dns_ha_status,hostname=example.com,status=healthy\ here value=1 1700000000000000000` + "\n"
+	if got := formatInfluxLine(testSeries); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestStatsdSink_Emit(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	sink, err := NewStatsdSink(listener.LocalAddr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter := func(value float64) []*dto.MetricFamily {
+		return []*dto.MetricFamily{{
+			Name: proto.String("dns_ha_errors_total"),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{{
+				Label:   []*dto.LabelPair{{Name: proto.String("hostname"), Value: proto.String("example.com")}},
+				Counter: &dto.Counter{Value: proto.Float64(value)},
+			}},
+		}}
+	}
+
+	tests := []struct {
+		name  string
+		value float64
+		want  string
+	}{
+		{name: "baseline is not sent", value: 3},
+		{name: "increase", value: 5, want: "dns_ha_errors_total:2|c|#hostname:example.com"},
+		{name: "reset", value: 1, want: "dns_ha_errors_total:1|c|#hostname:example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := sink.Emit(context.Background(), counter(tt.value), time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				return
+			}
+
+			buf := make([]byte, maxStatsdPayload)
+			_ = listener.SetReadDeadline(time.Now().Add(time.Second))
+			n, _, err := listener.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(buf[:n]); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/multierr"
+)
+
+// maxStatsdPayload keeps datagrams below the common MTU to prevent fragmentation.
+const maxStatsdPayload = 1432
+
+var statsdReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", ",", "_", "#", "_", "\n", "_")
+
+// StatsdSink sends the metrics via UDP using the StatsD protocol. Labels are sent as DogStatsD tags, counters are
+// sent as the increase since the last emit.
+type StatsdSink struct {
+	address string
+	prefix  string
+	dialer  net.Dialer
+
+	mutex    sync.Mutex
+	counters map[string]float64
+}
+
+func NewStatsdSink(address, prefix string) (*StatsdSink, error) {
+	if address == "" {
+		return nil, errors.New("empty statsd address supplied")
+	}
+
+	return &StatsdSink{
+		address:  address,
+		prefix:   prefix,
+		counters: map[string]float64{},
+	}, nil
+}
+
+func (s *StatsdSink) Emit(ctx context.Context, families []*dto.MetricFamily, now time.Time) error {
+	conn, err := s.dialer.DialContext(ctx, "udp", s.address)
+	if err != nil {
+		return fmt.Errorf("could not connect to statsd: %w", err)
+	}
+	defer conn.Close()
+
+	var errs error
+	var payload []byte
+	for _, line := range s.formatLines(flatten(families, now)) {
+		if len(payload) > 0 && len(payload)+len(line)+1 > maxStatsdPayload {
+			if _, err := conn.Write(payload); err != nil {
+				errs = multierr.Append(errs, err)
+			}
+			payload = payload[:0]
+		}
+		if len(payload) > 0 {
+			payload = append(payload, '\n')
+		}
+		payload = append(payload, line...)
+	}
+
+	if len(payload) > 0 {
+		if _, err := conn.Write(payload); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+func (s *StatsdSink) formatLines(all []series) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	lines := make([]string, 0, len(all))
+	for _, series := range all {
+		value, metricType := series.value, "g"
+		if series.counter {
+			key := series.key()
+			previous, found := s.counters[key]
+			s.counters[key] = series.value
+			// the first observation only establishes the baseline, a lower value means the counter has been reset
+			if !found {
+				continue
+			}
+			value, metricType = series.value-previous, "c"
+			if value < 0 {
+				value = series.value
+			}
+		}
+
+		name := series.name
+		if s.prefix != "" {
+			name = s.prefix + "." + name
+		}
+
+		line := statsdReplacer.Replace(name) + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + metricType
+		var tags []string
+		for _, label := range slices.Sorted(maps.Keys(series.labels)) {
+			tags = append(tags, statsdReplacer.Replace(label)+":"+statsdReplacer.Replace(series.labels[label]))
+		}
+		if len(tags) > 0 {
+			line += "|#" + strings.Join(tags, ",")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
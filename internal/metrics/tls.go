@@ -0,0 +1,216 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithTls serves the metrics via TLS. The certificate is reloaded when the files are rotated.
+func WithTls(certFile, keyFile string) MetricsServerOpts {
+	return func(s *MetricsServer) error {
+		reloader, err := newCertReloader(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		s.certReloader = reloader
+		return nil
+	}
+}
+
+// WithClientCa requires clients to present a certificate signed by one of the CAs in the file.
+func WithClientCa(caFile string) MetricsServerOpts {
+	return func(s *MetricsServer) error {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("could not read client ca: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in %q", caFile)
+		}
+		s.clientCas = pool
+		return nil
+	}
+}
+
+// WithBasicAuth requires clients to authenticate using basic auth.
+func WithBasicAuth(username, password string) MetricsServerOpts {
+	return func(s *MetricsServer) error {
+		if username == "" || password == "" {
+			return errors.New("basic auth requires username and password")
+		}
+		s.basicAuthUser = username
+		s.basicAuthPassword = password
+		return nil
+	}
+}
+
+// WithBearerTokens allows clients to authenticate using one of the given bearer tokens, alternatively to basic auth.
+func WithBearerTokens(tokens []string) MetricsServerOpts {
+	return func(s *MetricsServer) error {
+		if slices.Contains(tokens, "") {
+			return errors.New("empty bearer token supplied")
+		}
+		s.bearerTokens = tokens
+		return nil
+	}
+}
+
+// WithAllowedClientCns only accepts client certificates whose common name is one of the given names, so a
+// certificate signed by a shared CA does not grant access on its own.
+func WithAllowedClientCns(cns []string) MetricsServerOpts {
+	return func(s *MetricsServer) error {
+		if slices.Contains(cns, "") {
+			return errors.New("empty client cn supplied")
+		}
+		s.allowedClientCns = cns
+		return nil
+	}
+}
+
+func (s *MetricsServer) tlsConfig() (*tls.Config, error) {
+	if len(s.allowedClientCns) > 0 && s.clientCas == nil {
+		return nil, errors.New("allowed client cns require a client ca")
+	}
+	if s.certReloader == nil {
+		if s.clientCas != nil {
+			return nil, errors.New("client certificate verification requires tls")
+		}
+		return nil, nil
+	}
+
+	conf := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: s.certReloader.getCertificate,
+	}
+
+	if s.clientCas != nil {
+		conf.ClientCAs = s.clientCas
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if len(s.allowedClientCns) > 0 {
+		conf.VerifyConnection = s.verifyClientCn
+	}
+
+	return conf, nil
+}
+
+// verifyClientCn rejects connections of clients whose verified certificate carries a common name that is not allowed.
+func (s *MetricsServer) verifyClientCn(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("no client certificate presented")
+	}
+	if cn := state.PeerCertificates[0].Subject.CommonName; !slices.Contains(s.allowedClientCns, cn) {
+		return fmt.Errorf("client cn %q is not allowed", cn)
+	}
+	return nil
+}
+
+// withAuth requires clients to authenticate using basic auth or a bearer token if any of them is configured.
+func (s *MetricsServer) withAuth(next http.Handler) http.Handler {
+	if s.basicAuthUser == "" && len(s.bearerTokens) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.validBasicAuth(r) || s.validBearerToken(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.basicAuthUser != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="dns-ha"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="dns-ha"`)
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+func (s *MetricsServer) validBasicAuth(r *http.Request) bool {
+	if s.basicAuthUser == "" {
+		return false
+	}
+	user, password, ok := r.BasicAuth()
+	userMatches := subtle.ConstantTimeCompare([]byte(user), []byte(s.basicAuthUser)) == 1
+	passwordMatches := subtle.ConstantTimeCompare([]byte(password), []byte(s.basicAuthPassword)) == 1
+	return ok && userMatches && passwordMatches
+}
+
+func (s *MetricsServer) validBearerToken(r *http.Request) bool {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return false
+	}
+	return slices.ContainsFunc(s.bearerTokens, func(t string) bool {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1
+	})
+}
+
+// certReloader loads the certificate again once the modification time of one of the files changed.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mutex   sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.getCertificate(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) getCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.cert != nil && !modTime.After(r.modTime) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		// keep serving the previous certificate while the files are being rotated
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("could not load certificate: %w", err)
+	}
+
+	r.cert = &cert
+	r.modTime = modTime
+	return r.cert, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCert(t *testing.T, certFile, keyFile, commonName string, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	now := time.Now()
+	writeCert(t, certFile, keyFile, "first", now.Add(-time.Minute))
+
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeCert(t, certFile, keyFile, "second", now)
+	cert, err := reloader.getCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Subject.CommonName != "second" {
+		t.Errorf("expected rotated certificate, got %q", parsed.Subject.CommonName)
+	}
+
+	if _, err := newCertReloader(filepath.Join(dir, "missing.pem"), keyFile); err == nil {
+		t.Error("expected error for missing certificate")
+	}
+}
+
+func TestMetricsServer_withAuth(t *testing.T) {
+	server, err := New("127.0.0.1:0", WithBasicAuth("prometheus", "secret"), WithBearerTokens([]string{"token-a", "token-b"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := server.withAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		token    string
+		want     int
+	}{
+		{name: "valid", user: "prometheus", password: "secret", want: http.StatusOK},
+		{name: "wrong password", user: "prometheus", password: "wrong", want: http.StatusUnauthorized},
+		{name: "no credentials", want: http.StatusUnauthorized},
+		{name: "valid token", token: "token-b", want: http.StatusOK},
+		{name: "wrong token", token: "token-c", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.password)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("got status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsServer_verifyClientCn(t *testing.T) {
+	server, err := New("127.0.0.1:0", WithAllowedClientCns([]string{"node-a"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for cn, wantErr := range map[string]bool{"node-a": false, "node-b": true} {
+		state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: cn}}}}
+		if err := server.verifyClientCn(state); (err != nil) != wantErr {
+			t.Errorf("verifyClientCn(%q) error = %v, wantErr %v", cn, err, wantErr)
+		}
+	}
+
+	// the common names are only verified for certificates signed by a client ca
+	if _, err := server.tlsConfig(); err == nil {
+		t.Error("expected error for allowed client cns without client ca")
+	}
+}
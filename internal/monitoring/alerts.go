@@ -0,0 +1,102 @@
+package monitoring
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// AlertRules returns Prometheus alerting rules for dns-ha itself and each hostname.
+func AlertRules(hostnames []Hostname, m Metrics) ([]byte, error) {
+	groups := []ruleGroup{{
+		Name: "dns-ha",
+		Rules: []rule{
+			{
+				Alert:       "DnsHaHeartbeatMissing",
+				Expr:        fmt.Sprintf("time() - %s > 300", m.metric("heartbeat_timestamp_seconds")),
+				For:         "5m",
+				Labels:      map[string]string{"severity": "critical"},
+				Annotations: map[string]string{"summary": "dns-ha on {{ $labels.instance }} stopped reporting"},
+			},
+			{
+				Alert:       "DnsHaRestartsSuppressed",
+				Expr:        fmt.Sprintf("increase(%s[1h]) > 10", m.metric("service_restarts_suppressed_total")),
+				Labels:      map[string]string{"severity": "warning"},
+				Annotations: map[string]string{"summary": "dns-ha on {{ $labels.instance }} is rate limiting service restarts"},
+			},
+		},
+	}}
+
+	for _, hostname := range sorted(hostnames) {
+		selector := fmt.Sprintf(`hostname=%q`, hostname.Name)
+		group := ruleGroup{
+			Name: "dns-ha " + hostname.Name,
+			Rules: []rule{
+				{
+					Alert:  "DnsHaNoHealthyRecords",
+					Expr:   fmt.Sprintf(`%s == 0 or max by (hostname) (%s) == 1`, m.metric("active_records_total", selector), m.metric("fallback_active", selector)),
+					For:    "2m",
+					Labels: map[string]string{"severity": "critical"},
+					Annotations: map[string]string{
+						"summary": fmt.Sprintf("No healthy records for %s", hostname.Name),
+					},
+				},
+				{
+					Alert:  "DnsHaErrors",
+					Expr:   fmt.Sprintf(`increase(%s[15m]) > 0`, m.metric("errors_total", selector)),
+					Labels: map[string]string{"severity": "warning"},
+					Annotations: map[string]string{
+						"summary": fmt.Sprintf("dns-ha reports {{ $labels.error }} errors for %s", hostname.Name),
+					},
+				},
+			},
+		}
+
+		for _, ip := range hostname.Ips {
+			group.Rules = append(group.Rules, rule{
+				Alert:  "DnsHaRecordUnhealthy",
+				Expr:   m.metric("status", selector, fmt.Sprintf("ip=%q", ip), `status="unhealthy"`) + " == 1",
+				For:    "15m",
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("Record %s of %s is unhealthy", ip, hostname.Name),
+				},
+			}, rule{
+				Alert:  "DnsHaRecordCheckErrors",
+				Expr:   m.metric("status", selector, fmt.Sprintf("ip=%q", ip), `status="error"`) + " == 1",
+				For:    "15m",
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf("Checks of record %s of %s keep failing with errors", ip, hostname.Name),
+				},
+			})
+		}
+		groups = append(groups, group)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(ruleFile{Groups: groups}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), encoder.Close()
+}
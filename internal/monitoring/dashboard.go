@@ -0,0 +1,190 @@
+// Package monitoring generates Grafana dashboards and Prometheus alerting rules for the configured hostnames.
+package monitoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const defaultNamespace = "dns_ha"
+
+// Hostname describes a managed hostname and the IPs of its records.
+type Hostname struct {
+	Name string
+	Ips  []string
+}
+
+// Metrics describes how the metrics of dns-ha are exposed, see metrics_namespace and metrics_labels.
+type Metrics struct {
+	// Namespace is the prefix of all metric names, "dns_ha" if empty.
+	Namespace string
+	// Labels are added to all metrics and used to select the metrics of the instance.
+	Labels map[string]string
+}
+
+// metric returns a selector of the metric with the given name, restricted to the constant labels and the matchers.
+func (m Metrics) metric(name string, matchers ...string) string {
+	ns := m.Namespace
+	if ns == "" {
+		ns = defaultNamespace
+	}
+
+	var all []string
+	for _, label := range slices.Sorted(maps.Keys(m.Labels)) {
+		all = append(all, fmt.Sprintf("%s=%q", label, m.Labels[label]))
+	}
+	all = append(all, matchers...)
+	if len(all) == 0 {
+		return ns + "_" + name
+	}
+	return fmt.Sprintf("%s_%s{%s}", ns, name, strings.Join(all, ", "))
+}
+
+type panel struct {
+	Id          int            `json:"id"`
+	Type        string         `json:"type"`
+	Title       string         `json:"title"`
+	GridPos     gridPos        `json:"gridPos"`
+	Datasource  map[string]any `json:"datasource,omitempty"`
+	Targets     []target       `json:"targets,omitempty"`
+	FieldConfig map[string]any `json:"fieldConfig,omitempty"`
+	Options     map[string]any `json:"options,omitempty"`
+	Collapsed   *bool          `json:"collapsed,omitempty"`
+	Panels      []panel        `json:"panels,omitempty"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type target struct {
+	RefId        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+}
+
+var datasource = map[string]any{"type": "prometheus", "uid": "${datasource}"}
+
+// Dashboard returns a Grafana dashboard with an overview and a row for each hostname.
+func Dashboard(hostnames []Hostname, m Metrics) ([]byte, error) {
+	var panels []panel
+	nextId := 1
+	y := 0
+	add := func(p panel, w, h int, x int) panel {
+		p.Id = nextId
+		nextId++
+		p.GridPos = gridPos{H: h, W: w, X: x, Y: y}
+		if p.Type != "row" {
+			p.Datasource = datasource
+		}
+		return p
+	}
+
+	panels = append(panels,
+		add(panel{Type: "stat", Title: "Hostnames without healthy records", Targets: []target{
+			{RefId: "A", Expr: fmt.Sprintf(`count(%s == 0) or vector(0)`, m.metric("active_records_total"))},
+		}, FieldConfig: thresholds(1)}, 6, 4, 0),
+		add(panel{Type: "stat", Title: "Unhealthy records", Targets: []target{
+			{RefId: "A", Expr: fmt.Sprintf(`count(%s == 1) or vector(0)`, m.metric("status", `status="unhealthy"`))},
+		}, FieldConfig: thresholds(1)}, 6, 4, 6),
+		add(panel{Type: "stat", Title: "Seconds since heartbeat", Targets: []target{
+			{RefId: "A", Expr: fmt.Sprintf(`time() - max(%s)`, m.metric("heartbeat_timestamp_seconds"))},
+		}, FieldConfig: thresholds(300)}, 6, 4, 12),
+		add(panel{Type: "timeseries", Title: "Service restarts", Targets: []target{
+			{RefId: "A", Expr: fmt.Sprintf(`increase(%s[$__rate_interval])`, m.metric("service_restarts_total")), LegendFormat: "restarts"},
+		}}, 6, 4, 18),
+	)
+	y += 4
+
+	for _, hostname := range sorted(hostnames) {
+		collapsed := false
+		panels = append(panels, add(panel{Type: "row", Title: hostname.Name, Collapsed: &collapsed}, 24, 1, 0))
+		y++
+
+		selector := fmt.Sprintf(`hostname=%q`, hostname.Name)
+		panels = append(panels,
+			add(panel{Type: "state-timeline", Title: "Health of " + hostname.Name, Targets: []target{
+				{RefId: "A", Expr: m.metric("status", selector, `status="healthy"`), LegendFormat: "{{ip}}"},
+			}, FieldConfig: valueMappings("unhealthy", "red", "healthy", "green")}, 12, 8, 0),
+			add(panel{Type: "state-timeline", Title: "Published records of " + hostname.Name, Targets: []target{
+				{RefId: "A", Expr: m.metric("active_record", selector), LegendFormat: "{{ip}}"},
+			}, FieldConfig: valueMappings("standby", "transparent", "published", "green")}, 12, 8, 12),
+		)
+		y += 8
+
+		panels = append(panels,
+			add(panel{Type: "timeseries", Title: "Errors of " + hostname.Name, Targets: []target{
+				{RefId: "A", Expr: fmt.Sprintf(`increase(%s[$__rate_interval])`, m.metric("errors_total", selector)), LegendFormat: "{{error}}"},
+			}}, 12, 6, 0),
+			add(panel{Type: "timeseries", Title: "Fallback and maintenance of " + hostname.Name, Targets: []target{
+				{RefId: "A", Expr: m.metric("fallback_active", selector) + " == 1", LegendFormat: "fallback {{policy}}"},
+				{RefId: "B", Expr: m.metric("maintenance", selector) + " == 1", LegendFormat: "maintenance {{ip}}"},
+			}}, 12, 6, 12),
+		)
+		y += 6
+	}
+
+	dashboard := map[string]any{
+		"title":         "dns-ha",
+		"uid":           "dns-ha",
+		"tags":          []string{"dns-ha"},
+		"timezone":      "browser",
+		"schemaVersion": 39,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating": map[string]any{
+			"list": []map[string]any{{
+				"name":  "datasource",
+				"type":  "datasource",
+				"query": "prometheus",
+				"label": "Data source",
+			}},
+		},
+		"panels": panels,
+	}
+
+	return json.MarshalIndent(dashboard, "", "  ")
+}
+
+func thresholds(red float64) map[string]any {
+	return map[string]any{
+		"defaults": map[string]any{
+			"thresholds": map[string]any{
+				"mode": "absolute",
+				"steps": []map[string]any{
+					{"color": "green", "value": nil},
+					{"color": "red", "value": red},
+				},
+			},
+		},
+	}
+}
+
+// valueMappings maps the values 0 and 1 to the given texts and colors.
+func valueMappings(zeroText, zeroColor, oneText, oneColor string) map[string]any {
+	return map[string]any{
+		"defaults": map[string]any{
+			"mappings": []map[string]any{{
+				"type": "value",
+				"options": map[string]any{
+					"0": map[string]string{"text": zeroText, "color": zeroColor},
+					"1": map[string]string{"text": oneText, "color": oneColor},
+				},
+			}},
+		},
+	}
+}
+
+func sorted(hostnames []Hostname) []Hostname {
+	ret := slices.Clone(hostnames)
+	slices.SortFunc(ret, func(a, b Hostname) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return ret
+}
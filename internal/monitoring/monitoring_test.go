@@ -0,0 +1,130 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+var testHostnames = []Hostname{
+	{Name: "b.example.com", Ips: []string{"10.0.0.3"}},
+	{Name: "a.example.com", Ips: []string{"10.0.0.1", "10.0.0.2"}},
+}
+
+func TestDashboard(t *testing.T) {
+	data, err := Dashboard(testHostnames, Metrics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dashboard struct {
+		Panels []panel `json:"panels"`
+	}
+	if err := json.Unmarshal(data, &dashboard); err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []string
+	ids := map[int]bool{}
+	for _, p := range dashboard.Panels {
+		if ids[p.Id] {
+			t.Errorf("duplicate panel id %d", p.Id)
+		}
+		ids[p.Id] = true
+		if p.Type == "row" {
+			rows = append(rows, p.Title)
+		}
+	}
+
+	if len(rows) != 2 || rows[0] != "a.example.com" || rows[1] != "b.example.com" {
+		t.Errorf("expected a sorted row per hostname, got %v", rows)
+	}
+}
+
+func TestAlertRules(t *testing.T) {
+	data, err := AlertRules(testHostnames, Metrics{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rules ruleFile
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rules.Groups) != 3 {
+		t.Fatalf("expected a group for dns-ha and each hostname, got %d", len(rules.Groups))
+	}
+
+	unhealthy := 0
+	for _, r := range rules.Groups[1].Rules {
+		if r.Alert == "DnsHaRecordUnhealthy" {
+			unhealthy++
+		}
+	}
+	if rules.Groups[1].Name != "dns-ha a.example.com" || unhealthy != 2 {
+		t.Errorf("expected a rule per record of a.example.com, got %+v", rules.Groups[1])
+	}
+}
+
+func TestMetrics_namespaceAndLabels(t *testing.T) {
+	m := Metrics{Namespace: "edge_dns", Labels: map[string]string{"site": "fra1"}}
+
+	dashboard, err := Dashboard(testHostnames, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := AlertRules(testHostnames, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{"dashboard": dashboard, "alerting rules": rules} {
+		generated := string(data)
+		if strings.Contains(generated, "dns_ha_") {
+			t.Errorf("expected %s to only use the configured namespace", name)
+		}
+		if !strings.Contains(generated, "edge_dns_heartbeat_timestamp_seconds") {
+			t.Errorf("expected %s to use the configured namespace", name)
+		}
+	}
+
+	var file ruleFile
+	if err := yaml.Unmarshal(rules, &file); err != nil {
+		t.Fatal(err)
+	}
+	for _, group := range file.Groups {
+		for _, r := range group.Rules {
+			if !strings.Contains(r.Expr, `{site="fra1"`) {
+				t.Errorf("expected %s to select the constant labels, got %q", r.Alert, r.Expr)
+			}
+		}
+	}
+}
+
+func TestMetrics_metric(t *testing.T) {
+	tests := []struct {
+		name     string
+		metrics  Metrics
+		matchers []string
+		want     string
+	}{
+		{name: "default", want: "dns_ha_status"},
+		{name: "matchers", matchers: []string{`ip="10.0.0.1"`}, want: `dns_ha_status{ip="10.0.0.1"}`},
+		{
+			name:     "labels",
+			metrics:  Metrics{Namespace: "edge_dns", Labels: map[string]string{"site": "fra1", "role": "edge"}},
+			matchers: []string{`ip="10.0.0.1"`},
+			want:     `edge_dns_status{role="edge", site="fra1", ip="10.0.0.1"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.metrics.metric("status", tt.matchers...); got != tt.want {
+				t.Errorf("metric() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
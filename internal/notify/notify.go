@@ -0,0 +1,82 @@
+// Package notify implements systemd's sd_notify protocol, so dns-ha can run as a Type=notify unit with a watchdog.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// ErrNotSupported is returned if the process has not been started by systemd with a notify socket.
+var ErrNotSupported = errors.New("NOTIFY_SOCKET not set")
+
+// Send sends the state to systemd. It returns ErrNotSupported if the process is not supervised by systemd.
+func Send(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return ErrNotSupported
+	}
+
+	// abstract sockets are prefixed with an @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("could not connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval returns the interval systemd expects watchdog pings in. It returns false if the watchdog is not
+// enabled for this process.
+func WatchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+
+	// the watchdog may be meant for another process, e.g. a wrapper
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// RunWatchdog pings the watchdog at half of the interval as long as isAlive returns true. If isAlive returns false,
+// pings are withheld, so systemd restarts the process. It blocks until the context is canceled.
+func RunWatchdog(ctx context.Context, interval time.Duration, isAlive func() bool) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !isAlive() {
+				slog.Error("Check loop is not making progress, withholding watchdog ping")
+				continue
+			}
+			if err := Send(Watchdog); err != nil {
+				slog.Warn("Could not ping watchdog", "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+	if err := Send(Ready); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != Ready {
+		t.Errorf("got %q, want %q", got, Ready)
+	}
+
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Send(Ready); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	tests := []struct {
+		name        string
+		usec        string
+		pid         string
+		want        time.Duration
+		wantEnabled bool
+	}{
+		{name: "enabled", usec: "30000000", want: 30 * time.Second, wantEnabled: true},
+		{name: "own pid", usec: "30000000", pid: strconv.Itoa(os.Getpid()), want: 30 * time.Second, wantEnabled: true},
+		{name: "other pid", usec: "30000000", pid: "1"},
+		{name: "disabled", usec: ""},
+		{name: "invalid", usec: "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tt.usec)
+			t.Setenv("WATCHDOG_PID", tt.pid)
+			got, enabled := WatchdogInterval()
+			if got != tt.want || enabled != tt.wantEnabled {
+				t.Errorf("got %v/%v, want %v/%v", got, enabled, tt.want, tt.wantEnabled)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+)
+
+// OutageHooks is optionally implemented by Hooks to be notified if a hostname has had no healthy records for longer
+// than the EscalateAfter duration of its policy, and once it recovers from such an outage.
+type OutageHooks interface {
+	Outage(ctx context.Context, hostname string, since time.Time) error
+	OutageResolved(ctx context.Context, hostname string, since time.Time) error
+}
+
+// outage tracks a period during which a hostname has no healthy records.
+type outage struct {
+	since     time.Time
+	escalated bool
+}
+
+// escalateOutage escalates the outage of the hostname once it lasts longer than the policy allows.
+func (h *RecordManager) escalateOutage(ctx context.Context, hostname string) {
+	current := h.outages[hostname]
+	after := h.hostnamePolicies[hostname].EscalateAfter
+	if current == nil || current.escalated || after <= 0 || h.clock.Since(current.since) < after {
+		return
+	}
+
+	slog.Error("Hostname has no healthy records for a prolonged time", "hostname", hostname, "since", current.since)
+	current.escalated = true
+	metrics.Outage.WithLabelValues(hostname).Set(1)
+	if hooks, ok := h.hooks.(OutageHooks); ok {
+		if err := hooks.Outage(ctx, hostname, current.since); err != nil {
+			metrics.Errors.WithLabelValues(hostname, "hook_outage", ErrorKind(err)).Inc()
+			slog.Error("outage hook failed", "hostname", hostname, "err", err)
+		}
+	}
+}
+
+// resolveOutage ends the outage of the hostname and notifies the hooks if it had been escalated.
+func (h *RecordManager) resolveOutage(ctx context.Context, hostname string) {
+	current := h.outages[hostname]
+	delete(h.outages, hostname)
+	if current == nil || !current.escalated {
+		return
+	}
+
+	slog.Info("Hostname recovered from prolonged outage", "hostname", hostname, "duration", h.clock.Since(current.since).Round(time.Second))
+	metrics.Outage.WithLabelValues(hostname).Set(0)
+	if hooks, ok := h.hooks.(OutageHooks); ok {
+		if err := hooks.OutageResolved(ctx, hostname, current.since); err != nil {
+			metrics.Errors.WithLabelValues(hostname, "hook_outage_resolved", ErrorKind(err)).Inc()
+			slog.Error("outage_resolved hook failed", "hostname", hostname, "err", err)
+		}
+	}
+}
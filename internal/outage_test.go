@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+type outageRecorder struct {
+	events []string
+}
+
+func (o *outageRecorder) PreUpdate(_ context.Context, _ string, _, _ []string) error {
+	return nil
+}
+
+func (o *outageRecorder) PostUpdate(_ context.Context, _ string, _, _ []string) error {
+	return nil
+}
+
+func (o *outageRecorder) PostRestart(_ context.Context, _ []string) error {
+	return nil
+}
+
+func (o *outageRecorder) Outage(_ context.Context, hostname string, _ time.Time) error {
+	o.events = append(o.events, "outage "+hostname)
+	return nil
+}
+
+func (o *outageRecorder) OutageResolved(_ context.Context, hostname string, _ time.Time) error {
+	o.events = append(o.events, "resolved "+hostname)
+	return nil
+}
+
+func TestRecordManager_escalateOutage(t *testing.T) {
+	record := &ManagedDnsRecord{
+		DnsRecord: DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60},
+		Hostname:  "my.tld",
+		status:    &status.Unhealthy{},
+	}
+	hooks := &outageRecorder{}
+	m, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, map[string][]*ManagedDnsRecord{"my.tld": {record}},
+		WithHooks(hooks),
+		WithHostnamePolicies(map[string]HostnamePolicy{"my.tld": {EscalateAfter: time.Minute}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.applyRecords(context.Background())
+	if len(hooks.events) != 0 {
+		t.Fatalf("expected outage not to be escalated before EscalateAfter, got %v", hooks.events)
+	}
+
+	m.outages["my.tld"].since = time.Now().Add(-2 * time.Minute)
+	m.applyRecords(context.Background())
+	m.applyRecords(context.Background())
+	if want := []string{"outage my.tld"}; !slices.Equal(hooks.events, want) {
+		t.Fatalf("expected outage to be escalated once, got %v", hooks.events)
+	}
+
+	record.status = &status.Healthy{}
+	m.applyRecords(context.Background())
+	if want := []string{"outage my.tld", "resolved my.tld"}; !slices.Equal(hooks.events, want) {
+		t.Errorf("expected outage to be resolved, got %v", hooks.events)
+	}
+	if _, found := m.outages["my.tld"]; found {
+		t.Errorf("expected outage to be removed after recovery")
+	}
+}
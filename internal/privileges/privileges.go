@@ -0,0 +1,45 @@
+package privileges
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// lookupIds resolves the user and group names or ids. The group defaults to the user's primary group.
+func lookupIds(username, group string) (int, int, error) {
+	if username == "" {
+		return 0, 0, errors.New("empty user supplied")
+	}
+
+	u, err := user.Lookup(username)
+	if err != nil {
+		if u, err = user.LookupId(username); err != nil {
+			return 0, 0, fmt.Errorf("unknown user %q", username)
+		}
+	}
+
+	gidStr := u.Gid
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if g, err = user.LookupGroupId(group); err != nil {
+				return 0, 0, fmt.Errorf("unknown group %q", group)
+			}
+		}
+		gidStr = g.Gid
+	}
+
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid uid %q: %w", u.Uid, err)
+	}
+
+	gid, err := strconv.Atoi(gidStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid gid %q: %w", gidStr, err)
+	}
+
+	return uid, gid, nil
+}
@@ -0,0 +1,40 @@
+package privileges
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"syscall"
+)
+
+// Drop permanently switches the process to the given user and group and removes all supplementary groups. It is a
+// no-op if the process already runs as the user.
+func Drop(username, group string) error {
+	uid, gid, err := lookupIds(username, group)
+	if err != nil {
+		return err
+	}
+
+	if os.Getuid() == uid && os.Getgid() == gid {
+		return nil
+	}
+
+	// the group needs to be changed first, as the unprivileged user is not allowed to do so anymore
+	if err := syscall.Setgroups([]int{}); err != nil {
+		return fmt.Errorf("could not drop supplementary groups: %w", err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("could not set gid %d: %w", gid, err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("could not set uid %d: %w", uid, err)
+	}
+
+	// make sure the privileges can not be regained
+	if err := syscall.Setuid(0); err == nil && uid != 0 {
+		return fmt.Errorf("privileges could be regained after dropping them")
+	}
+
+	slog.Info("Dropped privileges", "uid", uid, "gid", gid)
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package privileges
+
+import (
+	"errors"
+)
+
+// Drop is only supported on linux.
+func Drop(username, group string) error {
+	if _, _, err := lookupIds(username, group); err != nil {
+		return err
+	}
+	return errors.New("dropping privileges is only supported on linux")
+}
@@ -0,0 +1,33 @@
+package privileges
+
+import (
+	"testing"
+)
+
+func TestLookupIds(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		group   string
+		wantUid int
+		wantGid int
+		wantErr bool
+	}{
+		{name: "by name", user: "root", wantUid: 0, wantGid: 0},
+		{name: "by id", user: "0", group: "0", wantUid: 0, wantGid: 0},
+		{name: "unknown user", user: "dns-ha-does-not-exist", wantErr: true},
+		{name: "unknown group", user: "root", group: "dns-ha-does-not-exist", wantErr: true},
+		{name: "empty user", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uid, gid, err := lookupIds(tt.user, tt.group)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookupIds() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (uid != tt.wantUid || gid != tt.wantGid) {
+				t.Errorf("lookupIds() = %d, %d, want %d, %d", uid, gid, tt.wantUid, tt.wantGid)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package internal
+
+import (
+	"slices"
+)
+
+// publishedRecords returns the records that are currently published for the hostname, so they are reconciled with the
+// backend even while the selection is frozen, e.g. while all records are unhealthy and the last records are kept. The
+// boolean is false if the published records are not known or can not be reconstructed from the config.
+func (h *RecordManager) publishedRecords(hostname string, ips []*ManagedDnsRecord) ([]ManagedDnsRecord, bool) {
+	published, found := h.publishedIps[hostname]
+	if !found {
+		return nil, false
+	}
+
+	fallback, _ := h.fallbackRecord(hostname, ips)
+	ret := make([]ManagedDnsRecord, 0, len(published))
+	for _, address := range published {
+		index := slices.IndexFunc(ips, func(record *ManagedDnsRecord) bool {
+			return record.Ip.String() == address
+		})
+		switch {
+		case index >= 0:
+			ret = append(ret, *ips[index])
+		case fallback.Ip != nil && fallback.Ip.String() == address:
+			ret = append(ret, fallback)
+		default:
+			// the address has been removed from the config in the meantime
+			return nil, false
+		}
+	}
+	return ret, true
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+func TestRecordManager_publishedRecords(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    HostnamePolicy
+		published []string
+		want      []string
+		wantFound bool
+	}{
+		{
+			name:      "nothing published yet",
+			wantFound: false,
+		},
+		{
+			name:      "kept records are reasserted",
+			published: []string{"10.0.0.1"},
+			want:      []string{"A 10.0.0.1"},
+			wantFound: true,
+		},
+		{
+			name:      "fallback ip is reasserted",
+			policy:    HostnamePolicy{OnAllUnhealthy: AllUnhealthyFallback, FallbackIp: net.ParseIP("2001:db8::1")},
+			published: []string{"2001:db8::1"},
+			want:      []string{"AAAA 2001:db8::1"},
+			wantFound: true,
+		},
+		{
+			name:      "removed records stay removed",
+			published: []string{},
+			want:      []string{},
+			wantFound: true,
+		},
+		{
+			name:      "address not part of config anymore",
+			published: []string{"10.0.0.9"},
+			wantFound: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := map[string][]*ManagedDnsRecord{"my.tld": {
+				{DnsRecord: DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, Hostname: "my.tld", status: &status.Unhealthy{}},
+				{DnsRecord: DnsRecord{Priority: 10, DnsType: "A", Ip: net.ParseIP("10.0.0.2"), Ttl: 60}, Hostname: "my.tld", status: &status.Unhealthy{}},
+			}}
+			m, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, records, WithHostnamePolicies(map[string]HostnamePolicy{"my.tld": tt.policy}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.published != nil {
+				m.publishedIps["my.tld"] = tt.published
+			}
+
+			got, found := m.publishedRecords("my.tld", records["my.tld"])
+			if found != tt.wantFound {
+				t.Fatalf("publishedRecords() found = %v, want %v", found, tt.wantFound)
+			}
+			if !found {
+				return
+			}
+			addresses := []string{}
+			for _, record := range got {
+				addresses = append(addresses, record.DnsType+" "+record.Ip.String())
+			}
+			if !reflect.DeepEqual(addresses, tt.want) {
+				t.Errorf("publishedRecords() = %v, want %v", addresses, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordManager_reconcileKeptRecords(t *testing.T) {
+	db := &dummyDnsDb{}
+	records := map[string][]*ManagedDnsRecord{"my.tld": {
+		{DnsRecord: DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, Hostname: "my.tld", status: &status.Unhealthy{}},
+	}}
+	m, err := NewRecordManager(db, &dummyService{}, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.publishedIps["my.tld"] = []string{"10.0.0.1"}
+
+	// all records are unhealthy, the last records are kept and still handed to the backend to repair drift
+	m.applyRecords(context.Background())
+	if want := []string{"A 10.0.0.1"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Errorf("expected kept records to be reconciled, got %v", db.updates["my.tld"])
+	}
+}
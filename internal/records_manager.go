@@ -1,116 +1,619 @@
 package internal
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"log/slog"
+	"maps"
+	"math/rand/v2"
 	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/soerenschneider/dns-ha/internal/clock"
+	"github.com/soerenschneider/dns-ha/internal/conf"
 	"github.com/soerenschneider/dns-ha/internal/metrics"
 	"github.com/soerenschneider/dns-ha/internal/status"
+	"go.uber.org/multierr"
 )
 
+const (
+	defaultCheckInterval       = 30 * time.Second
+	defaultMaxConcurrentChecks = 32
+)
+
+// DnsDb publishes the records of hostnames.
 type DnsDb interface {
-	UpdateIps(dnsRecord string, addresses []ManagedDnsRecord) (bool, error)
+	// Apply writes the desired records of all given hostnames in a single update, hostnames that are not part of
+	// desired are left untouched. It returns true if the published records changed.
+	Apply(ctx context.Context, desired map[string][]ManagedDnsRecord) (bool, error)
+	// ValidateConfig validates the written records, it's called after all updates of a cycle have been applied.
 	ValidateConfig(ctx context.Context) error
 }
 
+// Service makes the DNS server pick up changed records. Implementations must return once the context is canceled.
 type Service interface {
-	Reload() error
-	Restart() error
+	Reload(ctx context.Context) error
+	Restart(ctx context.Context) error
+	// FlushCache purges the given names from the resolver cache, so stale answers disappear before their TTL
+	// expires. Implementations return ErrFlushNotSupported if they can not flush the cache.
+	FlushCache(ctx context.Context, names []string) error
+}
+
+// ReloadReporter is optionally implemented by Services to report whether they can be reloaded at all. Services that
+// can only be restarted are rejected in combination with RestartPolicyNever, as nothing would pick up changes.
+type ReloadReporter interface {
+	SupportsReload() bool
+}
+
+// Hooks are notified about changes of the published records and restarts of the service.
+type Hooks interface {
+	PreUpdate(ctx context.Context, hostname string, oldIps, newIps []string) error
+	PostUpdate(ctx context.Context, hostname string, oldIps, newIps []string) error
+	PostRestart(ctx context.Context, hostnames []string) error
 }
 
 type RecordManager struct {
 	dnsDb          DnsDb
 	dnsServiceUnit Service
 	managedRecords map[string][]*ManagedDnsRecord
+	// recordsMutex guards replacing managedRecords, which is only ever done by Run, against concurrent readers.
+	recordsMutex   sync.RWMutex
+	checkInterval  time.Duration
+	checkJitter    time.Duration
+	checkStagger   time.Duration
+	maxConcurrency int
+	hooks          Hooks
+	// backendTimeout bounds each call to the DNS backend and the service, so a stuck call can not block Run forever.
+	// Half the check interval is used if it's zero.
+	backendTimeout time.Duration
+	retries        conf.RetryConfig
+
+	hostnamePolicies map[string]HostnamePolicy
+
+	// outages contains the hostnames without healthy records.
+	outages      map[string]*outage
+	publishedIps map[string][]string
+	// publishedMutex guards writes to publishedIps, which are only ever done by Run, against concurrent readers.
+	publishedMutex sync.RWMutex
+	// pendingHostnames contains the hostnames whose changes could not be applied in the current cycle.
+	pendingHostnames map[string]bool
+	// incumbentsUntil is the point in time the records published at startup stop being protected.
+	incumbentsUntil time.Time
+	// graceUntil contains the end of the grace period of the addresses of each hostname that recently took over.
+	graceUntil map[string]map[string]time.Time
+	// blackouts contains the hostnames whose blackout window is active.
+	blackouts map[string]bool
+
+	reconcileRequests chan struct{}
+	recordsUpdates    chan recordsUpdate
+	stateExports      chan chan State
+	stateImports      chan stateImport
+
+	// checkSlots limits the amount of healthchecks running in parallel across all hostnames.
+	checkSlots chan struct{}
+	// checks shares the results of identical healthchecks across the check loops of the hostnames.
+	checks *checkCache
+	// checkResults receives the results of the check loops of the hostnames.
+	checkResults chan hostnameResults
+	// stopLoops cancels the check loops of the current generation, loops waits for them to return.
+	stopLoops      context.CancelFunc
+	loops          sync.WaitGroup
+	loopGeneration uint64
 
-	unhealthyHosts map[string]bool
+	// busySince is the unix timestamp in nanoseconds Run started handling the current event, zero while it's idle.
+	busySince atomic.Int64
+	// stopped is set once Run has returned.
+	stopped atomic.Bool
+
+	restartCoalesce    time.Duration
+	restartMinInterval time.Duration
+	restartTimer       clock.Timer
+	lastRestart        time.Time
+	pendingRestart     []string
+
+	restartPolicy               string
+	reloadFailuresBeforeRestart int
+	reloadFailures              int
+
+	guard        Healthcheck
+	guardTimeout time.Duration
+	// guardActive is read by the check loops, which don't probe while the guard is engaged.
+	guardActive atomic.Bool
+
+	failureInjection bool
+	shutdownPolicy   string
+
+	stateTxt bool
+	// stateTxtSince is the point in time the published addresses of each hostname last changed.
+	stateTxtSince map[string]stateSince
+
+	clock clock.Clock
 }
 
-func NewRecordManager(dnsDb DnsDb, dnsService Service, managedRecords map[string][]*ManagedDnsRecord) (*RecordManager, error) {
-	return &RecordManager{
+type RecordManagerOpts func(*RecordManager) error
+
+// NewRecordManager creates a RecordManager. The service may be nil for DNS servers that pick up changed records on
+// their own.
+func NewRecordManager(dnsDb DnsDb, dnsService Service, managedRecords map[string][]*ManagedDnsRecord, opts ...RecordManagerOpts) (*RecordManager, error) {
+	m := &RecordManager{
 		dnsDb:          dnsDb,
 		dnsServiceUnit: dnsService,
 		managedRecords: managedRecords,
-		unhealthyHosts: make(map[string]bool, len(managedRecords)),
-	}, nil
+		checkInterval:  defaultCheckInterval,
+		maxConcurrency: defaultMaxConcurrentChecks,
+		retries:        conf.RetryConfig{Attempts: 1},
+
+		restartPolicy:               RestartPolicyEscalate,
+		shutdownPolicy:              ShutdownKeep,
+		reloadFailuresBeforeRestart: 1,
+		outages:                     map[string]*outage{},
+		publishedIps:                make(map[string][]string, len(managedRecords)),
+		pendingHostnames:            map[string]bool{},
+		graceUntil:                  map[string]map[string]time.Time{},
+		blackouts:                   map[string]bool{},
+
+		reconcileRequests: make(chan struct{}, 1),
+		recordsUpdates:    make(chan recordsUpdate, 1),
+		stateExports:      make(chan chan State),
+		stateImports:      make(chan stateImport),
+		checkResults:      make(chan hostnameResults),
+		clock:             clock.Real(),
+	}
+
+	var errs error
+	for _, opt := range opts {
+		if err := opt(m); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+	if err := m.checkRestartPolicy(); err != nil {
+		errs = multierr.Append(errs, err)
+	}
+	m.checkSlots = make(chan struct{}, m.maxConcurrency)
+	m.exposeStrategies()
+	m.useClock(managedRecords)
+
+	return m, errs
 }
 
-func (h *RecordManager) CheckRecords(ctx context.Context) {
-	h.runHealthchecks(ctx)
+// WithCheckInterval sets the interval between check cycles. A single cycle of healthchecks is never allowed to
+// take longer than the interval.
+func WithCheckInterval(interval time.Duration) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		if interval <= 0 {
+			return errors.New("check interval must be positive")
+		}
+		m.checkInterval = interval
+		return nil
+	}
+}
 
-	restartServiceNeeded := false
-	for hostname, ips := range h.managedRecords {
-		if h.updateRecords(ctx, hostname, ips) {
-			restartServiceNeeded = true
+// WithCheckJitter delays each healthcheck by a random duration in [0, jitter) to avoid synchronized probes.
+func WithCheckJitter(jitter time.Duration) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		if jitter < 0 {
+			return errors.New("check jitter must not be negative")
 		}
+		m.checkJitter = jitter
+		return nil
 	}
+}
 
-	if restartServiceNeeded {
-		if err := h.restartService(); err != nil {
-			metrics.Errors.WithLabelValues("", "service_restart").Inc()
-			slog.Error("could not restart service")
+// WithCheckStagger spreads the healthchecks of all records evenly across the given window of each cycle.
+func WithCheckStagger(window time.Duration) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		if window < 0 {
+			return errors.New("check stagger must not be negative")
 		}
+		m.checkStagger = window
+		return nil
 	}
 }
 
-func (h *RecordManager) updateRecords(ctx context.Context, hostname string, ips []*ManagedDnsRecord) bool {
-	ipsToUpdate := filterHealthyIps(hostname, ips)
-	if len(ipsToUpdate) == 0 {
-		if !h.unhealthyHosts[hostname] && !isInitialState(ips) {
-			slog.Warn("No healthy IPs detected", "hostname", hostname)
-			h.unhealthyHosts[hostname] = true
+// WithMaxConcurrentChecks limits the amount of healthchecks that are running at the same time.
+func WithMaxConcurrentChecks(limit int) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		if limit <= 0 {
+			return errors.New("max concurrent checks must be positive")
 		}
+		m.maxConcurrency = limit
+		return nil
+	}
+}
+
+// WithBackendTimeout limits how long a single update of the DNS backend and a single reload, restart or cache flush
+// of the service may take, defaults to half the check interval. It must be lower than the check interval, otherwise a
+// single stuck call makes IsAlive report a deadlock.
+func WithBackendTimeout(timeout time.Duration) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		if timeout <= 0 {
+			return errors.New("backend timeout must be positive")
+		}
+		m.backendTimeout = timeout
+		return nil
+	}
+}
+
+// backendCallTimeout returns the timeout of a single call to the DNS backend or the service.
+func (h *RecordManager) backendCallTimeout() time.Duration {
+	return cmp.Or(h.backendTimeout, h.checkInterval/2)
+}
+
+// WithClock replaces the real clock, e.g. to simulate long periods of time in tests. The clock is shared with the
+// managed records.
+func WithClock(c clock.Clock) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		if c == nil {
+			return errors.New("nil clock supplied")
+		}
+		m.clock = c
+		return nil
+	}
+}
+
+// useClock makes the records use the clock of the manager.
+func (h *RecordManager) useClock(records map[string][]*ManagedDnsRecord) {
+	for _, hostnameRecords := range records {
+		for _, record := range hostnameRecords {
+			record.clock = h.clock
+		}
+	}
+}
+
+// WithHooks registers hooks that are run before and after records are changed and after the service is restarted.
+func WithHooks(hooks Hooks) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		if hooks == nil {
+			return errors.New("nil hooks supplied")
+		}
+		m.hooks = hooks
+		return nil
+	}
+}
+
+// Run checks all records immediately and then each hostname independently once every check interval until the context
+// is canceled. Run is the only one changing the state of records and publishing them, the check loops of the
+// hostnames merely hand over the results of their healthchecks.
+func (h *RecordManager) Run(ctx context.Context) {
+	ticker := h.clock.NewTicker(h.checkInterval)
+	defer ticker.Stop()
+	defer h.stopped.Store(true)
+
+	h.busySince.Store(h.clock.Now().UnixNano())
+	h.dropExpiredRecords(ctx)
+	h.loadIncumbents()
+	h.CheckRecords(ctx)
+	h.startCheckLoops(ctx)
+	for {
+		h.busySince.Store(0)
+		select {
+		case <-ctx.Done():
+			h.stopCheckLoops()
+			shutdownCtx := context.WithoutCancel(ctx)
+			h.applyShutdownPolicy(shutdownCtx)
+			// do not leave records behind that have been written but not yet been picked up by the service
+			h.executeRestart(shutdownCtx)
+			// the series describe records that are not maintained anymore, exporters that outlive the process must
+			// not keep them
+			for hostname, records := range h.managedRecords {
+				for _, record := range records {
+					closeCheck(record)
+				}
+				metrics.DeleteHostname(hostname)
+			}
+			return
+		case <-ticker.C():
+			h.markBusy()
+			h.removeExpiredRecords(ctx)
+			h.guardEngaged(ctx)
+		case results := <-h.checkResults:
+			h.markBusy()
+			h.applyCheckResults(ctx, results)
+		case <-h.reconcileRequests:
+			h.markBusy()
+			slog.Info("Reconciling records with DNS backend")
+			h.applyRecords(ctx)
+		case update := <-h.recordsUpdates:
+			h.markBusy()
+			slog.Info("Replacing managed records")
+			h.stopCheckLoops()
+			h.replaceRecords(ctx, update)
+			h.startCheckLoops(ctx)
+		case reply := <-h.stateExports:
+			reply <- h.exportState()
+		case request := <-h.stateImports:
+			h.markBusy()
+			request.done <- h.importState(ctx, request.state)
+		case <-h.restartDue():
+			h.markBusy()
+			h.executeRestart(ctx)
+		}
+	}
+}
+
+func (h *RecordManager) markBusy() {
+	h.busySince.Store(h.clock.Now().UnixNano())
+}
+
+// IsAlive returns false if Run has been handling a single event for more than two check intervals, which hints at a
+// deadlock, or if Run has returned. A single cycle of checks can not take longer than one check interval.
+func (h *RecordManager) IsAlive() bool {
+	if h.stopped.Load() {
 		return false
 	}
+	busySince := h.busySince.Load()
+	return busySince == 0 || h.clock.Since(time.Unix(0, busySince)) < 2*h.checkInterval
+}
+
+// RequestReconcile asks Run to re-apply the current selection of records to the DNS backend without running
+// healthchecks, e.g. after the backend has been modified externally. It never blocks.
+func (h *RecordManager) RequestReconcile() {
+	select {
+	case h.reconcileRequests <- struct{}{}:
+	default:
+	}
+}
+
+// CheckRecords checks the records of all hostnames at once and applies the results.
+func (h *RecordManager) CheckRecords(ctx context.Context) {
+	if h.guardEngaged(ctx) {
+		return
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, h.checkInterval)
+	h.runHealthchecks(checkCtx)
+	cancel()
+
+	h.applyRecords(ctx)
+}
+
+// applyRecords publishes the records of the given hostnames, or of all hostnames if none are given.
+func (h *RecordManager) applyRecords(ctx context.Context, hostnames ...string) {
+	var updatedHostnames []string
+	if len(hostnames) == 0 {
+		clear(h.pendingHostnames)
+	}
+	for _, hostname := range hostnames {
+		delete(h.pendingHostnames, hostname)
+	}
+	previousIps := maps.Clone(h.publishedIps)
+	var announced []string
+	for _, wave := range h.hostnameWaves() {
+		desired := make(map[string][]ManagedDnsRecord, len(wave))
+		for _, hostname := range wave {
+			if len(hostnames) > 0 && !slices.Contains(hostnames, hostname) {
+				continue
+			}
+			if records, ok := h.desiredRecords(ctx, hostname, h.managedRecords[hostname]); ok {
+				desired[hostname] = h.withStateTxt(hostname, records)
+			}
+		}
+		updated, announcedWave := h.apply(ctx, desired, previousIps)
+		updatedHostnames = append(updatedHostnames, updated...)
+		announced = append(announced, announcedWave...)
+	}
 
-	if h.unhealthyHosts[hostname] {
-		slog.Info("Records for hostname recovered from unhealthy state", "hostname", hostname)
-		h.unhealthyHosts[hostname] = false
+	if len(updatedHostnames) == 0 {
+		h.postUpdate(ctx, announced, previousIps)
+		return
 	}
 
-	updated, err := h.dnsDb.UpdateIps(hostname, ipsToUpdate)
+	if err := h.validateConfig(ctx); err != nil {
+		slog.Error("updated dns config produced error", "hostnames", updatedHostnames, "err", err)
+		for _, hostname := range updatedHostnames {
+			metrics.Errors.WithLabelValues(hostname, "dns_invalid_config", ErrorKind(err)).Inc()
+			h.pendingHostnames[hostname] = true
+		}
+		h.updateFailed(ctx, announced, previousIps, h.publishedIps, err)
+		return
+	}
+
+	h.postUpdate(ctx, announced, previousIps)
+	h.requestRestart(ctx, updatedHostnames)
+}
+
+// apply publishes the desired records of all hostnames in a single update and returns the hostnames whose records
+// changed in the backend along with the hostnames the pre_update hooks have been run for, which need to be followed
+// by the post_update or the update_failed hooks.
+func (h *RecordManager) apply(ctx context.Context, desired map[string][]ManagedDnsRecord, previousIps map[string][]string) ([]string, []string) {
+	if len(desired) == 0 {
+		return nil, nil
+	}
+
+	announced := h.preUpdate(ctx, desired, previousIps)
+	changed, err := h.applyDesired(ctx, desired)
 	if err != nil {
-		metrics.Errors.WithLabelValues(hostname, "update_ips").Inc()
-		slog.Error("could not update active IPs", "hostname", hostname)
-		return false
+		slog.Error("could not update active IPs", "hostnames", slices.Sorted(maps.Keys(desired)), "err", err)
+		for hostname := range desired {
+			metrics.Errors.WithLabelValues(hostname, "update_ips", ErrorKind(err)).Inc()
+			h.pendingHostnames[hostname] = true
+		}
+		newIps := make(map[string][]string, len(announced))
+		for _, hostname := range announced {
+			newIps[hostname] = sortedIps(desired[hostname])
+		}
+		h.updateFailed(ctx, announced, previousIps, newIps, err)
+		return nil, nil
 	}
 
-	if updated {
-		ipsToUpdateLog := make([]string, len(ipsToUpdate))
-		for index, ip := range ipsToUpdate {
-			ipsToUpdateLog[index] = ip.Ip.String()
+	var updated []string
+	h.publishedMutex.Lock()
+	for hostname, records := range desired {
+		ips := sortedIps(records)
+		h.publishedIps[hostname] = ips
+		if !slices.Equal(previousIps[hostname], ips) {
+			updated = append(updated, hostname)
+			h.startGracePeriods(hostname, previousIps[hostname], ips)
 		}
-		slog.Info("Updating DNS records", "hostname", hostname, "ips", ipsToUpdateLog)
+	}
+	h.publishedMutex.Unlock()
 
-		if err := h.dnsDb.ValidateConfig(ctx); err != nil {
-			metrics.Errors.WithLabelValues(hostname, "dns_invalid_config").Inc()
-			slog.Error("updated unbound config produced error", "err", err)
-		} else {
-			return true
+	if !changed {
+		return nil, announced
+	}
+	// the backend restored records that deviated from the selection, e.g. after manual edits
+	if len(updated) == 0 {
+		metrics.DriftRepairs.Inc()
+		slog.Warn("Repaired records that deviated from the desired state")
+		updated = slices.Collect(maps.Keys(desired))
+	}
+	slices.Sort(updated)
+	for _, hostname := range updated {
+		slog.Info("Updating DNS records", "hostname", hostname, "ips", h.publishedIps[hostname])
+	}
+	metrics.NotifyChange()
+	return updated, announced
+}
+
+func (h *RecordManager) applyDesired(ctx context.Context, desired map[string][]ManagedDnsRecord) (bool, error) {
+	var changed bool
+	err := h.withRetries(ctx, "apply", func(ctx context.Context) error {
+		updated, err := h.dnsDb.Apply(ctx, desired)
+		// an earlier attempt may have partially applied the records
+		changed = changed || updated
+		return err
+	})
+	return changed, classify(err, ErrBackendUnavailable)
+}
+
+func (h *RecordManager) validateConfig(ctx context.Context) error {
+	return classify(h.withRetries(ctx, "validate", h.dnsDb.ValidateConfig), ErrValidationFailed)
+}
+
+// desiredRecords returns the records that should be published for the hostname and false if its records should be
+// left untouched. While the selection is frozen, the published records are returned, so deviations of the backend are
+// repaired every cycle.
+func (h *RecordManager) desiredRecords(ctx context.Context, hostname string, ips []*ManagedDnsRecord) ([]ManagedDnsRecord, bool) {
+	// the selection is not needed while it's frozen, which spares the decision engine
+	if h.inBlackout(hostname) || h.keepIncumbents(hostname, ips) || h.withinGracePeriod(hostname, ips) {
+		return h.publishedRecords(hostname, ips)
+	}
+
+	ipsToUpdate, selected := h.selectRecords(ctx, hostname, ips)
+	if !selected {
+		return h.publishedRecords(hostname, ips)
+	}
+
+	if len(ipsToUpdate) == 0 {
+		if isInitialState(ips) {
+			return h.publishedRecords(hostname, ips)
+		}
+
+		if _, found := h.outages[hostname]; !found {
+			slog.Warn("No healthy IPs detected", "hostname", hostname, "policy", h.allUnhealthyPolicy(hostname))
+			h.outages[hostname] = &outage{since: h.clock.Now()}
 		}
+		h.escalateOutage(ctx, hostname)
+
+		var publishFallback bool
+		ipsToUpdate, publishFallback = h.fallbackRecords(hostname, ips)
+		if !publishFallback {
+			return h.publishedRecords(hostname, ips)
+		}
+		ipsToUpdate = h.keepAddressFamilies(hostname, ips, ipsToUpdate)
+	} else {
+		if _, found := h.outages[hostname]; found {
+			slog.Info("Records for hostname recovered from unhealthy state", "hostname", hostname)
+			resetFallbackMetrics(hostname)
+			h.resolveOutage(ctx, hostname)
+		}
+		ipsToUpdate = h.keepAddressFamilies(hostname, ips, ipsToUpdate)
+		ipsToUpdate = h.limitRecords(hostname, ips, ipsToUpdate)
+	}
+
+	oldIps := h.publishedIps[hostname]
+	newIps := sortedIps(ipsToUpdate)
+
+	selectionChanged := !slices.Equal(oldIps, newIps)
+	if selectionChanged && h.deferChange(hostname) {
+		return h.publishedRecords(hostname, ips)
 	}
 
-	return false
+	return ipsToUpdate, true
+}
+
+func sortedIps(records []ManagedDnsRecord) []string {
+	ips := make([]string, 0, len(records))
+	for _, record := range records {
+		if record.Ip != nil {
+			ips = append(ips, record.Ip.String())
+		}
+	}
+	slices.Sort(ips)
+	return ips
 }
 
 func (h *RecordManager) runHealthchecks(ctx context.Context) {
-	wg := &sync.WaitGroup{}
-	for _, candidates := range h.managedRecords {
-		for _, candidate := range candidates {
-			wg.Add(1)
-			go candidate.Eval(ctx, wg)
+	records := h.sortedRecords()
+	now := h.clock.Now()
+	records = slices.DeleteFunc(records, func(record *ManagedDnsRecord) bool {
+		if record.ShouldCheck(now) {
+			return false
 		}
+		metrics.ChecksSkipped.WithLabelValues(record.Hostname, record.Ip.String()).Inc()
+		return true
+	})
+	ctx = withCheckCache(ctx, newCheckCache(), h.checkInterval)
+
+	wg := &sync.WaitGroup{}
+	for index, candidate := range records {
+		wg.Add(1)
+		go func(delay time.Duration) {
+			select {
+			case <-ctx.Done():
+				wg.Done()
+				return
+			case <-h.clock.After(delay):
+			}
+
+			select {
+			case <-ctx.Done():
+				wg.Done()
+			case h.checkSlots <- struct{}{}:
+				defer func() { <-h.checkSlots }()
+				candidate.Eval(ctx, wg)
+			}
+		}(h.probeDelay(index, len(records)))
 	}
 
 	wg.Wait()
 }
 
+// sortedRecords returns all managed records in a stable order, so each record keeps its phase across cycles.
+func (h *RecordManager) sortedRecords() []*ManagedDnsRecord {
+	hostnames := make([]string, 0, len(h.managedRecords))
+	for hostname := range h.managedRecords {
+		hostnames = append(hostnames, hostname)
+	}
+	slices.Sort(hostnames)
+
+	var records []*ManagedDnsRecord
+	for _, hostname := range hostnames {
+		records = append(records, h.managedRecords[hostname]...)
+	}
+
+	return records
+}
+
+func (h *RecordManager) probeDelay(index, total int) time.Duration {
+	var delay time.Duration
+	if h.checkStagger > 0 && total > 0 {
+		delay = h.checkStagger * time.Duration(index) / time.Duration(total)
+	}
+
+	if h.checkJitter > 0 {
+		delay += rand.N(h.checkJitter) //nolint G404
+	}
+
+	return delay
+}
+
 func isInitialState(ips []*ManagedDnsRecord) bool {
 	for _, ip := range ips {
-		if ip.GetState().Name() != status.InitialStateName {
+		if status.Effective(ip.GetState()).Name() != status.InitialStateName {
 			return false
 		}
 	}
@@ -118,31 +621,24 @@ func isInitialState(ips []*ManagedDnsRecord) bool {
 	return true
 }
 
-func filterHealthyIps(hostname string, ips []*ManagedDnsRecord) []ManagedDnsRecord {
-	healthyIps := make(map[string][]ManagedDnsRecord, len(ips))
+func filterHealthyIps(hostname string, ips []*ManagedDnsRecord, strategy Strategy) []ManagedDnsRecord {
+	healthy := make([]ManagedDnsRecord, 0, len(ips))
 	for _, ip := range ips {
-		if ip.GetState().Name() == status.HealthyStateName {
-			_, found := healthyIps[ip.DnsType]
-			if !found {
-				healthyIps[ip.DnsType] = []ManagedDnsRecord{}
-			}
-			healthyIps[ip.DnsType] = append(healthyIps[ip.DnsType], *ip)
+		if status.Effective(ip.GetState()).Name() == status.HealthyStateName && !ip.InMaintenance() {
+			healthy = append(healthy, *ip)
 		}
 	}
 
 	activeIps := make(map[string]bool, len(ips))
 	defer updateMetrics(hostname, ips, activeIps)
-	if len(healthyIps) == 0 {
+	if len(healthy) == 0 {
 		return nil
 	}
 
-	ipsToUpdate := make([]ManagedDnsRecord, 0, len(ips))
-	for _, healthyRecordsByDnsType := range healthyIps {
-		if len(healthyRecordsByDnsType) > 0 {
-			slices.SortFunc(healthyRecordsByDnsType, PriorityComparator)
-			ipsToUpdate = append(ipsToUpdate, healthyRecordsByDnsType[0])
-			activeIps[healthyRecordsByDnsType[0].Ip.String()] = true
-		}
+	slices.SortStableFunc(healthy, PriorityComparator)
+	ipsToUpdate := strategy.Select(hostname, healthy)
+	for _, ip := range ipsToUpdate {
+		activeIps[ip.Ip.String()] = true
 	}
 
 	return ipsToUpdate
@@ -164,17 +660,3 @@ func updateMetrics(hostname string, ips []*ManagedDnsRecord, activeIps map[strin
 	metrics.ActiveRecords.WithLabelValues(hostname).Set(float64(cntActive))
 	metrics.ConfiguredRecords.WithLabelValues(hostname).Set(float64(len(ips)))
 }
-
-func (h *RecordManager) restartService() error {
-	if err := h.dnsServiceUnit.Reload(); err != nil {
-		if !errors.Is(err, ErrReloadNotSupported) {
-			slog.Error("could not reload service", "err", err)
-		}
-
-		if err := h.dnsServiceUnit.Restart(); err != nil {
-			return err
-		}
-	}
-
-	return nil
-}
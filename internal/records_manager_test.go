@@ -4,8 +4,11 @@ import (
 	"context"
 	"net"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/soerenschneider/dns-ha/internal/conf"
 	"github.com/soerenschneider/dns-ha/internal/status"
 )
 
@@ -83,9 +86,155 @@ func Test_getHealthyIps(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := filterHealthyIps(tt.args.hostname, tt.args.ips); !reflect.DeepEqual(got, tt.want) {
+			if got := filterHealthyIps(tt.args.hostname, tt.args.ips, priorityStrategy{}); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("filterHealthyIps() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestRecordManager_probeDelay(t *testing.T) {
+	m := &RecordManager{checkStagger: 10 * time.Second}
+
+	want := []time.Duration{0, 2500 * time.Millisecond, 5 * time.Second, 7500 * time.Millisecond}
+	for index, expected := range want {
+		if got := m.probeDelay(index, len(want)); got != expected {
+			t.Errorf("probeDelay(%d) = %v, want %v", index, got, expected)
+		}
+	}
+
+	m.checkJitter = time.Second
+	for i := 0; i < 100; i++ {
+		if got := m.probeDelay(1, len(want)); got < want[1] || got >= want[1]+time.Second {
+			t.Fatalf("probeDelay() with jitter = %v, want within [%v, %v)", got, want[1], want[1]+time.Second)
+		}
+	}
+}
+
+func TestRecordManager_IsAlive(t *testing.T) {
+	m, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, nil, WithCheckInterval(10*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.IsAlive() {
+		t.Error("expected idle manager to be alive")
+	}
+
+	m.busySince.Store(time.Now().Add(-5 * time.Second).UnixNano())
+	if !m.IsAlive() {
+		t.Error("expected manager to be alive while handling an event")
+	}
+
+	m.busySince.Store(time.Now().Add(-time.Minute).UnixNano())
+	if m.IsAlive() {
+		t.Error("expected stuck manager not to be alive")
+	}
+
+	m.busySince.Store(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.Run(ctx)
+	if m.IsAlive() {
+		t.Error("expected manager not to be alive after Run returned")
+	}
+}
+
+// blockingHealthcheck blocks until its context is done.
+type blockingHealthcheck struct{}
+
+func (b *blockingHealthcheck) IsHealthy(ctx context.Context) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func TestRecordManager_CheckRecords_cappedAtInterval(t *testing.T) {
+	statusConf := conf.StatusConfig{HealthyStreak: 1, UnhealthyStreak: 1, InitialHealthyStreak: 1, InitialUnhealthyStreak: 1}
+	record, err := NewManagedDnsRecord("my.tld", DnsRecord{DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, statusConf, &blockingHealthcheck{}, WithCheckTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const interval = 50 * time.Millisecond
+	m, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, map[string][]*ManagedDnsRecord{"my.tld": {record}}, WithCheckInterval(interval))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	m.CheckRecords(context.Background())
+	if elapsed := time.Since(start); elapsed >= 10*interval {
+		t.Fatalf("expected the cycle to be capped at the check interval %v, took %v", interval, elapsed)
+	}
+	if state := record.GetState().Name(); state == status.HealthyStateName {
+		t.Errorf("expected the blocked check not to be healthy, got %s", state)
+	}
+}
+
+// concurrencyHealthcheck tracks the maximum amount of checks that run at the same time.
+type concurrencyHealthcheck struct {
+	mutex   sync.Mutex
+	running int
+	max     int
+}
+
+func (c *concurrencyHealthcheck) IsHealthy(_ context.Context) (bool, error) {
+	c.mutex.Lock()
+	c.running++
+	c.max = max(c.max, c.running)
+	c.mutex.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	c.mutex.Lock()
+	c.running--
+	c.mutex.Unlock()
+	return true, nil
+}
+
+func TestRecordManager_runHealthchecks_maxConcurrency(t *testing.T) {
+	statusConf := conf.StatusConfig{HealthyStreak: 1, UnhealthyStreak: 1, InitialHealthyStreak: 1, InitialUnhealthyStreak: 1}
+	check := &concurrencyHealthcheck{}
+
+	var records []*ManagedDnsRecord
+	for i := range 10 {
+		record, err := NewManagedDnsRecord("my.tld", DnsRecord{DnsType: "A", Ip: net.IPv4(10, 0, 0, byte(i+1)), Ttl: 60}, statusConf, check)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	const limit = 3
+	m, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, map[string][]*ManagedDnsRecord{"my.tld": records}, WithMaxConcurrentChecks(limit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.runHealthchecks(context.Background())
+	if check.max > limit {
+		t.Errorf("expected at most %d concurrent checks, got %d", limit, check.max)
+	}
+	for _, record := range records {
+		if state := record.GetState().Name(); state != status.HealthyStateName {
+			t.Errorf("expected all records to be checked, %s is %s", record.Ip, state)
+		}
+	}
+}
+
+func TestRecordManager_backendCallTimeout(t *testing.T) {
+	m, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, nil, WithCheckInterval(10*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.backendCallTimeout(); got != 5*time.Second {
+		t.Errorf("expected half the check interval by default, got %v", got)
+	}
+
+	if err := WithBackendTimeout(2 * time.Second)(m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.backendCallTimeout(); got != 2*time.Second {
+		t.Errorf("expected the configured timeout, got %v", got)
+	}
+}
@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+)
+
+type recordsUpdate struct {
+	records  map[string][]*ManagedDnsRecord
+	policies map[string]HostnamePolicy
+}
+
+// ReplaceRecords asks Run to replace the managed records and hostname policies, e.g. after the config has been
+// changed. Records of hostnames that are not managed anymore are removed from the DNS backend. Records that should
+// keep their state need to be passed as the same instances. It never blocks, a pending update is replaced.
+func (h *RecordManager) ReplaceRecords(records map[string][]*ManagedDnsRecord, policies map[string]HostnamePolicy) {
+	update := recordsUpdate{records: records, policies: policies}
+	for {
+		select {
+		case h.recordsUpdates <- update:
+			return
+		default:
+		}
+
+		// drop the pending update, the latest one wins
+		select {
+		case <-h.recordsUpdates:
+		default:
+		}
+	}
+}
+
+func (h *RecordManager) replaceRecords(ctx context.Context, update recordsUpdate) {
+	// expired records may still be part of the config
+	update.records, _ = h.withoutExpired(update.records)
+	var removedHostnames []string
+	for hostname, records := range h.managedRecords {
+		updated, found := update.records[hostname]
+		for _, record := range records {
+			if !slices.Contains(updated, record) {
+				closeCheck(record)
+			}
+		}
+		if !found {
+			removedHostnames = append(removedHostnames, hostname)
+			continue
+		}
+		h.forgetRemovedRecords(hostname, records, updated)
+	}
+	slices.Sort(removedHostnames)
+
+	h.useClock(update.records)
+	h.recordsMutex.Lock()
+	h.managedRecords = update.records
+	h.recordsMutex.Unlock()
+	h.hostnamePolicies = update.policies
+	h.exposeStrategies()
+
+	h.removeHostnames(ctx, removedHostnames)
+	h.CheckRecords(ctx)
+}
+
+// removeHostnames drops the state of hostnames that are not managed anymore and removes their records from the DNS
+// backend.
+func (h *RecordManager) removeHostnames(ctx context.Context, removedHostnames []string) {
+	removed := make(map[string][]ManagedDnsRecord, len(removedHostnames))
+	for _, hostname := range removedHostnames {
+		slog.Info("Removing records of hostname that is not managed anymore", "hostname", hostname)
+		h.publishedMutex.Lock()
+		delete(h.publishedIps, hostname)
+		h.publishedMutex.Unlock()
+		delete(h.outages, hostname)
+		delete(h.graceUntil, hostname)
+		delete(h.blackouts, hostname)
+		metrics.DeleteHostname(hostname)
+		removed[hostname] = nil
+	}
+
+	if len(removed) == 0 {
+		return
+	}
+
+	updated, err := h.applyDesired(ctx, removed)
+	if err != nil {
+		for _, hostname := range removedHostnames {
+			metrics.Errors.WithLabelValues(hostname, "update_ips", ErrorKind(err)).Inc()
+		}
+		slog.Error("could not remove records", "hostnames", removedHostnames, "err", err)
+	} else if updated {
+		if err := h.validateConfig(ctx); err != nil {
+			slog.Error("removing records produced invalid config", "err", err)
+		} else {
+			h.requestRestart(ctx, removedHostnames)
+		}
+	}
+}
+
+// closeCheck stops the background work of the healthcheck of a record that is not managed anymore, e.g. the probes
+// of a sampled checker.
+func closeCheck(record *ManagedDnsRecord) {
+	closer, ok := record.healthCheck.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		slog.Warn("Could not close healthcheck", "hostname", record.Hostname, "ip", record.Ip, "err", err)
+	}
+}
+
+// forgetRemovedRecords drops the state and the metrics of the records of a hostname that are not managed anymore.
+func (h *RecordManager) forgetRemovedRecords(hostname string, current, updated []*ManagedDnsRecord) {
+	for _, record := range current {
+		ip := record.Ip.String()
+		if slices.ContainsFunc(updated, func(r *ManagedDnsRecord) bool { return r.Ip.String() == ip }) {
+			continue
+		}
+		delete(h.graceUntil[hostname], ip)
+		metrics.DeleteRecord(hostname, ip)
+	}
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+)
+
+type closingHealthcheck struct {
+	dummyHealthcheck
+	closed bool
+}
+
+func (c *closingHealthcheck) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRecordManager_replaceRecords(t *testing.T) {
+	checks := map[string]*closingHealthcheck{}
+	newRecord := func(hostname, ip string) *ManagedDnsRecord {
+		checks[ip] = &closingHealthcheck{dummyHealthcheck: dummyHealthcheck{ret: true}}
+		record, err := NewManagedDnsRecord(hostname, DnsRecord{Priority: 10, DnsType: "A", Ip: net.ParseIP(ip), Ttl: 60}, conf.StatusConfig{
+			HealthyStreak:          1,
+			UnhealthyStreak:        1,
+			InitialHealthyStreak:   1,
+			InitialUnhealthyStreak: 1,
+		}, checks[ip])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record
+	}
+
+	kept := newRecord("a.tld", "10.0.0.1")
+	db := &dummyDnsDb{}
+	svc := &dummyService{}
+	m, err := NewRecordManager(db, svc, map[string][]*ManagedDnsRecord{
+		"a.tld": {kept, newRecord("a.tld", "10.0.0.9")},
+		"b.tld": {newRecord("b.tld", "10.0.0.2")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.CheckRecords(context.Background())
+	if len(db.updates["b.tld"]) != 1 {
+		t.Fatalf("expected b.tld to be published, got %v", db.updates)
+	}
+
+	m.ReplaceRecords(map[string][]*ManagedDnsRecord{
+		"a.tld": {kept},
+		"c.tld": {newRecord("c.tld", "10.0.0.3")},
+	}, nil)
+	m.replaceRecords(context.Background(), <-m.recordsUpdates)
+
+	if got := db.updates["b.tld"]; len(got) != 0 {
+		t.Errorf("expected records of b.tld to be removed, got %v", got)
+	}
+	if got := db.updates["c.tld"]; len(got) != 1 || got[0] != "A 10.0.0.3" {
+		t.Errorf("expected c.tld to be published, got %v", got)
+	}
+	if _, found := m.publishedIps["b.tld"]; found {
+		t.Error("expected published IPs of b.tld to be forgotten")
+	}
+	if svc.reloads == 0 {
+		t.Error("expected service to pick up the removed records")
+	}
+	// DeleteLabelValues reports whether the series existed
+	if metrics.ActiveRecord.DeleteLabelValues("a.tld", "10.0.0.9") {
+		t.Error("expected series of the removed record of a.tld to be deleted")
+	}
+	if !metrics.ActiveRecord.DeleteLabelValues("a.tld", "10.0.0.1") {
+		t.Error("expected series of the kept record of a.tld to be retained")
+	}
+	for ip, wantClosed := range map[string]bool{"10.0.0.1": false, "10.0.0.9": true, "10.0.0.2": true, "10.0.0.3": false} {
+		if checks[ip].closed != wantClosed {
+			t.Errorf("expected healthcheck of %s closed=%v", ip, wantClosed)
+		}
+	}
+}
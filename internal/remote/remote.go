@@ -0,0 +1,167 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	SchemeHttp    = "http"
+	SchemeHttps   = "https"
+	SchemeS3      = "s3"
+	SchemeEtcd    = "etcd"
+	SchemeEtcds   = "etcds"
+	SchemeConsul  = "consul"
+	SchemeConsuls = "consuls"
+
+	maxConfigSize = 10 << 20
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// IsRemote returns true if the location refers to a config that is fetched by Fetch rather than read from disk.
+func IsRemote(location string) bool {
+	scheme, _, found := strings.Cut(location, "://")
+	if !found {
+		return false
+	}
+
+	switch scheme {
+	case SchemeHttp, SchemeHttps, SchemeS3, SchemeEtcd, SchemeEtcds, SchemeConsul, SchemeConsuls:
+		return true
+	}
+	return false
+}
+
+// Fetch reads the content stored at the location. Supported locations are
+//   - http(s)://host/path, credentials may be supplied as part of the URL
+//   - s3://bucket/key, credentials and region are read from the usual AWS_* environment variables
+//   - etcd(s)://host:port/key, read via etcd's v3 JSON API
+//   - consul(s)://host:port/key, the token is read from CONSUL_HTTP_TOKEN
+func Fetch(ctx context.Context, location string) ([]byte, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case SchemeHttp, SchemeHttps:
+		return fetchHttp(ctx, u)
+	case SchemeS3:
+		return fetchS3(ctx, u)
+	case SchemeEtcd, SchemeEtcds:
+		return fetchEtcd(ctx, u)
+	case SchemeConsul, SchemeConsuls:
+		return fetchConsul(ctx, u)
+	default:
+		return nil, fmt.Errorf("unsupported config location %q", location)
+	}
+}
+
+func fetchHttp(ctx context.Context, u *url.URL) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return do(req)
+}
+
+func fetchEtcd(ctx context.Context, u *url.URL) ([]byte, error) {
+	payload, err := json.Marshal(map[string]string{
+		"key": base64.StdEncoding.EncodeToString([]byte(u.Path)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := url.URL{Scheme: httpScheme(u.Scheme == SchemeEtcds), Host: u.Host, Path: "/v3/kv/range"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if u.User != nil {
+		password, _ := u.User.Password()
+		req.SetBasicAuth(u.User.Username(), password)
+	}
+
+	body, err := do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Kvs []struct {
+			Value string `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("could not parse etcd response: %w", err)
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, fmt.Errorf("key %q not found in etcd", u.Path)
+	}
+
+	return base64.StdEncoding.DecodeString(resp.Kvs[0].Value)
+}
+
+func fetchConsul(ctx context.Context, u *url.URL) ([]byte, error) {
+	endpoint := url.URL{
+		Scheme:   httpScheme(u.Scheme == SchemeConsuls),
+		Host:     u.Host,
+		Path:     "/v1/kv/" + strings.TrimPrefix(u.Path, "/"),
+		RawQuery: "raw",
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if token := os.Getenv("CONSUL_HTTP_TOKEN"); token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+
+	return do(req)
+}
+
+func httpScheme(useTls bool) string {
+	if useTls {
+		return SchemeHttps
+	}
+	return SchemeHttp
+}
+
+func do(req *http.Request) ([]byte, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s returned status %d: %s", req.URL.Redacted(), resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxConfigSize {
+		return nil, errors.New("config exceeds maximum size")
+	}
+	return body, nil
+}
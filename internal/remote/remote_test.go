@@ -0,0 +1,89 @@
+package remote
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	const content = "check_interval: 10s\n"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /dns-ha.yaml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(content))
+	})
+	mux.HandleFunc("GET /v1/kv/dns-ha/config.yaml", func(w http.ResponseWriter, r *http.Request) {
+		if !r.URL.Query().Has("raw") || r.Header.Get("X-Consul-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	})
+	mux.HandleFunc("POST /v3/kv/range", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		key, _ := base64.StdEncoding.DecodeString(req["key"])
+		if string(key) != "/dns-ha/config.yaml" {
+			_, _ = w.Write([]byte(`{"kvs": []}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"kvs": []map[string]string{{"value": base64.StdEncoding.EncodeToString([]byte(content))}},
+		})
+	})
+	mux.HandleFunc("GET /bucket/dns-ha.yaml", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	t.Setenv("CONSUL_HTTP_TOKEN", "token")
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_S3", server.URL)
+
+	tests := []struct {
+		name     string
+		location string
+		wantErr  bool
+	}{
+		{name: "http", location: server.URL + "/dns-ha.yaml"},
+		{name: "consul", location: "consul://" + host + "/dns-ha/config.yaml"},
+		{name: "etcd", location: "etcd://" + host + "/dns-ha/config.yaml"},
+		{name: "s3", location: "s3://bucket/dns-ha.yaml"},
+		{name: "etcd missing key", location: "etcd://" + host + "/missing", wantErr: true},
+		{name: "http not found", location: server.URL + "/missing.yaml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !IsRemote(tt.location) {
+				t.Fatalf("IsRemote(%q) = false", tt.location)
+			}
+
+			got, err := Fetch(t.Context(), tt.location)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != content {
+				t.Errorf("Fetch() got = %q, want %q", got, content)
+			}
+		})
+	}
+}
+
+func TestIsRemote(t *testing.T) {
+	for _, location := range []string{"/etc/dns-ha.yaml", "conf.d", "file:///etc/dns-ha.yaml"} {
+		if IsRemote(location) {
+			t.Errorf("IsRemote(%q) = true", location)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Package resolve looks up the addresses of healthcheck targets that are configured by hostname. The lookups are sent
+// to a dedicated resolver, so they do not depend on the DNS server that is managed by dns-ha.
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+)
+
+type Resolver struct {
+	resolver *net.Resolver
+}
+
+// New returns a resolver that sends all queries to the DNS server at address, e.g. "9.9.9.9:53".
+func New(address string) *Resolver {
+	dialer := &net.Dialer{}
+	return &Resolver{
+		resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+		},
+	}
+}
+
+// Lookup returns the address of host for the given record type. If host resolves to multiple addresses, the lowest
+// one is returned, so repeated lookups yield the same address.
+func (r *Resolver) Lookup(ctx context.Context, host string, recordType string) (net.IP, error) {
+	network := "ip4"
+	if recordType == "AAAA" {
+		network = "ip6"
+	}
+
+	ips, err := r.resolver.LookupIP(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no %s records found for %s", recordType, host)
+	}
+
+	return slices.MinFunc(ips, func(a, b net.IP) int {
+		return bytes.Compare(a, b)
+	}), nil
+}
@@ -0,0 +1,116 @@
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serveDns answers A and AAAA queries for the given names from a local udp server and returns its address.
+func serveDns(t *testing.T, names map[string][]net.IP) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) != 1 {
+				continue
+			}
+			question := query.Questions[0]
+
+			response := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true, RCode: dnsmessage.RCodeNameError},
+				Questions: query.Questions,
+			}
+			if ips, found := names[question.Name.String()]; found {
+				response.RCode = dnsmessage.RCodeSuccess
+				for _, ip := range ips {
+					header := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 60}
+					if ip4 := ip.To4(); ip4 != nil && question.Type == dnsmessage.TypeA {
+						header.Type = dnsmessage.TypeA
+						response.Answers = append(response.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AResource{A: [4]byte(ip4)}})
+					} else if ip.To4() == nil && question.Type == dnsmessage.TypeAAAA {
+						header.Type = dnsmessage.TypeAAAA
+						response.Answers = append(response.Answers, dnsmessage.Resource{Header: header, Body: &dnsmessage.AAAAResource{AAAA: [16]byte(ip.To16())}})
+					}
+				}
+			}
+
+			packed, err := response.Pack()
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteTo(packed, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestResolver_Lookup(t *testing.T) {
+	address := serveDns(t, map[string][]net.IP{
+		"backup.example.com.": {net.ParseIP("192.0.2.20"), net.ParseIP("192.0.2.10"), net.ParseIP("2001:db8::2")},
+		"v4.example.com.":     {net.ParseIP("192.0.2.30")},
+	})
+
+	tests := []struct {
+		name       string
+		host       string
+		recordType string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "lowest ipv4 address",
+			host:       "backup.example.com",
+			recordType: "A",
+			want:       "192.0.2.10",
+		},
+		{
+			name:       "ipv6 address",
+			host:       "backup.example.com",
+			recordType: "AAAA",
+			want:       "2001:db8::2",
+		},
+		{
+			name:       "no address of record type",
+			host:       "v4.example.com",
+			recordType: "AAAA",
+			wantErr:    true,
+		},
+		{
+			name:       "unknown host",
+			host:       "unknown.example.com",
+			recordType: "A",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			got, err := New(address).Lookup(ctx, tt.host, tt.recordType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Lookup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("Lookup() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
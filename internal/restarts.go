@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+)
+
+const (
+	// RestartPolicyEscalate reloads the service and escalates to a restart if reloading fails repeatedly.
+	RestartPolicyEscalate = "escalate"
+	// RestartPolicyNever only ever reloads the service.
+	RestartPolicyNever = "never"
+)
+
+// WithRestartCoalescing delays restarts of the service by the given window, so changes of several hostnames
+// across cycles only lead to a single restart.
+func WithRestartCoalescing(window time.Duration) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		if window < 0 {
+			return errors.New("restart coalescing window must not be negative")
+		}
+		m.restartCoalesce = window
+		return nil
+	}
+}
+
+// WithRestartMinInterval rate limits restarts of the service to at most one per interval.
+func WithRestartMinInterval(interval time.Duration) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		if interval < 0 {
+			return errors.New("restart min interval must not be negative")
+		}
+		m.restartMinInterval = interval
+		return nil
+	}
+}
+
+// WithRestartPolicy controls whether the service may be restarted if reloading it fails and after how many
+// consecutive reload failures the restart happens.
+func WithRestartPolicy(policy string, reloadFailuresBeforeRestart int) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		switch policy {
+		case "":
+		case RestartPolicyEscalate, RestartPolicyNever:
+			m.restartPolicy = policy
+		default:
+			return fmt.Errorf("unknown restart policy %q", policy)
+		}
+
+		if reloadFailuresBeforeRestart < 0 {
+			return errors.New("reload failures before restart must not be negative")
+		}
+		if reloadFailuresBeforeRestart > 0 {
+			m.reloadFailuresBeforeRestart = reloadFailuresBeforeRestart
+		}
+		return nil
+	}
+}
+
+// checkRestartPolicy rejects RestartPolicyNever for a service that reports it can only be restarted.
+func (h *RecordManager) checkRestartPolicy() error {
+	reporter, ok := h.dnsServiceUnit.(ReloadReporter)
+	if h.restartPolicy == RestartPolicyNever && ok && !reporter.SupportsReload() {
+		return fmt.Errorf("restart policy %q requires a service that can be reloaded", RestartPolicyNever)
+	}
+	return nil
+}
+
+// requestRestart restarts the service immediately if neither coalescing nor rate limiting is configured. Otherwise,
+// the restart is scheduled and carried out by Run.
+func (h *RecordManager) requestRestart(ctx context.Context, hostnames []string) {
+	for _, hostname := range hostnames {
+		if !slices.Contains(h.pendingRestart, hostname) {
+			h.pendingRestart = append(h.pendingRestart, hostname)
+		}
+	}
+
+	if h.restartCoalesce == 0 && h.restartMinInterval == 0 {
+		h.executeRestart(ctx)
+		return
+	}
+
+	if h.restartTimer != nil {
+		metrics.RestartsSuppressed.Inc()
+		slog.Debug("Restart already pending, coalescing", "hostnames", hostnames)
+		return
+	}
+
+	delay := h.restartCoalesce
+	if !h.lastRestart.IsZero() {
+		if wait := h.lastRestart.Add(h.restartMinInterval).Sub(h.clock.Now()); wait > delay {
+			metrics.RestartsSuppressed.Inc()
+			slog.Info("Rate limiting restart of service", "delay", wait)
+			delay = wait
+		}
+	}
+	h.restartTimer = h.clock.NewTimer(delay)
+}
+
+// restartDue returns the channel that fires when a scheduled restart is due, or nil if no restart is scheduled.
+func (h *RecordManager) restartDue() <-chan time.Time {
+	if h.restartTimer == nil {
+		return nil
+	}
+	return h.restartTimer.C()
+}
+
+func (h *RecordManager) executeRestart(ctx context.Context) {
+	if h.restartTimer != nil {
+		h.restartTimer.Stop()
+		h.restartTimer = nil
+	}
+
+	if len(h.pendingRestart) == 0 {
+		return
+	}
+
+	hostnames := h.pendingRestart
+	h.pendingRestart = nil
+	slices.Sort(hostnames)
+
+	// without a service, the dns server picks up the written records on its own
+	if h.dnsServiceUnit != nil {
+		h.lastRestart = h.clock.Now()
+		metrics.Restarts.Inc()
+		if err := h.restartService(ctx); err != nil {
+			metrics.Errors.WithLabelValues("", "service_restart", ErrorKind(err)).Inc()
+			slog.Error("could not restart service, retrying", "err", err, "retry_in", h.checkInterval)
+			// the records have already been written, so the next cycle won't detect the need to restart again
+			h.pendingRestart = hostnames
+			h.restartTimer = h.clock.NewTimer(h.checkInterval)
+			return
+		}
+
+		flushCtx, cancel := context.WithTimeout(ctx, h.backendCallTimeout())
+		err := classify(h.dnsServiceUnit.FlushCache(flushCtx, plainHostnames(hostnames)), ErrBackendUnavailable)
+		cancel()
+		if err != nil && !errors.Is(err, ErrFlushNotSupported) {
+			metrics.Errors.WithLabelValues("", "cache_flush", ErrorKind(err)).Inc()
+			slog.Error("could not flush cache", "err", err)
+		}
+	}
+
+	if h.hooks != nil {
+		if err := h.hooks.PostRestart(ctx, hostnames); err != nil {
+			metrics.Errors.WithLabelValues("", "hook_post_restart", ErrorKind(err)).Inc()
+			slog.Error("post_restart hook failed", "err", err)
+		}
+	}
+}
+
+func (h *RecordManager) restartService(ctx context.Context) error {
+	err := h.withRetries(ctx, "reload", h.dnsServiceUnit.Reload)
+	if err == nil {
+		h.reloadFailures = 0
+		return nil
+	}
+
+	reloadSupported := !errors.Is(err, ErrReloadNotSupported)
+	err = classify(err, ErrBackendUnavailable)
+	if reloadSupported {
+		h.reloadFailures++
+		metrics.Errors.WithLabelValues("", "service_reload", ErrorKind(err)).Inc()
+		slog.Error("could not reload service", "err", err, "consecutive_failures", h.reloadFailures)
+	}
+
+	if h.restartPolicy == RestartPolicyNever {
+		if !reloadSupported {
+			return fmt.Errorf("service can only be restarted, which restart policy %q forbids: %w", RestartPolicyNever, err)
+		}
+		return fmt.Errorf("not restarting service due to restart policy: %w", err)
+	}
+
+	if reloadSupported && h.reloadFailures < h.reloadFailuresBeforeRestart {
+		return fmt.Errorf("not escalating to restart before %d reload failures: %w", h.reloadFailuresBeforeRestart, err)
+	}
+
+	if err := h.withRetries(ctx, "restart", h.dnsServiceUnit.Restart); err != nil {
+		return classify(err, ErrBackendUnavailable)
+	}
+
+	h.reloadFailures = 0
+	return nil
+}
@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+type dummyService struct {
+	reloads   int
+	restarts  int
+	flushed   []string
+	reloadErr error
+	// blocking makes Reload and Restart hang until the context is canceled
+	blocking bool
+}
+
+// restartOnlyService reports that it can only be restarted.
+type restartOnlyService struct {
+	dummyService
+}
+
+func (r *restartOnlyService) SupportsReload() bool {
+	return false
+}
+
+func (d *dummyService) FlushCache(_ context.Context, names []string) error {
+	d.flushed = append(d.flushed, names...)
+	return nil
+}
+
+func (d *dummyService) Reload(ctx context.Context) error {
+	d.reloads++
+	if d.blocking {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return d.reloadErr
+}
+
+func (d *dummyService) Restart(ctx context.Context) error {
+	d.restarts++
+	if d.blocking {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func TestRecordManager_requestRestart(t *testing.T) {
+	svc := &dummyService{}
+	m, err := NewRecordManager(nil, svc, nil, WithRestartMinInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without any previous restart, the first restart is only delayed by the coalescing window
+	m.requestRestart(context.Background(), []string{"a.my.tld"})
+	if m.restartDue() == nil {
+		t.Fatal("expected restart to be scheduled")
+	}
+	<-m.restartDue()
+	m.executeRestart(context.Background())
+	if svc.reloads != 1 {
+		t.Fatalf("expected 1 reload, got %d", svc.reloads)
+	}
+
+	// subsequent restarts are rate limited and coalesced
+	m.requestRestart(context.Background(), []string{"b.my.tld"})
+	m.requestRestart(context.Background(), []string{"c.my.tld", "b.my.tld"})
+	if svc.reloads != 1 {
+		t.Errorf("expected restart to be rate limited, got %d reloads", svc.reloads)
+	}
+	if len(m.pendingRestart) != 2 {
+		t.Errorf("expected 2 pending hostnames, got %v", m.pendingRestart)
+	}
+
+	m.executeRestart(context.Background())
+	if svc.reloads != 2 || m.restartDue() != nil || len(m.pendingRestart) != 0 {
+		t.Errorf("expected pending restart to be executed once, got %d reloads", svc.reloads)
+	}
+
+	if want := []string{"a.my.tld", "b.my.tld", "c.my.tld"}; !slices.Equal(svc.flushed, want) {
+		t.Errorf("expected flushed names %v, got %v", want, svc.flushed)
+	}
+}
+
+func TestRecordManager_requestRestartImmediately(t *testing.T) {
+	svc := &dummyService{}
+	m, err := NewRecordManager(nil, svc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.requestRestart(context.Background(), []string{"a.my.tld"})
+	if svc.reloads != 1 || m.restartDue() != nil {
+		t.Errorf("expected immediate restart, got %d reloads", svc.reloads)
+	}
+}
+
+func TestRecordManager_requestRestartWithoutService(t *testing.T) {
+	m, err := NewRecordManager(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.requestRestart(context.Background(), []string{"a.my.tld"})
+	if len(m.pendingRestart) != 0 || m.restartDue() != nil || !m.lastRestart.IsZero() {
+		t.Errorf("expected no restart without a service, pending %v", m.pendingRestart)
+	}
+}
+
+func TestRecordManager_restartService(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       string
+		failures     int
+		reloadErr    error
+		wantRestarts []int
+		wantErrs     []bool
+	}{
+		{
+			name:         "escalate after two failures",
+			policy:       RestartPolicyEscalate,
+			failures:     2,
+			reloadErr:    errors.New("dbus hiccup"),
+			wantRestarts: []int{0, 1, 1},
+			wantErrs:     []bool{true, false, true},
+		},
+		{
+			name:         "never restart",
+			policy:       RestartPolicyNever,
+			failures:     1,
+			reloadErr:    errors.New("dbus hiccup"),
+			wantRestarts: []int{0, 0},
+			wantErrs:     []bool{true, true},
+		},
+		{
+			name:         "never restart a service that can not be reloaded",
+			policy:       RestartPolicyNever,
+			failures:     1,
+			reloadErr:    ErrReloadNotSupported,
+			wantRestarts: []int{0, 0},
+			wantErrs:     []bool{true, true},
+		},
+		{
+			name:         "reload not supported restarts immediately",
+			policy:       RestartPolicyEscalate,
+			failures:     5,
+			reloadErr:    ErrReloadNotSupported,
+			wantRestarts: []int{1},
+			wantErrs:     []bool{false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &dummyService{reloadErr: tt.reloadErr}
+			m, err := NewRecordManager(nil, svc, nil, WithRestartPolicy(tt.policy, tt.failures))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for attempt := range tt.wantRestarts {
+				err := m.restartService(context.Background())
+				if (err != nil) != tt.wantErrs[attempt] {
+					t.Errorf("attempt %d: restartService() error = %v, wantErr %v", attempt, err, tt.wantErrs[attempt])
+				}
+				if svc.restarts != tt.wantRestarts[attempt] {
+					t.Errorf("attempt %d: expected %d restarts, got %d", attempt, tt.wantRestarts[attempt], svc.restarts)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordManager_restartServiceTimeout(t *testing.T) {
+	svc := &dummyService{blocking: true}
+	m, err := NewRecordManager(nil, svc, nil, WithBackendTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.restartService(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("restartService() did not honor the backend timeout")
+	}
+}
+
+func TestNewRecordManager_restartPolicyRequiresReload(t *testing.T) {
+	tests := []struct {
+		name    string
+		svc     Service
+		policy  string
+		wantErr bool
+	}{
+		{name: "restart only service with never", svc: &restartOnlyService{}, policy: RestartPolicyNever, wantErr: true},
+		{name: "restart only service with escalate", svc: &restartOnlyService{}, policy: RestartPolicyEscalate},
+		{name: "unreported service with never", svc: &dummyService{}, policy: RestartPolicyNever},
+		{name: "no service with never", policy: RestartPolicyNever},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRecordManager(nil, tt.svc, nil, WithRestartPolicy(tt.policy, 0))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewRecordManager() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+)
+
+// ErrNotRetryable marks errors of DnsDb and Service operations that can not be fixed by retrying the operation, e.g.
+// an invalid config that has already been rolled back.
+var ErrNotRetryable = errors.New("not retryable")
+
+// WithRetries retries failed updates of the DNS backend, validations of its config and reloads and restarts of the
+// service. The retries run within Run, the budget of the policy must be lower than the check interval, otherwise
+// IsAlive reports a deadlock while retrying, see conf.RetryConfig.Budget.
+func WithRetries(policy conf.RetryConfig) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		if policy.Attempts < 1 || policy.Backoff < 0 || policy.MaxBackoff < 0 || policy.Jitter < 0 {
+			return errors.New("invalid retry policy")
+		}
+		m.retries = policy
+		return nil
+	}
+}
+
+// withRetries runs the operation until it succeeds, the attempts are exhausted or the context is canceled. Each
+// attempt is bounded by the backend timeout.
+func (h *RecordManager) withRetries(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		metrics.BackendAttempts.WithLabelValues(operation).Inc()
+		attemptCtx, cancel := context.WithTimeout(ctx, h.backendCallTimeout())
+		err = fn(attemptCtx)
+		cancel()
+
+		if err == nil || errors.Is(err, ErrReloadNotSupported) {
+			return err
+		}
+		if attempt >= h.retries.Attempts || errors.Is(err, ErrNotRetryable) {
+			break
+		}
+
+		delay := h.retryDelay(attempt)
+		slog.Warn("Operation failed, retrying", "operation", operation, "attempt", attempt, "retry_in", delay, "err", err)
+		timer := h.clock.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			metrics.BackendFailures.WithLabelValues(operation).Inc()
+			return err
+		case <-timer.C():
+		}
+	}
+
+	metrics.BackendFailures.WithLabelValues(operation).Inc()
+	return err
+}
+
+func (h *RecordManager) retryDelay(attempt int) time.Duration {
+	delay := h.retries.Delay(attempt)
+	if h.retries.Jitter > 0 {
+		delay += rand.N(h.retries.Jitter) //nolint G404
+	}
+	return delay
+}
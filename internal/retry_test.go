@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/clock"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestRecordManager_withRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		attempts     int
+		errs         []error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{
+			name:         "succeeds after transient failure",
+			attempts:     3,
+			errs:         []error{errors.New("text file busy")},
+			wantAttempts: 2,
+		},
+		{
+			name:         "gives up after all attempts",
+			attempts:     2,
+			errs:         []error{errors.New("dbus hiccup"), errors.New("dbus hiccup"), errors.New("dbus hiccup")},
+			wantAttempts: 2,
+			wantErr:      true,
+		},
+		{
+			name:         "not retryable",
+			attempts:     3,
+			errs:         []error{fmt.Errorf("invalid config: %w", ErrNotRetryable)},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		{
+			name:         "reload not supported",
+			attempts:     3,
+			errs:         []error{ErrReloadNotSupported},
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewRecordManager(nil, nil, nil, WithRetries(conf.RetryConfig{Attempts: tt.attempts}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			attempts := 0
+			err = m.withRetries(context.Background(), "test", func(_ context.Context) error {
+				attempts++
+				if attempts > len(tt.errs) {
+					return nil
+				}
+				return tt.errs[attempts-1]
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("withRetries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestRecordManager_withRetries_isAlive(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC))
+	policy := conf.RetryConfig{Attempts: 4, Backoff: 2 * time.Second, MaxBackoff: 5 * time.Second}
+	m, err := NewRecordManager(nil, nil, nil, WithClock(fake), WithCheckInterval(30*time.Second), WithBackendTimeout(time.Second), WithRetries(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	m.markBusy()
+	go func() {
+		done <- m.withRetries(context.Background(), "test", func(_ context.Context) error {
+			return errors.New("busy")
+		})
+	}()
+
+	for retry := 1; retry < policy.Attempts; retry++ {
+		deadline := time.Now().Add(5 * time.Second)
+		for fake.Waiters() == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected retry %d to wait for its delay", retry)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		// each attempt may take up to the backend timeout on top of the delay
+		fake.Advance(policy.Delay(retry) + time.Second)
+		if !m.IsAlive() {
+			t.Fatalf("expected manager to be alive during retry %d", retry)
+		}
+	}
+	if err := <-done; err == nil {
+		t.Error("expected the operation to fail")
+	}
+}
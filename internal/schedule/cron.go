@@ -0,0 +1,168 @@
+// Package schedule parses cron expressions and evaluates recurring windows, e.g. the blackout windows of hostnames.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed cron expression in the format "minute hour day-of-month month day-of-week".
+type Cron struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	// anyDay and anyWeekday track wildcards, a day matches either field if both are restricted, as in cron.
+	anyDay     bool
+	anyWeekday bool
+}
+
+type field struct {
+	min, max int
+}
+
+var (
+	minuteField  = field{0, 59}
+	hourField    = field{0, 23}
+	dayField     = field{1, 31}
+	monthField   = field{1, 12}
+	weekdayField = field{0, 7}
+)
+
+// ParseCron parses a cron expression, fields support "*", lists, ranges and steps, e.g. "*/15 1-5 * * 1,3".
+func ParseCron(expr string) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in cron expression %q, got %d", expr, len(fields))
+	}
+
+	var ret Cron
+	var err error
+	if ret.minutes, err = minuteField.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("invalid minute: %w", err)
+	}
+	if ret.hours, err = hourField.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("invalid hour: %w", err)
+	}
+	if ret.days, err = dayField.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("invalid day of month: %w", err)
+	}
+	if ret.months, err = monthField.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("invalid month: %w", err)
+	}
+	if ret.weekdays, err = weekdayField.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("invalid day of week: %w", err)
+	}
+	// both 0 and 7 are Sunday
+	if ret.weekdays&(1<<7) != 0 {
+		ret.weekdays |= 1
+	}
+	ret.anyDay = strings.HasPrefix(fields[2], "*")
+	ret.anyWeekday = strings.HasPrefix(fields[4], "*")
+	return &ret, nil
+}
+
+func (f field) parse(expr string) (uint64, error) {
+	var ret uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepExpr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepExpr)
+			}
+		}
+
+		start, end := f.min, f.max
+		if rangeExpr != "*" {
+			first, last, isRange := strings.Cut(rangeExpr, "-")
+			var err error
+			if start, err = f.value(first); err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				if end, err = f.value(last); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				end = f.max
+			}
+			if end < start {
+				return 0, fmt.Errorf("invalid range %q", rangeExpr)
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			ret |= 1 << value
+		}
+	}
+	return ret, nil
+}
+
+func (f field) value(expr string) (int, error) {
+	value, err := strconv.Atoi(expr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", expr)
+	}
+	if value < f.min || value > f.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", value, f.min, f.max)
+	}
+	return value, nil
+}
+
+// Matches returns true if the expression matches the minute of t.
+func (c *Cron) Matches(t time.Time) bool {
+	if c.minutes&(1<<t.Minute()) == 0 || c.hours&(1<<t.Hour()) == 0 || c.months&(1<<int(t.Month())) == 0 {
+		return false
+	}
+
+	day := c.days&(1<<t.Day()) != 0
+	weekday := c.weekdays&(1<<int(t.Weekday())) != 0
+	switch {
+	case c.anyDay || c.anyWeekday:
+		return day && weekday
+	default:
+		return day || weekday
+	}
+}
+
+// Window is a recurring window that starts whenever its cron expression matches and lasts for its duration.
+type Window struct {
+	start    *Cron
+	duration time.Duration
+	location *time.Location
+}
+
+// NewWindow returns a window that starts at the times matched by the cron expression, evaluated in the location.
+func NewWindow(expr string, duration time.Duration, location *time.Location) (*Window, error) {
+	start, err := ParseCron(expr)
+	if err != nil {
+		return nil, err
+	}
+	if duration < time.Minute {
+		return nil, errors.New("duration must be at least one minute")
+	}
+	if location == nil {
+		location = time.Local
+	}
+
+	return &Window{start: start, duration: duration, location: location}, nil
+}
+
+// Active returns true if a window started within the duration before now.
+func (w *Window) Active(now time.Time) bool {
+	now = now.In(w.location)
+	minute := now.Truncate(time.Minute)
+	for start := minute; now.Sub(start) < w.duration; start = start.Add(-time.Minute) {
+		if w.start.Matches(start) {
+			return true
+		}
+	}
+	return false
+}
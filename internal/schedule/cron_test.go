@@ -0,0 +1,79 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCron_Matches(t *testing.T) {
+	// 2024-05-17 is a Friday
+	friday := time.Date(2024, 5, 17, 1, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr    string
+		t       time.Time
+		want    bool
+		wantErr bool
+	}{
+		{expr: "30 1 * * *", t: friday, want: true},
+		{expr: "30 1 * * *", t: friday.Add(time.Minute), want: false},
+		{expr: "*/15 * * * *", t: friday, want: true},
+		{expr: "*/20 * * * *", t: friday, want: false},
+		{expr: "0-45/15 1-3 * * *", t: friday, want: true},
+		{expr: "30 1 * * 1-4", t: friday, want: false},
+		{expr: "30 1 * * 5", t: friday, want: true},
+		{expr: "30 1 * 6 *", t: friday, want: false},
+		// restricted day of month and day of week match either
+		{expr: "30 1 1 * 5", t: friday, want: true},
+		{expr: "30 1 17 * 1", t: friday, want: true},
+		{expr: "30 1 1 * 1", t: friday, want: false},
+		{expr: "0 0 * * 7", t: time.Date(2024, 5, 19, 0, 0, 0, 0, time.UTC), want: true},
+		{expr: "30 1 * *", wantErr: true},
+		{expr: "60 1 * * *", wantErr: true},
+		{expr: "5-1 * * * *", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "a * * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			cron, err := ParseCron(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCron() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := cron.Matches(tt.t); got != tt.want {
+				t.Errorf("Matches(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindow_Active(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("timezone data not available")
+	}
+	window, err := NewWindow("30 23 * * *", 2*time.Hour, berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{t: time.Date(2024, 5, 17, 23, 29, 0, 0, berlin), want: false},
+		{t: time.Date(2024, 5, 17, 23, 30, 0, 0, berlin), want: true},
+		{t: time.Date(2024, 5, 18, 1, 29, 59, 0, berlin), want: true},
+		{t: time.Date(2024, 5, 18, 1, 30, 0, 0, berlin), want: false},
+		// the schedule is evaluated in the window's timezone
+		{t: time.Date(2024, 5, 17, 22, 0, 0, 0, time.UTC), want: true},
+	}
+	for _, tt := range tests {
+		if got := window.Active(tt.t); got != tt.want {
+			t.Errorf("Active(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	DefaultResolvectlBinary = "resolvectl"
+	resolvedServiceName     = "systemd-resolved"
+)
+
+// Resolved controls systemd-resolved, which picks up changes of the hosts file on its own. Reloading flushes the
+// cache, so answers of the upstream servers cached for the managed names disappear right away.
+type Resolved struct {
+	binary string
+}
+
+type ResolvedOpts func(*Resolved) error
+
+// WithResolvectlBinary configures the resolvectl binary.
+func WithResolvectlBinary(binary string) ResolvedOpts {
+	return func(r *Resolved) error {
+		if binary != "" {
+			r.binary = binary
+		}
+		return nil
+	}
+}
+
+func NewResolvedService(opts ...ResolvedOpts) (*Resolved, error) {
+	ret := &Resolved{binary: DefaultResolvectlBinary}
+
+	var errs error
+	for _, opt := range opts {
+		if err := opt(ret); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	return ret, errs
+}
+
+func (r *Resolved) Reload(ctx context.Context) error {
+	return r.flushCaches(ctx)
+}
+
+func (r *Resolved) Restart(ctx context.Context) error {
+	return reloadOrRestart(ctx, "restart", resolvedServiceName)
+}
+
+// FlushCache flushes the whole cache, resolvectl can not flush single names.
+func (r *Resolved) FlushCache(ctx context.Context, _ []string) error {
+	return r.flushCaches(ctx)
+}
+
+func (r *Resolved) flushCaches(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, r.binary, "flush-caches") //nolint G204
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s flush-caches failed: %w: %s", r.binary, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
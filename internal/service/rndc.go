@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"go.uber.org/multierr"
+)
+
+const DefaultRndcBinary = "rndc"
+
+// Rndc controls a BIND server using rndc. Reloading only reloads the managed zone, restarting reloads all zones.
+type Rndc struct {
+	zone   string
+	binary string
+	args   []string
+}
+
+type RndcOpts func(*Rndc) error
+
+// WithRndcCommand configures the rndc binary and additional arguments such as the server or key file.
+func WithRndcCommand(binary string, args []string) RndcOpts {
+	return func(r *Rndc) error {
+		if binary != "" {
+			r.binary = binary
+		}
+		r.args = args
+		return nil
+	}
+}
+
+func NewRndcService(zone string, opts ...RndcOpts) (*Rndc, error) {
+	if zone == "" {
+		return nil, errors.New("empty zone provided")
+	}
+
+	ret := &Rndc{zone: zone, binary: DefaultRndcBinary}
+
+	var errs error
+	for _, opt := range opts {
+		if err := opt(ret); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	return ret, errs
+}
+
+func (r *Rndc) Reload(ctx context.Context) error {
+	return r.run(ctx, "reload", r.zone)
+}
+
+func (r *Rndc) Restart(ctx context.Context) error {
+	return r.run(ctx, "reload")
+}
+
+// FlushCache is not supported, an authoritative server answers from the reloaded zone right away.
+func (r *Rndc) FlushCache(_ context.Context, _ []string) error {
+	return internal.ErrFlushNotSupported
+}
+
+func (r *Rndc) run(ctx context.Context, command ...string) error {
+	args := slices.Concat(r.args, command)
+	cmd := exec.CommandContext(ctx, r.binary, args...) //nolint G204
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s failed: %w: %s", r.binary, strings.Join(command, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var errNotWindows = errors.New("the windows service control manager is only available on windows")
+
+func scmServiceExists(_ string) error {
+	return errNotWindows
+}
+
+func scmReload(_ context.Context, _ string) error {
+	return errNotWindows
+}
+
+func scmRestart(_ context.Context, _ string, _ time.Duration) error {
+	return errNotWindows
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const scmPollInterval = 250 * time.Millisecond
+
+func openService(serviceName string) (*mgr.Mgr, *mgr.Service, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not connect to service control manager: %w", err)
+	}
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		_ = m.Disconnect()
+		return nil, nil, err
+	}
+	return m, s, nil
+}
+
+func scmServiceExists(serviceName string) error {
+	m, s, err := openService(serviceName)
+	if err != nil {
+		return err
+	}
+	_ = s.Close()
+	return m.Disconnect()
+}
+
+// scmReload notifies the service about changed parameters if it accepts the notification.
+func scmReload(ctx context.Context, serviceName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m, s, err := openService(serviceName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = s.Close()
+		_ = m.Disconnect()
+	}()
+
+	status, err := s.Query()
+	if err != nil {
+		return err
+	}
+	if status.Accepts&svc.AcceptParamChange == 0 {
+		return nil
+	}
+
+	_, err = s.Control(svc.ParamChange)
+	return err
+}
+
+func scmRestart(ctx context.Context, serviceName string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	m, s, err := openService(serviceName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = s.Close()
+		_ = m.Disconnect()
+	}()
+
+	status, err := s.Query()
+	if err != nil {
+		return err
+	}
+	if status.State != svc.Stopped {
+		if _, err := s.Control(svc.Stop); err != nil {
+			return fmt.Errorf("could not stop service: %w", err)
+		}
+		if err := waitForState(ctx, s, svc.Stopped); err != nil {
+			return err
+		}
+	}
+
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("could not start service: %w", err)
+	}
+	return waitForState(ctx, s, svc.Running)
+}
+
+func waitForState(ctx context.Context, s *mgr.Service, state svc.State) error {
+	ticker := time.NewTicker(scmPollInterval)
+	defer ticker.Stop()
+	for {
+		status, err := s.Query()
+		if err != nil {
+			return err
+		}
+		if status.State == state {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("service did not reach state %d: %w", state, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
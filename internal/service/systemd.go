@@ -1,17 +1,37 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os/exec"
+	"slices"
 	"strings"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"go.uber.org/multierr"
 )
 
 type Systemd struct {
-	serviceName string
+	serviceName  string
+	flushCommand []string
+}
+
+type SystemdOpts func(*Systemd) error
+
+// WithFlushCommand configures the command used to purge a name from the resolver cache, e.g.
+// ["unbound-control", "flush"]. The name to flush is appended as last argument.
+func WithFlushCommand(cmd []string) SystemdOpts {
+	return func(s *Systemd) error {
+		if len(cmd) > 0 && cmd[0] == "" {
+			return errors.New("empty flush command provided")
+		}
+		s.flushCommand = cmd
+		return nil
+	}
 }
 
-func NewSystemdService(serviceName string) (*Systemd, error) {
+func NewSystemdService(serviceName string, opts ...SystemdOpts) (*Systemd, error) {
 	if serviceName == "" {
 		return nil, errors.New("empty service name provided")
 	}
@@ -21,7 +41,16 @@ func NewSystemdService(serviceName string) (*Systemd, error) {
 		return nil, fmt.Errorf("systemd service %q does not seem to exist", serviceName)
 	}
 
-	return &Systemd{serviceName: serviceName}, nil
+	ret := &Systemd{serviceName: serviceName}
+
+	var errs error
+	for _, opt := range opts {
+		if err := opt(ret); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	return ret, errs
 }
 
 func serviceExists(serviceName string) (bool, error) {
@@ -40,16 +69,37 @@ func serviceExists(serviceName string) (bool, error) {
 	return true, nil
 }
 
-func (s *Systemd) Reload() error {
-	return reloadOrRestart("reload", s.serviceName)
+func (s *Systemd) Reload(ctx context.Context) error {
+	return reloadOrRestart(ctx, "reload", s.serviceName)
 }
 
-func (s *Systemd) Restart() error {
-	return reloadOrRestart("restart", s.serviceName)
+func (s *Systemd) Restart(ctx context.Context) error {
+	return reloadOrRestart(ctx, "restart", s.serviceName)
+}
+
+func (s *Systemd) FlushCache(ctx context.Context, names []string) error {
+	return flushNames(ctx, s.flushCommand, names)
+}
+
+// flushNames runs the flush command once per name, the name is appended as last argument.
+func flushNames(ctx context.Context, flushCommand []string, names []string) error {
+	if len(flushCommand) == 0 {
+		return internal.ErrFlushNotSupported
+	}
+
+	var errs error
+	for _, name := range names {
+		args := append(slices.Clone(flushCommand[1:]), name)
+		cmd := exec.CommandContext(ctx, flushCommand[0], args...) //nolint G204
+		if output, err := cmd.CombinedOutput(); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("failed to flush %s: %w: %s", name, err, strings.TrimSpace(string(output))))
+		}
+	}
+	return errs
 }
 
-func reloadOrRestart(operation string, serviceName string) error {
-	cmd := exec.Command("systemctl", operation, serviceName)
+func reloadOrRestart(ctx context.Context, operation string, serviceName string) error {
+	cmd := exec.CommandContext(ctx, "systemctl", operation, serviceName)
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("failed to %s service %s: %w", operation, serviceName, err)
 	}
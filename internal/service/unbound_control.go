@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"go.uber.org/multierr"
+)
+
+// UnboundControl reloads unbound using unbound-control, e.g. "unbound-control reload_keep_cache", which keeps the
+// resolver cache unlike reloading the systemd service. The fallback service is reloaded if the command fails, e.g.
+// because unbound-control is not available, remote control is disabled or unbound is too old to support the command.
+// Restarting and flushing the cache is always left to the fallback service.
+type UnboundControl struct {
+	reloadCommand []string
+	fallback      internal.Service
+}
+
+type UnboundControlOpts func(*UnboundControl) error
+
+// WithReloadCommand configures the command used to reload unbound, e.g. ["unbound-control", "reload_keep_cache"].
+func WithReloadCommand(cmd []string) UnboundControlOpts {
+	return func(u *UnboundControl) error {
+		if len(cmd) == 0 || cmd[0] == "" {
+			return errors.New("empty reload command provided")
+		}
+		u.reloadCommand = cmd
+		return nil
+	}
+}
+
+func NewUnboundControlService(fallback internal.Service, opts ...UnboundControlOpts) (*UnboundControl, error) {
+	if fallback == nil {
+		return nil, errors.New("empty fallback service provided")
+	}
+
+	ret := &UnboundControl{
+		reloadCommand: []string{"unbound-control", "reload_keep_cache"},
+		fallback:      fallback,
+	}
+
+	var errs error
+	for _, opt := range opts {
+		if err := opt(ret); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	return ret, errs
+}
+
+func (u *UnboundControl) Reload(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, u.reloadCommand[0], u.reloadCommand[1:]...) //nolint G204
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+
+	slog.Warn("Reload command failed, reloading service instead", "command", strings.Join(u.reloadCommand, " "), "err", err, "output", strings.TrimSpace(string(output)))
+	if fallbackErr := u.fallback.Reload(ctx); fallbackErr != nil {
+		return fmt.Errorf("%s failed: %w, reloading the service failed as well: %w", strings.Join(u.reloadCommand, " "), err, fallbackErr)
+	}
+	return nil
+}
+
+func (u *UnboundControl) Restart(ctx context.Context) error {
+	return u.fallback.Restart(ctx)
+}
+
+func (u *UnboundControl) FlushCache(ctx context.Context, names []string) error {
+	return u.fallback.FlushCache(ctx, names)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type dummyService struct {
+	reloads int
+	err     error
+}
+
+func (d *dummyService) Reload(_ context.Context) error {
+	d.reloads++
+	return d.err
+}
+
+func (d *dummyService) Restart(_ context.Context) error {
+	return nil
+}
+
+func (d *dummyService) FlushCache(_ context.Context, _ []string) error {
+	return nil
+}
+
+func TestUnboundControl_Reload(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     []string
+		fallbackErr error
+		wantReloads int
+		wantErr     bool
+	}{
+		{
+			name:    "command succeeds",
+			command: []string{"true"},
+		},
+		{
+			name:        "failing command falls back to the service",
+			command:     []string{"false"},
+			wantReloads: 1,
+		},
+		{
+			name:        "missing binary falls back to the service",
+			command:     []string{"/nonexistent/unbound-control", "reload_keep_cache"},
+			wantReloads: 1,
+		},
+		{
+			name:        "failing fallback",
+			command:     []string{"false"},
+			fallbackErr: errors.New("reload failed"),
+			wantReloads: 1,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fallback := &dummyService{err: tt.fallbackErr}
+			svc, err := NewUnboundControlService(fallback, WithReloadCommand(tt.command))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := svc.Reload(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Reload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if fallback.reloads != tt.wantReloads {
+				t.Errorf("expected %d reloads of the fallback, got %d", tt.wantReloads, fallback.reloads)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+const defaultWindowsTimeout = 30 * time.Second
+
+// Windows controls a service using the Windows service control manager. Services that don't accept parameter change
+// notifications are not reloaded, e.g. Microsoft DNS Server serves records written via its management API
+// immediately.
+type Windows struct {
+	serviceName  string
+	flushCommand []string
+	timeout      time.Duration
+}
+
+type WindowsOpts func(*Windows) error
+
+// WithWindowsFlushCommand configures the command used to purge a name from the resolver cache. The name to flush is
+// appended as last argument.
+func WithWindowsFlushCommand(cmd []string) WindowsOpts {
+	return func(w *Windows) error {
+		if len(cmd) > 0 && cmd[0] == "" {
+			return errors.New("empty flush command provided")
+		}
+		w.flushCommand = cmd
+		return nil
+	}
+}
+
+// WithWindowsTimeout configures how long to wait for the service to stop and start.
+func WithWindowsTimeout(timeout time.Duration) WindowsOpts {
+	return func(w *Windows) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		w.timeout = timeout
+		return nil
+	}
+}
+
+func NewWindowsService(serviceName string, opts ...WindowsOpts) (*Windows, error) {
+	if serviceName == "" {
+		return nil, errors.New("empty service name provided")
+	}
+
+	if err := scmServiceExists(serviceName); err != nil {
+		return nil, fmt.Errorf("windows service %q does not seem to exist: %w", serviceName, err)
+	}
+
+	ret := &Windows{serviceName: serviceName, timeout: defaultWindowsTimeout}
+
+	var errs error
+	for _, opt := range opts {
+		if err := opt(ret); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	return ret, errs
+}
+
+func (w *Windows) Reload(ctx context.Context) error {
+	if err := scmReload(ctx, w.serviceName); err != nil {
+		return fmt.Errorf("failed to reload service %s: %w", w.serviceName, err)
+	}
+	return nil
+}
+
+func (w *Windows) Restart(ctx context.Context) error {
+	if err := scmRestart(ctx, w.serviceName, w.timeout); err != nil {
+		return fmt.Errorf("failed to restart service %s: %w", w.serviceName, err)
+	}
+	return nil
+}
+
+func (w *Windows) FlushCache(ctx context.Context, names []string) error {
+	return flushNames(ctx, w.flushCommand, names)
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"go.uber.org/multierr"
+)
+
+const (
+	DefaultNsdControlBinary = "nsd-control"
+	DefaultKnotcBinary      = "knotc"
+)
+
+// ZoneControl controls an authoritative server such as NSD or Knot DNS using its control utility. Reloading only
+// reloads the managed zone, which notifies the secondaries of the bumped serial, restarting reloads all zones.
+type ZoneControl struct {
+	binary  string
+	args    []string
+	reload  []string
+	restart []string
+}
+
+type ZoneControlOpts func(*ZoneControl) error
+
+// WithControlCommand configures the control binary and additional arguments such as the config file.
+func WithControlCommand(binary string, args []string) ZoneControlOpts {
+	return func(z *ZoneControl) error {
+		if binary != "" {
+			z.binary = binary
+		}
+		z.args = args
+		return nil
+	}
+}
+
+// NewNsdControlService controls NSD using nsd-control.
+func NewNsdControlService(zone string, opts ...ZoneControlOpts) (*ZoneControl, error) {
+	return newZoneControl(zone, DefaultNsdControlBinary, []string{"reload", zone}, []string{"reload"}, opts...)
+}
+
+// NewKnotcService controls Knot DNS using knotc.
+func NewKnotcService(zone string, opts ...ZoneControlOpts) (*ZoneControl, error) {
+	return newZoneControl(zone, DefaultKnotcBinary, []string{"zone-reload", zone}, []string{"reload"}, opts...)
+}
+
+func newZoneControl(zone, binary string, reload, restart []string, opts ...ZoneControlOpts) (*ZoneControl, error) {
+	if zone == "" {
+		return nil, errors.New("empty zone provided")
+	}
+
+	ret := &ZoneControl{binary: binary, reload: reload, restart: restart}
+
+	var errs error
+	for _, opt := range opts {
+		if err := opt(ret); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	return ret, errs
+}
+
+func (z *ZoneControl) Reload(ctx context.Context) error {
+	return z.run(ctx, z.reload)
+}
+
+func (z *ZoneControl) Restart(ctx context.Context) error {
+	return z.run(ctx, z.restart)
+}
+
+// FlushCache is not supported, an authoritative server answers from the reloaded zone right away.
+func (z *ZoneControl) FlushCache(_ context.Context, _ []string) error {
+	return internal.ErrFlushNotSupported
+}
+
+func (z *ZoneControl) run(ctx context.Context, command []string) error {
+	args := slices.Concat(z.args, command)
+	cmd := exec.CommandContext(ctx, z.binary, args...) //nolint G204
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s failed: %w: %s", z.binary, strings.Join(command, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+const (
+	// ShutdownKeep leaves the published records in place when dns-ha stops.
+	ShutdownKeep = "keep"
+	// ShutdownPublishAll publishes the ShutdownIps of each hostname when dns-ha stops, or all of its records that are
+	// not unhealthy, so clients are not stuck with a selection that is not maintained anymore. Hostnames without such
+	// records keep their published records.
+	ShutdownPublishAll = "publish_all"
+	// ShutdownRemove removes all managed records when dns-ha stops, e.g. when decommissioning a node.
+	ShutdownRemove = "remove"
+)
+
+// WithShutdownPolicy defines what happens to the published records when Run returns.
+func WithShutdownPolicy(policy string) RecordManagerOpts {
+	return func(m *RecordManager) error {
+		switch policy {
+		case "":
+		case ShutdownKeep, ShutdownPublishAll, ShutdownRemove:
+			m.shutdownPolicy = policy
+		default:
+			return fmt.Errorf("unknown shutdown policy %q", policy)
+		}
+		return nil
+	}
+}
+
+// applyShutdownPolicy replaces the records of all managed hostnames according to the shutdown policy and restarts the
+// service.
+func (h *RecordManager) applyShutdownPolicy(ctx context.Context) {
+	if h.shutdownPolicy == ShutdownKeep {
+		return
+	}
+
+	desired := make(map[string][]ManagedDnsRecord, len(h.managedRecords))
+	for hostname, records := range h.managedRecords {
+		selected := []ManagedDnsRecord{}
+		if h.shutdownPolicy == ShutdownPublishAll {
+			selected = h.shutdownRecords(hostname, records)
+			if len(selected) == 0 {
+				slog.Warn("No record is safe to publish on shutdown, keeping the published records", "hostname", hostname)
+				continue
+			}
+		}
+		desired[hostname] = h.withStateTxt(hostname, selected)
+	}
+	if len(desired) == 0 {
+		return
+	}
+
+	hostnames := slices.Sorted(maps.Keys(desired))
+	slog.Info("Applying shutdown policy", "policy", h.shutdownPolicy, "hostnames", hostnames)
+	changed, err := h.applyDesired(ctx, desired)
+	if err != nil {
+		metrics.Errors.WithLabelValues("", "shutdown_policy", ErrorKind(err)).Inc()
+		slog.Error("could not apply shutdown policy", "policy", h.shutdownPolicy, "err", err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	if err := h.validateConfig(ctx); err != nil {
+		metrics.Errors.WithLabelValues("", "shutdown_policy", ErrorKind(err)).Inc()
+		slog.Error("shutdown policy produced invalid config", "policy", h.shutdownPolicy, "err", err)
+		return
+	}
+
+	h.publishedMutex.Lock()
+	for hostname, records := range desired {
+		h.publishedIps[hostname] = sortedIps(records)
+	}
+	h.publishedMutex.Unlock()
+	h.requestRestart(ctx, hostnames)
+}
+
+// shutdownRecords returns the records of the hostname that are published by ShutdownPublishAll: its ShutdownIps if
+// configured, otherwise all records that are not unhealthy and not in maintenance.
+func (h *RecordManager) shutdownRecords(hostname string, records []*ManagedDnsRecord) []ManagedDnsRecord {
+	safe := h.hostnamePolicies[hostname].ShutdownIps
+
+	var ret []ManagedDnsRecord
+	for _, record := range records {
+		if len(safe) > 0 {
+			if slices.ContainsFunc(safe, record.Ip.Equal) {
+				ret = append(ret, *record)
+			}
+			continue
+		}
+		if status.Effective(record.GetState()).Name() != status.UnhealthyStateName && !record.InMaintenance() {
+			ret = append(ret, *record)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+func TestRecordManager_applyShutdownPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		unhealthy   []string
+		shutdownIps []net.IP
+		wantUpdated bool
+		want        []string
+		wantReloads int
+	}{
+		{
+			name:   "keep",
+			policy: ShutdownKeep,
+		},
+		{
+			name:        "publish all",
+			policy:      ShutdownPublishAll,
+			wantUpdated: true,
+			want:        []string{"A 10.0.0.1", "A 10.0.0.2"},
+			wantReloads: 1,
+		},
+		{
+			name:        "publish all skips unhealthy records",
+			policy:      ShutdownPublishAll,
+			unhealthy:   []string{"10.0.0.2"},
+			wantUpdated: true,
+			want:        []string{"A 10.0.0.1"},
+			wantReloads: 1,
+		},
+		{
+			name:        "publish all publishes the shutdown ips",
+			policy:      ShutdownPublishAll,
+			unhealthy:   []string{"10.0.0.2"},
+			shutdownIps: []net.IP{net.ParseIP("10.0.0.2")},
+			wantUpdated: true,
+			want:        []string{"A 10.0.0.2"},
+			wantReloads: 1,
+		},
+		{
+			name:      "publish all keeps the records if no record is safe",
+			policy:    ShutdownPublishAll,
+			unhealthy: []string{"10.0.0.1", "10.0.0.2"},
+		},
+		{
+			name:        "remove",
+			policy:      ShutdownRemove,
+			wantUpdated: true,
+			want:        []string{},
+			wantReloads: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := map[string][]*ManagedDnsRecord{"my.tld": {
+				{DnsRecord: DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, Hostname: "my.tld", status: &status.Healthy{}},
+				{DnsRecord: DnsRecord{Priority: 10, DnsType: "A", Ip: net.ParseIP("10.0.0.2"), Ttl: 60}, Hostname: "my.tld", status: &status.Healthy{}},
+			}}
+			for _, record := range records["my.tld"] {
+				if slices.Contains(tt.unhealthy, record.Ip.String()) {
+					record.status = &status.Unhealthy{}
+				}
+			}
+			db := &dummyDnsDb{}
+			svc := &dummyService{}
+			policies := WithHostnamePolicies(map[string]HostnamePolicy{"my.tld": {ShutdownIps: tt.shutdownIps}})
+			m, err := NewRecordManager(db, svc, records, WithShutdownPolicy(tt.policy), policies)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m.applyShutdownPolicy(context.Background())
+			got, updated := db.updates["my.tld"]
+			if updated != tt.wantUpdated {
+				t.Fatalf("updated = %v, want %v", updated, tt.wantUpdated)
+			}
+			if updated && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("published %v, want %v", got, tt.want)
+			}
+			if svc.reloads != tt.wantReloads {
+				t.Errorf("expected %d reloads, got %d", tt.wantReloads, svc.reloads)
+			}
+		})
+	}
+}
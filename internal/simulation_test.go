@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/clock"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+// scriptedHealthcheck returns the result set by the simulation and counts its checks.
+type scriptedHealthcheck struct {
+	mutex   sync.Mutex
+	healthy bool
+	checks  int
+}
+
+func (s *scriptedHealthcheck) IsHealthy(_ context.Context) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.checks++
+	return s.healthy, nil
+}
+
+// simulation drives a RecordManager of a single hostname using a fake clock. Each step advances the clock by the check
+// interval and runs a check cycle synchronously, so behavior over long periods of time can be tested deterministically
+// and quickly.
+type simulation struct {
+	t        *testing.T
+	clock    *clock.Fake
+	db       *dummyDnsDb
+	service  *dummyService
+	manager  *RecordManager
+	checks   map[string]*scriptedHealthcheck
+	interval time.Duration
+}
+
+// newSimulation manages the addresses, ordered by descending priority, all of them are healthy initially.
+func newSimulation(t *testing.T, statusConf conf.StatusConfig, recordOpts []ManagedDnsRecordOpts, addresses []string, opts ...RecordManagerOpts) *simulation {
+	sim := &simulation{
+		t:        t,
+		clock:    clock.NewFake(time.Date(2024, 5, 17, 0, 0, 0, 0, time.UTC)),
+		db:       &dummyDnsDb{},
+		service:  &dummyService{},
+		checks:   map[string]*scriptedHealthcheck{},
+		interval: defaultCheckInterval,
+	}
+
+	var records []*ManagedDnsRecord
+	for index, address := range addresses {
+		check := &scriptedHealthcheck{healthy: true}
+		sim.checks[address] = check
+		record, err := NewManagedDnsRecord("sim.tld", DnsRecord{Priority: uint8(100 - index), DnsType: "A", Ip: net.ParseIP(address), Ttl: 60}, statusConf, check, recordOpts...) //nolint G115
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	opts = append([]RecordManagerOpts{WithClock(sim.clock), WithCheckInterval(sim.interval)}, opts...)
+	manager, err := NewRecordManager(sim.db, sim.service, map[string][]*ManagedDnsRecord{"sim.tld": records}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.manager = manager
+	return sim
+}
+
+func (s *simulation) setHealthy(address string, healthy bool) {
+	check := s.checks[address]
+	check.mutex.Lock()
+	defer check.mutex.Unlock()
+	check.healthy = healthy
+}
+
+func (s *simulation) checkCount(address string) int {
+	check := s.checks[address]
+	check.mutex.Lock()
+	defer check.mutex.Unlock()
+	return check.checks
+}
+
+// run runs check cycles until the duration has passed.
+func (s *simulation) run(duration time.Duration) {
+	for elapsed := time.Duration(0); elapsed < duration; elapsed += s.interval {
+		s.clock.Advance(s.interval)
+		s.manager.CheckRecords(s.t.Context())
+		select {
+		case <-s.manager.restartDue():
+			s.manager.executeRestart(s.t.Context())
+		default:
+		}
+	}
+}
+
+// expectPublished fails the test if other addresses than the given ones are published.
+func (s *simulation) expectPublished(msg string, addresses ...string) {
+	s.t.Helper()
+	var want []string
+	for _, address := range addresses {
+		want = append(want, "A "+address)
+	}
+	if got := s.db.updates["sim.tld"]; !reflect.DeepEqual(got, want) {
+		s.t.Fatalf("%s: published %v, want %v", msg, got, want)
+	}
+}
+
+var simulationStatus = conf.StatusConfig{
+	HealthyStreak:          3,
+	UnhealthyStreak:        3,
+	InitialHealthyStreak:   1,
+	InitialUnhealthyStreak: 1,
+}
+
+func TestSimulation_healthyAfter(t *testing.T) {
+	statusConf := simulationStatus
+	statusConf.HealthyAfter = 10 * time.Minute
+	sim := newSimulation(t, statusConf, nil, []string{"10.0.0.1", "10.0.0.2"})
+
+	sim.run(time.Minute)
+	sim.expectPublished("initial selection", "10.0.0.1")
+
+	sim.setHealthy("10.0.0.1", false)
+	sim.run(90 * time.Second)
+	sim.expectPublished("after failure", "10.0.0.2")
+
+	// the primary has to succeed for ten minutes, counted from its first successful check, before it takes over again
+	sim.setHealthy("10.0.0.1", true)
+	sim.run(10 * time.Minute)
+	sim.expectPublished("during hold-down", "10.0.0.2")
+	sim.run(30 * time.Second)
+	sim.expectPublished("after hold-down", "10.0.0.1")
+
+	// a single failure restarts the hold-down
+	sim.setHealthy("10.0.0.1", false)
+	sim.run(90 * time.Second)
+	sim.expectPublished("after second failure", "10.0.0.2")
+	sim.setHealthy("10.0.0.1", true)
+	sim.run(5 * time.Minute)
+	sim.expectPublished("after flapping", "10.0.0.2")
+}
+
+func TestSimulation_backoff(t *testing.T) {
+	backoff := WithBackoff(conf.BackoffConfig{After: 5 * time.Minute, Initial: time.Minute, Max: 30 * time.Minute})
+	sim := newSimulation(t, simulationStatus, []ManagedDnsRecordOpts{backoff}, []string{"10.0.0.1", "10.0.0.2"})
+
+	sim.setHealthy("10.0.0.1", false)
+	sim.run(5 * time.Hour)
+	sim.expectPublished("while primary is down", "10.0.0.2")
+
+	// the delay between checks is capped at 30m after backing off for hours
+	before := sim.checkCount("10.0.0.1")
+	sim.run(time.Hour)
+	if checks := sim.checkCount("10.0.0.1") - before; checks != 2 {
+		t.Errorf("expected 2 checks of backed off record within an hour, got %d", checks)
+	}
+	if checks := sim.checkCount("10.0.0.2"); checks != int(6*time.Hour/sim.interval) {
+		t.Errorf("expected healthy record to be checked every cycle, got %d checks", checks)
+	}
+
+	// a single successful check resumes the regular checks
+	sim.setHealthy("10.0.0.1", true)
+	sim.run(30 * time.Minute)
+	before = sim.checkCount("10.0.0.1")
+	sim.run(10 * time.Minute)
+	if checks := sim.checkCount("10.0.0.1") - before; checks != 20 {
+		t.Errorf("expected regular checks after recovery, got %d", checks)
+	}
+	sim.expectPublished("after recovery", "10.0.0.1")
+}
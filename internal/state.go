@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+// StateVersion is the version of the exported state, states of other versions are rejected.
+const StateVersion = 1
+
+// State is the state of all records of a running process that another process adopts, e.g. while dns-ha is upgraded,
+// so it does not need to re-learn the health of the records from the initial state.
+type State struct {
+	Version   int             `json:"version"`
+	Exported  time.Time       `json:"exported"`
+	Hostnames []HostnameState `json:"hostnames"`
+}
+
+// HostnameState is the exported state of a hostname.
+type HostnameState struct {
+	Hostname    string        `json:"hostname"`
+	Published   []string      `json:"published"`
+	OutageSince *time.Time    `json:"outage_since,omitempty"`
+	Records     []RecordState `json:"records"`
+}
+
+// RecordState is the exported state of a record.
+type RecordState struct {
+	Ip               string    `json:"ip"`
+	Status           string    `json:"status"`
+	Streak           int       `json:"streak"`
+	LastStatusChange time.Time `json:"last_status_change"`
+	Maintenance      bool      `json:"maintenance"`
+	// BackoffDelay is the current delay between checks of a persistently unhealthy record.
+	BackoffDelay time.Duration `json:"backoff_delay,omitempty"`
+	NextCheck    *time.Time    `json:"next_check,omitempty"`
+	GraceUntil   *time.Time    `json:"grace_until,omitempty"`
+}
+
+type stateImport struct {
+	state State
+	done  chan error
+}
+
+// ExportState returns the state of all records. The state is read by Run, so ExportState blocks until Run handles
+// the request or the context is canceled.
+func (h *RecordManager) ExportState(ctx context.Context) (State, error) {
+	reply := make(chan State, 1)
+	select {
+	case h.stateExports <- reply:
+	case <-ctx.Done():
+		return State{}, ctx.Err()
+	}
+
+	select {
+	case state := <-reply:
+		return state, nil
+	case <-ctx.Done():
+		return State{}, ctx.Err()
+	}
+}
+
+// ImportState adopts the state of the records exported by another process and publishes the adopted selection.
+// Records and hostnames that are not managed are ignored, records that have not been checked conclusively by the
+// other process keep their state. It blocks until Run has adopted the state or the context is canceled.
+func (h *RecordManager) ImportState(ctx context.Context, state State) error {
+	if state.Version != StateVersion {
+		return fmt.Errorf("unsupported state version %d, expected %d", state.Version, StateVersion)
+	}
+	for _, hostnameState := range state.Hostnames {
+		for _, record := range hostnameState.Records {
+			switch record.Status {
+			case status.InitialStateName, status.HealthyStateName, status.UnhealthyStateName:
+			default:
+				return fmt.Errorf("invalid status %q of record %s of %q", record.Status, record.Ip, hostnameState.Hostname)
+			}
+		}
+	}
+
+	request := stateImport{state: state, done: make(chan error, 1)}
+	select {
+	case h.stateImports <- request:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-request.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (h *RecordManager) exportState() State {
+	ret := State{
+		Version:   StateVersion,
+		Exported:  h.clock.Now(),
+		Hostnames: make([]HostnameState, 0, len(h.managedRecords)),
+	}
+
+	for hostname, records := range h.managedRecords {
+		hostnameState := HostnameState{
+			Hostname:  hostname,
+			Published: h.published(hostname),
+			Records:   make([]RecordState, 0, len(records)),
+		}
+		if current := h.outages[hostname]; current != nil {
+			since := current.since
+			hostnameState.OutageSince = &since
+		}
+
+		for _, record := range records {
+			recordState := RecordState{
+				Ip:               record.Ip.String(),
+				Status:           status.Effective(record.status).Name(),
+				Streak:           record.status.Streak(),
+				LastStatusChange: record.lastStatusChange,
+				Maintenance:      record.InMaintenance(),
+				BackoffDelay:     record.backoffDelay,
+			}
+			if !record.nextCheck.IsZero() {
+				nextCheck := record.nextCheck
+				recordState.NextCheck = &nextCheck
+			}
+			if until, found := h.graceUntil[hostname][recordState.Ip]; found {
+				recordState.GraceUntil = &until
+			}
+			hostnameState.Records = append(hostnameState.Records, recordState)
+		}
+		ret.Hostnames = append(ret.Hostnames, hostnameState)
+	}
+
+	slices.SortFunc(ret.Hostnames, func(a, b HostnameState) int {
+		return strings.Compare(a.Hostname, b.Hostname)
+	})
+	return ret
+}
+
+func (h *RecordManager) importState(ctx context.Context, state State) error {
+	var adopted []string
+	for _, hostnameState := range state.Hostnames {
+		records, found := h.managedRecords[hostnameState.Hostname]
+		if !found {
+			slog.Warn("Ignoring state of hostname that is not managed", "hostname", hostnameState.Hostname)
+			continue
+		}
+
+		if err := h.importHostnameState(hostnameState, records); err != nil {
+			return fmt.Errorf("could not import state of %q: %w", hostnameState.Hostname, err)
+		}
+		adopted = append(adopted, hostnameState.Hostname)
+	}
+
+	slog.Info("Adopted state of records", "hostnames", adopted, "exported", state.Exported)
+	h.applyRecords(ctx)
+	return nil
+}
+
+func (h *RecordManager) importHostnameState(state HostnameState, records []*ManagedDnsRecord) error {
+	hostname := state.Hostname
+	var published []string
+	for _, recordState := range state.Records {
+		index := slices.IndexFunc(records, func(r *ManagedDnsRecord) bool { return r.Ip.String() == recordState.Ip })
+		if index < 0 {
+			slog.Warn("Ignoring state of record that is not managed", "hostname", hostname, "ip", recordState.Ip)
+			continue
+		}
+		record := records[index]
+
+		if slices.Contains(state.Published, recordState.Ip) {
+			published = append(published, recordState.Ip)
+		}
+		if recordState.GraceUntil != nil {
+			if h.graceUntil[hostname] == nil {
+				h.graceUntil[hostname] = map[string]time.Time{}
+			}
+			h.graceUntil[hostname][recordState.Ip] = *recordState.GraceUntil
+		}
+		if err := record.setMaintenance(recordState.Maintenance); err != nil {
+			return err
+		}
+		if err := record.restoreState(recordState); err != nil {
+			return err
+		}
+	}
+
+	if state.OutageSince != nil {
+		h.outages[hostname] = &outage{since: *state.OutageSince}
+	} else {
+		delete(h.outages, hostname)
+	}
+
+	slices.Sort(published)
+	h.publishedMutex.Lock()
+	h.publishedIps[hostname] = published
+	h.publishedMutex.Unlock()
+	return nil
+}
+
+// restoreState adopts the exported state of the record unless the other process has not checked it conclusively.
+func (r *ManagedDnsRecord) restoreState(state RecordState) error {
+	if state.Status == status.InitialStateName {
+		return nil
+	}
+
+	restored, err := status.Restore(r.status, state.Status, state.Streak)
+	if err != nil {
+		return fmt.Errorf("record %s: %w", state.Ip, err)
+	}
+
+	previous := r.status.Name()
+	r.status = restored
+	r.lastStatusChange = state.LastStatusChange
+	r.backoffDelay = state.BackoffDelay
+	r.nextCheck = time.Time{}
+	if state.NextCheck != nil {
+		r.nextCheck = *state.NextCheck
+	}
+	r.shared.update(restored)
+	r.shared.addTransition(Transition{Timestamp: state.LastStatusChange, From: previous, To: restored.Name()})
+	updateStatusMetrics(r.Hostname, r.Ip.String(), restored.Name())
+	metrics.StatusChangeTimestamp.WithLabelValues(r.Hostname, r.Ip.String()).Set(float64(state.LastStatusChange.Unix()))
+	return nil
+}
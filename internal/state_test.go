@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+func TestRecordManager_ImportState(t *testing.T) {
+	newRecords := func(healthy bool) map[string][]*ManagedDnsRecord {
+		newRecord := func(ip string, prio uint8, healthy bool) *ManagedDnsRecord {
+			record, err := NewManagedDnsRecord("my.tld", DnsRecord{Priority: prio, DnsType: "A", Ip: net.ParseIP(ip), Ttl: 60}, conf.StatusConfig{
+				HealthyStreak:          3,
+				UnhealthyStreak:        3,
+				InitialHealthyStreak:   1,
+				InitialUnhealthyStreak: 1,
+			}, &dummyHealthcheck{ret: healthy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return record
+		}
+		return map[string][]*ManagedDnsRecord{"my.tld": {newRecord("10.0.0.1", 20, false), newRecord("10.0.0.2", 10, healthy)}}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	old, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, newRecords(true), WithCheckInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go old.Run(ctx)
+
+	state, err := old.ExportState(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"10.0.0.2"}; len(state.Hostnames) != 1 || !reflect.DeepEqual(state.Hostnames[0].Published, want) {
+		t.Fatalf("expected published records %v, got %+v", want, state.Hostnames)
+	}
+
+	// the checks of the new process have not concluded yet, the unhealthy record would be published first
+	db := &dummyDnsDb{}
+	records := newRecords(false)
+	m, err := NewRecordManager(db, &dummyService{}, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.ImportState(ctx, State{Version: StateVersion + 1}); err == nil {
+		t.Fatal("expected error for unsupported version")
+	}
+
+	state.Hostnames = append(state.Hostnames, HostnameState{Hostname: "other.tld", Published: []string{"10.0.1.1"}})
+	if err := m.importState(ctx, state); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := records["my.tld"][0].GetState().Name(); got != status.UnhealthyStateName {
+		t.Errorf("expected record to adopt unhealthy state, got %s", got)
+	}
+	if got := records["my.tld"][1].GetState().Name(); got != status.HealthyStateName {
+		t.Errorf("expected record to adopt healthy state, got %s", got)
+	}
+	if want := []string{"A 10.0.0.2"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Errorf("expected adopted selection %v, got %v", want, db.updates["my.tld"])
+	}
+	if _, found := db.updates["other.tld"]; found {
+		t.Error("expected state of unmanaged hostname to be ignored")
+	}
+
+	// a single failed check must not flip the adopted state
+	m.CheckRecords(ctx)
+	if want := []string{"A 10.0.0.2"}; !reflect.DeepEqual(db.updates["my.tld"], want) {
+		t.Errorf("expected adopted selection to be kept, got %v", db.updates["my.tld"])
+	}
+}
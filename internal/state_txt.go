@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultStateTxtTtl = 60
+
+type stateSince struct {
+	ips   []string
+	since time.Time
+}
+
+// WithStateTxt publishes a TXT record alongside each hostname that contains the published addresses and the time
+// they last changed, e.g. "active=10.0.0.2 ts=2024-05-17T12:00:00Z", so automation can discover the live site via DNS.
+func WithStateTxt() RecordManagerOpts {
+	return func(m *RecordManager) error {
+		m.stateTxt = true
+		m.stateTxtSince = map[string]stateSince{}
+		return nil
+	}
+}
+
+// withStateTxt appends the TXT record signaling the failover state to the records of the hostname. It uses the
+// lowest ttl of the records, so the state never outlives the addresses it describes.
+func (h *RecordManager) withStateTxt(hostname string, records []ManagedDnsRecord) []ManagedDnsRecord {
+	if !h.stateTxt {
+		return records
+	}
+
+	ips := sortedIps(records)
+	state, found := h.stateTxtSince[hostname]
+	if !found || !slices.Equal(state.ips, ips) {
+		state = stateSince{ips: ips, since: h.clock.Now()}
+		h.stateTxtSince[hostname] = state
+	}
+
+	ttl := uint16(defaultStateTxtTtl)
+	if len(records) > 0 {
+		ttl = slices.MinFunc(records, func(a, b ManagedDnsRecord) int { return int(a.Ttl) - int(b.Ttl) }).Ttl
+	}
+
+	return append(slices.Clone(records), ManagedDnsRecord{
+		Hostname: hostname,
+		DnsRecord: DnsRecord{
+			DnsType: "TXT",
+			Ttl:     ttl,
+			Txt:     fmt.Sprintf("active=%s ts=%s", strings.Join(ips, ","), state.since.UTC().Format(time.RFC3339)),
+		},
+	})
+}
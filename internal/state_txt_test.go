@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordManager_withStateTxt(t *testing.T) {
+	m, err := NewRecordManager(&dummyDnsDb{}, &dummyService{}, nil, WithStateTxt())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []ManagedDnsRecord{
+		{DnsRecord: DnsRecord{DnsType: "A", Ip: net.ParseIP("10.0.0.2"), Ttl: 60}},
+		{DnsRecord: DnsRecord{DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 30}},
+	}
+	got := m.withStateTxt("my.tld", records)
+	if len(got) != 3 || len(records) != 2 {
+		t.Fatalf("expected a TXT record to be appended, got %v", got)
+	}
+	txt := got[2]
+	if txt.DnsType != "TXT" || txt.Ttl != 30 || txt.Hostname != "my.tld" {
+		t.Errorf("unexpected TXT record %+v", txt)
+	}
+	if !regexp.MustCompile(`^active=10\.0\.0\.1,10\.0\.0\.2 ts=\S+Z$`).MatchString(txt.Txt) {
+		t.Errorf("unexpected text %q", txt.Txt)
+	}
+
+	// the timestamp only changes with the published addresses
+	since := m.stateTxtSince["my.tld"].since
+	if again := m.withStateTxt("my.tld", records)[2]; again.Txt != txt.Txt {
+		t.Errorf("expected stable text, got %q and %q", txt.Txt, again.Txt)
+	}
+	m.stateTxtSince["my.tld"] = stateSince{ips: m.stateTxtSince["my.tld"].ips, since: since.Add(-time.Hour)}
+	changed := m.withStateTxt("my.tld", records[:1])[1]
+	if !strings.HasPrefix(changed.Txt, "active=10.0.0.2 ") || m.stateTxtSince["my.tld"].since.Before(since) {
+		t.Errorf("expected the timestamp to follow the change, got %q", changed.Txt)
+	}
+
+	if removed := m.withStateTxt("my.tld", nil); len(removed) != 1 || removed[0].Ttl != defaultStateTxtTtl {
+		t.Errorf("expected TXT record for removed records, got %+v", removed)
+	}
+}
+
+func TestDnsRecord_Data(t *testing.T) {
+	tests := []struct {
+		record DnsRecord
+		want   string
+	}{
+		{record: DnsRecord{DnsType: "A", Ip: net.ParseIP("10.0.0.1")}, want: "10.0.0.1"},
+		{record: DnsRecord{DnsType: "TXT", Txt: `active=10.0.0.1 "quoted"`}, want: `"active=10.0.0.1 \"quoted\""`},
+	}
+	for _, tt := range tests {
+		if got := tt.record.Data(); got != tt.want {
+			t.Errorf("Data() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package status
+
+const (
+	ErrorStateName = "error"
+
+	// ErrorPolicyUnhealthy treats check errors like unhealthy results.
+	ErrorPolicyUnhealthy = "unhealthy"
+	// ErrorPolicyFreeze ignores check errors for the health streaks and moves the record into the Error state after
+	// the error streak has been reached. The record keeps its previous state for the selection of records.
+	ErrorPolicyFreeze = "freeze"
+)
+
+// Error is the state of a record whose checks keep failing with errors instead of producing a result. The record is
+// treated like its previous state until the first check produces a result again.
+type Error struct {
+	previous State
+}
+
+func newError(previous State) *Error {
+	return &Error{previous: previous}
+}
+
+func (s *Error) Name() string {
+	return ErrorStateName
+}
+
+func (s *Error) Streak() int {
+	return s.previous.Streak()
+}
+
+// Previous returns the state the record was in before its checks started failing with errors.
+func (s *Error) Previous() State {
+	return s.previous
+}
+
+func (s *Error) Healthy(state StateContext) {
+	state.SetState(s.previous)
+	s.previous.Healthy(state)
+}
+
+func (s *Error) Unhealthy(state StateContext) {
+	state.SetState(s.previous)
+	s.previous.Unhealthy(state)
+}
+
+func (s *Error) Error(state StateContext) {
+}
+
+// Effective returns the state that is used to select records, records in the Error state keep their previous state.
+func Effective(state State) State {
+	if errState, ok := state.(*Error); ok {
+		return errState.previous
+	}
+	return state
+}
+
+// errorCounter counts consecutive check errors if the freeze policy is configured.
+type errorCounter struct {
+	count int
+}
+
+// freeze returns true if the error must not affect the health streaks. It moves the record into the Error state once
+// the error streak has been reached.
+func (e *errorCounter) freeze(state StateContext, current State, thresholds thresholds) bool {
+	if thresholds.onError != ErrorPolicyFreeze {
+		return false
+	}
+
+	e.count++
+	if e.count >= max(thresholds.errorStreak, 1) {
+		e.count = 0
+		state.SetState(newError(current))
+	}
+	return true
+}
+
+func (e *errorCounter) reset() {
+	e.count = 0
+}
@@ -0,0 +1,46 @@
+package status
+
+import (
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+func TestError_Freeze(t *testing.T) {
+	ctx := &dummyContext{}
+	ctx.state = newHealthy(newThresholds(conf.StatusConfig{HealthyStreak: 1, UnhealthyStreak: 1, OnError: ErrorPolicyFreeze, ErrorStreak: 2}))
+
+	ctx.state.Error(ctx)
+	if ctx.state.Name() != HealthyStateName {
+		t.Fatalf("expected record to stay healthy before error streak is reached, got %s", ctx.state.Name())
+	}
+
+	ctx.state.Error(ctx)
+	if ctx.state.Name() != ErrorStateName {
+		t.Fatalf("expected error state, got %s", ctx.state.Name())
+	}
+	if Effective(ctx.state).Name() != HealthyStateName {
+		t.Fatalf("expected effective state to be healthy, got %s", Effective(ctx.state).Name())
+	}
+
+	ctx.state.Error(ctx)
+	if ctx.state.Name() != ErrorStateName {
+		t.Fatalf("expected record to stay in error state, got %s", ctx.state.Name())
+	}
+
+	// the first result leaves the error state and is applied to the previous state
+	ctx.state.Unhealthy(ctx)
+	if ctx.state.Name() != UnhealthyStateName {
+		t.Fatalf("expected record to be unhealthy, got %s", ctx.state.Name())
+	}
+}
+
+func TestError_Unhealthy(t *testing.T) {
+	ctx := &dummyContext{}
+	ctx.state = newHealthy(newThresholds(conf.StatusConfig{HealthyStreak: 1, UnhealthyStreak: 1, OnError: ErrorPolicyUnhealthy}))
+
+	ctx.state.Error(ctx)
+	if ctx.state.Name() != UnhealthyStateName {
+		t.Fatalf("expected errors to count as unhealthy results, got %s", ctx.state.Name())
+	}
+}
@@ -3,17 +3,15 @@ package status
 const HealthyStateName = "healthy"
 
 type Healthy struct {
-	currentStreak int
-
-	cfgStreakUntilHealthy   int
-	cfgStreakUntilUnhealthy int
+	streak     streak
+	errors     errorCounter
+	thresholds thresholds
 }
 
-func newHealthy(healthyStreak, unhealthyStreak int) *Healthy {
+func newHealthy(thresholds thresholds) *Healthy {
 	return &Healthy{
-		currentStreak:           healthyStreak,
-		cfgStreakUntilHealthy:   healthyStreak,
-		cfgStreakUntilUnhealthy: unhealthyStreak,
+		streak:     newStreak(thresholds.healthyStreak),
+		thresholds: thresholds,
 	}
 }
 
@@ -22,24 +20,28 @@ func (s *Healthy) Name() string {
 }
 
 func (s *Healthy) Streak() int {
-	return s.currentStreak
+	return s.streak.remaining
 }
 
 func (s *Healthy) Healthy(state StateContext) {
+	s.errors.reset()
 	// reset streak
-	s.currentStreak = s.cfgStreakUntilUnhealthy
+	s.streak.reset(s.thresholds.unhealthyStreak)
 }
 
 func (s *Healthy) Unhealthy(state StateContext) {
-	s.currentStreak--
-	if s.currentStreak <= 0 {
-		state.SetState(newUnhealthy(s.cfgStreakUntilHealthy, s.cfgStreakUntilUnhealthy))
+	s.errors.reset()
+	if s.streak.advance(state.Now(), s.thresholds.unhealthyAfter) {
+		state.SetState(newUnhealthy(s.thresholds))
 	}
 }
 
 func (s *Healthy) Error(state StateContext) {
-	s.currentStreak--
-	if s.currentStreak <= 0 {
-		state.SetState(newUnhealthy(s.cfgStreakUntilHealthy, s.cfgStreakUntilUnhealthy))
+	if s.errors.freeze(state, s, s.thresholds) {
+		return
+	}
+
+	if s.streak.advance(state.Now(), s.thresholds.unhealthyAfter) {
+		state.SetState(newUnhealthy(s.thresholds))
 	}
 }
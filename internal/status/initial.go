@@ -6,12 +6,16 @@ import (
 
 const InitialStateName = "initial"
 
+// Initial is the state of a record that has not been checked conclusively yet. Time based thresholds are not applied
+// to it, so records are published quickly after startup.
 type Initial struct {
 	currentStreak int
 	lastState     string
 
 	cfgHealthyStreak   int
 	cfgUnhealthyStreak int
+	errors             errorCounter
+	thresholds         thresholds
 }
 
 func NewUnknownState(opts conf.StatusConfig) *Initial {
@@ -19,6 +23,7 @@ func NewUnknownState(opts conf.StatusConfig) *Initial {
 		currentStreak:      opts.InitialHealthyStreak,
 		cfgHealthyStreak:   opts.HealthyStreak,
 		cfgUnhealthyStreak: opts.UnhealthyStreak,
+		thresholds:         newThresholds(opts),
 	}
 }
 
@@ -31,6 +36,7 @@ func (s *Initial) Streak() int {
 }
 
 func (s *Initial) Healthy(state StateContext) {
+	s.errors.reset()
 	if s.lastState == UnhealthyStateName {
 		s.currentStreak = s.cfgHealthyStreak
 		s.lastState = HealthyStateName
@@ -38,11 +44,12 @@ func (s *Initial) Healthy(state StateContext) {
 
 	s.currentStreak--
 	if s.currentStreak <= 0 {
-		state.SetState(newHealthy(s.cfgHealthyStreak, s.cfgUnhealthyStreak))
+		state.SetState(newHealthy(s.thresholds))
 	}
 }
 
 func (s *Initial) Unhealthy(state StateContext) {
+	s.errors.reset()
 	if s.lastState == HealthyStateName {
 		s.currentStreak = s.cfgUnhealthyStreak
 		s.lastState = UnhealthyStateName
@@ -50,9 +57,10 @@ func (s *Initial) Unhealthy(state StateContext) {
 
 	s.currentStreak--
 	if s.currentStreak <= 0 {
-		state.SetState(newUnhealthy(s.cfgHealthyStreak, s.cfgUnhealthyStreak))
+		state.SetState(newUnhealthy(s.thresholds))
 	}
 }
 
 func (s *Initial) Error(state StateContext) {
+	s.errors.freeze(state, s, s.thresholds)
 }
@@ -0,0 +1,37 @@
+package status
+
+import "fmt"
+
+// Restore returns a new state with the given name and remaining streak that uses the thresholds of the current state,
+// e.g. to adopt the state of a record from another dns-ha process. Only the healthy and unhealthy states can be
+// restored, records in the Error state are restored as their previous state.
+func Restore(current State, name string, streak int) (State, error) {
+	var t thresholds
+	switch s := Effective(current).(type) {
+	case *Initial:
+		t = s.thresholds
+	case *Healthy:
+		t = s.thresholds
+	case *Unhealthy:
+		t = s.thresholds
+	default:
+		return nil, fmt.Errorf("can not restore state of %T", current)
+	}
+
+	switch name {
+	case HealthyStateName:
+		ret := newHealthy(t)
+		if streak > 0 {
+			ret.streak.remaining = streak
+		}
+		return ret, nil
+	case UnhealthyStateName:
+		ret := newUnhealthy(t)
+		if streak > 0 {
+			ret.streak.remaining = streak
+		}
+		return ret, nil
+	default:
+		return nil, fmt.Errorf("can not restore state %q", name)
+	}
+}
@@ -1,5 +1,7 @@
 package status
 
+import "time"
+
 type State interface {
 	Name() string
 
@@ -9,6 +11,8 @@ type State interface {
 	Error(ctx StateContext)
 }
 
+// StateContext is the record whose state changes. It provides the time, so the time based thresholds can be simulated.
 type StateContext interface {
 	SetState(state State)
+	Now() time.Time
 }
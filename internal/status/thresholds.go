@@ -0,0 +1,53 @@
+package status
+
+import (
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+// thresholds define when a record changes between healthy and unhealthy. A change requires both the streak of
+// consecutive results and, if set, the duration since the first result of the streak.
+type thresholds struct {
+	healthyStreak   int
+	unhealthyStreak int
+	healthyAfter    time.Duration
+	unhealthyAfter  time.Duration
+	onError         string
+	errorStreak     int
+}
+
+func newThresholds(opts conf.StatusConfig) thresholds {
+	return thresholds{
+		healthyStreak:   opts.HealthyStreak,
+		unhealthyStreak: opts.UnhealthyStreak,
+		healthyAfter:    opts.HealthyAfter,
+		unhealthyAfter:  opts.UnhealthyAfter,
+		onError:         opts.OnError,
+		errorStreak:     opts.ErrorStreak,
+	}
+}
+
+// streak counts consecutive results that speak for a change of the state.
+type streak struct {
+	remaining int
+	start     time.Time
+}
+
+func newStreak(length int) streak {
+	return streak{remaining: length}
+}
+
+// advance records a result speaking for a change and returns true once the change is due.
+func (s *streak) advance(now time.Time, after time.Duration) bool {
+	if s.start.IsZero() {
+		s.start = now
+	}
+	s.remaining--
+	return s.remaining <= 0 && now.Sub(s.start) >= after
+}
+
+func (s *streak) reset(length int) {
+	s.remaining = length
+	s.start = time.Time{}
+}
@@ -0,0 +1,72 @@
+package status
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+type dummyContext struct {
+	state State
+	now   time.Time
+}
+
+func (d *dummyContext) SetState(state State) {
+	d.state = state
+}
+
+func (d *dummyContext) Now() time.Time {
+	if d.now.IsZero() {
+		return time.Now()
+	}
+	return d.now
+}
+
+func TestThresholds_UnhealthyAfter(t *testing.T) {
+	ctx := &dummyContext{now: time.Unix(0, 0)}
+	ctx.state = newHealthy(newThresholds(conf.StatusConfig{HealthyStreak: 1, UnhealthyStreak: 1, UnhealthyAfter: 90 * time.Second}))
+
+	for _, offset := range []time.Duration{0, 30 * time.Second, 60 * time.Second} {
+		ctx.now = time.Unix(0, 0).Add(offset)
+		ctx.state.Unhealthy(ctx)
+		if ctx.state.Name() != HealthyStateName {
+			t.Fatalf("expected record to stay healthy after failing for %v", offset)
+		}
+	}
+
+	// a success resets the streak and its start
+	ctx.now = ctx.now.Add(30 * time.Second)
+	ctx.state.Healthy(ctx)
+	ctx.now = ctx.now.Add(30 * time.Second)
+	ctx.state.Error(ctx)
+	if ctx.state.Name() != HealthyStateName {
+		t.Fatal("expected record to stay healthy after streak has been reset")
+	}
+
+	ctx.now = ctx.now.Add(90 * time.Second)
+	ctx.state.Unhealthy(ctx)
+	if ctx.state.Name() != UnhealthyStateName {
+		t.Fatal("expected record to be unhealthy after failing for 90s")
+	}
+}
+
+func TestThresholds_HealthyAfter(t *testing.T) {
+	ctx := &dummyContext{now: time.Unix(0, 0)}
+	ctx.state = newUnhealthy(newThresholds(conf.StatusConfig{HealthyStreak: 3, UnhealthyStreak: 3, HealthyAfter: 5 * time.Minute}))
+
+	// the count streak is reached after three checks, but the duration is not
+	for range 3 {
+		ctx.state.Healthy(ctx)
+		ctx.now = ctx.now.Add(time.Minute)
+	}
+	if ctx.state.Name() != UnhealthyStateName {
+		t.Fatal("expected record to stay unhealthy before healthy_after passed")
+	}
+
+	ctx.now = time.Unix(0, 0).Add(5 * time.Minute)
+	ctx.state.Healthy(ctx)
+	if ctx.state.Name() != HealthyStateName {
+		t.Fatal("expected record to be healthy after succeeding for 5m")
+	}
+}
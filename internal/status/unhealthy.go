@@ -3,17 +3,15 @@ package status
 const UnhealthyStateName = "unhealthy"
 
 type Unhealthy struct {
-	currentStreak int
-
-	cfgStreakUntilHealthy   int
-	cfgStreakUntilUnhealthy int
+	streak     streak
+	errors     errorCounter
+	thresholds thresholds
 }
 
-func newUnhealthy(healthyStreak, unhealthyStreak int) *Unhealthy {
+func newUnhealthy(thresholds thresholds) *Unhealthy {
 	return &Unhealthy{
-		currentStreak:           unhealthyStreak,
-		cfgStreakUntilHealthy:   healthyStreak,
-		cfgStreakUntilUnhealthy: unhealthyStreak,
+		streak:     newStreak(thresholds.unhealthyStreak),
+		thresholds: thresholds,
 	}
 }
 
@@ -22,22 +20,27 @@ func (s *Unhealthy) Name() string {
 }
 
 func (s *Unhealthy) Streak() int {
-	return s.currentStreak
+	return s.streak.remaining
 }
 
 func (s *Unhealthy) Healthy(state StateContext) {
-	s.currentStreak--
-	if s.currentStreak <= 0 {
-		state.SetState(newHealthy(s.cfgStreakUntilHealthy, s.cfgStreakUntilUnhealthy))
+	s.errors.reset()
+	if s.streak.advance(state.Now(), s.thresholds.healthyAfter) {
+		state.SetState(newHealthy(s.thresholds))
 	}
 }
 
 func (s *Unhealthy) Unhealthy(state StateContext) {
+	s.errors.reset()
 	// reset streak
-	s.currentStreak = s.cfgStreakUntilUnhealthy
+	s.streak.reset(s.thresholds.unhealthyStreak)
 }
 
 func (s *Unhealthy) Error(state StateContext) {
+	if s.errors.freeze(state, s, s.thresholds) {
+		return
+	}
+
 	// reset streak
-	s.currentStreak = s.cfgStreakUntilUnhealthy
+	s.streak.reset(s.thresholds.unhealthyStreak)
 }
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+)
+
+const (
+	// StrategyPriority publishes the healthy record with the highest priority of each address family.
+	StrategyPriority = "priority"
+	// StrategyWeighted spreads hostnames across the healthy records of each address family, using the priority as
+	// weight. The choice is stable, it only changes if the set of healthy records changes.
+	StrategyWeighted = "weighted"
+	// StrategyLatency publishes the healthy record with the lowest check latency of each address family.
+	StrategyLatency = "latency"
+	// StrategyAllHealthy publishes all healthy records.
+	StrategyAllHealthy = "all_healthy"
+
+	// latencyResolution is the precision latencies are compared with, so jitter does not flip the selection
+	latencyResolution = 10 * time.Millisecond
+)
+
+// Strategy selects the records to publish among the healthy records of a hostname.
+type Strategy interface {
+	// Name identifies the strategy in metrics.
+	Name() string
+	// Select returns the records to publish. Healthy contains at least one record and is ordered by priority, highest
+	// first.
+	Select(hostname string, healthy []ManagedDnsRecord) []ManagedDnsRecord
+}
+
+var builtinStrategies = map[string]Strategy{
+	StrategyPriority:   priorityStrategy{},
+	StrategyWeighted:   weightedStrategy{},
+	StrategyLatency:    latencyStrategy{},
+	StrategyAllHealthy: allHealthyStrategy{},
+}
+
+// BuiltinStrategy returns the built-in strategy of the given name or nil if there is none.
+func BuiltinStrategy(name string) Strategy {
+	return builtinStrategies[name]
+}
+
+func (h *RecordManager) strategy(hostname string) Strategy {
+	if strategy := h.hostnamePolicies[hostname].Strategy; strategy != nil {
+		return strategy
+	}
+	return priorityStrategy{}
+}
+
+// exposeStrategies exposes the selection strategy of each hostname.
+func (h *RecordManager) exposeStrategies() {
+	for hostname := range h.managedRecords {
+		metrics.SetSelectionStrategy(hostname, h.strategy(hostname).Name())
+	}
+}
+
+// perAddressFamily applies pick to the healthy records of each address family and returns the picked records.
+func perAddressFamily(healthy []ManagedDnsRecord, pick func([]ManagedDnsRecord) ManagedDnsRecord) []ManagedDnsRecord {
+	var families []string
+	for _, record := range healthy {
+		if !slices.Contains(families, record.DnsType) {
+			families = append(families, record.DnsType)
+		}
+	}
+
+	ret := make([]ManagedDnsRecord, 0, len(families))
+	for _, family := range families {
+		records := slices.DeleteFunc(slices.Clone(healthy), func(r ManagedDnsRecord) bool {
+			return r.DnsType != family
+		})
+		ret = append(ret, pick(records))
+	}
+	return ret
+}
+
+type priorityStrategy struct{}
+
+func (priorityStrategy) Name() string {
+	return StrategyPriority
+}
+
+func (priorityStrategy) Select(_ string, healthy []ManagedDnsRecord) []ManagedDnsRecord {
+	return perAddressFamily(healthy, func(records []ManagedDnsRecord) ManagedDnsRecord {
+		return records[0]
+	})
+}
+
+type weightedStrategy struct{}
+
+func (weightedStrategy) Name() string {
+	return StrategyWeighted
+}
+
+// Select uses rendezvous hashing of the hostname and the addresses, so each hostname sticks to its record while
+// hostnames are spread across the records in proportion to their weights.
+func (weightedStrategy) Select(hostname string, healthy []ManagedDnsRecord) []ManagedDnsRecord {
+	score := func(record ManagedDnsRecord) float64 {
+		// addresses often differ in the last byte only, which a cheap hash like fnv does not spread across its high bits
+		sum := sha256.Sum256([]byte(hostname + "|" + record.Ip.String()))
+		// map the hash to (0, 1), its logarithm is negative, so heavier records get higher scores
+		unit := (float64(binary.BigEndian.Uint64(sum[:])>>11) + 0.5) / (1 << 53)
+		return -float64(max(record.Priority, 1)) / math.Log(unit)
+	}
+
+	return perAddressFamily(healthy, func(records []ManagedDnsRecord) ManagedDnsRecord {
+		return slices.MaxFunc(records, func(a, b ManagedDnsRecord) int {
+			return cmp.Compare(score(a), score(b))
+		})
+	})
+}
+
+type latencyStrategy struct{}
+
+func (latencyStrategy) Name() string {
+	return StrategyLatency
+}
+
+// Select prefers the record with the higher priority if the latencies are equal at the resolution of
+// latencyResolution.
+func (latencyStrategy) Select(_ string, healthy []ManagedDnsRecord) []ManagedDnsRecord {
+	return perAddressFamily(healthy, func(records []ManagedDnsRecord) ManagedDnsRecord {
+		// MinFunc returns the first minimal record, which is the one with the highest priority
+		return slices.MinFunc(records, func(a, b ManagedDnsRecord) int {
+			return cmp.Compare(a.Latency().Round(latencyResolution), b.Latency().Round(latencyResolution))
+		})
+	})
+}
+
+type allHealthyStrategy struct{}
+
+func (allHealthyStrategy) Name() string {
+	return StrategyAllHealthy
+}
+
+func (allHealthyStrategy) Select(_ string, healthy []ManagedDnsRecord) []ManagedDnsRecord {
+	return healthy
+}
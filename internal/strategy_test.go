@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+func strategyRecord(ip string, priority uint8, latency time.Duration) ManagedDnsRecord {
+	dnsType := "A"
+	if net.ParseIP(ip).To4() == nil {
+		dnsType = "AAAA"
+	}
+	return ManagedDnsRecord{
+		DnsRecord: DnsRecord{Priority: priority, DnsType: dnsType, Ip: net.ParseIP(ip), Ttl: 60},
+		latency:   latency,
+	}
+}
+
+func TestStrategy_Select(t *testing.T) {
+	healthy := []ManagedDnsRecord{
+		strategyRecord("10.0.0.1", 100, 30*time.Millisecond),
+		strategyRecord("2001:db8::1", 90, 30*time.Millisecond),
+		strategyRecord("10.0.0.2", 50, 5*time.Millisecond),
+		strategyRecord("10.0.0.3", 10, 32*time.Millisecond),
+		strategyRecord("2001:db8::2", 10, 28*time.Millisecond),
+	}
+
+	tests := []struct {
+		strategy string
+		want     []string
+	}{
+		{strategy: StrategyPriority, want: []string{"10.0.0.1", "2001:db8::1"}},
+		{strategy: StrategyLatency, want: []string{"10.0.0.2", "2001:db8::1"}},
+		{strategy: StrategyAllHealthy, want: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "2001:db8::1", "2001:db8::2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			strategy := BuiltinStrategy(tt.strategy)
+			if strategy.Name() != tt.strategy {
+				t.Errorf("Name() = %q, want %q", strategy.Name(), tt.strategy)
+			}
+			if got := sortedIps(strategy.Select("my.tld", healthy)); !slices.Equal(got, tt.want) {
+				t.Errorf("Select() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeightedStrategy_Select(t *testing.T) {
+	healthy := []ManagedDnsRecord{
+		strategyRecord("10.0.0.1", 30, 0),
+		strategyRecord("10.0.0.2", 10, 0),
+		strategyRecord("10.0.0.3", 10, 0),
+	}
+
+	weighted := weightedStrategy{}
+	selected := map[string]int{}
+	for i := range 5000 {
+		hostname := fmt.Sprintf("host-%d.my.tld", i)
+		got := weighted.Select(hostname, healthy)
+		if len(got) != 1 {
+			t.Fatalf("expected a single record, got %v", got)
+		}
+		ip := got[0].Ip.String()
+		selected[ip]++
+
+		// the selection is stable and only moves away from records that are not healthy anymore
+		if again := weighted.Select(hostname, healthy); again[0].Ip.String() != ip {
+			t.Fatalf("selection of %s is not stable", hostname)
+		}
+		withoutOther := slices.DeleteFunc(slices.Clone(healthy), func(r ManagedDnsRecord) bool {
+			return r.Ip.String() != ip && r.Ip.String() == "10.0.0.3"
+		})
+		if moved := weighted.Select(hostname, withoutOther); moved[0].Ip.String() != ip {
+			t.Fatalf("selection of %s moved from %s after another record became unhealthy", hostname, ip)
+		}
+	}
+
+	// the record with weight 30 of 50 receives 60% of the hostnames
+	if share := float64(selected["10.0.0.1"]) / 5000; share < 0.55 || share > 0.65 {
+		t.Errorf("expected a share of about 0.6 for the heaviest record, got %v (%v)", share, selected)
+	}
+}
+
+func TestFilterHealthyIps_customStrategy(t *testing.T) {
+	ips := []*ManagedDnsRecord{
+		{DnsRecord: DnsRecord{Priority: 10, DnsType: "A", Ip: net.ParseIP("10.0.0.2"), Ttl: 60}, Hostname: "my.tld", status: &status.Healthy{}},
+		{DnsRecord: DnsRecord{Priority: 50, DnsType: "A", Ip: net.ParseIP("10.0.0.3"), Ttl: 60}, Hostname: "my.tld", status: &status.Unhealthy{}},
+		{DnsRecord: DnsRecord{Priority: 100, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, Hostname: "my.tld", status: &status.Healthy{}},
+	}
+
+	got := filterHealthyIps("my.tld", ips, lowestPriorityStrategy{})
+	want := []string{"10.0.0.2"}
+	if !slices.Equal(sortedIps(got), want) {
+		t.Errorf("filterHealthyIps() = %v, want %v", sortedIps(got), want)
+	}
+}
+
+// lowestPriorityStrategy publishes the healthy record with the lowest priority.
+type lowestPriorityStrategy struct{}
+
+func (lowestPriorityStrategy) Name() string {
+	return "lowest_priority"
+}
+
+func (lowestPriorityStrategy) Select(_ string, healthy []ManagedDnsRecord) []ManagedDnsRecord {
+	return healthy[len(healthy)-1:]
+}
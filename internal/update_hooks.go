@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"github.com/soerenschneider/dns-ha/internal/metrics"
+)
+
+// UpdateFailedHooks is optionally implemented by Hooks to be notified if an update announced by PreUpdate could not
+// be applied, so every PreUpdate is followed by either PostUpdate or UpdateFailed.
+type UpdateFailedHooks interface {
+	UpdateFailed(ctx context.Context, hostname string, oldIps, newIps []string, err error) error
+}
+
+// preUpdate runs the pre_update hooks of the hostnames whose selection changes with the update and returns them.
+// Hostnames whose published records are unknown, e.g. right after the start, are not announced, as it's unknown
+// whether the update changes them at all.
+func (h *RecordManager) preUpdate(ctx context.Context, desired map[string][]ManagedDnsRecord, previousIps map[string][]string) []string {
+	if h.hooks == nil {
+		return nil
+	}
+
+	var announced []string
+	for hostname, records := range desired {
+		oldIps, known := previousIps[hostname]
+		if known && !slices.Equal(oldIps, sortedIps(records)) {
+			announced = append(announced, hostname)
+		}
+	}
+	slices.Sort(announced)
+
+	for _, hostname := range announced {
+		if err := h.hooks.PreUpdate(ctx, hostname, previousIps[hostname], sortedIps(desired[hostname])); err != nil {
+			metrics.Errors.WithLabelValues(hostname, "hook_pre_update", ErrorKind(err)).Inc()
+			slog.Error("pre_update hook failed", "hostname", hostname, "err", err)
+		}
+	}
+	return announced
+}
+
+// postUpdate runs the post_update hooks of the announced hostnames once their update has been applied.
+func (h *RecordManager) postUpdate(ctx context.Context, announced []string, previousIps map[string][]string) {
+	for _, hostname := range announced {
+		if err := h.hooks.PostUpdate(ctx, hostname, previousIps[hostname], h.publishedIps[hostname]); err != nil {
+			metrics.Errors.WithLabelValues(hostname, "hook_post_update", ErrorKind(err)).Inc()
+			slog.Error("post_update hook failed", "hostname", hostname, "err", err)
+		}
+	}
+}
+
+// updateFailed runs the update_failed hooks of the announced hostnames whose update to the new ips could not be
+// applied.
+func (h *RecordManager) updateFailed(ctx context.Context, announced []string, previousIps, newIps map[string][]string, cause error) {
+	hooks, ok := h.hooks.(UpdateFailedHooks)
+	if !ok {
+		return
+	}
+
+	for _, hostname := range announced {
+		if err := hooks.UpdateFailed(ctx, hostname, previousIps[hostname], newIps[hostname], cause); err != nil {
+			metrics.Errors.WithLabelValues(hostname, "hook_update_failed", ErrorKind(err)).Inc()
+			slog.Error("update_failed hook failed", "hostname", hostname, "err", err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+type updateRecorder struct {
+	events []string
+}
+
+func (u *updateRecorder) PreUpdate(_ context.Context, hostname string, _, _ []string) error {
+	u.events = append(u.events, "pre_update "+hostname)
+	return nil
+}
+
+func (u *updateRecorder) PostUpdate(_ context.Context, hostname string, _, _ []string) error {
+	u.events = append(u.events, "post_update "+hostname)
+	return nil
+}
+
+func (u *updateRecorder) UpdateFailed(_ context.Context, hostname string, _, _ []string, _ error) error {
+	u.events = append(u.events, "update_failed "+hostname)
+	return nil
+}
+
+func (u *updateRecorder) PostRestart(_ context.Context, _ []string) error {
+	return nil
+}
+
+func TestRecordManager_updateHooks(t *testing.T) {
+	tests := []struct {
+		name      string
+		published []string
+		fail      bool
+		want      []string
+	}{
+		{
+			name: "published records unknown",
+		},
+		{
+			name:      "selection unchanged",
+			published: []string{"10.0.0.1"},
+		},
+		{
+			name:      "selection changed",
+			published: []string{"10.0.0.2"},
+			want:      []string{"pre_update my.tld", "post_update my.tld"},
+		},
+		{
+			name:      "update failed",
+			published: []string{"10.0.0.2"},
+			fail:      true,
+			want:      []string{"pre_update my.tld", "update_failed my.tld"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := []*ManagedDnsRecord{
+				{DnsRecord: DnsRecord{Priority: 10, DnsType: "A", Ip: net.ParseIP("10.0.0.1"), Ttl: 60}, Hostname: "my.tld", status: &status.Healthy{}},
+				{DnsRecord: DnsRecord{Priority: 20, DnsType: "A", Ip: net.ParseIP("10.0.0.2"), Ttl: 60}, Hostname: "my.tld", status: &status.Unhealthy{}},
+			}
+			hooks := &updateRecorder{}
+			db := &failingDnsDb{fail: map[string]bool{"my.tld": tt.fail}}
+			m, err := NewRecordManager(db, &dummyService{}, map[string][]*ManagedDnsRecord{"my.tld": records}, WithHooks(hooks))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.published != nil {
+				m.publishedIps["my.tld"] = tt.published
+			}
+
+			m.applyRecords(context.Background())
+			if !reflect.DeepEqual(hooks.events, tt.want) {
+				t.Errorf("hooks got %v, want %v", hooks.events, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ReferencePrefix = "vault:"
+
+	AuthToken      = "token"
+	AuthApprole    = "approle"
+	AuthKubernetes = "kubernetes"
+
+	defaultKubernetesJwtPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	defaultKvVersion         = 2
+	minRenewInterval         = 5 * time.Second
+)
+
+// Config configures the Vault client, it's embedded in dns-ha's config.
+type Config struct {
+	Address   string     `json:"address" yaml:"address" validate:"required,url"`
+	KvVersion int        `json:"kv_version" yaml:"kv_version" validate:"omitempty,oneof=1 2"`
+	Auth      AuthConfig `json:"auth" yaml:"auth"`
+}
+
+type AuthConfig struct {
+	Method string `json:"method" yaml:"method" validate:"required,oneof=token approle kubernetes"`
+	// Mount is the path the auth method is mounted at, defaults to the name of the method.
+	Mount string `json:"mount" yaml:"mount"`
+
+	// Token is used by the token method, it defaults to the VAULT_TOKEN environment variable.
+	Token string `json:"token" yaml:"token"`
+
+	RoleId   string `json:"role_id" yaml:"role_id" validate:"required_if=Method approle"`
+	SecretId string `json:"secret_id" yaml:"secret_id" validate:"required_if=Method approle"`
+
+	Role    string `json:"role" yaml:"role" validate:"required_if=Method kubernetes"`
+	JwtPath string `json:"jwt_path" yaml:"jwt_path"`
+}
+
+// Client is a minimal Vault client that authenticates using one of the supported auth methods, reads KV secrets and
+// keeps its token alive.
+type Client struct {
+	conf       Config
+	httpClient *http.Client
+
+	mutex    sync.RWMutex
+	token    string
+	leaseTtl time.Duration
+	canRenew bool
+	// refreshAt holds the time secrets with a lease need to be read again, by reference
+	refreshAt     map[string]time.Time
+	leasesChanged chan struct{}
+}
+
+func NewClient(ctx context.Context, conf Config) (*Client, error) {
+	if conf.Address == "" {
+		return nil, errors.New("empty vault address supplied")
+	}
+
+	if conf.KvVersion == 0 {
+		conf.KvVersion = defaultKvVersion
+	}
+
+	c := &Client{
+		conf: conf,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		refreshAt:     map[string]time.Time{},
+		leasesChanged: make(chan struct{}, 1),
+	}
+
+	if err := c.login(ctx); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+type authResponse struct {
+	Auth struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+func (c *Client) login(ctx context.Context) error {
+	mount := c.conf.Auth.Mount
+	if mount == "" {
+		mount = c.conf.Auth.Method
+	}
+
+	var payload map[string]string
+	switch c.conf.Auth.Method {
+	case AuthToken:
+		token := c.conf.Auth.Token
+		if token == "" {
+			token = os.Getenv("VAULT_TOKEN")
+		}
+		if token == "" {
+			return errors.New("no vault token supplied")
+		}
+		c.setToken(token, 0, true)
+		return nil
+	case AuthApprole:
+		payload = map[string]string{
+			"role_id":   c.conf.Auth.RoleId,
+			"secret_id": c.conf.Auth.SecretId,
+		}
+	case AuthKubernetes:
+		jwtPath := c.conf.Auth.JwtPath
+		if jwtPath == "" {
+			jwtPath = defaultKubernetesJwtPath
+		}
+		jwt, err := os.ReadFile(jwtPath)
+		if err != nil {
+			return fmt.Errorf("could not read service account token: %w", err)
+		}
+		payload = map[string]string{
+			"role": c.conf.Auth.Role,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		}
+	default:
+		return fmt.Errorf("unknown vault auth method %q", c.conf.Auth.Method)
+	}
+
+	var resp authResponse
+	if err := c.do(ctx, http.MethodPost, "auth/"+mount+"/login", payload, false, &resp); err != nil {
+		return fmt.Errorf("vault login failed: %w", err)
+	}
+
+	c.setToken(resp.Auth.ClientToken, time.Duration(resp.Auth.LeaseDuration)*time.Second, resp.Auth.Renewable)
+	return nil
+}
+
+func (c *Client) setToken(token string, ttl time.Duration, renewable bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.token = token
+	c.leaseTtl = ttl
+	c.canRenew = renewable
+}
+
+// Resolve reads the secret referenced as "vault:<mount>/<path>#<key>". Secrets with a lease, e.g. short-lived
+// credentials, are refreshed by KeepAlive.
+func (c *Client) Resolve(ctx context.Context, reference string) (string, error) {
+	mount, path, key, err := parseReference(reference)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		LeaseDuration int            `json:"lease_duration"`
+		Data          map[string]any `json:"data"`
+	}
+
+	apiPath := mount + "/" + path
+	if c.conf.KvVersion == 2 {
+		apiPath = mount + "/data/" + path
+	}
+
+	if err := c.do(ctx, http.MethodGet, apiPath, nil, true, &resp); err != nil {
+		return "", fmt.Errorf("could not read %q: %w", reference, err)
+	}
+
+	data := resp.Data
+	if c.conf.KvVersion == 2 {
+		nested, ok := data["data"].(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("unexpected response for %q", reference)
+		}
+		data = nested
+	}
+
+	value, found := data[key]
+	if !found {
+		return "", fmt.Errorf("key %q not found in %q", key, reference)
+	}
+
+	c.setLease(reference, time.Duration(resp.LeaseDuration)*time.Second)
+	return fmt.Sprint(value), nil
+}
+
+// setLease schedules the refresh of the secret at half of its lease duration, secrets without a lease are never
+// refreshed.
+func (c *Client) setLease(reference string, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if ttl <= 0 {
+		delete(c.refreshAt, reference)
+		return
+	}
+
+	c.refreshAt[reference] = time.Now().Add(max(ttl/2, minRenewInterval))
+	select {
+	case c.leasesChanged <- struct{}{}:
+	default:
+	}
+}
+
+// nextRefresh returns the time the first secret needs to be refreshed or the zero time if no secret has a lease.
+func (c *Client) nextRefresh() time.Time {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var next time.Time
+	for _, refreshAt := range c.refreshAt {
+		if next.IsZero() || refreshAt.Before(next) {
+			next = refreshAt
+		}
+	}
+	return next
+}
+
+// dropLeases forgets the leases that are due at the given time, or all leases if the time is zero, and returns
+// whether any lease has been dropped. Secrets that are still referenced get a new lease when they are resolved again.
+func (c *Client) dropLeases(now time.Time) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var dropped bool
+	for reference, refreshAt := range c.refreshAt {
+		if now.IsZero() || !now.Before(refreshAt) {
+			delete(c.refreshAt, reference)
+			dropped = true
+		}
+	}
+	return dropped
+}
+
+func parseReference(reference string) (mount, path, key string, err error) {
+	ref, found := strings.CutPrefix(reference, ReferencePrefix)
+	if !found {
+		return "", "", "", fmt.Errorf("%q is not a vault reference", reference)
+	}
+
+	fullPath, key, found := strings.Cut(ref, "#")
+	if !found || key == "" {
+		return "", "", "", fmt.Errorf("vault reference %q lacks a key", reference)
+	}
+
+	mount, path, found = strings.Cut(strings.Trim(fullPath, "/"), "/")
+	if !found || mount == "" || path == "" {
+		return "", "", "", fmt.Errorf("vault reference %q must be of the form vault:<mount>/<path>#<key>", reference)
+	}
+
+	return mount, path, key, nil
+}
+
+// KeepAlive renews the client's token at half of its lease duration and logs in again if the token can not be
+// renewed anymore. Secrets with a lease are refreshed at half of their lease duration, and all of them after logging
+// in again, by calling refresh, which is expected to resolve the secrets again. It blocks until the context is
+// canceled.
+func (c *Client) KeepAlive(ctx context.Context, refresh func()) {
+	renewAt := c.tokenRenewal()
+	for {
+		wakeAt := renewAt
+		if refreshAt := c.nextRefresh(); wakeAt.IsZero() || (!refreshAt.IsZero() && refreshAt.Before(wakeAt)) {
+			wakeAt = refreshAt
+		}
+
+		// tokens without a lease duration never expire, nothing to do until secrets with a lease are resolved
+		var wake <-chan time.Time
+		if !wakeAt.IsZero() {
+			wake = time.After(time.Until(wakeAt))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.leasesChanged:
+			continue
+		case <-wake:
+		}
+
+		now := time.Now()
+		loggedIn := false
+		if !renewAt.IsZero() && !now.Before(renewAt) {
+			loggedIn = c.renewToken(ctx)
+			renewAt = c.tokenRenewal()
+		}
+
+		// secrets that have been read using the previous token may have been revoked along with it
+		if loggedIn {
+			c.dropLeases(time.Time{})
+			slog.Info("Logged in to vault again, refreshing secrets")
+			refresh()
+		} else if c.dropLeases(now) {
+			slog.Info("Leases of vault secrets are expiring, refreshing secrets")
+			refresh()
+		}
+	}
+}
+
+// tokenRenewal returns the time the token needs to be renewed or the zero time if it never expires.
+func (c *Client) tokenRenewal() time.Time {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.leaseTtl == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(max(c.leaseTtl/2, minRenewInterval))
+}
+
+// renewToken renews the token or logs in again if it can't be renewed and returns whether a new token has been
+// acquired by logging in.
+func (c *Client) renewToken(ctx context.Context) bool {
+	c.mutex.RLock()
+	canRenew := c.canRenew
+	c.mutex.RUnlock()
+
+	if canRenew {
+		var resp authResponse
+		err := c.do(ctx, http.MethodPost, "auth/token/renew-self", map[string]string{}, true, &resp)
+		if err == nil {
+			slog.Debug("Renewed vault token", "ttl", resp.Auth.LeaseDuration)
+			c.setToken(resp.Auth.ClientToken, time.Duration(resp.Auth.LeaseDuration)*time.Second, resp.Auth.Renewable)
+			return false
+		}
+		slog.Warn("Could not renew vault token, logging in again", "err", err)
+	}
+
+	if err := c.login(ctx); err != nil {
+		slog.Error("Could not log in to vault", "err", err)
+		return false
+	}
+	return true
+}
+
+func (c *Client) do(ctx context.Context, method, path string, payload any, authenticated bool, result any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	url := strings.TrimSuffix(c.conf.Address, "/") + "/v1/" + path
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+
+	if authenticated {
+		c.mutex.RLock()
+		req.Header.Set("X-Vault-Token", c.token)
+		c.mutex.RUnlock()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("vault returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
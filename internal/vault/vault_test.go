@@ -0,0 +1,149 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference string
+		wantMount string
+		wantPath  string
+		wantKey   string
+		wantErr   bool
+	}{
+		{
+			name:      "nested path",
+			reference: "vault:secret/dns-ha/hooks#token",
+			wantMount: "secret",
+			wantPath:  "dns-ha/hooks",
+			wantKey:   "token",
+		},
+		{
+			name:      "missing key",
+			reference: "vault:secret/dns-ha",
+			wantErr:   true,
+		},
+		{
+			name:      "missing path",
+			reference: "vault:secret#token",
+			wantErr:   true,
+		},
+		{
+			name:      "no vault reference",
+			reference: "secret/dns-ha#token",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mount, path, key, err := parseReference(tt.reference)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if mount != tt.wantMount || path != tt.wantPath || key != tt.wantKey {
+				t.Errorf("parseReference() = %q, %q, %q, want %q, %q, %q", mount, path, key, tt.wantMount, tt.wantPath, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestClient_Resolve(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/auth/approle/login", func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload["role_id"] != "role" || payload["secret_id"] != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"auth": {"client_token": "token", "lease_duration": 3600, "renewable": true}}`))
+	})
+	mux.HandleFunc("GET /v1/secret/data/dns-ha", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"data": {"password": "s3cr3t"}, "metadata": {"version": 1}}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewClient(t.Context(), Config{
+		Address: server.URL,
+		Auth:    AuthConfig{Method: AuthApprole, RoleId: "role", SecretId: "secret"},
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	got, err := client.Resolve(t.Context(), "vault:secret/dns-ha#password")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if got != "s3cr3t" {
+		t.Errorf("Resolve() got = %q, want %q", got, "s3cr3t")
+	}
+
+	if _, err := client.Resolve(t.Context(), "vault:secret/dns-ha#missing"); err == nil {
+		t.Error("Resolve() expected error for missing key")
+	}
+
+	if _, err := NewClient(t.Context(), Config{
+		Address: server.URL,
+		Auth:    AuthConfig{Method: AuthApprole, RoleId: "role", SecretId: "wrong"},
+	}); err == nil {
+		t.Error("NewClient() expected error for invalid credentials")
+	}
+}
+
+func TestClient_leases(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/database/creds/dns-ha", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"lease_duration": 3600, "data": {"password": "short-lived"}}`))
+	})
+	mux.HandleFunc("GET /v1/secret/dns-ha", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"lease_duration": 0, "data": {"password": "static"}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := NewClient(t.Context(), Config{
+		Address:   server.URL,
+		KvVersion: 1,
+		Auth:      AuthConfig{Method: AuthToken, Token: "token"},
+	})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	if _, err := client.Resolve(t.Context(), "vault:secret/dns-ha#password"); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if next := client.nextRefresh(); !next.IsZero() {
+		t.Errorf("expected secrets without a lease not to be refreshed, got %v", next)
+	}
+
+	before := time.Now()
+	if _, err := client.Resolve(t.Context(), "vault:database/creds/dns-ha#password"); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	next := client.nextRefresh()
+	if next.Before(before.Add(30*time.Minute)) || next.After(time.Now().Add(30*time.Minute)) {
+		t.Errorf("expected the secret to be refreshed at half of its lease, got %v", next)
+	}
+
+	if client.dropLeases(time.Now()) {
+		t.Error("expected no lease to be due")
+	}
+	if !client.dropLeases(next) {
+		t.Error("expected the lease to be due")
+	}
+	if next := client.nextRefresh(); !next.IsZero() {
+		t.Errorf("expected the due lease to be dropped, got %v", next)
+	}
+}
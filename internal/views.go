@@ -0,0 +1,28 @@
+package internal
+
+import "strings"
+
+// ViewSeparator separates the hostname from the name of the DNS view the records are published in, e.g.
+// "ha.example.com@internal".
+const ViewSeparator = "@"
+
+// SplitView returns the hostname and the view of a configured record name. The view is empty if the records are not
+// published in a view.
+func SplitView(name string) (hostname, view string) {
+	hostname, view, _ = strings.Cut(name, ViewSeparator)
+	return hostname, view
+}
+
+// plainHostnames returns the distinct hostnames of the record names without their views.
+func plainHostnames(names []string) []string {
+	ret := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		hostname, _ := SplitView(name)
+		if !seen[hostname] {
+			seen[hostname] = true
+			ret = append(ret, hostname)
+		}
+	}
+	return ret
+}
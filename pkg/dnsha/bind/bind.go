@@ -0,0 +1,46 @@
+// Package bind exposes the BIND DnsDb backend and the rndc Service implementation.
+package bind
+
+import (
+	"github.com/soerenschneider/dns-ha/internal/dns/bind"
+	"github.com/soerenschneider/dns-ha/internal/service"
+)
+
+type (
+	Bind            = bind.Bind
+	ZoneFileWrapper = bind.ZoneFileWrapper
+	ZoneFile        = bind.ZoneFile
+	ZoneFileOpts    = bind.ZoneFileOpts
+
+	Rndc     = service.Rndc
+	RndcOpts = service.RndcOpts
+)
+
+func NewBind(zone string, fs ZoneFileWrapper) (*Bind, error) {
+	return bind.NewBind(zone, fs)
+}
+
+// NewZoneFile manages the records in the zone file at filePath.
+func NewZoneFile(filePath, zone string, opts ...ZoneFileOpts) (*ZoneFile, error) {
+	return bind.NewZoneFile(filePath, zone, opts...)
+}
+
+// WithBackups keeps the given amount of timestamped backups of the previous versions of the zone file.
+func WithBackups(backups int) ZoneFileOpts {
+	return bind.WithBackups(backups)
+}
+
+// WithCheckzone configures the binary and additional arguments used to validate the zone file.
+func WithCheckzone(binary string, args []string) ZoneFileOpts {
+	return bind.WithCheckzone(binary, args)
+}
+
+// NewRndcService reloads the zone using rndc.
+func NewRndcService(zone string, opts ...RndcOpts) (*Rndc, error) {
+	return service.NewRndcService(zone, opts...)
+}
+
+// WithRndcCommand configures the rndc binary and additional arguments such as the server or key file.
+func WithRndcCommand(binary string, args []string) RndcOpts {
+	return service.WithRndcCommand(binary, args)
+}
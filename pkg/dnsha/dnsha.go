@@ -0,0 +1,208 @@
+// Package dnsha exposes the core of dns-ha, so it can be embedded into other daemons. A RecordManager runs the
+// healthchecks of ManagedDnsRecords and publishes the healthiest records of each hostname to a DnsDb, restarting or
+// reloading the Service afterwards.
+//
+// The types are aliases of dns-ha's internal types, so values can be passed freely between both.
+package dnsha
+
+import (
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal"
+	"github.com/soerenschneider/dns-ha/internal/conf"
+)
+
+type (
+	RecordManager     = internal.RecordManager
+	RecordManagerOpts = internal.RecordManagerOpts
+
+	// DnsDb is the DNS backend the records are published to.
+	DnsDb = internal.DnsDb
+	// DnsDbReader is implemented by DnsDbs that can report the published records, they are kept at startup until
+	// health evidence suggests a different selection.
+	DnsDbReader = internal.DnsDbReader
+	// Service is the DNS server that needs to pick up changes of the DnsDb.
+	Service = internal.Service
+	// ReloadReporter is optionally implemented by a Service to report whether it can be reloaded, a Service that can
+	// only be restarted is rejected in combination with RestartPolicyNever.
+	ReloadReporter = internal.ReloadReporter
+	// Hooks are notified about changes of the published records and restarts of the Service.
+	Hooks = internal.Hooks
+	// OutageHooks is optionally implemented by Hooks to be notified about prolonged outages of a hostname.
+	OutageHooks = internal.OutageHooks
+	// Healthcheck determines whether a record is healthy.
+	Healthcheck = internal.Healthcheck
+	// Strategy selects the published records among the healthy records of a hostname, custom implementations are set
+	// in the HostnamePolicy.
+	Strategy = internal.Strategy
+	// DecisionEngine selects the published records of a hostname instead of its Strategy, e.g. an external policy
+	// service. It's set in the HostnamePolicy.
+	DecisionEngine = internal.DecisionEngine
+
+	DnsRecord            = internal.DnsRecord
+	ManagedDnsRecord     = internal.ManagedDnsRecord
+	ManagedDnsRecordOpts = internal.ManagedDnsRecordOpts
+	HostnamePolicy       = internal.HostnamePolicy
+	DecisionRequest      = internal.DecisionRequest
+	DecisionRecord       = internal.DecisionRecord
+	CheckResult          = internal.CheckResult
+	RecordHistory        = internal.RecordHistory
+	RecordStatus         = internal.RecordStatus
+	HostnameStatus       = internal.HostnameStatus
+	Transition           = internal.Transition
+	HealthGroup          = internal.HealthGroup
+
+	RecordConfig  = conf.RecordConfig
+	StatusConfig  = conf.StatusConfig
+	BackoffConfig = conf.BackoffConfig
+	RetryConfig   = conf.RetryConfig
+)
+
+const (
+	RestartPolicyEscalate = internal.RestartPolicyEscalate
+	RestartPolicyNever    = internal.RestartPolicyNever
+
+	ShutdownKeep       = internal.ShutdownKeep
+	ShutdownPublishAll = internal.ShutdownPublishAll
+	ShutdownRemove     = internal.ShutdownRemove
+
+	AllUnhealthyKeepLast   = internal.AllUnhealthyKeepLast
+	AllUnhealthyPublishAll = internal.AllUnhealthyPublishAll
+	AllUnhealthyFallback   = internal.AllUnhealthyFallback
+	AllUnhealthyRemove     = internal.AllUnhealthyRemove
+
+	StrategyPriority   = internal.StrategyPriority
+	StrategyWeighted   = internal.StrategyWeighted
+	StrategyLatency    = internal.StrategyLatency
+	StrategyAllHealthy = internal.StrategyAllHealthy
+)
+
+var (
+	// ErrReloadNotSupported is returned by a Service that can only be restarted.
+	ErrReloadNotSupported = internal.ErrReloadNotSupported
+	// ErrFlushNotSupported is returned by a Service that can not flush its cache.
+	ErrFlushNotSupported = internal.ErrFlushNotSupported
+	// ErrNotRetryable marks errors of a DnsDb or Service that must not be retried.
+	ErrNotRetryable = internal.ErrNotRetryable
+	// ErrFailureInjectionDisabled is returned if failures are injected without WithFailureInjection.
+	ErrFailureInjectionDisabled = internal.ErrFailureInjectionDisabled
+	// ErrUnknownHostname is returned if an operation refers to a hostname that is not managed.
+	ErrUnknownHostname = internal.ErrUnknownHostname
+	// ErrUnknownRecord is returned if an operation refers to a record that is not managed.
+	ErrUnknownRecord = internal.ErrUnknownRecord
+)
+
+func NewRecordManager(dnsDb DnsDb, service Service, managedRecords map[string][]*ManagedDnsRecord, opts ...RecordManagerOpts) (*RecordManager, error) {
+	return internal.NewRecordManager(dnsDb, service, managedRecords, opts...)
+}
+
+// BuiltinStrategy returns the built-in strategy of the given name or nil if there is none.
+func BuiltinStrategy(name string) Strategy {
+	return internal.BuiltinStrategy(name)
+}
+
+// NewHealthGroup returns a Healthcheck that shares the result of check between all records it is passed to, the check
+// runs at most once per interval.
+func NewHealthGroup(name string, check Healthcheck, interval time.Duration) (*HealthGroup, error) {
+	return internal.NewHealthGroup(name, check, interval)
+}
+
+func NewDnsRecord(conf RecordConfig) (DnsRecord, error) {
+	return internal.NewDnsRecord(conf)
+}
+
+func NewManagedDnsRecord(hostname string, record DnsRecord, statusOpts StatusConfig, healthCheck Healthcheck, opts ...ManagedDnsRecordOpts) (*ManagedDnsRecord, error) {
+	return internal.NewManagedDnsRecord(hostname, record, statusOpts, healthCheck, opts...)
+}
+
+// WithCheckTimeout sets the maximum duration a single healthcheck of the record may take.
+func WithCheckTimeout(timeout time.Duration) ManagedDnsRecordOpts {
+	return internal.WithCheckTimeout(timeout)
+}
+
+// WithBackoff reduces the probe frequency of a record that has been unhealthy for a long time.
+func WithBackoff(policy BackoffConfig) ManagedDnsRecordOpts {
+	return internal.WithBackoff(policy)
+}
+
+// WithHistorySize sets the amount of check results that are kept for the record, zero disables the history.
+func WithHistorySize(size int) ManagedDnsRecordOpts {
+	return internal.WithHistorySize(size)
+}
+
+// WithCheckInterval sets the interval between check cycles.
+func WithCheckInterval(interval time.Duration) RecordManagerOpts {
+	return internal.WithCheckInterval(interval)
+}
+
+// WithCheckJitter delays each healthcheck by a random duration in [0, jitter).
+func WithCheckJitter(jitter time.Duration) RecordManagerOpts {
+	return internal.WithCheckJitter(jitter)
+}
+
+// WithCheckStagger spreads the healthchecks of all records evenly across the given window of each cycle.
+func WithCheckStagger(window time.Duration) RecordManagerOpts {
+	return internal.WithCheckStagger(window)
+}
+
+// WithMaxConcurrentChecks limits the amount of healthchecks that are running at the same time.
+func WithMaxConcurrentChecks(limit int) RecordManagerOpts {
+	return internal.WithMaxConcurrentChecks(limit)
+}
+
+// WithHooks registers hooks that are run before and after records are changed and after the service is restarted.
+func WithHooks(hooks Hooks) RecordManagerOpts {
+	return internal.WithHooks(hooks)
+}
+
+// WithBackendTimeout limits how long a single update of the DnsDb and a single call to the Service may take, defaults
+// to half the check interval.
+func WithBackendTimeout(timeout time.Duration) RecordManagerOpts {
+	return internal.WithBackendTimeout(timeout)
+}
+
+// WithFailureInjection allows injecting simulated check failures using RecordManager.InjectFailure.
+func WithFailureInjection() RecordManagerOpts {
+	return internal.WithFailureInjection()
+}
+
+// WithGuard suspends healthchecks and freezes the published records while the local connectivity check fails.
+func WithGuard(guard Healthcheck, timeout time.Duration) RecordManagerOpts {
+	return internal.WithGuard(guard, timeout)
+}
+
+// WithHostnamePolicies sets the policies for individual hostnames.
+func WithHostnamePolicies(policies map[string]HostnamePolicy) RecordManagerOpts {
+	return internal.WithHostnamePolicies(policies)
+}
+
+// WithRetries retries failed updates of the DnsDb and reloads and restarts of the Service.
+func WithRetries(policy RetryConfig) RecordManagerOpts {
+	return internal.WithRetries(policy)
+}
+
+// WithStateTxt publishes a TXT record alongside each hostname containing the published addresses and the time they
+// last changed.
+func WithStateTxt() RecordManagerOpts {
+	return internal.WithStateTxt()
+}
+
+// WithShutdownPolicy defines what happens to the published records when RecordManager.Run returns.
+func WithShutdownPolicy(policy string) RecordManagerOpts {
+	return internal.WithShutdownPolicy(policy)
+}
+
+// WithRestartCoalescing delays restarts, so changes within the window only lead to a single restart.
+func WithRestartCoalescing(window time.Duration) RecordManagerOpts {
+	return internal.WithRestartCoalescing(window)
+}
+
+// WithRestartMinInterval allows at most one restart per interval.
+func WithRestartMinInterval(interval time.Duration) RecordManagerOpts {
+	return internal.WithRestartMinInterval(interval)
+}
+
+// WithRestartPolicy defines whether the service is restarted after reloading it failed.
+func WithRestartPolicy(policy string, reloadFailuresBeforeRestart int) RecordManagerOpts {
+	return internal.WithRestartPolicy(policy, reloadFailuresBeforeRestart)
+}
@@ -0,0 +1,29 @@
+package dnsha_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/pkg/dnsha"
+	"github.com/soerenschneider/dns-ha/pkg/dnsha/dnshatest"
+)
+
+func TestRecordManager(t *testing.T) {
+	records := []*dnsha.ManagedDnsRecord{
+		dnshatest.NewRecord(t, "my.tld", "10.0.0.1", 10, dnshatest.NewHealthcheck(false)),
+		dnshatest.NewRecord(t, "my.tld", "10.0.0.2", 10, dnshatest.NewHealthcheck(true)),
+	}
+
+	db := dnshatest.NewDb()
+	manager, err := dnsha.NewRecordManager(db, dnshatest.NewService(), map[string][]*dnsha.ManagedDnsRecord{"my.tld": records},
+		dnsha.WithRestartPolicy(dnsha.RestartPolicyNever, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager.CheckRecords(context.Background())
+	if got := db.Published("my.tld"); !reflect.DeepEqual(got, []string{"10.0.0.2"}) {
+		t.Errorf("expected healthy record to be published, got %v", got)
+	}
+}
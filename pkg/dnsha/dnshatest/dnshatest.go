@@ -0,0 +1,256 @@
+// Package dnshatest provides an in-memory DnsDb, a fake Service and fake Healthchecks, so RecordManager behavior can be
+// tested end-to-end without a DNS server or a service manager.
+package dnshatest
+
+import (
+	"context"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/pkg/dnsha"
+)
+
+// StatusConfig changes the state of a record after a single check, so each check cycle has an immediate effect.
+var StatusConfig = dnsha.StatusConfig{HealthyStreak: 1, UnhealthyStreak: 1, InitialHealthyStreak: 1, InitialUnhealthyStreak: 1}
+
+// Db is a DnsDb that keeps the published records in memory. It's safe for concurrent use.
+type Db struct {
+	mutex       sync.Mutex
+	records     map[string][]dnsha.ManagedDnsRecord
+	applies     int
+	applyErr    error
+	validateErr error
+}
+
+// NewDb returns an empty Db.
+func NewDb() *Db {
+	return &Db{records: map[string][]dnsha.ManagedDnsRecord{}}
+}
+
+func (d *Db) Apply(_ context.Context, desired map[string][]dnsha.ManagedDnsRecord) (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.applies++
+	if d.applyErr != nil {
+		return false, d.applyErr
+	}
+
+	changed := false
+	for hostname, records := range desired {
+		current, found := d.records[hostname]
+		if !found || !slices.EqualFunc(current, records, equalRecords) {
+			changed = true
+		}
+		d.records[hostname] = slices.Clone(records)
+	}
+	return changed, nil
+}
+
+func (d *Db) ValidateConfig(_ context.Context) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.validateErr
+}
+
+// PublishedIps implements dnsha.DnsDbReader.
+func (d *Db) PublishedIps(hostname string) ([]string, error) {
+	return d.Published(hostname), nil
+}
+
+// Published returns the sorted addresses that are published for the hostname.
+func (d *Db) Published(hostname string) []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	records, found := d.records[hostname]
+	if !found {
+		return nil
+	}
+	ips := make([]string, 0, len(records))
+	for _, record := range records {
+		if record.Ip != nil {
+			ips = append(ips, record.Ip.String())
+		}
+	}
+	slices.Sort(ips)
+	return ips
+}
+
+// Publish replaces the records of the hostname behind the RecordManager's back, e.g. to simulate records that have
+// been published before startup or manual edits.
+func (d *Db) Publish(hostname string, ips ...string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	records := make([]dnsha.ManagedDnsRecord, 0, len(ips))
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip)
+		dnsType := "AAAA"
+		if parsed.To4() != nil {
+			dnsType = "A"
+		}
+		records = append(records, dnsha.ManagedDnsRecord{Hostname: hostname, DnsRecord: dnsha.DnsRecord{DnsType: dnsType, Ip: parsed}})
+	}
+	d.records[hostname] = records
+}
+
+// Applies returns how often Apply has been called.
+func (d *Db) Applies() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.applies
+}
+
+// FailApply makes Apply return the error until it's called again with nil.
+func (d *Db) FailApply(err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.applyErr = err
+}
+
+// FailValidate makes ValidateConfig return the error until it's called again with nil.
+func (d *Db) FailValidate(err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.validateErr = err
+}
+
+func equalRecords(a, b dnsha.ManagedDnsRecord) bool {
+	return a.DnsType == b.DnsType && a.Ip.Equal(b.Ip) && a.Ttl == b.Ttl && a.Txt == b.Txt
+}
+
+// Service is a Service that counts reloads, restarts and flushed names. It's safe for concurrent use.
+type Service struct {
+	mutex      sync.Mutex
+	reloads    int
+	restarts   int
+	flushed    []string
+	reloadErr  error
+	restartErr error
+}
+
+// NewService returns a Service whose operations succeed.
+func NewService() *Service {
+	return &Service{}
+}
+
+func (s *Service) Reload(_ context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.reloads++
+	return s.reloadErr
+}
+
+func (s *Service) Restart(_ context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.restarts++
+	return s.restartErr
+}
+
+func (s *Service) FlushCache(_ context.Context, names []string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.flushed = append(s.flushed, names...)
+	return nil
+}
+
+// Reloads returns how often Reload has been called.
+func (s *Service) Reloads() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.reloads
+}
+
+// Restarts returns how often Restart has been called.
+func (s *Service) Restarts() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.restarts
+}
+
+// Flushed returns all names that have been flushed from the cache, in order.
+func (s *Service) Flushed() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return slices.Clone(s.flushed)
+}
+
+// FailReload makes Reload return the error until it's called again with nil, e.g. dnsha.ErrReloadNotSupported.
+func (s *Service) FailReload(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.reloadErr = err
+}
+
+// FailRestart makes Restart return the error until it's called again with nil.
+func (s *Service) FailRestart(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.restartErr = err
+}
+
+// Healthcheck is a Healthcheck whose result is controlled by the test. It's safe for concurrent use.
+type Healthcheck struct {
+	mutex   sync.Mutex
+	healthy bool
+	err     error
+	calls   int
+}
+
+// NewHealthcheck returns a Healthcheck that reports the given health.
+func NewHealthcheck(healthy bool) *Healthcheck {
+	return &Healthcheck{healthy: healthy}
+}
+
+func (h *Healthcheck) IsHealthy(_ context.Context) (bool, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.calls++
+	return h.healthy, h.err
+}
+
+// SetHealthy changes the result of all following checks and clears the error.
+func (h *Healthcheck) SetHealthy(healthy bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.healthy = healthy
+	h.err = nil
+}
+
+// SetError makes all following checks fail with the error.
+func (h *Healthcheck) SetError(err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.err = err
+}
+
+// Calls returns how often the check has been run.
+func (h *Healthcheck) Calls() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.calls
+}
+
+// NewRecord returns a record of the hostname using StatusConfig. The record type is derived from the address.
+func NewRecord(t testing.TB, hostname, ip string, prio int, check dnsha.Healthcheck, opts ...dnsha.ManagedDnsRecordOpts) *dnsha.ManagedDnsRecord {
+	t.Helper()
+
+	recordType := "AAAA"
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+		recordType = "A"
+	}
+
+	record, err := dnsha.NewDnsRecord(dnsha.RecordConfig{IP: ip, RecordType: recordType, Prio: prio, Ttl: 60})
+	if err != nil {
+		t.Fatalf("invalid record %s: %v", ip, err)
+	}
+	managed, err := dnsha.NewManagedDnsRecord(hostname, record, StatusConfig, check, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return managed
+}
@@ -0,0 +1,69 @@
+package dnshatest_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/soerenschneider/dns-ha/pkg/dnsha"
+	"github.com/soerenschneider/dns-ha/pkg/dnsha/dnshatest"
+)
+
+func TestFailover(t *testing.T) {
+	primary := dnshatest.NewHealthcheck(true)
+	records := map[string][]*dnsha.ManagedDnsRecord{"my.tld": {
+		dnshatest.NewRecord(t, "my.tld", "10.0.0.1", 20, primary),
+		dnshatest.NewRecord(t, "my.tld", "10.0.0.2", 10, dnshatest.NewHealthcheck(true)),
+	}}
+
+	db := dnshatest.NewDb()
+	svc := dnshatest.NewService()
+	manager, err := dnsha.NewRecordManager(db, svc, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager.CheckRecords(context.Background())
+	if got := db.Published("my.tld"); !reflect.DeepEqual(got, []string{"10.0.0.1"}) {
+		t.Fatalf("expected primary to be published, got %v", got)
+	}
+
+	primary.SetHealthy(false)
+	manager.CheckRecords(context.Background())
+	if got := db.Published("my.tld"); !reflect.DeepEqual(got, []string{"10.0.0.2"}) {
+		t.Fatalf("expected failover to secondary, got %v", got)
+	}
+	if svc.Reloads() != 2 || !reflect.DeepEqual(svc.Flushed(), []string{"my.tld", "my.tld"}) {
+		t.Errorf("expected a reload and flush per change, got %d reloads and %v", svc.Reloads(), svc.Flushed())
+	}
+
+	// drift is repaired in the next cycle
+	db.Publish("my.tld", "10.0.0.9")
+	manager.CheckRecords(context.Background())
+	if got := db.Published("my.tld"); !reflect.DeepEqual(got, []string{"10.0.0.2"}) {
+		t.Errorf("expected drift to be repaired, got %v", got)
+	}
+}
+
+func TestDb_FailApply(t *testing.T) {
+	db := dnshatest.NewDb()
+	db.FailApply(errors.New("text file busy"))
+
+	desired := map[string][]dnsha.ManagedDnsRecord{"my.tld": nil}
+	if _, err := db.Apply(context.Background(), desired); err == nil {
+		t.Fatal("expected Apply to fail")
+	}
+
+	db.FailApply(nil)
+	changed, err := db.Apply(context.Background(), desired)
+	if err != nil || !changed {
+		t.Fatalf("expected records to change, got %v, %v", changed, err)
+	}
+	if changed, _ := db.Apply(context.Background(), desired); changed {
+		t.Error("expected unchanged records to be reported as unchanged")
+	}
+	if db.Applies() != 3 {
+		t.Errorf("expected 3 applies, got %d", db.Applies())
+	}
+}
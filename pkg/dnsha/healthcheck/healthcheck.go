@@ -0,0 +1,49 @@
+// Package healthcheck exposes the healthchecks shipped with dns-ha.
+package healthcheck
+
+import (
+	"net"
+
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/healthcheck"
+	"github.com/soerenschneider/dns-ha/pkg/dnsha"
+)
+
+type (
+	Http        = healthcheck.Http
+	IcmpChecker = healthcheck.IcmpChecker
+	TcpChecker  = healthcheck.TcpChecker
+
+	CheckerOpts = healthcheck.CheckerOpts
+
+	HttpConfig = conf.HttpHealthcheckConfig
+	IcmpConfig = conf.IcmpHealthcheckConfig
+	TcpConfig  = conf.TcpHealthcheckConfig
+)
+
+const (
+	VirtualHostsAll = healthcheck.VirtualHostsAll
+	VirtualHostsAny = healthcheck.VirtualHostsAny
+)
+
+func NewHttp(host string, record dnsha.DnsRecord, args HttpConfig, opts ...CheckerOpts) (*Http, error) {
+	return healthcheck.NewHttp(host, record, args, opts...)
+}
+
+func NewIcmpChecker(record dnsha.DnsRecord, args IcmpConfig, opts ...CheckerOpts) (*IcmpChecker, error) {
+	return healthcheck.NewIcmpChecker(record, args, opts...)
+}
+
+func NewTcpChecker(record dnsha.DnsRecord, args TcpConfig, opts ...CheckerOpts) (*TcpChecker, error) {
+	return healthcheck.NewTcpChecker(record, args, opts...)
+}
+
+// WithSourceIp sends the probes from the given local address.
+func WithSourceIp(ip net.IP) CheckerOpts {
+	return healthcheck.WithSourceIp(ip)
+}
+
+// WithSourceInterface sends the probes via the given local interface.
+func WithSourceInterface(name string) CheckerOpts {
+	return healthcheck.WithSourceInterface(name)
+}
@@ -0,0 +1,38 @@
+// Package status exposes the state machine that decides whether a record is healthy based on streaks of
+// healthcheck results.
+package status
+
+import (
+	"github.com/soerenschneider/dns-ha/internal/conf"
+	"github.com/soerenschneider/dns-ha/internal/status"
+)
+
+type (
+	State        = status.State
+	StateContext = status.StateContext
+
+	Initial   = status.Initial
+	Healthy   = status.Healthy
+	Unhealthy = status.Unhealthy
+	Error     = status.Error
+)
+
+const (
+	InitialStateName   = status.InitialStateName
+	HealthyStateName   = status.HealthyStateName
+	UnhealthyStateName = status.UnhealthyStateName
+	ErrorStateName     = status.ErrorStateName
+
+	ErrorPolicyUnhealthy = status.ErrorPolicyUnhealthy
+	ErrorPolicyFreeze    = status.ErrorPolicyFreeze
+)
+
+// NewUnknownState returns the initial state of a record that has not been checked yet.
+func NewUnknownState(opts conf.StatusConfig) *Initial {
+	return status.NewUnknownState(opts)
+}
+
+// Effective returns the state that is used to select records, records in the Error state keep their previous state.
+func Effective(state State) State {
+	return status.Effective(state)
+}
@@ -0,0 +1,20 @@
+// Package systemd exposes the systemd Service implementation.
+package systemd
+
+import (
+	"github.com/soerenschneider/dns-ha/internal/service"
+)
+
+type (
+	Systemd     = service.Systemd
+	SystemdOpts = service.SystemdOpts
+)
+
+func NewSystemdService(serviceName string, opts ...SystemdOpts) (*Systemd, error) {
+	return service.NewSystemdService(serviceName, opts...)
+}
+
+// WithFlushCommand sets the command that purges a single name from the resolver cache.
+func WithFlushCommand(cmd []string) SystemdOpts {
+	return service.WithFlushCommand(cmd)
+}
@@ -0,0 +1,37 @@
+// Package unbound exposes the unbound DnsDb backend.
+package unbound
+
+import (
+	"github.com/soerenschneider/dns-ha/internal/dns/unbound"
+)
+
+type (
+	Unbound            = unbound.Unbound
+	UnboundConfWrapper = unbound.UnboundConfWrapper
+	FsImpl             = unbound.FsImpl
+	FsImplOpts         = unbound.FsImplOpts
+)
+
+const (
+	CheckconfTargetDbFile = unbound.CheckconfTargetDbFile
+	CheckconfTargetSystem = unbound.CheckconfTargetSystem
+)
+
+func NewUnbound(fs UnboundConfWrapper) (*Unbound, error) {
+	return unbound.NewUnbound(fs)
+}
+
+// NewUnboundConfigWrapper manages the records in the unbound db file at filePath.
+func NewUnboundConfigWrapper(filePath string, createFile bool, opts ...FsImplOpts) (*FsImpl, error) {
+	return unbound.NewUnboundConfigWrapper(filePath, createFile, opts...)
+}
+
+// WithBackups keeps the given amount of timestamped backups of the previous versions of the file.
+func WithBackups(backups int) FsImplOpts {
+	return unbound.WithBackups(backups)
+}
+
+// WithCheckconf configures the binary and additional arguments used to validate the config.
+func WithCheckconf(binary string, args []string, target string) FsImplOpts {
+	return unbound.WithCheckconf(binary, args, target)
+}
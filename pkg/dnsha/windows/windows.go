@@ -0,0 +1,27 @@
+// Package windows exposes the Service implementation using the Windows service control manager.
+package windows
+
+import (
+	"time"
+
+	"github.com/soerenschneider/dns-ha/internal/service"
+)
+
+type (
+	Windows     = service.Windows
+	WindowsOpts = service.WindowsOpts
+)
+
+func NewWindowsService(serviceName string, opts ...WindowsOpts) (*Windows, error) {
+	return service.NewWindowsService(serviceName, opts...)
+}
+
+// WithFlushCommand sets the command that purges a single name from the resolver cache.
+func WithFlushCommand(cmd []string) WindowsOpts {
+	return service.WithWindowsFlushCommand(cmd)
+}
+
+// WithTimeout sets how long to wait for the service to stop and start.
+func WithTimeout(timeout time.Duration) WindowsOpts {
+	return service.WithWindowsTimeout(timeout)
+}